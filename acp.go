@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// acpProtocolVersion is bumped on incompatible changes to the stdio protocol
+const acpProtocolVersion = 1
+
+// JSON-RPC error codes; codeTurnFailed is used when a prompt's turn fails
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeTurnFailed     = -32000
+)
+
+// acpServer lets an editor drive agent sessions over line-delimited JSON-RPC
+// 2.0 on stdin/stdout (agent --acp). The editor calls:
+//
+//	initialize                                  protocol version and capabilities
+//	session/new                                 {sessionId}
+//	session/load     {sessionId}                {sessionId, messages}
+//	session/prompt   {sessionId, prompt}        runs a turn; {stopReason: end_turn|cancelled}
+//	session/cancel   {sessionId}                cancels the running turn (notification)
+//
+// While a turn runs the agent sends session/update notifications carrying
+// each event, and asks before tools that change state run with a
+// session/request_permission request ({sessionId, toolCall}), which the
+// editor answers with {approved: bool}. An error reply denies the call.
+type acpServer struct {
+	// newAgent builds an agent with the provider, tools and stores configured
+	newAgent func() (*Agent, error)
+
+	writeMu sync.Mutex
+	encoder *json.Encoder
+
+	mu       sync.Mutex
+	sessions map[string]*acpSession
+	// calls receives the replies to requests sent to the editor, by ID
+	calls  map[string]chan rpcMessage
+	nextID int
+}
+
+type acpSession struct {
+	agent *Agent
+	// cancel stops the running turn; nil when idle
+	cancel context.CancelFunc
+}
+
+// rpcMessage is any JSON-RPC 2.0 message: a request, notification or reply
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func newACPServer(newAgent func() (*Agent, error)) *acpServer {
+	return &acpServer{newAgent: newAgent, sessions: map[string]*acpSession{}, calls: map[string]chan rpcMessage{}}
+}
+
+// Serve reads messages from in until it ends or ctx is cancelled. Requests
+// are handled concurrently so that permission replies can arrive while a
+// prompt is running.
+func (s *acpServer) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.encoder = json.NewEncoder(out)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.reply(json.RawMessage("null"), nil, &rpcError{codeParseError, err.Error()})
+			continue
+		}
+		if msg.JSONRPC != "2.0" {
+			if msg.ID != nil {
+				s.reply(msg.ID, nil, &rpcError{codeInvalidRequest, "invalid JSON-RPC 2.0 message"})
+			}
+			continue
+		}
+		if msg.Method == "" {
+			s.deliver(msg)
+			continue
+		}
+
+		wg.Add(1)
+		go func(msg rpcMessage) {
+			defer wg.Done()
+			result, err := s.handle(ctx, msg)
+			// notifications get no reply
+			if msg.ID == nil {
+				return
+			}
+			var rerr *rpcError
+			if err != nil && !errors.As(err, &rerr) {
+				rerr = &rpcError{codeTurnFailed, err.Error()}
+			}
+			s.reply(msg.ID, result, rerr)
+		}(msg)
+	}
+	// stop running turns and pending permission requests
+	cancel()
+	s.cancelAll()
+	return scanner.Err()
+}
+
+func (s *acpServer) handle(ctx context.Context, msg rpcMessage) (interface{}, error) {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		Prompt    string `json:"prompt"`
+	}
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": acpProtocolVersion,
+			"agentInfo":       map[string]string{"name": "code-editing-agent", "version": "0.1.0"},
+			"capabilities":    map[string]bool{"loadSession": true, "requestPermission": true},
+		}, nil
+	case "session/new":
+		agent, err := s.newAgent()
+		if err != nil {
+			return nil, err
+		}
+		s.addSession(agent)
+		return map[string]string{"sessionId": agent.session.ID}, nil
+	case "session/load":
+		session, err := s.load(params.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"sessionId": params.SessionID, "messages": session.agent.Messages()}, nil
+	case "session/prompt":
+		return s.prompt(ctx, params.SessionID, params.Prompt)
+	case "session/cancel":
+		s.mu.Lock()
+		if session, ok := s.sessions[params.SessionID]; ok && session.cancel != nil {
+			session.cancel()
+		}
+		s.mu.Unlock()
+		return map[string]interface{}{}, nil
+	default:
+		return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method %q not found", msg.Method)}
+	}
+}
+
+// load returns a session by ID, loading it from the session store if needed
+func (s *acpServer) load(id string) (*acpSession, error) {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	s.mu.Unlock()
+	if ok {
+		return session, nil
+	}
+
+	agent, err := s.newAgent()
+	if err != nil {
+		return nil, err
+	}
+	if agent.store == nil {
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("session %q not found", id)}
+	}
+	saved, err := agent.store.Load(id)
+	if err != nil {
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("session %q not found", id)}
+	}
+	agent.session = saved
+	agent.setConversation(saved.Messages)
+	return s.addSession(agent), nil
+}
+
+// addSession registers an agent's session, sending its events and permission
+// requests to the editor
+func (s *acpServer) addSession(agent *Agent) *acpSession {
+	id := agent.session.ID
+	agent.onEvent = func(e AgentEvent) {
+		// spinner updates are only meaningful on a terminal
+		if e.Type != EventActivity {
+			s.notify("session/update", map[string]interface{}{"sessionId": id, "update": e})
+		}
+	}
+	agent.approve = func(ctx context.Context, call ToolCall) error {
+		var decision struct {
+			Approved bool `json:"approved"`
+		}
+		err := s.call(ctx, "session/request_permission", map[string]interface{}{"sessionId": id, "toolCall": call}, &decision)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || !decision.Approved {
+			return deniedByUser(call)
+		}
+		return nil
+	}
+
+	session := &acpSession{agent: agent}
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+	return session
+}
+
+// prompt runs one turn and returns once it has finished
+func (s *acpServer) prompt(ctx context.Context, id, prompt string) (interface{}, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, &rpcError{codeInvalidParams, "prompt must not be empty"}
+	}
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("session %q not found; create or load it first", id)}
+	}
+	if session.cancel != nil {
+		s.mu.Unlock()
+		return nil, &rpcError{codeInvalidRequest, "a prompt is already running in this session"}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	session.cancel = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		session.cancel = nil
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	agent := session.agent
+	err := agent.runTurn(ctx, prompt)
+	if saveErr := agent.saveSession(); err == nil {
+		err = saveErr
+	}
+	if errors.Is(err, context.Canceled) {
+		return map[string]string{"stopReason": "cancelled"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"stopReason": "end_turn"}, nil
+}
+
+// call sends a request to the editor and decodes its reply into result
+func (s *acpServer) call(ctx context.Context, method string, params, result interface{}) error {
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	reply := make(chan rpcMessage, 1)
+	s.calls[id] = reply
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.calls, id)
+		s.mu.Unlock()
+	}()
+
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver routes a reply from the editor to the request waiting for it
+func (s *acpServer) deliver(msg rpcMessage) {
+	var id string
+	if err := json.Unmarshal(msg.ID, &id); err != nil {
+		// our IDs are strings, but accept numbers too
+		id = string(msg.ID)
+	}
+	s.mu.Lock()
+	reply, ok := s.calls[id]
+	s.mu.Unlock()
+	if ok {
+		reply <- msg
+	}
+}
+
+// cancelAll stops every running turn
+func (s *acpServer) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.cancel != nil {
+			session.cancel()
+		}
+	}
+}
+
+func (s *acpServer) reply(id json.RawMessage, result interface{}, rerr *rpcError) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rerr != nil {
+		msg["error"] = rerr
+	} else {
+		msg["result"] = result
+	}
+	s.send(msg)
+}
+
+func (s *acpServer) notify(method string, params interface{}) {
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *acpServer) send(msg interface{}) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.encoder.Encode(msg)
+}
+
+// runACP serves the stdio protocol until stdin is closed
+func runACP(global *globalOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// stdout carries the protocol, so logs and notices go to stderr
+	logger := global.logger(os.Stderr, slog.LevelWarn)
+	store, err := DefaultSessionStore()
+	if err != nil {
+		logger.Warn("session saving disabled", "error", err)
+	}
+	audit, err := defaultAuditLog(global.cfg())
+	if err != nil {
+		logger.Warn("audit log disabled", "error", err)
+	}
+	newAgent := func() (*Agent, error) {
+		agent := NewAgent(nil, nil, nil)
+		agent.logger = logger
+		if _, err := agent.applyConfig(global.cfg()); err != nil {
+			return nil, err
+		}
+		agent.store = store
+		agent.audit = audit
+		return agent, nil
+	}
+	if _, err := newAgent(); err != nil {
+		return err
+	}
+	return newACPServer(newAgent).Serve(ctx, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acpClient 通过管道与 acpServer 交换消息，模拟编辑器插件
+type acpClient struct {
+	t       *testing.T
+	in      *io.PipeWriter
+	scanner *bufio.Scanner
+	done    chan error
+}
+
+func newACPClient(t *testing.T, newAgent func() *Agent) *acpClient {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	c := &acpClient{t: t, in: inWriter, scanner: bufio.NewScanner(outReader), done: make(chan error, 1)}
+	server := newACPServer(func() (*Agent, error) { return newAgent(), nil })
+	go func() {
+		c.done <- server.Serve(context.Background(), inReader, outWriter)
+		outWriter.Close()
+	}()
+	t.Cleanup(func() { inWriter.Close() })
+	return c
+}
+
+func (c *acpClient) send(msg string) {
+	c.t.Helper()
+	_, err := fmt.Fprintln(c.in, msg)
+	require.NoError(c.t, err)
+}
+
+// next 读取服务端发来的下一条消息
+func (c *acpClient) next() rpcMessage {
+	c.t.Helper()
+	require.True(c.t, c.scanner.Scan(), "服务端输出提前结束")
+	var msg rpcMessage
+	require.NoError(c.t, json.Unmarshal(c.scanner.Bytes(), &msg))
+	return msg
+}
+
+// reply 读取消息直到收到 id 对应的回复，返回其间收到的通知的事件类型
+func (c *acpClient) reply(id string) (rpcMessage, []string) {
+	c.t.Helper()
+	updates := []string{}
+	for {
+		msg := c.next()
+		if msg.Method == "session/update" {
+			var params struct {
+				Update AgentEvent `json:"update"`
+			}
+			require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+			updates = append(updates, params.Update.Type)
+			continue
+		}
+		if msg.Method == "" && string(msg.ID) == id {
+			return msg, updates
+		}
+		c.t.Fatalf("unexpected message %+v", msg)
+	}
+}
+
+func (c *acpClient) newSession() string {
+	c.t.Helper()
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"session/new"}`)
+	msg, _ := c.reply("1")
+	var result struct {
+		SessionID string `json:"sessionId"`
+	}
+	require.NoError(c.t, json.Unmarshal(msg.Result, &result))
+	require.NotEmpty(c.t, result.SessionID)
+	return result.SessionID
+}
+
+func TestACPServer(t *testing.T) {
+	t.Run("初始化、创建会话并运行一轮", func(t *testing.T) {
+		c := newACPClient(t, oneShotAgent)
+		c.send(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`)
+		msg, _ := c.reply("0")
+		assert.JSONEq(t, `{"protocolVersion":1,"agentInfo":{"name":"code-editing-agent","version":"0.1.0"},"capabilities":{"loadSession":true,"requestPermission":true}}`, string(msg.Result))
+
+		id := c.newSession()
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"session/prompt","params":{"sessionId":%q,"prompt":"模块名是什么？"}}`, id))
+		msg, updates := c.reply("2")
+		assert.JSONEq(t, `{"stopReason":"end_turn"}`, string(msg.Result))
+		assert.Equal(t, []string{EventUsage, EventAssistantText, EventToolCall, EventToolResult, EventUsage, EventAssistantText, EventTiming}, updates)
+
+		c.in.Close()
+		assert.NoError(t, <-c.done)
+	})
+
+	t.Run("修改文件前请求权限", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		c := newACPClient(t, editAgent)
+		id := c.newSession()
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"session/prompt","params":{"sessionId":%q,"prompt":"创建文件"}}`, id))
+
+		var request rpcMessage
+		for request.Method != "session/request_permission" {
+			request = c.next()
+		}
+		var params struct {
+			SessionID string   `json:"sessionId"`
+			ToolCall  ToolCall `json:"toolCall"`
+		}
+		require.NoError(t, json.Unmarshal(request.Params, &params))
+		assert.Equal(t, id, params.SessionID)
+		assert.Equal(t, "edit_file", params.ToolCall.Name)
+		assert.NoFileExists(t, "hello.txt")
+
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"approved":true}}`, request.ID))
+		msg, updates := c.reply("2")
+		assert.JSONEq(t, `{"stopReason":"end_turn"}`, string(msg.Result))
+		assert.Contains(t, updates, EventFileEdit)
+		assert.FileExists(t, "hello.txt")
+	})
+
+	t.Run("拒绝权限请求时工具不执行", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		c := newACPClient(t, editAgent)
+		id := c.newSession()
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"session/prompt","params":{"sessionId":%q,"prompt":"创建文件"}}`, id))
+
+		var request rpcMessage
+		for request.Method != "session/request_permission" {
+			request = c.next()
+		}
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"not supported"}}`, request.ID))
+		msg, updates := c.reply("2")
+		assert.JSONEq(t, `{"stopReason":"end_turn"}`, string(msg.Result))
+		assert.Contains(t, updates, EventToolError)
+		assert.NoFileExists(t, "hello.txt")
+	})
+
+	t.Run("取消正在运行的一轮", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		c := newACPClient(t, editAgent)
+		id := c.newSession()
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"session/prompt","params":{"sessionId":%q,"prompt":"创建文件"}}`, id))
+		for c.next().Method != "session/request_permission" {
+		}
+
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","method":"session/cancel","params":{"sessionId":%q}}`, id))
+		msg, _ := c.reply("2")
+		assert.JSONEq(t, `{"stopReason":"cancelled"}`, string(msg.Result))
+	})
+
+	t.Run("错误的请求", func(t *testing.T) {
+		c := newACPClient(t, oneShotAgent)
+		c.send(`not json`)
+		assert.Equal(t, codeParseError, c.next().Error.Code)
+		c.send(`{"jsonrpc":"2.0","id":1,"method":"nope"}`)
+		msg, _ := c.reply("1")
+		assert.Equal(t, codeMethodNotFound, msg.Error.Code)
+		c.send(`{"jsonrpc":"2.0","id":2,"method":"session/prompt","params":{"sessionId":"nope","prompt":"你好"}}`)
+		msg, _ = c.reply("2")
+		assert.Equal(t, codeInvalidParams, msg.Error.Code)
+		c.send(`{"jsonrpc":"2.0","id":3,"method":"session/load","params":{"sessionId":"nope"}}`)
+		msg, _ = c.reply("3")
+		assert.Equal(t, codeInvalidParams, msg.Error.Code)
+
+		id := c.newSession()
+		c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":4,"method":"session/prompt","params":{"sessionId":%q,"prompt":" "}}`, id))
+		msg, _ = c.reply("4")
+		assert.Equal(t, codeInvalidParams, msg.Error.Code)
+	})
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"agent/diff"
+	"agent/search"
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+)
+
+const applyPrompt = `Apply the following change to the file %s, and to no other file:
+
+%s
+
+Read the file, then make the change with edit_file. The other tools are read-only; use them to look up
+definitions or usages when the change needs it. If the change does not apply to this file, leave it
+unchanged. Reply with one sentence describing what you changed.`
+
+// applyTools are the tools offered while applying a batch instruction to a file;
+// edit_file is limited to that file
+var applyTools = []string{"read_file", "edit_file", "grep", "glob", "go_to_definition", "find_references"}
+
+// applyResult is the outcome of applying the instruction to one file
+type applyResult struct {
+	Path    string
+	Mode    os.FileMode
+	Before  string
+	After   string
+	Summary string
+	Err     error
+}
+
+func newApplyCommand(global *globalOptions) *cobra.Command {
+	var instruction string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "apply -t <instruction> <glob>...",
+		Short: "Apply an instruction to each file matching a glob and show the combined diff",
+		Long: "Apply an instruction to each file matching the globs, one file at a time with only the file tools,\n" +
+			"for mechanical changes across a repository such as API migrations. The combined diff of all files\n" +
+			"is printed to stdout and progress to stderr; with --dry-run the files are restored afterwards.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if instruction == "" {
+				return fmt.Errorf("no instruction given: use -t")
+			}
+			files, err := applyFiles(cmd.Context(), args)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no files match %v", args)
+			}
+
+			logger := global.logger(os.Stderr, slog.LevelWarn)
+			newAgent := func() (*Agent, error) {
+				agent := NewAgent(nil, nil, nil)
+				agent.logger = logger
+				_, err := agent.applyConfig(global.cfg())
+				return agent, err
+			}
+			results := applyBatch(cmd.Context(), newAgent, instruction, files, cmd.ErrOrStderr())
+
+			failed := 0
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+				}
+				fmt.Fprint(cmd.OutOrStdout(), diff.Unified(filepath.ToSlash(r.Path), r.Before, r.After))
+				if dryRun && r.Before != r.After {
+					if err := os.WriteFile(r.Path, []byte(r.Before), r.Mode); err != nil {
+						return err
+					}
+				}
+			}
+			if failed > 0 {
+				return &exitError{code: 1, err: fmt.Errorf("%d of %d files failed", failed, len(results))}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&instruction, "task", "t", "", "the change to make in each file")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the diff, then restore the files")
+	return cmd
+}
+
+// applyFiles returns the files in the current directory that match any of the
+// globs, skipping files ignored by .gitignore
+func applyFiles(ctx context.Context, globs []string) ([]string, error) {
+	var files []string
+	for _, glob := range globs {
+		pattern, err := search.CompilePattern(glob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+		matches, _, err := search.Glob(ctx, ".", pattern, search.Options{})
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if !slices.Contains(files, match) {
+				files = append(files, match)
+			}
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// applyBatch applies the instruction to each file in turn, with a fresh agent
+// per file so one file's conversation does not leak into the next
+func applyBatch(ctx context.Context, newAgent func() (*Agent, error), instruction string, files []string, progress io.Writer) []applyResult {
+	results := make([]applyResult, 0, len(files))
+	for i, path := range files {
+		r := applyFile(ctx, newAgent, instruction, path)
+		results = append(results, r)
+
+		added, removed := diff.Stat(diff.Unified(path, r.Before, r.After))
+		status := fmt.Sprintf("+%d -%d", added, removed)
+		switch {
+		case r.Err != nil:
+			status = "failed: " + r.Err.Error()
+		case r.Before == r.After:
+			status = "unchanged"
+		case r.Summary != "":
+			status += " " + firstLine(r.Summary)
+		}
+		fmt.Fprintf(progress, "[%d/%d] %s: %s\n", i+1, len(files), path, status)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results
+}
+
+// applyFile runs one agent turn that applies the instruction to path
+func applyFile(ctx context.Context, newAgent func() (*Agent, error), instruction, path string) applyResult {
+	r := applyResult{Path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Mode = info.Mode().Perm()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Before, r.After = string(data), string(data)
+
+	agent, err := newAgent()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	agent.setTools(restrictTools(agent.toolDefinitions(), path))
+	agent.onEvent = func(e AgentEvent) {
+		if e.Type == EventAssistantText {
+			r.Summary = e.Content
+		}
+	}
+	r.Err = agent.runTurn(ctx, fmt.Sprintf(applyPrompt, filepath.ToSlash(path), instruction))
+
+	if data, err := os.ReadFile(path); err == nil {
+		r.After = string(data)
+	} else if r.Err == nil {
+		r.Err = err
+	}
+	return r
+}
+
+// restrictTools keeps the tools in applyTools and limits edit_file to path
+func restrictTools(defs []tools.ToolDefinition, path string) []tools.ToolDefinition {
+	var kept []tools.ToolDefinition
+	for _, def := range defs {
+		if !slices.Contains(applyTools, def.Name) {
+			continue
+		}
+		if def.Name == "edit_file" {
+			edit := def.Function
+			def.Function = func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+				if target := toolPathHint(input); filepath.Clean(target) != filepath.Clean(path) {
+					return tools.ErrorResult(fmt.Errorf("edit of %s %w: only %s may be changed", target, tools.ErrDenied, path))
+				}
+				return edit(ctx, input)
+			}
+			def.Stream = nil
+		}
+		kept = append(kept, def)
+	}
+	return kept
+}
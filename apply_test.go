@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBatch(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("a.go", []byte("package a\n\nvar x = ioutil.ReadAll\n"), 0644))
+	require.NoError(t, os.WriteFile("b.go", []byte("package a\n"), 0644))
+	require.NoError(t, os.WriteFile("notes.txt", []byte("ioutil\n"), 0644))
+
+	files, err := applyFiles(context.Background(), []string{"*.go", "a.go"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.go", "b.go"}, files)
+
+	replies := map[string][]*Response{
+		"a.go": {
+			{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: json.RawMessage(`{"path":"a.go","old_str":"ioutil.ReadAll","new_str":"io.ReadAll"}`)}}},
+			{Content: "Replaced ioutil.ReadAll with io.ReadAll."},
+		},
+		"b.go": {
+			{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: json.RawMessage(`{"path":"notes.txt","old_str":"ioutil","new_str":"io"}`)}}},
+			{Content: "Nothing to change."},
+		},
+	}
+	var providers []*mockProvider
+	var agents []*Agent
+	newAgent := func() (*Agent, error) {
+		defs, err := enabledTools(config.Default(), nil)
+		if err != nil {
+			return nil, err
+		}
+		provider := &mockProvider{responses: replies[files[len(providers)]]}
+		providers = append(providers, provider)
+		agents = append(agents, NewAgent(provider, nil, defs))
+		return agents[len(agents)-1], nil
+	}
+
+	var progress bytes.Buffer
+	results := applyBatch(context.Background(), newAgent, "use io.ReadAll instead of ioutil.ReadAll", files, &progress)
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "package a\n\nvar x = io.ReadAll\n", results[0].After)
+	assert.Equal(t, results[1].Before, results[1].After)
+	assert.Equal(t, "[1/2] a.go: +1 -1 Replaced ioutil.ReadAll with io.ReadAll.\n[2/2] b.go: unchanged\n", progress.String())
+
+	assert.Contains(t, providers[0].calls[0][0].Content, "use io.ReadAll instead of ioutil.ReadAll")
+	assert.NotContains(t, toolNames(agents[0].toolDefinitions()), "shell", "只提供文件工具")
+	last := providers[1].calls[1][len(providers[1].calls[1])-1]
+	assert.Contains(t, last.Content, "only b.go may be changed", "只能修改当前文件")
+	data, err := os.ReadFile("notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "ioutil\n", string(data))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/redact"
+	"agent/tools"
+)
+
+// Audit decisions: whether a tool call was allowed to run
+const (
+	auditAllowed = "allowed"
+	auditDenied  = "denied"
+)
+
+// auditRecord is one line of the audit log
+type auditRecord struct {
+	Time     time.Time       `json:"time"`
+	Session  string          `json:"session"`
+	Dir      string          `json:"dir"`
+	Tool     string          `json:"tool"`
+	ID       string          `json:"id"`
+	Input    json.RawMessage `json:"input"`
+	Decision string          `json:"decision"`
+	// Status is ok, error or cancelled; Error holds the reason for the last two
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	ResultHash string `json:"result_sha256,omitempty"`
+	ResultSize int    `json:"result_bytes"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// auditLog appends tool calls to a JSONL file. The file is only ever opened
+// for appending, so earlier records are never rewritten.
+type auditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// defaultAuditLog returns the audit log configured by cfg, in the agent
+// config directory unless audit.path is set; nil when audit.disabled is set
+func defaultAuditLog(cfg *config.Config) (*auditLog, error) {
+	if cfg.Audit.Disabled {
+		return nil, nil
+	}
+	if cfg.Audit.Path != "" {
+		path, err := cfg.ResolvePath(cfg.Audit.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &auditLog{path: path}, nil
+	}
+	dir, err := agentConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{path: filepath.Join(dir, "audit.jsonl")}, nil
+}
+
+// Append writes one record as a line of JSON
+func (l *auditLog) Append(record auditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordAudit appends a finished tool call to the audit log
+func (a *Agent) recordAudit(call ToolCall, start time.Time, elapsed time.Duration, result string, err error) {
+	if a.audit == nil {
+		return
+	}
+	if err := a.audit.Append(newAuditRecord(a.session.ID, call, start, elapsed, result, err)); err != nil {
+		a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("Audit log: %s", err)})
+	}
+}
+
+// newAuditRecord describes a finished tool call. Secrets in the arguments
+// are masked; the result is kept only as a SHA-256 hash.
+func newAuditRecord(session string, call ToolCall, start time.Time, elapsed time.Duration, result string, err error) auditRecord {
+	dir, _ := filepath.Abs(currentWorkspace.Root)
+	record := auditRecord{
+		Time:       start.UTC(),
+		Session:    session,
+		Dir:        dir,
+		Tool:       call.Name,
+		ID:         call.ID,
+		Input:      json.RawMessage(redact.String(string(call.Input))),
+		Decision:   auditAllowed,
+		Status:     "ok",
+		ResultSize: len(result),
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if !json.Valid(record.Input) {
+		record.Input, _ = json.Marshal(redact.String(string(call.Input)))
+	}
+	switch {
+	case errors.Is(err, tools.ErrDenied):
+		record.Decision, record.Status = auditDenied, "error"
+	case errors.Is(err, context.Canceled):
+		record.Status = "cancelled"
+	case err != nil:
+		record.Status = "error"
+	}
+	if err != nil {
+		record.Error = redact.String(err.Error())
+	} else {
+		sum := sha256.Sum256([]byte(result))
+		record.ResultHash = hex.EncodeToString(sum[:])
+	}
+	return record
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAuditLog(t *testing.T, path string) []auditRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	records := []auditRecord{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record auditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	require.NoError(t, os.WriteFile("notes.txt", []byte("remember the milk"), 0644))
+
+	cfg := config.Default()
+	cfg.Shell.Deny = []string{"make deploy"}
+	cfg.Audit.Path = filepath.Join(dir, "logs", "audit.jsonl")
+	defs, err := enabledTools(cfg, nil)
+	require.NoError(t, err)
+	audit, err := defaultAuditLog(cfg)
+	require.NoError(t, err)
+
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{
+			{ID: "1", Name: "read_file", Input: json.RawMessage(`{"path":"notes.txt"}`)},
+			{ID: "2", Name: "shell", Input: json.RawMessage(`{"command":"make deploy TOKEN_SECRET=abcdefghijkl"}`)},
+			{ID: "3", Name: "read_file", Input: json.RawMessage(`{"path":"missing.txt"}`)},
+		}},
+		{Content: "done"},
+	}}
+	agent := NewAgent(provider, nil, defs)
+	agent.audit = audit
+	agent.onEvent = func(AgentEvent) {}
+	require.NoError(t, agent.runTurn(context.Background(), "go"))
+
+	records := readAuditLog(t, cfg.Audit.Path)
+	require.Len(t, records, 3)
+
+	t.Run("成功的调用记录结果哈希", func(t *testing.T) {
+		sum := sha256.Sum256([]byte("remember the milk"))
+		assert.Equal(t, "read_file", records[0].Tool)
+		assert.Equal(t, agent.session.ID, records[0].Session)
+		assert.JSONEq(t, `{"path":"notes.txt"}`, string(records[0].Input))
+		assert.Equal(t, auditAllowed, records[0].Decision)
+		assert.Equal(t, "ok", records[0].Status)
+		assert.Equal(t, hex.EncodeToString(sum[:]), records[0].ResultHash)
+		assert.False(t, records[0].Time.IsZero())
+	})
+
+	t.Run("被拒绝的调用", func(t *testing.T) {
+		assert.Equal(t, auditDenied, records[1].Decision)
+		assert.Contains(t, records[1].Error, "deny pattern")
+		assert.NotContains(t, string(records[1].Input), "abcdefghijkl", "参数中的密钥被屏蔽")
+		assert.Empty(t, records[1].ResultHash)
+	})
+
+	t.Run("执行失败的调用", func(t *testing.T) {
+		assert.Equal(t, auditAllowed, records[2].Decision)
+		assert.Equal(t, "error", records[2].Status)
+	})
+
+	t.Run("只追加不覆盖", func(t *testing.T) {
+		provider.responses = []*Response{
+			{ToolCalls: []ToolCall{{ID: "4", Name: "read_file", Input: json.RawMessage(`{"path":"notes.txt"}`)}}},
+			{Content: "done"},
+		}
+		require.NoError(t, agent.runTurn(context.Background(), "again"))
+		assert.Len(t, readAuditLog(t, cfg.Audit.Path), 4)
+	})
+
+	t.Run("可以关闭", func(t *testing.T) {
+		cfg.Audit.Disabled = true
+		audit, err := defaultAuditLog(cfg)
+		require.NoError(t, err)
+		assert.Nil(t, audit)
+	})
+}
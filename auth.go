@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// keychainService is the service name API keys are stored under in the OS
+// keychain (macOS Keychain, Secret Service, Windows Credential Manager)
+const keychainService = "code-editing-agent"
+
+// defaultKeyEnv is the environment variable each provider reads its key from
+var defaultKeyEnv = map[string]string{
+	"anthropic": "ANTHROPIC_API_KEY",
+	"openai":    "OPENAI_API_KEY",
+	"gemini":    "GEMINI_API_KEY",
+	"github":    "GITHUB_TOKEN",
+	"gitlab":    "GITLAB_TOKEN",
+	"jira":      "JIRA_API_TOKEN",
+	"linear":    "LINEAR_API_KEY",
+	"slack":     "SLACK_BOT_TOKEN",
+	"slack-app": "SLACK_APP_TOKEN",
+	"discord":   "DISCORD_BOT_TOKEN",
+	"telegram":  "TELEGRAM_BOT_TOKEN",
+}
+
+// lookupAPIKey returns the key for provider from keyEnv, falling back to the
+// OS keychain, along with where it was found. A missing or unavailable
+// keychain is not an error: the key is simply not set.
+func lookupAPIKey(provider, keyEnv string) (key, source string) {
+	if keyEnv == "" {
+		keyEnv = defaultKeyEnv[provider]
+	}
+	if key := os.Getenv(keyEnv); key != "" {
+		return key, keyEnv
+	}
+	key, err := keyring.Get(keychainService, provider)
+	if err != nil {
+		return "", ""
+	}
+	return key, "keychain"
+}
+
+func newAuthCommand(global *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Store provider API keys (and GitHub, GitLab, tracker and chat bot tokens) in the OS keychain",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "login [provider]",
+		Short: "Save an API key to the keychain (read from the terminal, or stdin when piped)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			provider, err := authProvider(global, args)
+			if err != nil {
+				return err
+			}
+			key, err := readAPIKey(cmd.InOrStdin(), cmd.ErrOrStderr(), provider)
+			if err != nil {
+				return err
+			}
+			if err := keyring.Set(keychainService, provider, key); err != nil {
+				return fmt.Errorf("save key to keychain: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Saved %s API key %s to the keychain\n", provider, maskSecret(key))
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "logout [provider]",
+		Short: "Remove a saved API key from the keychain",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			provider, err := authProvider(global, args)
+			if err != nil {
+				return err
+			}
+			err = keyring.Delete(keychainService, provider)
+			if errors.Is(err, keyring.ErrNotFound) {
+				return fmt.Errorf("no %s API key in the keychain", provider)
+			}
+			if err != nil {
+				return fmt.Errorf("remove key from keychain: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %s API key from the keychain\n", provider)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show where each provider's API key comes from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, provider := range []string{"anthropic", "openai", "github", "gitlab", "jira", "linear", "slack", "slack-app", "discord", "telegram"} {
+				keyEnv := ""
+				if global.cfg().Provider == provider {
+					keyEnv = global.cfg().APIKeyEnv
+				}
+				switch provider {
+				case "github":
+					keyEnv = global.cfg().GitHub.TokenEnv
+				case "jira":
+					keyEnv = global.cfg().Tracker.Jira.TokenEnv
+				case "linear":
+					keyEnv = global.cfg().Tracker.Linear.TokenEnv
+				}
+				key, source := lookupAPIKey(provider, keyEnv)
+				if key == "" {
+					fmt.Fprintf(cmd.OutOrStdout(), "%-10s not set\n", provider)
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%-10s %s (from %s)\n", provider, maskSecret(key), source)
+			}
+			return nil
+		},
+	})
+	return cmd
+}
+
+// authProvider is the provider named in args, else the configured one
+func authProvider(global *globalOptions, args []string) (string, error) {
+	provider := global.cfg().Provider
+	if len(args) == 1 {
+		provider = args[0]
+	}
+	if provider == "" {
+		provider = "anthropic"
+	}
+	if _, ok := defaultKeyEnv[provider]; !ok {
+		return "", fmt.Errorf("unknown provider %q", provider)
+	}
+	return provider, nil
+}
+
+// readAPIKey prompts for a key without echoing it when in is a terminal,
+// and otherwise reads the first line so keys can be piped in
+func readAPIKey(in io.Reader, prompt io.Writer, provider string) (string, error) {
+	var key string
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprintf(prompt, "%s API key: ", provider)
+		data, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(prompt)
+		if err != nil {
+			return "", err
+		}
+		key = string(data)
+	} else {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		key = line
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("no API key given")
+	}
+	return key, nil
+}
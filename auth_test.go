@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+func TestLookupAPIKey(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("MY_KEY", "")
+
+	t.Run("未设置", func(t *testing.T) {
+		key, source := lookupAPIKey("anthropic", "")
+		assert.Empty(t, key)
+		assert.Empty(t, source)
+	})
+
+	t.Run("回退到钥匙串", func(t *testing.T) {
+		require.NoError(t, keyring.Set(keychainService, "anthropic", "sk-from-keychain"))
+		key, source := lookupAPIKey("anthropic", "")
+		assert.Equal(t, "sk-from-keychain", key)
+		assert.Equal(t, "keychain", source)
+	})
+
+	t.Run("环境变量优先", func(t *testing.T) {
+		t.Setenv("MY_KEY", "sk-from-env")
+		key, source := lookupAPIKey("anthropic", "MY_KEY")
+		assert.Equal(t, "sk-from-env", key)
+		assert.Equal(t, "MY_KEY", source)
+	})
+}
+
+func TestAuthCommand(t *testing.T) {
+	keyring.MockInit()
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	t.Run("从标准输入保存", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runCommand(newRootCommand(), strings.NewReader("sk-openai-123456\n"), &out, "auth", "login", "openai", "--config", configPath)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "sk-o****3456")
+		assert.NotContains(t, out.String(), "sk-openai-123456")
+
+		key, err := keyring.Get(keychainService, "openai")
+		require.NoError(t, err)
+		assert.Equal(t, "sk-openai-123456", key)
+	})
+
+	t.Run("状态显示来源", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(newRootCommand(), nil, &out, "auth", "status", "--config", configPath))
+		assert.Contains(t, out.String(), "from keychain")
+		assert.Contains(t, out.String(), "anthropic  not set")
+	})
+
+	t.Run("钥匙串中的密钥用于创建 provider", func(t *testing.T) {
+		require.NoError(t, keyring.Set(keychainService, "openai", "sk-openai-123456"))
+		cfg := config.Default()
+		cfg.Provider = "openai"
+		_, name, err := newProvider(cfg, newLogger(io.Discard, logOptions{}))
+		require.NoError(t, err)
+		assert.Contains(t, name, "OpenAI")
+	})
+
+	t.Run("删除", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(newRootCommand(), nil, &out, "auth", "logout", "openai", "--config", configPath))
+		_, err := keyring.Get(keychainService, "openai")
+		assert.ErrorIs(t, err, keyring.ErrNotFound)
+
+		assert.Error(t, runCommand(newRootCommand(), nil, &out, "auth", "logout", "openai", "--config", configPath))
+	})
+
+	t.Run("空密钥和未知 provider", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Error(t, runCommand(newRootCommand(), strings.NewReader("\n"), &out, "auth", "login", "openai", "--config", configPath))
+		assert.Error(t, runCommand(newRootCommand(), nil, &out, "auth", "login", "gemini", "--config", configPath))
+	})
+}
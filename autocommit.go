@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"agent/gitutil"
+	"agent/i18n"
+	"agent/theme"
+)
+
+const commitMessagePrompt = `Write a git commit message in the Conventional Commits format for the diff below.
+The changes were made in response to this instruction: %q
+
+Reply with the commit message only: a single "type(scope): summary" line of at most 72 characters,
+optionally followed by a blank line and a short body. Do not wrap it in code fences.
+
+%s`
+
+// maxCommitDiff limits how much of the diff is sent to the model for message generation
+const maxCommitDiff = 16 * 1024
+
+// commitTurnChanges stages and commits files that changed between the two
+// snapshots, using a model-generated commit message that references the instruction.
+// Files that already had uncommitted changes before the turn are left for the
+// user to commit, since they hold the user's own edits too.
+func (a *Agent) commitTurnChanges(ctx context.Context, instruction string, before gitutil.Snapshot) error {
+	after, err := a.repo.Snapshot()
+	if err != nil {
+		return err
+	}
+	var paths, dirty []string
+	for _, path := range gitutil.ChangedPaths(before, after) {
+		if _, ok := before[path]; ok {
+			dirty = append(dirty, path)
+			continue
+		}
+		// a path that is gone and untracked has nothing to stage
+		if _, err := os.Lstat(filepath.Join(a.repo.Dir, path)); os.IsNotExist(err) && !a.repo.Tracked(path) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(dirty) > 0 {
+		a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: %s had uncommitted changes before the turn", theme.Warning(i18n.T("Not committed")), strings.Join(dirty, ", "))})
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if err := a.repo.Add(paths...); err != nil {
+		return err
+	}
+	diff, err := a.repo.StagedDiff(paths...)
+	if err != nil {
+		return err
+	}
+
+	message := a.generateCommitMessage(ctx, instruction, diff)
+	if err := a.repo.Commit(message, paths...); err != nil {
+		return err
+	}
+
+	a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: %s (%d files)", theme.Success(i18n.T("Committed")), firstLine(message), len(paths))})
+	return nil
+}
+
+func (a *Agent) generateCommitMessage(ctx context.Context, instruction, diff string) string {
+	if len(diff) > maxCommitDiff {
+		// cut on a rune boundary so the prompt stays valid UTF-8
+		n := maxCommitDiff
+		for n > 0 && !utf8.RuneStart(diff[n]) {
+			n--
+		}
+		diff = diff[:n] + "\n... (diff truncated)"
+	}
+
+	summary := ""
+	prompt := fmt.Sprintf(commitMessagePrompt, instruction, diff)
+	stopProgress := a.showProgress(i18n.T("writing commit message…"))
+	response, err := a.provider.RunInference(ctx, []Message{{Role: "user", Content: prompt}}, nil)
+	stopProgress()
+	if err == nil {
+		summary = cleanCommitMessage(response.Content)
+	}
+	if summary == "" {
+		summary = "chore: apply agent changes"
+	}
+
+	return fmt.Sprintf("%s\n\nInstruction: %s\n", summary, instruction)
+}
+
+// cleanCommitMessage strips code fences and surrounding whitespace from a model reply
+func cleanCommitMessage(text string) string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
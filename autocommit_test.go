@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"agent/gitutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanCommitMessage(t *testing.T) {
+	assert.Equal(t, "feat: add x", cleanCommitMessage("```\nfeat: add x\n```\n"))
+	assert.Equal(t, "fix(io): y\n\nbody", cleanCommitMessage("  fix(io): y\n\nbody  "))
+	assert.Equal(t, "", cleanCommitMessage("```"))
+}
+
+func TestCommitTurnChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git 不可用")
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+		{"config", "commit.gpgsign", "false"},
+		{"commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		require.NoError(t, cmd.Run())
+	}
+	repo, err := gitutil.Open(dir)
+	require.NoError(t, err)
+
+	provider := &mockProvider{responses: []*Response{{Content: "```\nfeat: add greeting\n```"}}}
+	agent := NewAgent(provider, nil, nil)
+	agent.repo = repo
+
+	t.Run("没有改动时不提交", func(t *testing.T) {
+		before, err := repo.Snapshot()
+		require.NoError(t, err)
+		require.NoError(t, agent.commitTurnChanges(context.Background(), "noop", before))
+		assert.Empty(t, provider.calls)
+	})
+
+	t.Run("提交本轮改动", func(t *testing.T) {
+		before, err := repo.Snapshot()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hi\n"), 0644))
+
+		require.NoError(t, agent.commitTurnChanges(context.Background(), "add a greeting file", before))
+		require.Len(t, provider.calls, 1)
+		assert.Contains(t, provider.calls[0][0].Content, "add a greeting file")
+		assert.Contains(t, provider.calls[0][0].Content, "+hi")
+
+		log, err := repo.Run("log", "-1", "--format=%B")
+		require.NoError(t, err)
+		assert.Contains(t, log, "feat: add greeting")
+		assert.Contains(t, log, "Instruction: add a greeting file")
+	})
+
+	t.Run("不提交本轮之前就有改动的文件", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hi\nmine\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "scratch.txt"), []byte("notes\n"), 0644))
+		before, err := repo.Snapshot()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hi\nmine\nagent\n"), 0644))
+		require.NoError(t, os.Remove(filepath.Join(dir, "scratch.txt")))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "bye.txt"), []byte("bye\n"), 0644))
+
+		provider.responses = append(provider.responses, &Response{Content: "feat: add farewell"})
+		out := captureStdout(func() {
+			require.NoError(t, agent.commitTurnChanges(context.Background(), "add a farewell file", before))
+		})
+		assert.Contains(t, ansiPattern.ReplaceAllString(out, ""), "Not committed: hello.txt, scratch.txt had uncommitted changes before the turn")
+
+		files, err := repo.Run("show", "--name-only", "--format=", "HEAD")
+		require.NoError(t, err)
+		assert.Equal(t, "bye.txt\n", files, "只提交本轮新建的文件")
+		status, err := repo.Status()
+		require.NoError(t, err)
+		assert.Contains(t, status, "hello.txt", "用户的改动留在工作区")
+	})
+}
+
+func TestGenerateCommitMessageTruncatesDiff(t *testing.T) {
+	provider := &mockProvider{responses: []*Response{{Content: "docs: 更新说明"}}}
+	agent := NewAgent(provider, nil, nil)
+	diff := "+" + strings.Repeat("说", maxCommitDiff)
+	agent.generateCommitMessage(context.Background(), "更新说明", diff)
+	require.Len(t, provider.calls, 1)
+	prompt := provider.calls[0][0].Content
+	assert.True(t, utf8.ValidString(prompt), "在字符边界截断")
+	assert.Contains(t, prompt, "... (diff truncated)")
+}
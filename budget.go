@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+)
+
+// budgetWarnFraction is the share of the budget at which a warning is printed
+const budgetWarnFraction = 0.8
+
+// ErrBudgetExhausted is returned for inference refused by a strict budget
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// Budget caps the tokens and/or dollars a session may spend; zero limits are unlimited
+type Budget struct {
+	MaxTokens int64
+	MaxCost   float64
+	// Strict refuses inference once the budget is exhausted, for runs
+	// without a user to ask
+	Strict bool
+
+	usedTokens int64
+	usedCost   float64
+	warned     bool
+}
+
+// Record adds the usage of one inference call to the budget
+func (b *Budget) Record(model string, usage Usage) {
+	b.usedTokens += usage.Total()
+	b.usedCost += estimateCost(model, usage)
+}
+
+// fraction returns the highest share of any configured limit that has been used
+func (b *Budget) fraction() float64 {
+	f := 0.0
+	if b.MaxTokens > 0 {
+		f = float64(b.usedTokens) / float64(b.MaxTokens)
+	}
+	if b.MaxCost > 0 {
+		f = max(f, b.usedCost/b.MaxCost)
+	}
+	return f
+}
+
+// Exhausted reports whether any limit has been reached
+func (b *Budget) Exhausted() bool {
+	return b.fraction() >= 1
+}
+
+// ShouldWarn reports true once, the first time usage crosses the warning threshold
+func (b *Budget) ShouldWarn() bool {
+	if b.warned || b.fraction() < budgetWarnFraction {
+		return false
+	}
+	b.warned = true
+	return true
+}
+
+func (b *Budget) String() string {
+	parts := []string{}
+	if b.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d tokens", b.usedTokens, b.MaxTokens))
+	}
+	if b.MaxCost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.4f/$%.2f", b.usedCost, b.MaxCost))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BudgetMiddleware charges every response against b. A strict budget also
+// refuses inference once it is exhausted; the agent warns and asks before
+// each step itself (checkBudget), where it knows whether anyone can answer.
+func BudgetMiddleware(b *Budget) Middleware {
+	return func(next AIProvider) AIProvider {
+		return ProviderFunc(func(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
+			if b.Strict && b.Exhausted() {
+				return nil, fmt.Errorf("%w: %s", ErrBudgetExhausted, b)
+			}
+			response, err := next.RunInference(ctx, conversation, tools)
+			if err != nil {
+				return nil, err
+			}
+			b.Record(response.Model, response.Usage)
+			return response, nil
+		})
+	}
+}
+
+// checkBudget runs before each inference step. Once the budget is exhausted
+// the user is asked whether to keep spending, once per turn; when nobody can
+// answer (run, serve, webhook) the step is refused.
+func (a *Agent) checkBudget(ctx context.Context) error {
+	if a.budget == nil {
+		return nil
+	}
+	if a.budget.Exhausted() && !a.confirmBudget(ctx) {
+		return fmt.Errorf("%w: %s", ErrBudgetExhausted, a.budget)
+	}
+	return nil
+}
+
+// warnBudget tells the user once that most of the budget is spent
+func (a *Agent) warnBudget() {
+	if a.budget != nil && a.budget.ShouldWarn() && !a.budget.Exhausted() {
+		a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: %.0f%% of the session budget used (%s)", theme.Warning(i18n.T("Budget Warning")), budgetWarnFraction*100, a.budget)})
+	}
+}
+
+// confirmBudget asks the user whether to keep going once the budget is
+// exhausted. The answer holds until the turn ends.
+func (a *Agent) confirmBudget(ctx context.Context) bool {
+	if a.budget == nil || !a.budget.Exhausted() || a.overBudget {
+		return true
+	}
+	if a.confirm == nil {
+		return false
+	}
+	a.overBudget = a.confirm(ctx, i18n.Sprintf("%s: %s. Continue anyway?", theme.Error(i18n.T("Budget Exhausted")), a.budget))
+	return a.overBudget
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateCost(t *testing.T) {
+	usage := Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}
+	assert.InDelta(t, 12.5, estimateCost("gpt-4o-2024-08-06", usage), 1e-9)
+	assert.InDelta(t, 0.75, estimateCost("gpt-4o-mini", usage), 1e-9)
+	assert.InDelta(t, 18.0, estimateCost("claude-3-7-sonnet-latest", usage), 1e-9)
+	assert.Zero(t, estimateCost("unknown-model", usage))
+}
+
+func TestBudget(t *testing.T) {
+	t.Run("令牌预算", func(t *testing.T) {
+		b := &Budget{MaxTokens: 100}
+		b.Record("gpt-4o", Usage{InputTokens: 50, OutputTokens: 20})
+		assert.False(t, b.ShouldWarn())
+		assert.False(t, b.Exhausted())
+
+		b.Record("gpt-4o", Usage{InputTokens: 15})
+		assert.True(t, b.ShouldWarn())
+		assert.False(t, b.ShouldWarn(), "只警告一次")
+
+		b.Record("gpt-4o", Usage{OutputTokens: 15})
+		assert.True(t, b.Exhausted())
+		assert.Equal(t, "100/100 tokens", b.String())
+	})
+
+	t.Run("金额预算", func(t *testing.T) {
+		b := &Budget{MaxCost: 1}
+		b.Record("gpt-4o", Usage{OutputTokens: 100_000})
+		assert.True(t, b.Exhausted())
+		assert.Contains(t, b.String(), "$1.0000/$1.00")
+	})
+}
+
+func TestConfirmBudget(t *testing.T) {
+	ctx := context.Background()
+	answers := []string{"n", "y"}
+	getUserMessage := func() (string, bool) {
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, true
+	}
+	budget := &Budget{MaxTokens: 10}
+	provider := Chain(&mockProvider{responses: []*Response{{Usage: Usage{InputTokens: 10}}}}, BudgetMiddleware(budget))
+	agent := NewAgent(provider, getUserMessage, nil)
+
+	assert.True(t, agent.confirmBudget(ctx), "没有预算时不需要确认")
+
+	agent.budget = budget
+	assert.True(t, agent.confirmBudget(ctx))
+
+	_, err := agent.provider.RunInference(ctx, nil, nil)
+	assert.NoError(t, err)
+	assert.False(t, agent.confirmBudget(ctx), "没人能回答时不继续")
+
+	agent.confirm = agent.askYesNo
+	assert.False(t, agent.confirmBudget(ctx))
+	assert.True(t, agent.confirmBudget(ctx))
+	assert.True(t, agent.confirmBudget(ctx), "同意后本轮不再询问")
+}
+
+func TestBudgetInTurn(t *testing.T) {
+	// 每一步用掉 100 个令牌，模型一直调用工具
+	newAgent := func(budget *Budget) *Agent {
+		provider := ProviderFunc(func(context.Context, []Message, []tools.ToolDefinition) (*Response, error) {
+			return &Response{Model: "gpt-4o", Usage: Usage{InputTokens: 100}, ToolCalls: []ToolCall{{ID: "1", Name: "list_files", Input: []byte(`{}`)}}}, nil
+		})
+		agent := NewAgent(Chain(provider, BudgetMiddleware(budget)), nil, builtinTools())
+		agent.budget = budget
+		return agent
+	}
+
+	t.Run("没人能回答时预算用完即停止", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		budget := &Budget{MaxTokens: 120}
+		agent := newAgent(budget)
+		var notices []string
+		agent.onEvent = func(e AgentEvent) {
+			if e.Type == EventNotice {
+				notices = append(notices, e.Content)
+			}
+		}
+
+		err := agent.runTurn(context.Background(), "列出文件")
+		assert.ErrorIs(t, err, ErrBudgetExhausted)
+		assert.Equal(t, "200/120 tokens", budget.String(), "用完后不再推理")
+		require.Len(t, notices, 1)
+		assert.Contains(t, ansiPattern.ReplaceAllString(notices[0], ""), "80% of the session budget used")
+	})
+
+	t.Run("用完时询问用户，同意后本轮继续", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		budget := &Budget{MaxTokens: 150}
+		agent := newAgent(budget)
+		agent.onEvent = func(AgentEvent) {}
+		asked := 0
+		agent.confirm = func(ctx context.Context, question string) bool {
+			asked++
+			return asked == 1
+		}
+
+		require.NoError(t, agent.runTurn(context.Background(), "列出文件"))
+		assert.Equal(t, 1, asked, "同意后本轮不再询问")
+		assert.Equal(t, fmt.Sprintf("%d/150 tokens", maxTurnSteps*100), budget.String())
+		assert.False(t, agent.overBudget)
+
+		err := agent.runTurn(context.Background(), "继续")
+		assert.ErrorIs(t, err, ErrBudgetExhausted)
+		assert.Equal(t, 2, asked, "下一轮重新询问")
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// chatOutput posts a session's activity to a conversation in a chat app
+type chatOutput interface {
+	// say posts text in the conversation
+	say(ctx context.Context, conversation, text string) error
+	// askApproval posts text with buttons to approve or deny a tool call;
+	// pressing one must call chatBot.decide with value
+	askApproval(ctx context.Context, conversation, text, value string) error
+}
+
+// chatBot drives agent sessions from a chat app (Slack, Discord, Telegram):
+// each conversation is a session of the API server, and the activity of its
+// turns is posted back to the conversation
+type chatBot struct {
+	api    *apiServer
+	out    chatOutput
+	logger *slog.Logger
+	// maxText is the longest message the chat app accepts
+	maxText int
+
+	mu sync.Mutex
+	// conversations maps a conversation to its session ID
+	conversations map[string]string
+}
+
+func newChatBot(api *apiServer, out chatOutput, maxText int, logger *slog.Logger) *chatBot {
+	return &chatBot{api: api, out: out, logger: logger, maxText: maxText, conversations: map[string]string{}}
+}
+
+// session returns the ID of the session of a conversation, or "" if it has none
+func (b *chatBot) session(conversation string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conversations[conversation]
+}
+
+// reset forgets the session of a conversation, so its next message starts a
+// new one
+func (b *chatBot) reset(conversation string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.conversations, conversation)
+}
+
+// handleMessage starts a turn in the conversation's session, creating one for
+// a new conversation, and posts the turn's activity to the conversation
+func (b *chatBot) handleMessage(ctx context.Context, conversation, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	live, err := b.api.live(b.session(conversation))
+	if err != nil {
+		b.say(ctx, conversation, "⚠️ "+err.Error())
+		return
+	}
+	b.mu.Lock()
+	b.conversations[conversation] = live.agent.session.ID
+	b.mu.Unlock()
+
+	next, err := b.api.startTurn(live, text)
+	if err != nil {
+		b.say(ctx, conversation, "⏳ I'm still working on the previous message; send this again when it's done.")
+		return
+	}
+	go b.relay(ctx, live, next, conversation)
+}
+
+// relay posts the events of a turn to the conversation until the turn is done
+func (b *chatBot) relay(ctx context.Context, live *liveSession, next int, conversation string) {
+	b.api.follow(ctx, live, next, func(index int, e AgentEvent) error {
+		switch e.Type {
+		case EventAssistantText:
+			b.say(ctx, conversation, truncate(e.Content, b.maxText))
+		case EventToolCall:
+			b.say(ctx, conversation, fmt.Sprintf("🔧 `%s` %s", e.ToolCall.Name, truncate(string(e.ToolCall.Input), 200)))
+		case EventToolError:
+			b.say(ctx, conversation, "❌ "+truncate(e.Content, b.maxText-10))
+		case EventFileEdit:
+			b.say(ctx, conversation, fmt.Sprintf("✏️ Edited `%s`\n```\n%s\n```", e.Path, truncate(e.Diff, b.maxText-len(e.Path)-30)))
+		case EventNotice:
+			b.say(ctx, conversation, e.Content)
+		case EventApprovalRequest:
+			text := fmt.Sprintf("✋ Allow `%s`?\n```\n%s\n```", e.ToolCall.Name, truncate(string(e.ToolCall.Input), b.maxText-100))
+			if err := b.out.askApproval(ctx, conversation, text, live.agent.session.ID+"/"+e.ToolCall.ID); err != nil {
+				b.logger.Warn("asking for approval failed", "conversation", conversation, "error", err)
+			}
+		case EventTurnDone:
+			if e.Content != "" {
+				b.say(ctx, conversation, "⚠️ "+e.Content)
+			}
+			return errTurnDone
+		}
+		return nil
+	})
+}
+
+// decide delivers the decision of an approval button, given the value passed
+// to askApproval
+func (b *chatBot) decide(value string, approved bool) error {
+	sessionID, callID, ok := strings.Cut(value, "/")
+	if !ok || sessionID == "" {
+		return fmt.Errorf("invalid approval %q", value)
+	}
+	live, err := b.api.live(sessionID)
+	if err != nil {
+		return err
+	}
+	return live.decide(callID, approved)
+}
+
+func (b *chatBot) say(ctx context.Context, conversation, text string) {
+	if err := b.out.say(ctx, conversation, text); err != nil {
+		b.logger.Warn("sending message failed", "conversation", conversation, "error", err)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Exit codes of `agent run --ci`
+const (
+	exitFailure = 1
+	exitBudget  = 2
+)
+
+// exitError is an error that ends the program with a specific exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitCode is the exit code the program ends with after err
+func exitCode(err error) int {
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+	return exitFailure
+}
+
+// ciSummary is the machine-readable summary of a CI run
+type ciSummary struct {
+	runResult
+	ExitCode       int      `json:"exit_code"`
+	DurationMS     int64    `json:"duration_ms"`
+	ToolCalls      int      `json:"tool_calls"`
+	ToolErrors     int      `json:"tool_errors"`
+	FilesChanged   []string `json:"files_changed"`
+	BudgetExceeded bool     `json:"budget_exceeded"`
+	CostUSD        float64  `json:"cost_usd"`
+}
+
+// ciLog prints a run's events as a plain CI log, with each tool call in a
+// collapsible section: GitHub Actions groups, or GitLab sections when
+// running in GitLab CI. Without a CI to fold them, as in the logs of queued
+// tasks, sections are headed by their title.
+type ciLog struct {
+	out    io.Writer
+	gitlab bool
+	plain  bool
+	// open is the name of the open section, if any
+	open     string
+	sections int
+}
+
+func (l *ciLog) start(title string) {
+	l.end()
+	l.sections++
+	l.open = fmt.Sprintf("agent_step_%d", l.sections)
+	switch {
+	case l.plain:
+		fmt.Fprintf(l.out, "== %s\n", title)
+	case l.gitlab:
+		fmt.Fprintf(l.out, "\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K%s\n", time.Now().Unix(), l.open, title)
+	default:
+		fmt.Fprintf(l.out, "::group::%s\n", title)
+	}
+}
+
+func (l *ciLog) end() {
+	if l.open == "" {
+		return
+	}
+	switch {
+	case l.plain:
+	case l.gitlab:
+		fmt.Fprintf(l.out, "\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", time.Now().Unix(), l.open)
+	default:
+		fmt.Fprintln(l.out, "::endgroup::")
+	}
+	l.open = ""
+}
+
+func (l *ciLog) print(e AgentEvent) {
+	switch e.Type {
+	case EventAssistantText:
+		l.end()
+		fmt.Fprintln(l.out, e.Content)
+	case EventToolCall:
+		title := "Tool: " + e.ToolCall.Name
+		if path := toolPathHint(e.ToolCall.Input); path != "" {
+			title += " " + path
+		}
+		l.start(title)
+		fmt.Fprintf(l.out, "input: %s\n", e.ToolCall.Input)
+	case EventToolResult:
+		fmt.Fprintln(l.out, strings.TrimRight(e.Content, "\n"))
+		l.end()
+	case EventFileEdit:
+		fmt.Fprintln(l.out, strings.TrimRight(e.Diff, "\n"))
+		l.end()
+	case EventToolError:
+		fmt.Fprintf(l.out, "error: %s\n", e.Content)
+		l.end()
+	case EventNotice:
+		l.end()
+		fmt.Fprintln(l.out, e.Content)
+	}
+}
+
+// runCI runs a single turn for a CI pipeline: the log goes to out, the
+// summary to summaryPath if set, and the error carries the exit code
+func runCI(ctx context.Context, agent *Agent, prompt string, out io.Writer, summaryPath string) error {
+	log := &ciLog{out: out, gitlab: os.Getenv("GITLAB_CI") != ""}
+	summary, err := runSummarized(ctx, agent, prompt, log)
+	if err != nil && !log.gitlab {
+		fmt.Fprintf(out, "::error::%s\n", err)
+	}
+
+	if summaryPath != "" {
+		data, marshalErr := json.MarshalIndent(summary, "", "  ")
+		if marshalErr == nil {
+			marshalErr = os.WriteFile(summaryPath, append(data, '\n'), 0644)
+		}
+		if marshalErr != nil && err == nil {
+			return &exitError{code: exitFailure, err: fmt.Errorf("write summary: %w", marshalErr)}
+		}
+	}
+	if err != nil {
+		return &exitError{code: summary.ExitCode, err: err}
+	}
+	return nil
+}
+
+// runSummarized runs a single turn, printing its events to log, and
+// summarizes it; running out of budget is an error even when the turn ended
+func runSummarized(ctx context.Context, agent *Agent, prompt string, log *ciLog) (ciSummary, error) {
+	start := time.Now()
+	summary := ciSummary{runResult: runResult{Type: EventResult}}
+	files := map[string]bool{}
+	agent.onEvent = func(e AgentEvent) {
+		summary.record(e)
+		switch e.Type {
+		case EventToolCall:
+			summary.ToolCalls++
+		case EventToolError:
+			summary.ToolErrors++
+		case EventFileEdit:
+			files[e.Path] = true
+		}
+		log.print(e)
+	}
+	defer func() { agent.onEvent = nil }()
+
+	err := agent.runTurn(ctx, prompt)
+	if saveErr := agent.saveSession(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	log.end()
+
+	if agent.store != nil {
+		summary.Session = agent.session.ID
+	}
+	summary.DurationMS = time.Since(start).Milliseconds()
+	summary.FilesChanged = []string{}
+	for path := range files {
+		summary.FilesChanged = append(summary.FilesChanged, path)
+	}
+	sort.Strings(summary.FilesChanged)
+	summary.CostUSD = estimateCost(summary.Model, summary.Usage)
+	summary.BudgetExceeded = agent.budget != nil && agent.budget.Exhausted()
+
+	summary.Status = "success"
+	switch {
+	case summary.BudgetExceeded || errors.Is(err, ErrBudgetExhausted):
+		summary.BudgetExceeded = true
+		summary.Status = "budget_exceeded"
+		summary.ExitCode = exitBudget
+		if err == nil {
+			err = fmt.Errorf("%w: %s", ErrBudgetExhausted, agent.budget)
+		}
+	case err != nil:
+		summary.Status = "error"
+		summary.ExitCode = exitFailure
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return summary, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readSummary(t *testing.T, path string) ciSummary {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var summary ciSummary
+	require.NoError(t, json.Unmarshal(data, &summary))
+	return summary
+}
+
+func TestRunCI(t *testing.T) {
+	t.Run("工具调用分组输出并写入摘要", func(t *testing.T) {
+		t.Setenv("GITLAB_CI", "")
+		var out bytes.Buffer
+		path := filepath.Join(t.TempDir(), "summary.json")
+		require.NoError(t, runCI(context.Background(), oneShotAgent(), "模块名是什么？", &out, path))
+
+		assert.True(t, strings.HasPrefix(out.String(), "先读文件\n::group::Tool: read_file go.mod\ninput: {\"path\":\"go.mod\"}\n"), out.String())
+		assert.Contains(t, out.String(), "module agent\n")
+		assert.Contains(t, out.String(), "::endgroup::\n模块名是 agent\n")
+
+		summary := readSummary(t, path)
+		assert.Equal(t, "success", summary.Status)
+		assert.Equal(t, "模块名是 agent", summary.Result)
+		assert.Equal(t, 0, summary.ExitCode)
+		assert.Equal(t, 1, summary.ToolCalls)
+		assert.Equal(t, int64(300), summary.Usage.InputTokens)
+		assert.Equal(t, []string{}, summary.FilesChanged)
+		assert.Positive(t, summary.CostUSD)
+	})
+
+	t.Run("GitLab CI 使用折叠区块", func(t *testing.T) {
+		t.Setenv("GITLAB_CI", "true")
+		var out bytes.Buffer
+		require.NoError(t, runCI(context.Background(), oneShotAgent(), "模块名是什么？", &out, ""))
+		assert.Regexp(t, `\x1b\[0Ksection_start:\d+:agent_step_1\[collapsed=true\]\r\x1b\[0KTool: read_file go.mod\n`, out.String())
+		assert.Regexp(t, `\x1b\[0Ksection_end:\d+:agent_step_1\r\x1b\[0K\n`, out.String())
+	})
+
+	t.Run("失败时退出码为 1", func(t *testing.T) {
+		var out bytes.Buffer
+		path := filepath.Join(t.TempDir(), "summary.json")
+		agent := NewAgent(&mockProvider{}, nil, builtinTools())
+		err := runCI(context.Background(), agent, "你好", &out, path)
+		require.Error(t, err)
+		assert.Equal(t, exitFailure, exitCode(err))
+
+		summary := readSummary(t, path)
+		assert.Equal(t, "error", summary.Status)
+		assert.Equal(t, exitFailure, summary.ExitCode)
+		assert.Contains(t, summary.Error, "no more responses")
+	})
+
+	t.Run("超出预算时停止并且退出码为 2", func(t *testing.T) {
+		var out bytes.Buffer
+		path := filepath.Join(t.TempDir(), "summary.json")
+		agent := oneShotAgent()
+		agent.budget = &Budget{MaxTokens: 50, Strict: true}
+		agent.provider = Chain(agent.provider, BudgetMiddleware(agent.budget))
+		err := runCI(context.Background(), agent, "模块名是什么？", &out, path)
+		require.ErrorIs(t, err, ErrBudgetExhausted)
+		assert.Equal(t, exitBudget, exitCode(err))
+		assert.NotContains(t, out.String(), "模块名是 agent")
+
+		summary := readSummary(t, path)
+		assert.Equal(t, "budget_exceeded", summary.Status)
+		assert.True(t, summary.BudgetExceeded)
+		assert.Equal(t, exitBudget, summary.ExitCode)
+	})
+}
@@ -0,0 +1,781 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"agent/config"
+	"agent/gitutil"
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// globalOptions are persistent flags shared by every subcommand
+type globalOptions struct {
+	configPath    string
+	profile       string
+	provider      string
+	model         string
+	maxTokens     int64
+	color         string
+	theme         string
+	language      string
+	verbose       bool
+	debug         bool
+	logLevel      string
+	logFormat     string
+	transcripts   bool
+	dumpRequests  string
+	responseCache string
+	workspace     string
+	remote        string
+
+	// config is loaded before any subcommand runs, with env and flag overrides applied
+	config *config.Config
+	// flags are kept so the config can be resolved again for another profile
+	flags *pflag.FlagSet
+}
+
+// cfg returns the loaded configuration, or the defaults when a command runs
+// without the root command (as in tests)
+func (g *globalOptions) cfg() *config.Config {
+	if g.config == nil {
+		return config.Default()
+	}
+	return g.config
+}
+
+// loadConfig resolves the configuration and applies its theme and language
+func (g *globalOptions) loadConfig(flags *pflag.FlagSet) error {
+	g.flags = flags
+	cfg, err := g.resolveConfig(g.profile)
+	if err != nil {
+		return err
+	}
+	g.config = cfg
+	warnUntrustedProject(os.Stderr, cfg)
+	sessionStorage = cfg.Sessions
+	configuredModels = cfg.Models
+	timeFormat = cfg.TimeFormat
+	return applyTheme(cfg)
+}
+
+// logOptions returns the logger settings of the --log-* flags. Subsystems log
+// at level unless --verbose or --debug lower it to info or debug, or
+// --log-level sets another; the CLI passes warn, servers info.
+func (g *globalOptions) logOptions(level slog.Level) (logOptions, error) {
+	switch {
+	case g.debug:
+		level = slog.LevelDebug
+	case g.verbose:
+		level = min(level, slog.LevelInfo)
+	}
+	opts, err := parseLogLevel(g.logLevel, level)
+	if err != nil {
+		return logOptions{}, err
+	}
+	switch g.logFormat {
+	case "", "text":
+	case "json":
+		opts.json = true
+	default:
+		return logOptions{}, fmt.Errorf("invalid --log-format %q: use text or json", g.logFormat)
+	}
+	return opts, nil
+}
+
+// logger returns the diagnostics logger writing to w; the flags were
+// checked before any command ran
+func (g *globalOptions) logger(w io.Writer, level slog.Level) *slog.Logger {
+	opts, err := g.logOptions(level)
+	if err != nil {
+		opts = logOptions{level: level}
+	}
+	return newLogger(w, opts)
+}
+
+// resolveConfig reads the user config file, applies the project's .agent.yaml
+// (all of it only when the project is trusted, see config.Config.UseProject)
+// and merges the selected profile on top, then applies AGENT_* environment variables
+// and finally any flags given on the command line. An empty profile falls
+// back to AGENT_PROFILE, then to the config's default profile.
+func (g *globalOptions) resolveConfig(profile string) (*config.Config, error) {
+	path := g.configPath
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return nil, err
+		}
+		g.configPath = path
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if projectFile, ok := config.FindProjectFile(cwd); ok {
+			if err := cfg.UseProject(projectFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if profile == "" {
+		profile = os.Getenv(config.EnvProfile)
+	}
+	if profile == "" {
+		profile = cfg.Profile
+	}
+	if profile != "" {
+		if err := cfg.UseProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.ApplyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+
+	flags := g.flags
+	if flags == nil {
+		flags = pflag.NewFlagSet("", pflag.ContinueOnError)
+	}
+	if flags.Changed("provider") {
+		cfg.Provider = g.provider
+	}
+	if flags.Changed("model") {
+		cfg.Model = g.model
+	}
+	if flags.Changed("max-tokens") {
+		cfg.MaxTokens = g.maxTokens
+	}
+	if flags.Changed("theme") {
+		cfg.Theme = g.theme
+	}
+	if flags.Changed("color") {
+		cfg.Color = g.color
+	}
+	if flags.Changed("lang") {
+		cfg.Language = g.language
+	}
+	if flags.Changed("dump-requests") {
+		cfg.DumpRequests = g.dumpRequests
+	}
+	if flags.Changed("response-cache") {
+		cfg.ResponseCache = g.responseCache
+	}
+	if flags.Changed("remote") {
+		host, root, _ := strings.Cut(g.remote, ":")
+		cfg.Remote.Host, cfg.Remote.Root = host, root
+	}
+	if flags.Changed("transcripts") {
+		cfg.Transcripts.Enabled = g.transcripts
+	}
+	return cfg, cfg.Validate()
+}
+
+// chatOptions are the flags of the interactive chat command
+type chatOptions struct {
+	autoCommit   bool
+	resume       string
+	budgetTokens int64
+	budgetUSD    float64
+	plain        bool
+	tui          bool
+	acp          bool
+	timings      bool
+	reviewEdits  bool
+}
+
+// newRootCommand builds the CLI. Running `agent` without a subcommand starts
+// an interactive chat, same as `agent chat`.
+func newRootCommand() *cobra.Command {
+	global := &globalOptions{}
+	chat := &chatOptions{}
+
+	root := &cobra.Command{
+		Use:           "agent",
+		Short:         "A terminal coding agent backed by Claude or GPT",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := global.logOptions(slog.LevelWarn); err != nil {
+				return err
+			}
+			if global.workspace != "" {
+				if _, err := changeWorkspace(global.workspace); err != nil {
+					return err
+				}
+			}
+			if err := global.loadConfig(cmd.Flags()); err != nil {
+				return err
+			}
+			return setupTracing(cmd.Context(), global.cfg().Tracing)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runChat(global, chat)
+		},
+	}
+	root.PersistentFlags().StringVar(&global.configPath, "config", "", "config file (default ~/.config/agent/config.yaml)")
+	root.PersistentFlags().StringVar(&global.profile, "profile", "", "named config profile to use (default from AGENT_PROFILE or the config)")
+	root.PersistentFlags().StringVar(&global.provider, "provider", "", "model provider: anthropic or openai (default from config, else by available API key)")
+	root.PersistentFlags().StringVar(&global.model, "model", "", "model name (default from config, else the provider's default)")
+	root.PersistentFlags().Int64Var(&global.maxTokens, "max-tokens", 0, "maximum tokens per reply (default from config)")
+	root.PersistentFlags().StringVar(&global.color, "color", "auto", "colorize output: auto, always or never (auto honors NO_COLOR and disables color when not a terminal)")
+	root.PersistentFlags().StringVar(&global.theme, "theme", "default", "color theme: "+strings.Join(theme.Names(), ", "))
+	root.PersistentFlags().StringVar(&global.language, "lang", "auto", "language of messages: auto, en or zh (auto follows LC_ALL, LC_MESSAGES and LANG)")
+	root.PersistentFlags().BoolVar(&global.verbose, "verbose", false, "log tool calls, inference timing and retries to stderr")
+	root.PersistentFlags().BoolVar(&global.debug, "debug", false, "like --verbose, plus truncated provider payloads and HTTP requests")
+	root.PersistentFlags().StringVar(&global.logLevel, "log-level", "", "log level: debug, info, warn, error or off, optionally per subsystem (agent, provider, tools), e.g. warn,provider=debug")
+	root.PersistentFlags().StringVar(&global.logFormat, "log-format", "text", "log format: text or json")
+	root.PersistentFlags().StringVar(&global.dumpRequests, "dump-requests", "", "write the exact JSON body of every provider request and response to files in this directory")
+	root.PersistentFlags().StringVar(&global.responseCache, "response-cache", "", "cache provider responses in this directory and answer identical requests from it, for repeatable runs")
+	root.PersistentFlags().BoolVar(&global.transcripts, "transcripts", false, "record provider requests and responses, secrets masked, as JSONL files per session (see transcripts in the config)")
+	root.PersistentFlags().StringVar(&global.workspace, "workspace", "", "work in this directory instead of the current one; the project config is found from there (switch later with /cd)")
+	root.PersistentFlags().StringVar(&global.remote, "remote", "", "work on another machine over SSH: HOST[:DIR], e.g. me@devbox:src/app (see remote in the config)")
+	addChatFlags(root, chat)
+
+	root.AddCommand(
+		newChatCommand(global),
+		newRunCommand(global),
+		newSessionsCommand(),
+		newToolsCommand(global),
+		newModelsCommand(),
+		newConfigCommand(global),
+		newAuthCommand(global),
+		newMCPServeCommand(global),
+		newServeCommand(global),
+		newSlackCommand(global),
+		newDiscordCommand(global),
+		newTelegramCommand(global),
+		newWebhookCommand(global),
+		newReplayCommand(),
+		newExportCommand(),
+		newReviewCommand(global),
+		newExplainCommand(global),
+		newApplyCommand(global),
+		newWatchCommand(global),
+		newQueueCommand(global),
+		newTeamCommand(global),
+		newWorkflowCommand(global),
+		newEvalCommand(global),
+		newBugReportCommand(),
+	)
+	return root
+}
+
+// applyTheme selects the color theme and the message language, and turns colors
+// off for NO_COLOR or piped output
+func applyTheme(cfg *config.Config) error {
+	if err := i18n.Use(cfg.Language); err != nil {
+		return err
+	}
+	if err := theme.Use(cfg.Theme); err != nil {
+		return err
+	}
+	terminal := readline.IsTerminal(int(os.Stdout.Fd()))
+	if terminal && !theme.EnableANSI(os.Stdout) {
+		// consoles before Windows 10 would print the escape sequences literally
+		terminal = false
+	}
+	enabled, err := theme.ColorWanted(cfg.Color, terminal)
+	if err != nil {
+		return err
+	}
+	theme.SetEnabled(enabled)
+	return nil
+}
+
+func addChatFlags(cmd *cobra.Command, opts *chatOptions) {
+	cmd.Flags().BoolVar(&opts.autoCommit, "auto-commit", false, "commit files changed by each turn with a generated message")
+	cmd.Flags().StringVar(&opts.resume, "resume", "", "resume a saved session by ID")
+	cmd.Flags().Int64Var(&opts.budgetTokens, "budget-tokens", 0, "maximum tokens to spend in this session (0 = unlimited)")
+	cmd.Flags().Float64Var(&opts.budgetUSD, "budget-usd", 0, "maximum estimated cost in USD for this session (0 = unlimited)")
+	cmd.Flags().BoolVar(&opts.plain, "plain", false, "print raw text instead of rendered Markdown and highlighted code")
+	cmd.Flags().BoolVar(&opts.tui, "tui", false, "run in a full-screen terminal UI")
+	cmd.Flags().BoolVar(&opts.timings, "timings", false, "show after each turn how long the model and each tool took")
+	cmd.Flags().BoolVar(&opts.reviewEdits, "review-edits", false, "review each hunk of the model's file edits before it is written, like git add -p")
+	cmd.Flags().BoolVar(&opts.acp, "acp", false, "talk JSON-RPC over stdin/stdout instead of a terminal, for editor plugins")
+}
+
+func newChatCommand(global *globalOptions) *cobra.Command {
+	opts := &chatOptions{}
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Start an interactive chat session (the default)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runChat(global, opts)
+		},
+	}
+	addChatFlags(cmd, opts)
+	return cmd
+}
+
+// runChat wires up the provider, input and front-end for an interactive session
+func runChat(global *globalOptions, opts *chatOptions) error {
+	if opts.acp {
+		return runACP(global)
+	}
+	logger := global.logger(os.Stderr, slog.LevelWarn)
+	var agent *Agent
+	var getUserMessage func() (string, bool)
+	var closeInput func()
+	if !opts.tui {
+		var err error
+		var complete readline.AutoCompleter
+		if global.cfg().Remote.Host == "" {
+			complete = mentionCompleter{root: func() string { return currentWorkspace.Root }}
+		}
+		getUserMessage, closeInput, err = newReadlineInput(func() { agent.interrupts.interrupt() }, complete)
+		if err != nil {
+			logger.Warn("line editing disabled", "error", err)
+		}
+	}
+	if getUserMessage != nil {
+		defer closeInput()
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		getUserMessage = multilineInput(func() (string, bool) {
+			if !scanner.Scan() {
+				return "", false
+			}
+			return scanner.Text(), true
+		}, nil)
+	}
+
+	agent = NewAgent(nil, getUserMessage, nil)
+	agent.logger = logger
+	if opts.budgetTokens > 0 || opts.budgetUSD > 0 {
+		agent.budget = &Budget{MaxTokens: opts.budgetTokens, MaxCost: opts.budgetUSD}
+	}
+	name, err := agent.applyConfig(global.cfg())
+	if err != nil {
+		return err
+	}
+	agent.resolveProfile = global.resolveConfig
+	fmt.Println(i18n.Sprintf("Using %s", name))
+	if remote := global.cfg().Remote; remote.Host != "" {
+		fmt.Println(i18n.Sprintf("Workspace: %s", (&tools.Remote{Host: remote.Host, Root: remote.Root}).String()))
+	}
+	agent.inputShowsPrompt = closeInput != nil
+	if !opts.plain {
+		markdown, err := newMarkdownRenderer()
+		if err != nil {
+			logger.Warn("markdown rendering disabled", "error", err)
+		}
+		agent.markdown = markdown
+		agent.highlight = markdown != nil
+	}
+	agent.progress = readline.IsTerminal(int(os.Stdout.Fd()))
+	agent.showStatus = agent.progress
+	if agent.progress {
+		agent.notify = terminalNotifier(logger)
+	}
+	agent.showTimings = opts.timings
+	agent.showTimestamps = global.cfg().Timestamps
+	agent.reviewEdits = opts.reviewEdits
+	if store, err := DefaultSessionStore(); err != nil {
+		logger.Warn("session saving disabled", "error", err)
+	} else {
+		agent.store = store
+	}
+	if audit, err := defaultAuditLog(global.cfg()); err != nil {
+		logger.Warn("audit log disabled", "error", err)
+	} else {
+		agent.audit = audit
+	}
+	if crashes, err := defaultCrashReporter(); err != nil {
+		logger.Warn("diagnostic bundles disabled", "error", err)
+	} else {
+		agent.crashes = crashes
+	}
+	if agent.store != nil {
+		if journal, err := defaultSessionJournal(); err != nil {
+			logger.Warn("session journal disabled", "error", err)
+		} else {
+			agent.journal = journal
+		}
+	}
+	if opts.resume != "" {
+		if agent.store == nil {
+			return fmt.Errorf("cannot resume without a session store")
+		}
+		session, recovery, err := agent.loadSession(opts.resume)
+		if err != nil {
+			return err
+		}
+		agent.resumeSession(session)
+		if recovery != nil {
+			fmt.Println(journalNotice(recovery))
+		}
+	}
+	if opts.autoCommit && global.cfg().Remote.Host != "" {
+		logger.Warn("auto-commit disabled", "error", "the workspace is remote")
+	} else if opts.autoCommit {
+		repo, err := gitutil.Open(".")
+		if err != nil {
+			logger.Warn("auto-commit disabled", "error", err)
+		} else {
+			agent.repo = repo
+		}
+	}
+
+	if opts.tui {
+		// the TUI handles Ctrl-C itself and draws its own activity indicator
+		agent.progress = false
+		if agent.reviewEdits {
+			logger.Warn("edit review disabled", "error", "the TUI cannot ask about hunks")
+			agent.reviewEdits = false
+		}
+		return runTUI(agent)
+	}
+
+	agent.interrupts = newInterruptHandler(func() {
+		if err := agent.saveSession(); err != nil {
+			fmt.Printf("Error: %s\n", err)
+		}
+	})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go agent.interrupts.Listen(signals)
+
+	return agent.Run(context.Background())
+}
+
+func newSessionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List saved sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := DefaultSessionStore()
+			if err != nil {
+				return err
+			}
+			return listSessions(cmd.OutOrStdout(), store)
+		},
+	}
+	cmd.AddCommand(newSessionsSearchCommand())
+	cmd.AddCommand(newSessionsShareCommand())
+	cmd.AddCommand(&cobra.Command{
+		Use:   "migrate",
+		Short: "Copy the session files into the configured session database (sessions.backend: sqlite)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if sessionStorage.Backend != "sqlite" {
+				return fmt.Errorf("sessions are stored as files; set sessions.backend to sqlite in the config first")
+			}
+			dir, err := agentConfigDir()
+			if err != nil {
+				return err
+			}
+			store, err := DefaultSessionStore()
+			if err != nil {
+				return err
+			}
+			files := &FileSessionStore{Dir: filepath.Join(dir, "sessions")}
+			copied, err := migrateSessions(files, store)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Copied %d sessions from %s\n", copied, files.Dir)
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rm <id>...",
+		Short: "Delete saved sessions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := DefaultSessionStore()
+			if err != nil {
+				return err
+			}
+			for _, id := range args {
+				if err := store.Delete(id); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s\n", id)
+			}
+			return nil
+		},
+	})
+	return cmd
+}
+
+// listSessions prints one row per stored session, most recent first
+func listSessions(out io.Writer, store SessionStore) error {
+	sessions, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Fprintln(out, "No saved sessions")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tFIRST MESSAGE")
+	for _, s := range sessions {
+		first := ""
+		for _, msg := range s.Messages {
+			if msg.Role == "user" && msg.ToolCall == nil {
+				first = truncate(firstLine(msg.Content), 50)
+				break
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", s.ID, s.UpdatedAt.Format("2006-01-02 15:04"), len(s.Messages), first)
+	}
+	return w.Flush()
+}
+
+func newToolsCommand(global *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tools",
+		Short: "Inspect the tools available to the agent",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List built-in and custom tools",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := global.cfg()
+			env := toolEnv(cfg, currentWorkspace)
+			defs, offered := []tools.ToolDefinition{}, map[string]bool{}
+			for _, spec := range tools.Default.Specs() {
+				tool, ok := spec.New(env)
+				tool.ReadOnly, tool.Category, tool.Permissions = spec.ReadOnly, spec.Category, spec.Permissions
+				defs, offered[tool.Name] = append(defs, tool), ok
+			}
+			custom, err := customTools(cfg, currentWorkspace)
+			if err != nil {
+				return err
+			}
+			for _, tool := range custom {
+				defs, offered[tool.Name] = append(defs, tool), true
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tCATEGORY\tACCESS\tPERMISSIONS\tSTATUS\tDESCRIPTION")
+			for _, tool := range defs {
+				access := "read-write"
+				if tool.ReadOnly {
+					access = "read-only"
+				}
+				permissions := []string{}
+				for _, permission := range tool.Permissions {
+					permissions = append(permissions, string(permission))
+				}
+				status := "enabled"
+				if !offered[tool.Name] {
+					// e.g. code intelligence outside a Go module, tracker without Jira or Linear
+					status = "unavailable"
+				} else if cfg.IsToolDisabled(tool.Name) {
+					status = "disabled"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", tool.Name, tool.Category, access,
+					strings.Join(permissions, ","), status, firstLine(tool.Description))
+			}
+			return w.Flush()
+		},
+	})
+	cmd.AddCommand(newToolStatsCommand())
+	return cmd
+}
+
+func newConfigCommand(global *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show the effective configuration (file, AGENT_* env vars and flags combined)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printConfig(cmd.OutOrStdout(), global.configPath, global.cfg())
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List every effective setting as key = value",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := config.List(global.cfg())
+			if err != nil {
+				return err
+			}
+			for _, setting := range settings {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s = %s\n", setting.Key, setting.Value)
+			}
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the effective value of a setting, e.g. tools.disabled",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := config.Get(global.cfg(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	})
+
+	var project bool
+	set := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Write a setting to the config file; values are YAML, e.g. \"[read_file, grep]\"",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := global.configPath
+			if project {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				if path, _ = config.FindProjectFile(cwd); path == "" {
+					path = filepath.Join(cwd, config.ProjectFile)
+				}
+			} else if path == "" {
+				var err error
+				if path, err = config.DefaultPath(); err != nil {
+					return err
+				}
+			}
+			if err := config.Set(path, args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Set %s in %s\n", args[0], path)
+			return nil
+		},
+	}
+	set.Flags().BoolVar(&project, "project", false, "write to the project's .agent.yaml instead of the user config")
+	cmd.AddCommand(set)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "trust [dir]",
+		Short: "Trust a project so every setting in its .agent.yaml applies, including shell, endpoints and credentials",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			return trustProject(cmd.OutOrStdout(), global.configPath, dir)
+		},
+	})
+	return cmd
+}
+
+// trustProject adds the project containing dir to trusted_projects in the
+// user config at path
+func trustProject(out io.Writer, path, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	projectFile, ok := config.FindProjectFile(dir)
+	if !ok {
+		return fmt.Errorf("no %s found in %s or its parents", config.ProjectFile, dir)
+	}
+	root := filepath.Dir(projectFile)
+	if path == "" {
+		if path, err = config.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	if cfg.IsTrustedProject(root) {
+		fmt.Fprintf(out, "%s is already trusted\n", root)
+		return nil
+	}
+	value, err := yaml.Marshal(append(cfg.TrustedProjects, root))
+	if err != nil {
+		return err
+	}
+	if err := config.Set(path, "trusted_projects", string(value)); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Trusted %s in %s\n", root, path)
+	return nil
+}
+
+// warnUntrustedProject tells the user which settings of an untrusted
+// project's .agent.yaml were left out
+func warnUntrustedProject(w io.Writer, cfg *config.Config) {
+	if len(cfg.ProjectIgnored) == 0 {
+		return
+	}
+	fmt.Fprintln(w, i18n.Sprintf("Ignoring %s in %s until you trust the project with: agent config trust", strings.Join(cfg.ProjectIgnored, ", "), filepath.Join(cfg.ProjectDir, config.ProjectFile)))
+}
+
+// printConfig reports the effective configuration and where state is kept
+func printConfig(out io.Writer, path string, cfg *config.Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	dir, err := agentConfigDir()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = filepath.Join(dir, "config.yaml")
+	}
+
+	fmt.Fprintf(out, "# %s\n%s\n", path, data)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if cfg.ProjectDir != "" {
+		fmt.Fprintf(w, "project config\t%s\n", filepath.Join(cfg.ProjectDir, config.ProjectFile))
+	}
+	fmt.Fprintf(w, "config dir\t%s\n", dir)
+	fmt.Fprintf(w, "sessions\t%s\n", filepath.Join(dir, "sessions"))
+	fmt.Fprintf(w, "history\t%s\n", filepath.Join(dir, "history"))
+	if audit, err := defaultAuditLog(cfg); err == nil && audit != nil {
+		fmt.Fprintf(w, "audit log\t%s\n", audit.path)
+	}
+	for _, key := range []string{"OPENAI_API_KEY", "ANTHROPIC_API_KEY"} {
+		fmt.Fprintf(w, "%s\t%s\n", key, maskSecret(os.Getenv(key)))
+	}
+	return w.Flush()
+}
+
+// agentConfigDir is where the agent keeps sessions, history and settings
+func agentConfigDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "agent"), nil
+}
+
+// maskSecret shows whether a secret is set without revealing it
+func maskSecret(s string) string {
+	if s == "" {
+		return "(not set)"
+	}
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 4) + s[len(s)-4:]
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent/config"
+	"agent/theme"
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCommand 以给定参数执行子命令，输出写入 out；结束后恢复全局主题设置
+func runCommand(cmd *cobra.Command, in io.Reader, out *bytes.Buffer, args ...string) error {
+	previous, enabled := theme.Current().Name, theme.Enabled()
+	defer func() {
+		theme.Use(previous)
+		theme.SetEnabled(enabled)
+	}()
+
+	if in == nil {
+		in = strings.NewReader("")
+	}
+	cmd.SetArgs(args)
+	cmd.SetIn(in)
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	return cmd.Execute()
+}
+
+func TestRootCommand(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runCommand(newRootCommand(), nil, &out, "--help"))
+	for _, name := range []string{"chat", "run", "sessions", "tools", "config", "replay", "export"} {
+		assert.Contains(t, out.String(), name)
+	}
+
+	out.Reset()
+	assert.Error(t, runCommand(newRootCommand(), nil, &out, "run", "--output", "xml", "你好"))
+}
+
+func TestThemeFlags(t *testing.T) {
+	var out bytes.Buffer
+	assert.Error(t, runCommand(newRootCommand(), nil, &out, "--theme", "neon", "tools", "list"))
+	assert.Error(t, runCommand(newRootCommand(), nil, &out, "--color", "sometimes", "tools", "list"))
+
+	cfg := &config.Config{Color: "always", Theme: "mono"}
+	defer func() {
+		theme.Use("default")
+		theme.SetEnabled(true)
+	}()
+	require.NoError(t, applyTheme(cfg))
+	assert.Equal(t, "\u001b[1;7mError\u001b[0m", theme.Error("Error"))
+
+	cfg.Color = "never"
+	require.NoError(t, applyTheme(cfg))
+	assert.Equal(t, "Error", theme.Error("Error"))
+}
+
+func TestToolsListCommand(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runCommand(newToolsCommand(&globalOptions{}), nil, &out, "list"))
+	assert.Regexp(t, `read_file\s+files\s+read-only\s+read\s+enabled`, out.String())
+	assert.Regexp(t, `edit_file\s+files\s+read-write\s+read,write\s+enabled`, out.String())
+	assert.Regexp(t, `tracker\s+integrations\s+read-write\s+network\s+unavailable`, out.String(), "没有配置 Jira 或 Linear")
+}
+
+func TestListSessions(t *testing.T) {
+	store := &FileSessionStore{Dir: t.TempDir()}
+	var out bytes.Buffer
+
+	t.Run("没有会话", func(t *testing.T) {
+		require.NoError(t, listSessions(&out, store))
+		assert.Contains(t, out.String(), "No saved sessions")
+	})
+
+	t.Run("列出并删除会话", func(t *testing.T) {
+		s := NewSession()
+		s.Messages = []Message{{Role: "user", Content: "修复登录\n第二行"}, {Role: "assistant", Content: "好的"}}
+		require.NoError(t, store.Save(s))
+
+		out.Reset()
+		require.NoError(t, listSessions(&out, store))
+		assert.Contains(t, out.String(), s.ID)
+		assert.Contains(t, out.String(), "修复登录")
+		assert.NotContains(t, out.String(), "第二行")
+
+		require.NoError(t, store.Delete(s.ID))
+		assert.Error(t, store.Delete(s.ID))
+	})
+}
+
+func TestMaskSecret(t *testing.T) {
+	assert.Equal(t, "(not set)", maskSecret(""))
+	assert.Equal(t, "****", maskSecret("abcd"))
+	assert.Equal(t, "sk-a****wxyz", maskSecret("sk-abcdefghijklmnopqrstuvwxyz"))
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	defer func() {
+		theme.Use("default")
+		theme.SetEnabled(true)
+	}()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("provider: openai\nmodel: gpt-4o-mini\nmax_tokens: 2048\ntheme: light\n"), 0644))
+	t.Setenv(config.EnvModel, "gpt-4o")
+	t.Setenv(config.EnvMaxTokens, "")
+
+	root := newRootCommand()
+	require.NoError(t, root.ParseFlags([]string{"--config", path, "--max-tokens", "512"}))
+	global := &globalOptions{configPath: path, maxTokens: 512}
+	require.NoError(t, global.loadConfig(root.Flags()))
+
+	cfg := global.cfg()
+	assert.Equal(t, "openai", cfg.Provider, "来自配置文件")
+	assert.Equal(t, "gpt-4o", cfg.Model, "环境变量覆盖配置文件")
+	assert.Equal(t, int64(512), cfg.MaxTokens, "参数覆盖配置文件")
+	assert.Equal(t, "light", theme.Current().Name)
+}
+
+func TestEnabledTools(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	cfg := config.Default()
+	cfg.Tools.Disabled = []string{"edit_file", "shell"}
+	enabled, err := enabledTools(cfg, nil)
+	require.NoError(t, err)
+	require.Len(t, enabled, 3)
+	assert.Equal(t, "read_file", enabled[0].Name)
+	assert.Equal(t, "grep", enabled[1].Name)
+	assert.Equal(t, "glob", enabled[2].Name)
+
+	cfg.Tools.Disabled = nil
+	cfg.Shell.Backend = "podman"
+	t.Setenv("PATH", t.TempDir())
+	_, err = enabledTools(cfg, nil)
+	assert.ErrorContains(t, err, "shell backend podman", "容器运行时不存在")
+
+	cfg.Shell.Backend = "host"
+	cfg.Tools.Disabled = []string{"rm_rf"}
+	_, err = enabledTools(cfg, nil)
+	assert.Error(t, err)
+
+	t.Run("Go 模块中提供代码智能工具", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Tools.Disabled = []string{"rename_symbol"}
+		enabled, err := enabledTools(cfg, nil)
+		require.NoError(t, err, "没有 go.mod 时也可以禁用代码智能工具")
+		assert.Len(t, enabled, 5)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n"), 0644))
+		enabled, err = enabledTools(cfg, nil)
+		require.NoError(t, err)
+		names := []string{}
+		for _, tool := range enabled {
+			names = append(names, tool.Name)
+		}
+		assert.Equal(t, []string{"read_file", "edit_file", "grep", "glob", "shell", "run_benchmarks", "coverage", "go_to_definition", "find_references", "run_tests", "build", "format", "vulncheck"}, names)
+
+		cfg.LSP.Disabled = true
+		enabled, err = enabledTools(cfg, nil)
+		require.NoError(t, err)
+		assert.Len(t, enabled, 11)
+	})
+	t.Run("配置中声明的外部工具", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.ProjectDir = dir
+		cfg.Tools.Custom = []config.CustomTool{{Name: "hello", Description: "Say hello", Command: []string{"./hello.sh"}}}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "hello.sh"), []byte("#!/bin/sh\necho hello\n"), 0755))
+		cfg.Tools.Disabled = []string{"hello"}
+		enabled, err := enabledTools(cfg, nil)
+		require.NoError(t, err, "可以禁用自定义工具")
+		assert.NotContains(t, toolNames(enabled), "hello")
+
+		cfg.Tools.Disabled = nil
+		enabled, err = enabledTools(cfg, nil)
+		require.NoError(t, err)
+		hello := enabled[len(enabled)-1]
+		assert.Equal(t, "hello", hello.Name)
+		out, err := hello.Function(context.Background(), nil).Output()
+		require.NoError(t, err, "相对路径的命令基于项目根目录")
+		assert.Equal(t, "hello\n", out)
+
+		cfg.Tools.Custom[0].Name = "shell"
+		_, err = enabledTools(cfg, nil)
+		assert.ErrorContains(t, err, "name of a built-in tool")
+	})
+}
+
+// toolNames 返回 defs 中的工具名
+func toolNames(defs []tools.ToolDefinition) []string {
+	names := []string{}
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
+	return names
+}
+
+func TestConfigCommands(t *testing.T) {
+	t.Setenv(config.EnvModel, "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	var out bytes.Buffer
+
+	require.NoError(t, runCommand(newRootCommand(), nil, &out, "--config", path, "config", "set", "tools.disabled", "[edit_file]"))
+	assert.Contains(t, out.String(), "Set tools.disabled in "+path)
+
+	out.Reset()
+	require.NoError(t, runCommand(newRootCommand(), nil, &out, "--config", path, "config", "get", "tools.disabled"))
+	assert.Equal(t, "[\"edit_file\"]\n", out.String())
+
+	out.Reset()
+	require.NoError(t, runCommand(newRootCommand(), nil, &out, "--config", path, "--model", "gpt-4o", "config", "list"))
+	assert.Contains(t, out.String(), "model = gpt-4o\n", "列出包含参数覆盖后的值")
+
+	out.Reset()
+	assert.Error(t, runCommand(newRootCommand(), nil, &out, "--config", path, "config", "set", "color", "sometimes"))
+}
+
+func TestConfigTrust(t *testing.T) {
+	t.Setenv(config.EnvModel, "")
+	t.Setenv(config.EnvProfile, "")
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, config.ProjectFile), []byte("model: project-model\nshell:\n  program: bash\nbase_url: http://attacker.example\n"), 0644))
+	chdir(t, root)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	global := &globalOptions{configPath: path}
+
+	cfg, err := global.resolveConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, "project-model", cfg.Model)
+	assert.Empty(t, cfg.Shell.Program, "未信任的项目不能修改 shell")
+	assert.Empty(t, cfg.BaseURL)
+	assert.Equal(t, []string{"base_url", "shell"}, cfg.ProjectIgnored)
+	var warning bytes.Buffer
+	warnUntrustedProject(&warning, cfg)
+	assert.Contains(t, warning.String(), "base_url, shell")
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand(newRootCommand(), nil, &out, "--config", path, "config", "trust"))
+	assert.Contains(t, out.String(), "Trusted ")
+	out.Reset()
+	require.NoError(t, runCommand(newRootCommand(), nil, &out, "--config", path, "config", "trust", "."))
+	assert.Contains(t, out.String(), "already trusted")
+
+	cfg, err = global.resolveConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, "bash", cfg.Shell.Program, "信任后所有设置都生效")
+	assert.Equal(t, "http://attacker.example", cfg.BaseURL)
+	assert.Empty(t, cfg.ProjectIgnored)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"agent/i18n"
+	"agent/theme"
+)
+
+// slashCommand is a REPL command such as /help, handled locally instead of sent to the model
+type slashCommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(a *Agent, args []string) error
+}
+
+var slashCommands []slashCommand
+
+func init() {
+	slashCommands = []slashCommand{
+		{
+			name:        "help",
+			usage:       "/help",
+			description: "Show available commands",
+			run:         runHelpCommand,
+		},
+		{
+			name:        "history",
+			usage:       "/history [-v]",
+			description: "List messages in the current session with their numbers; -v adds time, model and tokens",
+			run:         runHistoryCommand,
+		},
+		{
+			name:        "search",
+			usage:       "/search TEXT",
+			description: "Search this and saved sessions for text, showing matching messages with context",
+			run:         runSearchCommand,
+		},
+		{
+			name:        "fork",
+			usage:       "/fork N",
+			description: "Continue in a new session that keeps messages 1..N of the current one",
+			run:         runForkCommand,
+		},
+		{
+			name:        "export",
+			usage:       "/export [file]",
+			description: "Export the session as Markdown, or JSON when the file ends in .json",
+			run:         runExportCommand,
+		},
+		{
+			name:        "status",
+			usage:       "/status",
+			description: "Show the model, context usage and session cost",
+			run:         runStatusCommand,
+		},
+		{
+			name:        "tokens",
+			usage:       "/tokens",
+			description: "Count the tokens of the next request and show where they go",
+			run:         runTokensCommand,
+		},
+		{
+			name:        "system",
+			usage:       "/system [section]",
+			description: "Show the system prompt section by section, with the tokens each takes",
+			run:         runSystemCommand,
+		},
+		{
+			name:        "usage",
+			usage:       "/usage",
+			description: "Show turns, tool calls, tokens, wall time and cost of the session",
+			run:         runUsageCommand,
+		},
+		{
+			name:        "debug-last",
+			usage:       "/debug-last [file]",
+			description: "Write the exact provider requests and responses of the last turn to a file (default debug-last.json)",
+			run:         runDebugLastCommand,
+		},
+		{
+			name:        "timings",
+			usage:       "/timings [on|off]",
+			description: "Show where each turn's time went: first token, model and tools",
+			run:         runTimingsCommand,
+		},
+		{
+			name:        "timestamps",
+			usage:       "/timestamps [on|off]",
+			description: "Show when each message was sent, in the time_format of the config",
+			run:         runTimestampsCommand,
+		},
+		{
+			name:        "knowledge",
+			usage:       "/knowledge [update]",
+			description: "Show what the agent learned about this repository in earlier sessions, or update it from this one",
+			run:         runKnowledgeCommand,
+		},
+		{
+			name:        "review-edits",
+			usage:       "/review-edits [on|off]",
+			description: "Review each hunk of the model's file edits before they are written",
+			run:         runReviewEditsCommand,
+		},
+		{
+			name:        "tools",
+			usage:       "/tools [enable|disable NAME | stats [calls|failures|latency]]",
+			description: "List tools, turn one on or off for this session, or show per-tool call stats",
+			run:         runToolsCommand,
+		},
+		{
+			name:        "tool-choice",
+			usage:       "/tool-choice [auto|required|none|TOOL]",
+			description: "Show or set how the model uses tools at the start of each turn, e.g. none to plan without tools",
+			run:         runToolChoiceCommand,
+		},
+		{
+			name:        "postprocess",
+			usage:       "/postprocess [PROCESSOR…|off]",
+			description: "Show or set how replies are processed: strip_thinking, language, extract_code",
+			run:         runPostProcessCommand,
+		},
+		{
+			name:        "refresh",
+			usage:       "/refresh",
+			description: "Capture the uncommitted changes and recent commits sent to the model again",
+			run:         runRefreshCommand,
+		},
+		{
+			name:        "pin",
+			usage:       "/pin [PATH…]",
+			description: "List pinned files, or pin files so their latest contents are always in context",
+			run:         runPinCommand,
+		},
+		{
+			name:        "unpin",
+			usage:       "/unpin PATH…|all",
+			description: "Stop keeping files in context",
+			run:         runUnpinCommand,
+		},
+		{
+			name:        "profile",
+			usage:       "/profile [name]",
+			description: "List config profiles, or switch provider, model and tools to another",
+			run:         runProfileCommand,
+		},
+		{
+			name:        "cd",
+			usage:       "/cd [DIR]",
+			description: "Show the workspace, or move the session to another directory and load its project config",
+			run:         runCdCommand,
+		},
+		{
+			name:        "voice",
+			usage:       "/voice [on|off]",
+			description: "Talk instead of typing and hear the replies read aloud",
+			run:         runVoiceCommand,
+		},
+		{
+			name:        "prompt",
+			usage:       "/prompt [name key=value…]",
+			description: "List prompt templates, or fill one in and send it",
+			run:         runPromptCommand,
+		},
+	}
+}
+
+// handleCommand runs input as a slash command; it reports false if input is not a command
+func (a *Agent) handleCommand(input string) (bool, error) {
+	if !strings.HasPrefix(input, "/") {
+		return false, nil
+	}
+
+	fields := strings.Fields(input[1:])
+	if len(fields) == 0 {
+		return false, nil
+	}
+	for _, cmd := range slashCommands {
+		if cmd.name == fields[0] {
+			return true, cmd.run(a, fields[1:])
+		}
+	}
+	return true, fmt.Errorf("unknown command /%s (try /help)", fields[0])
+}
+
+func runHelpCommand(a *Agent, args []string) error {
+	for _, cmd := range slashCommands {
+		fmt.Printf("  %-12s %s\n", cmd.usage, i18n.T(cmd.description))
+	}
+	fmt.Println()
+	fmt.Println(i18n.T(`End a line with \ to continue it, or wrap a multi-line message in """ ... """.`))
+	fmt.Println(i18n.T("Mention @path or @path:10-20 to attach a file or some of its lines; Tab completes the path."))
+	fmt.Println(i18n.T("Start a line with ! to run a shell command yourself, or with !! to also attach its output to your next message."))
+	return nil
+}
+
+func runHistoryCommand(a *Agent, args []string) error {
+	verbose := len(args) == 1 && args[0] == "-v"
+	if len(args) > 0 && !verbose {
+		return fmt.Errorf("usage: /history [-v]")
+	}
+	for i, msg := range a.Messages() {
+		if a.showTimestamps && !verbose && msg.Meta != nil {
+			fmt.Printf("%3d %-9s %s %s\n", i+1, msg.Role, theme.Muted(formatTime(msg.Meta.Time)), truncate(firstLine(msg.Content), 80))
+			continue
+		}
+		fmt.Printf("%3d %-9s %s\n", i+1, msg.Role, truncate(firstLine(msg.Content), 80))
+		if meta := formatMeta(msg.Meta); verbose && meta != "" {
+			fmt.Printf("%13s %s\n", "", theme.Muted(meta))
+		}
+	}
+	return nil
+}
+
+func runForkCommand(a *Agent, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: /fork N")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid message number %q", args[0])
+	}
+
+	a.syncSession()
+	forked, err := a.session.Fork(n)
+	if err != nil {
+		return err
+	}
+	original := a.session.ID
+
+	a.session = forked
+	a.setConversation(forked.Messages)
+	a.reads.Reset()
+	if err := a.saveSession(); err != nil {
+		return err
+	}
+
+	fmt.Println(i18n.Sprintf("Forked session %s at message %d into new session %s", original, n, forked.ID))
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
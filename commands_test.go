@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/config"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCommand(t *testing.T) {
+	agent := NewAgent(&mockProvider{}, nil, nil)
+
+	t.Run("普通输入不是命令", func(t *testing.T) {
+		handled, err := agent.handleCommand("hello /fork")
+		assert.False(t, handled)
+		assert.NoError(t, err)
+	})
+
+	t.Run("未知命令", func(t *testing.T) {
+		handled, err := agent.handleCommand("/nope")
+		assert.True(t, handled)
+		assert.Error(t, err)
+	})
+}
+
+func TestForkCommand(t *testing.T) {
+	agent := NewAgent(&mockProvider{}, nil, nil)
+	agent.store = &FileSessionStore{Dir: filepath.Join(t.TempDir(), "sessions")}
+	agent.conversation = []Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "reply"},
+		{Role: "user", Content: "second"},
+	}
+	require.NoError(t, agent.saveSession())
+	original := agent.session.ID
+
+	t.Run("参数错误", func(t *testing.T) {
+		_, err := agent.handleCommand("/fork")
+		assert.Error(t, err)
+		_, err = agent.handleCommand("/fork x")
+		assert.Error(t, err)
+		_, err = agent.handleCommand("/fork 9")
+		assert.Error(t, err)
+		assert.Equal(t, original, agent.session.ID)
+	})
+
+	t.Run("分叉到新会话", func(t *testing.T) {
+		handled, err := agent.handleCommand("/fork 2")
+		require.True(t, handled)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, original, agent.session.ID)
+		assert.Len(t, agent.conversation, 2)
+
+		// 原会话保持不变
+		saved, err := agent.store.Load(original)
+		require.NoError(t, err)
+		assert.Len(t, saved.Messages, 3)
+
+		forked, err := agent.store.Load(agent.session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, original, forked.ParentID)
+	})
+
+	t.Run("分叉后重新发送读过的文件", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+		agent.reads = &tools.ReadCache{}
+		workspace := &tools.Workspace{Root: dir, Reads: agent.reads}
+		input := json.RawMessage(`{"path": "a.txt"}`)
+		_, err := workspace.ReadFile(context.Background(), input)
+		require.NoError(t, err)
+
+		_, err = agent.handleCommand("/fork 1")
+		require.NoError(t, err)
+		out, err := workspace.ReadFile(context.Background(), input)
+		require.NoError(t, err)
+		assert.Equal(t, "a", out, "分叉丢弃了读取文件的消息")
+	})
+}
+
+func TestPromptCommand(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	store, err := defaultPromptStore(config.Default())
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(store.Dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir, "review-pr.md"), []byte("Review {{url}} carefully.\n"), 0644))
+
+	t.Run("缺少占位符时不发送", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{}, nil, nil)
+		_, err := agent.handleCommand("/prompt review-pr")
+		assert.Error(t, err)
+		assert.Empty(t, agent.nextMessage)
+	})
+
+	t.Run("填充模板后作为消息发送", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "看起来不错"}}}
+		agent := NewAgent(provider, scriptedInput("/prompt review-pr url=https://example.com/pr/7 be brief"), nil)
+		require.NoError(t, agent.Run(context.Background()))
+
+		require.Len(t, provider.calls, 1)
+		assert.Equal(t, "Review https://example.com/pr/7 carefully.\n\nbe brief", provider.calls[0][0].Content)
+		assert.Empty(t, agent.nextMessage)
+	})
+}
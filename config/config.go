@@ -0,0 +1,1411 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 是 agent 的配置，依次由配置文件、环境变量和命令行参数覆盖
+type Config struct {
+	// Provider 为 anthropic 或 openai，留空时根据已设置的 API key 自动选择
+	Provider string `yaml:"provider,omitempty"`
+	// Model 留空时使用所选 provider 的默认模型
+	Model     string `yaml:"model,omitempty"`
+	MaxTokens int64  `yaml:"max_tokens,omitempty"`
+	// BaseURL 指向兼容的 API 端点，例如 Azure 或本地的 Ollama
+	BaseURL string `yaml:"base_url,omitempty"`
+	// APIKeyEnv 是保存 API key 的环境变量名，留空时使用 provider 的默认变量
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+	Theme     string `yaml:"theme,omitempty"`
+	// Color 为 auto、always 或 never
+	Color string `yaml:"color,omitempty"`
+	// Language 是界面语言 auto、en 或 zh，auto 按 locale 环境变量选择
+	Language string `yaml:"language,omitempty"`
+	Tools    Tools  `yaml:"tools,omitempty"`
+	// Prompts 是内联的提示词模板，名称到正文
+	Prompts map[string]string `yaml:"prompts,omitempty"`
+	// Instructions 是作为系统提示发送给模型的指令文件，相对路径基于项目根目录
+	Instructions []string    `yaml:"instructions,omitempty"`
+	Sandbox      Sandbox     `yaml:"sandbox,omitempty"`
+	Shell        Shell       `yaml:"shell,omitempty"`
+	Remote       Remote      `yaml:"remote,omitempty"`
+	Limits       Limits      `yaml:"limits,omitempty"`
+	Audit        Audit       `yaml:"audit,omitempty"`
+	LSP          LSP         `yaml:"lsp,omitempty"`
+	Index        Index       `yaml:"index,omitempty"`
+	GitHub       GitHub      `yaml:"github,omitempty"`
+	Tracker      Tracker     `yaml:"tracker,omitempty"`
+	Storage      Storage     `yaml:"storage,omitempty"`
+	Kubernetes   Kubernetes  `yaml:"kubernetes,omitempty"`
+	Docker       Docker      `yaml:"docker,omitempty"`
+	Watch        Watch       `yaml:"watch,omitempty"`
+	Verify       Verify      `yaml:"verify,omitempty"`
+	Notify       Notify      `yaml:"notify,omitempty"`
+	Replies      Replies     `yaml:"replies,omitempty"`
+	Embeddings   Embeddings  `yaml:"embeddings,omitempty"`
+	Knowledge    Knowledge   `yaml:"knowledge,omitempty"`
+	Sessions     Sessions    `yaml:"sessions,omitempty"`
+	Server       Server      `yaml:"server,omitempty"`
+	Team         Team        `yaml:"team,omitempty"`
+	Tracing      Tracing     `yaml:"tracing,omitempty"`
+	Transcripts  Transcripts `yaml:"transcripts,omitempty"`
+	Voice        Voice       `yaml:"voice,omitempty"`
+	System       System      `yaml:"system,omitempty"`
+	// Models 描述内置模型表中没有或需要修正的模型，例如本地模型，优先于内置的条目
+	Models []Model `yaml:"models,omitempty"`
+	// DumpRequests 是保存每次 provider 请求和响应原始 JSON 正文的目录，留空时不保存
+	DumpRequests string `yaml:"dump_requests,omitempty"`
+	// ResponseCache 是缓存推理响应的目录，相同的模型、对话和工具直接返回缓存的响应，
+	// 用于可重复的运行；相对路径基于项目根目录，留空时不缓存
+	ResponseCache string `yaml:"response_cache,omitempty"`
+	// TimeFormat 是对话、导出和搜索结果中时间的格式：Go 的时间格式，或者 iso、rfc3339；
+	// 留空时随界面语言
+	TimeFormat string `yaml:"time_format,omitempty"`
+	// Timestamps 在交互式会话中显示消息的时间，会话中可以用 /timestamps 切换
+	Timestamps bool `yaml:"timestamps,omitempty"`
+
+	// Profile 是默认使用的配置档名称
+	Profile string `yaml:"profile,omitempty"`
+	// Profiles 是具名的配置档，选中时覆盖在配置之上
+	Profiles map[string]*Config `yaml:"profiles,omitempty"`
+	// TrustedProjects 是信任的项目根目录，这些项目的 .agent.yaml 中的所有设置都生效，
+	// 其他项目只应用 Project 中的设置；只在用户配置中生效，由 agent config trust 添加
+	TrustedProjects []string `yaml:"trusted_projects,omitempty"`
+
+	// ProjectDir 是找到 .agent.yaml 的项目根目录，没有项目配置时为空
+	ProjectDir string `yaml:"-"`
+	// ProjectIgnored 是项目未被信任而没有生效的 .agent.yaml 顶层键
+	ProjectIgnored []string `yaml:"-"`
+}
+
+// Tools 是工具相关的设置
+type Tools struct {
+	// Allowed 非空时只提供其中列出的工具
+	Allowed []string `yaml:"allowed,omitempty"`
+	// Disabled 列出不提供给模型的工具名称
+	Disabled []string `yaml:"disabled,omitempty"`
+	// Custom 是用户定义的外部可执行工具，无需重新编译即可添加项目专用的工具
+	Custom []CustomTool `yaml:"custom,omitempty"`
+}
+
+// CustomTool 是以外部可执行文件实现的工具：agent 把模型给出的 JSON 参数写入命令的
+// 标准输入，把标准输出作为结果返回给模型；命令以非零状态退出时标准错误作为错误信息
+type CustomTool struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Command 是要执行的命令和参数，在工作区根目录中执行，相对路径的可执行文件基于项目根目录
+	Command []string `yaml:"command"`
+	// Schema 是输入参数的 JSON Schema（type: object），留空表示没有参数
+	Schema map[string]any `yaml:"schema,omitempty"`
+	// ReadOnly 表示工具不修改任何状态，执行前不需要确认
+	ReadOnly bool `yaml:"read_only,omitempty"`
+	// Timeout 限制每次执行的时间，例如 30s，默认 1m
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Shell 是 shell 工具执行命令前检查的规则，模式中的 * 匹配任意文本，
+// 模式匹配命令开头的若干个词，例如 "git push" 匹配 "git push origin main"
+type Shell struct {
+	// Allow 非空时只允许执行匹配其中某个模式的命令
+	Allow []string `yaml:"allow,omitempty"`
+	// Deny 列出禁止执行的命令模式，在内置的危险命令列表之外生效，优先于 Allow
+	Deny []string `yaml:"deny,omitempty"`
+	// UserDeny 列出用户在 REPL 中用 !命令 执行时禁止的命令模式。这些命令由用户输入，
+	// 内置的危险命令列表、Allow 和 Deny 都只约束模型，不适用于它们
+	UserDeny []string `yaml:"user_deny,omitempty"`
+	// Backend 为 host（默认）、docker 或 podman，后两者在容器中执行命令
+	Backend string `yaml:"backend,omitempty"`
+	// Image 是容器使用的镜像
+	Image string `yaml:"image,omitempty"`
+	// Network 允许容器访问网络，默认不联网
+	Network bool `yaml:"network,omitempty"`
+	// Program 是在主机上执行命令的 shell：sh、bash、cmd、powershell 或 pwsh，
+	// 留空时 Windows 上使用 cmd，其他平台使用 sh
+	Program string `yaml:"program,omitempty"`
+}
+
+// Remote 描述通过 SSH 访问的远程工作区：设置 Host 后文件工具和 shell 命令都在远程机器上执行。
+// 连接使用系统的 ssh 命令，因此 ~/.ssh/config、ssh-agent 和 known_hosts 照常生效
+type Remote struct {
+	// Host 是 ssh 的目标，例如 user@devbox 或 ~/.ssh/config 中的别名，留空表示使用本地工作区
+	Host string `yaml:"host,omitempty"`
+	// Root 是远程机器上的工作区目录，留空时为登录用户的主目录
+	Root string `yaml:"root,omitempty"`
+	// Port 和 Identity 为 0 或空时使用 ssh 的默认设置
+	Port     int    `yaml:"port,omitempty"`
+	Identity string `yaml:"identity,omitempty"`
+}
+
+// Limits 限制工具一次处理的数据量，避免误读超大文件拖垮会话；0 表示不限制
+type Limits struct {
+	// MaxReadBytes 是 read_file 可以读取的最大文件大小
+	MaxReadBytes int64 `yaml:"max_read_bytes,omitempty"`
+	// MaxWriteBytes 是 edit_file 可以编辑或写入的最大文件大小
+	MaxWriteBytes int64 `yaml:"max_write_bytes,omitempty"`
+	// MaxFilesPerTurn 是一轮对话中文件工具最多可以访问的不同文件数
+	MaxFilesPerTurn int `yaml:"max_files_per_turn,omitempty"`
+	// MaxWritesPerTurn 是一轮对话中最多可以修改的不同文件数
+	MaxWritesPerTurn int `yaml:"max_writes_per_turn,omitempty"`
+	// MaxCommandsPerMinute 是 shell 工具在任意一分钟内最多执行的命令数
+	MaxCommandsPerMinute int `yaml:"max_commands_per_minute,omitempty"`
+}
+
+// Audit 是工具调用审计日志的设置
+type Audit struct {
+	// Disabled 关闭审计日志
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Path 是审计日志文件，留空时为配置目录下的 audit.jsonl，相对路径基于项目根目录
+	Path string `yaml:"path,omitempty"`
+}
+
+// LSP 是代码智能工具（go_to_definition、find_references、rename_symbol）使用的语言服务器设置，
+// 这些工具只在项目根目录有 go.mod 时提供
+type LSP struct {
+	// Disabled 关闭代码智能工具
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Command 是语言服务器的命令及参数
+	Command []string `yaml:"command,omitempty"`
+}
+
+// Index 是工作区索引的设置。索引记录文件列表和 Go 文件的顶层符号，监视工作区并随文件变化增量更新；
+// 配置了向量模型时，文件内容的向量也只为变化的文件重新计算
+type Index struct {
+	// Enabled 在工作区建立并监视索引，提供 find_symbol 工具，配置了 Embeddings 时还提供 semantic_search
+	Enabled bool `yaml:"enabled,omitempty"`
+}
+
+// GitHub 是 github 工具的设置，token 从 TokenEnv 指定的环境变量或系统钥匙串（agent auth login github）读取
+type GitHub struct {
+	// Disabled 关闭 github 工具
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Remote 是推送使用的远程仓库名
+	Remote string `yaml:"remote,omitempty"`
+	// TokenEnv 是保存 token 的环境变量名，默认为 GITHUB_TOKEN；只在用户配置中生效
+	TokenEnv string `yaml:"token_env,omitempty"`
+	// APIURL 是 API 地址，GitHub Enterprise 为 https://HOST/api/v3；只在用户配置中生效。
+	// token 只发给 github.com 和这里的主机，留空时其他主机的请求不带 token
+	APIURL string `yaml:"api_url,omitempty"`
+}
+
+// Storage 是 storage 工具可以读取的对象存储，配置了 Buckets 时提供该工具。
+// 访问通过 aws 和 gcloud 命令进行，使用它们的标准凭据链
+type Storage struct {
+	// Buckets 列出可以读取的存储桶，例如 s3://pipeline-data 或 gs://exports-*，* 匹配任意文本
+	Buckets []string `yaml:"buckets,omitempty"`
+}
+
+// Kubernetes 是 kubernetes 工具的设置，工具通过 kubectl 查看集群，默认只能执行只读的命令
+type Kubernetes struct {
+	// Enabled 提供 kubernetes 工具
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Context 和 Namespace 为空时使用 kubeconfig 中的当前值
+	Context   string `yaml:"context,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	// Verbs 是在只读命令之外允许执行的 kubectl 命令，例如 rollout 或 scale
+	Verbs []string `yaml:"verbs,omitempty"`
+	// AllowSecrets 允许读取 Secret 资源，AllowRaw 允许用 kubectl get --raw 访问任意 API 路径
+	AllowSecrets bool `yaml:"allow_secrets,omitempty"`
+	AllowRaw     bool `yaml:"allow_raw,omitempty"`
+}
+
+// Docker 是 docker 工具的设置，工具可以构建镜像、启停 compose 服务和查看容器
+type Docker struct {
+	// Enabled 提供 docker 工具
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Runtime 为 docker（默认）或 podman
+	Runtime string `yaml:"runtime,omitempty"`
+}
+
+// Watch 是 agent watch 的设置：工作区中的文件变化后执行 Test，测试失败时把 Task 交给模型；
+// 没有 Test 时每次变化都交给模型
+type Watch struct {
+	// Task 是触发时发给模型的指令，例如 "Diagnose the failing tests and propose a fix"
+	Task string `yaml:"task,omitempty"`
+	// Test 是文件变化后执行的命令和参数，例如 [go, test, ./...]
+	Test []string `yaml:"test,omitempty"`
+	// Cooldown 是两次触发之间的最短间隔，默认 2m
+	Cooldown time.Duration `yaml:"cooldown,omitempty"`
+	// Interval 是检查文件变化的间隔，默认 2s
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// Verify 是工具修改文件后运行的快速检查，失败时把输出附在工具结果中报告给模型，
+// 让它在同一轮中修正
+type Verify struct {
+	// Disabled 关闭修改后的检查
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Checks 是要运行的检查，默认检查 Go 文件的格式以及所在的包能否编译；只在用户配置中生效。
+	// 检查像 shell 工具的命令一样执行：使用同样的执行后端，并且要通过 Shell 的允许和拒绝列表
+	Checks []VerifyCheck `yaml:"checks,omitempty"`
+}
+
+// VerifyCheck 是对被修改文件运行的一条检查命令。命令中的 {file} 替换为文件相对于
+// 项目根目录的路径，{dir} 替换为文件所在目录（形如 ./pkg/a）；命令以非零状态退出，
+// 或者设置了 FailOnOutput 且有输出时检查失败
+type VerifyCheck struct {
+	// Files 是匹配文件名的 glob，例如 *.go
+	Files string `yaml:"files"`
+	// Command 是命令及参数，例如 [go, build, "{dir}"]
+	Command []string `yaml:"command"`
+	// FailOnOutput 表示有输出即失败，用于 gofmt -l 这类成功时也以零状态退出的命令
+	FailOnOutput bool `yaml:"fail_on_output,omitempty"`
+	// Requires 非空时只在项目根目录存在该文件时运行，例如 go.mod
+	Requires string `yaml:"requires,omitempty"`
+}
+
+// Notify 是交互式会话中长时间运行的一轮结束或等待批准时的提醒，
+// 方便用户在等待时切换去做别的事
+type Notify struct {
+	// Disabled 关闭提醒
+	Disabled bool `yaml:"disabled,omitempty"`
+	// After 是一轮运行多久之后才提醒，默认 30s
+	After time.Duration `yaml:"after,omitempty"`
+	// Bell 表示同时响终端铃声，桌面通知不可用时（例如通过 ssh）也能听到
+	Bell bool `yaml:"bell,omitempty"`
+}
+
+// Replies 是显示和保存助手回复之前对回复的处理
+type Replies struct {
+	// PostProcess 是依次处理每条回复的处理器，取 PostProcessors 中的名称；
+	// 项目脚本的 after_reply 钩子在它们之后执行
+	PostProcess []string `yaml:"post_process,omitempty"`
+	// Language 是 language 处理器要求的回复语言 zh 或 en，留空时使用界面语言
+	Language string `yaml:"language,omitempty"`
+}
+
+// PostProcessors 是内置的回复处理器：strip_thinking 去掉 <thinking> 等思考过程，
+// language 在回复不是所要求的语言时请模型改写，extract_code 把标明了文件路径的代码块
+// （例如 ```go cmd/main.go）写入工作区
+var PostProcessors = []string{"strip_thinking", "language", "extract_code"}
+
+// Embeddings 是计算文本向量的模型，embed_text 工具和语义检索使用它
+type Embeddings struct {
+	// Provider 是 openai、gemini 或 ollama，留空时不提供 embed_text 工具
+	Provider string `yaml:"provider,omitempty"`
+	// Model 是向量模型，留空时使用提供方的默认模型
+	Model string `yaml:"model,omitempty"`
+	// BaseURL 覆盖提供方的 API 地址，例如兼容 OpenAI 的服务或远程的 Ollama
+	BaseURL string `yaml:"base_url,omitempty"`
+	// APIKeyEnv 是读取 API key 的环境变量，留空时使用提供方的默认变量；ollama 不需要
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+}
+
+// Knowledge 是每个仓库的知识库 .agent/knowledge.md，记录架构、做过的决定和踩过的坑：
+// 会话开始时作为系统提示加载，会话结束时由模型总结本次会话学到的内容并更新它
+type Knowledge struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MaxBytes 是知识库的大小上限，默认 16384；更新时要求模型精简到限制之内
+	MaxBytes int `yaml:"max_bytes,omitempty"`
+}
+
+// Sessions 是保存会话的位置
+type Sessions struct {
+	// Backend 是 files（每个会话一个 JSON 文件，默认）或 sqlite（一个数据库，
+	// 适合同时运行很多会话的服务器）；切换到 sqlite 时导入已有的会话文件
+	Backend string `yaml:"backend,omitempty"`
+	// Path 是 sqlite 数据库文件，留空时为配置目录中的 sessions.db
+	Path string `yaml:"path,omitempty"`
+}
+
+// Model 描述一个模型的上下文窗口、能力和价格
+type Model struct {
+	// Name 匹配以它开头的模型名，例如 llama3 匹配 llama3.1:8b
+	Name string `yaml:"name"`
+	// ContextWindow 是上下文窗口的 token 数，0 表示未知
+	ContextWindow int64 `yaml:"context_window,omitempty"`
+	// NoTools 表示模型不支持工具调用，请求中不发送工具
+	NoTools bool `yaml:"no_tools,omitempty"`
+	// Vision 表示模型接受图片输入
+	Vision bool `yaml:"vision,omitempty"`
+	// InputPrice 和 OutputPrice 是每百万 token 的美元价格，用于估算费用
+	InputPrice  float64 `yaml:"input_price,omitempty"`
+	OutputPrice float64 `yaml:"output_price,omitempty"`
+}
+
+// Server 是 agent serve 的用户：配置了 Users 或 OIDC 后每个请求都要认证，每个用户只能
+// 看到自己的会话，并受自己的工具权限和配额限制
+type Server struct {
+	Users []ServerUser `yaml:"users,omitempty"`
+	OIDC  OIDC         `yaml:"oidc,omitempty"`
+}
+
+// ServerUser 是一个服务器用户及其权限和配额
+type ServerUser struct {
+	// Name 是用户名；通过 OIDC 登录的用户按 OIDC.Claim 的值匹配，名为 * 的条目适用于
+	// 其他所有通过 OIDC 登录的用户
+	Name string `yaml:"name"`
+	// APIKeyEnv 是保存该用户 API key 的环境变量名，客户端以 Authorization: Bearer <key> 认证
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+	// Tools 非空时只提供其中列出的工具
+	Tools []string `yaml:"tools,omitempty"`
+	// Permissions 非空时只提供所需权限都在其中的工具：read、write、exec 或 network；
+	// 没有声明权限的工具视为需要 exec
+	Permissions []string `yaml:"permissions,omitempty"`
+	// MaxTokensPerDay 和 MaxCostPerDay 是用户所有会话每天（UTC）合计的 token 数和估算费用
+	// （美元）上限，0 表示不限制
+	MaxTokensPerDay int64   `yaml:"max_tokens_per_day,omitempty"`
+	MaxCostPerDay   float64 `yaml:"max_cost_per_day,omitempty"`
+}
+
+// OIDC 是用 OpenID Connect 提供方签发的 ID token 认证服务器用户的设置
+type OIDC struct {
+	// Issuer 是提供方的 URL，例如 https://accounts.google.com
+	Issuer string `yaml:"issuer,omitempty"`
+	// ClientID 是 token 的 audience
+	ClientID string `yaml:"client_id,omitempty"`
+	// Claim 是作为用户名的 claim，默认 email
+	Claim string `yaml:"claim,omitempty"`
+}
+
+// ToolPermissions 是服务器用户可以获得的工具权限
+var ToolPermissions = []string{"read", "write", "exec", "network"}
+
+// validate 检查用户名唯一，每个用户都有认证方式，权限和配额合法
+func (s Server) validate() error {
+	if (s.OIDC.Issuer == "") != (s.OIDC.ClientID == "") {
+		return fmt.Errorf("server oidc needs both issuer and client_id")
+	}
+	names := map[string]bool{}
+	for _, user := range s.Users {
+		if user.Name == "" {
+			return fmt.Errorf("server user without a name")
+		}
+		if names[user.Name] {
+			return fmt.Errorf("duplicate server user %q", user.Name)
+		}
+		names[user.Name] = true
+		if user.APIKeyEnv == "" && s.OIDC.Issuer == "" {
+			return fmt.Errorf("server user %q has no api_key_env and oidc is not configured", user.Name)
+		}
+		if user.Name == "*" && user.APIKeyEnv != "" {
+			return fmt.Errorf("server user * matches OIDC users and cannot have an api_key_env")
+		}
+		for _, permission := range user.Permissions {
+			if !slices.Contains(ToolPermissions, permission) {
+				return fmt.Errorf("server user %q: unknown permission %q, want one of %s", user.Name, permission, strings.Join(ToolPermissions, ", "))
+			}
+		}
+		if user.MaxTokensPerDay < 0 || user.MaxCostPerDay < 0 {
+			return fmt.Errorf("server user %q: quotas must not be negative", user.Name)
+		}
+	}
+	return nil
+}
+
+// SessionBackends 是支持的会话存储
+var SessionBackends = []string{"files", "sqlite"}
+
+// EmbeddingProviders 是支持的向量提供方
+var EmbeddingProviders = []string{"openai", "gemini", "ollama"}
+
+// Team 是 agent team 中协作的角色：planner 把目标拆成任务板上的任务，implementer
+// 逐个实现，reviewer 审查每个任务，不通过时把意见交回 implementer 修改
+type Team struct {
+	Planner     Role `yaml:"planner,omitempty"`
+	Implementer Role `yaml:"implementer,omitempty"`
+	Reviewer    Role `yaml:"reviewer,omitempty"`
+	// Rounds 是一个任务最多审查的次数，默认 3
+	Rounds int `yaml:"rounds,omitempty"`
+}
+
+// Role 是团队中一个角色的设置
+type Role struct {
+	// Disabled 去掉这个角色：没有 planner 时目标本身就是唯一的任务，没有 reviewer 时
+	// 任务实现后不经审查即完成；implementer 不能去掉
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Prompt 是角色的系统提示，留空时使用内置的提示
+	Prompt string `yaml:"prompt,omitempty"`
+	// Profile 是角色使用的配置档，例如让另一个模型来审查；留空时使用当前的配置
+	Profile string `yaml:"profile,omitempty"`
+	// Tools 非空时只提供其中列出的工具；默认 planner 和 reviewer 只有只读工具
+	Tools []string `yaml:"tools,omitempty"`
+}
+
+// merge 把 overlay 中设置了的字段覆盖到角色上
+func (r *Role) merge(overlay Role) {
+	if overlay.Disabled {
+		r.Disabled = true
+	}
+	if overlay.Prompt != "" {
+		r.Prompt = overlay.Prompt
+	}
+	if overlay.Profile != "" {
+		r.Profile = overlay.Profile
+	}
+	if overlay.Tools != nil {
+		r.Tools = overlay.Tools
+	}
+}
+
+// Tracker 是 tracker 工具访问的问题跟踪系统，配置了 Jira 或 Linear 之一时提供该工具
+type Tracker struct {
+	Jira   Jira   `yaml:"jira,omitempty"`
+	Linear Linear `yaml:"linear,omitempty"`
+}
+
+// Jira 是 Jira 的站点和认证设置，设置了 URL 才会启用
+type Jira struct {
+	// URL 是站点地址，例如 https://example.atlassian.net
+	URL string `yaml:"url,omitempty"`
+	// Email 是 Jira Cloud 账号的邮箱，与 API token 一起用于认证；留空时 token 作为个人访问令牌（Jira Server/Data Center）
+	Email string `yaml:"email,omitempty"`
+	// TokenEnv 是保存 token 的环境变量名，默认为 JIRA_API_TOKEN，也可以用 agent auth login jira 存入钥匙串
+	TokenEnv string `yaml:"token_env,omitempty"`
+}
+
+// Linear 是 Linear 的认证设置，找到 API key 时启用
+type Linear struct {
+	// TokenEnv 是保存 API key 的环境变量名，默认为 LINEAR_API_KEY，也可以用 agent auth login linear 存入钥匙串
+	TokenEnv string `yaml:"token_env,omitempty"`
+}
+
+// Tracing 是 OpenTelemetry 追踪的设置，设置了 Endpoint 或 OTEL_EXPORTER_OTLP_ENDPOINT 环境变量时
+// 把推理调用、工具执行和对话轮次的 span 通过 OTLP/HTTP 导出
+type Tracing struct {
+	// Endpoint 是 OTLP/HTTP 接收端地址，例如 http://localhost:4318
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// Transcripts 是 provider 通信记录的设置：开启后每次请求和响应的 HTTP 正文在脱敏后
+// 按会话追加到 JSONL 文件，用于离线分析提示词和排查 provider 格式转换的问题
+type Transcripts struct {
+	// Enabled 开启通信记录
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Dir 是记录文件所在目录，留空时为配置目录下的 transcripts，相对路径基于项目根目录
+	Dir string `yaml:"dir,omitempty"`
+}
+
+// Voice 是 /voice 打开的语音模式：麦克风录音用兼容 Whisper 的服务转写后作为消息发送，
+// 助手的回复用 TTS 朗读
+type Voice struct {
+	// BaseURL 覆盖 OpenAI 的 API 地址，例如本地兼容的语音服务
+	BaseURL string `yaml:"base_url,omitempty"`
+	// APIKeyEnv 是读取 API key 的环境变量，留空时使用 OPENAI_API_KEY
+	APIKeyEnv string `yaml:"api_key_env,omitempty"`
+	// TranscriptionModel 是转写模型，默认 whisper-1
+	TranscriptionModel string `yaml:"transcription_model,omitempty"`
+	// SpeechModel 是朗读模型，默认 tts-1
+	SpeechModel string `yaml:"speech_model,omitempty"`
+	// Voice 是朗读的声音，默认 alloy
+	Voice string `yaml:"voice,omitempty"`
+	// Record 是录音命令，把 WAV 写入 {file}，说完停顿后自行退出；默认使用 sox 的 rec
+	Record []string `yaml:"record,omitempty"`
+	// Play 是播放 {file} 中 mp3 音频的命令；默认依次尝试 afplay、mpv 和 ffplay
+	Play []string `yaml:"play,omitempty"`
+}
+
+// SystemSections 是系统提示词的内置部分，按默认顺序排列：persona（角色）、tools（工具
+// 使用说明）、repo_map（工作区的文件列表）、instructions（项目指令）、knowledge（项目
+// 知识库）、git（仓库状态）和 pinned（固定的文件）。persona 和 tools 默认为空
+var SystemSections = []string{"persona", "tools", "repo_map", "instructions", "knowledge", "git", "pinned"}
+
+// System 配置系统提示词由哪些部分、按什么顺序组成，每个部分是一条系统消息
+type System struct {
+	// Order 调整各部分的顺序，未列出的部分按默认顺序排在后面
+	Order []string `yaml:"order,omitempty"`
+	// Disabled 是不发送的部分
+	Disabled []string `yaml:"disabled,omitempty"`
+	// Sections 添加新的部分，或用同名的部分覆盖内置部分，例如设置 persona
+	Sections []SystemSection `yaml:"sections,omitempty"`
+	// RepoMap 在 repo_map 部分列出工作区中的文件，文件列表来自监视工作区的索引（见 Index），
+	// 每次请求都是最新的
+	RepoMap bool `yaml:"repo_map,omitempty"`
+}
+
+// SystemSection 是配置的系统提示词部分，内容来自 Text 或 File 之一
+type SystemSection struct {
+	Name string `yaml:"name"`
+	Text string `yaml:"text,omitempty"`
+	// File 是读取内容的文件，相对路径基于项目根目录
+	File string `yaml:"file,omitempty"`
+}
+
+// Sandbox 限制文件工具可以访问的路径
+type Sandbox struct {
+	// Paths 非空时文件工具只能访问这些目录，相对路径基于项目根目录；
+	// shell 等执行程序的工具不受路径限制，因此不再提供
+	Paths []string `yaml:"paths,omitempty"`
+}
+
+// ProjectFile 是项目根目录下的配置文件名
+const ProjectFile = ".agent.yaml"
+
+// Project 是项目的 .agent.yaml 中不需要信任就生效的设置。项目文件随仓库分发，
+// 打开一个陌生的仓库不应该让它执行命令、更换 API 端点或读取别的凭据，所以这些设置
+// 只能收紧用户配置；其余设置只在项目列入 TrustedProjects 后生效
+type Project struct {
+	Model string `yaml:"model,omitempty"`
+	// Tools 的允许列表与用户配置的取交集，禁用列表与用户配置的合并
+	Tools ProjectTools `yaml:"tools,omitempty"`
+	// Instructions 添加在用户配置的指令文件之后，必须是项目目录之内的相对路径
+	Instructions []string `yaml:"instructions,omitempty"`
+	// Sandbox 限制文件工具的路径；用户配置了沙箱时必须在用户的沙箱之内
+	Sandbox Sandbox `yaml:"sandbox,omitempty"`
+
+	// Dir 是项目根目录
+	Dir string `yaml:"-"`
+	// Ignored 是文件中设置了但不在 Project 中的顶层键
+	Ignored []string `yaml:"-"`
+}
+
+// ProjectTools 是项目可以设置的工具范围
+type ProjectTools struct {
+	Allowed  []string `yaml:"allowed,omitempty"`
+	Disabled []string `yaml:"disabled,omitempty"`
+}
+
+// projectKeys 是 Project 中的顶层键
+var projectKeys = map[string]bool{"model": true, "tools": true, "instructions": true, "sandbox": true}
+
+// 可以覆盖配置文件的环境变量
+const (
+	EnvProvider  = "AGENT_PROVIDER"
+	EnvModel     = "AGENT_MODEL"
+	EnvMaxTokens = "AGENT_MAX_TOKENS"
+	EnvTheme     = "AGENT_THEME"
+	EnvLanguage  = "AGENT_LANG"
+	EnvProfile   = "AGENT_PROFILE"
+)
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		MaxTokens: 1024,
+		Theme:     "default",
+		Color:     "auto",
+		Shell: Shell{
+			Backend: "host",
+			Image:   "debian:stable-slim",
+		},
+		LSP: LSP{
+			Command: []string{"gopls"},
+		},
+		Notify: Notify{
+			After: 30 * time.Second,
+		},
+		Team: Team{
+			Rounds: 3,
+		},
+		Verify: Verify{
+			Checks: []VerifyCheck{
+				{Files: "*.go", Command: []string{"gofmt", "-l", "{file}"}, FailOnOutput: true, Requires: "go.mod"},
+				{Files: "*.go", Command: []string{"go", "build", "{dir}"}, Requires: "go.mod"},
+			},
+		},
+		GitHub: GitHub{
+			Remote: "origin",
+		},
+		Limits: Limits{
+			MaxReadBytes:         1 << 20,
+			MaxWriteBytes:        1 << 20,
+			MaxFilesPerTurn:      50,
+			MaxWritesPerTurn:     20,
+			MaxCommandsPerMinute: 30,
+		},
+	}
+}
+
+// DefaultPath 返回默认配置文件路径 ~/.config/agent/config.yaml
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "agent", "config.yaml"), nil
+}
+
+// Load 读取配置文件并叠加在默认配置之上；文件不存在时返回默认配置
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// FindProjectFile 从 dir 向上查找 .agent.yaml，到达 git 仓库根目录或文件系统根目录为止
+func FindProjectFile(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// LoadProject 读取项目配置的全部设置，只包含文件中设置了的字段；只用于信任的项目，见 UseProject
+func LoadProject(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overlay := &Config{}
+	if err := yaml.Unmarshal(data, overlay); err != nil {
+		return nil, fmt.Errorf("invalid project config %s: %w", path, err)
+	}
+	overlay.ProjectDir = filepath.Dir(path)
+	return overlay, overlay.Validate()
+}
+
+// ReadProject 读取项目配置中不需要信任就生效的设置，其余设置了的顶层键记录在 Ignored 中
+func ReadProject(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid project config %s: %w", path, err)
+	}
+	project := &Project{Dir: filepath.Dir(path)}
+	if err := yaml.Unmarshal(data, project); err != nil {
+		return nil, fmt.Errorf("invalid project config %s: %w", path, err)
+	}
+	for key := range keys {
+		if !projectKeys[key] {
+			project.Ignored = append(project.Ignored, key)
+		}
+	}
+	sort.Strings(project.Ignored)
+	return project, nil
+}
+
+// IsTrustedProject 报告 dir 是否列在 TrustedProjects 中
+func (c *Config) IsTrustedProject(dir string) bool {
+	for _, trusted := range c.TrustedProjects {
+		if sameDir(trusted, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDir 报告两个目录在解析符号链接后是否相同
+func sameDir(a, b string) bool {
+	resolve := func(dir string) string {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		return dir
+	}
+	return resolve(a) == resolve(b)
+}
+
+// UseProject 叠加 path 处的项目配置：信任的项目合并文件中的所有设置，
+// 其他项目只应用 Project 中的设置，没有生效的顶层键记录在 ProjectIgnored 中
+func (c *Config) UseProject(path string) error {
+	if c.IsTrustedProject(filepath.Dir(path)) {
+		overlay, err := LoadProject(path)
+		if err != nil {
+			return err
+		}
+		withoutUserOnly(overlay)
+		c.Merge(overlay)
+		return nil
+	}
+	project, err := ReadProject(path)
+	if err != nil {
+		return err
+	}
+	if err := c.ApplyProject(project); err != nil {
+		return fmt.Errorf("project config %s: %w", path, err)
+	}
+	return nil
+}
+
+// withoutUserOnly 去掉只能在用户配置中设置的字段：GitHub token 所在的环境变量和它发往的 API 地址，
+// 以及每次修改文件后自动执行的检查命令。即使信任了项目，仓库中的文件也不能决定 token 发给谁，
+// 也不能让模型的每次修改都触发它写下的命令
+func withoutUserOnly(overlay *Config) {
+	overlay.GitHub.TokenEnv = ""
+	overlay.GitHub.APIURL = ""
+	overlay.Verify.Checks = nil
+	for _, profile := range overlay.Profiles {
+		withoutUserOnly(profile)
+	}
+}
+
+// ApplyProject 把项目设置叠加到 c 上，只收紧不放宽：允许的工具取交集，禁用的工具合并，
+// 指令文件追加且必须在项目目录之内，沙箱路径必须在用户配置的沙箱之内
+func (c *Config) ApplyProject(p *Project) error {
+	// 用户配置中的相对路径基于当前目录，要在设置项目目录之前解析，否则之后会被当作项目中的路径
+	var err error
+	if c.Instructions, err = c.resolvePaths(c.Instructions); err != nil {
+		return err
+	}
+	if c.Sandbox.Paths, err = c.resolvePaths(c.Sandbox.Paths); err != nil {
+		return err
+	}
+	c.ProjectDir = p.Dir
+	c.ProjectIgnored = p.Ignored
+	if p.Model != "" {
+		c.Model = p.Model
+	}
+	if p.Tools.Allowed != nil {
+		allowed := p.Tools.Allowed
+		if len(c.Tools.Allowed) > 0 {
+			allowed = nil
+			for _, name := range p.Tools.Allowed {
+				if contains(c.Tools.Allowed, name) {
+					allowed = append(allowed, name)
+				}
+			}
+			if len(allowed) == 0 {
+				return fmt.Errorf("tools.allowed keeps none of the tools the user config allows")
+			}
+		}
+		c.Tools.Allowed = allowed
+	}
+	for _, name := range p.Tools.Disabled {
+		if !contains(c.Tools.Disabled, name) {
+			c.Tools.Disabled = append(c.Tools.Disabled, name)
+		}
+	}
+	for _, name := range p.Instructions {
+		if !p.contains(name) {
+			return fmt.Errorf("instruction file %s is outside the project", name)
+		}
+		if !contains(c.Instructions, name) {
+			c.Instructions = append(c.Instructions, name)
+		}
+	}
+	if p.Sandbox.Paths != nil {
+		roots := c.Sandbox.Paths
+		for _, path := range p.Sandbox.Paths {
+			resolved, err := c.ResolvePath(path)
+			if err != nil {
+				return err
+			}
+			if len(roots) > 0 && !slices.ContainsFunc(roots, func(root string) bool { return within(resolved, root) }) {
+				return fmt.Errorf("sandbox path %s is outside the user config's sandbox", path)
+			}
+		}
+		c.Sandbox.Paths = p.Sandbox.Paths
+	}
+	return nil
+}
+
+// resolvePaths 返回 paths 中每个路径经 ResolvePath 解析后的结果
+func (c *Config) resolvePaths(paths []string) ([]string, error) {
+	var resolved []string
+	for _, path := range paths {
+		abs, err := c.ResolvePath(path)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, abs)
+	}
+	return resolved, nil
+}
+
+// contains 报告项目中的相对路径 name 是否在项目目录之内，已存在的文件按解析符号链接后的位置判断
+func (p *Project) contains(name string) bool {
+	if filepath.IsAbs(name) {
+		return false
+	}
+	path := filepath.Join(p.Dir, name)
+	if !within(path, p.Dir) {
+		return false
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return true
+	}
+	dir, err := filepath.EvalSymlinks(p.Dir)
+	return err == nil && within(target, dir)
+}
+
+// within 报告 path 是否是 root 或在它之下
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Merge 用 overlay 中设置了的字段覆盖 c，列表整体替换，提示词模板和自定义工具按名称合并
+func (c *Config) Merge(overlay *Config) {
+	if overlay.Provider != "" {
+		c.Provider = overlay.Provider
+	}
+	if overlay.Model != "" {
+		c.Model = overlay.Model
+	}
+	if overlay.MaxTokens != 0 {
+		c.MaxTokens = overlay.MaxTokens
+	}
+	if overlay.BaseURL != "" {
+		c.BaseURL = overlay.BaseURL
+	}
+	if overlay.APIKeyEnv != "" {
+		c.APIKeyEnv = overlay.APIKeyEnv
+	}
+	if overlay.Theme != "" {
+		c.Theme = overlay.Theme
+	}
+	if overlay.Profile != "" {
+		c.Profile = overlay.Profile
+	}
+	for name, profile := range overlay.Profiles {
+		if c.Profiles == nil {
+			c.Profiles = map[string]*Config{}
+		}
+		c.Profiles[name] = profile
+	}
+	if overlay.Color != "" {
+		c.Color = overlay.Color
+	}
+	if overlay.Language != "" {
+		c.Language = overlay.Language
+	}
+	if overlay.Tools.Allowed != nil {
+		c.Tools.Allowed = overlay.Tools.Allowed
+	}
+	if overlay.Tools.Disabled != nil {
+		c.Tools.Disabled = overlay.Tools.Disabled
+	}
+	for _, tool := range overlay.Tools.Custom {
+		c.Tools.Custom = append(withoutCustomTool(c.Tools.Custom, tool.Name), tool)
+	}
+	if overlay.Instructions != nil {
+		c.Instructions = overlay.Instructions
+	}
+	if overlay.Sandbox.Paths != nil {
+		c.Sandbox.Paths = overlay.Sandbox.Paths
+	}
+	if overlay.Shell.Allow != nil {
+		c.Shell.Allow = overlay.Shell.Allow
+	}
+	if overlay.Shell.Deny != nil {
+		c.Shell.Deny = overlay.Shell.Deny
+	}
+	if overlay.Shell.UserDeny != nil {
+		c.Shell.UserDeny = overlay.Shell.UserDeny
+	}
+	if overlay.Shell.Backend != "" {
+		c.Shell.Backend = overlay.Shell.Backend
+	}
+	if overlay.Shell.Image != "" {
+		c.Shell.Image = overlay.Shell.Image
+	}
+	if overlay.Shell.Program != "" {
+		c.Shell.Program = overlay.Shell.Program
+	}
+	if overlay.Shell.Network {
+		c.Shell.Network = true
+	}
+	if overlay.Remote.Host != "" {
+		c.Remote.Host = overlay.Remote.Host
+	}
+	if overlay.Remote.Root != "" {
+		c.Remote.Root = overlay.Remote.Root
+	}
+	if overlay.Remote.Port != 0 {
+		c.Remote.Port = overlay.Remote.Port
+	}
+	if overlay.Remote.Identity != "" {
+		c.Remote.Identity = overlay.Remote.Identity
+	}
+	if overlay.Limits.MaxReadBytes != 0 {
+		c.Limits.MaxReadBytes = overlay.Limits.MaxReadBytes
+	}
+	if overlay.Limits.MaxWriteBytes != 0 {
+		c.Limits.MaxWriteBytes = overlay.Limits.MaxWriteBytes
+	}
+	if overlay.Limits.MaxFilesPerTurn != 0 {
+		c.Limits.MaxFilesPerTurn = overlay.Limits.MaxFilesPerTurn
+	}
+	if overlay.Limits.MaxWritesPerTurn != 0 {
+		c.Limits.MaxWritesPerTurn = overlay.Limits.MaxWritesPerTurn
+	}
+	if overlay.Limits.MaxCommandsPerMinute != 0 {
+		c.Limits.MaxCommandsPerMinute = overlay.Limits.MaxCommandsPerMinute
+	}
+	if overlay.Audit.Disabled {
+		c.Audit.Disabled = true
+	}
+	if overlay.Audit.Path != "" {
+		c.Audit.Path = overlay.Audit.Path
+	}
+	if overlay.LSP.Disabled {
+		c.LSP.Disabled = true
+	}
+	if len(overlay.LSP.Command) > 0 {
+		c.LSP.Command = overlay.LSP.Command
+	}
+	if overlay.Index.Enabled {
+		c.Index.Enabled = true
+	}
+	if overlay.GitHub.Disabled {
+		c.GitHub.Disabled = true
+	}
+	if overlay.GitHub.Remote != "" {
+		c.GitHub.Remote = overlay.GitHub.Remote
+	}
+	if overlay.GitHub.TokenEnv != "" {
+		c.GitHub.TokenEnv = overlay.GitHub.TokenEnv
+	}
+	if overlay.GitHub.APIURL != "" {
+		c.GitHub.APIURL = overlay.GitHub.APIURL
+	}
+	if overlay.Storage.Buckets != nil {
+		c.Storage.Buckets = overlay.Storage.Buckets
+	}
+	if overlay.Kubernetes.Enabled {
+		c.Kubernetes.Enabled = true
+	}
+	if overlay.Kubernetes.Context != "" {
+		c.Kubernetes.Context = overlay.Kubernetes.Context
+	}
+	if overlay.Kubernetes.Namespace != "" {
+		c.Kubernetes.Namespace = overlay.Kubernetes.Namespace
+	}
+	if overlay.Kubernetes.Verbs != nil {
+		c.Kubernetes.Verbs = overlay.Kubernetes.Verbs
+	}
+	if overlay.Kubernetes.AllowSecrets {
+		c.Kubernetes.AllowSecrets = true
+	}
+	if overlay.Kubernetes.AllowRaw {
+		c.Kubernetes.AllowRaw = true
+	}
+	if overlay.Docker.Enabled {
+		c.Docker.Enabled = true
+	}
+	if overlay.Docker.Runtime != "" {
+		c.Docker.Runtime = overlay.Docker.Runtime
+	}
+	if overlay.Watch.Task != "" {
+		c.Watch.Task = overlay.Watch.Task
+	}
+	if overlay.Watch.Test != nil {
+		c.Watch.Test = overlay.Watch.Test
+	}
+	if overlay.Watch.Cooldown != 0 {
+		c.Watch.Cooldown = overlay.Watch.Cooldown
+	}
+	if overlay.Watch.Interval != 0 {
+		c.Watch.Interval = overlay.Watch.Interval
+	}
+	c.Team.Planner.merge(overlay.Team.Planner)
+	c.Team.Implementer.merge(overlay.Team.Implementer)
+	c.Team.Reviewer.merge(overlay.Team.Reviewer)
+	if overlay.Team.Rounds != 0 {
+		c.Team.Rounds = overlay.Team.Rounds
+	}
+	if overlay.Notify.Disabled {
+		c.Notify.Disabled = true
+	}
+	if overlay.Notify.After != 0 {
+		c.Notify.After = overlay.Notify.After
+	}
+	if overlay.Notify.Bell {
+		c.Notify.Bell = true
+	}
+	if overlay.Replies.PostProcess != nil {
+		c.Replies.PostProcess = overlay.Replies.PostProcess
+	}
+	if overlay.Replies.Language != "" {
+		c.Replies.Language = overlay.Replies.Language
+	}
+	if overlay.Sessions.Backend != "" {
+		c.Sessions.Backend = overlay.Sessions.Backend
+	}
+	if overlay.Sessions.Path != "" {
+		c.Sessions.Path = overlay.Sessions.Path
+	}
+	if overlay.Models != nil {
+		c.Models = overlay.Models
+	}
+	if overlay.Server.Users != nil {
+		c.Server.Users = overlay.Server.Users
+	}
+	if overlay.Server.OIDC.Issuer != "" {
+		c.Server.OIDC = overlay.Server.OIDC
+	}
+	if overlay.Knowledge.Enabled {
+		c.Knowledge.Enabled = true
+	}
+	if overlay.Knowledge.MaxBytes != 0 {
+		c.Knowledge.MaxBytes = overlay.Knowledge.MaxBytes
+	}
+	if overlay.Embeddings.Provider != "" {
+		c.Embeddings = overlay.Embeddings
+	}
+	if overlay.Verify.Disabled {
+		c.Verify.Disabled = true
+	}
+	if overlay.Verify.Checks != nil {
+		c.Verify.Checks = overlay.Verify.Checks
+	}
+	if overlay.Tracker.Jira.URL != "" {
+		c.Tracker.Jira.URL = overlay.Tracker.Jira.URL
+	}
+	if overlay.Tracker.Jira.Email != "" {
+		c.Tracker.Jira.Email = overlay.Tracker.Jira.Email
+	}
+	if overlay.Tracker.Jira.TokenEnv != "" {
+		c.Tracker.Jira.TokenEnv = overlay.Tracker.Jira.TokenEnv
+	}
+	if overlay.Tracker.Linear.TokenEnv != "" {
+		c.Tracker.Linear.TokenEnv = overlay.Tracker.Linear.TokenEnv
+	}
+	if overlay.Tracing.Endpoint != "" {
+		c.Tracing.Endpoint = overlay.Tracing.Endpoint
+	}
+	if overlay.Transcripts.Enabled {
+		c.Transcripts.Enabled = true
+	}
+	if overlay.Transcripts.Dir != "" {
+		c.Transcripts.Dir = overlay.Transcripts.Dir
+	}
+	if overlay.Voice.BaseURL != "" {
+		c.Voice.BaseURL = overlay.Voice.BaseURL
+	}
+	if overlay.Voice.APIKeyEnv != "" {
+		c.Voice.APIKeyEnv = overlay.Voice.APIKeyEnv
+	}
+	if overlay.Voice.TranscriptionModel != "" {
+		c.Voice.TranscriptionModel = overlay.Voice.TranscriptionModel
+	}
+	if overlay.Voice.SpeechModel != "" {
+		c.Voice.SpeechModel = overlay.Voice.SpeechModel
+	}
+	if overlay.Voice.Voice != "" {
+		c.Voice.Voice = overlay.Voice.Voice
+	}
+	if overlay.Voice.Record != nil {
+		c.Voice.Record = overlay.Voice.Record
+	}
+	if overlay.Voice.Play != nil {
+		c.Voice.Play = overlay.Voice.Play
+	}
+	if overlay.System.Order != nil {
+		c.System.Order = overlay.System.Order
+	}
+	if overlay.System.Disabled != nil {
+		c.System.Disabled = overlay.System.Disabled
+	}
+	for _, section := range overlay.System.Sections {
+		replaced := false
+		for i := range c.System.Sections {
+			if c.System.Sections[i].Name == section.Name {
+				c.System.Sections[i], replaced = section, true
+			}
+		}
+		if !replaced {
+			c.System.Sections = append(c.System.Sections, section)
+		}
+	}
+	if overlay.System.RepoMap {
+		c.System.RepoMap = true
+	}
+	if overlay.DumpRequests != "" {
+		c.DumpRequests = overlay.DumpRequests
+	}
+	if overlay.ResponseCache != "" {
+		c.ResponseCache = overlay.ResponseCache
+	}
+	if overlay.TimeFormat != "" {
+		c.TimeFormat = overlay.TimeFormat
+	}
+	if overlay.Timestamps {
+		c.Timestamps = true
+	}
+	if len(overlay.Prompts) > 0 {
+		merged := map[string]string{}
+		for name, body := range c.Prompts {
+			merged[name] = body
+		}
+		for name, body := range overlay.Prompts {
+			merged[name] = body
+		}
+		c.Prompts = merged
+	}
+	if overlay.ProjectDir != "" {
+		c.ProjectDir = overlay.ProjectDir
+	}
+}
+
+// ProfileNames 返回配置档名称，按字母排序
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseProfile 把指定配置档覆盖到配置上
+func (c *Config) UseProfile(name string) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	c.Merge(profile)
+	c.Profile = name
+	return c.Validate()
+}
+
+// ResolvePath 把相对路径解析为基于项目根目录（没有项目时为当前目录）的绝对路径
+func (c *Config) ResolvePath(path string) (string, error) {
+	if !filepath.IsAbs(path) && c.ProjectDir != "" {
+		path = filepath.Join(c.ProjectDir, path)
+	}
+	return filepath.Abs(path)
+}
+
+// ApplyEnv 用环境变量覆盖配置
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	if v := getenv(EnvProvider); v != "" {
+		c.Provider = v
+	}
+	if v := getenv(EnvModel); v != "" {
+		c.Model = v
+	}
+	if v := getenv(EnvMaxTokens); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvMaxTokens, err)
+		}
+		c.MaxTokens = n
+	}
+	if v := getenv(EnvTheme); v != "" {
+		c.Theme = v
+	}
+	if v := getenv(EnvLanguage); v != "" {
+		c.Language = v
+	}
+	return c.Validate()
+}
+
+// Validate 检查配置取值是否合法
+func (c *Config) Validate() error {
+	switch c.Provider {
+	case "", "anthropic", "openai":
+	default:
+		return fmt.Errorf("unknown provider %q (use anthropic or openai)", c.Provider)
+	}
+	switch c.Color {
+	case "", "auto", "always", "never":
+	default:
+		return fmt.Errorf("invalid color mode %q (use auto, always or never)", c.Color)
+	}
+	switch c.Language {
+	case "", "auto", "en", "zh":
+	default:
+		return fmt.Errorf("unknown language %q (use auto, en or zh)", c.Language)
+	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	switch c.Shell.Backend {
+	case "", "host", "docker", "podman":
+	default:
+		return fmt.Errorf("invalid shell backend %q (use host, docker or podman)", c.Shell.Backend)
+	}
+	switch c.Shell.Program {
+	case "", "sh", "bash", "cmd", "powershell", "pwsh":
+	default:
+		return fmt.Errorf("invalid shell program %q (use sh, bash, cmd, powershell or pwsh)", c.Shell.Program)
+	}
+	if (c.Shell.Backend == "docker" || c.Shell.Backend == "podman") && c.Shell.Image == "" {
+		return fmt.Errorf("shell backend %s needs shell.image", c.Shell.Backend)
+	}
+	switch c.Docker.Runtime {
+	case "", "docker", "podman":
+	default:
+		return fmt.Errorf("invalid docker runtime %q (use docker or podman)", c.Docker.Runtime)
+	}
+	for _, bucket := range c.Storage.Buckets {
+		if !strings.HasPrefix(bucket, "s3://") && !strings.HasPrefix(bucket, "gs://") {
+			return fmt.Errorf("storage bucket %q must start with s3:// or gs://", bucket)
+		}
+	}
+	if c.Watch.Cooldown < 0 || c.Watch.Interval < 0 {
+		return fmt.Errorf("watch cooldown and interval must not be negative")
+	}
+	if c.Team.Rounds < 0 {
+		return fmt.Errorf("team rounds must not be negative")
+	}
+	if c.Team.Implementer.Disabled {
+		return fmt.Errorf("the team implementer cannot be disabled")
+	}
+	if c.Notify.After < 0 {
+		return fmt.Errorf("notify after must not be negative")
+	}
+	for _, name := range c.Replies.PostProcess {
+		if !slices.Contains(PostProcessors, name) {
+			return fmt.Errorf("unknown reply post-processor %q, want one of %s", name, strings.Join(PostProcessors, ", "))
+		}
+	}
+	if c.Replies.Language != "" && c.Replies.Language != "zh" && c.Replies.Language != "en" {
+		return fmt.Errorf("replies language must be zh or en, got %q", c.Replies.Language)
+	}
+	if c.Sessions.Backend != "" && !slices.Contains(SessionBackends, c.Sessions.Backend) {
+		return fmt.Errorf("unknown sessions backend %q, want one of %s", c.Sessions.Backend, strings.Join(SessionBackends, ", "))
+	}
+	if c.Knowledge.MaxBytes < 0 {
+		return fmt.Errorf("knowledge max_bytes must not be negative")
+	}
+	if err := c.Server.validate(); err != nil {
+		return err
+	}
+	for _, model := range c.Models {
+		if model.Name == "" {
+			return fmt.Errorf("model without a name")
+		}
+		if model.ContextWindow < 0 || model.InputPrice < 0 || model.OutputPrice < 0 {
+			return fmt.Errorf("model %q: context_window and prices must not be negative", model.Name)
+		}
+	}
+	if err := c.System.validate(); err != nil {
+		return err
+	}
+	if c.TimeFormat != "" && c.TimeFormat != "iso" && c.TimeFormat != "rfc3339" && (time.Time{}).Format(c.TimeFormat) == c.TimeFormat {
+		return fmt.Errorf("time_format %q has no date or time fields; use a Go layout such as \"Jan 2 15:04\", iso or rfc3339", c.TimeFormat)
+	}
+	for name, command := range map[string][]string{"record": c.Voice.Record, "play": c.Voice.Play} {
+		if len(command) > 0 && !slices.ContainsFunc(command, func(arg string) bool { return strings.Contains(arg, "{file}") }) {
+			return fmt.Errorf("voice.%s must pass the audio file as {file}", name)
+		}
+	}
+	if c.Embeddings.Provider != "" && !slices.Contains(EmbeddingProviders, c.Embeddings.Provider) {
+		return fmt.Errorf("unknown embeddings provider %q, want one of %s", c.Embeddings.Provider, strings.Join(EmbeddingProviders, ", "))
+	}
+	for _, check := range c.Verify.Checks {
+		if len(check.Command) == 0 {
+			return fmt.Errorf("verify check for %q has no command", check.Files)
+		}
+		if _, err := filepath.Match(check.Files, ""); err != nil {
+			return fmt.Errorf("invalid verify files pattern %q: %w", check.Files, err)
+		}
+	}
+	if c.Remote.Port < 0 || c.Remote.Port > 65535 {
+		return fmt.Errorf("invalid remote port %d", c.Remote.Port)
+	}
+	if c.Remote.Host != "" {
+		if c.Shell.Backend != "" && c.Shell.Backend != "host" {
+			return fmt.Errorf("shell backend %s cannot be used with a remote workspace", c.Shell.Backend)
+		}
+		if len(c.Sandbox.Paths) > 0 {
+			return fmt.Errorf("sandbox.paths cannot be used with a remote workspace")
+		}
+	}
+	limits := c.Limits
+	if limits.MaxReadBytes < 0 || limits.MaxWriteBytes < 0 || limits.MaxFilesPerTurn < 0 || limits.MaxWritesPerTurn < 0 || limits.MaxCommandsPerMinute < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	names := map[string]bool{}
+	for _, tool := range c.Tools.Custom {
+		if !customToolName.MatchString(tool.Name) {
+			return fmt.Errorf("custom tool name %q must be 1-64 letters, digits, _ or -", tool.Name)
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("custom tool %s is defined twice", tool.Name)
+		}
+		names[tool.Name] = true
+		if len(tool.Command) == 0 || tool.Command[0] == "" {
+			return fmt.Errorf("custom tool %s needs a command", tool.Name)
+		}
+		if t, ok := tool.Schema["type"]; ok && t != "object" {
+			return fmt.Errorf("custom tool %s: schema type must be object", tool.Name)
+		}
+		if tool.Timeout < 0 {
+			return fmt.Errorf("custom tool %s: timeout must not be negative", tool.Name)
+		}
+	}
+	for name, profile := range c.Profiles {
+		if profile == nil {
+			return fmt.Errorf("profile %s is empty", name)
+		}
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// IsToolDisabled 报告工具是否被禁用，或不在非空的允许列表中
+func (c *Config) IsToolDisabled(name string) bool {
+	if len(c.Tools.Allowed) > 0 && !contains(c.Tools.Allowed, name) {
+		return true
+	}
+	return contains(c.Tools.Disabled, name)
+}
+
+// customToolName 是 provider 接受的工具名
+var customToolName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// withoutCustomTool 返回去掉名为 name 的工具后的副本
+func withoutCustomTool(list []CustomTool, name string) []CustomTool {
+	out := []CustomTool{}
+	for _, tool := range list {
+		if tool.Name != name {
+			out = append(out, tool)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s System) validate() error {
+	names := append([]string{}, SystemSections...)
+	seen := map[string]bool{}
+	for _, section := range s.Sections {
+		if section.Name == "" {
+			return fmt.Errorf("system section without a name")
+		}
+		if seen[section.Name] {
+			return fmt.Errorf("system section %s is defined twice", section.Name)
+		}
+		seen[section.Name] = true
+		if (section.Text == "") == (section.File == "") {
+			return fmt.Errorf("system section %s needs either text or file", section.Name)
+		}
+		if !contains(names, section.Name) {
+			names = append(names, section.Name)
+		}
+	}
+	for _, name := range append(append([]string{}, s.Order...), s.Disabled...) {
+		if !contains(names, name) {
+			return fmt.Errorf("unknown system section %q (use one of %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
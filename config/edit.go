@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Setting 是展开后的一项配置，Key 为点分隔的路径，例如 tools.disabled
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// List 把配置展开为按键排序的设置列表，列表和对象的值以 JSON 表示
+func List(cfg *Config) ([]Setting, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+
+	settings := []Setting{}
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+			for key, child := range m {
+				walk(prefix+"."+key, child)
+			}
+			return
+		}
+		settings = append(settings, Setting{Key: strings.TrimPrefix(prefix, "."), Value: formatValue(value)})
+	}
+	walk("", tree)
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings, nil
+}
+
+// Get 返回一项配置的值；键对应一组配置时以 JSON 对象返回
+func Get(cfg *Config, key string) (string, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return "", err
+	}
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("unknown config key %q", key)
+		}
+		if value, ok = m[part]; !ok {
+			if !isKnownKey(key) {
+				return "", fmt.Errorf("unknown config key %q", key)
+			}
+			return "", nil
+		}
+	}
+	return formatValue(value), nil
+}
+
+// Set 把配置文件 path 中的 key 设为 value 并写回。value 按 YAML 解析，
+// 例如 "[read_file, grep]" 表示列表。写入前会校验整个文件，文件中的注释会保留。
+func Set(path, key, value string) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	var parsed yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if len(parsed.Content) > 0 {
+		valueNode = parsed.Content[0]
+	}
+	if err := setNode(doc.Content[0], strings.Split(key, "."), valueNode); err != nil {
+		return fmt.Errorf("cannot set %s: %w", key, err)
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err := validateStrict(out.Bytes()); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// setNode 在映射节点中按路径设置值，缺少的中间层级会被创建
+func setNode(mapping *yaml.Node, path []string, value *yaml.Node) error {
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a mapping", path[0])
+	}
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			mapping.Content[i+1] = value
+			return nil
+		}
+		return setNode(mapping.Content[i+1], path[1:], value)
+	}
+
+	child := value
+	if len(path) > 1 {
+		child = &yaml.Node{Kind: yaml.MappingNode}
+		if err := setNode(child, path[1:], value); err != nil {
+			return err
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}, child)
+	return nil
+}
+
+// validateStrict 拒绝未知字段和非法取值
+func validateStrict(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	cfg := &Config{}
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg.Validate()
+}
+
+// isKnownKey 报告 key 是否是 Config 中的字段（可能尚未设置）
+func isKnownKey(key string) bool {
+	data, err := yaml.Marshal(nestedKey(key))
+	if err != nil {
+		return false
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(&Config{}) == nil
+}
+
+// nestedKey 把点分隔的键转换为嵌套映射，叶子为空值
+func nestedKey(key string) map[string]interface{} {
+	parts := strings.Split(key, ".")
+	var leaf interface{}
+	for i := len(parts) - 1; i > 0; i-- {
+		leaf = map[string]interface{}{parts[i]: leaf}
+	}
+	return map[string]interface{}{parts[0]: leaf}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAndGet(t *testing.T) {
+	cfg := Default()
+	cfg.Model = "gpt-4o"
+	cfg.Tools.Disabled = []string{"edit_file"}
+
+	settings, err := List(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, settings, Setting{Key: "model", Value: "gpt-4o"})
+	assert.Contains(t, settings, Setting{Key: "max_tokens", Value: "1024"})
+	assert.Contains(t, settings, Setting{Key: "tools.disabled", Value: `["edit_file"]`})
+
+	value, err := Get(cfg, "tools.disabled")
+	require.NoError(t, err)
+	assert.Equal(t, `["edit_file"]`, value)
+
+	value, err = Get(cfg, "base_url")
+	require.NoError(t, err)
+	assert.Empty(t, value, "已知但未设置的键")
+
+	_, err = Get(cfg, "modle")
+	assert.Error(t, err)
+}
+
+func TestSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent", "config.yaml")
+
+	t.Run("创建文件并设置嵌套键", func(t *testing.T) {
+		require.NoError(t, Set(path, "model", "gpt-4o-mini"))
+		require.NoError(t, Set(path, "tools.disabled", "[edit_file]"))
+		require.NoError(t, Set(path, "max_tokens", "4096"))
+
+		cfg, err := Load(path)
+		require.NoError(t, err)
+		assert.Equal(t, "gpt-4o-mini", cfg.Model)
+		assert.Equal(t, []string{"edit_file"}, cfg.Tools.Disabled)
+		assert.Equal(t, int64(4096), cfg.MaxTokens)
+	})
+
+	t.Run("保留注释并覆盖已有值", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("# my settings\nmodel: gpt-4o # fast\n"), 0600))
+		require.NoError(t, Set(path, "model", "gpt-4.1"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "# my settings")
+		assert.Contains(t, string(data), "model: gpt-4.1")
+	})
+
+	t.Run("拒绝非法的键和值", func(t *testing.T) {
+		before, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		assert.Error(t, Set(path, "modle", "x"))
+		assert.Error(t, Set(path, "provider", "gemini"))
+		assert.Error(t, Set(path, "max_tokens", "many"))
+		assert.Error(t, Set(path, "model.name", "x"))
+
+		after, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(before), string(after), "校验失败时不修改文件")
+	})
+}
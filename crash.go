@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/i18n"
+	agentlib "agent/pkg/agent"
+	"agent/redact"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// maxCrashEvents is how many of the latest events a diagnostic bundle keeps
+const maxCrashEvents = 50
+
+// crashReporter keeps the latest events of the session and, when a turn
+// fails unexpectedly, writes them with the error and a copy of the config
+// to a diagnostic bundle in dir, secrets masked, for bug reports
+type crashReporter struct {
+	dir string
+
+	mu     sync.Mutex
+	events []AgentEvent
+}
+
+// crashDir is where diagnostic bundles are written
+func crashDir() (string, error) {
+	dir, err := agentConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "crashes"), nil
+}
+
+// defaultCrashReporter writes bundles to crashDir
+func defaultCrashReporter() (*crashReporter, error) {
+	dir, err := crashDir()
+	if err != nil {
+		return nil, err
+	}
+	return &crashReporter{dir: dir}, nil
+}
+
+// record remembers an event, cut to the size logged at debug level; the
+// chunks of streamed tool output are left out, the result follows them
+func (r *crashReporter) record(e AgentEvent) {
+	if r == nil || e.Type == EventToolOutput {
+		return
+	}
+	e.Content = truncate(e.Content, maxLoggedPayload)
+	e.Diff = truncate(e.Diff, maxLoggedPayload)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	if len(r.events) > maxCrashEvents {
+		r.events = r.events[len(r.events)-maxCrashEvents:]
+	}
+}
+
+// crashBundle is the diagnostic bundle written for an unexpected failure
+type crashBundle struct {
+	Time      time.Time `json:"time"`
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	Platform  string    `json:"platform"`
+	Args      []string  `json:"args"`
+	Session   string    `json:"session,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Error     string    `json:"error"`
+	// Stack is set when the failure was a panic
+	Stack  string       `json:"stack,omitempty"`
+	Events []AgentEvent `json:"events"`
+	// Config is the effective config as YAML
+	Config string `json:"config,omitempty"`
+}
+
+// panicError is a panic during a turn, recovered so that the session is
+// saved and the failure reported instead of the process crashing
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// recoverPanic turns a panic into an error carrying the stack; it must be
+// deferred directly
+func recoverPanic(err *error) {
+	if p := recover(); p != nil {
+		*err = &panicError{value: p, stack: debug.Stack()}
+	}
+}
+
+// unexpectedFailure reports whether a turn failed in a way worth a bug
+// report, rather than being cancelled or stopped by a limit
+func unexpectedFailure(err error) bool {
+	for _, expected := range []error{context.Canceled, ErrBudgetExhausted, ErrContextFull, ErrQuotaExceeded, agentlib.ErrMaxSteps} {
+		if errors.Is(err, expected) {
+			return false
+		}
+	}
+	return err != nil
+}
+
+// reportCrash writes a diagnostic bundle for err and tells the user where
+func (a *Agent) reportCrash(err error) {
+	if a.crashes == nil {
+		return
+	}
+	path, writeErr := a.captureCrash(err)
+	if writeErr != nil {
+		a.log().Warn("writing the diagnostic bundle failed", "error", writeErr)
+		return
+	}
+	a.log().Info("diagnostic bundle written", "path", path, "error", err)
+	a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("Diagnostics saved to %s; run `agent bug-report` to turn them into an issue report", path)})
+}
+
+// captureCrash writes the bundle for err and returns its path
+func (a *Agent) captureCrash(err error) (string, error) {
+	bundle := crashBundle{
+		Time:      time.Now().UTC(),
+		Version:   buildVersion(),
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Args:      os.Args[1:],
+		Model:     a.requestModel(),
+		Error:     err.Error(),
+	}
+	var p *panicError
+	if errors.As(err, &p) {
+		bundle.Stack = string(p.stack)
+	}
+	if a.session != nil {
+		bundle.Session = a.session.ID
+	}
+	if a.config != nil {
+		data, err := yaml.Marshal(a.config)
+		if err != nil {
+			return "", err
+		}
+		bundle.Config = string(data)
+	}
+	a.crashes.mu.Lock()
+	bundle.Events = append([]AgentEvent{}, a.crashes.events...)
+	a.crashes.mu.Unlock()
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(a.crashes.dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(a.crashes.dir, "crash-"+bundle.Time.Format("20060102-150405.000")+".json")
+	return path, os.WriteFile(path, []byte(redact.String(string(data))), 0600)
+}
+
+// buildVersion is the module version the binary was built from, if known
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// latestCrash returns the path of the newest bundle in dir
+func latestCrash(dir string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no diagnostic bundles in %s", dir)
+	}
+	// the names sort by time
+	sort.Strings(paths)
+	return paths[len(paths)-1], nil
+}
+
+// writeBugReport formats a bundle as the Markdown body of an issue
+func writeBugReport(w io.Writer, bundle crashBundle) {
+	fmt.Fprintf(w, "## What happened\n\n<!-- What were you doing when it failed? -->\n\n")
+	fmt.Fprintf(w, "## Error\n\n```\n%s\n```\n\n", bundle.Error)
+	fmt.Fprintf(w, "## Environment\n\n")
+	fmt.Fprintf(w, "- Version: %s\n- Go: %s\n- Platform: %s\n", bundle.Version, bundle.GoVersion, bundle.Platform)
+	if bundle.Model != "" {
+		fmt.Fprintf(w, "- Model: %s\n", bundle.Model)
+	}
+	fmt.Fprintf(w, "- Command: agent %s\n- Time: %s\n", strings.Join(bundle.Args, " "), bundle.Time.Format(time.RFC3339))
+	if bundle.Stack != "" {
+		fmt.Fprintf(w, "\n<details><summary>Stack trace</summary>\n\n```\n%s\n```\n\n</details>\n", strings.TrimRight(bundle.Stack, "\n"))
+	}
+	if len(bundle.Events) > 0 {
+		fmt.Fprintf(w, "\n<details><summary>Last %d events</summary>\n\n```\n", len(bundle.Events))
+		for _, e := range bundle.Events {
+			line := e.Content
+			switch {
+			case e.Type == EventFileEdit:
+				line = e.Path
+			case e.ToolCall != nil:
+				line = e.ToolCall.Name + ": " + line
+			case e.Usage != nil:
+				line = fmt.Sprintf("%s: %d in, %d out", e.Model, e.Usage.InputTokens, e.Usage.OutputTokens)
+			}
+			fmt.Fprintf(w, "%s %-14s %s\n", e.Time.Format("15:04:05"), e.Type, truncate(firstLine(line), 120))
+		}
+		fmt.Fprintf(w, "```\n\n</details>\n")
+	}
+	if bundle.Config != "" {
+		fmt.Fprintf(w, "\n<details><summary>Config (secrets masked)</summary>\n\n```yaml\n%s\n```\n\n</details>\n", strings.TrimRight(bundle.Config, "\n"))
+	}
+}
+
+func newBugReportCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "bug-report [BUNDLE]",
+		Short: "Turn a diagnostic bundle into the body of an issue",
+		Long: `When a turn fails unexpectedly, or the agent panics, a diagnostic bundle
+with the error, the stack trace of a panic, the last events of the session and
+the config, secrets masked, is saved to the crashes directory of the agent
+config directory. bug-report prints the newest bundle, or the one given, as
+Markdown to paste into an issue; check it for anything private first.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var path string
+			if len(args) == 1 {
+				path = args[0]
+			} else {
+				dir, err := crashDir()
+				if err != nil {
+					return err
+				}
+				if path, err = latestCrash(dir); err != nil {
+					return err
+				}
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			var bundle crashBundle
+			if err := json.Unmarshal(data, &bundle); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			writeBugReport(cmd.OutOrStdout(), bundle)
+			return nil
+		},
+	}
+}
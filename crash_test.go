@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/config"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrashReport(t *testing.T) {
+	newAgent := func(t *testing.T, provider AIProvider) *Agent {
+		agent := NewAgent(provider, nil, nil)
+		agent.crashes = &crashReporter{dir: t.TempDir()}
+		agent.config = &config.Config{Model: "gpt-4o", Prompts: map[string]string{"deploy": "use OPENAI_API_KEY=sk-abcdefghijklmnopqrstuvwxyz123456"}}
+		agent.onEvent = func(AgentEvent) {}
+		return agent
+	}
+	readBundle := func(t *testing.T, agent *Agent) crashBundle {
+		path, err := latestCrash(agent.crashes.dir)
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var bundle crashBundle
+		require.NoError(t, json.Unmarshal(data, &bundle))
+		return bundle
+	}
+
+	t.Run("panic 被恢复并写入诊断包", func(t *testing.T) {
+		agent := newAgent(t, ProviderFunc(func(context.Context, []Message, []tools.ToolDefinition) (*Response, error) {
+			var m map[string]int
+			m["boom"]++
+			return nil, nil
+		}))
+		err := agent.runTurn(context.Background(), "你好")
+		var p *panicError
+		require.ErrorAs(t, err, &p)
+
+		bundle := readBundle(t, agent)
+		assert.Contains(t, bundle.Error, "assignment to entry in nil map")
+		assert.Contains(t, bundle.Stack, "crash_test.go", "包含 panic 时的调用栈")
+		assert.Equal(t, "gpt-4o", bundle.Model)
+		assert.Contains(t, bundle.Config, "deploy")
+		assert.NotContains(t, bundle.Config, "sk-abcdefghij", "配置中的密钥被遮盖")
+	})
+
+	t.Run("provider 出错时写入诊断包和之前的事件", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "第一轮"}}}
+		agent := newAgent(t, provider)
+		require.NoError(t, agent.runTurn(context.Background(), "你好"))
+		agent.provider = ProviderFunc(func(context.Context, []Message, []tools.ToolDefinition) (*Response, error) {
+			return nil, errors.New("unexpected EOF")
+		})
+		require.Error(t, agent.runTurn(context.Background(), "再来"))
+
+		bundle := readBundle(t, agent)
+		assert.Equal(t, "unexpected EOF", bundle.Error)
+		assert.Empty(t, bundle.Stack)
+		var types []string
+		for _, e := range bundle.Events {
+			types = append(types, e.Type)
+		}
+		assert.Contains(t, types, EventAssistantText)
+	})
+
+	t.Run("取消和预算用尽不算意外", func(t *testing.T) {
+		for _, err := range []error{context.Canceled, ErrBudgetExhausted, fmt.Errorf("step: %w", ErrContextFull)} {
+			agent := newAgent(t, ProviderFunc(func(context.Context, []Message, []tools.ToolDefinition) (*Response, error) {
+				return nil, err
+			}))
+			require.Error(t, agent.runTurn(context.Background(), "你好"))
+			_, latestErr := latestCrash(agent.crashes.dir)
+			assert.Error(t, latestErr, err.Error())
+		}
+	})
+
+	t.Run("没有配置时不写诊断包", func(t *testing.T) {
+		agent := NewAgent(ProviderFunc(func(context.Context, []Message, []tools.ToolDefinition) (*Response, error) {
+			return nil, errors.New("unexpected EOF")
+		}), nil, nil)
+		agent.onEvent = func(AgentEvent) {}
+		assert.Error(t, agent.runTurn(context.Background(), "你好"))
+	})
+}
+
+func TestCrashReporterKeepsLatestEvents(t *testing.T) {
+	r := &crashReporter{}
+	for i := 0; i < maxCrashEvents+10; i++ {
+		r.record(AgentEvent{Type: EventNotice, Content: fmt.Sprint(i)})
+	}
+	r.record(AgentEvent{Type: EventToolOutput, Content: "chunk"})
+	require.Len(t, r.events, maxCrashEvents)
+	assert.Equal(t, "10", r.events[0].Content)
+	assert.Equal(t, fmt.Sprint(maxCrashEvents+9), r.events[maxCrashEvents-1].Content, "不记录流式输出")
+}
+
+func TestBugReport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	crashes, err := crashDir()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(crashes, 0700))
+	write := func(name string, bundle crashBundle) {
+		data, err := json.Marshal(bundle)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(crashes, name), data, 0600))
+	}
+	write("crash-20260101-100000.000.json", crashBundle{Error: "old"})
+	write("crash-20260102-100000.000.json", crashBundle{
+		Error:   "panic: boom",
+		Version: "v1.2.3",
+		Model:   "gpt-4o",
+		Args:    []string{"chat"},
+		Stack:   "goroutine 1 [running]:\nmain.main()",
+		Events:  []AgentEvent{{Type: EventToolCall, ToolCall: &ToolCall{Name: "read_file"}}},
+		Config:  "model: gpt-4o\n",
+	})
+
+	var out bytes.Buffer
+	cmd := newBugReportCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs(nil)
+	require.NoError(t, cmd.Execute())
+	report := out.String()
+	assert.Contains(t, report, "```\npanic: boom\n```", "使用最新的诊断包")
+	assert.Contains(t, report, "- Version: v1.2.3")
+	assert.Contains(t, report, "- Command: agent chat")
+	assert.Contains(t, report, "main.main()")
+	assert.Contains(t, report, "tool_call      read_file: ")
+	assert.Contains(t, report, "```yaml\nmodel: gpt-4o\n```")
+
+	out.Reset()
+	cmd.SetArgs([]string{filepath.Join(crashes, "crash-20260101-100000.000.json")})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "```\nold\n```")
+	assert.NotContains(t, out.String(), "Stack trace")
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent/redact"
+	"agent/tools"
+)
+
+// maxLoggedPayload bounds how much of a provider payload is written at debug level
+const maxLoggedPayload = 2000
+
+// Subsystems that log diagnostics. Each logger carries its subsystem in the
+// "subsystem" attribute, and --log-level can set a level per subsystem.
+const (
+	logSubsystemKey   = "subsystem"
+	subsystemAgent    = "agent"
+	subsystemProvider = "provider"
+	subsystemTools    = "tools"
+)
+
+// levelOff is above every level, so nothing is logged
+const levelOff = slog.Level(100)
+
+// logOptions configures the diagnostics logger
+type logOptions struct {
+	// level applies to subsystems without a level of their own
+	level slog.Level
+	// levels are the levels of individual subsystems
+	levels map[string]slog.Level
+	// json writes JSON lines instead of text
+	json bool
+}
+
+// parseLogLevel parses "LEVEL[,SUBSYSTEM=LEVEL...]", e.g. "warn,provider=debug",
+// where LEVEL is debug, info, warn, error or off. The level of subsystems not
+// named defaults to level.
+func parseLogLevel(spec string, level slog.Level) (logOptions, error) {
+	opts := logOptions{level: level, levels: map[string]slog.Level{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		subsystem, name, named := strings.Cut(part, "=")
+		if !named {
+			name = part
+		}
+		var parsed slog.Level
+		if strings.EqualFold(name, "off") {
+			parsed = levelOff
+		} else if err := parsed.UnmarshalText([]byte(name)); err != nil {
+			return logOptions{}, fmt.Errorf("invalid log level %q: use debug, info, warn, error or off", name)
+		}
+		switch subsystem {
+		case subsystemAgent, subsystemProvider, subsystemTools:
+			opts.levels[subsystem] = parsed
+		default:
+			if named {
+				return logOptions{}, fmt.Errorf("unknown log subsystem %q: use %s, %s or %s", subsystem, subsystemAgent, subsystemProvider, subsystemTools)
+			}
+			opts.level = parsed
+		}
+	}
+	return opts, nil
+}
+
+// newLogger returns a logger writing to w at the levels of opts, as text or
+// JSON lines. Secrets are masked in everything logged.
+func newLogger(w io.Writer, opts logOptions) *slog.Logger {
+	lowest := opts.level
+	for _, level := range opts.levels {
+		lowest = min(lowest, level)
+	}
+	var handler slog.Handler
+	if opts.json {
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lowest})
+	} else {
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{Level: lowest})
+	}
+	return slog.New(redactHandler{levelHandler{Handler: handler, levels: opts.levels, level: opts.level}})
+}
+
+// levelHandler drops records below the level of the logger's subsystem,
+// which it learns from the subsystem attribute
+type levelHandler struct {
+	slog.Handler
+	levels map[string]slog.Level
+	level  slog.Level
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.Handler.Enabled(ctx, level)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	level := h.level
+	for _, attr := range attrs {
+		if attr.Key != logSubsystemKey {
+			continue
+		}
+		if subsystemLevel, ok := h.levels[attr.Value.String()]; ok {
+			level = subsystemLevel
+		}
+	}
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), levels: h.levels, level: level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), levels: h.levels, level: h.level}
+}
+
+// redactHandler masks API keys and other secrets in log messages and
+// attributes, such as tool input and provider payloads
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, redact.String(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return redactHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr masks secrets in string values, including errors and other
+// values that are logged as text
+func redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redact.String(value.String()))
+	case slog.KindAny:
+		return slog.String(attr.Key, redact.String(fmt.Sprint(value.Any())))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, child := range group {
+			redacted[i] = redactAttr(child)
+		}
+		return slog.Group(attr.Key, redacted...)
+	}
+	return attr
+}
+
+// logFor returns the agent's logger for a subsystem, discarding output when
+// none is configured
+func (a *Agent) logFor(subsystem string) *slog.Logger {
+	if a.logger == nil {
+		a.logger = newLogger(io.Discard, logOptions{level: levelOff})
+	}
+	return a.logger.With(logSubsystemKey, subsystem)
+}
+
+// log returns the agent subsystem's logger
+func (a *Agent) log() *slog.Logger {
+	return a.logFor(subsystemAgent)
+}
+
+// LoggingMiddleware logs each inference call with its timing, and at debug
+// level the truncated conversation sent and response received
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next AIProvider) AIProvider {
+		return ProviderFunc(func(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
+			logger.Debug("inference request", "messages", len(conversation), "tools", len(tools), "payload", truncatedJSON(conversation))
+
+			start := time.Now()
+			response, err := next.RunInference(ctx, conversation, tools)
+			elapsed := time.Since(start).Round(time.Millisecond)
+			if err != nil {
+				logger.Info("inference failed", "duration", elapsed, "error", err)
+				return nil, err
+			}
+
+			logger.Info("inference", "model", response.Model, "duration", elapsed,
+				"input_tokens", response.Usage.InputTokens, "output_tokens", response.Usage.OutputTokens,
+				"tool_calls", len(response.ToolCalls))
+			logger.Debug("inference response", "payload", truncatedJSON(response))
+			return response, nil
+		})
+	}
+}
+
+// logHTTPAttempt is an SDK middleware that logs every HTTP attempt, making
+// the SDKs' automatic retries visible
+func logHTTPAttempt(logger *slog.Logger, req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if retry := req.Header.Get("X-Stainless-Retry-Count"); retry != "" && retry != "0" {
+		logger.Info("retrying request", "url", req.URL.String(), "attempt", retry)
+	}
+
+	start := time.Now()
+	resp, err := next(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		logger.Debug("http request failed", "method", req.Method, "url", req.URL.String(), "duration", elapsed, "error", err)
+		return resp, err
+	}
+	logger.Debug("http request", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "duration", elapsed)
+	return resp, nil
+}
+
+// truncatedJSON encodes v for logging, cut to maxLoggedPayload characters
+func truncatedJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return truncate(string(data), maxLoggedPayload)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	run := func(level slog.Level) string {
+		var out bytes.Buffer
+		base := &mockProvider{responses: []*Response{{Content: "ok", Model: "gpt-4o", Usage: Usage{InputTokens: 10, OutputTokens: 5}}}}
+		provider := Chain(base, LoggingMiddleware(newLogger(&out, logOptions{level: level})))
+
+		_, err := provider.RunInference(context.Background(), []Message{{Role: "user", Content: "你好"}}, nil)
+		require.NoError(t, err)
+		return out.String()
+	}
+
+	t.Run("verbose 记录耗时和用量", func(t *testing.T) {
+		out := run(slog.LevelInfo)
+		assert.Contains(t, out, "msg=inference")
+		assert.Contains(t, out, "input_tokens=10")
+		assert.Contains(t, out, "duration=")
+		assert.NotContains(t, out, "payload=")
+	})
+
+	t.Run("debug 额外记录截断的请求内容", func(t *testing.T) {
+		out := run(slog.LevelDebug)
+		assert.Contains(t, out, "inference request")
+		assert.Contains(t, out, "你好")
+	})
+
+	t.Run("warn 级别不输出", func(t *testing.T) {
+		assert.Empty(t, run(slog.LevelWarn))
+	})
+}
+
+func TestParseLogLevel(t *testing.T) {
+	t.Run("整体级别和子系统级别", func(t *testing.T) {
+		opts, err := parseLogLevel("error, provider=debug,tools=off", slog.LevelWarn)
+		require.NoError(t, err)
+		assert.Equal(t, slog.LevelError, opts.level)
+		assert.Equal(t, map[string]slog.Level{subsystemProvider: slog.LevelDebug, subsystemTools: levelOff}, opts.levels)
+	})
+
+	t.Run("没有整体级别时使用默认值", func(t *testing.T) {
+		opts, err := parseLogLevel("agent=INFO", slog.LevelWarn)
+		require.NoError(t, err)
+		assert.Equal(t, slog.LevelWarn, opts.level)
+		assert.Equal(t, slog.LevelInfo, opts.levels[subsystemAgent])
+	})
+
+	t.Run("无效的级别和子系统", func(t *testing.T) {
+		_, err := parseLogLevel("loud", slog.LevelWarn)
+		assert.ErrorContains(t, err, `invalid log level "loud"`)
+		_, err = parseLogLevel("ui=debug", slog.LevelWarn)
+		assert.ErrorContains(t, err, `unknown log subsystem "ui"`)
+	})
+}
+
+func TestSubsystemLevels(t *testing.T) {
+	var out bytes.Buffer
+	logger := newLogger(&out, logOptions{level: slog.LevelWarn, levels: map[string]slog.Level{subsystemProvider: slog.LevelDebug}, json: true})
+
+	logger.With(logSubsystemKey, subsystemTools).Info("tool call")
+	logger.With(logSubsystemKey, subsystemProvider).Debug("inference request", "messages", 2)
+	logger.Warn("audit log disabled")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "inference request", record["msg"])
+	assert.Equal(t, "provider", record["subsystem"])
+	assert.Equal(t, "DEBUG", record["level"])
+	assert.Contains(t, lines[1], `"msg":"audit log disabled"`)
+}
+
+func TestLogHTTPAttempt(t *testing.T) {
+	var out bytes.Buffer
+	logger := newLogger(&out, logOptions{level: slog.LevelInfo})
+	req, err := http.NewRequest(http.MethodPost, "https://api.example.com/v1/messages", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Stainless-Retry-Count", "2")
+
+	_, err = logHTTPAttempt(logger, req, func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "retrying request")
+	assert.Contains(t, out.String(), "attempt=2")
+}
+
+func TestExecuteToolCallsLogsArguments(t *testing.T) {
+	var out bytes.Buffer
+	agent := NewAgent(&mockProvider{}, nil, builtinTools())
+	agent.logger = newLogger(&out, logOptions{level: slog.LevelInfo})
+	agent.onEvent = func(AgentEvent) {}
+
+	err := agent.executeToolCalls(context.Background(), []ToolCall{{ID: "1", Name: "read_file", Input: []byte(`{"path":"go.mod"}`)}})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `input="{\"path\":\"go.mod\"}"`)
+	assert.Contains(t, out.String(), "subsystem=tools")
+	assert.Contains(t, out.String(), "tool call done")
+}
+
+func TestSecretsRedacted(t *testing.T) {
+	const secret = "sk-ant-REDACTED"
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile(".env", []byte("ANTHROPIC_API_KEY="+secret+"\n"), 0600))
+
+	var logs bytes.Buffer
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{{ID: "1", Name: "read_file", Input: json.RawMessage(`{"path":".env"}`)}}},
+		{Content: "The key is set."},
+	}}
+	agent := NewAgent(Chain(provider, LoggingMiddleware(newLogger(&logs, logOptions{level: slog.LevelDebug}))), nil, builtinTools())
+	agent.logger = newLogger(&logs, logOptions{level: slog.LevelDebug})
+	events := []AgentEvent{}
+	agent.onEvent = func(e AgentEvent) { events = append(events, e) }
+	require.NoError(t, agent.runTurn(context.Background(), "is my key set?"))
+
+	t.Run("发送给模型的工具结果", func(t *testing.T) {
+		sent := provider.calls[1][len(provider.calls[1])-1].Content
+		assert.NotContains(t, sent, secret)
+		assert.Contains(t, sent, "ANTHROPIC_API_KEY=[REDACTED:")
+	})
+
+	t.Run("事件和会话", func(t *testing.T) {
+		for _, e := range events {
+			assert.NotContains(t, e.Content, secret)
+		}
+		for _, msg := range agent.conversation {
+			assert.NotContains(t, msg.Content, secret)
+		}
+	})
+
+	t.Run("日志", func(t *testing.T) {
+		assert.Contains(t, logs.String(), "tool result")
+		assert.NotContains(t, logs.String(), secret)
+
+		logs.Reset()
+		agent.log().With("key", secret).Info("failed", "error", fmt.Errorf("bad key %s", secret))
+		assert.NotContains(t, logs.String(), secret)
+	})
+}
@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+
+	"agent/theme"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Unified 计算文件修改前后内容的统一格式 diff，内容相同时返回空字符串
+func Unified(path, before, after string) string {
+	if before == after {
+		return ""
+	}
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "a/" + path,
+		ToFile:   "b/" + path,
+		Context:  3,
+	})
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// Colorize 按当前主题为统一格式 diff 添加终端颜色，关闭颜色时原样返回
+func Colorize(unified string) string {
+	t := theme.Current()
+	lines := strings.SplitAfter(unified, "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		body := strings.TrimRight(line, "\n")
+		newline := line[len(body):]
+		switch {
+		case strings.HasPrefix(body, "+++"), strings.HasPrefix(body, "---"):
+			b.WriteString(theme.Paint(t.DiffHeader, body))
+		case strings.HasPrefix(body, "@@"):
+			b.WriteString(theme.Paint(t.DiffHunk, body))
+		case strings.HasPrefix(body, "+"):
+			b.WriteString(theme.Paint(t.DiffAdd, body))
+		case strings.HasPrefix(body, "-"):
+			b.WriteString(theme.Paint(t.DiffDelete, body))
+		default:
+			b.WriteString(body)
+		}
+		b.WriteString(newline)
+	}
+	return b.String()
+}
+
+// Stat 统计 diff 中新增和删除的行数。hunk 中的行数由 hunk 头给出，
+// 内容以 --- 或 +++ 开头的行不会被当作文件头
+func Stat(unified string) (added, removed int) {
+	oldLeft, newLeft := 0, 0
+	for _, line := range strings.Split(unified, "\n") {
+		switch {
+		case oldLeft > 0 || newLeft > 0:
+			switch {
+			case strings.HasPrefix(line, "+"):
+				added++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				removed++
+				oldLeft--
+			case strings.HasPrefix(line, "\\"):
+				// \ No newline at end of file
+			default:
+				oldLeft--
+				newLeft--
+			}
+		case strings.HasPrefix(line, "@@ "):
+			oldLeft, newLeft = hunkLengths(line)
+		}
+	}
+	return added, removed
+}
+
+// hunkLengths 返回 hunk 头 @@ -a,b +c,d @@ 中修改前后的行数 b 和 d，省略时为 1
+func hunkLengths(header string) (before, after int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0, 0
+	}
+	length := func(field, sign string) int {
+		r, ok := strings.CutPrefix(field, sign)
+		if !ok {
+			return 0
+		}
+		_, n, found := strings.Cut(r, ",")
+		if !found {
+			return 1
+		}
+		count, _ := strconv.Atoi(n)
+		return count
+	}
+	return length(fields[1], "-"), length(fields[2], "+")
+}
+
+// NewLines 返回 diff 中每个文件在新版本里出现在 hunk 中的行号（新增的行和上下文行），
+// 这些行可以在代码托管平台上评论。删除的文件不出现在结果中
+func NewLines(unified string) map[string]map[int]bool {
+	files := map[string]map[int]bool{}
+	var lines map[int]bool
+	next, oldLeft, newLeft, prev := 0, 0, 0, ""
+	for _, line := range strings.Split(unified, "\n") {
+		if oldLeft > 0 || newLeft > 0 {
+			// hunk 中的行，即使以 --- 或 +++ 开头也不是文件头
+			prev = ""
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+				continue
+			case strings.HasPrefix(line, "\\"):
+				continue
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			default:
+				oldLeft--
+				newLeft--
+			}
+			if lines != nil && next > 0 {
+				lines[next] = true
+				next++
+			}
+			continue
+		}
+		header := strings.HasPrefix(prev, "--- ")
+		prev = line
+		switch {
+		case header && strings.HasPrefix(line, "+++ "):
+			lines = nil
+			if path, ok := strings.CutPrefix(line[4:], "b/"); ok {
+				lines = map[int]bool{}
+				files[strings.TrimRight(path, "\t")] = lines
+			}
+		case strings.HasPrefix(line, "@@ "):
+			// @@ -a,b +c,d @@
+			fields := strings.Fields(line)
+			next = 0
+			if len(fields) > 2 {
+				start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+				next, _ = strconv.Atoi(start)
+			}
+			oldLeft, newLeft = hunkLengths(line)
+		}
+	}
+	return files
+}
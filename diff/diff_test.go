@@ -0,0 +1,140 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified(t *testing.T) {
+	t.Run("内容相同", func(t *testing.T) {
+		assert.Equal(t, "", Unified("a.txt", "same\n", "same\n"))
+	})
+
+	t.Run("修改一行", func(t *testing.T) {
+		out := Unified("a.txt", "one\ntwo\nthree\n", "one\n2\nthree\n")
+		assert.Contains(t, out, "--- a/a.txt")
+		assert.Contains(t, out, "+++ b/a.txt")
+		assert.Contains(t, out, "-two\n")
+		assert.Contains(t, out, "+2\n")
+		assert.Contains(t, out, " one\n")
+	})
+
+	t.Run("新建文件", func(t *testing.T) {
+		out := Unified("new.txt", "", "hello\n")
+		assert.Contains(t, out, "+hello")
+	})
+}
+
+func TestColorize(t *testing.T) {
+	out := Colorize("--- a/x\n+++ b/x\n@@ -1 +1 @@\n-old\n+new\n ctx\n")
+	assert.Contains(t, out, "\u001b[31m-old\u001b[0m\n")
+	assert.Contains(t, out, "\u001b[32m+new\u001b[0m\n")
+	assert.Contains(t, out, "\u001b[36m@@ -1 +1 @@\u001b[0m\n")
+	assert.Contains(t, out, " ctx\n")
+	assert.Contains(t, out, "\u001b[1m--- a/x\u001b[0m\n")
+}
+
+func TestStat(t *testing.T) {
+	added, removed := Stat(Unified("x", "a\nb\nc\n", "a\nB\nc\nd\n"))
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 1, removed)
+
+	t.Run("内容以 --- 和 +++ 开头的行", func(t *testing.T) {
+		added, removed := Stat(Unified("x", "--x\nkeep\n", "++y\nkeep\n"))
+		assert.Equal(t, 1, added)
+		assert.Equal(t, 1, removed)
+	})
+}
+
+func TestNewLines(t *testing.T) {
+	unified := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -10,3 +10,4 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := 4
+ 	fmt.Println(a, b)
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package old
+-
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package main
++++ counter
+`
+	lines := NewLines(unified)
+	assert.Equal(t, map[string]map[int]bool{
+		"main.go": {10: true, 11: true, 12: true, 13: true},
+		"new.go":  {1: true, 2: true},
+	}, lines, "新增的行和上下文行可以评论，删除的文件没有")
+}
+
+// FuzzUnified 检查 diff 的统计和可评论的行号与修改前后的内容一致
+func FuzzUnified(f *testing.F) {
+	for _, c := range [][2]string{{"a\nb\nc\n", "a\nB\nc\nd\n"}, {"", "new\n"}, {"old\n", ""}, {"--x\nkeep\n", "++ b/y\nkeep\n"},
+		{"no newline", "no newline\n"}, {"same\n", "same\n"}, {"@@ -1 +1 @@\n", "+++ b/z\n"}} {
+		f.Add(c[0], c[1])
+	}
+
+	f.Fuzz(func(t *testing.T, before, after string) {
+		unified := Unified("f", before, after)
+		if before == after {
+			assert.Empty(t, unified)
+			return
+		}
+		added, removed := Stat(unified)
+		assert.Positive(t, added+removed, unified)
+		assert.LessOrEqual(t, added, strings.Count(after, "\n")+1)
+		assert.LessOrEqual(t, removed, strings.Count(before, "\n")+1)
+		for path, lines := range NewLines(unified) {
+			assert.Equal(t, "f", path, unified)
+			for line := range lines {
+				assert.True(t, line >= 1 && line <= strings.Count(after, "\n")+1, "行号 %d 超出范围\n%s", line, unified)
+			}
+		}
+	})
+}
+
+func TestHunks(t *testing.T) {
+	var before, after []string
+	for i := 1; i <= 20; i++ {
+		before = append(before, fmt.Sprintf("line %d\n", i))
+	}
+	after = append(after, before...)
+	after[1] = "second\n"
+	after[17] = "eighteenth\n"
+	from, to := strings.Join(before, ""), strings.Join(after, "")
+
+	hunks := Hunks(from, to)
+	require.Len(t, hunks, 2, "相距较远的修改分成两个 hunk")
+	assert.Equal(t, "@@ -1,5 +1,5 @@\n line 1\n-line 2\n+second\n line 3\n line 4\n line 5\n", hunks[0].Text)
+	assert.True(t, strings.HasPrefix(hunks[1].Text, "@@ -15,6 +15,6 @@\n"))
+
+	assert.Equal(t, to, Apply(from, to, []bool{true, true}))
+	assert.Equal(t, from, Apply(from, to, []bool{false, false}))
+	partial := Apply(from, to, []bool{false, true})
+	assert.Contains(t, partial, "line 2\n")
+	assert.Contains(t, partial, "eighteenth\n")
+
+	t.Run("新建文件和末尾没有换行", func(t *testing.T) {
+		hunks := Hunks("", "hello")
+		require.Len(t, hunks, 1)
+		assert.Equal(t, "@@ -0,0 +1 @@\n+hello\n\\ No newline at end of file\n", hunks[0].Text)
+		assert.Equal(t, "hello", Apply("", "hello", []bool{true}))
+		assert.Equal(t, "a\nc", Apply("a\nb", "a\nc", []bool{true}))
+	})
+}
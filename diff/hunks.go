@@ -0,0 +1,87 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Hunk 是一处修改及其前后 3 行上下文，Text 是以 @@ 头开始的统一格式文本
+type Hunk struct {
+	Text string
+}
+
+// Hunks 把修改前后的内容拆成 hunk，顺序与 Unified 的输出一致
+func Hunks(before, after string) []Hunk {
+	a, b := splitLines(before), splitLines(after)
+	var hunks []Hunk
+	for _, group := range difflib.NewMatcher(a, b).GetGroupedOpCodes(3) {
+		first, last := group[0], group[len(group)-1]
+		var text strings.Builder
+		fmt.Fprintf(&text, "@@ -%s +%s @@\n", hunkRange(first.I1, last.I2), hunkRange(first.J1, last.J2))
+		for _, op := range group {
+			if op.Tag == 'e' {
+				writeLines(&text, " ", a[op.I1:op.I2])
+				continue
+			}
+			writeLines(&text, "-", a[op.I1:op.I2])
+			writeLines(&text, "+", b[op.J1:op.J2])
+		}
+		hunks = append(hunks, Hunk{Text: text.String()})
+	}
+	return hunks
+}
+
+// Apply 只把 accepted 为 true 的 hunk 应用到 before 上，accepted 与 Hunks 的结果一一对应
+func Apply(before, after string, accepted []bool) string {
+	a, b := splitLines(before), splitLines(after)
+	var out []string
+	i := 0
+	for g, group := range difflib.NewMatcher(a, b).GetGroupedOpCodes(3) {
+		for _, op := range group {
+			if op.Tag == 'e' {
+				continue
+			}
+			out = append(out, a[i:op.I1]...)
+			if g < len(accepted) && accepted[g] {
+				out = append(out, b[op.J1:op.J2]...)
+			} else {
+				out = append(out, a[op.I1:op.I2]...)
+			}
+			i = op.I2
+		}
+	}
+	out = append(out, a[i:]...)
+	return strings.Join(out, "")
+}
+
+// splitLines 把内容拆成保留换行符的行；与 difflib.SplitLines 不同，不会在末尾补换行
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunkRange 按统一格式写出从 0 开始的 [start, end) 行范围
+func hunkRange(start, end int) string {
+	switch n := end - start; n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, n)
+	}
+}
+
+func writeLines(b *strings.Builder, prefix string, lines []string) {
+	for _, line := range lines {
+		b.WriteString(prefix + line)
+		if !strings.HasSuffix(line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
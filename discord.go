@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/spf13/cobra"
+)
+
+// maxDiscordText is the longest message Discord accepts
+const maxDiscordText = 2000
+
+// discordChat is the part of the Discord API the bot uses; *discordgo.Session
+// implements it
+type discordChat interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	MessageThreadStart(channelID, messageID string, name string, archiveDuration int, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+}
+
+// discordBot drives agent sessions from Discord. Mentioning the bot in a
+// channel starts a thread, which is one session; direct messages are one
+// session per user. Only allowed users can talk to the bot.
+type discordBot struct {
+	bot  *chatBot
+	chat discordChat
+	// botUserID is the bot's own user, whose mentions are stripped from messages
+	botUserID string
+	// allowed holds the IDs and names of the users the bot answers
+	allowed map[string]bool
+}
+
+func newDiscordBot(api *apiServer, chat discordChat, botUserID string, allowed []string, logger *slog.Logger) *discordBot {
+	d := &discordBot{chat: chat, botUserID: botUserID, allowed: map[string]bool{}}
+	for _, user := range allowed {
+		d.allowed[user] = true
+	}
+	d.bot = newChatBot(api, d, maxDiscordText, logger)
+	return d
+}
+
+// handleMessage runs a message in the session of its thread or direct
+// message channel
+func (d *discordBot) handleMessage(ctx context.Context, m *discordgo.Message) {
+	if m.Author == nil || m.Author.Bot || !d.isAllowed(m.Author) {
+		return
+	}
+	mentioned := false
+	for _, user := range m.Mentions {
+		mentioned = mentioned || user.ID == d.botUserID
+	}
+	text := strings.NewReplacer("<@"+d.botUserID+">", "", "<@!"+d.botUserID+">", "").Replace(m.Content)
+
+	conversation := m.ChannelID
+	switch {
+	case m.GuildID == "" || d.bot.session(conversation) != "":
+		// direct messages, and threads the bot started
+	case mentioned:
+		thread, err := d.chat.MessageThreadStart(m.ChannelID, m.ID, truncate(strings.TrimSpace(text), 90), 24*60, discordgo.WithContext(ctx))
+		if err != nil {
+			d.bot.logger.Warn("starting a Discord thread failed", "channel", m.ChannelID, "error", err)
+			return
+		}
+		conversation = thread.ID
+	default:
+		return
+	}
+	d.bot.handleMessage(ctx, conversation, text)
+}
+
+func (d *discordBot) say(ctx context.Context, conversation, text string) error {
+	_, err := d.chat.ChannelMessageSendComplex(conversation, &discordgo.MessageSend{Content: text}, discordgo.WithContext(ctx))
+	return err
+}
+
+// askApproval posts a tool call with buttons to approve or deny it
+func (d *discordBot) askApproval(ctx context.Context, conversation, text, value string) error {
+	_, err := d.chat.ChannelMessageSendComplex(conversation, &discordgo.MessageSend{
+		Content: text,
+		Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Approve", Style: discordgo.SuccessButton, CustomID: "approve:" + value},
+			discordgo.Button{Label: "Deny", Style: discordgo.DangerButton, CustomID: "deny:" + value},
+		}}},
+	}, discordgo.WithContext(ctx))
+	return err
+}
+
+// handleInteraction delivers the decision of an approval button and replaces
+// the buttons with who decided
+func (d *discordBot) handleInteraction(ctx context.Context, i *discordgo.Interaction) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	action, value, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+	if action != "approve" && action != "deny" {
+		return
+	}
+
+	if user == nil || !d.isAllowed(user) {
+		d.ephemeral(ctx, i, "⛔ You are not allowed to approve tool calls.")
+		return
+	}
+	if err := d.bot.decide(value, action == "approve"); err != nil {
+		d.ephemeral(ctx, i, "⚠️ "+err.Error())
+		return
+	}
+
+	verdict := "✅ Approved"
+	if action == "deny" {
+		verdict = "⛔ Denied"
+	}
+	err := d.chat.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("%s\n%s by <@%s>", i.Message.Content, verdict, user.ID),
+			Components: []discordgo.MessageComponent{},
+		},
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		d.bot.logger.Warn("updating Discord message failed", "error", err)
+	}
+}
+
+// ephemeral answers an interaction with a message only its user sees
+func (d *discordBot) ephemeral(ctx context.Context, i *discordgo.Interaction, content string) {
+	err := d.chat.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: content, Flags: discordgo.MessageFlagsEphemeral},
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		d.bot.logger.Warn("answering Discord interaction failed", "error", err)
+	}
+}
+
+func (d *discordBot) isAllowed(user *discordgo.User) bool {
+	return d.allowed[user.ID] || d.allowed[user.Username]
+}
+
+func newDiscordCommand(global *globalOptions) *cobra.Command {
+	var allowed []string
+	var requireApproval bool
+	cmd := &cobra.Command{
+		Use:   "discord",
+		Short: "Run a Discord bot that drives sessions from threads and direct messages",
+		Long: `Run a Discord bot sharing the session store of the CLI.
+
+Mention the bot in a channel to start a thread: each thread is one session,
+and messages in it continue the session. Direct messages to the bot are a
+session too. Tools that change files or run commands wait for an allowed
+user to press Approve or Deny.
+
+The bot only answers the users given with --allow-user (IDs or user names),
+since it runs commands on this machine. It needs the Message Content intent
+and a bot token in DISCORD_BOT_TOKEN (or "agent auth login discord").`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(allowed) == 0 {
+				return fmt.Errorf("--allow-user is required: the bot runs commands on this machine")
+			}
+			token, _ := lookupAPIKey("discord", "")
+			if token == "" {
+				return fmt.Errorf("set DISCORD_BOT_TOKEN (or run `agent auth login discord`)")
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			logger := global.logger(os.Stderr, slog.LevelInfo)
+			newAgent, store, err := serverAgents(global, logger, nil)
+			if err != nil {
+				return err
+			}
+			api := newAPIServer(ctx, store, newAgent)
+			api.requireApproval = requireApproval
+			go api.reapIdle(ctx)
+
+			session, err := discordgo.New("Bot " + token)
+			if err != nil {
+				return err
+			}
+			session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentsMessageContent
+			var bot *discordBot
+			session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+				bot.handleMessage(ctx, m.Message)
+			})
+			session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				bot.handleInteraction(ctx, i.Interaction)
+			})
+			user, err := session.User("@me")
+			if err != nil {
+				return fmt.Errorf("discord auth: %w", err)
+			}
+			// the handlers only run once the session is open
+			bot = newDiscordBot(api, session, user.ID, allowed, logger)
+			if err := session.Open(); err != nil {
+				return err
+			}
+			defer session.Close()
+			fmt.Fprintf(cmd.ErrOrStderr(), "Running as %s\n", user.Username)
+			<-ctx.Done()
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&allowed, "allow-user", nil, "Discord user ID or name allowed to use the bot (repeatable)")
+	cmd.Flags().BoolVar(&requireApproval, "require-approval", true, "ask before each tool call that changes state")
+	return cmd
+}
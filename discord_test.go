@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDiscord 记录机器人发送的消息和交互回复
+type fakeDiscord struct {
+	mu        sync.Mutex
+	sent      map[string][]*discordgo.MessageSend
+	responses []*discordgo.InteractionResponse
+}
+
+func (f *fakeDiscord) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent[channelID] = append(f.sent[channelID], data)
+	return &discordgo.Message{ChannelID: channelID, Content: data.Content}, nil
+}
+
+func (f *fakeDiscord) MessageThreadStart(channelID, messageID string, name string, archiveDuration int, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "thread-" + messageID, Name: name}, nil
+}
+
+func (f *fakeDiscord) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+// waitSent 等待频道中以 prefix 开头的消息并返回它
+func (f *fakeDiscord) waitSent(t *testing.T, channel, prefix string) *discordgo.MessageSend {
+	t.Helper()
+	var found *discordgo.MessageSend
+	require.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, msg := range f.sent[channel] {
+			if strings.HasPrefix(msg.Content, prefix) {
+				found = msg
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond, "no Discord message starting with %q in %s", prefix, channel)
+	return found
+}
+
+func newTestDiscordBot(t *testing.T, newAgent func() *Agent) (*discordBot, *fakeDiscord) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store := &FileSessionStore{Dir: t.TempDir()}
+	api := newAPIServer(ctx, store, func() (*Agent, error) {
+		agent := newAgent()
+		agent.store = store
+		return agent, nil
+	})
+	api.requireApproval = true
+	chat := &fakeDiscord{sent: map[string][]*discordgo.MessageSend{}}
+	return newDiscordBot(api, chat, "BOT", []string{"alice"}, slog.New(slog.NewTextHandler(io.Discard, nil))), chat
+}
+
+func TestDiscordBot(t *testing.T) {
+	alice := &discordgo.User{ID: "1", Username: "alice"}
+
+	t.Run("在频道中提及机器人时创建线程", func(t *testing.T) {
+		bot, chat := newTestDiscordBot(t, oneShotAgent)
+		bot.handleMessage(context.Background(), &discordgo.Message{
+			ID: "m1", ChannelID: "general", GuildID: "g", Author: alice,
+			Content: "<@BOT> 模块名是什么？", Mentions: []*discordgo.User{{ID: "BOT"}},
+		})
+		chat.waitSent(t, "thread-m1", "模块名是 agent")
+		assert.NotEmpty(t, bot.bot.session("thread-m1"))
+	})
+
+	t.Run("忽略未提及机器人和不允许的用户的消息", func(t *testing.T) {
+		bot, _ := newTestDiscordBot(t, oneShotAgent)
+		bot.handleMessage(context.Background(), &discordgo.Message{ID: "m1", ChannelID: "general", GuildID: "g", Author: alice, Content: "大家好"})
+		bot.handleMessage(context.Background(), &discordgo.Message{ID: "m2", ChannelID: "dm", Author: &discordgo.User{ID: "2", Username: "mallory"}, Content: "rm -rf ~"})
+		assert.Empty(t, bot.bot.session("thread-m1"))
+		assert.Empty(t, bot.bot.session("dm"))
+	})
+
+	t.Run("私信中用按钮批准工具调用", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		bot, chat := newTestDiscordBot(t, editAgent)
+		bot.handleMessage(context.Background(), &discordgo.Message{ID: "m1", ChannelID: "dm", Author: alice, Content: "创建 hello.txt"})
+
+		msg := chat.waitSent(t, "dm", "✋ Allow `edit_file`?")
+		buttons := msg.Components[0].(discordgo.ActionsRow).Components
+		approve := buttons[0].(discordgo.Button)
+
+		interaction := func(user *discordgo.User) *discordgo.Interaction {
+			return &discordgo.Interaction{
+				Type:    discordgo.InteractionMessageComponent,
+				User:    user,
+				Message: &discordgo.Message{Content: msg.Content},
+				Data:    discordgo.MessageComponentInteractionData{CustomID: approve.CustomID},
+			}
+		}
+		bot.handleInteraction(context.Background(), interaction(&discordgo.User{ID: "2", Username: "mallory"}))
+		bot.handleInteraction(context.Background(), interaction(alice))
+		chat.waitSent(t, "dm", "完成")
+
+		chat.mu.Lock()
+		defer chat.mu.Unlock()
+		require.Len(t, chat.responses, 2)
+		assert.Equal(t, "⛔ You are not allowed to approve tool calls.", chat.responses[0].Data.Content)
+		assert.Equal(t, msg.Content+"\n✅ Approved by <@1>", chat.responses[1].Data.Content)
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent/i18n"
+)
+
+// requestDump keeps the HTTP exchanges with the provider of the last turn
+// for /debug-last, and with dir set (--dump-requests) also writes every
+// request and response body to files as they happen. Bodies are kept
+// exactly as sent and received, without masking secrets, since the point
+// is to see what the schema conversion produced; headers, and so API keys,
+// are left out.
+type requestDump struct {
+	dir string
+	// session returns the ID of the session the requests belong to
+	session func() string
+	logger  *slog.Logger
+
+	mu        sync.Mutex
+	exchanges []httpExchange
+	// seq numbers the files written to dir
+	seq int
+}
+
+// reset forgets the exchanges of the previous turn
+func (d *requestDump) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.exchanges = nil
+}
+
+func (d *requestDump) recordExchange(exchange httpExchange) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.exchanges = append(d.exchanges, exchange)
+	if d.dir == "" {
+		return
+	}
+	d.seq++
+	if err := d.write(exchange, d.seq); err != nil {
+		d.logger.Warn("dumping requests failed", "error", err)
+	}
+}
+
+// write saves an exchange's bodies as <session>-<seq>-request.json and
+// <session>-<seq>-response.json
+func (d *requestDump) write(exchange httpExchange, seq int) error {
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return err
+	}
+	prefix := "no-session"
+	if d.session != nil && d.session() != "" {
+		prefix = d.session()
+	}
+	prefix = filepath.Join(d.dir, fmt.Sprintf("%s-%03d", prefix, seq))
+	if err := os.WriteFile(prefix+"-request.json", exchange.Request, 0600); err != nil {
+		return err
+	}
+	if exchange.Response == nil {
+		return nil
+	}
+	return os.WriteFile(prefix+"-response.json", exchange.Response, 0600)
+}
+
+// last returns the exchanges of the last turn
+func (d *requestDump) last() []httpExchange {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]httpExchange{}, d.exchanges...)
+}
+
+// dumpedExchange is how /debug-last writes an exchange: bodies that are
+// JSON are embedded as is, anything else as a string
+type dumpedExchange struct {
+	Time       time.Time       `json:"time"`
+	Method     string          `json:"method"`
+	URL        string          `json:"url"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Status     int             `json:"status"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+func rawBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	data, _ := json.Marshal(string(body))
+	return data
+}
+
+// runDebugLastCommand writes the provider requests and responses of the
+// last turn to a file, debug-last.json unless one is given
+func runDebugLastCommand(a *Agent, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /debug-last [file]")
+	}
+	if a.requests == nil {
+		return fmt.Errorf("provider requests are not recorded here")
+	}
+	exchanges := a.requests.last()
+	if len(exchanges) == 0 {
+		fmt.Println(i18n.T("No provider requests in the last turn"))
+		return nil
+	}
+	path := "debug-last.json"
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	dumped := make([]dumpedExchange, len(exchanges))
+	for i, exchange := range exchanges {
+		dumped[i] = dumpedExchange{
+			Time:       exchange.Time,
+			Method:     exchange.Method,
+			URL:        exchange.URL,
+			Request:    rawBody(exchange.Request),
+			Status:     exchange.Status,
+			Response:   rawBody(exchange.Response),
+			DurationMS: exchange.Duration.Milliseconds(),
+		}
+		if exchange.Err != nil {
+			dumped[i].Error = exchange.Err.Error()
+		}
+	}
+	data, err := json.MarshalIndent(dumped, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	fmt.Println(i18n.Sprintf("Wrote %d provider requests of the last turn to %s", len(exchanges), path))
+	return nil
+}
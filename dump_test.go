@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestDump(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "你好"}}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Provider = "openai"
+	cfg.BaseURL = server.URL
+	cfg.DumpRequests = dir
+
+	agent := NewAgent(nil, nil, nil)
+	agent.onEvent = func(AgentEvent) {}
+	_, err := agent.applyConfig(cfg)
+	require.NoError(t, err)
+	require.NoError(t, agent.runTurn(context.Background(), "第一轮"))
+	require.NoError(t, agent.runTurn(context.Background(), "第二轮"))
+
+	t.Run("每次请求的正文原样写入文件", func(t *testing.T) {
+		for _, name := range []string{"001-request.json", "001-response.json", "002-request.json", "002-response.json"} {
+			assert.FileExists(t, filepath.Join(dir, agent.session.ID+"-"+name))
+		}
+		data, err := os.ReadFile(filepath.Join(dir, agent.session.ID+"-002-request.json"))
+		require.NoError(t, err)
+		var request struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.Unmarshal(data, &request))
+		require.NotEmpty(t, request.Messages)
+		assert.Equal(t, "第二轮", request.Messages[len(request.Messages)-1].Content)
+	})
+
+	t.Run("/debug-last 只写最后一轮", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "last.json")
+		require.NoError(t, runDebugLastCommand(agent, []string{path}))
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var dumped []dumpedExchange
+		require.NoError(t, json.Unmarshal(data, &dumped))
+		require.Len(t, dumped, 1)
+		assert.Equal(t, http.StatusOK, dumped[0].Status)
+		assert.Contains(t, string(dumped[0].Request), "第二轮")
+		assert.Contains(t, string(dumped[0].Response), "chatcmpl-1")
+	})
+}
+
+func TestDebugLastWithoutRequests(t *testing.T) {
+	agent := oneShotAgent()
+	assert.Error(t, runDebugLastCommand(agent, nil))
+
+	agent.requests = &requestDump{}
+	output := captureStdout(func() { require.NoError(t, runDebugLastCommand(agent, nil)) })
+	assert.Contains(t, output, "No provider requests")
+}
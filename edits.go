@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"agent/diff"
+	"agent/i18n"
+	"agent/theme"
+)
+
+// readFileOrEmpty returns a file's content, or "" if it cannot be read (e.g. does not exist yet)
+func readFileOrEmpty(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// fileDiff returns the unified diff between before and the current content of path;
+// it is empty when path is empty or the file is unchanged
+func fileDiff(path, before string) string {
+	if path == "" {
+		return ""
+	}
+	return diff.Unified(path, before, readFileOrEmpty(path))
+}
+
+// printFileDiff shows a file edit as a unified diff, colored when highlighting is enabled
+func (a *Agent) printFileDiff(path, unified string) {
+	added, removed := diff.Stat(unified)
+	if a.highlight {
+		unified = diff.Colorize(unified)
+	}
+	fmt.Printf("%s: %s (+%d -%d)\n%s", theme.Success(i18n.T("Edited")), path, added, removed, unified)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+
+	assert.Empty(t, fileDiff("", "x"), "没有路径时不计算diff")
+	assert.Empty(t, fileDiff(path, ""), "文件不存在且内容为空时没有变化")
+
+	require.NoError(t, os.WriteFile(path, []byte("new\n"), 0644))
+	assert.Contains(t, fileDiff(path, "old\n"), "+new")
+	assert.Empty(t, fileDiff(path, "new\n"))
+}
+
+func TestEditToolCallUpdatesFile(t *testing.T) {
+	chdir(t, t.TempDir())
+	path := "main.go"
+	require.NoError(t, os.WriteFile(path, []byte("package main\n"), 0644))
+	input, err := json.Marshal(tools.EditFileInput{Path: path, OldStr: "main", NewStr: "app"})
+	require.NoError(t, err)
+
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: input}}},
+		{Content: "Renamed the package."},
+	}}
+	agent := NewAgent(provider, nil, builtinTools())
+	events := []AgentEvent{}
+	agent.onEvent = func(e AgentEvent) { events = append(events, e) }
+	require.NoError(t, agent.runTurn(context.Background(), "rename the package"))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "package app\n", string(content))
+
+	types := []string{}
+	for _, e := range events {
+		types = append(types, e.Type)
+		if e.Type == EventFileEdit {
+			assert.Equal(t, path, e.Path)
+			assert.Contains(t, e.Diff, "+package app")
+			assert.Equal(t, []tools.FileRef{{Path: path}}, e.Files)
+		}
+	}
+	result := provider.calls[1][len(provider.calls[1])-1]
+	assert.Contains(t, result.Content, "Diff:\n", "模型在结果中看到修改的 diff")
+	assert.Equal(t, []string{
+		EventActivity, EventActivity, EventUsage,
+		EventToolCall, EventActivity, EventActivity, EventFileEdit,
+		EventActivity, EventActivity, EventUsage, EventAssistantText, EventTiming,
+	}, types)
+}
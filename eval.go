@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"agent/config"
+	"agent/evals"
+	"agent/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+// evalDir holds the project's eval suites, relative to the project root;
+// they take precedence over the user's
+var evalDir = filepath.Join(".agent", "evals")
+
+// defaultEvalStore finds eval suites in the project, then in the config directory
+func defaultEvalStore(cfg *config.Config) (*evals.Store, error) {
+	dir, err := agentConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	root := cfg.ProjectDir
+	if root == "" {
+		root = currentWorkspace.Root
+	}
+	return &evals.Store{Dirs: []string{filepath.Join(root, evalDir), filepath.Join(dir, "evals")}}, nil
+}
+
+// evalConfig is one configuration a suite runs with: a profile, a model, or
+// both; empty fields keep what the config selects
+type evalConfig struct {
+	Profile string
+	Model   string
+}
+
+func (c evalConfig) String() string {
+	switch {
+	case c.Profile != "" && c.Model != "":
+		return c.Profile + "+" + c.Model
+	case c.Profile != "":
+		return c.Profile
+	case c.Model != "":
+		return c.Model
+	}
+	return "default"
+}
+
+// evalConfigs returns every combination of the given profiles and models
+func evalConfigs(profiles, models []string) []evalConfig {
+	if len(profiles) == 0 {
+		profiles = []string{""}
+	}
+	if len(models) == 0 {
+		models = []string{""}
+	}
+	configs := []evalConfig{}
+	for _, profile := range profiles {
+		for _, model := range models {
+			configs = append(configs, evalConfig{Profile: profile, Model: model})
+		}
+	}
+	return configs
+}
+
+// evalResult is the outcome of one attempt at a task
+type evalResult struct {
+	Config     string  `json:"config"`
+	Provider   string  `json:"provider,omitempty"`
+	Model      string  `json:"model,omitempty"`
+	Task       string  `json:"task"`
+	Attempt    int     `json:"attempt"`
+	Passed     bool    `json:"passed"`
+	Failure    string  `json:"failure,omitempty"`
+	Usage      Usage   `json:"usage"`
+	CostUSD    float64 `json:"cost_usd"`
+	DurationMS int64   `json:"duration_ms"`
+	ToolCalls  int     `json:"tool_calls"`
+	Workspace  string  `json:"workspace,omitempty"`
+}
+
+// evalRun runs every task of a suite with every configuration, repeat
+// times each, in a throwaway workspace per attempt
+type evalRun struct {
+	suite   *evals.Suite
+	configs []evalConfig
+	repeat  int
+	// newAgent builds the agent for an attempt, in the attempt's workspace
+	newAgent func(c evalConfig) (*Agent, error)
+	// keep leaves the workspaces behind to look at what the agent did
+	keep bool
+	// out gets a line per attempt; log gets what the agent did
+	out, log io.Writer
+}
+
+// run returns the results in the order the attempts ran. The working
+// directory is restored afterwards, since attempts change into their workspace.
+func (r *evalRun) run(ctx context.Context) ([]evalResult, error) {
+	previous, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Chdir(previous)
+
+	results := []evalResult{}
+	for _, c := range r.configs {
+		for _, task := range r.suite.Tasks {
+			for attempt := 1; attempt <= max(r.repeat, 1); attempt++ {
+				if err := ctx.Err(); err != nil {
+					return results, err
+				}
+				result, err := r.attempt(ctx, c, task, attempt)
+				if err != nil {
+					return results, fmt.Errorf("%s with %s: %w", task.Name, c, err)
+				}
+				results = append(results, result)
+				status := "PASS"
+				if !result.Passed {
+					status = "FAIL"
+				}
+				fmt.Fprintf(r.out, "%s  %s [%s #%d] %s\n", status, task.Name, c, attempt, formatMS(result.DurationMS))
+				if result.Failure != "" {
+					fmt.Fprintf(r.out, "      %s\n", strings.ReplaceAll(result.Failure, "\n", "\n      "))
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// attempt runs a task once. Failing to set up the workspace or the agent is
+// an error; the agent failing or a check not passing is a failed result.
+func (r *evalRun) attempt(ctx context.Context, c evalConfig, task evals.Task, attempt int) (evalResult, error) {
+	result := evalResult{Config: c.String(), Model: c.Model, Task: task.Name, Attempt: attempt}
+	workspace, err := os.MkdirTemp("", "agent-eval-*")
+	if err != nil {
+		return result, err
+	}
+	if r.keep {
+		result.Workspace = workspace
+	} else {
+		defer os.RemoveAll(workspace)
+	}
+	if err := task.Setup(r.suite.Dir, workspace); err != nil {
+		return result, err
+	}
+	if _, err := changeWorkspace(workspace); err != nil {
+		return result, err
+	}
+	agent, err := r.newAgent(c)
+	if err != nil {
+		return result, err
+	}
+	if agent.config != nil {
+		result.Provider = providerName(agent.config)
+		if agent.config.Model != "" {
+			result.Model = agent.config.Model
+		}
+	}
+
+	fmt.Fprintf(r.log, "=== %s [%s #%d] in %s\n", task.Name, c, attempt, workspace)
+	taskCtx, cancel := ctx, context.CancelFunc(func() {})
+	if task.Timeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, task.Timeout)
+	}
+	summary, runErr := runSummarized(taskCtx, agent, task.Prompt, &ciLog{out: r.log, plain: true})
+	cancel()
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	result.Usage, result.CostUSD, result.DurationMS, result.ToolCalls = summary.Usage, summary.CostUSD, summary.DurationMS, summary.ToolCalls
+	if summary.Model != "" {
+		result.Model = summary.Model
+	}
+	if runErr != nil {
+		result.Failure = "run failed: " + runErr.Error()
+		return result, nil
+	}
+	for _, check := range task.Checks {
+		if err := check.Verify(ctx, workspace); err != nil {
+			result.Failure = err.Error()
+			return result, nil
+		}
+	}
+	result.Passed = true
+	return result, nil
+}
+
+// writeEvalReport writes the pass rate, tokens, cost and time per configuration
+func writeEvalReport(w io.Writer, configs []evalConfig, results []evalResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONFIG\tPROVIDER\tMODEL\tPASSED\tRATE\tTOKENS\tCOST\tTIME")
+	for _, c := range configs {
+		var passed, attempts int
+		var tokens, ms int64
+		var cost float64
+		provider, model := "", ""
+		for _, result := range results {
+			if result.Config != c.String() {
+				continue
+			}
+			attempts++
+			if result.Passed {
+				passed++
+			}
+			tokens += result.Usage.InputTokens + result.Usage.OutputTokens
+			cost += result.CostUSD
+			ms += result.DurationMS
+			provider, model = result.Provider, result.Model
+		}
+		if attempts == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%.0f%%\t%s\t$%.4f\t%s\n", c, orDash(provider), orDash(model), passed, attempts,
+			100*float64(passed)/float64(attempts), i18n.FormatNumber(tokens), cost, formatMS(ms))
+	}
+	return tw.Flush()
+}
+
+// orDash shows a missing value in a table
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func newEvalCommand(global *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "eval",
+		Short: "Benchmark the agent on scripted coding tasks and compare models and configurations",
+		Long: "Eval suites are YAML files in .agent/evals of the project or evals in the config directory,\n" +
+			"one per suite. Each task runs in a throwaway workspace made of a fixture directory\n" +
+			"(relative to the suite file) and files, and passes when all its checks do, e.g. go-fixes.yaml:\n\n" +
+			"  description: Small Go fixes\n" +
+			"  tasks:\n" +
+			"    - name: off-by-one\n" +
+			"      prompt: Sum skips the last element. Fix it.\n" +
+			"      fixture: fixtures/sum\n" +
+			"      timeout: 5m\n" +
+			"      checks:\n" +
+			"        - command: [go, test, ./...]\n" +
+			"        - file: sum.go\n" +
+			"          contains: range\n",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the eval suites",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := defaultEvalStore(global.cfg())
+			if err != nil {
+				return err
+			}
+			list, err := store.List()
+			if err != nil {
+				return err
+			}
+			if len(list) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No eval suites; add <name>.yaml files to %s\n", strings.Join(store.Dirs, " or "))
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tTASKS\tDESCRIPTION")
+			for _, suite := range list {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", suite.Name, len(suite.Tasks), suite.Description)
+			}
+			return w.Flush()
+		},
+	})
+
+	var profiles, models, tasks []string
+	var repeat int
+	var keep, jsonOutput bool
+	var logPath string
+	run := &cobra.Command{
+		Use:   "run <suite | file.yaml>",
+		Short: "Run a suite's tasks with each configuration and report the pass rates",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := defaultEvalStore(global.cfg())
+			if err != nil {
+				return err
+			}
+			suite, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+			if len(tasks) > 0 {
+				selected := []evals.Task{}
+				for _, name := range tasks {
+					found := false
+					for _, task := range suite.Tasks {
+						if task.Name == name {
+							selected, found = append(selected, task), true
+						}
+					}
+					if !found {
+						return fmt.Errorf("eval suite %s has no task %s", suite.Name, name)
+					}
+				}
+				suite.Tasks = selected
+			}
+
+			log := io.Discard
+			if logPath != "" {
+				file, err := os.Create(logPath)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				log = file
+			}
+			progress := cmd.OutOrStdout()
+			if jsonOutput {
+				progress = cmd.ErrOrStderr()
+			}
+			configs := evalConfigs(profiles, models)
+			r := &evalRun{suite: suite, configs: configs, repeat: repeat, keep: keep, out: progress, log: log,
+				newAgent: func(c evalConfig) (*Agent, error) {
+					cfg, err := global.resolveConfig(c.Profile)
+					if err != nil {
+						return nil, err
+					}
+					if c.Model != "" {
+						cfg.Model = c.Model
+					}
+					agent := NewAgent(nil, nil, nil)
+					agent.logger = global.logger(log, slog.LevelWarn)
+					if _, err := agent.applyConfig(cfg); err != nil {
+						return nil, err
+					}
+					return agent, nil
+				}}
+			start := time.Now()
+			results, err := r.run(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(results)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "\n%s: %d tasks × %d attempts in %s\n\n", suite.Name, len(suite.Tasks), max(repeat, 1), formatMS(time.Since(start).Milliseconds()))
+			return writeEvalReport(cmd.OutOrStdout(), configs, results)
+		},
+	}
+	run.Flags().StringSliceVar(&profiles, "profiles", nil, "config profiles to compare, comma-separated or repeated (default the selected profile)")
+	run.Flags().StringSliceVar(&models, "models", nil, "models to compare, comma-separated or repeated; combined with each profile")
+	run.Flags().StringSliceVar(&tasks, "task", nil, "run only these tasks of the suite")
+	run.Flags().IntVar(&repeat, "repeat", 1, "attempts per task and configuration, to measure how reliably a task passes")
+	run.Flags().BoolVar(&keep, "keep", false, "keep the workspaces to inspect what the agent did")
+	run.Flags().BoolVar(&jsonOutput, "json", false, "write every attempt's result as JSON instead of the report")
+	run.Flags().StringVar(&logPath, "log", "", "write what the agent did in every attempt to this file")
+	cmd.AddCommand(run)
+	return cmd
+}
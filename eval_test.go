@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"agent/config"
+	"agent/evals"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalConfigs(t *testing.T) {
+	assert.Equal(t, []evalConfig{{}}, evalConfigs(nil, nil))
+	configs := evalConfigs([]string{"fast", "careful"}, []string{"gpt-4o"})
+	assert.Equal(t, []evalConfig{{Profile: "fast", Model: "gpt-4o"}, {Profile: "careful", Model: "gpt-4o"}}, configs)
+	assert.Equal(t, "fast+gpt-4o", configs[0].String())
+	assert.Equal(t, "default", evalConfig{}.String())
+	assert.Equal(t, "gpt-4o", evalConfig{Model: "gpt-4o"}.String())
+}
+
+func TestEvalRun(t *testing.T) {
+	chdir(t, t.TempDir())
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	suite := &evals.Suite{Name: "calc", Tasks: []evals.Task{{
+		Name:   "rename",
+		Prompt: "Rename the package to calc",
+		Files:  map[string]string{"calc.go": "package sum\n"},
+		Checks: []evals.Check{{File: "calc.go", Equals: "package calc"}},
+	}}}
+	// 模拟的模型直接改写工作区中的文件：good 改对，bad 改错，broken 出错
+	newAgent := func(c evalConfig) (*Agent, error) {
+		agent := NewAgent(ProviderFunc(func(context.Context, []Message, []tools.ToolDefinition) (*Response, error) {
+			switch c.Model {
+			case "broken":
+				return nil, errors.New("overloaded")
+			case "good":
+				os.WriteFile("calc.go", []byte("package calc\n"), 0644)
+			default:
+				os.WriteFile("calc.go", []byte("package calculator\n"), 0644)
+			}
+			return &Response{Content: "Done.", Usage: Usage{InputTokens: 100, OutputTokens: 20}}, nil
+		}), nil, nil)
+		agent.config = &config.Config{Provider: "openai", Model: c.Model}
+		return agent, nil
+	}
+
+	var out, log bytes.Buffer
+	configs := evalConfigs(nil, []string{"good", "bad", "broken"})
+	r := &evalRun{suite: suite, configs: configs, repeat: 2, newAgent: newAgent, out: &out, log: &log}
+	results, err := r.run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 6, "每个配置的每个任务运行 repeat 次")
+
+	assert.True(t, results[0].Passed)
+	assert.Equal(t, "good", results[0].Model)
+	assert.Equal(t, "openai", results[0].Provider)
+	assert.Equal(t, int64(100), results[0].Usage.InputTokens)
+	assert.Equal(t, 2, results[1].Attempt)
+	assert.False(t, results[2].Passed)
+	assert.Equal(t, "calc.go does not have the expected content", results[2].Failure)
+	assert.False(t, results[4].Passed)
+	assert.Contains(t, results[4].Failure, "overloaded")
+	assert.Contains(t, out.String(), "PASS  rename [good #1]")
+	assert.Contains(t, out.String(), "FAIL  rename [bad #2]")
+	assert.Contains(t, log.String(), "=== rename [good #1]")
+
+	after, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, cwd, after, "结束后回到原来的目录")
+	_, err = os.Stat("calc.go")
+	assert.True(t, os.IsNotExist(err), "任务在临时工作区中运行")
+
+	var report bytes.Buffer
+	require.NoError(t, writeEvalReport(&report, configs, results))
+	assert.Regexp(t, `good\s+openai\s+good\s+2/2\s+100%\s+240`, report.String())
+	assert.Regexp(t, `bad\s+openai\s+bad\s+0/2\s+0%`, report.String())
+}
+
+func TestEvalRunKeepsWorkspaces(t *testing.T) {
+	chdir(t, t.TempDir())
+	suite := &evals.Suite{Name: "s", Tasks: []evals.Task{{
+		Name:   "touch",
+		Prompt: "x",
+		Checks: []evals.Check{{File: "a.txt", Contains: "a"}},
+	}}}
+	r := &evalRun{suite: suite, configs: []evalConfig{{}}, keep: true, out: io.Discard, log: io.Discard,
+		newAgent: func(evalConfig) (*Agent, error) {
+			return NewAgent(&mockProvider{responses: []*Response{{Content: "Nothing to do."}}}, nil, nil), nil
+		}}
+	results, err := r.run(context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "a.txt does not exist", results[0].Failure)
+	require.NotEmpty(t, results[0].Workspace)
+	defer os.RemoveAll(results[0].Workspace)
+	assert.DirExists(t, results[0].Workspace, "--keep 保留工作区")
+}
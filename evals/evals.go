@@ -0,0 +1,283 @@
+// Package evals 读取评测套件：一组脚本化的编程任务，每个任务在临时工作区中
+// 交给智能体完成，再按成功标准检查结果，用来定量比较不同的模型和配置
+package evals
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxCheckOutput 是失败的检查命令保留的输出长度
+const maxCheckOutput = 2000
+
+// Suite 是一个具名的评测套件，定义在 <name>.yaml 文件中
+type Suite struct {
+	Name string `yaml:"-"`
+	// Dir 是套件文件所在的目录，任务的 Fixture 相对于它
+	Dir string `yaml:"-"`
+	// Description 是列表中显示的说明
+	Description string `yaml:"description,omitempty"`
+	Tasks       []Task `yaml:"tasks"`
+}
+
+// Task 是套件中的一个任务
+type Task struct {
+	Name string `yaml:"name"`
+	// Prompt 是发给智能体的任务描述
+	Prompt string `yaml:"prompt"`
+	// Fixture 是复制为工作区的目录，相对于套件文件所在的目录
+	Fixture string `yaml:"fixture,omitempty"`
+	// Files 是写入工作区的文件，路径到内容，覆盖 Fixture 中的同名文件
+	Files map[string]string `yaml:"files,omitempty"`
+	// Checks 是成功标准，全部通过时任务才算完成
+	Checks []Check `yaml:"checks"`
+	// Timeout 限制智能体完成任务的时间，为 0 时不限制
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Check 是一条成功标准：Command 执行成功，或 File 的内容符合 Equals 或 Contains
+type Check struct {
+	// Command 在工作区中执行，退出码为 0 时通过，例如 [go, test, ./...]
+	Command []string `yaml:"command,omitempty"`
+	// File 是要检查的文件，相对于工作区
+	File string `yaml:"file,omitempty"`
+	// Equals 是文件应有的内容，忽略首尾的空白
+	Equals string `yaml:"equals,omitempty"`
+	// Contains 是文件应该包含的文本
+	Contains string `yaml:"contains,omitempty"`
+}
+
+// String 返回检查的简短描述，用于报告失败
+func (c Check) String() string {
+	if len(c.Command) > 0 {
+		return strings.Join(c.Command, " ")
+	}
+	return c.File
+}
+
+// Parse 解析并检查名为 name 的评测套件，dir 是套件文件所在的目录
+func Parse(name, dir string, data []byte) (*Suite, error) {
+	var s Suite
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid eval suite %s: %w", name, err)
+	}
+	s.Name, s.Dir = name, dir
+	if len(s.Tasks) == 0 {
+		return nil, fmt.Errorf("eval suite %s has no tasks", name)
+	}
+	seen := map[string]bool{}
+	for i := range s.Tasks {
+		task := &s.Tasks[i]
+		if task.Name == "" {
+			task.Name = fmt.Sprintf("task %d", i+1)
+		}
+		if seen[task.Name] {
+			return nil, fmt.Errorf("eval suite %s has two tasks named %s", name, task.Name)
+		}
+		seen[task.Name] = true
+		if err := task.validate(); err != nil {
+			return nil, fmt.Errorf("task %s of eval suite %s: %w", task.Name, name, err)
+		}
+	}
+	return &s, nil
+}
+
+func (t *Task) validate() error {
+	if strings.TrimSpace(t.Prompt) == "" {
+		return fmt.Errorf("no prompt")
+	}
+	if len(t.Checks) == 0 {
+		return fmt.Errorf("no checks: add a command to run or a file to compare")
+	}
+	for path := range t.Files {
+		if !filepath.IsLocal(path) {
+			return fmt.Errorf("file %s is outside the workspace", path)
+		}
+	}
+	for i, check := range t.Checks {
+		switch {
+		case len(check.Command) > 0 && check.File != "":
+			return fmt.Errorf("check %d sets both command and file", i+1)
+		case len(check.Command) > 0:
+		case check.File == "":
+			return fmt.Errorf("check %d needs a command or a file", i+1)
+		case !filepath.IsLocal(check.File):
+			return fmt.Errorf("check %d: file %s is outside the workspace", i+1, check.File)
+		case check.Equals == "" && check.Contains == "":
+			return fmt.Errorf("check %d: file %s needs equals or contains", i+1, check.File)
+		}
+	}
+	return nil
+}
+
+// Setup 在空目录 workspace 中准备任务的工作区：先复制 Fixture，再写入 Files
+func (t *Task) Setup(suiteDir, workspace string) error {
+	if t.Fixture != "" {
+		fixture := t.Fixture
+		if !filepath.IsAbs(fixture) {
+			fixture = filepath.Join(suiteDir, fixture)
+		}
+		if err := copyDir(fixture, workspace); err != nil {
+			return fmt.Errorf("fixture of task %s: %w", t.Name, err)
+		}
+	}
+	paths := make([]string, 0, len(t.Files))
+	for path := range t.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		target := filepath.Join(workspace, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(t.Files[path]), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyDir 把 src 中的文件复制到 dst，保留文件权限
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// Verify 在工作区中执行检查，不通过时返回的错误说明原因
+func (c Check) Verify(ctx context.Context, workspace string) error {
+	if len(c.Command) > 0 {
+		cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+		cmd.Dir = workspace
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			return nil
+		}
+		text := strings.TrimSpace(string(output))
+		if len(text) > maxCheckOutput {
+			text = "…" + text[len(text)-maxCheckOutput:]
+		}
+		if text == "" {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+		return fmt.Errorf("%s: %w\n%s", c, err, text)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workspace, c.File))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s does not exist", c.File)
+	}
+	if err != nil {
+		return err
+	}
+	content := string(data)
+	if c.Equals != "" && strings.TrimSpace(content) != strings.TrimSpace(c.Equals) {
+		return fmt.Errorf("%s does not have the expected content", c.File)
+	}
+	if c.Contains != "" && !strings.Contains(content, c.Contains) {
+		return fmt.Errorf("%s does not contain %q", c.File, c.Contains)
+	}
+	return nil
+}
+
+// Store 从目录中加载评测套件，每个 <name>.yaml 文件是一个套件。Dirs 按优先级
+// 排列，前面目录中的套件覆盖后面目录中的同名套件
+type Store struct {
+	Dirs []string
+}
+
+// Load 按名称读取套件；以 .yaml 或 .yml 结尾的参数是套件文件的路径
+func (s *Store) Load(name string) (*Suite, error) {
+	if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		dir, err := filepath.Abs(filepath.Dir(name))
+		if err != nil {
+			return nil, err
+		}
+		return Parse(strings.TrimSuffix(filepath.Base(name), ext), dir, data)
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid eval suite name %q", name)
+	}
+	for _, dir := range s.Dirs {
+		data, err := os.ReadFile(filepath.Join(dir, name+".yaml"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		return Parse(name, abs, data)
+	}
+	return nil, fmt.Errorf("eval suite %s not found in %s", name, strings.Join(s.Dirs, ", "))
+}
+
+// List 返回全部套件，按名称排序；不存在的目录被忽略，无效的定义返回错误
+func (s *Store) List() ([]*Suite, error) {
+	byName := map[string]*Suite{}
+	for _, dir := range s.Dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), ".yaml")
+			if _, ok := byName[name]; ok || entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+				continue
+			}
+			suite, err := s.Load(name)
+			if err != nil {
+				return nil, err
+			}
+			byName[name] = suite
+		}
+	}
+	list := make([]*Suite, 0, len(byName))
+	for _, suite := range byName {
+		list = append(list, suite)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
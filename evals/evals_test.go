@@ -0,0 +1,132 @@
+package evals
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goFixes = `
+description: Small Go fixes
+tasks:
+  - name: off-by-one
+    prompt: Fix Sum so that the tests pass.
+    fixture: sum
+    files:
+      sum.go: "package sum\n"
+    checks:
+      - command: [go, test, ./...]
+      - file: sum.go
+        contains: range
+    timeout: 2m
+  - prompt: Rename the package to calc.
+    checks:
+      - file: calc.go
+        equals: package calc
+`
+
+func TestParse(t *testing.T) {
+	t.Run("解析任务", func(t *testing.T) {
+		s, err := Parse("go-fixes", "/suites", []byte(goFixes))
+		require.NoError(t, err)
+		assert.Equal(t, "go-fixes", s.Name)
+		assert.Equal(t, "/suites", s.Dir)
+		require.Len(t, s.Tasks, 2)
+		assert.Equal(t, 2*time.Minute, s.Tasks[0].Timeout)
+		assert.Equal(t, "go test ./...", s.Tasks[0].Checks[0].String())
+		assert.Equal(t, "task 2", s.Tasks[1].Name, "没有名称的任务按序号命名")
+	})
+
+	t.Run("无效的定义", func(t *testing.T) {
+		for _, bad := range []string{
+			"tasks: []\n",
+			"tasks: [{prompt: x}]\n",
+			"tasks: [{checks: [{command: [true]}]}]\n",
+			"tasks: [{prompt: x, checks: [{}]}]\n",
+			"tasks: [{prompt: x, checks: [{file: a.go}]}]\n",
+			"tasks: [{prompt: x, checks: [{file: ../a.go, contains: x}]}]\n",
+			"tasks: [{prompt: x, checks: [{command: [true], file: a.go, contains: x}]}]\n",
+			"tasks: [{prompt: x, files: {/etc/passwd: x}, checks: [{command: [true]}]}]\n",
+			"tasks: [{name: a, prompt: x, checks: [{command: [true]}]}, {name: a, prompt: y, checks: [{command: [true]}]}]\n",
+			"tasks: [{prompt: x, chekcs: []}]\n",
+		} {
+			_, err := Parse("bad", "", []byte(bad))
+			assert.Error(t, err, bad)
+		}
+	})
+}
+
+func TestSetup(t *testing.T) {
+	suiteDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(suiteDir, "sum", "internal"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(suiteDir, "sum", "sum.go"), []byte("package old\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(suiteDir, "sum", "internal", "x.go"), []byte("package internal\n"), 0644))
+
+	workspace := t.TempDir()
+	task := &Task{Name: "t", Fixture: "sum", Files: map[string]string{"sum.go": "package sum\n", "cmd/main.go": "package main\n"}}
+	require.NoError(t, task.Setup(suiteDir, workspace))
+
+	for path, want := range map[string]string{"sum.go": "package sum\n", "internal/x.go": "package internal\n", "cmd/main.go": "package main\n"} {
+		data, err := os.ReadFile(filepath.Join(workspace, path))
+		require.NoError(t, err, path)
+		assert.Equal(t, want, string(data), "Files 覆盖 Fixture 中的同名文件")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "calc.go"), []byte("package calc\n\nfunc Sum() {}\n"), 0644))
+	ctx := context.Background()
+
+	assert.NoError(t, Check{File: "calc.go", Contains: "func Sum"}.Verify(ctx, workspace))
+	assert.NoError(t, Check{File: "calc.go", Equals: "package calc\n\nfunc Sum() {}"}.Verify(ctx, workspace), "忽略首尾的空白")
+	assert.ErrorContains(t, Check{File: "calc.go", Equals: "package calc"}.Verify(ctx, workspace), "expected content")
+	assert.ErrorContains(t, Check{File: "calc.go", Contains: "func Avg"}.Verify(ctx, workspace), "does not contain")
+	assert.ErrorContains(t, Check{File: "missing.go", Contains: "x"}.Verify(ctx, workspace), "does not exist")
+
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用 sh 命令")
+	}
+	assert.NoError(t, Check{Command: []string{"sh", "-c", "test -f calc.go"}}.Verify(ctx, workspace), "命令在工作区中执行")
+	err := Check{Command: []string{"sh", "-c", "echo FAIL: TestSum; exit 1"}}.Verify(ctx, workspace)
+	assert.ErrorContains(t, err, "exit status 1")
+	assert.ErrorContains(t, err, "FAIL: TestSum", "失败时附上命令的输出")
+}
+
+func TestStore(t *testing.T) {
+	project, user := t.TempDir(), t.TempDir()
+	write := func(dir, name, body string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0644))
+	}
+	write(project, "go-fixes.yaml", goFixes)
+	write(user, "go-fixes.yaml", "description: mine\n"+goFixes[len("\ndescription: Small Go fixes\n"):])
+	write(user, "docs.yaml", "tasks: [{prompt: Write a README, checks: [{file: README.md, contains: Usage}]}]\n")
+	write(user, "notes.txt", "not a suite")
+	store := &Store{Dirs: []string{project, user, filepath.Join(t.TempDir(), "missing")}}
+
+	s, err := store.Load("go-fixes")
+	require.NoError(t, err)
+	assert.Equal(t, "Small Go fixes", s.Description, "项目中的套件优先")
+	assert.Equal(t, project, s.Dir)
+
+	list, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "docs", list[0].Name)
+	assert.Equal(t, "go-fixes", list[1].Name)
+
+	s, err = store.Load(filepath.Join(user, "docs.yaml"))
+	require.NoError(t, err, "也可以直接给出套件文件的路径")
+	assert.Equal(t, "docs", s.Name)
+
+	_, err = store.Load("../go-fixes")
+	assert.Error(t, err)
+	_, err = store.Load("missing")
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+)
+
+// Agent event types
+const (
+	EventUserMessage   = "user_message"
+	EventAssistantText = "assistant_text"
+	EventToolCall      = "tool_call"
+	EventToolResult    = "tool_result"
+	EventToolError     = "tool_error"
+	EventToolOutput    = "tool_output"
+	EventFileEdit      = "file_edit"
+	EventUsage         = "usage"
+	EventActivity      = "activity"
+	EventNotice        = "notice"
+	EventTiming        = "timing"
+	// EventTextDelta is a piece of a reply as it streams in; the
+	// assistant_text event that follows has the whole reply, after post-processing
+	EventTextDelta = "text_delta"
+)
+
+// AgentEvent describes something that happened while running a turn.
+// Front-ends (the line REPL, the TUI) render these instead of the agent
+// printing directly.
+type AgentEvent struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Content  string    `json:"content,omitempty"`
+	ToolCall *ToolCall `json:"tool_call,omitempty"`
+	// Path and Diff are set for file_edit events
+	Path  string `json:"path,omitempty"`
+	Diff  string `json:"diff,omitempty"`
+	Model string `json:"model,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+	// Timing is set for timing events, sent when a turn ends
+	Timing *TurnTiming `json:"timing,omitempty"`
+	// Files and Metadata come from the structured result of tool_result,
+	// tool_error and file_edit events
+	Files    []tools.FileRef `json:"files,omitempty"`
+	Metadata map[string]any  `json:"metadata,omitempty"`
+	// ErrorKind classifies the failure of tool_error events, if known
+	ErrorKind tools.ErrorKind `json:"error_kind,omitempty"`
+}
+
+// emit delivers an event to the registered handler, or prints it to the terminal
+func (a *Agent) emit(e AgentEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	a.crashes.record(e)
+	a.journalEvent(e)
+	if e.Type == EventAssistantText && a.voice != nil {
+		a.voice.speak(e.Content)
+	}
+	if a.onEvent != nil {
+		a.onEvent(e)
+		return
+	}
+	a.printEvent(e)
+}
+
+// printEvent renders an event for the line-based REPL
+func (a *Agent) printEvent(e AgentEvent) {
+	switch e.Type {
+	case EventUserMessage:
+		fmt.Printf("%s%s: %s\n", theme.User(i18n.T("You (queued)")), a.timestamp(e.Time), e.Content)
+	case EventAssistantText:
+		a.printAssistant(e.Content, e.Time)
+	case EventToolOutput:
+		a.printToolOutput(*e.ToolCall, e.Content)
+	case EventToolResult:
+		if a.endStreamedOutput(e.ToolCall) {
+			fmt.Println(i18n.Sprintf("%s: %s finished, output above", theme.Success(i18n.T("Tool Result")), e.ToolCall.Name))
+			return
+		}
+		a.printToolResult(*e.ToolCall, e.Content)
+		if len(e.Files) > 0 {
+			fmt.Println(theme.Muted(formatFileRefs(e.Files)))
+		}
+	case EventToolError:
+		label := i18n.T("Tool Error")
+		if e.ErrorKind == tools.ErrorPermissionDenied {
+			// the model is told not to retry, so the user decides what happens next
+			label = i18n.T("Denied")
+		}
+		if a.endStreamedOutput(e.ToolCall) {
+			// the output in the error was streamed already
+			fmt.Printf("%s: %s\n", theme.Error(label), firstLine(e.Content))
+			return
+		}
+		fmt.Printf("%s: %s\n", theme.Error(label), e.Content)
+	case EventFileEdit:
+		a.printFileDiff(e.Path, e.Diff)
+	case EventNotice:
+		fmt.Println(e.Content)
+	case EventTiming:
+		a.printTiming(*e.Timing)
+	}
+}
+
+// maxListedFiles caps the files named under a tool result
+const maxListedFiles = 5
+
+// formatFileRefs summarizes the files a tool result refers to, each file
+// named once, e.g. "3 files: a.go, b.go, c.go"
+func formatFileRefs(files []tools.FileRef) string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, file := range files {
+		if !seen[file.Path] {
+			seen[file.Path] = true
+			paths = append(paths, file.Path)
+		}
+	}
+	noun := "files"
+	if len(paths) == 1 {
+		noun = "file"
+	}
+	listed := paths
+	if len(listed) > maxListedFiles {
+		listed = listed[:maxListedFiles]
+	}
+	summary := fmt.Sprintf("%d %s: %s", len(paths), noun, strings.Join(listed, ", "))
+	if more := len(paths) - len(listed); more > 0 {
+		summary += fmt.Sprintf(" and %d more", more)
+	}
+	return summary
+}
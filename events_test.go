@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatFileRefs(t *testing.T) {
+	assert.Equal(t, "1 file: a.go", formatFileRefs([]tools.FileRef{{Path: "a.go", Line: 3}, {Path: "a.go", Line: 9}}))
+	refs := []tools.FileRef{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		refs = append(refs, tools.FileRef{Path: name + ".go"})
+	}
+	assert.Equal(t, "7 files: a.go, b.go, c.go, d.go, e.go and 2 more", formatFileRefs(refs))
+}
+
+func TestStructuredToolResultEvents(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("a.go", []byte("package a\n// TODO: one\n"), 0644))
+	require.NoError(t, os.WriteFile("b.go", []byte("package b\n// TODO: two\n"), 0644))
+
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{
+			{ID: "1", Name: "grep", Input: []byte(`{"pattern":"TODO"}`)},
+			{ID: "2", Name: "shell", Input: []byte(`{"command":"exit 3"}`)},
+		}},
+		{Content: "done"},
+	}}
+	agent := NewAgent(provider, nil, builtinTools())
+	results := map[string]AgentEvent{}
+	agent.onEvent = func(e AgentEvent) {
+		if e.Type == EventToolResult || e.Type == EventToolError {
+			results[e.ToolCall.ID] = e
+		}
+	}
+	require.NoError(t, agent.runTurn(context.Background(), "find the todos"))
+
+	t.Run("结果引用的文件和元数据随事件发出", func(t *testing.T) {
+		grep := results["1"]
+		assert.Equal(t, EventToolResult, grep.Type)
+		assert.Equal(t, []tools.FileRef{{Path: "a.go", Line: 2}, {Path: "b.go", Line: 2}}, grep.Files)
+		assert.Equal(t, 2, grep.Metadata["matches"])
+	})
+
+	t.Run("失败的结果带有退出码", func(t *testing.T) {
+		shell := results["2"]
+		assert.Equal(t, EventToolError, shell.Type)
+		assert.Equal(t, 3, shell.Metadata["exit_code"])
+	})
+
+	t.Run("终端显示引用的文件", func(t *testing.T) {
+		out := captureStdout(func() { agent.printEvent(results["1"]) })
+		assert.Contains(t, out, "2 files: a.go, b.go")
+	})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+)
+
+const explainPrompt = `Explain %s of %s to a developer who is new to this code base.
+Cover what it does, how it fits into the surrounding code, and anything subtle: edge cases,
+error handling, concurrency or performance concerns. Refer to lines by number. Use the read-only
+tools to look up other code when it helps, but do not suggest changes unless something is wrong.
+
+%s`
+
+// Limits on the context sent with an explanation request
+const (
+	maxExplainFile    = 64 * 1024
+	maxExplainSymbols = 20
+)
+
+func newExplainCommand(global *globalOptions) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "explain <path>[:line[-line]]",
+		Short: "Explain a file or a range of its lines",
+		Long: "Explain a file or a range of its lines, e.g. `agent explain main.go:40-80`. The file and the\n" +
+			"declarations the lines refer to are sent with the request, and the model may read more of the\n" +
+			"code with the read-only tools. The explanation is printed to stdout for use from editors.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch output {
+			case outputText, outputJSON, outputJSONL:
+			default:
+				return fmt.Errorf("unknown output format %q", output)
+			}
+			path, start, end, err := parseExplainTarget(args[0])
+			if err != nil {
+				return err
+			}
+			prompt, err := explainRequest(path, start, end)
+			if err != nil {
+				return err
+			}
+
+			agent := NewAgent(nil, nil, nil)
+			agent.logger = global.logger(os.Stderr, slog.LevelWarn)
+			if _, err := agent.applyConfig(global.cfg()); err != nil {
+				return err
+			}
+			agent.setTools(readOnlyTools(agent.toolDefinitions()))
+			return runPrompt(cmd.Context(), agent, prompt, output, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", outputText, "output format: text, json (one object at the end) or jsonl (streamed events)")
+	return cmd
+}
+
+// parseExplainTarget splits "path:start-end" into its parts. Without a range start
+// and end are 0; "path:n" selects a single line.
+func parseExplainTarget(arg string) (path string, start, end int, err error) {
+	path, lines, found := cutLast(arg, ":")
+	if !found || lines == "" || strings.ContainsAny(lines, `/\`) {
+		// a colon in the path itself, e.g. C:\src\main.go
+		return arg, 0, 0, nil
+	}
+	first, last, isRange := strings.Cut(lines, "-")
+	if start, err = strconv.Atoi(first); err != nil || start < 1 {
+		return "", 0, 0, fmt.Errorf("invalid line range %q: use PATH:LINE or PATH:START-END", lines)
+	}
+	end = start
+	if isRange {
+		if end, err = strconv.Atoi(last); err != nil || end < start {
+			return "", 0, 0, fmt.Errorf("invalid line range %q: use PATH:LINE or PATH:START-END", lines)
+		}
+	}
+	return path, start, end, nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// explainRequest builds the prompt for explaining lines start to end of path, or
+// the whole file when start is 0
+func explainRequest(path string, start, end int) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	target := "the file"
+	if start > 0 {
+		if start > len(lines) {
+			return "", fmt.Errorf("%s has only %d lines", path, len(lines))
+		}
+		end = min(end, len(lines))
+		target = fmt.Sprintf("lines %d-%d", start, end)
+		if start == end {
+			target = fmt.Sprintf("line %d", start)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Contents of %s:\n", filepath.ToSlash(path))
+	size := 0
+	for i, line := range lines {
+		if size > maxExplainFile {
+			b.WriteString("... (file truncated)\n")
+			break
+		}
+		size += len(line) + 1
+		marker := " "
+		if start > 0 && i+1 >= start && i+1 <= end {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s%5d  %s\n", marker, i+1, line)
+	}
+	if symbols := relatedSymbols(path, start, end); len(symbols) > 0 {
+		b.WriteString("\nDeclarations in the same package that the code refers to:\n")
+		for _, symbol := range symbols {
+			fmt.Fprintf(&b, "\n// %s\n%s\n", symbol.location, symbol.source)
+		}
+	}
+	return fmt.Sprintf(explainPrompt, target, filepath.ToSlash(path), b.String()), nil
+}
+
+// symbol is a top-level declaration and where it is
+type symbol struct {
+	location string
+	source   string
+}
+
+// relatedSymbols returns the declarations in the other files of the Go package of
+// path that lines start to end refer to. It returns nil for other languages and
+// for files that do not parse.
+func relatedSymbols(path string, start, end int) []symbol {
+	if !strings.HasSuffix(path, ".go") {
+		return nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil
+	}
+	inRange := func(pos token.Pos) bool {
+		line := fset.Position(pos).Line
+		return start == 0 || (line >= start && line <= end)
+	}
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && inRange(id.Pos()) {
+			used[id.Name] = true
+		}
+		return true
+	})
+
+	// the file itself is sent whole; test files are left out
+	dir := filepath.Dir(path)
+	var files []*ast.File
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || sameFile(name, path) {
+			continue
+		}
+		if f, err := parser.ParseFile(fset, name, nil, parser.ParseComments); err == nil && f.Name.Name == file.Name.Name {
+			files = append(files, f)
+		}
+	}
+
+	var symbols []symbol
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if len(symbols) == maxExplainSymbols {
+				break
+			}
+			if !declares(decl, used) {
+				continue
+			}
+			begin := decl.Pos()
+			if doc := declDoc(decl); doc != nil {
+				begin = doc.Pos()
+			}
+			position := fset.Position(begin)
+			src, err := os.ReadFile(position.Filename)
+			if err != nil {
+				continue
+			}
+			symbols = append(symbols, symbol{
+				location: fmt.Sprintf("%s:%d", filepath.ToSlash(position.Filename), fset.Position(decl.Pos()).Line),
+				source:   string(src[position.Offset:fset.Position(decl.End()).Offset]),
+			})
+		}
+	}
+	return symbols
+}
+
+// declares reports whether decl declares one of the names
+func declares(decl ast.Decl, names map[string]bool) bool {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return names[d.Name.Name]
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if names[s.Name.Name] {
+					return true
+				}
+			case *ast.ValueSpec:
+				for _, name := range s.Names {
+					if names[name.Name] {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+func sameFile(a, b string) bool {
+	x, errA := os.Stat(a)
+	y, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(x, y)
+}
+
+// readOnlyTools returns the tools that do not modify anything
+func readOnlyTools(defs []tools.ToolDefinition) []tools.ToolDefinition {
+	var readOnly []tools.ToolDefinition
+	for _, def := range defs {
+		if def.ReadOnly {
+			readOnly = append(readOnly, def)
+		}
+	}
+	return readOnly
+}
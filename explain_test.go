@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExplainTarget(t *testing.T) {
+	for arg, want := range map[string][3]interface{}{
+		"main.go":        {"main.go", 0, 0},
+		"main.go:12":     {"main.go", 12, 12},
+		"main.go:12-30":  {"main.go", 12, 30},
+		`C:\src\main.go`: {`C:\src\main.go`, 0, 0},
+	} {
+		path, start, end, err := parseExplainTarget(arg)
+		require.NoError(t, err, arg)
+		assert.Equal(t, want, [3]interface{}{path, start, end}, arg)
+	}
+	for _, arg := range []string{"main.go:0", "main.go:30-12", "main.go:a-b"} {
+		_, _, _, err := parseExplainTarget(arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestExplainRequest(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() {\n\tprintln(greeting(name))\n}\n\nfunc unused() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "greet.go"), []byte("package main\n\n// greeting 返回问候语\nfunc greeting(who string) string { return \"hi \" + who }\n\nvar name = \"go\"\n\nfunc other() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "greet_test.go"), []byte("package main\n\nvar name2 = name\n"), 0644))
+
+	prompt, err := explainRequest(main, 3, 5)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "Explain lines 3-5 of ")
+	assert.Contains(t, prompt, ">    4  \tprintln(greeting(name))\n")
+	assert.Contains(t, prompt, "     7  func unused() {}\n")
+	assert.Contains(t, prompt, "// greeting 返回问候语\nfunc greeting(who string) string", "包含引用的声明及其注释")
+	assert.Contains(t, prompt, `var name = "go"`)
+	assert.NotContains(t, prompt, "func other()")
+	assert.NotContains(t, prompt, "name2")
+
+	_, err = explainRequest(main, 40, 40)
+	assert.Error(t, err)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/i18n"
+	"agent/pkg/message"
+
+	"github.com/spf13/cobra"
+)
+
+// exportFormatForPath picks the export format from a file extension
+func exportFormatForPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return "json"
+	}
+	return "md"
+}
+
+// exportSession writes s to w in the given format ("md" or "json")
+func exportSession(w io.Writer, s *Session, format string) error {
+	switch format {
+	case "md", "markdown":
+		return ExportMarkdown(w, s)
+	case "json":
+		return ExportJSON(w, s)
+	default:
+		return fmt.Errorf("unknown export format %q (want md or json)", format)
+	}
+}
+
+// ExportJSON writes the session as indented JSON
+func ExportJSON(w io.Writer, s *Session) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// ExportMarkdown writes a readable transcript with tool calls and results in fenced blocks
+func ExportMarkdown(w io.Writer, s *Session) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session %s\n\n", s.ID)
+	fmt.Fprintf(&b, "- Created: %s\n", formatTime(s.CreatedAt))
+	fmt.Fprintf(&b, "- Messages: %d\n", len(s.Messages))
+	if s.Usage != nil && (s.Usage.InputTokens > 0 || s.Usage.OutputTokens > 0) {
+		fmt.Fprintf(&b, "- Tokens: %s in / %s out\n", i18n.FormatNumber(s.Usage.InputTokens), i18n.FormatNumber(s.Usage.OutputTokens))
+	}
+	if s.ParentID != "" {
+		fmt.Fprintf(&b, "- Forked from: %s (message %d)\n", s.ParentID, s.ForkedAt)
+	}
+
+	for _, msg := range s.Messages {
+		b.WriteString("\n")
+		meta := ""
+		if line := formatMeta(msg.Meta); line != "" {
+			meta = "_" + line + "_\n\n"
+		}
+		if msg.ToolCall != nil {
+			fmt.Fprintf(&b, "### Tool call: `%s`\n\n", msg.ToolCall.Name)
+			b.WriteString(meta)
+			b.WriteString(fence(prettyJSON(msg.ToolCall.Input), "json"))
+			b.WriteString("\n**Result:**\n\n")
+			result := strings.TrimPrefix(msg.Content, toolResultContent(msg.ToolCall.Name, ""))
+			b.WriteString(fence(result, resultLanguage(result)))
+			continue
+		}
+
+		if msg.Role == "user" {
+			b.WriteString("## User\n\n")
+		} else {
+			b.WriteString("## Assistant\n\n")
+		}
+		b.WriteString(meta)
+		b.WriteString(strings.TrimRight(msg.Content, "\n"))
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// timeFormat is the time_format of the config, set when the config is loaded
+var timeFormat string
+
+// formatTime shows t in the configured time format, by default the one of
+// the interface language
+func formatTime(t time.Time) string {
+	return i18n.FormatTime(t, timeFormat)
+}
+
+// formatMeta summarizes message metadata as "2006-01-02 15:04:05 · gpt-4o ·
+// 1.2k in / 300 out · calls 1, 2", or for tool results "... · took 1.5s";
+// it is empty for messages saved without metadata
+func formatMeta(meta *message.Metadata) string {
+	if meta == nil {
+		return ""
+	}
+	parts := []string{formatTime(meta.Time)}
+	if meta.Model != "" {
+		parts = append(parts, meta.Model)
+	}
+	if meta.Usage != nil {
+		parts = append(parts, fmt.Sprintf("%s in / %s out", formatTokens(meta.Usage.InputTokens), formatTokens(meta.Usage.OutputTokens)))
+	}
+	if len(meta.ToolCallIDs) > 0 {
+		parts = append(parts, "calls "+strings.Join(meta.ToolCallIDs, ", "))
+	}
+	if meta.DurationMS > 0 {
+		parts = append(parts, "took "+(time.Duration(meta.DurationMS)*time.Millisecond).String())
+	}
+	return strings.Join(parts, " · ")
+}
+
+// fence wraps content in a code fence longer than any backtick run inside it
+func fence(content, lang string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + lang + "\n" + strings.TrimRight(content, "\n") + "\n" + marker + "\n"
+}
+
+func prettyJSON(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	pretty, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(raw)
+	}
+	return string(pretty)
+}
+
+// resultLanguage detects unified diffs so they get diff highlighting
+func resultLanguage(result string) string {
+	if strings.HasPrefix(result, "diff --git") || strings.HasPrefix(result, "--- ") || strings.Contains(result, "\n@@ ") {
+		return "diff"
+	}
+	return ""
+}
+
+func newExportCommand() *cobra.Command {
+	var format, output string
+	cmd := &cobra.Command{
+		Use:   "export <session file or ID>",
+		Short: "Export a session as Markdown or JSON",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := loadSessionArg(args[0])
+			if err != nil {
+				return err
+			}
+			if format == "" {
+				format = exportFormatForPath(output)
+			}
+			if output == "" {
+				return exportSession(cmd.OutOrStdout(), session, format)
+			}
+			return exportToFile(output, session, format)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "export format: md or json (default from -o extension, else md)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write to file instead of stdout")
+	return cmd
+}
+
+func exportToFile(path string, s *Session, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := exportSession(f, s, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runExportCommand(a *Agent, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /export [file.md|file.json]")
+	}
+	path := a.session.ID + ".md"
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	a.syncSession()
+	if err := exportToFile(path, a.session, exportFormatForPath(path)); err != nil {
+		return err
+	}
+	fmt.Printf("Exported session to %s\n", path)
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agent/pkg/message"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestSession() *Session {
+	s := NewSession()
+	s.Messages = []Message{
+		{Role: "user", Content: "show me main.go"},
+		{
+			Role:     "user",
+			Content:  toolResultContent("read_file", "package main\n```inner```\n"),
+			ToolCall: &ToolCall{ID: "1", Name: "read_file", Input: json.RawMessage(`{"path":"main.go"}`)},
+		},
+		{Role: "assistant", Content: "Here it is:\n\n```go\npackage main\n```"},
+	}
+	return s
+}
+
+func TestExportMarkdown(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, ExportMarkdown(&out, exportTestSession()))
+
+	md := out.String()
+	assert.Contains(t, md, "## User\n\nshow me main.go")
+	assert.Contains(t, md, "### Tool call: `read_file`")
+	assert.Contains(t, md, "```json\n{\n  \"path\": \"main.go\"\n}\n```")
+	// 结果中包含反引号时使用更长的围栏
+	assert.Contains(t, md, "````\npackage main\n```inner```\n````")
+	assert.Contains(t, md, "## Assistant\n\nHere it is:")
+
+	t.Run("显示消息的元数据", func(t *testing.T) {
+		s := exportTestSession()
+		at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+		s.Messages[1].Meta = &message.Metadata{Time: at, DurationMS: 1500}
+		s.Messages[2].Meta = &message.Metadata{Time: at, Model: "gpt-4o", Usage: &Usage{InputTokens: 1200, OutputTokens: 300}, ToolCallIDs: []string{"1"}}
+		var out bytes.Buffer
+		require.NoError(t, ExportMarkdown(&out, s))
+		md := out.String()
+		assert.Contains(t, md, "## User\n\nshow me main.go", "没有元数据的消息保持原样")
+		assert.Contains(t, md, "### Tool call: `read_file`\n\n_2024-05-01 09:30:00 · took 1.5s_\n\n")
+		assert.Contains(t, md, "## Assistant\n\n_2024-05-01 09:30:00 · gpt-4o · 1.2k in / 300 out · calls 1_\n\nHere it is:")
+	})
+
+	t.Run("按配置的格式显示时间", func(t *testing.T) {
+		defer func() { timeFormat = "" }()
+		timeFormat = "Jan 2 15:04"
+		s := exportTestSession()
+		s.CreatedAt = time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+		s.Usage = &SessionUsage{InputTokens: 123456, OutputTokens: 7890}
+		s.Messages[2].Meta = &message.Metadata{Time: time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)}
+		var out bytes.Buffer
+		require.NoError(t, ExportMarkdown(&out, s))
+		md := out.String()
+		assert.Contains(t, md, "- Created: May 1 09:00\n")
+		assert.Contains(t, md, "- Tokens: 123,456 in / 7,890 out\n")
+		assert.Contains(t, md, "_May 1 09:30_")
+	})
+}
+
+func TestFence(t *testing.T) {
+	assert.Equal(t, "```go\nx\n```\n", fence("x\n", "go"))
+	assert.Equal(t, "`````\na ```` b\n`````\n", fence("a ```` b", ""))
+}
+
+func TestResultLanguage(t *testing.T) {
+	assert.Equal(t, "diff", resultLanguage("--- a/x\n+++ b/x\n@@ -1 +1 @@\n"))
+	assert.Equal(t, "", resultLanguage("plain text"))
+}
+
+func TestRunExport(t *testing.T) {
+	dir := t.TempDir()
+	s := exportTestSession()
+	path := filepath.Join(dir, "session.json")
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+
+	t.Run("默认输出Markdown到标准输出", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(newExportCommand(), nil, &out, path))
+		assert.Contains(t, out.String(), "# Session "+s.ID)
+	})
+
+	t.Run("按扩展名导出JSON文件", func(t *testing.T) {
+		target := filepath.Join(dir, "out.json")
+		var out bytes.Buffer
+		require.NoError(t, runCommand(newExportCommand(), nil, &out, "-o", target, path))
+
+		loaded, err := LoadSessionFile(target)
+		require.NoError(t, err)
+		require.Len(t, loaded.Messages, len(s.Messages))
+		assert.Equal(t, s.Messages[1].Content, loaded.Messages[1].Content)
+		assert.JSONEq(t, string(s.Messages[1].ToolCall.Input), string(loaded.Messages[1].ToolCall.Input))
+	})
+
+	t.Run("未知格式", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Error(t, runCommand(newExportCommand(), nil, &out, "--format", "pdf", path))
+	})
+}
+
+func TestExportCommand(t *testing.T) {
+	agent := NewAgent(&mockProvider{}, nil, nil)
+	agent.conversation = exportTestSession().Messages
+
+	target := filepath.Join(t.TempDir(), "transcript.md")
+	handled, err := agent.handleCommand("/export " + target)
+	require.True(t, handled)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "# Session "+agent.session.ID)
+}
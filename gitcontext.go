@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"agent/gitutil"
+	"agent/i18n"
+)
+
+const (
+	// gitContextCommits is how many recent commit messages the git context lists
+	gitContextCommits = 5
+	// gitContextMaxFiles caps the uncommitted files listed, so a workspace
+	// full of build output does not fill the context
+	gitContextMaxFiles = 40
+)
+
+// loadGitContext summarizes the repository around dir for the system
+// context: the branch, uncommitted changes and the last commit messages,
+// since "continue where I left off" is the most common first prompt. It is
+// empty outside a git repository.
+func loadGitContext(dir string) string {
+	repo, err := gitutil.Open(dir)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	// no timestamp: the context stays the same for identical repositories,
+	// which keeps provider prompt caches and the response cache effective
+	b.WriteString("Git state of the workspace when the session started or /refresh last ran (it may have changed since):\n")
+	if branch, err := repo.CurrentBranch(); err == nil {
+		fmt.Fprintf(&b, "\nBranch: %s\n", branch)
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		return ""
+	}
+	files := strings.Split(strings.TrimRight(status, "\n"), "\n")
+	switch {
+	case status == "":
+		b.WriteString("\nNo uncommitted changes.\n")
+	default:
+		b.WriteString("\nUncommitted changes:\n")
+		for _, file := range files[:min(len(files), gitContextMaxFiles)] {
+			b.WriteString(file + "\n")
+		}
+		if len(files) > gitContextMaxFiles {
+			fmt.Fprintf(&b, "... and %d more files\n", len(files)-gitContextMaxFiles)
+		}
+		if stat, err := repo.DiffStat(); err == nil && stat != "" {
+			// the last line is the total, e.g. "3 files changed, 10 insertions(+)"
+			lines := strings.Split(strings.TrimRight(stat, "\n"), "\n")
+			fmt.Fprintf(&b, "In tracked files: %s\n", strings.TrimSpace(lines[len(lines)-1]))
+		}
+	}
+
+	if commits, err := repo.RecentCommits(gitContextCommits); err == nil && len(commits) > 0 {
+		b.WriteString("\nLast commits:\n")
+		for _, commit := range commits {
+			b.WriteString(commit + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// refreshGitContext recomputes the git context of the workspace; working on
+// another machine leaves it empty, since git runs locally
+func (a *Agent) refreshGitContext() {
+	context := ""
+	if a.config.Remote.Host == "" {
+		context = loadGitContext(currentWorkspace.Root)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gitContext = context
+}
+
+func (a *Agent) currentGitContext() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.gitContext
+}
+
+func runRefreshCommand(a *Agent, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: /refresh")
+	}
+	a.refreshGitContext()
+	if a.currentGitContext() == "" {
+		fmt.Println(i18n.T("Not in a git repository; no git context to refresh"))
+		return nil
+	}
+	fmt.Println(i18n.T("Refreshed the git context sent to the model"))
+	return nil
+}
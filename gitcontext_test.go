@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"agent/gitutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitContext(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git 不可用")
+	}
+	dir := t.TempDir()
+	chdir(t, dir)
+	agent := NewAgent(nil, nil, nil)
+	agent.refreshGitContext()
+	assert.Empty(t, agent.currentGitContext(), "不在仓库中时没有 git 信息")
+
+	repo := &gitutil.Repo{Dir: dir}
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+		{"config", "commit.gpgsign", "false"},
+	} {
+		_, err := repo.Run(args...)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile("a.go", []byte("package a\n"), 0644))
+	_, err := repo.Run("add", ".")
+	require.NoError(t, err)
+	_, err = repo.Run("commit", "-q", "-m", "Add package a")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile("a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, os.WriteFile("b.go", []byte("package a\n"), 0644))
+
+	agent.refreshGitContext()
+	context := agent.currentGitContext()
+	assert.Contains(t, context, "Branch: main")
+	assert.Contains(t, context, "Uncommitted changes:\n M a.go\n?? b.go\n")
+	assert.Contains(t, context, "In tracked files: 1 file changed, 2 insertions(+)")
+	assert.Regexp(t, `Last commits:\n[0-9a-f]+ Add package a$`, context)
+
+	conversation := agent.requestConversation()
+	require.Len(t, conversation, 1)
+	assert.Equal(t, Message{Role: "system", Content: context}, conversation[0])
+
+	t.Run("/refresh 重新获取", func(t *testing.T) {
+		_, err := repo.Run("commit", "-qam", "Add A")
+		require.NoError(t, err)
+		_, err = agent.handleCommand("/refresh")
+		require.NoError(t, err)
+		assert.Contains(t, agent.currentGitContext(), "Uncommitted changes:\n?? b.go\n\nLast")
+	})
+}
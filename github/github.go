@@ -0,0 +1,362 @@
+// Package github 是 GitHub REST API 的一个小客户端，只包含代理需要的接口
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL 是 github.com 的 API 地址，GitHub Enterprise 为 https://HOST/api/v3
+const DefaultBaseURL = "https://api.github.com"
+
+// Client 调用 GitHub REST API，Token 为空时只能访问公开数据
+type Client struct {
+	BaseURL string
+	Token   string
+	// HTTP 为空时使用带超时的默认客户端
+	HTTP *http.Client
+}
+
+// Repository 标识一个仓库
+type Repository struct {
+	Owner string
+	Name  string
+}
+
+func (r Repository) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+var remotePattern = regexp.MustCompile(`^(?:https?://(?:[^@/]+@)?|ssh://(?:[^@/]+@)?|[^@/]+@)([^/:]+)(?::\d+)?[:/]([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// ParseRemote 从 git 远程地址中解析主机和仓库，支持 https、ssh 和 scp 风格（git@host:owner/repo.git）的地址
+func ParseRemote(remote string) (host string, repo Repository, err error) {
+	m := remotePattern.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil {
+		return "", Repository{}, fmt.Errorf("cannot parse a GitHub repository from remote %q", remote)
+	}
+	return m[1], Repository{Owner: m[2], Name: m[3]}, nil
+}
+
+// APIError 是 API 返回的错误
+type APIError struct {
+	Status  int
+	Message string
+	Errors  []struct {
+		Message string `json:"message"`
+	}
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("GitHub API error %d: %s", e.Status, e.Message)
+	for _, detail := range e.Errors {
+		if detail.Message != "" {
+			msg += "; " + detail.Message
+		}
+	}
+	return msg
+}
+
+// Do 发送请求，body 不为 nil 时编码为 JSON，响应解码到 out（可以为 nil）
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.request(ctx, method, path, "application/vnd.github+json", body, func(r io.Reader) error {
+		if out == nil {
+			return nil
+		}
+		return json.NewDecoder(r).Decode(out)
+	})
+}
+
+// request 发送请求，成功时用 read 读取响应内容
+func (c *Client) request(ctx context.Context, method, path, accept string, body interface{}, read func(io.Reader) error) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(base, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{Status: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	return read(resp.Body)
+}
+
+// DefaultBranch 返回仓库的默认分支
+func (c *Client) DefaultBranch(ctx context.Context, repo Repository) (string, error) {
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.Do(ctx, http.MethodGet, "/repos/"+escape(repo), nil, &info); err != nil {
+		return "", err
+	}
+	return info.DefaultBranch, nil
+}
+
+// NewPullRequest 是创建拉取请求的参数，Head 是包含改动的分支
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// PullRequest 是已创建的拉取请求
+type PullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreatePullRequest 在仓库中创建拉取请求
+func (c *Client) CreatePullRequest(ctx context.Context, repo Repository, pr NewPullRequest) (*PullRequest, error) {
+	var created PullRequest
+	if err := c.Do(ctx, http.MethodPost, "/repos/"+escape(repo)+"/pulls", pr, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func escape(repo Repository) string {
+	return url.PathEscape(repo.Owner) + "/" + url.PathEscape(repo.Name)
+}
+
+// User 是 issue 或评论的作者
+type User struct {
+	Login string `json:"login"`
+}
+
+// Issue 是一个 issue 或拉取请求，拉取请求的 PullRequest 不为 nil
+type Issue struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	State       string    `json:"state"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	User        User      `json:"user"`
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+// Comment 是 issue 或拉取请求下的评论
+type Comment struct {
+	User      User      `json:"user"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetIssue 返回编号为 number 的 issue 或拉取请求
+func (c *Client) GetIssue(ctx context.Context, repo Repository, number int) (*Issue, error) {
+	var issue Issue
+	if err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", escape(repo), number), nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// IssueComments 返回 issue 或拉取请求的前 100 条评论
+func (c *Client) IssueComments(ctx context.Context, repo Repository, number int) ([]Comment, error) {
+	var comments []Comment
+	if err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100", escape(repo), number), nil, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// LinkedPullRequests 返回在 issue 的时间线中引用了它的同仓库拉取请求
+func (c *Client) LinkedPullRequests(ctx context.Context, repo Repository, number int) ([]Issue, error) {
+	var events []struct {
+		Event  string `json:"event"`
+		Source struct {
+			Issue *struct {
+				Issue
+				Repository struct {
+					FullName string `json:"full_name"`
+				} `json:"repository"`
+			} `json:"issue"`
+		} `json:"source"`
+	}
+	if err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/timeline?per_page=100", escape(repo), number), nil, &events); err != nil {
+		return nil, err
+	}
+	linked := []Issue{}
+	seen := map[int]bool{}
+	for _, event := range events {
+		source := event.Source.Issue
+		if event.Event != "cross-referenced" || source == nil || source.PullRequest == nil || seen[source.Number] {
+			continue
+		}
+		if !strings.EqualFold(source.Repository.FullName, repo.String()) {
+			continue
+		}
+		seen[source.Number] = true
+		linked = append(linked, source.Issue)
+	}
+	return linked, nil
+}
+
+// PullRequestDiff 返回拉取请求的统一格式 diff
+func (c *Client) PullRequestDiff(ctx context.Context, repo Repository, number int) (string, error) {
+	var diff strings.Builder
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", escape(repo), number), "application/vnd.github.diff", nil, func(body io.Reader) error {
+		_, err := io.Copy(&diff, body)
+		return err
+	})
+	return diff.String(), err
+}
+
+// CreateIssueComment 在 issue 或拉取请求下发表评论
+func (c *Client) CreateIssueComment(ctx context.Context, repo Repository, number int, body string) (*Comment, error) {
+	var comment Comment
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", escape(repo), number), map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ReviewComment 是评审中针对一行代码的评论，Line 是新版本中的行号
+type ReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Body string `json:"body"`
+}
+
+// NewReview 是要提交的评审，Event 为 COMMENT、APPROVE 或 REQUEST_CHANGES
+type NewReview struct {
+	Body     string          `json:"body,omitempty"`
+	Event    string          `json:"event"`
+	Comments []ReviewComment `json:"comments,omitempty"`
+}
+
+// Review 是已提交的评审
+type Review struct {
+	ID      int64  `json:"id"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreateReview 在拉取请求上提交评审，评论只能针对 diff 中出现的行
+func (c *Client) CreateReview(ctx context.Context, repo Repository, number int, review NewReview) (*Review, error) {
+	var created Review
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/pulls/%d/reviews", escape(repo), number), review, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// WorkflowRun 是 GitHub Actions 的一次工作流运行，Conclusion 在运行结束前为空
+type WorkflowRun struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	RunNumber  int       `json:"run_number"`
+	Event      string    `json:"event"`
+	HeadBranch string    `json:"head_branch"`
+	HeadSHA    string    `json:"head_sha"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion"`
+	HTMLURL    string    `json:"html_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// JobStep 是作业中的一个步骤
+type JobStep struct {
+	Number     int    `json:"number"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+}
+
+// Job 是工作流运行中的一个作业
+type Job struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion"`
+	HTMLURL    string    `json:"html_url"`
+	Steps      []JobStep `json:"steps"`
+}
+
+// WorkflowRuns 返回分支上最近的工作流运行，最新的在前；branch 为空时不按分支过滤
+func (c *Client) WorkflowRuns(ctx context.Context, repo Repository, branch string) ([]WorkflowRun, error) {
+	path := "/repos/" + escape(repo) + "/actions/runs?per_page=30"
+	if branch != "" {
+		path += "&branch=" + url.QueryEscape(branch)
+	}
+	var page struct {
+		WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+	}
+	if err := c.Do(ctx, http.MethodGet, path, nil, &page); err != nil {
+		return nil, err
+	}
+	return page.WorkflowRuns, nil
+}
+
+// WorkflowRun 返回 ID 为 id 的工作流运行
+func (c *Client) WorkflowRun(ctx context.Context, repo Repository, id int64) (*WorkflowRun, error) {
+	var run WorkflowRun
+	if err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/actions/runs/%d", escape(repo), id), nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// RunJobs 返回工作流运行最近一次尝试的前 100 个作业
+func (c *Client) RunJobs(ctx context.Context, repo Repository, runID int64) ([]Job, error) {
+	var page struct {
+		Jobs []Job `json:"jobs"`
+	}
+	if err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/actions/runs/%d/jobs?per_page=100", escape(repo), runID), nil, &page); err != nil {
+		return nil, err
+	}
+	return page.Jobs, nil
+}
+
+// JobLog 返回作业的纯文本日志；API 重定向到日志的下载地址，需要有仓库读权限的 token
+func (c *Client) JobLog(ctx context.Context, repo Repository, jobID int64) (string, error) {
+	var log strings.Builder
+	err := c.request(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/actions/jobs/%d/logs", escape(repo), jobID), "application/vnd.github+json", nil, func(body io.Reader) error {
+		_, err := io.Copy(&log, body)
+		return err
+	})
+	return log.String(), err
+}
@@ -0,0 +1,181 @@
+// Package gitlab 是 GitLab REST API（v4）的一个小客户端，只包含代理需要的接口
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL 是 gitlab.com 的 API 地址，自建实例为 https://HOST/api/v4
+const DefaultBaseURL = "https://gitlab.com/api/v4"
+
+// Client 调用 GitLab REST API，Token 为空时只能访问公开数据
+type Client struct {
+	BaseURL string
+	Token   string
+	// HTTP 为空时使用带超时的默认客户端
+	HTTP *http.Client
+}
+
+var remotePattern = regexp.MustCompile(`^(?:https?://(?:[^@/]+@)?|ssh://(?:[^@/]+@)?|[^@/]+@)([^/:]+)(?::\d+)?[:/](.+?)(?:\.git)?/?$`)
+
+// ParseRemote 从 git 远程地址中解析主机和项目路径，项目路径可以包含子组（group/subgroup/project）
+func ParseRemote(remote string) (host, project string, err error) {
+	m := remotePattern.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil || !strings.Contains(m[2], "/") {
+		return "", "", fmt.Errorf("cannot parse a GitLab project from remote %q", remote)
+	}
+	return m[1], m[2], nil
+}
+
+// APIError 是 API 返回的错误
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitLab API error %d: %s", e.Status, e.Message)
+}
+
+// Get 请求 path 并把 JSON 响应解码到 out
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		// GitLab 的 message 可能是字符串，也可能是按字段分组的对象
+		var body struct {
+			Message json.RawMessage `json:"message"`
+			Error   string          `json:"error"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(data, &body)
+		apiErr := &APIError{Status: resp.StatusCode, Message: body.Error}
+		if len(body.Message) > 0 {
+			var text string
+			if json.Unmarshal(body.Message, &text) == nil {
+				apiErr.Message = text
+			} else {
+				apiErr.Message = string(body.Message)
+			}
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// User 是 issue 或评论的作者
+type User struct {
+	Username string `json:"username"`
+}
+
+// Issue 是一个 issue 或合并请求
+type Issue struct {
+	IID         int    `json:"iid"`
+	Title       string `json:"title"`
+	State       string `json:"state"`
+	Description string `json:"description"`
+	WebURL      string `json:"web_url"`
+	Author      User   `json:"author"`
+}
+
+// Note 是 issue 或合并请求下的评论
+type Note struct {
+	Author    User      `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	// System 表示由 GitLab 生成的活动记录，例如修改标签
+	System bool `json:"system"`
+}
+
+// Kind 是 issue 或合并请求在 API 路径中的名称
+type Kind string
+
+const (
+	Issues        Kind = "issues"
+	MergeRequests Kind = "merge_requests"
+)
+
+// GetIssue 返回项目中编号为 iid 的 issue 或合并请求
+func (c *Client) GetIssue(ctx context.Context, project string, kind Kind, iid int) (*Issue, error) {
+	var issue Issue
+	if err := c.Get(ctx, fmt.Sprintf("/projects/%s/%s/%d", url.PathEscape(project), kind, iid), &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// Notes 返回 issue 或合并请求按时间排序的前 100 条评论，不包括系统记录
+func (c *Client) Notes(ctx context.Context, project string, kind Kind, iid int) ([]Note, error) {
+	var notes []Note
+	if err := c.Get(ctx, fmt.Sprintf("/projects/%s/%s/%d/notes?sort=asc&order_by=created_at&per_page=100", url.PathEscape(project), kind, iid), &notes); err != nil {
+		return nil, err
+	}
+	comments := []Note{}
+	for _, note := range notes {
+		if !note.System {
+			comments = append(comments, note)
+		}
+	}
+	return comments, nil
+}
+
+// RelatedMergeRequests 返回与 issue 关联的合并请求
+func (c *Client) RelatedMergeRequests(ctx context.Context, project string, iid int) ([]Issue, error) {
+	var requests []Issue
+	if err := c.Get(ctx, fmt.Sprintf("/projects/%s/issues/%d/related_merge_requests", url.PathEscape(project), iid), &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// MergeRequestDiff 返回合并请求的统一格式 diff
+func (c *Client) MergeRequestDiff(ctx context.Context, project string, iid int) (string, error) {
+	var mr struct {
+		Changes []struct {
+			OldPath string `json:"old_path"`
+			NewPath string `json:"new_path"`
+			Diff    string `json:"diff"`
+		} `json:"changes"`
+	}
+	if err := c.Get(ctx, fmt.Sprintf("/projects/%s/merge_requests/%d/changes", url.PathEscape(project), iid), &mr); err != nil {
+		return "", err
+	}
+	var diff strings.Builder
+	for _, change := range mr.Changes {
+		fmt.Fprintf(&diff, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n%s", change.OldPath, change.NewPath, change.OldPath, change.NewPath, change.Diff)
+		if !strings.HasSuffix(change.Diff, "\n") {
+			diff.WriteString("\n")
+		}
+	}
+	return diff.String(), nil
+}
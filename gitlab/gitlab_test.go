@@ -0,0 +1,51 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		host    string
+		project string
+	}{
+		{"https 地址", "https://gitlab.com/group/project.git", "gitlab.com", "group/project"},
+		{"子组", "https://gitlab.example.com/group/sub/project", "gitlab.example.com", "group/sub/project"},
+		{"scp 风格地址", "git@gitlab.com:group/project.git", "gitlab.com", "group/project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, project, err := ParseRemote(tt.remote)
+			require.NoError(t, err)
+			assert.Equal(t, tt.host, host)
+			assert.Equal(t, tt.project, project)
+		})
+	}
+
+	t.Run("缺少项目路径", func(t *testing.T) {
+		_, _, err := ParseRemote("https://gitlab.com/project")
+		assert.Error(t, err)
+	})
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "404 Project Not Found"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL}
+	_, err := client.GetIssue(context.Background(), "group/project", Issues, 1)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "GitLab API error 404: 404 Project Not Found", err.Error())
+}
@@ -0,0 +1,200 @@
+package gitutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Repo 表示一个本地 git 仓库
+type Repo struct {
+	Dir string
+}
+
+// Open 打开 dir 所在的 git 仓库，Dir 为仓库根目录
+func Open(dir string) (*Repo, error) {
+	out, err := run(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+	return &Repo{Dir: strings.TrimSpace(out)}, nil
+}
+
+// Run 在仓库根目录执行 git 命令并返回标准输出
+func (r *Repo) Run(args ...string) (string, error) {
+	return run(r.Dir, nil, args...)
+}
+
+func run(dir string, stdin []byte, args ...string) (string, error) {
+	return runEnv(dir, nil, stdin, args...)
+}
+
+// runEnv 与 run 相同，env 中的变量追加到当前环境变量之后
+func runEnv(dir string, env []string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return stdout.String(), nil
+}
+
+// Snapshot 记录工作区中所有未提交文件的内容指纹（路径 -> 内容哈希）
+type Snapshot map[string]string
+
+// Snapshot 生成当前工作区的快照，用于比较一次对话前后的文件变化
+func (r *Repo) Snapshot() (Snapshot, error) {
+	out, err := r.Run("status", "--porcelain=v1", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	snap := Snapshot{}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status, path := entry[:2], entry[3:]
+		// 重命名和复制条目后面紧跟着原路径
+		if status[0] == 'R' || status[0] == 'C' {
+			i++
+		}
+		snap[path] = fingerprint(filepath.Join(r.Dir, path))
+	}
+	return snap, nil
+}
+
+func fingerprint(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "missing"
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChangedPaths 返回两次快照之间发生变化的路径（已排序）
+func ChangedPaths(before, after Snapshot) []string {
+	changed := []string{}
+	for path, hash := range after {
+		if before[path] != hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Add 暂存指定路径（包括删除）
+func (r *Repo) Add(paths ...string) error {
+	_, err := r.Run(append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+// StagedDiff 返回指定路径已暂存的 diff
+func (r *Repo) StagedDiff(paths ...string) (string, error) {
+	return r.Run(append([]string{"diff", "--cached", "--"}, paths...)...)
+}
+
+// Commit 仅提交指定路径，不影响用户已暂存的其他改动
+func (r *Repo) Commit(message string, paths ...string) error {
+	args := append([]string{"commit", "-q", "-F", "-", "--"}, paths...)
+	_, err := run(r.Dir, []byte(message), args...)
+	return err
+}
+
+// CurrentBranch 返回当前分支名，处于分离 HEAD 状态时返回错误
+func (r *Repo) CurrentBranch() (string, error) {
+	out, err := r.Run("symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("not on a branch (detached HEAD)")
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// CreateBranch 从当前提交创建分支并切换过去，未提交的改动保留在工作区
+func (r *Repo) CreateBranch(name string) error {
+	if _, err := r.Run("check-ref-format", "--branch", name); err != nil {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	_, err := r.Run("switch", "-c", name)
+	return err
+}
+
+// RemoteURL 返回远程仓库的地址
+func (r *Repo) RemoteURL(remote string) (string, error) {
+	out, err := r.Run("remote", "get-url", remote)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Push 把分支推送到远程仓库并设置上游分支，env 可以提供认证等 git 配置
+func (r *Repo) Push(remote, branch string, env []string) (string, error) {
+	// git push 的进度信息写在标准错误中，这里只需要结果
+	return runEnv(r.Dir, env, nil, "push", "--porcelain", "-u", remote, "refs/heads/"+branch+":refs/heads/"+branch)
+}
+
+// HasCommits 判断仓库是否已经有提交
+func (r *Repo) HasCommits() bool {
+	_, err := r.Run("rev-parse", "--verify", "-q", "HEAD")
+	return err == nil
+}
+
+// Tracked 判断路径是否在暂存区中，也就是 git 已经跟踪它
+func (r *Repo) Tracked(path string) bool {
+	_, err := r.Run("ls-files", "--error-unmatch", "--", path)
+	return err == nil
+}
+
+// Status 返回 git status --short 的输出，每个未提交的文件一行
+func (r *Repo) Status() (string, error) {
+	return r.Run("status", "--short")
+}
+
+// DiffStat 返回已跟踪文件中未提交的改动相对 HEAD 的统计，还没有提交时统计暂存区
+func (r *Repo) DiffStat() (string, error) {
+	if !r.HasCommits() {
+		return r.Run("diff", "--cached", "--stat")
+	}
+	return r.Run("diff", "HEAD", "--stat")
+}
+
+// RecentCommits 返回最近 n 个提交的短哈希和标题，最新的在前，还没有提交时为空
+func (r *Repo) RecentCommits(n int) ([]string, error) {
+	if !r.HasCommits() {
+		return nil, nil
+	}
+	out, err := r.Run("log", "-n", fmt.Sprint(n), "--format=%h %s")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(out, "\n"), "\n"), nil
+}
@@ -0,0 +1,169 @@
+package gitutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initRepo 创建一个带初始提交的临时仓库
+func initRepo(t *testing.T) *Repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git 不可用")
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+		{"config", "commit.gpgsign", "false"},
+	} {
+		_, err := run(dir, nil, args...)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644))
+	_, err := run(dir, nil, "add", ".")
+	require.NoError(t, err)
+	_, err = run(dir, nil, "commit", "-q", "-m", "init")
+	require.NoError(t, err)
+
+	repo, err := Open(dir)
+	require.NoError(t, err)
+	return repo
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("非仓库目录", func(t *testing.T) {
+		_, err := Open(t.TempDir())
+		assert.Error(t, err)
+	})
+}
+
+func TestSnapshotAndChangedPaths(t *testing.T) {
+	repo := initRepo(t)
+
+	// 用户在对话前已有的改动
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "user.txt"), []byte("mine"), 0644))
+	before, err := repo.Snapshot()
+	require.NoError(t, err)
+	assert.Contains(t, before, "user.txt")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "a.txt"), []byte("changed\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "new.txt"), []byte("new"), 0644))
+	after, err := repo.Snapshot()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a.txt", "new.txt"}, ChangedPaths(before, after))
+}
+
+func TestCommitOnlySelectedPaths(t *testing.T) {
+	repo := initRepo(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "user.txt"), []byte("mine"), 0644))
+	_, err := repo.Run("add", "user.txt")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "a.txt"), []byte("changed\n"), 0644))
+
+	require.NoError(t, repo.Add("a.txt"))
+	diff, err := repo.StagedDiff("a.txt")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+changed")
+
+	require.NoError(t, repo.Commit("feat: change a", "a.txt"))
+
+	files, err := repo.Run("show", "--name-only", "--format=%s", "HEAD")
+	require.NoError(t, err)
+	assert.Contains(t, files, "feat: change a")
+	assert.Contains(t, files, "a.txt")
+	assert.NotContains(t, files, "user.txt")
+
+	// 用户原来暂存的文件仍在暂存区
+	status, err := repo.Run("status", "--porcelain")
+	require.NoError(t, err)
+	assert.Contains(t, status, "A  user.txt")
+}
+
+func TestTracked(t *testing.T) {
+	repo := initRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "new.txt"), []byte("new"), 0644))
+	assert.True(t, repo.Tracked("a.txt"))
+	assert.False(t, repo.Tracked("new.txt"), "未跟踪的文件")
+	require.NoError(t, os.Remove(filepath.Join(repo.Dir, "a.txt")))
+	assert.True(t, repo.Tracked("a.txt"), "删除但还没提交的文件仍然被跟踪")
+}
+
+func TestBranchAndPush(t *testing.T) {
+	repo := initRepo(t)
+	remote := t.TempDir()
+	_, err := run(remote, nil, "init", "-q", "--bare")
+	require.NoError(t, err)
+	_, err = repo.Run("remote", "add", "origin", remote)
+	require.NoError(t, err)
+
+	t.Run("创建并切换分支", func(t *testing.T) {
+		require.NoError(t, repo.CreateBranch("fix/bug"))
+		branch, err := repo.CurrentBranch()
+		require.NoError(t, err)
+		assert.Equal(t, "fix/bug", branch)
+	})
+
+	t.Run("无效的分支名", func(t *testing.T) {
+		assert.Error(t, repo.CreateBranch("bad..name"))
+	})
+
+	t.Run("推送到远程仓库", func(t *testing.T) {
+		url, err := repo.RemoteURL("origin")
+		require.NoError(t, err)
+		assert.Equal(t, remote, url)
+
+		_, err = repo.Push("origin", "fix/bug", nil)
+		require.NoError(t, err)
+		_, err = run(remote, nil, "rev-parse", "--verify", "refs/heads/fix/bug")
+		assert.NoError(t, err)
+	})
+
+	t.Run("分离 HEAD", func(t *testing.T) {
+		_, err := repo.Run("switch", "-q", "--detach")
+		require.NoError(t, err)
+		_, err = repo.CurrentBranch()
+		assert.Error(t, err)
+	})
+}
+
+func TestStatusAndRecentCommits(t *testing.T) {
+	repo := initRepo(t)
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "a.txt"), []byte("a\nb\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.Dir, "new.txt"), []byte("new"), 0644))
+
+	status, err := repo.Status()
+	require.NoError(t, err)
+	assert.Equal(t, " M a.txt\n?? new.txt\n", status)
+	stat, err := repo.DiffStat()
+	require.NoError(t, err)
+	assert.Contains(t, stat, "a.txt | 1 +")
+
+	commits, err := repo.RecentCommits(5)
+	require.NoError(t, err)
+	require.Len(t, commits, 1)
+	assert.Regexp(t, `^[0-9a-f]+ init$`, commits[0])
+
+	t.Run("还没有提交的仓库", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := run(dir, nil, "init", "-q")
+		require.NoError(t, err)
+		empty, err := Open(dir)
+		require.NoError(t, err)
+		assert.False(t, empty.HasCommits())
+		commits, err := empty.RecentCommits(5)
+		require.NoError(t, err)
+		assert.Empty(t, commits)
+		_, err = empty.DiffStat()
+		assert.NoError(t, err)
+	})
+}
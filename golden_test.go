@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// updateGolden 用本次运行的记录覆盖 golden 文件：go test -run TestGoldenTranscripts -update
+var updateGolden = flag.Bool("update", false, "rewrite the golden transcripts in testdata/golden")
+
+// goldenScenario 是 testdata/golden 中的一个剧本：工作区的初始文件、用户依次输入的
+// 提示，以及模型依次返回的响应
+type goldenScenario struct {
+	Files     map[string]string `yaml:"files"`
+	Prompts   []string          `yaml:"prompts"`
+	Responses []struct {
+		Content   string `yaml:"content"`
+		ToolCalls []struct {
+			ID    string      `yaml:"id"`
+			Name  string      `yaml:"name"`
+			Input interface{} `yaml:"input"`
+		} `yaml:"tool_calls"`
+	} `yaml:"responses"`
+}
+
+// TestGoldenTranscripts 用 mock provider 按剧本运行 agent，把每次推理收到的对话和
+// 结束时的工作区记录下来，与 .golden 文件比较
+func TestGoldenTranscripts(t *testing.T) {
+	scenarios, err := filepath.Glob(filepath.Join("testdata", "golden", "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, scenarios)
+
+	for _, path := range scenarios {
+		path, _ := filepath.Abs(path)
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var scenario goldenScenario
+			require.NoError(t, yaml.Unmarshal(data, &scenario))
+
+			got := runGoldenScenario(t, scenario)
+			goldenPath := strings.TrimSuffix(path, ".yaml") + ".golden"
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(goldenPath, []byte(got), 0644))
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			require.NoError(t, err, "用 -update 生成 golden 文件")
+			assert.Equal(t, string(want), got, "记录与 %s 不同；行为变化是有意的话用 -update 更新", goldenPath)
+		})
+	}
+}
+
+// runGoldenScenario 在临时工作区中运行剧本并返回记录
+func runGoldenScenario(t *testing.T, scenario goldenScenario) string {
+	t.Helper()
+	dir := t.TempDir()
+	chdir(t, dir)
+	for name, content := range scenario.Files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+	}
+
+	provider := &mockProvider{}
+	for i, r := range scenario.Responses {
+		response := &Response{Content: r.Content, Model: "mock", Usage: Usage{InputTokens: 10, OutputTokens: 1}}
+		for _, call := range r.ToolCalls {
+			if call.Input == nil {
+				call.Input = map[string]interface{}{}
+			}
+			input, err := json.Marshal(call.Input)
+			require.NoError(t, err, "响应 %d 的工具输入", i+1)
+			response.ToolCalls = append(response.ToolCalls, ToolCall{ID: call.ID, Name: call.Name, Input: input})
+		}
+		provider.responses = append(provider.responses, response)
+	}
+	agent := NewAgent(provider, nil, builtinTools())
+	agent.onEvent = func(AgentEvent) {}
+
+	var b strings.Builder
+	var names []string
+	for _, def := range agent.toolDefinitions() {
+		names = append(names, def.Name)
+	}
+	fmt.Fprintf(&b, "tools: %s\n", strings.Join(names, ", "))
+	// previous 是上一次写出的对话
+	var previous []Message
+	for _, prompt := range scenario.Prompts {
+		start := len(provider.calls)
+		err := agent.runTurn(context.Background(), prompt)
+		fmt.Fprintf(&b, "\n=== turn: %s\n", firstLine(prompt))
+		for i := start; i < len(provider.calls); i++ {
+			writeGoldenConversation(&b, fmt.Sprintf("request %d", i+1), previous, provider.calls[i])
+			previous = provider.calls[i]
+		}
+		writeGoldenConversation(&b, "end of turn", previous, agent.requestConversation())
+		previous = agent.requestConversation()
+		if err != nil {
+			fmt.Fprintf(&b, "\nerror: %s\n", err)
+		}
+	}
+	if left := len(provider.responses); left > 0 {
+		fmt.Fprintf(&b, "\n%d responses were not used\n", left)
+	}
+
+	fmt.Fprintf(&b, "\n=== files\n")
+	var files []string
+	require.NoError(t, filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, filepath.ToSlash(path))
+		}
+		return err
+	}))
+	sort.Strings(files)
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		fmt.Fprintf(&b, "--- %s\n%s", name, data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			b.WriteString("\n")
+		}
+	}
+	return strings.ReplaceAll(b.String(), dir, "$WORKDIR")
+}
+
+// writeGoldenConversation 写出一次推理收到的或者轮次结束时的对话。对话通常只是在
+// 上一次后面追加了消息，这时只写新的消息；之前的消息有变化时写出整个对话。
+func writeGoldenConversation(b *strings.Builder, heading string, previous, conversation []Message) {
+	from := len(previous)
+	if from > len(conversation) || !sameMessages(previous, conversation[:from]) {
+		from = 0
+	}
+	if from == 0 {
+		fmt.Fprintf(b, "\n--- %s: %d messages\n", heading, len(conversation))
+	} else {
+		fmt.Fprintf(b, "\n--- %s: %d messages, %d new\n", heading, len(conversation), len(conversation)-from)
+	}
+	for _, msg := range conversation[from:] {
+		if msg.ToolCall != nil {
+			fmt.Fprintf(b, "[%s %s %s %s]\n", msg.Role, msg.ToolCall.ID, msg.ToolCall.Name, msg.ToolCall.Input)
+		} else {
+			fmt.Fprintf(b, "[%s]\n", msg.Role)
+		}
+		b.WriteString(strings.TrimRight(msg.Content, "\n") + "\n")
+	}
+}
+
+// sameMessages 比较发送给模型的内容，不比较元数据
+func sameMessages(a, b []Message) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Role != b[i].Role || a[i].Content != b[i].Content {
+			return false
+		}
+		if (a[i].ToolCall == nil) != (b[i].ToolCall == nil) {
+			return false
+		}
+		if a[i].ToolCall != nil && (a[i].ToolCall.ID != b[i].ToolCall.ID || string(a[i].ToolCall.Input) != string(b[i].ToolCall.Input)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"agent/agentpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer implements agentpb.AgentServer on top of the HTTP API's
+// sessions, so both APIs see the same sessions and approvals
+type grpcServer struct {
+	agentpb.UnimplementedAgentServer
+	api *apiServer
+}
+
+// newGRPCServer returns a gRPC server for api's sessions. Like the HTTP API,
+// it needs api.token in the authorization metadata ("Bearer <token>") when
+// one is set.
+func newGRPCServer(api *apiServer) *grpc.Server {
+	g := &grpcServer{api: api}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := g.authorize(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := g.authorize(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	agentpb.RegisterAgentServer(server, g)
+	return server
+}
+
+// authorize checks the bearer token in the authorization metadata of ctx
+func (g *grpcServer) authorize(ctx context.Context) error {
+	if g.api.token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(g.api.token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+// errTurnDone stops following a session's events once the turn has ended
+var errTurnDone = errors.New("turn done")
+
+func (g *grpcServer) CreateSession(ctx context.Context, req *agentpb.CreateSessionRequest) (*agentpb.Session, error) {
+	live, err := g.api.live("")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return live.proto(), nil
+}
+
+func (g *grpcServer) GetSession(ctx context.Context, req *agentpb.GetSessionRequest) (*agentpb.Session, error) {
+	live, err := g.session(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	return live.proto(), nil
+}
+
+func (g *grpcServer) SendMessage(req *agentpb.SendMessageRequest, stream agentpb.Agent_SendMessageServer) error {
+	if strings.TrimSpace(req.GetContent()) == "" {
+		return status.Error(codes.InvalidArgument, "content must not be empty")
+	}
+	live, err := g.session(req.GetSessionId())
+	if err != nil {
+		return err
+	}
+	next, err := g.api.startTurn(live, req.GetContent())
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	err = g.api.follow(stream.Context(), live, next, func(index int, e AgentEvent) error {
+		if err := stream.Send(eventProto(e)); err != nil {
+			return err
+		}
+		if e.Type == EventTurnDone {
+			return errTurnDone
+		}
+		return nil
+	})
+	if errors.Is(err, errTurnDone) {
+		return nil
+	}
+	return status.FromContextError(err).Err()
+}
+
+func (g *grpcServer) ApproveTool(ctx context.Context, req *agentpb.ApproveToolRequest) (*agentpb.ApproveToolResponse, error) {
+	live, err := g.session(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	if err := live.decide(req.GetToolCallId(), req.GetApproved()); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &agentpb.ApproveToolResponse{}, nil
+}
+
+func (g *grpcServer) session(id string) (*liveSession, error) {
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+	live, err := g.api.live(id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return live, nil
+}
+
+// proto returns the session with its messages as of the last finished turn
+func (l *liveSession) proto() *agentpb.Session {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	session := &agentpb.Session{Id: l.agent.session.ID, Busy: l.busy}
+	for _, message := range l.messages {
+		session.Messages = append(session.Messages, &agentpb.Message{
+			Role:     message.Role,
+			Content:  message.Content,
+			ToolCall: toolCallProto(message.ToolCall),
+		})
+	}
+	return session
+}
+
+func eventProto(e AgentEvent) *agentpb.Event {
+	event := &agentpb.Event{
+		Type:     e.Type,
+		Time:     timestamppb.New(e.Time),
+		Content:  e.Content,
+		ToolCall: toolCallProto(e.ToolCall),
+		Path:     e.Path,
+		Diff:     e.Diff,
+		Model:    e.Model,
+	}
+	if e.Usage != nil {
+		event.Usage = &agentpb.Usage{InputTokens: e.Usage.InputTokens, OutputTokens: e.Usage.OutputTokens, CachedTokens: e.Usage.CachedTokens}
+	}
+	if e.Timing != nil {
+		// the proto has no timing message; clients get the one-line summary
+		event.Content = e.Timing.String()
+	}
+	return event
+}
+
+func toolCallProto(call *ToolCall) *agentpb.ToolCall {
+	if call == nil {
+		return nil
+	}
+	return &agentpb.ToolCall{Id: call.ID, Name: call.Name, Input: string(call.Input)}
+}
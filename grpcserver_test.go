@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"agent/agentpb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPC 在内存连接上启动 gRPC 服务，返回客户端
+func newTestGRPC(t *testing.T, newAgent func() *Agent, requireApproval bool) agentpb.AgentClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	api := newAPIServer(ctx, &FileSessionStore{Dir: t.TempDir()}, func() (*Agent, error) { return newAgent(), nil })
+	api.requireApproval = requireApproval
+	t.Cleanup(cancel)
+	return dialGRPC(t, api)
+}
+
+// dialGRPC 在内存连接上为 api 启动 gRPC 服务，返回客户端
+func dialGRPC(t *testing.T, api *apiServer) agentpb.AgentClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(api)
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return agentpb.NewAgentClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("设置了 token 时需要在元数据中携带", func(t *testing.T) {
+		api := newAPIServer(ctx, &FileSessionStore{Dir: t.TempDir()}, func() (*Agent, error) { return oneShotAgent(), nil })
+		api.token = "secret"
+		client := dialGRPC(t, api)
+
+		_, err := client.CreateSession(ctx, &agentpb.CreateSessionRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		wrong := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+		_, err = client.CreateSession(wrong, &agentpb.CreateSessionRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		stream, err := client.SendMessage(ctx, &agentpb.SendMessageRequest{SessionId: "x", Content: "hi"})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "流式调用同样检查")
+
+		authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+		session, err := client.CreateSession(authorized, &agentpb.CreateSessionRequest{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, session.Id)
+	})
+
+	t.Run("发送消息并流式接收事件", func(t *testing.T) {
+		client := newTestGRPC(t, oneShotAgent, false)
+		session, err := client.CreateSession(ctx, &agentpb.CreateSessionRequest{})
+		require.NoError(t, err)
+		require.NotEmpty(t, session.Id)
+
+		stream, err := client.SendMessage(ctx, &agentpb.SendMessageRequest{SessionId: session.Id, Content: "模块名是什么？"})
+		require.NoError(t, err)
+		types := []string{}
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				break
+			}
+			types = append(types, event.Type)
+			if event.Type == EventUsage {
+				assert.Positive(t, event.Usage.InputTokens)
+			}
+		}
+		assert.Equal(t, []string{
+			EventUserMessage, EventUsage, EventAssistantText, EventToolCall, EventToolResult,
+			EventUsage, EventAssistantText, EventTiming, EventTurnDone,
+		}, types)
+
+		session, err = client.GetSession(ctx, &agentpb.GetSessionRequest{SessionId: session.Id})
+		require.NoError(t, err)
+		assert.False(t, session.Busy)
+		require.Len(t, session.Messages, 4)
+		assert.Equal(t, "read_file", session.Messages[2].ToolCall.Name)
+		assert.Equal(t, "模块名是 agent", session.Messages[3].Content)
+	})
+
+	t.Run("批准工具调用", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		client := newTestGRPC(t, editAgent, true)
+		session, err := client.CreateSession(ctx, &agentpb.CreateSessionRequest{})
+		require.NoError(t, err)
+
+		stream, err := client.SendMessage(ctx, &agentpb.SendMessageRequest{SessionId: session.Id, Content: "创建文件"})
+		require.NoError(t, err)
+		var last *agentpb.Event
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				break
+			}
+			if event.Type == EventApprovalRequest {
+				_, err := client.ApproveTool(ctx, &agentpb.ApproveToolRequest{SessionId: session.Id, ToolCallId: event.ToolCall.Id, Approved: true})
+				require.NoError(t, err)
+			}
+			last = event
+		}
+		require.NotNil(t, last)
+		assert.Equal(t, EventTurnDone, last.Type)
+		assert.Empty(t, last.Content)
+		assert.FileExists(t, "hello.txt")
+	})
+
+	t.Run("错误的请求返回相应的状态码", func(t *testing.T) {
+		client := newTestGRPC(t, oneShotAgent, false)
+		_, err := client.GetSession(ctx, &agentpb.GetSessionRequest{SessionId: "nope"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = client.GetSession(ctx, &agentpb.GetSessionRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		session, err := client.CreateSession(ctx, &agentpb.CreateSessionRequest{})
+		require.NoError(t, err)
+		_, err = client.ApproveTool(ctx, &agentpb.ApproveToolRequest{SessionId: session.Id, ToolCallId: "1", Approved: true})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		stream, err := client.SendMessage(ctx, &agentpb.SendMessageRequest{SessionId: session.Id, Content: " "})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
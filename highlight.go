@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agent/i18n"
+	"agent/theme"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// highlightCode returns code with ANSI syntax highlighting. hint is a file name
+// or language name; when it matches nothing the language is guessed from the
+// content, and code is returned unchanged if no lexer applies.
+func highlightCode(code, hint string) string {
+	if !theme.Enabled() {
+		return code
+	}
+	lexer := lexerFor(code, hint)
+	if lexer == nil {
+		return code
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return code
+	}
+	var b strings.Builder
+	if err := formatters.TTY256.Format(&b, styles.Get(theme.Current().Code), iterator); err != nil {
+		return code
+	}
+	return b.String()
+}
+
+func lexerFor(code, hint string) chroma.Lexer {
+	if hint != "" {
+		if lexer := lexers.Match(hint); lexer != nil {
+			return lexer
+		}
+		if lexer := lexers.Get(hint); lexer != nil {
+			return lexer
+		}
+	}
+	return lexers.Analyse(code)
+}
+
+// toolPathHint extracts a "path" argument from tool input to pick a lexer
+func toolPathHint(input json.RawMessage) string {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return ""
+	}
+	return args.Path
+}
+
+// printToolResult echoes a tool result, highlighting file contents when enabled
+func (a *Agent) printToolResult(call ToolCall, result string) {
+	hint := toolPathHint(call.Input)
+	if !a.highlight || hint == "" {
+		fmt.Printf("%s: %s\n", theme.Success(i18n.T("Tool Result")), result)
+		return
+	}
+	fmt.Printf("%s: %s\n%s\n", theme.Success(i18n.T("Tool Result")), hint, strings.TrimRight(highlightCode(result, hint), "\n"))
+}
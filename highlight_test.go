@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlightCode(t *testing.T) {
+	code := "package main\n\nfunc main() {}\n"
+
+	t.Run("按文件名选择语言", func(t *testing.T) {
+		out := highlightCode(code, "main.go")
+		assert.Contains(t, out, "\x1b[")
+		assert.Equal(t, code, ansiPattern.ReplaceAllString(out, ""))
+	})
+
+	t.Run("按语言名选择", func(t *testing.T) {
+		out := highlightCode("print('hi')\n", "python")
+		assert.Contains(t, out, "\x1b[")
+	})
+}
+
+func TestToolPathHint(t *testing.T) {
+	assert.Equal(t, "a/b.go", toolPathHint(json.RawMessage(`{"path":"a/b.go"}`)))
+	assert.Equal(t, "", toolPathHint(json.RawMessage(`{"command":"ls"}`)))
+	assert.Equal(t, "", toolPathHint(json.RawMessage(`not json`)))
+}
@@ -0,0 +1,84 @@
+// Package i18n 翻译命令行界面的消息。消息以英文原文为键，
+// 其他语言的目录把原文映射为译文，没有译文的消息显示原文
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 支持的语言
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+// Catalogs 是各语言的消息目录，英文是原文，目录为空
+var Catalogs = map[string]map[string]string{
+	English: {},
+	Chinese: zh,
+}
+
+var current = English
+
+// Languages 返回支持的语言，按字母排序
+func Languages() []string {
+	langs := make([]string, 0, len(Catalogs))
+	for lang := range Catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Use 切换界面语言，lang 为空或 auto 时按环境变量 LC_ALL、LC_MESSAGES 和 LANG 选择
+func Use(lang string) error {
+	if lang == "" || lang == "auto" {
+		lang = Detect(os.Getenv)
+	}
+	if _, ok := Catalogs[lang]; !ok {
+		return fmt.Errorf("unknown language %q (use auto, %s)", lang, strings.Join(Languages(), ", "))
+	}
+	current = lang
+	return nil
+}
+
+// Current 返回当前的界面语言
+func Current() string {
+	return current
+}
+
+// Detect 按 POSIX 的优先顺序从 locale 环境变量推断界面语言，例如 zh_CN.UTF-8 对应中文，
+// 无法识别时使用英文
+func Detect(getenv func(string) string) string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+		lang := strings.ToLower(v)
+		if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+			lang = lang[:i]
+		}
+		if _, ok := Catalogs[lang]; ok {
+			return lang
+		}
+		return English
+	}
+	return English
+}
+
+// T 返回 msg 在当前语言中的译文
+func T(msg string) string {
+	if s, ok := Catalogs[current][msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// Sprintf 用 format 在当前语言中的译文格式化参数
+func Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
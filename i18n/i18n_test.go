@@ -0,0 +1,119 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"没有 locale 时使用英文", nil, English},
+		{"LANG 为中文", map[string]string{"LANG": "zh_CN.UTF-8"}, Chinese},
+		{"LC_ALL 优先于 LANG", map[string]string{"LC_ALL": "en_US.UTF-8", "LANG": "zh_CN.UTF-8"}, English},
+		{"LC_MESSAGES 优先于 LANG", map[string]string{"LC_MESSAGES": "zh_TW", "LANG": "C"}, Chinese},
+		{"不支持的语言使用英文", map[string]string{"LANG": "fr_FR.UTF-8"}, English},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, Detect(func(key string) string { return c.env[key] }))
+		})
+	}
+}
+
+func TestUse(t *testing.T) {
+	defer Use(English)
+
+	require.NoError(t, Use(Chinese))
+	assert.Equal(t, Chinese, Current())
+	assert.Equal(t, "错误", T("Error"))
+	assert.Equal(t, "已切换到档案 work（w）", Sprintf("Switched to profile %s (%s)", "work", "w"))
+
+	t.Run("没有译文时显示原文", func(t *testing.T) {
+		assert.Equal(t, "untranslated", T("untranslated"))
+	})
+
+	t.Run("auto 按环境变量选择", func(t *testing.T) {
+		t.Setenv("LC_ALL", "")
+		t.Setenv("LC_MESSAGES", "")
+		t.Setenv("LANG", "en_GB.UTF-8")
+		require.NoError(t, Use("auto"))
+		assert.Equal(t, English, Current())
+		assert.Equal(t, "Error", T("Error"))
+	})
+
+	t.Run("未知语言", func(t *testing.T) {
+		assert.ErrorContains(t, Use("klingon"), "unknown language")
+	})
+}
+
+var verb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs(t *testing.T) {
+	t.Run("译文保留原文的格式化动词", func(t *testing.T) {
+		for lang, catalog := range Catalogs {
+			for msg, s := range catalog {
+				assert.Equal(t, verb.FindAllString(msg, -1), verb.FindAllString(s, -1), "%s: %q", lang, msg)
+			}
+		}
+	})
+
+	t.Run("命令行中的每条消息都有中文译文", func(t *testing.T) {
+		files, err := filepath.Glob(filepath.Join("..", "*.go"))
+		require.NoError(t, err)
+		fset := token.NewFileSet()
+		for _, path := range files {
+			f, err := parser.ParseFile(fset, path, nil, 0)
+			require.NoError(t, err)
+			ast.Inspect(f, func(n ast.Node) bool {
+				for _, msg := range messages(n) {
+					assert.Contains(t, zh, msg, "%s", fset.Position(n.Pos()))
+				}
+				return true
+			})
+		}
+	})
+}
+
+// messages 返回节点中交给翻译的字符串字面量：i18n.T 和 i18n.Sprintf 的参数，
+// 以及斜杠命令的说明
+func messages(n ast.Node) []string {
+	var lits []ast.Expr
+	switch n := n.(type) {
+	case *ast.CallExpr:
+		sel, ok := n.Fun.(*ast.SelectorExpr)
+		if !ok || len(n.Args) == 0 {
+			return nil
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "i18n" {
+			lits = append(lits, n.Args[0])
+		}
+	case *ast.KeyValueExpr:
+		if key, ok := n.Key.(*ast.Ident); ok && key.Name == "description" {
+			lits = append(lits, n.Value)
+		}
+	}
+	var msgs []string
+	for _, e := range lits {
+		lit, ok := e.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			continue
+		}
+		msg, err := strconv.Unquote(lit.Value)
+		if err == nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 时间格式的简称
+const (
+	TimeISO     = "iso"
+	TimeRFC3339 = "rfc3339"
+)
+
+// timeLayouts 是各语言默认的日期时间格式
+var timeLayouts = map[string]string{
+	English: "2006-01-02 15:04:05",
+	Chinese: "2006年1月2日 15:04:05",
+}
+
+// TimeLayout 把配置的时间格式解析为 Go 的时间格式：为空时使用当前语言的格式，
+// iso 和 rfc3339 是常用格式的简称，其他值本身就是 Go 的时间格式
+func TimeLayout(format string) string {
+	switch format {
+	case "":
+		return timeLayouts[current]
+	case TimeISO:
+		return timeLayouts[English]
+	case TimeRFC3339:
+		return time.RFC3339
+	}
+	return format
+}
+
+// FormatTime 按配置的时间格式显示 t 的本地时间
+func FormatTime(t time.Time, format string) string {
+	return t.Local().Format(TimeLayout(format))
+}
+
+// Ago 用当前语言把 t 显示为相对于 now 的时间，例如 "2m ago"；超过一周时显示日期
+func Ago(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < 10*time.Second:
+		return T("just now")
+	case d < time.Minute:
+		return Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return Sprintf("%dh ago", int(d.Hours()))
+	case d < 7*24*time.Hour:
+		return Sprintf("%dd ago", int(d.Hours()/24))
+	}
+	return t.Local().Format(strings.Fields(timeLayouts[current])[0])
+}
+
+// FormatNumber 用千位分隔符显示整数，例如 12,345
+func FormatNumber(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String()
+}
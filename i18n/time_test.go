@@ -0,0 +1,50 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTime(t *testing.T) {
+	defer Use(English)
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+
+	assert.Equal(t, "2024-05-01 09:30:00", FormatTime(at, ""))
+	assert.Equal(t, "09:30", FormatTime(at, "15:04"), "Go 的时间格式")
+	assert.Equal(t, at.Format(time.RFC3339), FormatTime(at, TimeRFC3339))
+
+	Use(Chinese)
+	assert.Equal(t, "2024年5月1日 09:30:00", FormatTime(at, ""), "默认格式随语言变化")
+	assert.Equal(t, "2024-05-01 09:30:00", FormatTime(at, TimeISO))
+}
+
+func TestAgo(t *testing.T) {
+	defer Use(English)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.Local)
+	cases := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{3 * time.Second, "just now"},
+		{42 * time.Second, "42s ago"},
+		{2*time.Minute + 5*time.Second, "2m ago"},
+		{5 * time.Hour, "5h ago"},
+		{3 * 24 * time.Hour, "3d ago"},
+		{9 * 24 * time.Hour, "2024-05-01"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, Ago(now.Add(-c.ago), now), c.ago.String())
+	}
+
+	Use(Chinese)
+	assert.Equal(t, "2分钟前", Ago(now.Add(-2*time.Minute), now))
+	assert.Equal(t, "2024年5月1日", Ago(now.Add(-9*24*time.Hour), now))
+}
+
+func TestFormatNumber(t *testing.T) {
+	for n, want := range map[int64]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -45678: "-45,678"} {
+		assert.Equal(t, want, FormatNumber(n))
+	}
+}
@@ -0,0 +1,192 @@
+package i18n
+
+// zh 是中文的消息目录
+var zh = map[string]string{
+	// 对话
+	"Chat with Claude/GPT (use 'ctrl-c' to quit, /help for commands)": "与 Claude/GPT 对话（按 ctrl-c 退出，/help 查看命令）",
+	"Using %s":                        "使用 %s",
+	"You":                             "你",
+	"You (queued)":                    "你（排队中）",
+	"Assistant":                       "助手",
+	"Queued":                          "已排队",
+	"Error":                           "错误",
+	"Turn cancelled":                  "本轮已取消",
+	"Interrupted":                     "已中断",
+	"%s (press Ctrl-C again to exit)": "%s（再按一次 Ctrl-C 退出）",
+	"Stopped":                         "已停止",
+	"%s: turn reached the limit of %d inference steps": "%s：本轮达到了 %d 次推理的上限",
+	"Truncated": "已截断",
+	"%s: the reply was cut off by the output token limit; raise max_tokens in the config if this keeps happening": "%s：回复达到了输出 token 上限被截断；经常出现时请在配置中调大 max_tokens",
+	"Refused":                         "已拒绝",
+	"%s: %s":                          "%s：%s",
+	"Turn finished after %s":          "本轮已完成，用时 %s",
+	"Turn failed after %s: %v":        "本轮在 %s 后出错：%v",
+	"Waiting for approval to run %s":  "等待批准运行 %s",
+	"Verification failed":             "检查未通过",
+	"%s: the model declined to reply": "%s：模型拒绝回复",
+	"Session Error":                   "会话错误",
+	"Auto-commit Error":               "自动提交错误",
+	"thinking…":                       "思考中…",
+	"writing commit message…":         "生成提交说明…",
+	"running %s…":                     "运行 %s…",
+	"running %s %s…":                  "运行 %s %s…",
+	"Timing":                          "耗时",
+	"Message (Enter to send, Alt-Enter to steer, Ctrl-T sidebar, Ctrl-C cancel/quit)": "消息（Enter 发送，Alt-Enter 插话，Ctrl-T 侧栏，Ctrl-C 取消/退出）",
+	"Commands are not available while a turn is running":                              "本轮进行中不能使用命令",
+	"working…":      "处理中…",
+	"%d queued":     "%d 条排队中",
+	"Not committed": "未提交",
+	"%s: %s had uncommitted changes before the turn": "%s：%s 在本轮开始前已有未提交的改动",
+	"Committed":         "已提交",
+	"%s: %s (%d files)": "%s：%s（%d 个文件）",
+
+	// 工具
+	"Tool activity":                 "工具活动",
+	"Tool Call":                     "工具调用",
+	"Tool Output":                   "工具输出",
+	"Tool Result":                   "工具结果",
+	"Tool Error":                    "工具错误",
+	"Denied":                        "已拒绝",
+	"Edited":                        "已编辑",
+	"%s: %s finished, output above": "%s：%s 已完成，输出见上方",
+	"Script error":                  "脚本错误",
+	"%s: %v; still using the previous version": "%s：%v；继续使用之前的版本",
+	"Reloaded scripts in %s":                   "已重新加载 %s 中的脚本",
+	"Audit log: %s":                            "审计日志：%s",
+	"on":                                       "开",
+	"off":                                      "关",
+	"Capture the uncommitted changes and recent commits sent to the model again":                      "重新获取发送给模型的未提交改动和最近的提交",
+	"Show or set how the model uses tools at the start of each turn, e.g. none to plan without tools": "显示或设置每轮开始时模型如何使用工具，例如 none 表示只做规划不调用工具",
+	"The model must call a tool at the start of each turn":                                            "每轮开始时模型必须调用工具",
+	"The model answers without calling tools":                                                         "模型不调用工具直接回答",
+	"The model calls %s at the start of each turn":                                                    "每轮开始时模型调用 %s",
+	"The model decides when to call tools":                                                            "由模型决定何时调用工具",
+	"Post-processing failed":                                                                          "回复处理失败",
+	"%s: %v":                                                                                          "%s：%v",
+	"%s: %s: %v":                                                                                      "%s：%s：%v",
+	"Wrote %s":                                                                                        "已写入 %s",
+	"Replies are shown as the model wrote them":                                                       "按模型的原文显示回复",
+	"Replies pass through %s":                                                                         "回复依次经过 %s 处理",
+	"Show or set how replies are processed: strip_thinking, language, extract_code": "显示或设置回复的处理方式：strip_thinking、language、extract_code",
+	"Next request: %s tokens (%s)":        "下一个请求：%s token（%s）",
+	", %.0f%% of the context window (%s)": "，占上下文窗口的 %.0f%%（%s）",
+	"Instructions and context":            "指令和上下文",
+	"Messages":                            "消息",
+	"Tools":                               "工具",
+	"Count the tokens of the next request and show where they go":                    "计算下一个请求的 token 数并显示其构成",
+	"Refreshed the git context sent to the model":                                    "已更新发送给模型的 git 信息",
+	"Not in a git repository; no git context to refresh":                             "不在 git 仓库中，没有可更新的 git 信息",
+	"List pinned files, or pin files so their latest contents are always in context": "列出固定的文件，或固定文件使其最新内容始终在上下文中",
+	"Stop keeping files in context":                                                  "不再把文件保留在上下文中",
+	"No pinned files; pin one with /pin PATH":                                        "没有固定的文件；用 /pin PATH 固定",
+	"Pinned %s":          "已固定 %s",
+	"Unpinned %s":        "已取消固定 %s",
+	"Unpinned all files": "已取消固定所有文件",
+	"Attached %s":        "已附上 %s",
+	"Start a line with ! to run a shell command yourself, or with !! to also attach its output to your next message.": "以 ! 开头的行由你自己执行 shell 命令，以 !! 开头时还会把输出附在下一条消息中。",
+	"The output will be attached to your next message":                                                                "输出将附在你的下一条消息中",
+	"Talk instead of typing and hear the replies read aloud":                                                          "用说话代替打字，并朗读回复",
+	"See the code on screen.":  "代码请看屏幕。",
+	"Listening… pause to send": "正在听…停顿后发送",
+	"Nothing heard":            "没有听到内容",
+	"You (voice)":              "你（语音）",
+	"Voice mode on: press Enter on an empty line to speak; replies are read aloud": "语音模式已开启：在空行按回车开始说话；回复会被朗读",
+	"Voice mode off": "语音模式已关闭",
+	"Show when each message was sent, in the time_format of the config": "显示每条消息的发送时间，格式取配置中的 time_format",
+	"Timestamps %s": "时间戳已%s",
+	"Recovered":     "已恢复",
+	"%s: restored the conversation as it was when the agent stopped (%d messages)": "%s：已恢复智能体停止时的对话（%d 条消息）",
+	"%s was running and may have left changes; check them before going on":         "%s 当时正在运行，可能留下了改动；继续之前请检查",
+	"Show the system prompt section by section, with the tokens each takes":        "逐部分显示系统提示词及各部分的 token 数",
+	"disabled":   "已关闭",
+	"empty":      "空",
+	"~%s tokens": "约 %s 个 token",
+	"Steering":   "插话",
+	"%s: skipped %d tool calls so the model reads your message first": "%s：跳过了 %d 个工具调用，让模型先读你的消息",
+	"just now": "刚刚",
+	"%ds ago":  "%d秒前",
+	"%dm ago":  "%d分钟前",
+	"%dh ago":  "%d小时前",
+	"%dd ago":  "%d天前",
+	"Diagnostics saved to %s; run `agent bug-report` to turn them into an issue report":           "诊断信息已保存到 %s；运行 `agent bug-report` 可生成用于提交 issue 的报告",
+	"Mention @path or @path:10-20 to attach a file or some of its lines; Tab completes the path.": "用 @路径 或 @路径:10-20 附上文件或其中几行；按 Tab 补全路径。",
+	"Not attached": "未附上",
+	"Tool %s enabled for this session (use `agent config set tools.disabled` to keep it)":  "已在本次会话中启用工具 %s（用 `agent config set tools.disabled` 保存设置）",
+	"Tool %s disabled for this session (use `agent config set tools.disabled` to keep it)": "已在本次会话中禁用工具 %s（用 `agent config set tools.disabled` 保存设置）",
+
+	// 回放和监视
+	"Session %s (%d messages, created %s)":           "会话 %s（%d 条消息，创建于 %s）",
+	"Forked from %s at message %d":                   "从 %s 的第 %d 条消息分出",
+	"-- Enter: next, q: quit --":                     "-- Enter：下一条，q：退出 --",
+	"Skipped re-execution: %s is not read-only":      "跳过重新执行：%s 不是只读工具",
+	"Skipped re-execution: unknown tool %s":          "跳过重新执行：未知工具 %s",
+	"Re-executed: error":                             "重新执行：出错",
+	"Re-executed: same result":                       "重新执行：结果相同",
+	"Re-executed: result differs":                    "重新执行：结果不同",
+	"Watching %d files for changes (Ctrl-C to stop)": "正在监视 %d 个文件的改动（按 Ctrl-C 停止）",
+	"%d files changed; running %s":                   "%d 个文件有改动；运行 %s",
+	"%d files changed: %s":                           "%d 个文件有改动：%s",
+	"Tests pass":                                     "测试通过",
+	"Tests fail":                                     "测试失败",
+	"Run the task?":                                  "运行任务吗？",
+
+	// 预算和用量
+	"Budget Warning": "预算提醒",
+	"%s: %.0f%% of the session budget used (%s)": "%s：已使用会话预算的 %.0f%%（%s）",
+	"Budget Exhausted":                           "预算用尽",
+	"%s: %s. Continue anyway?":                   "%s：%s。仍要继续吗？",
+	"%s [y/N]: ":                                 "%s [y/N]：",
+	"Turn stopped: session budget exhausted":     "回合已停止：会话预算已用尽",
+	"Message not sent: session budget exhausted": "消息未发送：会话预算已用尽",
+	"model pending":                              "模型待定",
+	"context":                                    "上下文",
+	"context nearly full":                        "上下文即将用满",
+	"Session usage":                              "会话用量",
+	"Turns":                                      "轮数",
+	"Wall time":                                  "总耗时",
+	"Input tokens":                               "输入 token",
+	"Output tokens":                              "输出 token",
+	"Cached tokens":                              "缓存 token",
+	"Estimated cost":                             "估计费用",
+	"Tool calls":                                 "工具调用",
+
+	// 斜杠命令
+	"Show available commands": "显示可用的命令",
+	"List messages in the current session with their numbers; -v adds time, model and tokens": "列出当前会话中的消息及其编号；-v 同时显示时间、模型和 token",
+	"Search this and saved sessions for text, showing matching messages with context":         "在当前和已保存的会话中搜索文本，显示匹配的消息及其上下文",
+	"No matches for %q": "没有找到 %q",
+	"Continue in a new session that keeps messages 1..N of the current one":                                "在保留当前会话第 1..N 条消息的新会话中继续",
+	"Export the session as Markdown, or JSON when the file ends in .json":                                  "把会话导出为 Markdown，文件以 .json 结尾时导出为 JSON",
+	"Show the model, context usage and session cost":                                                       "显示模型、上下文用量和会话费用",
+	"Show turns, tool calls, tokens, wall time and cost of the session":                                    "显示会话的轮数、工具调用、token、耗时和费用",
+	"Show where each turn's time went: first token, model and tools":                                       "显示每轮的时间花在哪里：首个 token、模型和工具",
+	"List tools, turn one on or off for this session, or show per-tool call stats":                         "列出工具、在本次会话中启用或禁用某个工具，或显示各工具的调用统计",
+	"List config profiles, or switch provider, model and tools to another":                                 "列出配置档案，或切换到另一个档案的 provider、模型和工具",
+	"Show the workspace, or move the session to another directory and load its project config":             "显示工作区，或把会话移到另一个目录并加载其项目配置",
+	"List prompt templates, or fill one in and send it":                                                    "列出提示词模板，或填写一个模板并发送",
+	`End a line with \ to continue it, or wrap a multi-line message in """ ... """.`:                       `在行尾输入 \ 继续下一行，或用 """ ... """ 包裹多行消息。`,
+	"Write the exact provider requests and responses of the last turn to a file (default debug-last.json)": "把上一轮发给 provider 的请求和响应原样写入文件（默认 debug-last.json）",
+	"Forked session %s at message %d into new session %s":                                                  "已从会话 %s 的第 %d 条消息分出新会话 %s",
+	"Turn timings are %s":              "每轮耗时显示已%s",
+	"Turn timings %s":                  "每轮耗时显示已%s",
+	"Edit review is %s":                "逐块审查修改已%s",
+	"updating the knowledge base…":     "正在更新知识库…",
+	"Knowledge base error":             "知识库错误",
+	"Updated the knowledge base in %s": "已更新知识库 %s",
+	"The knowledge base is empty; it is written when a session ends":                                    "知识库是空的；会话结束时会写入",
+	"Nothing new to add to the knowledge base":                                                          "没有需要加入知识库的新内容",
+	"Show what the agent learned about this repository in earlier sessions, or update it from this one": "显示之前的会话中对这个仓库了解到的内容，或根据本次会话更新",
+	"Edit review %s": "逐块审查修改已%s",
+	"Review each hunk of the model's file edits before they are written":                                  "在写入之前逐块审查模型对文件的修改",
+	"Proposed edit to %s (%d hunks)":                                                                      "对 %s 的修改（%d 处）",
+	"Apply this hunk (%d/%d)? [y,n,a,d]: ":                                                                "应用这处修改（%d/%d）？[y,n,a,d]：",
+	"y: apply this hunk, n: skip it, a: apply this and all later hunks, d: skip this and all later hunks": "y：应用这处，n：跳过这处，a：应用这处和之后所有修改，d：跳过这处和之后所有修改",
+	"No profiles configured; add a profiles section to config.yaml":                                       "没有配置档案；在 config.yaml 中添加 profiles 部分",
+	"Switched to profile %s (%s)":                                                                         "已切换到档案 %s（%s）",
+	"Workspace: %s":                                                                                       "工作区：%s",
+	"Ignoring %s in %s until you trust the project with: agent config trust":                              "已忽略 %s（%s），信任项目后才会生效：agent config trust",
+	"Auto-commit disabled: %s":                                                                            "自动提交已关闭：%s",
+	"No prompt templates; add <name>.md files to %s":                                                      "没有提示词模板；在 %s 中添加 <名称>.md 文件",
+	"No provider requests in the last turn":                                                               "上一轮没有发给 provider 的请求",
+	"Wrote %d provider requests of the last turn to %s":                                                   "已把上一轮的 %d 个 provider 请求写入 %s",
+}
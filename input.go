@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"agent/i18n"
+	"agent/pkg/message"
+	"agent/theme"
+)
+
+// inputQueue reads user input in the background so the user can keep typing
+// while a turn is running; lines typed during a turn are queued and delivered
+// to the model at the next inference step.
+type inputQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []string
+	closed  bool
+	busy    bool
+}
+
+func newInputQueue(getUserMessage func() (string, bool)) *inputQueue {
+	q := &inputQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	go q.read(getUserMessage)
+	return q
+}
+
+func (q *inputQueue) read(getUserMessage func() (string, bool)) {
+	for {
+		line, ok := getUserMessage()
+
+		q.mu.Lock()
+		if !ok {
+			q.closed = true
+			q.cond.Broadcast()
+			q.mu.Unlock()
+			return
+		}
+		q.pending = append(q.pending, line)
+		if text, ok := steeringText(line); q.busy && ok {
+			fmt.Println(theme.Muted(i18n.T("Steering") + ": " + text))
+		} else if q.busy {
+			fmt.Println(theme.Muted(i18n.T("Queued") + ": " + line))
+		}
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// Next blocks until a line is available; it reports false once input is exhausted
+func (q *inputQueue) Next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.pending) == 0 {
+		return "", false
+	}
+	line := q.pending[0]
+	q.pending = q.pending[1:]
+	return line, true
+}
+
+// Drain removes and returns the queued lines accepted by take, without blocking
+func (q *inputQueue) Drain(take func(line string) bool) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	taken, kept := []string{}, []string{}
+	for _, line := range q.pending {
+		if take(line) {
+			taken = append(taken, line)
+		} else {
+			kept = append(kept, line)
+		}
+	}
+	q.pending = kept
+	return taken
+}
+
+// SetBusy marks whether a turn is running, so new lines are announced as queued
+func (q *inputQueue) SetBusy(busy bool) {
+	q.mu.Lock()
+	q.busy = busy
+	q.mu.Unlock()
+}
+
+// readInput returns the next line from the input queue, or reads directly when no queue is running
+func (a *Agent) readInput() (string, bool) {
+	if a.input == nil {
+		return a.getUserMessage()
+	}
+	return a.input.Next()
+}
+
+// askYesNo is the REPL's confirm: it prints question and reads the answer
+// from the input queue
+func (a *Agent) askYesNo(ctx context.Context, question string) bool {
+	fmt.Print(i18n.Sprintf("%s [y/N]: ", question))
+	answer, ok := a.readInput()
+	if !ok || ctx.Err() != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Pending reports whether a queued line is accepted by take
+func (q *inputQueue) Pending(take func(line string) bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, line := range q.pending {
+		if take(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// isQueuedMessage reports whether a line typed during a turn is a message
+// for the model rather than a slash command or a !command
+func isQueuedMessage(line string) bool {
+	_, _, escape := parseShellEscape(line)
+	return strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "/") && !escape
+}
+
+// drainQueuedInput appends messages typed or steered during the turn to the
+// conversation; queued slash commands and !commands stay in the queue and
+// run at the next prompt
+func (a *Agent) drainQueuedInput() {
+	var lines []string
+	if a.input != nil {
+		for _, line := range a.input.Drain(isQueuedMessage) {
+			if text, ok := steeringText(line); ok {
+				if text == "" {
+					continue
+				}
+				line = text
+			}
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, a.takeSteering()...)
+	for _, line := range lines {
+		a.emit(AgentEvent{Type: EventUserMessage, Content: line})
+		a.appendMessages(Message{Role: "user", Content: line, Meta: message.Now()})
+	}
+}
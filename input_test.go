@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedInput 返回依次产生给定输入的 getUserMessage 函数
+func scriptedInput(lines ...string) func() (string, bool) {
+	return func() (string, bool) {
+		if len(lines) == 0 {
+			return "", false
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, true
+	}
+}
+
+func TestInputQueue(t *testing.T) {
+	q := newInputQueue(scriptedInput("one", "/help", "two"))
+
+	first, ok := q.Next()
+	require.True(t, ok)
+	assert.Equal(t, "one", first)
+
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.closed
+	}, time.Second, time.Millisecond)
+
+	taken := q.Drain(func(line string) bool { return line != "/help" })
+	assert.Equal(t, []string{"two"}, taken)
+
+	next, ok := q.Next()
+	require.True(t, ok)
+	assert.Equal(t, "/help", next)
+
+	_, ok = q.Next()
+	assert.False(t, ok)
+}
+
+func TestRunTurnDeliversQueuedMessages(t *testing.T) {
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{{ID: "1", Name: "missing_tool", Input: json.RawMessage(`{}`)}}},
+		{Content: "done"},
+	}}
+	agent := NewAgent(provider, nil, nil)
+	agent.input = newInputQueue(scriptedInput("also check the tests", "/history", "!git status"))
+	require.Eventually(t, func() bool {
+		agent.input.mu.Lock()
+		defer agent.input.mu.Unlock()
+		return agent.input.closed
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, agent.runTurn(context.Background(), "fix the bug"))
+	require.Len(t, provider.calls, 2)
+
+	first := provider.calls[0]
+	assert.Equal(t, "also check the tests", first[len(first)-1].Content)
+
+	// 排队的命令留到下一个提示符执行
+	line, ok := agent.input.Next()
+	require.True(t, ok)
+	assert.Equal(t, "/history", line)
+	line, ok = agent.input.Next()
+	require.True(t, ok)
+	assert.Equal(t, "!git status", line, "!命令也不发给模型")
+}
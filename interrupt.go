@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"agent/i18n"
+	"agent/theme"
+)
+
+// interruptHandler turns Ctrl-C into cancellation of the in-flight turn;
+// a Ctrl-C while no turn is running (or a second one) exits the program.
+type interruptHandler struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+
+	// onExit runs before exiting, e.g. to save the session
+	onExit func()
+	exit   func(code int)
+}
+
+func newInterruptHandler(onExit func()) *interruptHandler {
+	return &interruptHandler{onExit: onExit, exit: os.Exit}
+}
+
+// Listen handles signals until the channel is closed
+func (h *interruptHandler) Listen(signals <-chan os.Signal) {
+	for range signals {
+		h.interrupt()
+	}
+}
+
+func (h *interruptHandler) interrupt() {
+	h.mu.Lock()
+	cancel := h.cancel
+	h.cancel = nil
+	h.mu.Unlock()
+
+	if cancel != nil {
+		fmt.Printf("\n%s\n", i18n.Sprintf("%s (press Ctrl-C again to exit)", theme.Warning(i18n.T("Interrupted"))))
+		cancel()
+		return
+	}
+
+	fmt.Println()
+	if h.onExit != nil {
+		h.onExit()
+	}
+	h.exit(0)
+}
+
+// BeginTurn returns a context that is cancelled by the next Ctrl-C; call done when the turn ends
+func (h *interruptHandler) BeginTurn(ctx context.Context) (context.Context, func()) {
+	turnCtx, cancel := context.WithCancel(ctx)
+	if h == nil {
+		return turnCtx, cancel
+	}
+
+	h.mu.Lock()
+	h.cancel = cancel
+	h.mu.Unlock()
+
+	return turnCtx, func() {
+		h.mu.Lock()
+		h.cancel = nil
+		h.mu.Unlock()
+		cancel()
+	}
+}
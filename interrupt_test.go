@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterruptHandler(t *testing.T) {
+	exited := -1
+	saved := false
+	h := newInterruptHandler(func() { saved = true })
+	h.exit = func(code int) { exited = code }
+
+	t.Run("第一次中断取消当前轮次", func(t *testing.T) {
+		ctx, done := h.BeginTurn(context.Background())
+		defer done()
+
+		h.interrupt()
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+		assert.Equal(t, -1, exited)
+		assert.False(t, saved)
+
+		// 同一轮次中的第二次中断退出程序
+		h.interrupt()
+		assert.Equal(t, 0, exited)
+		assert.True(t, saved)
+	})
+
+	t.Run("空闲时中断直接退出", func(t *testing.T) {
+		exited, saved = -1, false
+		_, done := h.BeginTurn(context.Background())
+		done()
+
+		h.interrupt()
+		assert.Equal(t, 0, exited)
+		assert.True(t, saved)
+	})
+
+	t.Run("通过信号通道触发", func(t *testing.T) {
+		exited = -1
+		signals := make(chan os.Signal, 1)
+		signals <- os.Interrupt
+		close(signals)
+		h.Listen(signals)
+		assert.Equal(t, 0, exited)
+	})
+
+	t.Run("nil处理器仍返回可用的上下文", func(t *testing.T) {
+		var nilHandler *interruptHandler
+		ctx, done := nilHandler.BeginTurn(context.Background())
+		assert.NoError(t, ctx.Err())
+		done()
+		assert.Error(t, ctx.Err())
+	})
+}
+
+// interruptingProvider 模拟推理过程中用户按下 Ctrl-C
+type interruptingProvider struct {
+	handler *interruptHandler
+}
+
+func (p *interruptingProvider) RunInference(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
+	p.handler.interrupt()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRunRecoversFromInterruptedTurn(t *testing.T) {
+	inputs := []string{"hello"}
+	getUserMessage := func() (string, bool) {
+		if len(inputs) == 0 {
+			return "", false
+		}
+		input := inputs[0]
+		inputs = inputs[1:]
+		return input, true
+	}
+
+	handler := newInterruptHandler(nil)
+	handler.exit = func(int) { t.Fatal("不应退出") }
+	agent := NewAgent(&interruptingProvider{handler: handler}, getUserMessage, nil)
+	agent.interrupts = handler
+
+	require.NoError(t, agent.Run(context.Background()))
+	assert.Empty(t, agent.conversation, "被取消的轮次不应留在对话中")
+}
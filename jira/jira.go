@@ -0,0 +1,154 @@
+// Package jira 是 Jira REST API（v2）的一个小客户端，只包含代理需要的接口
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Client 调用站点 BaseURL（例如 https://example.atlassian.net）的 Jira REST API。
+// Email 不为空时用邮箱和 API token 做基本认证（Jira Cloud），否则把 Token 作为个人访问令牌（Jira Server/Data Center）
+type Client struct {
+	BaseURL string
+	Email   string
+	Token   string
+	// HTTP 为空时使用带超时的默认客户端
+	HTTP *http.Client
+}
+
+// APIError 是 API 返回的错误
+type APIError struct {
+	Status   int
+	Messages []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Jira API error %d: %s", e.Status, strings.Join(e.Messages, "; "))
+}
+
+// Do 发送请求，body 不为 nil 时编码为 JSON，响应解码到 out（可以为 nil）
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case c.Email != "":
+		req.SetBasicAuth(c.Email, c.Token)
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		// 错误分为整体的 errorMessages 和按字段的 errors
+		var body struct {
+			ErrorMessages []string          `json:"errorMessages"`
+			Errors        map[string]string `json:"errors"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(data, &body)
+		apiErr := &APIError{Status: resp.StatusCode, Messages: body.ErrorMessages}
+		fields := make([]string, 0, len(body.Errors))
+		for field := range body.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			apiErr.Messages = append(apiErr.Messages, field+": "+body.Errors[field])
+		}
+		if len(apiErr.Messages) == 0 {
+			apiErr.Messages = []string{http.StatusText(resp.StatusCode)}
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// User 是 issue 的经办人、报告人或评论的作者
+type User struct {
+	DisplayName string `json:"displayName"`
+}
+
+// Comment 是 issue 下的评论，Created 是 Jira 格式的时间（2006-01-02T15:04:05.000-0700）
+type Comment struct {
+	ID      string `json:"id"`
+	Author  User   `json:"author"`
+	Body    string `json:"body"`
+	Created string `json:"created"`
+}
+
+// Issue 是一个 issue，Description 是 Jira 的 wiki 标记文本
+type Issue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Status      struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		IssueType struct {
+			Name string `json:"name"`
+		} `json:"issuetype"`
+		Assignee *User `json:"assignee"`
+		Reporter *User `json:"reporter"`
+		Comment  struct {
+			Comments []Comment `json:"comments"`
+		} `json:"comment"`
+	} `json:"fields"`
+}
+
+// BrowseURL 返回 issue 在网页中的地址
+func (c *Client) BrowseURL(key string) string {
+	return strings.TrimRight(c.BaseURL, "/") + "/browse/" + key
+}
+
+// GetIssue 返回 issue 及其评论
+func (c *Client) GetIssue(ctx context.Context, key string) (*Issue, error) {
+	var issue Issue
+	path := fmt.Sprintf("/rest/api/2/issue/%s?fields=summary,description,status,issuetype,assignee,reporter,comment", url.PathEscape(key))
+	if err := c.Do(ctx, http.MethodGet, path, nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// AddComment 在 issue 下发表评论
+func (c *Client) AddComment(ctx context.Context, key, body string) (*Comment, error) {
+	var comment Comment
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/rest/api/2/issue/%s/comment", url.PathEscape(key)), map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
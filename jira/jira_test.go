@@ -0,0 +1,48 @@
+package jira
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+		w.Write([]byte(`{"key": "PROJ-1"}`))
+	}))
+	defer server.Close()
+
+	t.Run("Jira Cloud 使用邮箱和 API token", func(t *testing.T) {
+		client := &Client{BaseURL: server.URL, Email: "me@example.com", Token: "secret"}
+		_, err := client.GetIssue(context.Background(), "PROJ-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Basic bWVAZXhhbXBsZS5jb206c2VjcmV0", header)
+	})
+
+	t.Run("没有邮箱时使用个人访问令牌", func(t *testing.T) {
+		client := &Client{BaseURL: server.URL, Token: "secret"}
+		_, err := client.GetIssue(context.Background(), "PROJ-1")
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer secret", header)
+	})
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errorMessages": [], "errors": {"comment": "Comment body can not be empty!"}}`))
+	}))
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL}
+	_, err := client.AddComment(context.Background(), "PROJ-1", "")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "Jira API error 400: comment: Comment body can not be empty!", err.Error())
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent/i18n"
+	"agent/theme"
+)
+
+// Journal entry types
+const (
+	// journalBegin starts the journal; Base is the number of saved messages
+	// it continues from
+	journalBegin = "begin"
+	// journalMessage appends Messages to the conversation
+	journalMessage = "message"
+	// journalTruncate drops the messages after the first Base
+	journalTruncate     = "truncate"
+	journalToolStarted  = "tool_started"
+	journalToolFinished = "tool_finished"
+)
+
+// journalEntry is a line of a session's journal
+type journalEntry struct {
+	Time     time.Time  `json:"time"`
+	Type     string     `json:"type"`
+	Base     int        `json:"base,omitempty"`
+	Created  *time.Time `json:"created,omitempty"`
+	Messages []Message  `json:"messages,omitempty"`
+	ToolCall *ToolCall  `json:"tool_call,omitempty"`
+}
+
+// sessionJournal writes every change to the conversation to disk as it
+// happens, one JSON line each, until the session is saved again. After a
+// crash mid-turn, resuming the session replays its journal, so only the
+// tool running at the time is lost rather than the whole turn.
+type sessionJournal struct {
+	dir string
+
+	mu sync.Mutex
+	// file is the open journal of session, nil before the first change
+	// since the session was saved
+	file    *os.File
+	session string
+}
+
+// journalDir is where the journals of unsaved changes are kept
+func journalDir() (string, error) {
+	dir, err := agentConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal"), nil
+}
+
+// defaultSessionJournal keeps journals in the config directory
+func defaultSessionJournal() (*sessionJournal, error) {
+	dir, err := journalDir()
+	if err != nil {
+		return nil, err
+	}
+	return &sessionJournal{dir: dir}, nil
+}
+
+func (j *sessionJournal) path(id string) string {
+	return filepath.Join(j.dir, id+".jsonl")
+}
+
+// write appends e to the journal of session, which had base messages when it
+// was last saved. A nil journal writes nothing; failures are returned for
+// logging, since the turn goes on without the journal.
+func (j *sessionJournal) write(session *Session, base int, e journalEntry) error {
+	if j == nil || session == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil && j.session != session.ID {
+		j.file.Close()
+		j.file = nil
+	}
+	if j.file == nil {
+		if err := os.MkdirAll(j.dir, 0700); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(j.path(session.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		j.file, j.session = file, session.ID
+		if info, err := file.Stat(); err == nil && info.Size() == 0 {
+			if err := j.writeLine(journalEntry{Time: time.Now(), Type: journalBegin, Base: base, Created: &session.CreatedAt}); err != nil {
+				return err
+			}
+		}
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	return j.writeLine(e)
+}
+
+// writeLine writes an entry and syncs it, so it survives a crash of the machine too
+func (j *sessionJournal) writeLine(e journalEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// clear removes the journal of session once it has been saved
+func (j *sessionJournal) clear(id string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil && j.session == id {
+		j.file.Close()
+		j.file = nil
+	}
+	if err := os.Remove(j.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// journalChange records a change to the conversation, which had n messages
+// before it, when the session is persisted; a.mu is held
+func (a *Agent) journalChange(n int, e journalEntry) {
+	if a.store == nil {
+		return
+	}
+	if err := a.journal.write(a.session, n, e); err != nil {
+		a.log().Warn("session journal not written", "error", err)
+	}
+}
+
+// journalEvent records when tools start and finish, to tell after a crash
+// which tool was interrupted
+func (a *Agent) journalEvent(e AgentEvent) {
+	if a.journal == nil || a.store == nil || e.ToolCall == nil {
+		return
+	}
+	entry := journalEntry{Time: e.Time, ToolCall: e.ToolCall}
+	switch e.Type {
+	case EventToolCall:
+		entry.Type = journalToolStarted
+	case EventToolResult, EventFileEdit, EventToolError:
+		entry.Type = journalToolFinished
+	default:
+		return
+	}
+	if err := a.journal.write(a.session, a.conversationLen(), entry); err != nil {
+		a.log().Warn("session journal not written", "error", err)
+	}
+}
+
+// journalRecovery describes what replaying a journal restored
+type journalRecovery struct {
+	// Messages is the length of the restored conversation
+	Messages int
+	// Interrupted are the tools that were running when the agent stopped;
+	// they may have changed files without their result being recorded
+	Interrupted []ToolCall
+}
+
+// exists reports whether session has a journal of unsaved changes
+func (j *sessionJournal) exists(id string) bool {
+	if j == nil {
+		return false
+	}
+	_, err := os.Stat(j.path(id))
+	return err == nil
+}
+
+// replay applies the journal of session to it, if there is one. A session
+// that was never saved is passed as nil and created from the journal. The
+// replay stops at the first incomplete line, the one being written when the
+// agent stopped, so the conversation ends at the last consistent point.
+func (j *sessionJournal) replay(id string, session *Session) (*Session, journalRecovery, error) {
+	var recovery journalRecovery
+	if j == nil {
+		return session, recovery, nil
+	}
+	file, err := os.Open(j.path(id))
+	if os.IsNotExist(err) {
+		return session, recovery, nil
+	}
+	if err != nil {
+		return session, recovery, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var messages []Message
+	running := map[string]ToolCall{}
+	order := []string{}
+	began := false
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			break
+		}
+		if !began {
+			if e.Type != journalBegin {
+				return session, recovery, fmt.Errorf("journal of session %s does not start with its base", id)
+			}
+			began = true
+			if session == nil {
+				session = NewSession()
+				session.ID = id
+				if e.Created != nil {
+					session.CreatedAt = *e.Created
+				}
+			}
+			if e.Base > len(session.Messages) {
+				return session, recovery, fmt.Errorf("journal of session %s continues from %d messages, but the session has %d", id, e.Base, len(session.Messages))
+			}
+			messages = append([]Message{}, session.Messages[:e.Base]...)
+			continue
+		}
+		switch e.Type {
+		case journalMessage:
+			messages = append(messages, e.Messages...)
+		case journalTruncate:
+			if e.Base < len(messages) {
+				messages = messages[:e.Base]
+			}
+		case journalToolStarted:
+			if e.ToolCall != nil {
+				running[e.ToolCall.ID] = *e.ToolCall
+				order = append(order, e.ToolCall.ID)
+			}
+		case journalToolFinished:
+			if e.ToolCall != nil {
+				delete(running, e.ToolCall.ID)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return session, recovery, err
+	}
+	if !began {
+		return session, recovery, nil
+	}
+	for _, callID := range order {
+		if call, ok := running[callID]; ok {
+			recovery.Interrupted = append(recovery.Interrupted, call)
+			delete(running, callID)
+		}
+	}
+	recovery.Messages = len(messages)
+	session.Messages = messages
+	return session, recovery, nil
+}
+
+// loadSession loads a saved session and replays its journal, so a session
+// the agent stopped in mid-turn continues from the last change it made. The
+// recovered session is saved right away and the journal removed; recovery
+// is nil when there was nothing to recover.
+func (a *Agent) loadSession(id string) (*Session, *journalRecovery, error) {
+	session, loadErr := a.store.Load(id)
+	if !a.journal.exists(id) {
+		return session, nil, loadErr
+	}
+	if loadErr != nil {
+		// the agent stopped before the session was first saved
+		session = nil
+	}
+	recovered, recovery, err := a.journal.replay(id, session)
+	if err != nil {
+		a.log().Warn("session journal not replayed", "session", id, "error", err)
+		return session, nil, loadErr
+	}
+	if recovered == nil {
+		return nil, nil, loadErr
+	}
+	if err := a.store.Save(recovered); err != nil {
+		return nil, nil, err
+	}
+	if err := a.journal.clear(id); err != nil {
+		a.log().Warn("session journal not removed", "session", id, "error", err)
+	}
+	return recovered, &recovery, nil
+}
+
+// journalNotice tells the user what was recovered after the agent stopped
+func journalNotice(recovery *journalRecovery) string {
+	notice := i18n.Sprintf("%s: restored the conversation as it was when the agent stopped (%d messages)", theme.Warning(i18n.T("Recovered")), recovery.Messages)
+	for _, call := range recovery.Interrupted {
+		notice += "\n" + i18n.Sprintf("%s was running and may have left changes; check them before going on", call.Name)
+	}
+	return notice
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionJournal(t *testing.T) {
+	newAgent := func(t *testing.T, store SessionStore, journal *sessionJournal, provider AIProvider) *Agent {
+		agent := NewAgent(provider, nil, builtinTools())
+		agent.store = store
+		agent.journal = journal
+		agent.onEvent = func(AgentEvent) {}
+		return agent
+	}
+
+	t.Run("轮次中途停止后恢复到最后一条消息", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		require.NoError(t, os.WriteFile("notes.txt", []byte("hello"), 0644))
+		store := &FileSessionStore{Dir: t.TempDir()}
+		journal := &sessionJournal{dir: t.TempDir()}
+
+		agent := newAgent(t, store, journal, &mockProvider{responses: []*Response{{Content: "Hi."}}})
+		require.NoError(t, agent.runTurn(context.Background(), "hi"))
+		require.NoError(t, agent.saveSession())
+		assert.False(t, journal.exists(agent.session.ID), "保存后删除日志")
+
+		// 第二轮读完文件后模型出错，会话没有保存，就像进程在这里崩溃
+		agent.provider = &mockProvider{responses: []*Response{
+			{Content: "Reading.", ToolCalls: []ToolCall{{ID: "1", Name: "read_file", Input: json.RawMessage(`{"path":"notes.txt"}`)}}},
+		}}
+		assert.Error(t, agent.runTurn(context.Background(), "read notes.txt"))
+		id := agent.session.ID
+		require.True(t, journal.exists(id))
+
+		resumed := newAgent(t, store, &sessionJournal{dir: journal.dir}, nil)
+		session, recovery, err := resumed.loadSession(id)
+		require.NoError(t, err)
+		require.NotNil(t, recovery)
+		assert.Equal(t, 5, recovery.Messages)
+		assert.Empty(t, recovery.Interrupted)
+		require.Len(t, session.Messages, 5)
+		assert.Equal(t, "read notes.txt", session.Messages[2].Content)
+		assert.Equal(t, "read_file", session.Messages[4].ToolCall.Name)
+
+		saved, err := store.Load(id)
+		require.NoError(t, err)
+		assert.Len(t, saved.Messages, 5, "恢复的会话立即保存")
+		assert.False(t, journal.exists(id))
+	})
+
+	t.Run("取消的轮次被截掉", func(t *testing.T) {
+		store := &FileSessionStore{Dir: t.TempDir()}
+		journal := &sessionJournal{dir: t.TempDir()}
+		agent := newAgent(t, store, journal, nil)
+		agent.appendMessages(Message{Role: "user", Content: "a"}, Message{Role: "assistant", Content: "b"})
+		require.NoError(t, agent.saveSession())
+		agent.appendMessages(Message{Role: "user", Content: "c"})
+		agent.truncateConversation(2)
+		agent.appendMessages(Message{Role: "user", Content: "d"})
+
+		session, recovery, err := agent.loadSession(agent.session.ID)
+		require.NoError(t, err)
+		require.NotNil(t, recovery)
+		require.Len(t, session.Messages, 3)
+		assert.Equal(t, "d", session.Messages[2].Content)
+	})
+
+	t.Run("正在运行的工具和写了一半的行", func(t *testing.T) {
+		store := &FileSessionStore{Dir: t.TempDir()}
+		journal := &sessionJournal{dir: t.TempDir()}
+		agent := newAgent(t, store, journal, nil)
+		call := &ToolCall{ID: "7", Name: "bash", Input: json.RawMessage(`{"command":"make"}`)}
+		agent.appendMessages(Message{Role: "user", Content: "build it"})
+		agent.emit(AgentEvent{Type: EventToolCall, ToolCall: call})
+		file, err := os.OpenFile(journal.path(agent.session.ID), os.O_APPEND|os.O_WRONLY, 0600)
+		require.NoError(t, err)
+		file.WriteString(`{"type":"message","messages":[{"role":"us`)
+		file.Close()
+
+		session, recovery, err := agent.loadSession(agent.session.ID)
+		require.NoError(t, err, "从未保存的会话也从日志恢复")
+		require.NotNil(t, recovery)
+		assert.Equal(t, agent.session.ID, session.ID)
+		assert.Equal(t, agent.session.CreatedAt.Unix(), session.CreatedAt.Unix())
+		require.Len(t, session.Messages, 1)
+		require.Len(t, recovery.Interrupted, 1)
+		assert.Equal(t, "bash", recovery.Interrupted[0].Name)
+		assert.Contains(t, journalNotice(recovery), "bash was running")
+	})
+
+	t.Run("没有日志时照常加载", func(t *testing.T) {
+		store := &FileSessionStore{Dir: t.TempDir()}
+		agent := newAgent(t, store, &sessionJournal{dir: t.TempDir()}, nil)
+		require.NoError(t, agent.saveSession())
+		session, recovery, err := agent.loadSession(agent.session.ID)
+		require.NoError(t, err)
+		assert.Nil(t, recovery)
+		assert.Equal(t, agent.session.ID, session.ID)
+
+		_, _, err = agent.loadSession("missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("与会话对不上的日志被忽略", func(t *testing.T) {
+		store := &FileSessionStore{Dir: t.TempDir()}
+		journal := &sessionJournal{dir: t.TempDir()}
+		agent := newAgent(t, store, journal, nil)
+		require.NoError(t, agent.saveSession())
+		require.NoError(t, os.WriteFile(filepath.Join(journal.dir, agent.session.ID+".jsonl"), []byte(`{"type":"begin","base":3}`+"\n"), 0600))
+		session, recovery, err := agent.loadSession(agent.session.ID)
+		require.NoError(t, err)
+		assert.Nil(t, recovery)
+		assert.Empty(t, session.Messages)
+	})
+
+	t.Run("没有会话存储时不写日志", func(t *testing.T) {
+		journal := &sessionJournal{dir: t.TempDir()}
+		agent := newAgent(t, nil, journal, nil)
+		agent.appendMessages(Message{Role: "user", Content: "a"})
+		entries, _ := os.ReadDir(journal.dir)
+		assert.Empty(t, entries)
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/theme"
+)
+
+// knowledgeFile holds what the agent learned about a repository across
+// sessions, relative to the project root; it is plain Markdown so it can be
+// reviewed, edited and committed like any other doc
+var knowledgeFile = filepath.Join(".agent", "knowledge.md")
+
+const (
+	// defaultKnowledgeBytes is the size the notes are kept under unless the
+	// config sets knowledge.max_bytes
+	defaultKnowledgeBytes = 16 * 1024
+	// maxLearnTranscript is how much of the session, from its end, the
+	// summarization pass reads
+	maxLearnTranscript = 64 * 1024
+	// maxLearnToolResult is how much of each tool result it reads
+	maxLearnToolResult = 300
+	// noKnowledgeChanges is the reply that leaves the notes as they are
+	noKnowledgeChanges = "NO CHANGES"
+)
+
+const learnPrompt = `You maintain the knowledge base of a code repository: notes that help a coding
+agent work on it in future sessions. Below are the current notes and the
+transcript of a session that just ended.
+
+Update the notes with what this session taught that will still be true and
+useful later: architecture (where things live, how parts fit together), decisions
+and their reasons, conventions, and gotchas (commands that fail, traps, flaky
+tests). Leave out the session's task itself, anything already obvious from the
+code, and anything temporary. Correct notes the session proved wrong. Keep the
+sections "## Architecture", "## Decisions" and "## Gotchas", one short bullet
+per fact, and keep the whole file under %d bytes by merging or dropping the
+least useful bullets.
+
+Reply with the complete updated Markdown file only. If the session taught
+nothing worth keeping, reply with exactly ` + noKnowledgeChanges + `.
+
+Current notes:
+
+%s
+
+Session transcript:
+
+%s`
+
+// knowledgePath returns the knowledge base of cfg's project, or of the
+// workspace when there is no project config
+func knowledgePath(cfg *config.Config) string {
+	root := cfg.ProjectDir
+	if root == "" {
+		root = currentWorkspace.Root
+	}
+	return filepath.Join(root, knowledgeFile)
+}
+
+// loadKnowledge reads the repository's notes as a system prompt; it is empty
+// when the knowledge base is off, empty or not written yet, or the workspace
+// is on another machine
+func loadKnowledge(cfg *config.Config) (string, error) {
+	if !cfg.Knowledge.Enabled || cfg.Remote.Host != "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(knowledgePath(cfg))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the knowledge base: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", nil
+	}
+	return "Notes on this repository from earlier sessions (they may be out of date; the code wins):\n\n" + strings.TrimSpace(string(data)), nil
+}
+
+// learn asks the model what the session taught about the repository and
+// rewrites the knowledge base with it. Sessions without a reply from the
+// model have nothing to teach and cost nothing.
+func (a *Agent) learn(ctx context.Context) (bool, error) {
+	cfg := a.config
+	if !cfg.Knowledge.Enabled || cfg.Remote.Host != "" {
+		return false, nil
+	}
+	transcript := learnTranscript(a.Messages())
+	if transcript == "" {
+		return false, nil
+	}
+	path := knowledgePath(cfg)
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	notes := strings.TrimSpace(string(current))
+	if notes == "" {
+		notes = "(none yet)"
+	}
+	limit := cfg.Knowledge.MaxBytes
+	if limit == 0 {
+		limit = defaultKnowledgeBytes
+	}
+
+	stopProgress := a.showProgress(i18n.T("updating the knowledge base…"))
+	response, err := a.provider.RunInference(ctx, []Message{{Role: "user", Content: fmt.Sprintf(learnPrompt, limit, notes, transcript)}}, nil)
+	stopProgress()
+	if err != nil {
+		return false, err
+	}
+	a.recordUsage(response.Model, response.Usage)
+	updated := strings.TrimSpace(cleanMarkdownReply(response.Content))
+	if updated == "" || updated == noKnowledgeChanges || updated == strings.TrimSpace(string(current)) {
+		return false, nil
+	}
+	if len(updated) > limit {
+		return false, fmt.Errorf("the updated notes are %d bytes, over the limit of %d", len(updated), limit)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(updated+"\n"), 0644); err != nil {
+		return false, err
+	}
+	a.log().Info("knowledge base updated", "path", path, "bytes", len(updated))
+	return true, nil
+}
+
+// learnTranscript condenses the session for the summarization pass: the
+// messages in full, tool calls by name and target, and the start of each
+// tool result. It is empty when the model never replied.
+func learnTranscript(messages []Message) string {
+	var parts []string
+	replied := false
+	for _, msg := range messages {
+		switch {
+		case msg.ToolCall != nil:
+			status := "ok"
+			if msg.IsError {
+				status = "error"
+			}
+			target := ""
+			if path := toolPathHint(msg.ToolCall.Input); path != "" {
+				target = " " + path
+			}
+			result := strings.TrimPrefix(msg.Content, toolResultContent(msg.ToolCall.Name, ""))
+			parts = append(parts, fmt.Sprintf("[tool %s%s: %s] %s", msg.ToolCall.Name, target, status, truncate(result, maxLearnToolResult)))
+		case msg.Role == "assistant":
+			replied = true
+			parts = append(parts, "Assistant: "+msg.Content)
+		case msg.Role == "user":
+			parts = append(parts, "User: "+msg.Content)
+		}
+	}
+	if !replied {
+		return ""
+	}
+	transcript := strings.Join(parts, "\n\n")
+	if len(transcript) > maxLearnTranscript {
+		transcript = "(earlier messages omitted)\n\n" + strings.ToValidUTF8(transcript[len(transcript)-maxLearnTranscript:], "")
+	}
+	return transcript
+}
+
+// cleanMarkdownReply removes a code fence wrapped around a whole reply
+func cleanMarkdownReply(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "```") || !strings.HasSuffix(reply, "```") {
+		return reply
+	}
+	_, body, ok := strings.Cut(reply, "\n")
+	if !ok {
+		return reply
+	}
+	return strings.TrimSuffix(body, "```")
+}
+
+// learnAtExit updates the knowledge base when an interactive session ends
+func (a *Agent) learnAtExit(ctx context.Context) {
+	updated, err := a.learn(ctx)
+	switch {
+	case err != nil:
+		fmt.Printf("%s: %s\n", theme.Error(i18n.T("Knowledge base error")), err)
+	case updated:
+		fmt.Println(i18n.Sprintf("Updated the knowledge base in %s", knowledgeFile))
+	}
+}
+
+// runKnowledgeCommand shows the repository's notes, or updates them from the
+// session so far
+func runKnowledgeCommand(a *Agent, args []string) error {
+	if !a.config.Knowledge.Enabled {
+		return fmt.Errorf("the knowledge base is off; set knowledge.enabled in the config")
+	}
+	switch {
+	case len(args) == 0:
+		data, err := os.ReadFile(knowledgePath(a.config))
+		if os.IsNotExist(err) || (err == nil && strings.TrimSpace(string(data)) == "") {
+			fmt.Println(i18n.T("The knowledge base is empty; it is written when a session ends"))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	case len(args) == 1 && args[0] == "update":
+		updated, err := a.learn(context.Background())
+		if err != nil {
+			return err
+		}
+		if !updated {
+			fmt.Println(i18n.T("Nothing new to add to the knowledge base"))
+			return nil
+		}
+		fmt.Println(i18n.Sprintf("Updated the knowledge base in %s", knowledgeFile))
+		return nil
+	}
+	return fmt.Errorf("usage: /knowledge [update]")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnowledge(t *testing.T) {
+	newAgent := func(t *testing.T, responses ...*Response) (*Agent, *mockProvider) {
+		dir := t.TempDir()
+		chdir(t, dir)
+		provider := &mockProvider{responses: responses}
+		agent := NewAgent(provider, nil, nil)
+		agent.onEvent = func(AgentEvent) {}
+		agent.config.Knowledge.Enabled = true
+		return agent, provider
+	}
+	session := []Message{
+		{Role: "user", Content: "why does the build fail?"},
+		{Role: "user", Content: toolResultContent("shell", "cgo: C compiler not found"), ToolCall: &ToolCall{ID: "1", Name: "shell", Input: json.RawMessage(`{"command":"go build"}`)}},
+		{Role: "assistant", Content: "The sqlite driver needs cgo; build with CGO_ENABLED=1."},
+	}
+
+	t.Run("会话结束时总结并写入知识库", func(t *testing.T) {
+		agent, provider := newAgent(t, &Response{Content: "```markdown\n## Gotchas\n\n- The sqlite driver needs cgo.\n```"})
+		agent.setConversation(session)
+		updated, err := agent.learn(context.Background())
+		require.NoError(t, err)
+		assert.True(t, updated)
+		data, err := os.ReadFile(knowledgeFile)
+		require.NoError(t, err)
+		assert.Equal(t, "## Gotchas\n\n- The sqlite driver needs cgo.\n", string(data), "去掉包住整个回复的代码块")
+
+		prompt := provider.calls[0][0].Content
+		assert.Contains(t, prompt, "(none yet)")
+		assert.Contains(t, prompt, "[tool shell: ok] cgo: C compiler not found")
+		assert.Contains(t, prompt, "Assistant: The sqlite driver needs cgo")
+
+		notes, err := loadKnowledge(agent.config)
+		require.NoError(t, err)
+		assert.Contains(t, notes, "- The sqlite driver needs cgo.")
+	})
+
+	t.Run("下个会话开始时加载", func(t *testing.T) {
+		agent, provider := newAgent(t, &Response{Content: "ok"})
+		require.NoError(t, os.MkdirAll(filepath.Dir(knowledgeFile), 0755))
+		require.NoError(t, os.WriteFile(knowledgeFile, []byte("- Tests need Docker.\n"), 0644))
+		cfg := agent.config
+		notes, err := loadKnowledge(cfg)
+		require.NoError(t, err)
+		agent.knowledge = notes
+		require.NoError(t, agent.runTurn(context.Background(), "run the tests"))
+		assert.Equal(t, "system", provider.calls[0][0].Role)
+		assert.Contains(t, provider.calls[0][0].Content, "- Tests need Docker.")
+
+		cfg.Knowledge.Enabled = false
+		notes, err = loadKnowledge(cfg)
+		require.NoError(t, err)
+		assert.Empty(t, notes, "关闭时不加载")
+	})
+
+	t.Run("没有新内容或没有回复时不修改", func(t *testing.T) {
+		agent, provider := newAgent(t, &Response{Content: "NO CHANGES"})
+		updated, err := agent.learn(context.Background())
+		require.NoError(t, err)
+		assert.False(t, updated)
+		assert.Empty(t, provider.calls, "模型没有回复过的会话不总结")
+
+		agent.setConversation(session)
+		updated, err = agent.learn(context.Background())
+		require.NoError(t, err)
+		assert.False(t, updated)
+		_, err = os.Stat(knowledgeFile)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("超过大小上限时不写入", func(t *testing.T) {
+		agent, _ := newAgent(t, &Response{Content: "- a very long note"})
+		agent.config.Knowledge.MaxBytes = 5
+		agent.setConversation(session)
+		_, err := agent.learn(context.Background())
+		assert.ErrorContains(t, err, "over the limit of 5")
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"agent/tools"
+)
+
+// checkLimits refuses a tool call that would exceed config.Limits: new
+// files accessed or written this turn, and shell commands per minute.
+// Allowed calls are counted against the limits.
+func (a *Agent) checkLimits(tool tools.ToolDefinition, input json.RawMessage) error {
+	limits := a.config.Limits
+	if tool.Name == "shell" && !a.commandRate.Allow(time.Now(), limits.MaxCommandsPerMinute, time.Minute) {
+		return fmt.Errorf("shell %w: %d commands in the last minute, the rate limit; wait, or combine steps into fewer commands", tools.ErrDenied, limits.MaxCommandsPerMinute)
+	}
+
+	path := toolPathHint(input)
+	if path == "" {
+		return nil
+	}
+	path = filepath.Clean(path)
+	write := !tool.ReadOnly && !a.turnWrites[path]
+	if write && limits.MaxWritesPerTurn > 0 && len(a.turnWrites) >= limits.MaxWritesPerTurn {
+		return fmt.Errorf("write %w: this turn already changed %d files, the per-turn limit; summarize your progress so the user can continue in a new message", tools.ErrDenied, limits.MaxWritesPerTurn)
+	}
+	if err := a.touchFile(path); err != nil {
+		return err
+	}
+	if write {
+		if a.turnWrites == nil {
+			a.turnWrites = map[string]bool{}
+		}
+		a.turnWrites[path] = true
+	}
+	return nil
+}
+
+// touchFile records a file accessed by a tool in the current turn, and
+// refuses a new one once the turn has reached config.Limits.MaxFilesPerTurn.
+// Files already accessed this turn can be used again.
+func (a *Agent) touchFile(path string) error {
+	if a.turnFiles[path] {
+		return nil
+	}
+	if limit := a.config.Limits.MaxFilesPerTurn; limit > 0 && len(a.turnFiles) >= limit {
+		return fmt.Errorf("file access %w: this turn already accessed %d files, the per-turn limit; summarize your progress so the user can continue in a new message", tools.ErrDenied, limit)
+	}
+	if a.turnFiles == nil {
+		a.turnFiles = map[string]bool{}
+	}
+	a.turnFiles[path] = true
+	return nil
+}
+
+// rateLimiter counts events in a sliding time window
+type rateLimiter struct {
+	times []time.Time
+}
+
+// Allow records an event at now and reports true, unless max events already
+// happened within window before now; max <= 0 means unlimited
+func (r *rateLimiter) Allow(now time.Time, max int, window time.Duration) bool {
+	if max <= 0 {
+		return true
+	}
+	cutoff := now.Add(-window)
+	kept := r.times[:0]
+	for _, t := range r.times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	r.times = kept
+	if len(r.times) >= max {
+		return false
+	}
+	r.times = append(r.times, now)
+	return true
+}
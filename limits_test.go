@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLimits(t *testing.T) {
+	chdir(t, t.TempDir())
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, os.WriteFile(fmt.Sprintf("f%d.txt", i), []byte("small"), 0644))
+	}
+	require.NoError(t, os.WriteFile("big.log", []byte(strings.Repeat("x", 2048)), 0644))
+
+	cfg := config.Default()
+	cfg.Limits = config.Limits{MaxReadBytes: 1024, MaxWriteBytes: 1024, MaxFilesPerTurn: 2}
+	defs, err := enabledTools(cfg, nil)
+	require.NoError(t, err)
+
+	read := func(id, path string) ToolCall {
+		return ToolCall{ID: id, Name: "read_file", Input: json.RawMessage(`{"path":"` + path + `"}`)}
+	}
+	toolResults := func(conversation []Message) []string {
+		results := []string{}
+		for _, msg := range conversation {
+			if msg.ToolCall != nil {
+				results = append(results, msg.Content)
+			}
+		}
+		return results
+	}
+
+	t.Run("超过大小限制的文件", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{
+			{ToolCalls: []ToolCall{read("1", "big.log")}},
+			{Content: "too big"},
+		}}
+		agent := NewAgent(provider, nil, defs)
+		agent.config = cfg
+		agent.onEvent = func(AgentEvent) {}
+		require.NoError(t, agent.runTurn(context.Background(), "read the log"))
+
+		results := toolResults(agent.conversation)
+		require.Len(t, results, 1)
+		assert.Contains(t, results[0], "big.log is 2.0 KiB, over the 1.0 KiB read limit")
+	})
+
+	t.Run("每轮访问的文件数", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{
+			{ToolCalls: []ToolCall{read("1", "f1.txt"), read("2", "f2.txt"), read("3", "./f1.txt"), read("4", "f3.txt")}},
+			{Content: "done"},
+			{ToolCalls: []ToolCall{read("5", "f3.txt")}},
+			{Content: "done"},
+		}}
+		agent := NewAgent(provider, nil, defs)
+		agent.config = cfg
+		agent.onEvent = func(AgentEvent) {}
+
+		require.NoError(t, agent.runTurn(context.Background(), "read everything"))
+		results := toolResults(agent.conversation)
+		require.Len(t, results, 4)
+		assert.Contains(t, results[2], "executed with result: small", "同一文件可以重复访问")
+		assert.Contains(t, results[3], "already accessed 2 files")
+
+		require.NoError(t, agent.runTurn(context.Background(), "continue"))
+		results = toolResults(agent.conversation)
+		assert.Contains(t, results[4], "executed with result: small", "新一轮重新计数")
+	})
+}
+
+func TestWriteAndCommandLimits(t *testing.T) {
+	chdir(t, t.TempDir())
+	cfg := config.Default()
+	cfg.Limits.MaxWritesPerTurn = 1
+	cfg.Limits.MaxCommandsPerMinute = 2
+	defs, err := enabledTools(cfg, nil)
+	require.NoError(t, err)
+
+	create := func(id, path string) ToolCall {
+		return ToolCall{ID: id, Name: "edit_file", Input: json.RawMessage(`{"path":"` + path + `","old_str":"","new_str":"x"}`)}
+	}
+	shell := func(id string) ToolCall {
+		return ToolCall{ID: id, Name: "shell", Input: json.RawMessage(`{"command":"echo hi"}`)}
+	}
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{create("1", "a.txt"), create("2", "b.txt"), shell("3"), shell("4"), shell("5")}},
+		{Content: "done"},
+	}}
+	agent := NewAgent(provider, nil, defs)
+	agent.config = cfg
+	agent.onEvent = func(AgentEvent) {}
+	require.NoError(t, agent.runTurn(context.Background(), "go"))
+
+	results := []string{}
+	for _, msg := range agent.conversation {
+		if msg.ToolCall != nil {
+			results = append(results, msg.Content)
+		}
+	}
+	require.Len(t, results, 5)
+	assert.Contains(t, results[0], "Created a.txt")
+	assert.Contains(t, results[1], "write denied: this turn already changed 1 files")
+	assert.NoFileExists(t, "b.txt")
+	assert.Contains(t, results[3], "hi")
+	assert.Contains(t, results[4], "shell denied: 2 commands in the last minute")
+}
+
+func TestRateLimiter(t *testing.T) {
+	var r rateLimiter
+	start := time.Now()
+	assert.True(t, r.Allow(start, 2, time.Minute))
+	assert.True(t, r.Allow(start.Add(10*time.Second), 2, time.Minute))
+	assert.False(t, r.Allow(start.Add(20*time.Second), 2, time.Minute))
+	assert.True(t, r.Allow(start.Add(61*time.Second), 2, time.Minute), "最早的一次已经移出窗口")
+	assert.False(t, r.Allow(start.Add(62*time.Second), 2, time.Minute))
+	assert.True(t, r.Allow(start, 0, time.Minute), "0 表示不限制")
+}
@@ -0,0 +1,164 @@
+// Package linear 是 Linear GraphQL API 的一个小客户端，只包含代理需要的接口
+package linear
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultURL 是 Linear 的 GraphQL 接口地址
+const DefaultURL = "https://api.linear.app/graphql"
+
+// Client 调用 Linear GraphQL API，Token 是个人 API key
+type Client struct {
+	URL   string
+	Token string
+	// HTTP 为空时使用带超时的默认客户端
+	HTTP *http.Client
+}
+
+// APIError 是 API 返回的错误
+type APIError struct {
+	Status   int
+	Messages []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Linear API error %d: %s", e.Status, strings.Join(e.Messages, "; "))
+}
+
+// Query 执行 GraphQL 查询或变更，把响应的 data 解码到 out
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	endpoint := c.URL
+	if endpoint == "" {
+		endpoint = DefaultURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// GraphQL 的错误可能伴随 200 或 4xx 状态码返回
+	var body struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &body); err != nil && resp.StatusCode < 300 {
+		return err
+	}
+	if len(body.Errors) > 0 || resp.StatusCode >= 300 {
+		apiErr := &APIError{Status: resp.StatusCode}
+		for _, e := range body.Errors {
+			apiErr.Messages = append(apiErr.Messages, e.Message)
+		}
+		if len(apiErr.Messages) == 0 {
+			apiErr.Messages = []string{http.StatusText(resp.StatusCode)}
+		}
+		return apiErr
+	}
+	return json.Unmarshal(body.Data, out)
+}
+
+// User 是 issue 的经办人、创建人或评论的作者
+type User struct {
+	Name string `json:"name"`
+}
+
+// Comment 是 issue 下的评论
+type Comment struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	URL       string    `json:"url"`
+	User      *User     `json:"user"`
+}
+
+// Issue 是一个 issue，Identifier 是团队前缀加编号（例如 ENG-123），ID 是变更接口使用的内部 ID
+type Issue struct {
+	ID          string `json:"id"`
+	Identifier  string `json:"identifier"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	State       struct {
+		Name string `json:"name"`
+	} `json:"state"`
+	Assignee *User `json:"assignee"`
+	Creator  *User `json:"creator"`
+	Comments struct {
+		Nodes []Comment `json:"nodes"`
+	} `json:"comments"`
+}
+
+const issueQuery = `query Issue($id: String!) {
+  issue(id: $id) {
+    id identifier title description url
+    state { name }
+    assignee { name }
+    creator { name }
+    comments(first: 100) { nodes { body createdAt url user { name } } }
+  }
+}`
+
+// GetIssue 返回 issue 及其前 100 条评论，id 可以是内部 ID 或 ENG-123 形式的标识
+func (c *Client) GetIssue(ctx context.Context, id string) (*Issue, error) {
+	var data struct {
+		Issue Issue `json:"issue"`
+	}
+	if err := c.Query(ctx, issueQuery, map[string]interface{}{"id": id}, &data); err != nil {
+		return nil, err
+	}
+	return &data.Issue, nil
+}
+
+const createCommentMutation = `mutation CreateComment($issueId: String!, $body: String!) {
+  commentCreate(input: {issueId: $issueId, body: $body}) {
+    success
+    comment { body createdAt url user { name } }
+  }
+}`
+
+// CreateComment 在内部 ID 为 issueID 的 issue 下发表评论
+func (c *Client) CreateComment(ctx context.Context, issueID, body string) (*Comment, error) {
+	var data struct {
+		CommentCreate struct {
+			Success bool    `json:"success"`
+			Comment Comment `json:"comment"`
+		} `json:"commentCreate"`
+	}
+	if err := c.Query(ctx, createCommentMutation, map[string]interface{}{"issueId": issueID, "body": body}, &data); err != nil {
+		return nil, err
+	}
+	if !data.CommentCreate.Success {
+		return nil, fmt.Errorf("linear did not create the comment")
+	}
+	return &data.CommentCreate.Comment, nil
+}
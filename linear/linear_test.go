@@ -0,0 +1,37 @@
+package linear
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIError(t *testing.T) {
+	t.Run("GraphQL 错误", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data": null, "errors": [{"message": "Entity not found: Issue"}]}`))
+		}))
+		defer server.Close()
+
+		client := &Client{URL: server.URL}
+		_, err := client.GetIssue(context.Background(), "ENG-1")
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "Linear API error 200: Entity not found: Issue", err.Error())
+	})
+
+	t.Run("认证失败", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		client := &Client{URL: server.URL}
+		_, err := client.GetIssue(context.Background(), "ENG-1")
+		assert.EqualError(t, err, "Linear API error 401: Unauthorized")
+	})
+}
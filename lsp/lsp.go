@@ -0,0 +1,604 @@
+// Package lsp 实现一个最小的语言服务器协议客户端，用于通过 gopls 等语言服务器
+// 查找定义、引用和重命名符号
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Position 是文档中的位置，Line 和 Character 都从 0 开始，Character 以 UTF-16 码元计
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit 是重命名等操作需要对多个文件做的修改
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit    `json:"documentChanges,omitempty"`
+}
+
+type TextDocumentEdit struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Edits []TextEdit `json:"edits"`
+}
+
+// Files 返回每个文件路径及其修改，合并 Changes 和 DocumentChanges
+func (e *WorkspaceEdit) Files() (map[string][]TextEdit, error) {
+	files := map[string][]TextEdit{}
+	add := func(uri string, edits []TextEdit) error {
+		path, err := URIToPath(uri)
+		if err != nil {
+			return err
+		}
+		files[path] = append(files[path], edits...)
+		return nil
+	}
+	for uri, edits := range e.Changes {
+		if err := add(uri, edits); err != nil {
+			return nil, err
+		}
+	}
+	for _, change := range e.DocumentChanges {
+		if err := add(change.TextDocument.URI, change.Edits); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// PathToURI 把绝对路径转换为 file:// URI
+func PathToURI(path string) string {
+	return slashPathToURI(filepath.ToSlash(path))
+}
+
+// URIToPath 把 file:// URI 转换为本地路径
+func URIToPath(uri string) (string, error) {
+	path, err := uriToSlashPath(uri)
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// slashPathToURI 把以 / 分隔的绝对路径转换为 URI，Windows 盘符路径 C:/a 对应 file:///C:/a
+func slashPathToURI(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// uriToSlashPath 把 file:// URI 转换为以 / 分隔的路径，file:///C:/a 对应 C:/a
+func uriToSlashPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported document URI %q", uri)
+	}
+	path := u.Path
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return path, nil
+}
+
+// requestTimeout 是单个请求（包括服务器启动和加载工作区）的最长等待时间
+const requestTimeout = 2 * time.Minute
+
+// Client 与语言服务器进程通信。服务器在第一次请求时启动，请求依次执行。
+// 每次请求前会通知服务器工作区中被修改过的文件，使结果反映磁盘上的最新内容
+type Client struct {
+	// Root 是工作区根目录的绝对路径
+	Root string
+	// Command 是启动语言服务器的命令及参数，例如 ["gopls"]
+	Command []string
+	// Extensions 是需要跟踪修改的文件名后缀，例如 ".go"、"go.mod"
+	Extensions []string
+
+	mu     sync.Mutex
+	conn   *conn
+	mtimes map[string]time.Time
+}
+
+// Definition 返回 path 中 pos 处符号的定义位置
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	if err := c.request(ctx, path, "textDocument/definition", positionParams(path, pos, nil), &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// References 返回 path 中 pos 处符号的所有引用，包括声明
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var locations []Location
+	extra := map[string]interface{}{"context": map[string]bool{"includeDeclaration": true}}
+	err := c.request(ctx, path, "textDocument/references", positionParams(path, pos, extra), &locations)
+	return locations, err
+}
+
+// Rename 返回把 path 中 pos 处的符号重命名为 newName 需要做的修改，不会修改文件
+func (c *Client) Rename(ctx context.Context, path string, pos Position, newName string) (*WorkspaceEdit, error) {
+	var edit WorkspaceEdit
+	extra := map[string]interface{}{"newName": newName}
+	err := c.request(ctx, path, "textDocument/rename", positionParams(path, pos, extra), &edit)
+	return &edit, err
+}
+
+// Close 关闭语言服务器
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c.conn.call(ctx, "shutdown", nil, nil)
+	c.conn.notify("exit", nil)
+	err := c.conn.close()
+	c.conn = nil
+	return err
+}
+
+func positionParams(path string, pos Position, extra map[string]interface{}) map[string]interface{} {
+	params := map[string]interface{}{
+		"textDocument": map[string]string{"uri": PathToURI(path)},
+		"position":     pos,
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+	return params
+}
+
+// request 在 path 对应的文档打开期间发送请求
+func (c *Client) request(ctx context.Context, path, method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.start(ctx); err != nil {
+		return err
+	}
+	c.syncFiles()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	uri := PathToURI(path)
+	c.conn.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": languageID(path), "version": 1, "text": string(content)},
+	})
+	defer c.conn.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	return c.conn.call(ctx, method, params, result)
+}
+
+// start 启动语言服务器并完成初始化握手
+func (c *Client) start(ctx context.Context) error {
+	if c.conn != nil {
+		if err := c.conn.exited(); err == nil {
+			return nil
+		}
+		// 服务器已退出，重新启动
+		c.conn.close()
+		c.conn = nil
+	}
+	if len(c.Command) == 0 {
+		return fmt.Errorf("no language server command configured")
+	}
+	if _, err := exec.LookPath(c.Command[0]); err != nil {
+		return fmt.Errorf("language server %s not found: %w", c.Command[0], err)
+	}
+
+	cmd := exec.Command(c.Command[0], c.Command[1:]...)
+	cmd.Dir = c.Root
+	conn, err := dial(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", c.Command[0], err)
+	}
+	params := map[string]interface{}{
+		"processId":        os.Getpid(),
+		"rootUri":          PathToURI(c.Root),
+		"workspaceFolders": []map[string]string{{"uri": PathToURI(c.Root), "name": filepath.Base(c.Root)}},
+		"capabilities": map[string]interface{}{
+			"workspace": map[string]interface{}{"workspaceEdit": map[string]bool{"documentChanges": true}},
+		},
+	}
+	if err := conn.call(ctx, "initialize", params, nil); err != nil {
+		conn.close()
+		return fmt.Errorf("failed to initialize %s: %w", c.Command[0], err)
+	}
+	conn.notify("initialized", map[string]interface{}{})
+	c.conn = conn
+	c.mtimes = nil
+	return nil
+}
+
+// syncFiles 通知服务器自上次请求以来被创建、修改或删除的文件
+func (c *Client) syncFiles() {
+	mtimes := map[string]time.Time{}
+	filepath.WalkDir(c.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != c.Root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !c.tracked(d.Name()) {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			mtimes[path] = info.ModTime()
+		}
+		return nil
+	})
+
+	// 第一次只记录修改时间，服务器刚启动时读取的就是最新内容
+	if c.mtimes != nil {
+		// 1: 创建，2: 修改，3: 删除
+		changes := []map[string]interface{}{}
+		for path, mtime := range mtimes {
+			old, ok := c.mtimes[path]
+			switch {
+			case !ok:
+				changes = append(changes, map[string]interface{}{"uri": PathToURI(path), "type": 1})
+			case !old.Equal(mtime):
+				changes = append(changes, map[string]interface{}{"uri": PathToURI(path), "type": 2})
+			}
+		}
+		for path := range c.mtimes {
+			if _, ok := mtimes[path]; !ok {
+				changes = append(changes, map[string]interface{}{"uri": PathToURI(path), "type": 3})
+			}
+		}
+		if len(changes) > 0 {
+			c.conn.notify("workspace/didChangeWatchedFiles", map[string]interface{}{"changes": changes})
+		}
+	}
+	c.mtimes = mtimes
+}
+
+func (c *Client) tracked(name string) bool {
+	for _, ext := range c.Extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func languageID(path string) string {
+	switch filepath.Ext(path) {
+	case ".go":
+		return "go"
+	case ".mod":
+		return "go.mod"
+	default:
+		return strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+}
+
+// parseLocations 解析 definition 的结果，它可能是 Location、Location 数组或 LocationLink 数组
+func parseLocations(raw json.RawMessage) ([]Location, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single Location
+	if err := json.Unmarshal(raw, &single); err == nil && single.URI != "" {
+		return []Location{single}, nil
+	}
+	var items []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange Range  `json:"targetSelectionRange"`
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("unexpected definition result: %w", err)
+	}
+	locations := []Location{}
+	for _, item := range items {
+		if item.TargetURI != "" {
+			locations = append(locations, Location{URI: item.TargetURI, Range: item.TargetSelectionRange})
+		} else {
+			locations = append(locations, item.Location)
+		}
+	}
+	return locations, nil
+}
+
+// conn 是与语言服务器进程之间的 JSON-RPC 连接，消息带有 Content-Length 头
+type conn struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan response
+	// done 在服务器退出后关闭，err 为退出原因
+	done chan struct{}
+	err  error
+}
+
+type response struct {
+	Result json.RawMessage
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+}
+
+func dial(cmd *exec.Cmd) (*conn, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &conn{cmd: cmd, stdin: stdin, pending: map[int]chan response{}, done: make(chan struct{})}
+	go c.read(bufio.NewReader(stdout))
+	return c, nil
+}
+
+// read 读取服务器发来的消息：把响应交给等待的请求，回应服务器的请求，忽略通知
+func (c *conn) read(r *bufio.Reader) {
+	var err error
+	defer func() {
+		c.cmd.Wait()
+		c.mu.Lock()
+		c.err = fmt.Errorf("language server exited: %w", err)
+		c.mu.Unlock()
+		close(c.done)
+	}()
+	for {
+		var body []byte
+		if body, err = readMessage(r); err != nil {
+			return
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			response
+		}
+		if json.Unmarshal(body, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			c.write(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": serverRequestResult(msg.Method, msg.Params)})
+		case msg.Method == "" && msg.ID != nil:
+			id, _ := strconv.Atoi(string(msg.ID))
+			c.mu.Lock()
+			ch, ok := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg.response
+			}
+		}
+	}
+}
+
+// serverRequestResult 回应服务器发来的请求，例如 workspace/configuration 需要与请求项数相同的结果
+func serverRequestResult(method string, params json.RawMessage) interface{} {
+	if method == "workspace/configuration" {
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &p)
+		return make([]interface{}, len(p.Items))
+	}
+	return nil
+}
+
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+func (c *conn) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (c *conn) notify(method string, params interface{}) error {
+	return c.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *conn) call(ctx context.Context, method string, params, result interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan response, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-c.done:
+		return c.exited()
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// exited 在服务器已退出时返回退出原因
+func (c *conn) exited() error {
+	select {
+	case <-c.done:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	default:
+		return nil
+	}
+}
+
+func (c *conn) close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	return nil
+}
+
+// ApplyEdits 把修改应用到文本上，修改的范围不能重叠
+func ApplyEdits(text string, edits []TextEdit) (string, error) {
+	type span struct {
+		start, end, index int
+		newText           string
+	}
+	spans := make([]span, 0, len(edits))
+	for i, edit := range edits {
+		start, err := Offset(text, edit.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := Offset(text, edit.Range.End)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", fmt.Errorf("invalid edit range %+v", edit.Range)
+		}
+		spans = append(spans, span{start, end, i, edit.NewText})
+	}
+	// 从后往前应用，前面的偏移量不受影响。同一位置的插入按数组中的顺序出现在结果中，
+	// 所以数组中靠后的先应用
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start > spans[j].start
+		}
+		return spans[i].index > spans[j].index
+	})
+	for i, s := range spans {
+		if i > 0 && s.end > spans[i-1].start {
+			return "", errors.New("overlapping edits")
+		}
+		text = text[:s.start] + s.newText + text[s.end:]
+	}
+	return text, nil
+}
+
+// Offset 把位置转换为 text 中的字节偏移量
+func Offset(text string, pos Position) (int, error) {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return 0, fmt.Errorf("line %d is beyond the end of the file", pos.Line+1)
+		}
+		offset += i + 1
+	}
+	units := 0
+	for i, r := range text[offset:] {
+		if units >= pos.Character || r == '\n' {
+			return offset + i, nil
+		}
+		units += utf16Len(r)
+	}
+	return len(text), nil
+}
+
+// Character 返回一行中字节偏移量 col 对应的 UTF-16 码元位置
+func Character(line string, col int) int {
+	units := 0
+	for _, r := range line[:col] {
+		units += utf16Len(r)
+	}
+	return units
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
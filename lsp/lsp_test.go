@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"bufio"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEdits(t *testing.T) {
+	text := "package main\n\nfunc hello() {}\n\nfunc main() { hello() }\n"
+	edits := []TextEdit{
+		{Range: Range{Start: Position{4, 14}, End: Position{4, 19}}, NewText: "greet"},
+		{Range: Range{Start: Position{2, 5}, End: Position{2, 10}}, NewText: "greet"},
+	}
+	result, err := ApplyEdits(text, edits)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc greet() {}\n\nfunc main() { greet() }\n", result)
+
+	t.Run("重叠的修改", func(t *testing.T) {
+		_, err := ApplyEdits(text, []TextEdit{
+			{Range: Range{Start: Position{2, 0}, End: Position{2, 10}}},
+			{Range: Range{Start: Position{2, 5}, End: Position{2, 12}}},
+		})
+		assert.ErrorContains(t, err, "overlapping")
+	})
+
+	t.Run("超出文件的行", func(t *testing.T) {
+		_, err := ApplyEdits(text, []TextEdit{{Range: Range{Start: Position{10, 0}, End: Position{10, 1}}}})
+		assert.ErrorContains(t, err, "beyond the end")
+	})
+
+	t.Run("同一位置的插入按数组顺序出现", func(t *testing.T) {
+		at := Range{Start: Position{0, 0}, End: Position{0, 0}}
+		result, err := ApplyEdits("main", []TextEdit{{Range: at, NewText: "a"}, {Range: at, NewText: "b"}})
+		require.NoError(t, err)
+		assert.Equal(t, "abmain", result)
+	})
+}
+
+func TestOffsetUTF16(t *testing.T) {
+	// "😀" 占两个 UTF-16 码元、四个字节
+	line := `s := "😀é" + name`
+	col := strings.Index(line, "name")
+	character := Character(line, col)
+	assert.Equal(t, 13, character)
+
+	offset, err := Offset("x\n"+line, Position{Line: 1, Character: character})
+	require.NoError(t, err)
+	assert.Equal(t, 2+col, offset)
+}
+
+func TestURIs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a b", "main.go")
+	uri := PathToURI(path)
+	assert.True(t, strings.HasPrefix(uri, "file:///"), uri)
+	assert.Contains(t, uri, "/a%20b/main.go")
+	back, err := URIToPath(uri)
+	require.NoError(t, err)
+	assert.Equal(t, path, back)
+
+	_, err = URIToPath("https://example.com/main.go")
+	assert.Error(t, err)
+
+	// 以 / 分隔的形式与平台无关，Windows 的路径在任何系统上都可以测试
+	cases := []struct {
+		name, path, uri string
+	}{
+		{"Unix 路径", "/home/a b/main.go", "file:///home/a%20b/main.go"},
+		{"Windows 盘符路径", "C:/Users/a b/main.go", "file:///C:/Users/a%20b/main.go"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.uri, slashPathToURI(c.path))
+			path, err := uriToSlashPath(c.uri)
+			require.NoError(t, err)
+			assert.Equal(t, c.path, path)
+		})
+	}
+}
+
+func TestParseLocations(t *testing.T) {
+	single, err := parseLocations([]byte(`{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":3}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Location{{URI: "file:///a.go", Range: Range{Position{1, 2}, Position{1, 3}}}}, single)
+
+	links, err := parseLocations([]byte(`[{"targetUri":"file:///b.go","targetRange":{},"targetSelectionRange":{"start":{"line":4,"character":5},"end":{"line":4,"character":6}}}]`))
+	require.NoError(t, err)
+	assert.Equal(t, "file:///b.go", links[0].URI)
+	assert.Equal(t, Position{4, 5}, links[0].Range.Start)
+
+	none, err := parseLocations([]byte(`null`))
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestReadMessage(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("Content-Length: 2\r\nContent-Type: application/json\r\n\r\n{}Content-Length: 4\r\n\r\nnull"))
+	body, err := readMessage(r)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(body))
+	body, err = readMessage(r)
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(body))
+
+	_, err = readMessage(bufio.NewReader(strings.NewReader("X: 1\r\n\r\n")))
+	assert.ErrorContains(t, err, "Content-Length")
+}
+
+// FuzzApplyEdits 检查不重叠的修改与应用顺序无关，结果的长度与修改一致
+func FuzzApplyEdits(f *testing.F) {
+	f.Add("package main\n\nfunc hello() {}\n", 2, 5, 2, 10, "greet", 0, 0, 0, 0, "// x\n")
+	f.Add("a\nb\n", 0, 0, 0, 1, "A", 0, 1, 0, 1, "!")
+	f.Add("héllo 世界\n", 0, 1, 0, 2, "e", 0, 6, 0, 8, "world")
+	f.Add("x", 5, 0, 6, 0, "", -1, -1, 0, 0, "")
+	f.Add("abc", 0, 2, 0, 1, "", 0, 0, 0, 3, "z")
+
+	f.Fuzz(func(t *testing.T, text string, l1, c1, l2, c2 int, new1 string, l3, c3, l4, c4 int, new2 string) {
+		edits := []TextEdit{
+			{Range: Range{Start: Position{l1, c1}, End: Position{l2, c2}}, NewText: new1},
+			{Range: Range{Start: Position{l3, c3}, End: Position{l4, c4}}, NewText: new2},
+		}
+		result, err := ApplyEdits(text, edits)
+		if err != nil {
+			return
+		}
+		length := len(text)
+		var starts []int
+		for _, edit := range edits {
+			start, err := Offset(text, edit.Range.Start)
+			require.NoError(t, err)
+			end, err := Offset(text, edit.Range.End)
+			require.NoError(t, err)
+			length += len(edit.NewText) - (end - start)
+			starts = append(starts, start)
+		}
+		assert.Len(t, result, length)
+
+		if starts[0] == starts[1] {
+			// 同一位置的插入按数组中的顺序出现
+			assert.Contains(t, result, new1+new2)
+			return
+		}
+		reversed, err := ApplyEdits(text, []TextEdit{edits[1], edits[0]})
+		require.NoError(t, err)
+		assert.Equal(t, result, reversed)
+	})
+}
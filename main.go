@@ -1,217 +1,219 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
-
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/config"
+	"agent/gitutil"
+	"agent/i18n"
+	agentlib "agent/pkg/agent"
+	"agent/pkg/message"
+	"agent/pkg/provider"
+	"agent/redact"
+	"agent/script"
+	"agent/search"
+	"agent/theme"
 	"agent/tools"
 
-	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/openai/openai-go"
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/openai/openai-go/option"
-	"github.com/openai/openai-go/packages/param"
-	"github.com/openai/openai-go/shared"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// AI Provider interface for unified handling
-type AIProvider interface {
-	RunInference(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error)
-}
-
-// Unified message structure
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-// Unified response structure
-type Response struct {
-	Content   string     `json:"content"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
-}
+// The message and provider types live in agent/pkg/message and
+// agent/pkg/provider so that other programs can embed the agent loop
+// (agent/pkg/agent); package main keeps its own names for them.
+type (
+	AIProvider   = provider.Provider
+	ProviderFunc = provider.Func
+	Middleware   = provider.Middleware
+	Message      = message.Message
+	Response     = message.Response
+	Usage        = message.Usage
+	ToolCall     = message.ToolCall
+)
 
-type ToolCall struct {
-	ID    string          `json:"id"`
-	Name  string          `json:"name"`
-	Input json.RawMessage `json:"input"`
+// Chain wraps p with middlewares; the first middleware is the outermost
+func Chain(p AIProvider, middlewares ...Middleware) AIProvider {
+	return provider.Chain(p, middlewares...)
 }
 
-// Anthropic provider implementation
-type AnthropicProvider struct {
-	client anthropic.Client
+func main() {
+	err := newRootCommand().Execute()
+	closeWorkspaceIndexes()
+	shutdownTracing()
+	if err != nil {
+		os.Exit(exitCode(err))
+	}
 }
 
-func NewAnthropicProvider() *AnthropicProvider {
-	return &AnthropicProvider{
-		client: anthropic.NewClient(),
+// providerName is the provider cfg selects: an empty cfg.Provider prefers
+// OpenAI when OPENAI_API_KEY is set, as before config files existed
+func providerName(cfg *config.Config) string {
+	if cfg.Provider != "" {
+		return cfg.Provider
+	}
+	if os.Getenv("OPENAI_API_KEY") != "" {
+		return "openai"
 	}
+	return "anthropic"
 }
 
-func (ap *AnthropicProvider) RunInference(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
-	// Convert unified messages to Anthropic format
-	anthropicMessages := make([]anthropic.MessageParam, len(conversation))
-	for i, msg := range conversation {
-		if msg.Role == "user" {
-			anthropicMessages[i] = anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content))
-		} else {
-			anthropicMessages[i] = anthropic.NewAssistantMessage(anthropic.NewTextBlock(msg.Content))
+// newProvider builds the provider selected by cfg (see providerName),
+// wrapped with tracing and logging; recorders receive its HTTP traffic.
+// cfg.BaseURL points either provider at a compatible endpoint (a local
+// OpenAI-compatible server needs no key). Keys come from the environment,
+// else the OS keychain. It also returns a display name for the chosen model.
+func newProvider(cfg *config.Config, logger *slog.Logger, recorders ...exchangeRecorder) (AIProvider, string, error) {
+	name := providerName(cfg)
+	middleware := func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		if len(recorders) > 0 {
+			return captureExchange(req, func(req *http.Request) (*http.Response, error) {
+				return logHTTPAttempt(logger, req, next)
+			}, recorders)
 		}
+		return logHTTPAttempt(logger, req, next)
 	}
 
-	// Convert tools to Anthropic format
-	anthropicTools := []anthropic.ToolUnionParam{}
-	for _, tool := range tools {
-		anthropicTools = append(anthropicTools, anthropic.ToolUnionParam{
-			OfTool: &anthropic.ToolParam{
-				InputSchema: tool.InputSchema,
-				Name:        tool.Name,
-				Description: anthropic.String(tool.Description),
-			},
-		})
+	var base AIProvider
+	var model string
+	switch name {
+	case "openai":
+		openaiKey, _ := lookupAPIKey(name, cfg.APIKeyEnv)
+		if openaiKey == "" && cfg.BaseURL == "" {
+			keyEnv := cfg.APIKeyEnv
+			if keyEnv == "" {
+				keyEnv = defaultKeyEnv[name]
+			}
+			return nil, "", fmt.Errorf("provider openai requires %s or a key saved with `agent auth login openai`", keyEnv)
+		}
+		opts := []option.RequestOption{option.WithMiddleware(
+			func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+				return middleware(req, next)
+			})}
+		if cfg.BaseURL != "" {
+			opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+		}
+		p := provider.NewOpenAI(openaiKey, opts...)
+		if cfg.Model != "" {
+			p.Model = cfg.Model
+		}
+		p.MaxTokens = cfg.MaxTokens
+		base, model = p, "OpenAI "+p.Model
+	case "anthropic":
+		opts := []anthropicoption.RequestOption{anthropicoption.WithMiddleware(
+			func(req *http.Request, next anthropicoption.MiddlewareNext) (*http.Response, error) {
+				return middleware(req, next)
+			})}
+		if key, _ := lookupAPIKey(name, cfg.APIKeyEnv); key != "" {
+			opts = append(opts, anthropicoption.WithAPIKey(key))
+		}
+		if cfg.BaseURL != "" {
+			opts = append(opts, anthropicoption.WithBaseURL(cfg.BaseURL))
+		}
+		p := provider.NewAnthropic(opts...)
+		if cfg.Model != "" {
+			p.Model = cfg.Model
+		}
+		if cfg.MaxTokens > 0 {
+			p.MaxTokens = cfg.MaxTokens
+		}
+		base, model = p, "Anthropic "+p.Model
+	default:
+		return nil, "", fmt.Errorf("unknown provider %q", name)
 	}
-
-	message, err := ap.client.Messages.New(ctx, anthropic.MessageNewParams{
-		Model:     anthropic.ModelClaude3_7SonnetLatest,
-		MaxTokens: int64(1024),
-		Messages:  anthropicMessages,
-		Tools:     anthropicTools,
+	// Repairs mean the agent loop built a malformed conversation; warn so it
+	// gets noticed instead of surfacing as a 400 from the API
+	validate := provider.Validate(func(repairs []string) {
+		logger.Warn("repaired conversation before inference", "repairs", repairs)
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert response back to unified format
-	response := &Response{}
-	for _, content := range message.Content {
-		switch content.Type {
-		case "text":
-			response.Content = content.Text
-		case "tool_use":
-			toolCall := ToolCall{
-				ID:    content.ID,
-				Name:  content.Name,
-				Input: content.Input,
-			}
-			response.ToolCalls = append(response.ToolCalls, toolCall)
+	middlewares := []Middleware{TracingMiddleware(name), LoggingMiddleware(logger), validate}
+	if cfg.ResponseCache != "" {
+		dir, err := cfg.ResolvePath(cfg.ResponseCache)
+		if err != nil {
+			return nil, "", err
 		}
+		// Anything that changes the reply for the same conversation is part of the key
+		middlewares = append(middlewares, provider.Cache(dir, fmt.Sprintf("%s %s max_tokens=%d", cfg.BaseURL, model, cfg.MaxTokens)))
 	}
-
-	return response, nil
+	return Chain(base, middlewares...), model, nil
 }
 
-// OpenAI provider implementation
-type OpenAIProvider struct {
-	client openai.Client
-}
-
-func NewOpenAIProvider(apiKey string) *OpenAIProvider {
-	return &OpenAIProvider{
-		client: openai.NewClient(option.WithAPIKey(apiKey)),
+// enabledTools returns the built-in tools allowed by cfg, confined to the
+// configured sandbox paths. File reads are noted in reads, if not nil, so
+// that unchanged files are not sent again.
+func enabledTools(cfg *config.Config, reads *tools.ReadCache) ([]tools.ToolDefinition, error) {
+	workspace := &tools.Workspace{
+		Root:          currentWorkspace.Root,
+		MaxReadBytes:  cfg.Limits.MaxReadBytes,
+		MaxWriteBytes: cfg.Limits.MaxWriteBytes,
+		Reads:         reads,
+		HostShell:     cfg.Shell.Program,
 	}
-}
-
-func (op *OpenAIProvider) RunInference(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
-	// Convert unified messages to OpenAI format
-	openaiMessages := make([]openai.ChatCompletionMessageParamUnion, len(conversation))
-	for i, msg := range conversation {
-		if msg.Role == "user" {
-			openaiMessages[i] = openai.UserMessage(msg.Content)
-		} else {
-			openaiMessages[i] = openai.AssistantMessage(msg.Content)
+	if cfg.Remote.Host != "" {
+		workspace.Remote = &tools.Remote{Host: cfg.Remote.Host, Root: cfg.Remote.Root, Port: cfg.Remote.Port, Identity: cfg.Remote.Identity}
+	}
+	env := toolEnv(cfg, workspace)
+	if env.Container = shellContainer(cfg.Shell); env.Container != nil {
+		if _, err := exec.LookPath(cfg.Shell.Backend); err != nil {
+			return nil, fmt.Errorf("shell backend %s: %w", cfg.Shell.Backend, err)
 		}
 	}
-
-	// Convert tools to OpenAI format
-	openaiTools := []openai.ChatCompletionToolParam{}
-	for _, tool := range tools {
-		// Convert Anthropic schema to OpenAI schema format
-		// Create parameters from schema properties
-		params := make(map[string]interface{})
-		if tool.InputSchema.Properties != nil {
-			if props, ok := tool.InputSchema.Properties.(map[string]interface{}); ok {
-				params["type"] = "object"
-				params["properties"] = props
-				if len(tool.InputSchema.Required) > 0 {
-					params["required"] = tool.InputSchema.Required
-				}
-			}
+	// a remote workspace's files are not on this machine
+	if cfg.Index.Enabled && workspace.Remote == nil {
+		index, err := workspaceIndex(workspace.Root)
+		if err != nil {
+			return nil, fmt.Errorf("workspace index: %w", err)
 		}
-
-		openaiTool := openai.ChatCompletionToolParam{
-			Function: shared.FunctionDefinitionParam{
-				Name:        tool.Name,
-				Description: param.NewOpt(tool.Description),
-				Parameters:  shared.FunctionParameters(params),
-			},
+		env.Index = index
+		if env.Embeddings != nil {
+			env.Semantic = workspaceEmbeddings(index, cfg.Embeddings, env.Embeddings)
 		}
-		openaiTools = append(openaiTools, openaiTool)
 	}
-
-	params := openai.ChatCompletionNewParams{
-		Model:    openai.ChatModelGPT4o,
-		Messages: openaiMessages,
-	}
-
-	if len(openaiTools) > 0 {
-		params.Tools = openaiTools
-	}
-
-	completion, err := op.client.Chat.Completions.New(ctx, params)
+	all := tools.Default.Snapshot(env)
+	custom, err := customTools(cfg, workspace)
 	if err != nil {
 		return nil, err
 	}
-
-	// Convert response back to unified format
-	response := &Response{}
-	if len(completion.Choices) > 0 {
-		choice := completion.Choices[0]
-		if choice.Message.Content != "" {
-			response.Content = choice.Message.Content
+	all = append(all, custom...)
+	for _, name := range append(append([]string{}, cfg.Tools.Allowed...), cfg.Tools.Disabled...) {
+		if _, ok := tools.Default.Lookup(name); !ok && !isCustomTool(cfg, name) {
+			return nil, fmt.Errorf("config disables unknown tool %q", name)
 		}
+	}
 
-		// Handle tool calls
-		for _, toolCall := range choice.Message.ToolCalls {
-			unifiedToolCall := ToolCall{
-				ID:    toolCall.ID,
-				Name:  toolCall.Function.Name,
-				Input: json.RawMessage(toolCall.Function.Arguments),
-			}
-			response.ToolCalls = append(response.ToolCalls, unifiedToolCall)
+	enabled := []tools.ToolDefinition{}
+	for _, tool := range all {
+		if cfg.IsToolDisabled(tool.Name) || tool.Name == "github" && cfg.GitHub.Disabled {
+			continue
 		}
+		enabled = append(enabled, tool)
 	}
-
-	return response, nil
-}
-
-func main() {
-	var provider AIProvider
-
-	// 优先使用 OpenAI，如果没有 API key 则使用 Anthropic
-	if openaiKey := os.Getenv("OPENAI_API_KEY"); openaiKey != "" {
-		provider = NewOpenAIProvider(openaiKey)
-		fmt.Println("使用 OpenAI GPT-4o")
-	} else {
-		provider = NewAnthropicProvider()
-		fmt.Println("使用 Anthropic Claude")
+	roots, err := sandboxRoots(cfg)
+	if err != nil || roots == nil {
+		return enabled, err
 	}
+	return sandboxTools(enabled, roots), nil
+}
 
-	tools := []tools.ToolDefinition{tools.ReadFileDefinition}
-	scanner := bufio.NewScanner(os.Stdin)
-	getUserMessage := func() (string, bool) {
-		if !scanner.Scan() {
-			return "", false
-		}
-		return scanner.Text(), true
-	}
+// currentWorkspace confines the file tools to the directory the agent was
+// started in: absolute paths and paths leading out of it are rejected
+var currentWorkspace = &tools.Workspace{Root: "."}
 
-	agent := NewAgent(provider, getUserMessage, tools)
-	err := agent.Run(context.TODO())
-	if err != nil {
-		fmt.Printf("Error: %s\n\n", err)
-	}
+// builtinTools returns the registered tools that need no configuration,
+// working in the current directory
+func builtinTools() []tools.ToolDefinition {
+	return tools.Default.Snapshot(tools.Env{Workspace: currentWorkspace})
 }
 
 func NewAgent(provider AIProvider, getUserMessage func() (string, bool), tools []tools.ToolDefinition) *Agent {
@@ -219,6 +221,9 @@ func NewAgent(provider AIProvider, getUserMessage func() (string, bool), tools [
 		provider:       provider,
 		getUserMessage: getUserMessage,
 		tools:          tools,
+		session:        NewSession(),
+		config:         config.Default(),
+		usageTracker:   usageTracker{started: time.Now()},
 	}
 }
 
@@ -226,65 +231,569 @@ type Agent struct {
 	provider       AIProvider
 	getUserMessage func() (string, bool)
 	tools          []tools.ToolDefinition
+	conversation   []Message
+
+	// mu guards conversation, tools, stats and usageTracker, which front-ends
+	// read while a turn runs; turn makes turns of the agent run one at a time
+	mu   sync.Mutex
+	turn sync.Mutex
+
+	// session mirrors conversation and is persisted to store after every turn
+	session *Session
+	store   SessionStore
+
+	// budget is nil when spending is unlimited
+	budget *Budget
+
+	// interrupts cancels the running turn on Ctrl-C; nil disables it
+	interrupts *interruptHandler
+
+	// repo is set when auto-commit mode is enabled
+	repo *gitutil.Repo
+
+	// input reads user lines in the background while Run is active
+	input *inputQueue
+	// steering holds messages sent to the running turn with steer, guarded
+	// by mu
+	steering []string
+	// inputShowsPrompt is set when getUserMessage renders its own prompt (readline)
+	inputShowsPrompt bool
+
+	// markdown renders assistant replies; nil prints them raw
+	markdown *markdownRenderer
+	// highlight enables syntax highlighting of file contents returned by tools
+	highlight bool
+	// progress shows a spinner with elapsed time while waiting on inference or tools
+	progress bool
+
+	// onEvent receives turn events instead of them being printed (e.g. by the TUI)
+	onEvent func(AgentEvent)
+	// approve, when set, is asked before each tool that changes state runs;
+	// an error denies the call and is reported to the model
+	approve func(ctx context.Context, call ToolCall) error
+	// confirm asks the user a yes/no question during a turn (the REPL and the
+	// TUI); nil when nobody can answer, and the question counts as declined
+	confirm func(ctx context.Context, question string) bool
+	// overBudget is set when the user agreed to go over the exhausted budget,
+	// until the turn ends
+	overBudget bool
+	// notify, when set, tells the user a long turn finished or waits for
+	// approval; bell asks for the terminal bell as well
+	notify func(body string, bell bool)
+	// postProcessors are the names of the built-in processors replies pass
+	// through, from the config or /postprocess
+	postProcessors []string
+	// toolChoice controls tool use in the first inference of each turn
+	// (/tool-choice, run --tool-choice); later steps are auto, so forcing a
+	// tool cannot loop
+	toolChoice provider.ToolChoice
+	// streamText requests replies as a stream and emits each piece of text as
+	// a text_delta event before the whole reply (serve)
+	streamText bool
+
+	// config is the loaded configuration (defaults when none was loaded)
+	config *config.Config
+	// instructions are sent as a system message ahead of the conversation
+	instructions string
+	// knowledge is the repository's notes from earlier sessions, sent after
+	// the instructions (see knowledge.go)
+	knowledge string
+	// customSections are the system prompt sections the config adds or
+	// overrides, by name (see system.go)
+	customSections map[string]string
+	// repoIndex is the watched index of the workspace the repo map lists the
+	// files of, nil unless system.repo_map is set
+	repoIndex *search.Index
+	// pins are the files /pin keeps in context, relative to the workspace
+	pins []string
+	// gitContext summarizes the repository's recent changes for the model;
+	// it is captured when the config is applied and on /refresh
+	gitContext string
+	// resolveProfile recomputes the configuration for /profile; nil disables switching
+	resolveProfile func(name string) (*config.Config, error)
+
+	// stats feeds the status line shown after each turn
+	stats sessionStats
+	// usageTracker counts turns and tool calls for the usage report
+	usageTracker usageTracker
+	// showStatus prints the status line after each turn (interactive terminals only)
+	showStatus bool
+	// showTimings prints the timing breakdown after each turn (--timings, /timings)
+	showTimings bool
+	// showTimestamps shows when messages were sent (timestamps in the config, /timestamps)
+	showTimestamps bool
+	// reviewEdits asks hunk by hunk before edit_file writes anything
+	// (--review-edits, /review-edits)
+	reviewEdits bool
+	// timer times the running turn
+	timer *turnTimer
+	// requests keeps the provider requests of the last turn for /debug-last
+	requests *requestDump
+	// reads remembers the files read in this conversation; it is reset
+	// whenever messages are dropped, since the model no longer sees them
+	reads *tools.ReadCache
+	// scripts are the project scripts the tools and hooks come from, nil
+	// when the tools were not built from a config
+	scripts *script.Set
+	// streamedCall is the tool call whose output is being streamed to the
+	// terminal; streamedLine tells whether the output so far ends a line
+	streamedCall string
+	streamedLine bool
+
+	// nextMessage is set by commands like /prompt to send a message after they run
+	nextMessage string
+	// shellContext holds the output of !!commands until the next message
+	shellContext []string
+	// voice records messages and reads replies aloud; nil when voice mode is off
+	voice *voiceMode
+	// turnFiles and turnWrites are the files tools have accessed and
+	// changed in the current turn
+	turnFiles  map[string]bool
+	turnWrites map[string]bool
+	// commandRate limits shell commands per minute
+	commandRate rateLimiter
+	// audit records every tool call; nil disables the audit log
+	audit *auditLog
+	// crashes writes a diagnostic bundle when a turn fails unexpectedly; nil
+	// disables them
+	crashes *crashReporter
+	// journal records the changes to the conversation between saves of the
+	// session, to recover them after a crash; nil disables it
+	journal *sessionJournal
+
+	// logger writes diagnostics at the --log-level levels; nil discards them
+	logger *slog.Logger
+	// metrics counts inference calls and tool executions for /metrics; nil disables them
+	metrics *metrics
 }
 
-func (a Agent) Run(ctx context.Context) error {
-	conversation := []Message{}
+// maxTurnSteps bounds the number of inference calls in a single turn
+const maxTurnSteps = agentlib.DefaultMaxSteps
 
-	fmt.Println("Chat with Claude/GPT (use 'ctrl-c' to quit)")
+func (a *Agent) Run(ctx context.Context) error {
+	a.input = newInputQueue(a.getUserMessage)
+	defer func() { a.input = nil }()
+	if a.confirm == nil {
+		a.confirm = a.askYesNo
+		defer func() { a.confirm = nil }()
+	}
+
+	fmt.Println(i18n.T("Chat with Claude/GPT (use 'ctrl-c' to quit, /help for commands)"))
 	for {
-		fmt.Print("\u001b[94mYou\u001b[0m: ")
-		userInput, ok := a.getUserMessage()
+		if !a.inputShowsPrompt {
+			fmt.Print(inputPrompt())
+		}
+		userInput, ok := a.readInput()
 		if !ok {
 			break
 		}
+		if text, ok := steeringText(userInput); ok {
+			// typed as the turn ended; there is nothing left to steer
+			userInput = text
+		}
 
-		userMessage := Message{
-			Role:    "user",
-			Content: userInput,
+		if a.voice != nil && strings.TrimSpace(userInput) == "" {
+			text, err := a.listenInput(ctx)
+			if err != nil {
+				fmt.Printf("%s: %s\n", theme.Error(i18n.T("Error")), err)
+			}
+			if text == "" {
+				continue
+			}
+			userInput = text
 		}
-		conversation = append(conversation, userMessage)
+		if command, attach, ok := parseShellEscape(userInput); ok {
+			escapeCtx, done := a.interrupts.BeginTurn(ctx)
+			err := a.runShellEscape(escapeCtx, command, attach)
+			done()
+			if err != nil {
+				fmt.Printf("%s: %s\n", theme.Error(i18n.T("Error")), err)
+			}
+			continue
+		}
+		if handled, err := a.handleCommand(userInput); handled {
+			if err != nil {
+				fmt.Printf("%s: %s\n", theme.Error(i18n.T("Error")), err)
+			}
+			if a.nextMessage == "" {
+				continue
+			}
+			// Commands such as /prompt expand into a message for the model
+			userInput, a.nextMessage = a.nextMessage, ""
+			fmt.Printf("%s: %s\n", theme.User(i18n.T("You")), userInput)
+		}
+
+		turn, err := a.beginTurn(ctx, userInput)
+		if err != nil {
+			fmt.Println(i18n.T("Message not sent: session budget exhausted"))
+			continue
+		}
+
+		turnCtx, done := a.interrupts.BeginTurn(ctx)
+		a.input.SetBusy(true)
+		err = a.runUserTurn(ctx, turnCtx, turn)
+		a.input.SetBusy(false)
+		done()
 
-		response, err := a.provider.RunInference(ctx, conversation, a.tools)
+		if errors.Is(err, errTurnCancelled) {
+			fmt.Println(i18n.T("Turn cancelled"))
+			continue
+		}
+		if errors.Is(err, ErrBudgetExhausted) {
+			fmt.Println(i18n.T("Turn stopped: session budget exhausted"))
+			continue
+		}
 		if err != nil {
 			return err
 		}
+		if a.showStatus {
+			a.printStatus()
+		}
+	}
 
-		// Handle tool calls first
-		if len(response.ToolCalls) > 0 {
-			for _, toolCall := range response.ToolCalls {
-				// Find and execute the tool
-				for _, tool := range a.tools {
-					if tool.Name == toolCall.Name {
-						result, err := tool.Function(toolCall.Input)
-						if err != nil {
-							fmt.Printf("\u001b[91mTool Error\u001b[0m: %s\n", err)
-						} else {
-							fmt.Printf("\u001b[92mTool Result\u001b[0m: %s\n", result)
-						}
+	if a.voice != nil {
+		a.voice.close()
+		a.voice = nil
+	}
+	a.learnAtExit(ctx)
+	a.printUsage()
+	return nil
+}
 
-						// Add tool result to conversation
-						toolResultMessage := Message{
-							Role:    "assistant",
-							Content: fmt.Sprintf("Tool %s executed with result: %s", toolCall.Name, result),
-						}
-						conversation = append(conversation, toolResultMessage)
-						break
-					}
-				}
+// toolResultContent formats a tool result as conversation text, the same
+// way the library loop does
+func toolResultContent(name, result string) string {
+	return agentlib.ToolResultContent(name, tools.ToolResult{Text: result})
+}
+
+// syncSession copies the live conversation into the session
+func (a *Agent) syncSession() {
+	a.session.Messages = a.Messages()
+	a.session.Pins = a.Pins()
+	usage := a.usage()
+	a.session.Usage = &usage
+}
+
+// saveSession persists the current session if a store is configured
+func (a *Agent) saveSession() error {
+	a.syncSession()
+	if a.store == nil {
+		return nil
+	}
+	if err := a.store.Save(a.session); err != nil {
+		return err
+	}
+	if err := a.journal.clear(a.session.ID); err != nil {
+		a.log().Warn("session journal not removed", "error", err)
+	}
+	return nil
+}
+
+// runTurn sends a user message to the provider and keeps running inference
+// until the model stops calling tools. Concurrent calls wait for the running
+// turn to finish.
+func (a *Agent) runTurn(ctx context.Context, userInput string) (err error) {
+	a.turn.Lock()
+	defer a.turn.Unlock()
+	ctx, span := tracer().Start(ctx, "agent.turn")
+	var steps int
+	var turnUsage Usage
+	defer func() {
+		if a.session != nil {
+			span.SetAttributes(attribute.String("session.id", a.session.ID))
+		}
+		span.SetAttributes(
+			attribute.Int("agent.steps", steps),
+			attribute.Int64("gen_ai.usage.input_tokens", turnUsage.InputTokens),
+			attribute.Int64("gen_ai.usage.output_tokens", turnUsage.OutputTokens),
+		)
+		endSpan(span, err)
+	}()
+
+	userMessage := Message{
+		Role:    "user",
+		Content: userInput,
+		Meta:    message.Now(),
+	}
+	a.appendMessages(userMessage)
+	a.turnFiles, a.turnWrites = nil, nil
+	// going over the budget was agreed for this turn only
+	defer func() { a.overBudget = false }()
+	a.recordTurn()
+	a.timer = newTurnTimer()
+	if a.requests != nil {
+		a.requests.reset()
+	}
+	ctx = a.timer.trace(ctx)
+	defer func() {
+		if err == nil {
+			timing := a.timer.finish()
+			a.emit(AgentEvent{Type: EventTiming, Timing: &timing})
+		}
+	}()
+	defer func() {
+		elapsed := time.Since(a.timer.start).Round(time.Second)
+		switch {
+		case err == nil:
+			a.notifyIfLong(i18n.Sprintf("Turn finished after %s", elapsed))
+		case !errors.Is(err, context.Canceled):
+			a.notifyIfLong(i18n.Sprintf("Turn failed after %s: %v", elapsed, err))
+		}
+	}()
+	defer func() {
+		if unexpectedFailure(err) {
+			a.reportCrash(err)
+		}
+	}()
+	defer recoverPanic(&err)
+
+	a.reloadScripts()
+	continuations := 0
+	for step := 0; step < maxTurnSteps; step++ {
+		a.drainQueuedInput()
+		steps++
+
+		stopProgress := a.showProgress(i18n.T("thinking…"))
+		inferenceStart := time.Now()
+		inferenceCtx := ctx
+		if step == 0 && !a.toolChoice.IsAuto() {
+			inferenceCtx = provider.WithToolChoice(ctx, a.toolChoice)
+		}
+		if a.streamText {
+			inferenceCtx = provider.WithTextDelta(inferenceCtx, func(text string) {
+				a.emit(AgentEvent{Type: EventTextDelta, Content: text})
+			})
+		}
+		conversation, defs := a.requestConversation(), a.requestTools(a.toolDefinitions())
+		if err := a.checkContext(ctx, conversation, defs); err != nil {
+			stopProgress()
+			return err
+		}
+		if a.budget != nil && a.budget.Exhausted() {
+			stopProgress()
+			if err := a.checkBudget(ctx); err != nil {
+				return err
 			}
+			stopProgress = a.showProgress(i18n.T("thinking…"))
 		}
+		response, err := a.provider.RunInference(inferenceCtx, conversation, defs)
+		a.timer.recordInference(time.Since(inferenceStart))
+		stopProgress()
+		if err != nil {
+			return err
+		}
+		usage := response.Usage
+		turnUsage.InputTokens += usage.InputTokens
+		turnUsage.OutputTokens += usage.OutputTokens
+		a.recordUsage(response.Model, usage)
+		a.emit(AgentEvent{Type: EventUsage, Model: response.Model, Usage: &usage})
+		a.warnBudget()
 
 		// Display assistant response
 		if response.Content != "" {
-			fmt.Printf("\u001b[93mAssistant\u001b[0m: %s\n", response.Content)
+			response.Content = a.postProcess(ctx, response.Content)
+		}
+		if response.Content != "" {
+			a.emit(AgentEvent{Type: EventAssistantText, Content: response.Content})
 			assistantMessage := Message{
 				Role:    "assistant",
 				Content: response.Content,
+				Meta:    message.ResponseMeta(response),
 			}
-			conversation = append(conversation, assistantMessage)
+			a.appendMessages(assistantMessage)
+		}
+
+		switch {
+		case agentlib.ShouldContinue(response, continuations):
+			continuations++
+			a.log().Info("reply truncated, continuing", "continuation", continuations)
+			a.appendMessages(Message{Role: "user", Content: agentlib.ContinueContent, Meta: message.Now()})
+			continue
+		case response.StopReason == message.StopMaxTokens:
+			a.log().Warn("reply truncated", "tool_calls", len(response.ToolCalls), "continuations", continuations)
+			a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: the reply was cut off by the output token limit; raise max_tokens in the config if this keeps happening", theme.Error(i18n.T("Truncated")))})
+		case response.StopReason == message.StopRefusal:
+			a.log().Warn("model refused to reply")
+			a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: the model declined to reply", theme.Error(i18n.T("Refused")))})
+		}
+
+		if len(response.ToolCalls) == 0 && a.steered() {
+			// the user steered during the last inference; answer them in this turn
+			continue
+		}
+		if len(response.ToolCalls) == 0 {
+			timing := a.timer.finish()
+			a.log().Info("turn done", "steps", steps, "duration", time.Duration(timing.TotalMS)*time.Millisecond,
+				"first_token", time.Duration(timing.FirstTokenMS)*time.Millisecond,
+				"inference", time.Duration(timing.InferenceMS)*time.Millisecond,
+				"tools", time.Duration(timing.ToolsMS())*time.Millisecond)
+			return nil
+		}
+		if err := a.executeToolCalls(ctx, response.ToolCalls); err != nil {
+			return err
 		}
 	}
 
+	a.log().Warn("turn reached the step limit", "steps", maxTurnSteps, "duration", time.Duration(a.timer.finish().TotalMS)*time.Millisecond)
+	a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: turn reached the limit of %d inference steps", theme.Error(i18n.T("Stopped")), maxTurnSteps)})
+	return nil
+}
+
+// executeToolCalls runs each requested tool and appends its result to the conversation
+func (a *Agent) executeToolCalls(ctx context.Context, toolCalls []ToolCall) error {
+	log := a.logFor(subsystemTools)
+	for i, toolCall := range toolCalls {
+		if a.steered() {
+			log.Info("tool calls skipped for a steering message", "skipped", len(toolCalls)-i)
+			a.skipToolCalls(toolCalls[i:])
+			return nil
+		}
+		call := toolCall
+		found := false
+		// Find and execute the tool
+		for _, tool := range a.toolDefinitions() {
+			if tool.Name == toolCall.Name {
+				// Remember the target file of editing tools so the change can be shown as a diff
+				path, before := "", ""
+				if !tool.ReadOnly {
+					path = toolPathHint(toolCall.Input)
+					before = readFileOrEmpty(path)
+				}
+
+				a.emit(AgentEvent{Type: EventToolCall, ToolCall: &call})
+				log.Info("tool call", "tool", toolCall.Name, "id", toolCall.ID, "input", string(toolCall.Input))
+				start := time.Now()
+				toolCtx, span := tracer().Start(ctx, "execute_tool "+toolCall.Name, trace.WithAttributes(
+					attribute.String("gen_ai.tool.name", toolCall.Name),
+					attribute.String("gen_ai.tool.call.id", toolCall.ID),
+				))
+				stopProgress := a.showProgress(toolActivity(toolCall))
+				if a.approve != nil {
+					// the call is approved below before it runs
+					toolCtx = tools.Approved(toolCtx)
+				} else if a.confirm != nil {
+					toolCtx = tools.WithConfirm(toolCtx, func(ctx context.Context, question string) bool {
+						stopProgress()
+						return a.confirm(ctx, question)
+					})
+				}
+				err := a.checkLimits(tool, toolCall.Input)
+				if err == nil {
+					err = a.beforeToolHooks(call)
+				}
+				if err == nil && a.approve != nil && !tool.ReadOnly {
+					a.notifyIfLong(i18n.Sprintf("Waiting for approval to run %s", toolCall.Name))
+					err = a.approve(toolCtx, call)
+				}
+				input, rejected := toolCall.Input, ""
+				if err == nil && a.reviewEdits && toolCall.Name == "edit_file" {
+					stopProgress()
+					a.notifyIfLong(i18n.Sprintf("Waiting for approval to run %s", toolCall.Name))
+					input, rejected, err = a.reviewEdit(call)
+				}
+				var result tools.ToolResult
+				if err != nil {
+					result = tools.ErrorResult(err)
+				} else {
+					stream := &streamRedactor{emit: func(chunk string) {
+						if a.onEvent == nil {
+							// the spinner would garble the streamed lines
+							stopProgress()
+						}
+						a.emit(AgentEvent{Type: EventToolOutput, ToolCall: &call, Content: chunk})
+					}}
+					result = agentlib.RunToolRetrying(toolCtx, tool, input, stream.write)
+					stream.flush()
+					if rejected != "" && !result.IsError {
+						result.Text += "\n\n" + rejected
+					}
+					result = a.afterToolHooks(call, result)
+				}
+				stopProgress()
+				elapsed := time.Since(start).Round(time.Millisecond)
+				output, err := result.Output()
+				a.recordAudit(call, start, elapsed, output, err)
+				if errors.Is(err, context.Canceled) {
+					log.Info("tool call cancelled", "tool", toolCall.Name, "duration", elapsed)
+					endSpan(span, err)
+					return err
+				}
+				// Secrets in tool output never reach the provider, the session or the screen
+				result = agentlib.RedactResult(result)
+				output, err = result.Output()
+				if agentlib.FailureAction(result) == agentlib.ActionAbort {
+					// a service the tool needs is down; retrying in the next step would fail too
+					log.Warn("tool unavailable", "tool", toolCall.Name, "duration", elapsed, "error", err)
+					endSpan(span, err)
+					a.emit(AgentEvent{Type: EventToolError, ToolCall: &call, Content: err.Error(), ErrorKind: result.ErrorKind()})
+					return fmt.Errorf("%w: %s: %v", agentlib.ErrToolUnavailable, toolCall.Name, err)
+				}
+				span.SetAttributes(attribute.Int("agent.tool.result_bytes", len(output)))
+				endSpan(span, err)
+				a.metrics.observeTool(toolCall.Name, elapsed, err)
+				a.timer.recordTool(call, elapsed)
+				a.recordToolCall(toolCall.Name)
+				if err != nil {
+					log.Info("tool call failed", "tool", toolCall.Name, "duration", elapsed, "error", err)
+				} else {
+					log.Info("tool call done", "tool", toolCall.Name, "duration", elapsed, "result_bytes", len(output))
+					log.Debug("tool result", "tool", toolCall.Name, "result", truncate(output, maxLoggedPayload))
+				}
+				// Tools that change files report the diff; for the others it is
+				// taken from the file named in the input
+				unified := result.Diff
+				if unified != "" && len(result.Files) > 0 {
+					path = result.Files[0].Path
+				} else if err == nil {
+					unified = redact.String(fileDiff(path, before))
+				}
+				event := AgentEvent{ToolCall: &call, Files: result.Files, Metadata: result.Metadata, ErrorKind: result.ErrorKind()}
+				switch {
+				case err != nil:
+					event.Type, event.Content = EventToolError, err.Error()
+				case unified != "":
+					event.Type, event.Path, event.Diff = EventFileEdit, path, unified
+				default:
+					event.Type, event.Content = EventToolResult, output
+				}
+				a.emit(event)
+
+				// Tool results are input for the model's next inference step; on
+				// failure the model sees the error so it can correct itself
+				content := agentlib.ToolResultContent(toolCall.Name, result)
+				if changed := editedFiles(tool, result, path, unified, err); len(changed) > 0 {
+					// a broken build is reported with the edit that caused it, so
+					// the model fixes it before moving on
+					if failures := a.verifyFiles(ctx, changed); len(failures) > 0 {
+						content += "\n\n" + verifyReport(failures)
+						a.emit(AgentEvent{Type: EventNotice, Content: verifyNotice(failures)})
+					}
+				}
+				meta := message.Now()
+				meta.DurationMS = elapsed.Milliseconds()
+				toolResultMessage := Message{
+					Role:     "user",
+					Content:  content,
+					ToolCall: &call,
+					IsError:  err != nil,
+					Meta:     meta,
+				}
+				a.appendMessages(toolResultMessage)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			log.Info("unknown tool", "tool", toolCall.Name)
+			a.appendMessages(Message{
+				Role:     "user",
+				Content:  agentlib.ToolNotFoundContent(toolCall.Name),
+				ToolCall: &call,
+				IsError:  true,
+				Meta:     message.Now(),
+			})
+		}
+	}
 	return nil
 }
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"agent/config"
+	agentlib "agent/pkg/agent"
+	"agent/pkg/message"
 	"agent/tools"
 
 	"github.com/invopop/jsonschema"
@@ -101,6 +109,12 @@ func TestGenerateSchema(t *testing.T) {
 		assert.NotNil(t, schema)
 		t.Log("Generated schema:", schema)
 	})
+
+	t.Run("同一类型只生成一次", func(t *testing.T) {
+		first := tools.GenerateSchema[tools.ShellInput]()
+		second := tools.GenerateSchema[tools.ShellInput]()
+		assert.Same(t, first.Properties, second.Properties)
+	})
 }
 
 func TestGenerateSchemaProperties(t *testing.T) {
@@ -128,7 +142,8 @@ func TestGenerateSchemaProperties(t *testing.T) {
 func Test_ReadFileTool(t *testing.T) {
 	// 创建一个测试文件
 	testContent := "Hello, World!"
-	testFile := "/tmp/test_file.txt"
+	chdir(t, t.TempDir())
+	testFile := "test_file.txt"
 
 	err := os.WriteFile(testFile, []byte(testContent), 0644)
 	require.NoError(t, err)
@@ -139,8 +154,8 @@ func Test_ReadFileTool(t *testing.T) {
 	}()
 
 	// 测试 ReadFile 工具
-	input := `{"path": "/tmp/test_file.txt"}`
-	result, err := tools.ReadFile([]byte(input))
+	input := `{"path": "test_file.txt"}`
+	result, err := currentWorkspace.ReadFile(context.Background(), []byte(input))
 
 	assert.NoError(t, err)
 	assert.Equal(t, testContent, result)
@@ -160,3 +175,153 @@ func BenchmarkGenerateSchema(b *testing.B) {
 		}
 	})
 }
+
+func TestToolErrorReportedToModel(t *testing.T) {
+	cfg := config.Default()
+	cfg.Shell.Deny = []string{"make deploy"}
+	defs, err := enabledTools(cfg, nil)
+	require.NoError(t, err)
+
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{{ID: "1", Name: "shell", Input: json.RawMessage(`{"command":"make deploy"}`)}}},
+		{Content: "Deploying is not allowed here."},
+	}}
+	agent := NewAgent(provider, nil, defs)
+	agent.onEvent = func(AgentEvent) {}
+	require.NoError(t, agent.runTurn(context.Background(), "deploy it"))
+
+	last := provider.calls[1][len(provider.calls[1])-1]
+	assert.Contains(t, last.Content, "Tool shell was denied: command denied")
+	assert.Contains(t, last.Content, `"make deploy"`)
+	assert.Contains(t, last.Content, "Do not retry the same call", "被拒绝的调用不让模型原样重试")
+}
+
+func TestToolConfirm(t *testing.T) {
+	runtime := filepath.Join(t.TempDir(), "docker")
+	require.NoError(t, os.WriteFile(runtime, []byte("#!/bin/sh\necho \"$@\"\n"), 0755))
+	docker := tools.DockerTool(&tools.Workspace{Root: t.TempDir()}, &tools.Docker{Runtime: runtime})
+	newAgent := func() (*Agent, *mockProvider) {
+		provider := &mockProvider{responses: []*Response{
+			{ToolCalls: []ToolCall{{ID: "1", Name: "docker", Input: json.RawMessage(`{"action":"compose_up"}`)}}},
+			{Content: "完成"},
+		}}
+		agent := NewAgent(provider, nil, []tools.ToolDefinition{docker})
+		agent.onEvent = func(AgentEvent) {}
+		return agent, provider
+	}
+	lastResult := func(provider *mockProvider) string {
+		return provider.calls[1][len(provider.calls[1])-1].Content
+	}
+
+	t.Run("在交互式会话中询问用户", func(t *testing.T) {
+		agent, provider := newAgent()
+		var questions []string
+		agent.confirm = func(_ context.Context, question string) bool {
+			questions = append(questions, question)
+			return false
+		}
+		require.NoError(t, agent.runTurn(context.Background(), "启动服务"))
+		assert.Equal(t, []string{"Run " + runtime + " compose up --build --detach?"}, questions)
+		assert.Contains(t, lastResult(provider), "the user declined")
+	})
+
+	t.Run("没有人可以回答时拒绝", func(t *testing.T) {
+		agent, provider := newAgent()
+		require.NoError(t, agent.runTurn(context.Background(), "启动服务"))
+		assert.Contains(t, lastResult(provider), "needs the user's approval")
+	})
+
+	t.Run("批准过的调用不再询问", func(t *testing.T) {
+		agent, provider := newAgent()
+		agent.approve = func(context.Context, ToolCall) error { return nil }
+		agent.confirm = func(context.Context, string) bool {
+			t.Error("不应再次询问")
+			return false
+		}
+		require.NoError(t, agent.runTurn(context.Background(), "启动服务"))
+		assert.Contains(t, lastResult(provider), "compose up --build --detach")
+	})
+}
+
+func TestTransientToolErrorAbortsTurn(t *testing.T) {
+	calls := 0
+	flaky := tools.ToolDefinition{
+		Name: "status",
+		Function: func(context.Context, json.RawMessage) tools.ToolResult {
+			calls++
+			return tools.ErrorResult(tools.WithKind(tools.ErrorTransient, errors.New("service unavailable")))
+		},
+	}
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{{ID: "1", Name: "status", Input: json.RawMessage(`{}`)}}},
+	}}
+	agent := NewAgent(provider, nil, []tools.ToolDefinition{flaky})
+	var kinds []tools.ErrorKind
+	agent.onEvent = func(e AgentEvent) {
+		if e.Type == EventToolError {
+			kinds = append(kinds, e.ErrorKind)
+		}
+	}
+
+	err := agent.runTurn(context.Background(), "check the status")
+	assert.ErrorIs(t, err, agentlib.ErrToolUnavailable)
+	assert.ErrorContains(t, err, "status: service unavailable")
+	assert.Equal(t, 1, calls, "修改状态的工具不自动重试")
+	assert.Equal(t, []tools.ErrorKind{tools.ErrorTransient}, kinds)
+	assert.Len(t, provider.calls, 1, "不再请求模型")
+}
+
+func TestTruncatedReply(t *testing.T) {
+	var responses []*Response
+	for i := 0; i <= agentlib.MaxContinuations; i++ {
+		responses = append(responses, &Response{Content: fmt.Sprintf("part %d", i), StopReason: message.StopMaxTokens})
+	}
+	provider := &mockProvider{responses: responses}
+	agent := NewAgent(provider, nil, nil)
+	var notices []string
+	agent.onEvent = func(e AgentEvent) {
+		if e.Type == EventNotice {
+			notices = append(notices, e.Content)
+		}
+	}
+
+	require.NoError(t, agent.runTurn(context.Background(), "write a long story"))
+	assert.Len(t, provider.calls, agentlib.MaxContinuations+1, "被截断的回复自动继续")
+	assert.Equal(t, agentlib.ContinueContent, provider.calls[1][len(provider.calls[1])-1].Content)
+	require.Len(t, notices, 1, "继续的次数用完后提示用户")
+	assert.Contains(t, notices[0], "max_tokens")
+}
+
+func TestMessageMetadata(t *testing.T) {
+	agent := oneShotAgent()
+	agent.onEvent = func(AgentEvent) {}
+	require.NoError(t, agent.runTurn(context.Background(), "模块名是什么"))
+
+	messages := agent.Messages()
+	require.Len(t, messages, 4)
+	for _, msg := range messages {
+		require.NotNil(t, msg.Meta, msg.Content)
+		assert.False(t, msg.Meta.Time.IsZero())
+	}
+	assistant := messages[1].Meta
+	assert.Equal(t, "gpt-4o", assistant.Model)
+	assert.Equal(t, &Usage{InputTokens: 100, OutputTokens: 10}, assistant.Usage)
+	assert.Equal(t, []string{"1"}, assistant.ToolCallIDs, "助手消息记录它请求的工具调用")
+	assert.Equal(t, "1", messages[2].ToolCall.ID)
+	assert.Nil(t, messages[2].Meta.Usage)
+
+	t.Run("随会话保存", func(t *testing.T) {
+		require.NoError(t, agent.saveSession())
+		data, err := json.Marshal(agent.session)
+		require.NoError(t, err)
+		var saved Session
+		require.NoError(t, json.Unmarshal(data, &saved))
+		assert.Equal(t, assistant.Usage, saved.Messages[1].Meta.Usage)
+		assert.True(t, assistant.Time.Equal(saved.Messages[1].Meta.Time))
+	})
+
+	t.Run("/history 只接受 -v", func(t *testing.T) {
+		_, err := agent.handleCommand("/history --all")
+		assert.ErrorContains(t, err, "usage: /history [-v]")
+	})
+}
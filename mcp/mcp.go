@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"agent/tools"
+)
+
+// ProtocolVersion 是服务端支持的 MCP 协议版本，客户端请求其他版本时也以此回应
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC 错误码
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Server 通过 MCP 协议（基于 stdio 的 JSON-RPC 2.0，每行一条消息）提供工具
+type Server struct {
+	Name    string
+	Version string
+	Tools   []tools.ToolDefinition
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Tool 是 tools/list 返回的工具描述
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// Content 是工具结果中的一段内容，目前只有文本
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// CallResult 是 tools/call 的结果，工具出错时 IsError 为 true，错误信息放在内容中供模型阅读
+type CallResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Serve 从 in 逐行读取请求并把响应写到 out，直到 in 结束或 ctx 取消。
+// 每个请求在单独的 goroutine 中处理，响应顺序可能与请求不同
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(out)
+	send := func(resp response) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoder.Encode(resp)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			send(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{codeParseError, err.Error()}})
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			if req.ID != nil {
+				send(response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{codeInvalidRequest, "invalid JSON-RPC 2.0 request"}})
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(req request) {
+			defer wg.Done()
+			result, rerr := s.handle(ctx, req)
+			// 没有 id 的是通知，不需要回应
+			if req.ID == nil {
+				return
+			}
+			send(response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr})
+		}(req)
+	}
+	return scanner.Err()
+}
+
+func (s *Server) handle(ctx context.Context, req request) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+		}, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "tools/list":
+		list := make([]Tool, 0, len(s.Tools))
+		for _, tool := range s.Tools {
+			list = append(list, describe(tool))
+		}
+		return map[string]interface{}{"tools": list}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		for _, tool := range s.Tools {
+			if tool.Name == params.Name {
+				return call(ctx, tool, params.Arguments), nil
+			}
+		}
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name)}
+	default:
+		return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method %q not found", req.Method)}
+	}
+}
+
+// describe 把工具定义转换为 MCP 的工具描述
+func describe(tool tools.ToolDefinition) Tool {
+	schema := map[string]interface{}{"type": "object", "properties": tool.InputSchema.Properties}
+	if len(tool.InputSchema.Required) > 0 {
+		schema["required"] = tool.InputSchema.Required
+	}
+	return Tool{Name: tool.Name, Description: tool.Description, InputSchema: schema}
+}
+
+// call 执行工具，把结果包装为 CallResult，修改文件的 diff 作为第二段文本
+func call(ctx context.Context, tool tools.ToolDefinition, arguments json.RawMessage) CallResult {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	result := tool.Function(ctx, arguments)
+	content := []Content{{Type: "text", Text: result.Text}}
+	if result.Diff != "" {
+		content = append(content, Content{Type: "text", Text: result.Diff})
+	}
+	return CallResult{Content: content, IsError: result.IsError}
+}
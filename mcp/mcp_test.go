@@ -0,0 +1,116 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoInput struct {
+	Text string `json:"text" jsonschema_description:"Text to echo."`
+}
+
+var echoTool = tools.ToolDefinition{
+	Name:        "echo",
+	Description: "Echo text back.",
+	InputSchema: tools.GenerateSchema[echoInput](),
+	Function: func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+		var params echoInput
+		if err := json.Unmarshal(input, &params); err != nil {
+			return tools.ErrorResult(err)
+		}
+		if params.Text == "" {
+			return tools.ErrorResult(fmt.Errorf("text must not be empty"))
+		}
+		if params.Text == "edit" {
+			return tools.ToolResult{Text: "OK", Diff: "-a\n+b\n"}
+		}
+		return tools.ToolResult{Text: params.Text}
+	},
+}
+
+// serve 发送若干行请求，返回按 id 索引的响应
+func serve(t *testing.T, lines ...string) map[string]map[string]interface{} {
+	t.Helper()
+	server := &Server{Name: "test", Version: "1.0", Tools: []tools.ToolDefinition{echoTool}}
+	var out bytes.Buffer
+	require.NoError(t, server.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &out))
+
+	responses := map[string]map[string]interface{}{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var resp map[string]interface{}
+		require.NoError(t, decoder.Decode(&resp))
+		assert.Equal(t, "2.0", resp["jsonrpc"])
+		responses[fmt.Sprint(resp["id"])] = resp
+	}
+	return responses
+}
+
+func TestServe(t *testing.T) {
+	t.Run("初始化和工具列表", func(t *testing.T) {
+		responses := serve(t,
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"c","version":"1"}}}`,
+			`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+			`{"jsonrpc":"2.0","id":3,"method":"ping"}`,
+		)
+		require.Len(t, responses, 3, "通知没有响应")
+
+		init := responses["1"]["result"].(map[string]interface{})
+		assert.Equal(t, ProtocolVersion, init["protocolVersion"])
+		assert.Equal(t, "test", init["serverInfo"].(map[string]interface{})["name"])
+
+		list := responses["2"]["result"].(map[string]interface{})["tools"].([]interface{})
+		require.Len(t, list, 1)
+		tool := list[0].(map[string]interface{})
+		assert.Equal(t, "echo", tool["name"])
+		schema := tool["inputSchema"].(map[string]interface{})
+		assert.Equal(t, "object", schema["type"])
+		assert.Contains(t, schema["properties"], "text")
+		assert.Equal(t, []interface{}{"text"}, schema["required"])
+
+		assert.Equal(t, map[string]interface{}{}, responses["3"]["result"])
+	})
+
+	t.Run("调用工具", func(t *testing.T) {
+		responses := serve(t,
+			`{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"echo","arguments":{"text":"你好"}}}`,
+			`{"jsonrpc":"2.0","id":"b","method":"tools/call","params":{"name":"echo","arguments":{}}}`,
+			`{"jsonrpc":"2.0","id":"c","method":"tools/call","params":{"name":"missing"}}`,
+			`{"jsonrpc":"2.0","id":"d","method":"tools/call","params":{"name":"echo","arguments":{"text":"edit"}}}`,
+		)
+		ok := responses["a"]["result"].(map[string]interface{})
+		assert.Equal(t, "你好", ok["content"].([]interface{})[0].(map[string]interface{})["text"])
+		assert.Nil(t, ok["isError"])
+
+		failed := responses["b"]["result"].(map[string]interface{})
+		assert.Equal(t, true, failed["isError"], "工具错误作为结果返回")
+		assert.Contains(t, failed["content"].([]interface{})[0].(map[string]interface{})["text"], "must not be empty")
+
+		assert.Equal(t, float64(codeInvalidParams), responses["c"]["error"].(map[string]interface{})["code"])
+
+		edited := responses["d"]["result"].(map[string]interface{})["content"].([]interface{})
+		require.Len(t, edited, 2, "diff 作为第二段文本")
+		assert.Equal(t, "-a\n+b\n", edited[1].(map[string]interface{})["text"])
+	})
+
+	t.Run("协议错误", func(t *testing.T) {
+		responses := serve(t,
+			`not json`,
+			`{"jsonrpc":"2.0","id":7,"method":"resources/list"}`,
+			`{"id":8,"method":"ping"}`,
+		)
+		assert.Equal(t, float64(codeParseError), responses["<nil>"]["error"].(map[string]interface{})["code"])
+		assert.Equal(t, float64(codeMethodNotFound), responses["7"]["error"].(map[string]interface{})["code"])
+		assert.Equal(t, float64(codeInvalidRequest), responses["8"]["error"].(map[string]interface{})["code"])
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"agent/mcp"
+	agentlib "agent/pkg/agent"
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+)
+
+// mcpSession is the session name audit records of MCP tool calls are filed under
+const mcpSession = "mcp"
+
+func newMCPServeCommand(global *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "mcp-serve",
+		Short: "Serve the built-in tools over MCP on stdin/stdout, for Claude Desktop, IDEs and other MCP clients",
+		Long: `Serve the built-in tools over the Model Context Protocol on stdin/stdout.
+
+Tools run in the current directory with the same configuration as a chat:
+disabled tools, sandbox paths, shell allow/deny lists, the container backend
+and size limits all apply. Results are redacted and every call is recorded
+in the audit log.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defs, err := enabledTools(global.cfg(), nil)
+			if err != nil {
+				return err
+			}
+			audit, err := defaultAuditLog(global.cfg())
+			if err != nil {
+				global.logger(cmd.ErrOrStderr(), slog.LevelWarn).Warn("audit log disabled", "error", err)
+			}
+			server := &mcp.Server{Name: "code-editing-agent", Version: "0.1.0", Tools: mcpTools(defs, audit)}
+			return server.Serve(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+}
+
+// mcpTools wraps tools so that MCP clients get the same redaction and audit
+// log as the agent's own tool calls
+func mcpTools(defs []tools.ToolDefinition, audit *auditLog) []tools.ToolDefinition {
+	wrapped := make([]tools.ToolDefinition, len(defs))
+	for i, def := range defs {
+		def := def
+		run := def.Function
+		// MCP results are sent whole, so only Function is used
+		def.Stream = nil
+		def.Function = func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+			start := time.Now()
+			result := run(ctx, input)
+			if audit != nil {
+				call := ToolCall{ID: fmt.Sprintf("mcp-%d", start.UnixNano()), Name: def.Name, Input: input}
+				text, err := result.Output()
+				audit.Append(newAuditRecord(mcpSession, call, start, time.Since(start), text, err))
+			}
+			return agentlib.RedactResult(result)
+		}
+		wrapped[i] = def
+	}
+	return wrapped
+}
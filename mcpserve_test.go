@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMCPServeCommand(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	require.NoError(t, os.WriteFile(".env", []byte("API_TOKEN=supersecretvalue\n"), 0644))
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("tools:\n  disabled: [shell]\naudit:\n  path: audit.jsonl\n"), 0644))
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"read_file","arguments":{"path":".env"}}}`,
+	}, "\n")
+	var out bytes.Buffer
+	require.NoError(t, runCommand(newRootCommand(), strings.NewReader(in), &out, "mcp-serve", "--config", configPath))
+
+	text := out.String()
+	var responses []map[string]interface{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var resp map[string]interface{}
+		require.NoError(t, decoder.Decode(&resp))
+		responses = append(responses, resp)
+	}
+	require.Len(t, responses, 2)
+	assert.NotContains(t, text, `"shell"`, "配置中禁用的工具不提供")
+	assert.Contains(t, text, `"read_file"`)
+	assert.Contains(t, text, "API_TOKEN=[REDACTED:secret]")
+	assert.NotContains(t, text, "supersecretvalue")
+
+	records := readAuditLog(t, filepath.Join(dir, "audit.jsonl"))
+	require.Len(t, records, 1)
+	assert.Equal(t, mcpSession, records[0].Session)
+	assert.Equal(t, "read_file", records[0].Tool)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agent/i18n"
+	"agent/theme"
+)
+
+// mentionHeader introduces the files attached for @mentions in a message
+const mentionHeader = "Files the user mentioned with @, attached so you need not read them:"
+
+var (
+	// mentionPattern finds @path mentions at the start of the message or
+	// after whitespace, so e-mail addresses are left alone
+	mentionPattern = regexp.MustCompile(`(?:^|\s)@([^\s@]+)`)
+	// mentionLines splits a :N or :N-M line range off a mention
+	mentionLines = regexp.MustCompile(`^(.+):(\d+)(?:-(\d+))?$`)
+)
+
+// mention is a file mentioned in a message, with the lines to attach; from
+// and to are 1-based and inclusive, 0 for the whole file
+type mention struct {
+	path     string
+	from, to int
+}
+
+func (m mention) String() string {
+	switch {
+	case m.from == 0:
+		return m.path
+	case m.to == m.from:
+		return fmt.Sprintf("%s:%d", m.path, m.from)
+	default:
+		return fmt.Sprintf("%s:%d-%d", m.path, m.from, m.to)
+	}
+}
+
+// parseMention reads a mention as path, path:N or path:N-M
+func parseMention(token string) mention {
+	m := mention{path: token}
+	if parts := mentionLines.FindStringSubmatch(token); parts != nil {
+		m.path = parts[1]
+		m.from, _ = strconv.Atoi(parts[2])
+		m.to = m.from
+		if parts[3] != "" {
+			m.to, _ = strconv.Atoi(parts[3])
+		}
+	}
+	return m
+}
+
+// expandMentions attaches the files mentioned with @ in text to it, saving
+// the model a read_file call for each. Mentions that are not files, like
+// @someone, stay as they are; trailing punctuation is dropped when the path
+// without it is the file. It returns the message to send, the mentions it
+// attached and the reasons the others could not be.
+func (a *Agent) expandMentions(text string) (string, []string, []error) {
+	var attached []string
+	var failed []error
+	var b strings.Builder
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		token := match[1]
+		for {
+			content, m, err := a.readMention(token)
+			if err == nil {
+				if !seen[m.String()] {
+					seen[m.String()] = true
+					attached = append(attached, m.String())
+					fmt.Fprintf(&b, "\n\n--- %s\n%s", m, strings.TrimRight(content, "\n"))
+				}
+				break
+			}
+			trimmed := strings.TrimRight(token, ".,;:!?)]}'\"")
+			if errors.Is(err, fs.ErrNotExist) && trimmed != token && trimmed != "" {
+				token = trimmed
+				continue
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				failed = append(failed, fmt.Errorf("@%s: %w", token, err))
+			}
+			break
+		}
+	}
+	if len(attached) == 0 {
+		return text, nil, failed
+	}
+	return text + "\n\n" + mentionHeader + b.String(), attached, failed
+}
+
+// attachMentions expands the @mentions of a message typed in the REPL or
+// the TUI and tells the user which files went with it
+func (a *Agent) attachMentions(text string) string {
+	expanded, attached, failed := a.expandMentions(text)
+	if len(attached) > 0 {
+		a.emit(AgentEvent{Type: EventNotice, Content: theme.Muted(i18n.Sprintf("Attached %s", strings.Join(attached, ", ")))})
+	}
+	for _, err := range failed {
+		a.emit(AgentEvent{Type: EventNotice, Content: fmt.Sprintf("%s: %s", theme.Warning(i18n.T("Not attached")), err)})
+	}
+	return expanded
+}
+
+// readMention reads the file, or the lines of it, a mention refers to
+func (a *Agent) readMention(token string) (string, mention, error) {
+	m := parseMention(token)
+	m.path = filepath.ToSlash(filepath.Clean(m.path))
+	content, err := a.readPinned(m.path)
+	if err != nil || m.from == 0 {
+		return content, m, err
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if m.from > len(lines) || m.to < m.from {
+		return "", m, fmt.Errorf("lines %d-%d are out of range (1-%d)", m.from, m.to, len(lines))
+	}
+	m.to = min(m.to, len(lines))
+	return strings.Join(lines[m.from-1:m.to], ""), m, nil
+}
+
+// mentionCompleter completes @mentions in the line editor with the files and
+// directories of the workspace, one path segment at a time like a shell
+type mentionCompleter struct {
+	// root returns the directory of the workspace, which /cd can change
+	root func() string
+}
+
+// Do implements readline.AutoCompleter: it returns the endings of the
+// matching names for the @mention before the cursor, and how many runes of
+// the mention they complete
+func (c mentionCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && line[start-1] != '@' && line[start-1] != ' ' && line[start-1] != '\t' {
+		start--
+	}
+	if start == 0 || line[start-1] != '@' || (start > 1 && line[start-2] != ' ' && line[start-2] != '\t') {
+		return nil, 0
+	}
+	typed := string(line[start:pos])
+	dir, base := filepath.Split(filepath.FromSlash(typed))
+	entries, err := os.ReadDir(filepath.Join(c.root(), dir))
+	if err != nil {
+		return nil, 0
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		} else {
+			name += " "
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	completions := make([][]rune, len(names))
+	for i, name := range names {
+		completions[i] = []rune(name[len(base):])
+	}
+	return completions, len([]rune(base))
+}
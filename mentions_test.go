@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandMentions(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n\nfunc main() {\n\tprintln(1)\n}\n"), 0644))
+	require.NoError(t, os.MkdirAll("pkg", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("pkg", "api.go"), []byte("package pkg\n"), 0644))
+	agent := NewAgent(nil, nil, nil)
+
+	t.Run("附上提到的文件", func(t *testing.T) {
+		text, attached, failed := agent.expandMentions("look at @main.go and @./pkg/api.go, then @main.go again")
+		assert.Empty(t, failed)
+		assert.Equal(t, []string{"main.go", "pkg/api.go"}, attached, "去掉结尾的标点，重复的只附上一次")
+		assert.Contains(t, text, "look at @main.go and")
+		assert.Contains(t, text, mentionHeader+"\n\n--- main.go\npackage main\n")
+		assert.Contains(t, text, "--- pkg/api.go\npackage pkg")
+	})
+
+	t.Run("只附上指定的行", func(t *testing.T) {
+		text, attached, _ := agent.expandMentions("@main.go:3-4 is wrong")
+		assert.Equal(t, []string{"main.go:3-4"}, attached)
+		assert.Contains(t, text, "--- main.go:3-4\nfunc main() {\n\tprintln(1)")
+		assert.NotContains(t, text, "package main")
+
+		_, attached, _ = agent.expandMentions("@main.go:2-99")
+		assert.Equal(t, []string{"main.go:2-5"}, attached, "超出的行数截到文件末尾")
+
+		_, attached, failed := agent.expandMentions("@main.go:9")
+		assert.Empty(t, attached)
+		require.Len(t, failed, 1)
+		assert.ErrorContains(t, failed[0], "out of range")
+	})
+
+	t.Run("不是文件的提及保持原样", func(t *testing.T) {
+		text, attached, failed := agent.expandMentions("ask @someone or mail me@main.go")
+		assert.Equal(t, "ask @someone or mail me@main.go", text)
+		assert.Empty(t, attached)
+		assert.Empty(t, failed)
+	})
+
+	t.Run("工作区之外的文件不能附上", func(t *testing.T) {
+		_, attached, failed := agent.expandMentions("@../secret.txt")
+		assert.Empty(t, attached)
+		assert.Len(t, failed, 1)
+	})
+
+	t.Run("沙箱之外的文件不能附上", func(t *testing.T) {
+		agent := NewAgent(nil, nil, nil)
+		agent.config.Sandbox.Paths = []string{"pkg"}
+		_, attached, failed := agent.expandMentions("@main.go @pkg/api.go")
+		assert.Equal(t, []string{"pkg/api.go"}, attached)
+		require.Len(t, failed, 1)
+		assert.ErrorContains(t, failed[0], "outside the sandbox")
+	})
+
+	t.Run("附上的内容遮盖密钥", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(".env", []byte("OPENAI_API_KEY=sk-proj-ABCDEFGHIJKLMNOPQRSTUVWX\n"), 0644))
+		text, attached, _ := agent.expandMentions("@.env")
+		assert.Equal(t, []string{".env"}, attached)
+		assert.NotContains(t, text, "sk-proj-ABCDEFGHIJKLMNOPQRSTUVWX")
+		assert.Contains(t, text, "[REDACTED:")
+	})
+
+	t.Run("runPrompt 不展开提及", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{responses: []*Response{{Content: "好的"}}}, nil, nil)
+		require.NoError(t, runPrompt(context.Background(), agent, "看看 @main.go", outputJSON, io.Discard))
+		assert.Equal(t, "看看 @main.go", agent.conversation[0].Content, "webhook 的 issue 内容不能读取文件")
+	})
+}
+
+func TestMentionCompleter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pkg", "provider"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pkg", "api.go"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), nil, 0644))
+	c := mentionCompleter{root: func() string { return dir }}
+	complete := func(line string) ([]string, int) {
+		candidates, length := c.Do([]rune(line), len([]rune(line)))
+		var names []string
+		for _, candidate := range candidates {
+			names = append(names, string(candidate))
+		}
+		return names, length
+	}
+
+	t.Run("补全目录中的文件和子目录", func(t *testing.T) {
+		names, length := complete("see @pkg/")
+		assert.Equal(t, []string{"api.go ", "provider/"}, names)
+		assert.Equal(t, 0, length)
+
+		names, length = complete("@pkg/pro")
+		assert.Equal(t, []string{"vider/"}, names)
+		assert.Equal(t, 3, length)
+	})
+
+	t.Run("不补全隐藏文件，除非输入了点", func(t *testing.T) {
+		names, _ := complete("@")
+		assert.Equal(t, []string{"main.go ", "pkg/"}, names)
+		names, _ = complete("@.e")
+		assert.Equal(t, []string{"nv "}, names)
+	})
+
+	t.Run("不在提及中时不补全", func(t *testing.T) {
+		names, _ := complete("main")
+		assert.Empty(t, names)
+		names, _ = complete("me@ma")
+		assert.Empty(t, names)
+	})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/tools"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the Prometheus metrics of a server, served on /metrics
+type metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	inferences    *prometheus.CounterVec
+	inferenceTime *prometheus.HistogramVec
+	tokens        *prometheus.CounterVec
+	tools         *prometheus.CounterVec
+	toolTime      *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_http_requests_total",
+			Help: "HTTP requests served, by route and status code.",
+		}, []string{"method", "route", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_http_request_duration_seconds",
+			Help:    "Time to serve HTTP requests, by route; event streams count until they close.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inferences: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_inference_requests_total",
+			Help: "Inference calls to the model provider, by status (ok or error).",
+		}, []string{"provider", "model", "status"}),
+		inferenceTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_inference_duration_seconds",
+			Help:    "Latency of inference calls.",
+			Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120},
+		}, []string{"provider", "model"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_tokens_total",
+			Help: "Tokens used by inference calls, by type (input or output).",
+		}, []string{"provider", "model", "type"}),
+		tools: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_tool_executions_total",
+			Help: "Tool executions, by status (ok or error).",
+		}, []string{"tool", "status"}),
+		toolTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_tool_duration_seconds",
+			Help:    "Time to run tools, including waiting for approval.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"tool"}),
+	}
+	m.registry.MustRegister(m.httpRequests, m.httpDuration, m.inferences, m.inferenceTime, m.tokens, m.tools, m.toolTime,
+		collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// handler serves the metrics in the Prometheus exposition format
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// serve routes /metrics to the metrics and everything else to next, which
+// it instruments
+func (m *metrics) serve(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.handler())
+	mux.Handle("/", m.instrument(next))
+	return mux
+}
+
+// instrument counts and times the requests next serves
+func (m *metrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		route := routeLabel(r.URL.Path)
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Inc()
+		m.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder remembers the status code of a response. It keeps
+// flushing for event streams and hijacking for WebSockets working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not support hijacking")
+	}
+	// a hijacked connection answers with 101 Switching Protocols
+	r.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// routeLabel replaces the IDs in a request path with placeholders, so that
+// each route is one label value
+func routeLabel(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		switch parts[i-1] {
+		case "sessions":
+			parts[i] = "{id}"
+		case "approvals":
+			parts[i] = "{call}"
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// observeTool records a tool execution; it does nothing on nil metrics
+func (m *metrics) observeTool(name string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.tools.WithLabelValues(name, metricStatus(err)).Inc()
+	m.toolTime.WithLabelValues(name).Observe(elapsed.Seconds())
+}
+
+func metricStatus(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// MetricsMiddleware counts inference calls, their latency and tokens by
+// provider and model. Failed calls have no model in the response, so they
+// are counted with an empty model.
+func MetricsMiddleware(m *metrics, provider string) Middleware {
+	return func(next AIProvider) AIProvider {
+		return ProviderFunc(func(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
+			start := time.Now()
+			response, err := next.RunInference(ctx, conversation, tools)
+			model := ""
+			if response != nil {
+				model = response.Model
+			}
+			m.inferences.WithLabelValues(provider, model, metricStatus(err)).Inc()
+			m.inferenceTime.WithLabelValues(provider, model).Observe(time.Since(start).Seconds())
+			if err == nil {
+				m.tokens.WithLabelValues(provider, model, "input").Add(float64(response.Usage.InputTokens))
+				m.tokens.WithLabelValues(provider, model, "output").Add(float64(response.Usage.OutputTokens))
+			}
+			return response, err
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteLabel(t *testing.T) {
+	assert.Equal(t, "/sessions", routeLabel("/sessions"))
+	assert.Equal(t, "/sessions/{id}/events", routeLabel("/sessions/abc123/events"))
+	assert.Equal(t, "/sessions/{id}/approvals/{call}", routeLabel("/sessions/abc123/approvals/toolu_1"))
+	assert.Equal(t, "/webhook", routeLabel("/webhook"))
+}
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	store := &FileSessionStore{Dir: t.TempDir()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api := newAPIServer(ctx, store, func() (*Agent, error) {
+		agent := oneShotAgent()
+		agent.provider = Chain(agent.provider, MetricsMiddleware(m, "openai"))
+		agent.metrics = m
+		agent.store = store
+		return agent, nil
+	})
+	server := httptest.NewServer(m.serve(api.Handler()))
+	defer server.Close()
+
+	var created map[string]string
+	require.Equal(t, http.StatusCreated, doJSON(t, "POST", server.URL+"/sessions", "", &created))
+	doJSON(t, "POST", server.URL+"/sessions/"+created["id"]+"/messages", `{"content": "模块名是什么？"}`, nil)
+	readEvents(t, server.URL+"/sessions/"+created["id"]+"/events?since=0")
+	doJSON(t, "GET", server.URL+"/sessions/missing", "", nil)
+
+	resp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	out := string(data)
+
+	assert.Contains(t, out, `agent_http_requests_total{code="201",method="POST",route="/sessions"} 1`)
+	assert.Contains(t, out, `agent_http_requests_total{code="404",method="GET",route="/sessions/{id}"} 1`)
+	assert.Contains(t, out, `agent_inference_requests_total{model="gpt-4o",provider="openai",status="ok"} 2`)
+	assert.Contains(t, out, `agent_tokens_total{model="gpt-4o",provider="openai",type="input"} 300`)
+	assert.Contains(t, out, `agent_tool_executions_total{status="ok",tool="read_file"} 1`)
+	assert.Contains(t, out, `agent_tool_duration_seconds_count{tool="read_file"} 1`)
+	assert.Contains(t, out, "go_goroutines")
+}
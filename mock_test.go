@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mockProvider 按顺序返回预设的响应，并记录每次调用收到的对话
+type mockProvider struct {
+	responses []*Response
+	calls     [][]Message
+}
+
+func (m *mockProvider) RunInference(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
+	m.calls = append(m.calls, append([]Message(nil), conversation...))
+	if len(m.responses) == 0 {
+		return nil, fmt.Errorf("mock provider: no more responses")
+	}
+	response := m.responses[0]
+	m.responses = m.responses[1:]
+	return response, nil
+}
+
+// chdir 在测试期间切换工作目录，文件工具的工作区随之切换
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"agent/config"
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+)
+
+// modelInfo is what the agent knows about a model: the context window that
+// sets when the conversation is too long, the features it can be sent, and
+// the prices of its tokens
+type modelInfo struct {
+	// prefix matches the model names the entry covers, e.g. dated snapshots
+	prefix string
+	// contextWindow is in tokens, 0 if unknown
+	contextWindow int64
+	// tools is whether the model supports tool calls
+	tools bool
+	// vision is whether the model accepts image input
+	vision bool
+	// input and output are prices in USD per million tokens, 0 if unknown
+	input, output float64
+}
+
+// builtinModels is matched by prefix, so more specific names must come first
+var builtinModels = []modelInfo{
+	{prefix: "gpt-4o-mini", contextWindow: 128000, tools: true, vision: true, input: 0.15, output: 0.60},
+	{prefix: "gpt-4o", contextWindow: 128000, tools: true, vision: true, input: 2.50, output: 10.00},
+	{prefix: "gpt-4.1-mini", contextWindow: 1047576, tools: true, vision: true, input: 0.40, output: 1.60},
+	{prefix: "gpt-4.1", contextWindow: 1047576, tools: true, vision: true, input: 2.00, output: 8.00},
+	{prefix: "o1-mini", contextWindow: 128000, input: 1.10, output: 4.40},
+	{prefix: "claude-3-7-sonnet", contextWindow: 200000, tools: true, vision: true, input: 3.00, output: 15.00},
+	{prefix: "claude-3-5-haiku", contextWindow: 200000, tools: true, vision: true, input: 0.80, output: 4.00},
+	{prefix: "claude-", contextWindow: 200000, tools: true, vision: true},
+}
+
+// configuredModels are the models: entries of the config, which take
+// precedence over the built-in ones; set when the config is loaded
+var configuredModels []config.Model
+
+// lookupModel returns what is known about model, from the config or the
+// built-in table
+func lookupModel(model string) (modelInfo, bool) {
+	if model == "" {
+		return modelInfo{}, false
+	}
+	for _, m := range configuredModels {
+		if strings.HasPrefix(model, m.Name) {
+			return configuredModel(m), true
+		}
+	}
+	for _, m := range builtinModels {
+		if strings.HasPrefix(model, m.prefix) {
+			return m, true
+		}
+	}
+	return modelInfo{}, false
+}
+
+func configuredModel(m config.Model) modelInfo {
+	return modelInfo{prefix: m.Name, contextWindow: m.ContextWindow, tools: !m.NoTools, vision: m.Vision, input: m.InputPrice, output: m.OutputPrice}
+}
+
+// contextWindow returns the context limit of model in tokens, or 0 if unknown
+func contextWindow(model string) int64 {
+	info, _ := lookupModel(model)
+	return info.contextWindow
+}
+
+// estimateCost returns the cost of usage in USD, or 0 for unknown models
+func estimateCost(model string, usage Usage) float64 {
+	info, _ := lookupModel(model)
+	return (float64(usage.InputTokens)*info.input + float64(usage.OutputTokens)*info.output) / 1e6
+}
+
+// requestModel is the model the next request goes to: the configured one,
+// or the one that answered last when the provider picks its default
+func (a *Agent) requestModel() string {
+	if a.config != nil && a.config.Model != "" {
+		return a.config.Model
+	}
+	return a.sessionStats().Model
+}
+
+// requestTools drops the tools from requests to models known not to support
+// tool calls, which would reject the request or ignore the tools
+func (a *Agent) requestTools(defs []tools.ToolDefinition) []tools.ToolDefinition {
+	model := a.requestModel()
+	if info, ok := lookupModel(model); ok && !info.tools && len(defs) > 0 {
+		a.log().Warn("model does not support tools; sending none", "model", model)
+		return nil
+	}
+	return defs
+}
+
+// writeModels writes the registry as a table, configured models first
+func writeModels(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tCONTEXT\tTOOLS\tVISION\tUSD/M IN\tUSD/M OUT\tSOURCE")
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	write := func(m modelInfo, source string) {
+		context, prices := "?", []string{"?", "?"}
+		if m.contextWindow > 0 {
+			context = formatTokens(m.contextWindow)
+		}
+		if m.input > 0 || m.output > 0 {
+			prices = []string{fmt.Sprintf("%.2f", m.input), fmt.Sprintf("%.2f", m.output)}
+		}
+		fmt.Fprintf(tw, "%s*\t%s\t%s\t%s\t%s\t%s\t%s\n", m.prefix, context, yesNo(m.tools), yesNo(m.vision), prices[0], prices[1], source)
+	}
+	for _, m := range configuredModels {
+		write(configuredModel(m), "config")
+	}
+	for _, m := range builtinModels {
+		write(m, "built-in")
+	}
+	tw.Flush()
+}
+
+func newModelsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "models",
+		Short: "List the models the agent knows the context window, features and prices of",
+		Long: `List the models the agent knows: their context window, which sets when a
+conversation is too long; whether they support tool calls (models that do not
+are sent no tools) and image input; and their prices, used to estimate costs
+and enforce budgets. Names match by prefix. Add or correct entries, e.g. for
+local models, with models: in the config.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			writeModels(cmd.OutOrStdout())
+			return nil
+		},
+	}
+}
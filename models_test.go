@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupModel(t *testing.T) {
+	t.Run("按前缀匹配，更具体的条目优先", func(t *testing.T) {
+		info, ok := lookupModel("gpt-4o-mini-2024-07-18")
+		require.True(t, ok)
+		assert.Equal(t, "gpt-4o-mini", info.prefix)
+		assert.Equal(t, int64(200000), contextWindow("claude-sonnet-4-20250514"))
+		assert.Zero(t, contextWindow("unknown-model"))
+	})
+
+	t.Run("配置的模型优先于内置的条目", func(t *testing.T) {
+		configuredModels = []config.Model{{Name: "llama3", ContextWindow: 8192, NoTools: true}, {Name: "gpt-4o", ContextWindow: 64000, InputPrice: 1}}
+		t.Cleanup(func() { configuredModels = nil })
+
+		info, ok := lookupModel("llama3.1:8b")
+		require.True(t, ok)
+		assert.Equal(t, int64(8192), info.contextWindow)
+		assert.False(t, info.tools)
+		assert.Equal(t, int64(64000), contextWindow("gpt-4o-2024-08-06"))
+		assert.InDelta(t, 1.0, estimateCost("gpt-4o", Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}), 1e-9)
+
+		var b strings.Builder
+		writeModels(&b)
+		assert.Contains(t, b.String(), "llama3*")
+		assert.Less(t, strings.Index(b.String(), "llama3*"), strings.Index(b.String(), "gpt-4o-mini*"), "配置的模型排在前面")
+	})
+}
+
+func TestRequestTools(t *testing.T) {
+	agent := NewAgent(&mockProvider{}, nil, builtinTools())
+	defs := agent.toolDefinitions()
+	require.NotEmpty(t, defs)
+
+	t.Run("支持工具的模型照常发送工具", func(t *testing.T) {
+		agent.config.Model = "gpt-4o"
+		assert.Equal(t, defs, agent.requestTools(defs))
+	})
+
+	t.Run("不支持工具的模型不发送工具", func(t *testing.T) {
+		agent.config.Model = "o1-mini"
+		assert.Empty(t, agent.requestTools(defs))
+	})
+
+	t.Run("未配置模型时按上次回复的模型判断", func(t *testing.T) {
+		agent.config.Model = ""
+		agent.recordUsage("o1-mini-2024-09-12", Usage{InputTokens: 10})
+		assert.Empty(t, agent.requestTools(defs))
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import "strings"
+
+const heredocDelimiter = `"""`
+
+// multilineInput wraps a line reader so a single prompt can span several lines:
+// a trailing backslash continues onto the next line, and a line starting with
+// """ opens a block that runs until a line ending with """. This lets users
+// paste code and stack traces as one message. onContinue, if set, is called
+// with true while more lines are expected and false once the message is complete.
+func multilineInput(next func() (string, bool), onContinue func(bool)) func() (string, bool) {
+	setContinue := func(more bool) {
+		if onContinue != nil {
+			onContinue(more)
+		}
+	}
+
+	return func() (string, bool) {
+		line, ok := next()
+		if !ok {
+			return "", false
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), heredocDelimiter) {
+			return readHeredoc(strings.TrimSpace(line)[len(heredocDelimiter):], next, setContinue), true
+		}
+
+		lines := []string{}
+		for strings.HasSuffix(line, `\`) {
+			lines = append(lines, strings.TrimSuffix(line, `\`))
+			setContinue(true)
+			more, ok := next()
+			if !ok {
+				line = ""
+				break
+			}
+			line = more
+		}
+		setContinue(false)
+		return strings.Join(append(lines, line), "\n"), true
+	}
+}
+
+// readHeredoc collects lines until the closing delimiter; first is the text after the opening one
+func readHeredoc(first string, next func() (string, bool), setContinue func(bool)) string {
+	defer setContinue(false)
+
+	lines := []string{}
+	line := first
+	for {
+		if strings.HasSuffix(strings.TrimRight(line, " \t"), heredocDelimiter) {
+			if rest := strings.TrimSuffix(strings.TrimRight(line, " \t"), heredocDelimiter); rest != "" {
+				lines = append(lines, rest)
+			}
+			return strings.Join(lines, "\n")
+		}
+		if line != "" || len(lines) > 0 {
+			lines = append(lines, line)
+		}
+
+		setContinue(true)
+		more, ok := next()
+		if !ok {
+			return strings.Join(lines, "\n")
+		}
+		line = more
+	}
+}
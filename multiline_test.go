@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultilineInput(t *testing.T) {
+	read := func(lines ...string) []string {
+		next := multilineInput(scriptedInput(lines...), nil)
+		messages := []string{}
+		for {
+			msg, ok := next()
+			if !ok {
+				return messages
+			}
+			messages = append(messages, msg)
+		}
+	}
+
+	t.Run("单行输入保持不变", func(t *testing.T) {
+		assert.Equal(t, []string{"hello", "world"}, read("hello", "world"))
+	})
+
+	t.Run("反斜杠续行", func(t *testing.T) {
+		assert.Equal(t, []string{"line one\nline two\nline three", "next"},
+			read(`line one\`, `line two\`, "line three", "next"))
+	})
+
+	t.Run("三引号块", func(t *testing.T) {
+		assert.Equal(t, []string{"fix this:\n\tpanic: boom\n\nat main.go:3", "after"},
+			read(`"""`, "fix this:", "\tpanic: boom", "", "at main.go:3", `"""`, "after"))
+	})
+
+	t.Run("三引号块首尾带内容", func(t *testing.T) {
+		assert.Equal(t, []string{"first\nlast"}, read(`"""first`, `last"""`))
+		assert.Equal(t, []string{"inline"}, read(`"""inline"""`))
+	})
+
+	t.Run("块内的反斜杠不续行", func(t *testing.T) {
+		assert.Equal(t, []string{`a \` + "\nb"}, read(`"""`, `a \`, "b", `"""`))
+	})
+
+	t.Run("输入提前结束", func(t *testing.T) {
+		assert.Equal(t, []string{"partial\n"}, read(`partial\`))
+		assert.Equal(t, []string{"open block"}, read(`"""`, "open block"))
+	})
+
+	t.Run("续行回调", func(t *testing.T) {
+		states := []bool{}
+		next := multilineInput(scriptedInput(`a\`, "b"), func(more bool) { states = append(states, more) })
+		msg, ok := next()
+		assert.True(t, ok)
+		assert.Equal(t, "a\nb", msg)
+		assert.Equal(t, []bool{true, false}, states)
+	})
+}
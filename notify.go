@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// desktopNotifyTimeout bounds the command showing a desktop notification
+const desktopNotifyTimeout = 5 * time.Second
+
+// notifyIfLong tells the user, who may have switched to another window
+// during a long autonomous run, that the turn needs them again: it finished
+// or waits for approval. Turns shorter than notify.after stay quiet.
+func (a *Agent) notifyIfLong(body string) {
+	cfg := a.config.Notify
+	if a.notify == nil || cfg.Disabled || a.timer == nil || time.Since(a.timer.start) < cfg.After {
+		return
+	}
+	a.notify(body, cfg.Bell)
+}
+
+// terminalNotifier is the notify function of interactive sessions: it shows
+// a desktop notification when the system has a way to, and rings the
+// terminal bell when asked to
+func terminalNotifier(logger *slog.Logger) func(body string, bell bool) {
+	desktop := desktopNotifier()
+	return func(body string, bell bool) {
+		if bell {
+			fmt.Fprint(os.Stdout, "\a")
+		}
+		if desktop == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), desktopNotifyTimeout)
+		defer cancel()
+		if err := desktop(ctx, "agent", body); err != nil {
+			logger.Debug("desktop notification failed", "error", err)
+		}
+	}
+}
+
+// desktopNotifier returns a function showing a desktop notification with
+// osascript on macOS and notify-send elsewhere, or nil when neither exists
+func desktopNotifier() func(ctx context.Context, title, body string) error {
+	if runtime.GOOS == "darwin" {
+		return func(ctx context.Context, title, body string) error {
+			// AppleScript strings use the same quotes and escapes as Go's
+			script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(body), strconv.Quote(title))
+			return exec.CommandContext(ctx, "osascript", "-e", script).Run()
+		}
+	}
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return nil
+	}
+	return func(ctx context.Context, title, body string) error {
+		return exec.CommandContext(ctx, "notify-send", title, body).Run()
+	}
+}
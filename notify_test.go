@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyLongTurns(t *testing.T) {
+	newAgent := func(after time.Duration, responses ...*Response) (*Agent, *[]string) {
+		agent := NewAgent(&mockProvider{responses: responses}, nil, builtinTools())
+		agent.onEvent = func(AgentEvent) {}
+		agent.config.Notify.After = after
+		notified := &[]string{}
+		agent.notify = func(body string, bell bool) {
+			assert.False(t, bell, "默认不响铃")
+			*notified = append(*notified, body)
+		}
+		return agent, notified
+	}
+
+	t.Run("超过时长的一轮结束时提醒", func(t *testing.T) {
+		agent, notified := newAgent(0, &Response{Content: "done"})
+		require.NoError(t, agent.runTurn(context.Background(), "hi"))
+		require.Len(t, *notified, 1)
+		assert.Contains(t, (*notified)[0], "Turn finished after")
+	})
+
+	t.Run("短的一轮不提醒", func(t *testing.T) {
+		agent, notified := newAgent(time.Hour, &Response{Content: "done"})
+		require.NoError(t, agent.runTurn(context.Background(), "hi"))
+		assert.Empty(t, *notified)
+	})
+
+	t.Run("关闭提醒", func(t *testing.T) {
+		agent, notified := newAgent(0, &Response{Content: "done"})
+		agent.config.Notify.Disabled = true
+		require.NoError(t, agent.runTurn(context.Background(), "hi"))
+		assert.Empty(t, *notified)
+	})
+
+	t.Run("等待批准时提醒", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		input, err := json.Marshal(tools.EditFileInput{Path: "a.txt", NewStr: "x"})
+		require.NoError(t, err)
+		agent, notified := newAgent(0,
+			&Response{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: input}}},
+			&Response{Content: "denied"},
+		)
+		agent.approve = func(ctx context.Context, call ToolCall) error { return errors.New("no") }
+		require.NoError(t, agent.runTurn(context.Background(), "create a.txt"))
+		assert.Equal(t, "Waiting for approval to run edit_file", (*notified)[0])
+		assert.Contains(t, (*notified)[1], "Turn finished after")
+		_, err = os.Stat("a.txt")
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats for `agent run`
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+)
+
+// EventResult is the final event of a one-shot run in json/jsonl output
+const EventResult = "result"
+
+// runResult summarizes a one-shot run for programs orchestrating the agent
+type runResult struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Result  string `json:"result"`
+	Error   string `json:"error,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Usage   Usage  `json:"usage"`
+	Session string `json:"session_id,omitempty"`
+	// Events holds the whole run for -output json; jsonl streams them instead
+	Events []AgentEvent `json:"events,omitempty"`
+}
+
+func newRunCommand(global *globalOptions) *cobra.Command {
+	var output, resume, template, summary, toolChoice string
+	var ci bool
+	var budgetTokens int64
+	var budgetUSD float64
+	cmd := &cobra.Command{
+		Use:   "run [prompt | -t template key=value...]",
+		Short: "Run a single prompt to completion and exit",
+		Long: "Run a single prompt to completion and exit. The prompt is read from stdin when omitted or \"-\".\n" +
+			"With --template, the arguments fill the template's {{placeholders}} instead.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch output {
+			case outputText, outputJSON, outputJSONL:
+			default:
+				return fmt.Errorf("unknown output format %q", output)
+			}
+			if ci && output != outputText {
+				return fmt.Errorf("--ci writes its own log; use --summary for machine-readable output")
+			}
+			if summary != "" && !ci {
+				return fmt.Errorf("--summary requires --ci")
+			}
+			if ci {
+				global.cfg().Color = "never"
+				if err := applyTheme(global.cfg()); err != nil {
+					return err
+				}
+			}
+
+			prompt := strings.Join(args, " ")
+			if template != "" {
+				store, err := defaultPromptStore(global.cfg())
+				if err != nil {
+					return err
+				}
+				if prompt, err = renderPromptTemplate(store, template, args); err != nil {
+					return err
+				}
+			} else if prompt == "" || prompt == "-" {
+				data, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				prompt = string(data)
+			}
+			prompt = strings.TrimSpace(prompt)
+			if prompt == "" {
+				return fmt.Errorf("no prompt given")
+			}
+
+			logger := global.logger(os.Stderr, slog.LevelWarn)
+			agent := NewAgent(nil, nil, nil)
+			agent.logger = logger
+			if budgetTokens > 0 || budgetUSD > 0 {
+				agent.budget = &Budget{MaxTokens: budgetTokens, MaxCost: budgetUSD, Strict: ci}
+			}
+			if _, err := agent.applyConfig(global.cfg()); err != nil {
+				return err
+			}
+			if err := agent.setToolChoice(toolChoice); err != nil {
+				return err
+			}
+			if store, err := DefaultSessionStore(); err == nil {
+				agent.store = store
+				agent.journal, _ = defaultSessionJournal()
+			}
+			audit, err := defaultAuditLog(global.cfg())
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Audit log disabled: %s\n", err)
+			}
+			agent.audit = audit
+			agent.crashes, _ = defaultCrashReporter()
+			if resume != "" {
+				if agent.store == nil {
+					return fmt.Errorf("cannot resume without a session store")
+				}
+				session, recovery, err := agent.loadSession(resume)
+				if err != nil {
+					return err
+				}
+				agent.resumeSession(session)
+				if recovery != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), journalNotice(recovery))
+				}
+			}
+
+			// only a prompt the user typed attaches files; runPrompt also runs
+			// issue bodies from the webhook, which anyone can write
+			prompt, _, failed := agent.expandMentions(prompt)
+			for _, err := range failed {
+				logger.Warn("mention not attached", "error", err)
+			}
+
+			if ci {
+				return runCI(cmd.Context(), agent, prompt, cmd.OutOrStdout(), summary)
+			}
+			return runPrompt(cmd.Context(), agent, prompt, output, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", outputText, "output format: text, json (one object at the end) or jsonl (streamed events)")
+	cmd.Flags().StringVar(&resume, "resume", "", "continue a saved session by ID")
+	cmd.Flags().StringVarP(&template, "template", "t", "", "use a saved prompt template from the config directory")
+	cmd.Flags().Int64Var(&budgetTokens, "budget-tokens", 0, "maximum tokens to spend on the run (0 = unlimited)")
+	cmd.Flags().Float64Var(&budgetUSD, "budget-usd", 0, "maximum estimated cost in USD for the run (0 = unlimited)")
+	cmd.Flags().BoolVar(&ci, "ci", false, "run as a CI step: plain log with collapsible sections, and an exit code of 1 on failure or 2 on budget overrun")
+	cmd.Flags().StringVar(&toolChoice, "tool-choice", "auto", "tool use at the start of the run: auto, required, none, or a tool the model must call first, e.g. edit_file")
+	cmd.Flags().StringVar(&summary, "summary", "", "with --ci, write a JSON summary of the run to this file")
+	return cmd
+}
+
+// record updates the result with the final reply and the usage of a run's event
+func (r *runResult) record(e AgentEvent) {
+	switch e.Type {
+	case EventAssistantText:
+		r.Result = e.Content
+	case EventUsage:
+		r.Model = e.Model
+		r.Usage.InputTokens += e.Usage.InputTokens
+		r.Usage.OutputTokens += e.Usage.OutputTokens
+		r.Usage.CachedTokens += e.Usage.CachedTokens
+	}
+}
+
+// runPrompt runs a single turn and writes its events in the given format
+func runPrompt(ctx context.Context, agent *Agent, prompt, format string, out io.Writer) error {
+	result := runResult{Type: EventResult}
+	encoder := json.NewEncoder(out)
+
+	var streamErr error
+	if format != outputText {
+		agent.onEvent = func(e AgentEvent) {
+			if e.Type == EventActivity {
+				// spinner updates are only meaningful on a terminal
+				return
+			}
+			result.record(e)
+			if format == outputJSONL {
+				if err := encoder.Encode(e); err != nil && streamErr == nil {
+					streamErr = err
+				}
+				return
+			}
+			result.Events = append(result.Events, e)
+		}
+		defer func() { agent.onEvent = nil }()
+	}
+
+	err := agent.runTurn(ctx, prompt)
+	if saveErr := agent.saveSession(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if agent.store != nil {
+		result.Session = agent.session.ID
+	}
+	if format == outputText {
+		return err
+	}
+
+	result.Status = "success"
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	if encodeErr := encoder.Encode(result); encodeErr != nil {
+		return encodeErr
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func oneShotAgent() *Agent {
+	provider := &mockProvider{responses: []*Response{
+		{
+			Content:   "先读文件",
+			ToolCalls: []ToolCall{{ID: "1", Name: "read_file", Input: []byte(`{"path":"go.mod"}`)}},
+			Model:     "gpt-4o",
+			Usage:     Usage{InputTokens: 100, OutputTokens: 10},
+		},
+		{Content: "模块名是 agent", Model: "gpt-4o", Usage: Usage{InputTokens: 200, OutputTokens: 20}},
+	}}
+	return NewAgent(provider, nil, builtinTools())
+}
+
+func TestRunPrompt(t *testing.T) {
+	t.Run("jsonl 逐行输出事件并以结果结尾", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runPrompt(context.Background(), oneShotAgent(), "模块名是什么？", outputJSONL, &out))
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		types := []string{}
+		for _, line := range lines {
+			var e map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &e), line)
+			types = append(types, e["type"].(string))
+		}
+		assert.Equal(t, []string{
+			EventUsage, EventAssistantText, EventToolCall, EventToolResult,
+			EventUsage, EventAssistantText, EventTiming, EventResult,
+		}, types)
+
+		var result runResult
+		require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &result))
+		assert.Equal(t, "success", result.Status)
+		assert.Equal(t, "模块名是 agent", result.Result)
+		assert.Equal(t, int64(300), result.Usage.InputTokens)
+		assert.Empty(t, result.Events)
+	})
+
+	t.Run("json 输出单个对象", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runPrompt(context.Background(), oneShotAgent(), "模块名是什么？", outputJSON, &out))
+
+		var result runResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		assert.Equal(t, "success", result.Status)
+		assert.Equal(t, "gpt-4o", result.Model)
+		assert.Len(t, result.Events, 7)
+		assert.Equal(t, "read_file", result.Events[2].ToolCall.Name)
+	})
+
+	t.Run("失败时报告错误状态", func(t *testing.T) {
+		var out bytes.Buffer
+		agent := NewAgent(ProviderFunc(func(context.Context, []Message, []tools.ToolDefinition) (*Response, error) {
+			return nil, fmt.Errorf("rate limited")
+		}), nil, nil)
+
+		err := runPrompt(context.Background(), agent, "你好", outputJSON, &out)
+		require.Error(t, err)
+
+		var result runResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		assert.Equal(t, "error", result.Status)
+		assert.Equal(t, "rate limited", result.Error)
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"agent/i18n"
+	"agent/redact"
+	"agent/tools"
+)
+
+// pinnedHeader introduces the pinned files in the request conversation
+const pinnedHeader = "Pinned files. The user keeps these files in your context: below are their current contents, refreshed before every request, so do not read them again with tools."
+
+// pinWorkspace reads pinned files with the same confinement and size limit
+// as read_file, on the remote machine when working remotely
+func (a *Agent) pinWorkspace() *tools.Workspace {
+	w := a.hookWorkspace()
+	if remote := a.config.Remote; remote.Host != "" {
+		w.Remote = &tools.Remote{Host: remote.Host, Root: remote.Root, Port: remote.Port, Identity: remote.Identity}
+	}
+	return w
+}
+
+// readPinned returns the current contents of a pinned file. Like read_file
+// it is held to the sandbox, and secrets are masked before the contents go
+// to the model.
+func (a *Agent) readPinned(path string) (string, error) {
+	roots, err := sandboxRoots(a.config)
+	if err != nil {
+		return "", err
+	}
+	if roots != nil && !insideRoots(path, roots) {
+		return "", fmt.Errorf("path %s is outside the sandbox (%s)", path, strings.Join(roots, ", "))
+	}
+	input, err := json.Marshal(tools.ReadFileInput{Path: path})
+	if err != nil {
+		return "", err
+	}
+	content, err := a.pinWorkspace().ReadFile(context.Background(), input)
+	return redact.String(content), err
+}
+
+// Pins returns the pinned files, in the order they were pinned
+func (a *Agent) Pins() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.pins)
+}
+
+func (a *Agent) setPins(pins []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pins = slices.Clone(pins)
+}
+
+// pinnedContext is the system message carrying the pinned files, read
+// afresh so the model always sees their latest contents; it is empty when
+// nothing is pinned. A file that can no longer be read stays pinned and the
+// model is told why it is missing.
+func (a *Agent) pinnedContext() string {
+	pins := a.Pins()
+	if len(pins) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(pinnedHeader)
+	for _, path := range pins {
+		content, err := a.readPinned(path)
+		if err != nil {
+			content = fmt.Sprintf("(cannot be read: %s)", err)
+		}
+		fmt.Fprintf(&b, "\n\n--- %s\n%s", path, strings.TrimRight(content, "\n"))
+	}
+	return b.String()
+}
+
+// runPinCommand lists the pinned files, or pins more of them
+func runPinCommand(a *Agent, args []string) error {
+	if len(args) == 0 {
+		pins := a.Pins()
+		if len(pins) == 0 {
+			fmt.Println(i18n.T("No pinned files; pin one with /pin PATH"))
+		}
+		for _, path := range pins {
+			fmt.Printf("  %s\n", path)
+		}
+		return nil
+	}
+	pins := a.Pins()
+	for _, arg := range args {
+		path := filepath.ToSlash(filepath.Clean(arg))
+		if slices.Contains(pins, path) {
+			continue
+		}
+		if _, err := a.readPinned(path); err != nil {
+			return err
+		}
+		pins = append(pins, path)
+		fmt.Println(i18n.Sprintf("Pinned %s", path))
+	}
+	a.setPins(pins)
+	return nil
+}
+
+// runUnpinCommand removes files from the pinned ones, or all of them
+func runUnpinCommand(a *Agent, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: /unpin PATH…|all")
+	}
+	if len(args) == 1 && args[0] == "all" {
+		a.setPins(nil)
+		fmt.Println(i18n.T("Unpinned all files"))
+		return nil
+	}
+	pins := a.Pins()
+	for _, arg := range args {
+		path := filepath.ToSlash(filepath.Clean(arg))
+		if !slices.Contains(pins, path) {
+			return fmt.Errorf("%s is not pinned", path)
+		}
+		pins = slices.DeleteFunc(pins, func(p string) bool { return p == path })
+		fmt.Println(i18n.Sprintf("Unpinned %s", path))
+	}
+	a.setPins(pins)
+	return nil
+}
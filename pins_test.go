@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinCommands(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+	require.NoError(t, os.MkdirAll("pkg", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("pkg", "api.go"), []byte("package pkg\n"), 0644))
+	agent := NewAgent(nil, nil, nil)
+	agent.instructions = "be brief"
+	agent.appendMessages(Message{Role: "user", Content: "hi"})
+
+	_, err := agent.handleCommand("/pin main.go ./pkg/api.go main.go")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.go", "pkg/api.go"}, agent.Pins(), "重复的文件只固定一次")
+
+	conversation := agent.requestConversation()
+	require.Len(t, conversation, 3)
+	assert.Equal(t, "be brief", conversation[0].Content)
+	assert.Equal(t, "system", conversation[1].Role)
+	assert.Contains(t, conversation[1].Content, "--- main.go\npackage main")
+	assert.Contains(t, conversation[1].Content, "--- pkg/api.go\npackage pkg")
+
+	t.Run("每次请求读取最新内容", func(t *testing.T) {
+		require.NoError(t, os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644))
+		assert.Contains(t, agent.requestConversation()[1].Content, "func main() {}")
+
+		require.NoError(t, os.Remove(filepath.Join("pkg", "api.go")))
+		assert.Contains(t, agent.requestConversation()[1].Content, "--- pkg/api.go\n(cannot be read:")
+	})
+
+	t.Run("不能固定工作区之外或不存在的文件", func(t *testing.T) {
+		_, err := agent.handleCommand("/pin ../secret.txt")
+		assert.Error(t, err)
+		_, err = agent.handleCommand("/pin missing.go")
+		assert.Error(t, err)
+		assert.Len(t, agent.Pins(), 2)
+	})
+
+	t.Run("取消固定", func(t *testing.T) {
+		_, err := agent.handleCommand("/unpin pkg/api.go")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"main.go"}, agent.Pins())
+		_, err = agent.handleCommand("/unpin pkg/api.go")
+		assert.ErrorContains(t, err, "not pinned")
+
+		_, err = agent.handleCommand("/unpin all")
+		require.NoError(t, err)
+		assert.Empty(t, agent.Pins())
+		assert.Len(t, agent.requestConversation(), 2, "没有固定的文件时不发送这条系统消息")
+	})
+
+	t.Run("恢复会话时保留固定的文件", func(t *testing.T) {
+		agent.setPins([]string{"main.go"})
+		agent.syncSession()
+		resumed := NewAgent(nil, nil, nil)
+		resumed.resumeSession(agent.session)
+		assert.Equal(t, []string{"main.go"}, resumed.Pins())
+	})
+}
@@ -0,0 +1,317 @@
+// Package agent 提供可嵌入其他 Go 程序的 agent 循环：把用户消息交给模型，
+// 执行模型请求的工具并把结果交回模型，直到模型不再调用工具。
+//
+//	p := provider.NewAnthropic()
+//	a := agent.New(p,
+//		agent.WithTools((&tools.Workspace{Root: "."}).Tools()...),
+//		agent.WithSystemPrompt("You are a careful Go reviewer."),
+//	)
+//	reply, err := a.Send(ctx, "What does main.go do?")
+//
+// 命令行程序在这个循环之外还提供会话保存、预算、审计等功能，但执行工具和格式化
+// 工具结果的方式与这里相同
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"agent/pkg/message"
+	"agent/pkg/provider"
+	"agent/tools"
+)
+
+// DefaultMaxSteps 是一轮对话中默认最多的推理次数
+const DefaultMaxSteps = 25
+
+// MaxContinuations 是回复因输出长度上限被截断时，一轮对话中最多自动继续生成的次数
+const MaxContinuations = 3
+
+// ContinueContent 是请模型接着被截断的回复继续生成的用户消息
+const ContinueContent = "Your reply was cut off by the output token limit. Continue exactly where it stopped, without repeating anything."
+
+// 事件类型，与命令行程序的事件同名
+const (
+	EventAssistantText = "assistant_text"
+	EventToolCall      = "tool_call"
+	EventToolResult    = "tool_result"
+	EventToolError     = "tool_error"
+	EventUsage         = "usage"
+)
+
+// ErrMaxSteps 表示一轮对话达到了推理次数上限，模型仍在调用工具
+var ErrMaxSteps = errors.New("turn reached the inference step limit")
+
+// ErrToolUnavailable 表示工具在自动重试后仍以暂时性错误失败，这一轮对话因此结束
+var ErrToolUnavailable = errors.New("tool unavailable")
+
+// Event 描述一轮对话中发生的事情，用于显示进度
+type Event struct {
+	Type     string
+	Content  string
+	ToolCall *message.ToolCall
+	Model    string
+	Usage    *message.Usage
+	// Result 是 tool_result 和 tool_error 事件的结构化结果
+	Result *tools.ToolResult
+}
+
+// Reply 是一轮对话的结果
+type Reply struct {
+	// Content 是模型最后一次回复的文本，被截断后自动继续生成的部分接在后面
+	Content string
+	// StopReason 是最后一次推理的停止原因，取 message.Stop 常量。仍为
+	// message.StopMaxTokens 时回复在自动继续 MaxContinuations 次后仍被截断
+	StopReason string
+	// Steps 是这一轮的推理次数
+	Steps int
+	// Usage 是这一轮所有推理的 token 用量之和
+	Usage message.Usage
+}
+
+// Agent 保存对话历史并运行 agent 循环。Agent 可以被多个 goroutine 同时使用：
+// 并发的 Send 依次执行，Messages、Usage 等方法在一轮对话进行时也可以调用。
+// 不同的 Agent 之间没有共享的状态，一个进程可以同时运行多个会话
+type Agent struct {
+	provider provider.Provider
+	system   string
+	maxSteps int
+	onEvent  func(Event)
+	approve  func(ctx context.Context, call message.ToolCall) error
+
+	// turn 使 Send 依次执行，mu 保护下面的字段
+	turn     sync.Mutex
+	mu       sync.Mutex
+	tools    []tools.ToolDefinition
+	messages []message.Message
+	usage    message.Usage
+}
+
+// Option 配置 New 创建的 Agent
+type Option func(*Agent)
+
+// WithTools 设置模型可以调用的工具
+func WithTools(defs ...tools.ToolDefinition) Option {
+	return func(a *Agent) { a.tools = append(a.tools, defs...) }
+}
+
+// WithSystemPrompt 设置每次推理时放在对话之前的系统消息
+func WithSystemPrompt(prompt string) Option {
+	return func(a *Agent) { a.system = prompt }
+}
+
+// WithMaxSteps 设置一轮对话中最多的推理次数
+func WithMaxSteps(n int) Option {
+	return func(a *Agent) { a.maxSteps = n }
+}
+
+// WithEventHandler 设置接收事件的函数，它在调用 Send 的 goroutine 中被调用
+func WithEventHandler(fn func(Event)) Option {
+	return func(a *Agent) { a.onEvent = fn }
+}
+
+// WithApproval 设置在每个会修改状态的工具执行前调用的函数，
+// 返回错误时拒绝这次调用，错误会作为工具结果告诉模型
+func WithApproval(fn func(ctx context.Context, call message.ToolCall) error) Option {
+	return func(a *Agent) { a.approve = fn }
+}
+
+// WithMessages 设置初始的对话历史，例如恢复之前保存的对话
+func WithMessages(messages []message.Message) Option {
+	return func(a *Agent) { a.messages = append([]message.Message{}, messages...) }
+}
+
+// New 创建使用 p 进行推理的 Agent
+func New(p provider.Provider, opts ...Option) *Agent {
+	a := &Agent{provider: p, maxSteps: DefaultMaxSteps}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Messages 返回对话历史的副本，不包括系统消息
+func (a *Agent) Messages() []message.Message {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]message.Message{}, a.messages...)
+}
+
+// Reset 清空对话历史，进行中的一轮对话结束后才生效
+func (a *Agent) Reset() {
+	a.turn.Lock()
+	defer a.turn.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages = nil
+}
+
+// Usage 返回所有完成和进行中的推理的 token 用量之和
+func (a *Agent) Usage() message.Usage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usage
+}
+
+// Tools 返回模型可以调用的工具
+func (a *Agent) Tools() []tools.ToolDefinition {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]tools.ToolDefinition{}, a.tools...)
+}
+
+// SetTools 替换模型可以调用的工具，从下一次推理开始生效
+func (a *Agent) SetTools(defs ...tools.ToolDefinition) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tools = append([]tools.ToolDefinition{}, defs...)
+}
+
+// Send 发送一条用户消息并运行 agent 循环，直到模型不再调用工具。
+// 出错或被取消时这一轮的消息会被丢弃，对话历史保持不变。
+// 另一轮对话正在进行时，Send 等它结束后再开始
+func (a *Agent) Send(ctx context.Context, input string) (*Reply, error) {
+	a.turn.Lock()
+	defer a.turn.Unlock()
+	a.mu.Lock()
+	checkpoint := len(a.messages)
+	a.mu.Unlock()
+	reply, err := a.send(ctx, input)
+	if err != nil {
+		a.mu.Lock()
+		a.messages = a.messages[:checkpoint]
+		a.mu.Unlock()
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (a *Agent) send(ctx context.Context, input string) (*Reply, error) {
+	a.appendMessage(message.Message{Role: "user", Content: input, Meta: message.Now()})
+	reply := &Reply{}
+	continuations := 0
+	for reply.Steps < a.maxSteps {
+		reply.Steps++
+		response, err := a.provider.RunInference(ctx, a.conversation(), a.Tools())
+		if err != nil {
+			return nil, err
+		}
+		usage := response.Usage
+		reply.Usage.InputTokens += usage.InputTokens
+		reply.Usage.OutputTokens += usage.OutputTokens
+		reply.Usage.CachedTokens += usage.CachedTokens
+		a.mu.Lock()
+		a.usage.InputTokens += usage.InputTokens
+		a.usage.OutputTokens += usage.OutputTokens
+		a.usage.CachedTokens += usage.CachedTokens
+		a.mu.Unlock()
+		a.emit(Event{Type: EventUsage, Model: response.Model, Usage: &usage})
+
+		continued := reply.StopReason == message.StopMaxTokens
+		reply.StopReason = response.StopReason
+		if response.Content != "" {
+			if continued {
+				reply.Content += response.Content
+			} else {
+				reply.Content = response.Content
+			}
+			a.emit(Event{Type: EventAssistantText, Content: response.Content})
+			a.appendMessage(message.Message{Role: "assistant", Content: response.Content, Meta: message.ResponseMeta(response)})
+		}
+		if len(response.ToolCalls) == 0 {
+			if ShouldContinue(response, continuations) {
+				continuations++
+				a.appendMessage(message.Message{Role: "user", Content: ContinueContent, Meta: message.Now()})
+				continue
+			}
+			return reply, nil
+		}
+		for _, call := range response.ToolCalls {
+			if err := a.runToolCall(ctx, call); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w (%d)", ErrMaxSteps, a.maxSteps)
+}
+
+// conversation 返回发送给模型的消息：系统消息加上对话历史
+func (a *Agent) conversation() []message.Message {
+	if a.system == "" {
+		return a.Messages()
+	}
+	return append([]message.Message{{Role: "system", Content: a.system}}, a.Messages()...)
+}
+
+// appendMessage 把一条消息加入对话历史
+func (a *Agent) appendMessage(msg message.Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages = append(a.messages, msg)
+}
+
+// runToolCall 执行一次工具调用并把结果加入对话。ctx 被取消或工具暂时不可用时返回错误，
+// 其他错误会作为结果告诉模型，让它自行纠正，见 FailureAction
+func (a *Agent) runToolCall(ctx context.Context, call message.ToolCall) error {
+	var tool *tools.ToolDefinition
+	defs := a.Tools()
+	for i := range defs {
+		if defs[i].Name == call.Name {
+			tool = &defs[i]
+			break
+		}
+	}
+	if tool == nil {
+		a.appendMessage(message.Message{Role: "user", Content: ToolNotFoundContent(call.Name), ToolCall: &call, IsError: true, Meta: message.Now()})
+		return nil
+	}
+
+	a.emit(Event{Type: EventToolCall, ToolCall: &call})
+	var result tools.ToolResult
+	if a.approve != nil && !tool.ReadOnly {
+		if err := a.approve(ctx, call); err != nil {
+			result = tools.ErrorResult(err)
+		}
+		ctx = tools.Approved(ctx)
+	}
+	meta := message.Now()
+	if !result.IsError {
+		result = RunToolRetrying(ctx, *tool, call.Input, nil)
+	}
+	meta.DurationMS = time.Since(meta.Time).Milliseconds()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	// 工具输出中的密钥不会发给模型
+	result = RedactResult(result)
+	if FailureAction(result) == ActionAbort {
+		a.emit(Event{Type: EventToolError, ToolCall: &call, Content: result.Text, Result: &result})
+		return fmt.Errorf("%w: %s: %s", ErrToolUnavailable, call.Name, result.Text)
+	}
+	content := ToolResultContent(call.Name, result)
+	if result.IsError {
+		a.emit(Event{Type: EventToolError, ToolCall: &call, Content: result.Text, Result: &result})
+	} else {
+		a.emit(Event{Type: EventToolResult, ToolCall: &call, Content: result.Text, Result: &result})
+	}
+	a.appendMessage(message.Message{Role: "user", Content: content, ToolCall: &call, IsError: result.IsError, Meta: meta})
+	return nil
+}
+
+func (a *Agent) emit(e Event) {
+	if a.onEvent != nil {
+		a.onEvent(e)
+	}
+}
+
+// ShouldContinue 判断是否请模型接着被截断的回复继续生成：回复只有文字、因输出长度
+// 上限被截断，并且这一轮已经继续过的次数 continuations 还没有达到 MaxContinuations。
+// 截断的工具调用不继续，它们的输入不完整，工具会报告错误，模型可以据此重试
+func ShouldContinue(response *message.Response, continuations int) bool {
+	return response.StopReason == message.StopMaxTokens && len(response.ToolCalls) == 0 &&
+		response.Content != "" && continuations < MaxContinuations
+}
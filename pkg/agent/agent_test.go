@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"agent/pkg/message"
+	"agent/pkg/provider"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scripted 依次返回 responses，并记录每次收到的对话
+func scripted(responses ...*message.Response) (provider.Provider, *[][]message.Message) {
+	var seen [][]message.Message
+	return provider.Func(func(ctx context.Context, conversation []message.Message, _ []tools.ToolDefinition) (*message.Response, error) {
+		seen = append(seen, append([]message.Message{}, conversation...))
+		if len(responses) == 0 {
+			return nil, errors.New("no more responses")
+		}
+		response := responses[0]
+		responses = responses[1:]
+		return response, nil
+	}), &seen
+}
+
+func echoTool(name string, readOnly bool) tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:     name,
+		ReadOnly: readOnly,
+		Function: func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+			return tools.ToolResult{Text: "echo " + string(input)}
+		},
+	}
+}
+
+func TestSend(t *testing.T) {
+	p, seen := scripted(
+		&message.Response{ToolCalls: []message.ToolCall{{ID: "1", Name: "read", Input: json.RawMessage(`"a.go"`)}}, Usage: message.Usage{InputTokens: 10, OutputTokens: 2}},
+		&message.Response{Content: "看完了", Usage: message.Usage{InputTokens: 20, OutputTokens: 3}},
+	)
+	var events []string
+	a := New(p,
+		WithTools(echoTool("read", true)),
+		WithSystemPrompt("be brief"),
+		WithEventHandler(func(e Event) { events = append(events, e.Type) }),
+	)
+
+	reply, err := a.Send(context.Background(), "读一下 a.go")
+	require.NoError(t, err)
+	assert.Equal(t, "看完了", reply.Content)
+	assert.Equal(t, 2, reply.Steps)
+	assert.Equal(t, message.Usage{InputTokens: 30, OutputTokens: 5}, reply.Usage)
+	assert.Equal(t, []string{EventUsage, EventToolCall, EventToolResult, EventUsage, EventAssistantText}, events)
+
+	require.Len(t, *seen, 2)
+	assert.Equal(t, message.Message{Role: "system", Content: "be brief"}, (*seen)[0][0], "系统消息放在对话之前")
+	messages := a.Messages()
+	require.Len(t, messages, 3)
+	assert.Equal(t, `Tool read executed with result: echo "a.go"`, messages[1].Content)
+	assert.Equal(t, "1", messages[1].ToolCall.ID)
+	assert.Equal(t, &message.Usage{InputTokens: 20, OutputTokens: 3}, messages[2].Meta.Usage, "助手消息记录产生它的推理的用量")
+	assert.False(t, messages[0].Meta.Time.IsZero())
+
+	t.Run("出错时丢弃这一轮", func(t *testing.T) {
+		_, err := a.Send(context.Background(), "再来一次")
+		assert.ErrorContains(t, err, "no more responses")
+		assert.Len(t, a.Messages(), 3)
+	})
+}
+
+func TestSendTruncated(t *testing.T) {
+	truncated := func(content string) *message.Response {
+		return &message.Response{Content: content, StopReason: message.StopMaxTokens}
+	}
+
+	t.Run("被截断的回复自动继续", func(t *testing.T) {
+		p, seen := scripted(truncated("前半"), &message.Response{Content: "后半", StopReason: message.StopEndTurn})
+		a := New(p)
+		reply, err := a.Send(context.Background(), "写点什么")
+		require.NoError(t, err)
+		assert.Equal(t, "前半后半", reply.Content)
+		assert.Equal(t, message.StopEndTurn, reply.StopReason)
+		require.Len(t, *seen, 2)
+		last := (*seen)[1][len((*seen)[1])-1]
+		assert.Equal(t, ContinueContent, last.Content)
+	})
+
+	t.Run("继续的次数有上限", func(t *testing.T) {
+		var responses []*message.Response
+		for i := 0; i <= MaxContinuations; i++ {
+			responses = append(responses, truncated("x"))
+		}
+		p, seen := scripted(responses...)
+		reply, err := New(p).Send(context.Background(), "写点什么")
+		require.NoError(t, err)
+		assert.Len(t, *seen, MaxContinuations+1)
+		assert.Equal(t, message.StopMaxTokens, reply.StopReason, "调用方可以知道回复仍不完整")
+	})
+
+	t.Run("截断的工具调用不继续", func(t *testing.T) {
+		call := &message.Response{StopReason: message.StopMaxTokens, ToolCalls: []message.ToolCall{{ID: "1", Name: "read", Input: json.RawMessage(`{"pa`)}}}
+		assert.False(t, ShouldContinue(call, 0))
+	})
+}
+
+func TestSendToolCalls(t *testing.T) {
+	t.Run("拒绝的调用把原因告诉模型", func(t *testing.T) {
+		p, _ := scripted(
+			&message.Response{ToolCalls: []message.ToolCall{
+				{ID: "1", Name: "edit", Input: json.RawMessage(`{}`)},
+				{ID: "2", Name: "read", Input: json.RawMessage(`{}`)},
+				{ID: "3", Name: "missing", Input: json.RawMessage(`{}`)},
+			}},
+			&message.Response{Content: "好"},
+		)
+		var approved []string
+		a := New(p,
+			WithTools(echoTool("edit", false), echoTool("read", true)),
+			WithApproval(func(_ context.Context, call message.ToolCall) error {
+				approved = append(approved, call.Name)
+				return errors.New("denied by user")
+			}),
+		)
+		_, err := a.Send(context.Background(), "改一下")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"edit"}, approved, "只读工具不需要确认")
+		messages := a.Messages()
+		assert.Equal(t, "Tool edit failed: denied by user", messages[1].Content)
+		assert.Equal(t, "Tool read executed with result: echo {}", messages[2].Content)
+		assert.Equal(t, "Tool missing not found", messages[3].Content)
+	})
+
+	t.Run("达到推理次数上限", func(t *testing.T) {
+		call := &message.Response{ToolCalls: []message.ToolCall{{ID: "1", Name: "read", Input: json.RawMessage(`{}`)}}}
+		p, _ := scripted(call, call, call)
+		a := New(p, WithTools(echoTool("read", true)), WithMaxSteps(2))
+		_, err := a.Send(context.Background(), "一直读")
+		assert.ErrorIs(t, err, ErrMaxSteps)
+		assert.Empty(t, a.Messages())
+	})
+
+	t.Run("从已有的对话继续", func(t *testing.T) {
+		p, seen := scripted(&message.Response{Content: "继续"})
+		a := New(p, WithMessages([]message.Message{{Role: "user", Content: "之前"}, {Role: "assistant", Content: "好"}}))
+		_, err := a.Send(context.Background(), "然后呢")
+		require.NoError(t, err)
+		assert.Len(t, (*seen)[0], 3)
+		a.Reset()
+		assert.Empty(t, a.Messages())
+	})
+}
+
+func TestConcurrentUse(t *testing.T) {
+	// 回复最后一条用户消息，中途让出调度，使并发的调用交错执行
+	echo := provider.Func(func(ctx context.Context, conversation []message.Message, _ []tools.ToolDefinition) (*message.Response, error) {
+		last := conversation[len(conversation)-1]
+		runtime.Gosched()
+		return &message.Response{Content: "re: " + last.Content, Usage: message.Usage{InputTokens: 1, OutputTokens: 1}}, nil
+	})
+	const sessions, turns = 4, 10
+
+	var wg sync.WaitGroup
+	agents := make([]*Agent, sessions)
+	for s := range agents {
+		agents[s] = New(echo, WithTools(echoTool("read", true)))
+		for i := 0; i < turns; i++ {
+			wg.Add(2)
+			go func(a *Agent, i int) {
+				defer wg.Done()
+				_, err := a.Send(context.Background(), fmt.Sprint(i))
+				assert.NoError(t, err)
+			}(agents[s], i)
+			go func(a *Agent) {
+				defer wg.Done()
+				a.Messages()
+				a.Usage()
+				a.SetTools(a.Tools()...)
+			}(agents[s])
+		}
+	}
+	wg.Wait()
+
+	for _, a := range agents {
+		messages := a.Messages()
+		require.Len(t, messages, 2*turns)
+		for i := 0; i < len(messages); i += 2 {
+			assert.Equal(t, "re: "+messages[i].Content, messages[i+1].Content, "一轮对话结束后下一轮才开始")
+		}
+		assert.Equal(t, message.Usage{InputTokens: turns, OutputTokens: turns}, a.Usage())
+	}
+}
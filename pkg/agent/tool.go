@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"agent/redact"
+	"agent/tools"
+)
+
+// RunTool 执行工具，ctx 同时交给工具，让它停止启动的进程；ctx 在执行期间被取消时立即返回以 ctx.Err() 失败的结果。
+// output 不为 nil 且工具支持流式输出时，输出一产生就在调用方的 goroutine 中交给 output
+func RunTool(ctx context.Context, tool tools.ToolDefinition, input json.RawMessage, output func(chunk string)) tools.ToolResult {
+	done := make(chan tools.ToolResult, 1)
+	chunks := make(chan string, 64)
+	go func() {
+		if tool.Stream != nil && output != nil {
+			done <- tool.Stream(ctx, input, chunkWriter{ctx: ctx, chunks: chunks})
+		} else {
+			done <- tool.Function(ctx, input)
+		}
+	}()
+
+	for {
+		select {
+		case chunk := <-chunks:
+			output(chunk)
+		case result := <-done:
+			// 此时所有写入都已返回，剩下的都在缓冲区里
+			for len(chunks) > 0 {
+				output(<-chunks)
+			}
+			return result
+		case <-ctx.Done():
+			return tools.ErrorResult(ctx.Err())
+		}
+	}
+}
+
+// MaxTransientRetries 是只读工具遇到暂时性错误时自动重试的次数
+const MaxTransientRetries = 2
+
+// transientBackoff 是第一次重试前的等待时间，之后每次加倍
+var transientBackoff = 500 * time.Millisecond
+
+// RunToolRetrying 与 RunTool 相同，但只读工具以暂时性错误失败时等待后自动重试。
+// 修改状态的工具不重试，因为失败前操作可能已经生效
+func RunToolRetrying(ctx context.Context, tool tools.ToolDefinition, input json.RawMessage, output func(chunk string)) tools.ToolResult {
+	result := RunTool(ctx, tool, input, output)
+	delay := transientBackoff
+	for retry := 0; retry < MaxTransientRetries && tool.ReadOnly && result.ErrorKind() == tools.ErrorTransient; retry++ {
+		select {
+		case <-ctx.Done():
+			return tools.ErrorResult(ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+		result = RunTool(ctx, tool, input, output)
+	}
+	return result
+}
+
+// Action 是工具调用失败后 agent 的处理方式
+type Action int
+
+const (
+	// ActionRetry 把错误告诉模型，让它修正输入后再试
+	ActionRetry Action = iota
+	// ActionSurface 让用户看到调用被拒绝，并告诉模型不要原样重试
+	ActionSurface
+	// ActionAbort 结束这一轮对话
+	ActionAbort
+)
+
+// FailureAction 返回工具以 result 失败后的处理方式：自动重试后仍是暂时性错误时结束这一轮，
+// 被拒绝的调用交给用户决定，其他错误交给模型修正
+func FailureAction(result tools.ToolResult) Action {
+	switch result.ErrorKind() {
+	case tools.ErrorTransient:
+		return ActionAbort
+	case tools.ErrorPermissionDenied:
+		return ActionSurface
+	}
+	return ActionRetry
+}
+
+// chunkWriter 把流式工具的输出交给 RunTool。调用被取消后没有人读取，
+// 写入的内容直接丢弃，而不是阻塞工具
+type chunkWriter struct {
+	ctx    context.Context
+	chunks chan<- string
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	select {
+	case w.chunks <- string(p):
+	case <-w.ctx.Done():
+	}
+	return len(p), nil
+}
+
+// ToolResultContent 把工具结果格式化为对话中的文本。失败的结果只有错误说明；
+// 修改了文件时附上 diff，让模型确认改动的内容
+func ToolResultContent(name string, result tools.ToolResult) string {
+	if result.IsError {
+		return ToolErrorContent(name, result.Err())
+	}
+	content := fmt.Sprintf("Tool %s executed with result: %s", name, result.Text)
+	if result.Diff != "" {
+		content += "\n\nDiff:\n" + result.Diff
+	}
+	return content
+}
+
+// RedactResult 去掉结果的文本和 diff 中的密钥，失败的结果保留错误的分类
+func RedactResult(result tools.ToolResult) tools.ToolResult {
+	if result.IsError {
+		err := errors.New(redact.String(result.Err().Error()))
+		if kind := result.ErrorKind(); kind != "" {
+			err = tools.WithKind(kind, err)
+		}
+		redacted := tools.ErrorResult(err)
+		redacted.Files, redacted.Metadata = result.Files, result.Metadata
+		return redacted
+	}
+	result.Text = redact.String(result.Text)
+	result.Diff = redact.String(result.Diff)
+	return result
+}
+
+// ToolErrorContent 把失败的工具调用格式化为对话中的文本，按错误的分类提示模型下一步怎么做
+func ToolErrorContent(name string, err error) string {
+	switch tools.KindOf(err) {
+	case tools.ErrorInvalidInput:
+		return fmt.Sprintf("Tool %s failed: %s. Correct the input and call it again.", name, err)
+	case tools.ErrorNotFound:
+		return fmt.Sprintf("Tool %s failed: %s. Check the name or path, e.g. by searching for it, and try again.", name, err)
+	case tools.ErrorPermissionDenied:
+		return fmt.Sprintf("Tool %s was denied: %s. Do not retry the same call; ask the user if it is needed.", name, err)
+	}
+	return fmt.Sprintf("Tool %s failed: %s", name, err)
+}
+
+// ToolNotFoundContent 是模型调用了不存在的工具时的回复
+func ToolNotFoundContent(name string) string {
+	return fmt.Sprintf("Tool %s not found", name)
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunToolStreams(t *testing.T) {
+	chunks := []string{"ok  \tagent/a\n", "FAIL\tagent/b\n"}
+	run := func(output io.Writer) (string, error) {
+		result := ""
+		for _, chunk := range chunks {
+			io.WriteString(output, chunk)
+			result += chunk
+		}
+		return result, nil
+	}
+	tool := tools.ToolDefinition{
+		Name:     "run_tests",
+		Function: func(context.Context, json.RawMessage) tools.ToolResult { return tools.Result(run(io.Discard)) },
+		Stream: func(_ context.Context, _ json.RawMessage, output io.Writer) tools.ToolResult {
+			return tools.Result(run(output))
+		},
+	}
+
+	var streamed []string
+	result, err := RunTool(context.Background(), tool, nil, func(chunk string) { streamed = append(streamed, chunk) }).Output()
+	require.NoError(t, err)
+	assert.Equal(t, chunks, streamed)
+	assert.Equal(t, "ok  \tagent/a\nFAIL\tagent/b\n", result)
+
+	t.Run("没有 output 时不流式执行", func(t *testing.T) {
+		result, err := RunTool(context.Background(), tool, nil, nil).Output()
+		require.NoError(t, err)
+		assert.Equal(t, "ok  \tagent/a\nFAIL\tagent/b\n", result)
+	})
+
+	t.Run("取消后丢弃输出而不阻塞工具", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		finished := make(chan struct{})
+		endless := tools.ToolDefinition{Stream: func(_ context.Context, _ json.RawMessage, output io.Writer) tools.ToolResult {
+			defer close(finished)
+			for i := 0; i < 1000; i++ {
+				fmt.Fprintf(output, "line %d\n", i)
+			}
+			return tools.ToolResult{}
+		}}
+		_, err := RunTool(ctx, endless, nil, func(string) { cancel() }).Output()
+		assert.ErrorIs(t, err, context.Canceled)
+		<-finished
+	})
+}
+
+func TestToolResultContent(t *testing.T) {
+	assert.Equal(t, "Tool read_file executed with result: text", ToolResultContent("read_file", tools.Result("text", nil)))
+	assert.Equal(t, "Tool read_file failed: no such file", ToolResultContent("read_file", tools.Result("", errors.New("no such file"))))
+	assert.Equal(t, "Tool edit_file executed with result: OK\n\nDiff:\n-a\n+b\n",
+		ToolResultContent("edit_file", tools.ToolResult{Text: "OK", Diff: "-a\n+b\n", Files: []tools.FileRef{{Path: "a.txt"}}}),
+		"diff 附在结果之后，文件引用不发给模型")
+}
+
+func TestRunToolRetrying(t *testing.T) {
+	backoff := transientBackoff
+	transientBackoff = time.Millisecond
+	t.Cleanup(func() { transientBackoff = backoff })
+
+	flaky := func(failures int, readOnly bool) (tools.ToolDefinition, *int) {
+		calls := 0
+		return tools.ToolDefinition{
+			ReadOnly: readOnly,
+			Function: func(context.Context, json.RawMessage) tools.ToolResult {
+				calls++
+				if calls <= failures {
+					return tools.ErrorResult(tools.WithKind(tools.ErrorTransient, errors.New("connection reset")))
+				}
+				return tools.ToolResult{Text: "ok"}
+			},
+		}, &calls
+	}
+
+	t.Run("只读工具遇到暂时性错误时重试", func(t *testing.T) {
+		tool, calls := flaky(2, true)
+		out, err := RunToolRetrying(context.Background(), tool, nil, nil).Output()
+		require.NoError(t, err)
+		assert.Equal(t, "ok", out)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("重试次数用完后返回错误", func(t *testing.T) {
+		tool, calls := flaky(10, true)
+		result := RunToolRetrying(context.Background(), tool, nil, nil)
+		assert.Equal(t, tools.ErrorTransient, result.ErrorKind())
+		assert.Equal(t, 1+MaxTransientRetries, *calls)
+		assert.Equal(t, ActionAbort, FailureAction(result))
+	})
+
+	t.Run("修改状态的工具不重试", func(t *testing.T) {
+		tool, calls := flaky(1, false)
+		result := RunToolRetrying(context.Background(), tool, nil, nil)
+		assert.True(t, result.IsError)
+		assert.Equal(t, 1, *calls)
+	})
+}
+
+func TestToolErrorContent(t *testing.T) {
+	cases := []struct {
+		err    error
+		want   string
+		action Action
+	}{
+		{errors.New("boom"), "Tool shell failed: boom", ActionRetry},
+		{tools.WithKind(tools.ErrorInvalidInput, errors.New("old_str not found")), "Tool shell failed: old_str not found. Correct the input and call it again.", ActionRetry},
+		{fmt.Errorf("failed to read: %w", os.ErrNotExist), "Tool shell failed: failed to read: file does not exist. Check the name or path, e.g. by searching for it, and try again.", ActionRetry},
+		{fmt.Errorf("command %w", tools.ErrDenied), "Tool shell was denied: command denied. Do not retry the same call; ask the user if it is needed.", ActionSurface},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, ToolErrorContent("shell", c.err))
+		assert.Equal(t, c.action, FailureAction(tools.ErrorResult(c.err)), c.want)
+	}
+
+	t.Run("去掉密钥后保留错误的分类", func(t *testing.T) {
+		result := RedactResult(tools.ErrorResult(fmt.Errorf("token sk-abcdefghijklmnopqrstuvwxyz %w", tools.ErrDenied)))
+		assert.NotContains(t, result.Text, "sk-abcdefghij")
+		assert.Equal(t, tools.ErrorPermissionDenied, result.ErrorKind())
+	})
+}
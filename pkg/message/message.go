@@ -0,0 +1,89 @@
+// Package message 定义 agent 与模型提供方之间通用的对话消息类型
+package message
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Message 是对话中的一条消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCall 在消息携带工具结果时记录产生它的调用
+	ToolCall *ToolCall `json:"tool_call,omitempty"`
+	// IsError 标记执行失败的工具调用的结果，provider 据此告诉模型调用失败了
+	IsError bool `json:"is_error,omitempty"`
+	// Meta 记录消息是何时、如何产生的，随会话保存，不发送给模型
+	Meta *Metadata `json:"meta,omitempty"`
+}
+
+// Metadata 是消息的元数据，用于显示和事后分析 agent 的行为
+type Metadata struct {
+	Time time.Time `json:"time"`
+	// Model 和 Usage 是产生助手消息的推理所用的模型和 token 用量
+	Model string `json:"model,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+	// ToolCallIDs 是与助手消息同一次推理请求的工具调用，对应工具结果消息的 ToolCall.ID
+	ToolCallIDs []string `json:"tool_call_ids,omitempty"`
+	// DurationMS 是工具结果消息的工具执行时间
+	DurationMS int64 `json:"duration_ms,omitempty"`
+}
+
+// Now 返回时间为当前时间的元数据
+func Now() *Metadata {
+	return &Metadata{Time: time.Now()}
+}
+
+// ResponseMeta 返回由推理结果 r 产生的助手消息的元数据
+func ResponseMeta(r *Response) *Metadata {
+	meta := Now()
+	meta.Model = r.Model
+	usage := r.Usage
+	meta.Usage = &usage
+	for _, call := range r.ToolCalls {
+		meta.ToolCallIDs = append(meta.ToolCallIDs, call.ID)
+	}
+	return meta
+}
+
+// Response 是一次推理的结果
+type Response struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Usage     Usage      `json:"usage"`
+	// StopReason 是模型停止生成的原因，取下面的 Stop 常量，提供方报告了其他原因时原样保留
+	StopReason string `json:"stop_reason,omitempty"`
+}
+
+// 模型停止生成的原因
+const (
+	// StopEndTurn 表示模型完成了回复
+	StopEndTurn = "end_turn"
+	// StopMaxTokens 表示回复达到了输出长度上限，文字或工具调用的输入被截断了
+	StopMaxTokens = "max_tokens"
+	// StopToolUse 表示模型停下来等待工具调用的结果
+	StopToolUse = "tool_use"
+	// StopRefusal 表示模型出于安全原因拒绝回复
+	StopRefusal = "refusal"
+)
+
+// Usage 是提供方报告的一次推理的 token 用量
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	CachedTokens int64 `json:"cached_tokens,omitempty"`
+}
+
+// Total 返回输入和输出 token 之和
+func (u Usage) Total() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+// ToolCall 是模型请求的一次工具调用
+type ToolCall struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
@@ -0,0 +1,148 @@
+package message
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PartialToolCall 累积流式返回的工具调用参数。参数完整之前就能读出已经完整的
+// 顶层字符串字段，例如 path 或 command，用于提前显示调用的目标和开始审批
+type PartialToolCall struct {
+	ID   string
+	Name string
+	args strings.Builder
+}
+
+// Append 追加一段参数
+func (p *PartialToolCall) Append(delta string) {
+	p.args.WriteString(delta)
+}
+
+// Field 返回顶层字符串字段 name 的值；字段还没有完整收到、不是字符串或不存在时返回 false
+func (p *PartialToolCall) Field(name string) (string, bool) {
+	return partialField(p.args.String(), name)
+}
+
+// ToolCall 返回参数收完后的工具调用
+func (p *PartialToolCall) ToolCall() ToolCall {
+	return ToolCall{ID: p.ID, Name: p.Name, Input: json.RawMessage(p.args.String())}
+}
+
+// partialField 在可能不完整的 JSON 对象 data 中查找顶层字符串字段 name
+func partialField(data, name string) (string, bool) {
+	s := &scanner{data: data}
+	if !s.next('{') {
+		return "", false
+	}
+	for {
+		s.space()
+		if s.next('}') {
+			return "", false
+		}
+		raw, ok := s.str()
+		if !ok {
+			return "", false
+		}
+		var key string
+		if json.Unmarshal([]byte(raw), &key) != nil {
+			return "", false
+		}
+		s.space()
+		if !s.next(':') {
+			return "", false
+		}
+		s.space()
+		if key == name {
+			raw, ok := s.str()
+			var value string
+			if !ok || json.Unmarshal([]byte(raw), &value) != nil {
+				return "", false
+			}
+			return value, true
+		}
+		if !s.skip() {
+			return "", false
+		}
+		s.space()
+		if !s.next(',') {
+			return "", false
+		}
+	}
+}
+
+// scanner 逐字节读取 JSON，读到末尾时各方法返回 false，表示还需要更多数据
+type scanner struct {
+	data string
+	pos  int
+}
+
+func (s *scanner) space() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+// next 在下一个字节是 c 时跳过它
+func (s *scanner) next(c byte) bool {
+	s.space()
+	if s.pos < len(s.data) && s.data[s.pos] == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// str 读取一个完整的字符串字面量，返回包括引号的原文
+func (s *scanner) str() (string, bool) {
+	if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+		return "", false
+	}
+	for i := s.pos + 1; i < len(s.data); i++ {
+		switch s.data[i] {
+		case '\\':
+			i++
+		case '"':
+			raw := s.data[s.pos : i+1]
+			s.pos = i + 1
+			return raw, true
+		}
+	}
+	return "", false
+}
+
+// skip 跳过一个完整的值；数字和字面量要等到后面的分隔符出现才算完整
+func (s *scanner) skip() bool {
+	if s.pos >= len(s.data) {
+		return false
+	}
+	depth := 0
+	for s.pos < len(s.data) {
+		switch c := s.data[s.pos]; c {
+		case '"':
+			if _, ok := s.str(); !ok {
+				return false
+			}
+			if depth == 0 {
+				return true
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return true
+			}
+			depth--
+			if depth == 0 {
+				s.pos++
+				return true
+			}
+		case ',', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return true
+			}
+		}
+		s.pos++
+	}
+	return false
+}
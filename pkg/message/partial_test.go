@@ -0,0 +1,40 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialToolCall(t *testing.T) {
+	t.Run("字段完整后即可读出", func(t *testing.T) {
+		call := &PartialToolCall{ID: "1", Name: "edit_file"}
+		var seen []string
+		for _, delta := range []string{`{"pa`, `th": "cmd/ma`, `in.go"`, `, "old_str": "a\"b`, `", "new_str": "c"}`} {
+			call.Append(delta)
+			path, ok := call.Field("path")
+			if ok {
+				seen = append(seen, path)
+			}
+		}
+		assert.Equal(t, []string{"cmd/main.go", "cmd/main.go", "cmd/main.go"}, seen, "路径在第三段之后可读")
+		value, ok := call.Field("old_str")
+		assert.True(t, ok)
+		assert.Equal(t, `a"b`, value)
+		assert.JSONEq(t, `{"path": "cmd/main.go", "old_str": "a\"b", "new_str": "c"}`, string(call.ToolCall().Input))
+	})
+
+	t.Run("跳过其他类型的值", func(t *testing.T) {
+		data := `{"timeout": 30, "env": {"A": "}"}, "args": [1, [2]], "ok": true, "command": "go test ./..."`
+		value, ok := partialField(data, "command")
+		assert.True(t, ok)
+		assert.Equal(t, "go test ./...", value)
+	})
+
+	t.Run("不完整或不存在时没有值", func(t *testing.T) {
+		for _, data := range []string{``, `{`, `{"command": "go te`, `{"timeout": 3`, `{"command": 1}`, `{"path": "a"}`, `{"env": {"command": "x"}}`} {
+			_, ok := partialField(data, "command")
+			assert.False(t, ok, data)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package message
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// 对话结构的规则，违反时模型服务通常只返回难以理解的 400 错误：
+//   - 角色只能是 system、user 或 assistant
+//   - 消息内容不能为空
+//   - 工具结果是带 ToolCall 的 user 消息，每个调用只有一个结果
+//   - 助手消息在 Meta.ToolCallIDs 中列出的调用，在下一条普通消息之前都有结果
+//   - 除系统提示外，对话以用户消息开始，普通的用户消息和助手消息交替出现
+
+// 修复时补上的内容
+const (
+	emptyToolResult   = "(no output)"
+	missingToolResult = "(no result: the tool call was interrupted before it finished)"
+	removedHistory    = "(earlier messages of this conversation were removed)"
+)
+
+// ValidationError 列出对话中无法自动修复的问题
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid conversation: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate 检查对话结构，有问题时返回列出全部问题的 *ValidationError，包括能修复的
+func Validate(conversation []Message) error {
+	_, repairs, err := Repair(conversation)
+	var problems []string
+	if err != nil {
+		problems = err.(*ValidationError).Problems
+	}
+	if problems = append(problems, repairs...); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Repair 返回修复后的对话和所做修改的说明，conversation 本身不变。
+// 无法修复时返回 *ValidationError
+func Repair(conversation []Message) ([]Message, []string, error) {
+	var out, repairs, problems = []Message{}, []string(nil), []string(nil)
+	answered := map[string]bool{}
+	// pending 是最近的助手消息列出、还没有结果的调用
+	var pending []string
+	// closeCalls 为没有结果的调用补上结果
+	closeCalls := func() {
+		for _, id := range pending {
+			out = append(out, Message{Role: "user", Content: missingToolResult, ToolCall: &ToolCall{ID: id}, IsError: true})
+			answered[id] = true
+			repairs = append(repairs, fmt.Sprintf("added a result for tool call %s, which had none", id))
+		}
+		pending = nil
+	}
+
+	for i, msg := range conversation {
+		n := i + 1
+		switch msg.Role {
+		case "system", "user", "assistant":
+		default:
+			problems = append(problems, fmt.Sprintf("message %d has the unknown role %q", n, msg.Role))
+			continue
+		}
+		empty := strings.TrimSpace(msg.Content) == ""
+
+		if msg.ToolCall != nil && msg.ToolCall.ID == "" {
+			msg.ToolCall = nil
+			repairs = append(repairs, fmt.Sprintf("message %d is a tool result without a call ID; sent it as a user message", n))
+		}
+		if msg.ToolCall != nil {
+			id := msg.ToolCall.ID
+			if answered[id] {
+				repairs = append(repairs, fmt.Sprintf("dropped message %d, a second result for tool call %s", n, id))
+				continue
+			}
+			if msg.Role != "user" {
+				repairs = append(repairs, fmt.Sprintf("message %d is a tool result with the role %s; changed it to user", n, msg.Role))
+				msg.Role = "user"
+			}
+			if empty {
+				msg.Content = emptyToolResult
+				repairs = append(repairs, fmt.Sprintf("message %d is an empty tool result", n))
+			}
+			answered[id] = true
+			pending = slices.DeleteFunc(pending, func(p string) bool { return p == id })
+			out = append(out, msg)
+			continue
+		}
+
+		closeCalls()
+		if msg.Role == "assistant" && msg.Meta != nil {
+			for _, id := range msg.Meta.ToolCallIDs {
+				if !answered[id] {
+					pending = append(pending, id)
+				}
+			}
+		}
+		if empty {
+			repairs = append(repairs, fmt.Sprintf("dropped message %d, an empty %s message", n, msg.Role))
+			continue
+		}
+		if msg.Role == "system" {
+			out = append(out, msg)
+			continue
+		}
+
+		last := -1
+		for j := len(out) - 1; j >= 0; j-- {
+			if out[j].Role != "system" {
+				last = j
+				break
+			}
+		}
+		switch {
+		case last < 0 && msg.Role == "assistant":
+			out = append(out, Message{Role: "user", Content: removedHistory})
+			repairs = append(repairs, fmt.Sprintf("message %d starts the conversation with an assistant message; added a user message before it", n))
+		case last >= 0 && out[last].Role == msg.Role && out[last].ToolCall == nil:
+			out[last].Content += "\n\n" + msg.Content
+			repairs = append(repairs, fmt.Sprintf("merged message %d into the %s message before it", n, msg.Role))
+			continue
+		}
+		out = append(out, msg)
+	}
+	closeCalls()
+
+	if len(problems) > 0 {
+		return nil, repairs, &ValidationError{Problems: problems}
+	}
+	if len(out) == 0 {
+		return nil, repairs, &ValidationError{Problems: []string{"the conversation has no messages"}}
+	}
+	return out, repairs, nil
+}
@@ -0,0 +1,124 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func result(id, content string) Message {
+	return Message{Role: "user", Content: content, ToolCall: &ToolCall{ID: id, Name: "read_file"}}
+}
+
+func TestRepair(t *testing.T) {
+	t.Run("合法的对话不变", func(t *testing.T) {
+		conversation := []Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "read a.go"},
+			{Role: "assistant", Content: "reading", Meta: &Metadata{ToolCallIDs: []string{"1", "2"}}},
+			result("1", "package a"),
+			result("2", "package b"),
+			result("3", "调用不在助手消息中的结果"),
+			{Role: "assistant", Content: "done"},
+			{Role: "user", Content: "thanks"},
+		}
+		repaired, repairs, err := Repair(conversation)
+		require.NoError(t, err)
+		assert.Empty(t, repairs)
+		assert.Equal(t, conversation, repaired)
+		assert.NoError(t, Validate(conversation))
+	})
+
+	t.Run("补上中断的调用的结果", func(t *testing.T) {
+		conversation := []Message{
+			{Role: "user", Content: "read"},
+			{Role: "assistant", Content: "reading", Meta: &Metadata{ToolCallIDs: []string{"1", "2"}}},
+			result("1", "package a"),
+			{Role: "user", Content: "stop"},
+		}
+		repaired, repairs, err := Repair(conversation)
+		require.NoError(t, err)
+		require.Len(t, repaired, 5)
+		assert.Equal(t, "2", repaired[3].ToolCall.ID)
+		assert.Equal(t, missingToolResult, repaired[3].Content)
+		assert.Equal(t, "stop", repaired[4].Content)
+		assert.Len(t, repairs, 1)
+	})
+
+	t.Run("对话结尾的调用也补上结果", func(t *testing.T) {
+		repaired, _, err := Repair([]Message{
+			{Role: "user", Content: "read"},
+			{Role: "assistant", Content: "reading", Meta: &Metadata{ToolCallIDs: []string{"1"}}},
+		})
+		require.NoError(t, err)
+		require.Len(t, repaired, 3)
+		assert.Equal(t, "1", repaired[2].ToolCall.ID)
+	})
+
+	t.Run("去掉空消息和重复的结果", func(t *testing.T) {
+		repaired, repairs, err := Repair([]Message{
+			{Role: "system", Content: " "},
+			{Role: "user", Content: "read"},
+			result("1", ""),
+			result("1", "again"),
+			{Role: "assistant", Content: "\n"},
+		})
+		require.NoError(t, err)
+		require.Len(t, repaired, 2)
+		assert.Equal(t, emptyToolResult, repaired[1].Content)
+		assert.Len(t, repairs, 4)
+	})
+
+	t.Run("修正工具结果的角色", func(t *testing.T) {
+		repaired, _, err := Repair([]Message{
+			{Role: "user", Content: "read"},
+			{Role: "assistant", Content: "x", ToolCall: &ToolCall{ID: "1"}},
+			{Role: "user", Content: "y", ToolCall: &ToolCall{}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "user", repaired[1].Role)
+		assert.Nil(t, repaired[2].ToolCall, "没有 ID 的结果作为普通消息发送")
+	})
+
+	t.Run("合并连续的同角色消息", func(t *testing.T) {
+		repaired, repairs, err := Repair([]Message{
+			{Role: "user", Content: "one"},
+			{Role: "user", Content: "two"},
+			{Role: "assistant", Content: "a"},
+			{Role: "assistant", Content: "b"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []Message{{Role: "user", Content: "one\n\ntwo"}, {Role: "assistant", Content: "a\n\nb"}}, repaired)
+		assert.Len(t, repairs, 2)
+	})
+
+	t.Run("以助手消息开始时补上用户消息", func(t *testing.T) {
+		repaired, _, err := Repair([]Message{{Role: "system", Content: "s"}, {Role: "assistant", Content: "a"}})
+		require.NoError(t, err)
+		require.Len(t, repaired, 3)
+		assert.Equal(t, Message{Role: "user", Content: removedHistory}, repaired[1])
+	})
+
+	t.Run("不修改原来的对话", func(t *testing.T) {
+		conversation := []Message{{Role: "user", Content: "one"}, {Role: "user", Content: "two"}}
+		_, _, err := Repair(conversation)
+		require.NoError(t, err)
+		assert.Equal(t, "one", conversation[0].Content)
+	})
+
+	t.Run("无法修复的对话", func(t *testing.T) {
+		_, _, err := Repair([]Message{{Role: "tool", Content: "x"}})
+		var invalid *ValidationError
+		require.ErrorAs(t, err, &invalid)
+		assert.Contains(t, invalid.Problems[0], `unknown role "tool"`)
+
+		_, _, err = Repair([]Message{{Role: "user", Content: ""}})
+		assert.ErrorContains(t, err, "no messages")
+	})
+
+	t.Run("Validate 也列出能修复的问题", func(t *testing.T) {
+		err := Validate([]Message{{Role: "user", Content: "one"}, {Role: "user", Content: "two"}})
+		assert.ErrorContains(t, err, "merged message 2")
+	})
+}
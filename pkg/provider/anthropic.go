@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// Anthropic 通过 Anthropic Messages API 运行推理
+type Anthropic struct {
+	client    anthropic.Client
+	Model     string
+	MaxTokens int64
+	// tools 缓存转换成 API 格式的工具，在多轮之间复用
+	tools toolCache[anthropic.ToolUnionParam]
+}
+
+// NewAnthropic 创建 Anthropic 提供方，opts 可以设置 API key、base URL 等
+func NewAnthropic(opts ...option.RequestOption) *Anthropic {
+	return &Anthropic{
+		client:    anthropic.NewClient(opts...),
+		Model:     string(anthropic.ModelClaude3_7SonnetLatest),
+		MaxTokens: 1024,
+	}
+}
+
+func (ap *Anthropic) RunInference(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (*message.Response, error) {
+	system, anthropicMessages := anthropicMessages(conversation)
+
+	// 每组工具只转换一次
+	anthropicTools := ap.tools.get(tools, anthropicTool)
+
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(ap.Model),
+		MaxTokens: ap.MaxTokens,
+		System:    system,
+		Messages:  anthropicMessages,
+		Tools:     anthropicTools,
+	}
+	if choice := ToolChoiceFrom(ctx); !choice.IsAuto() && len(tools) > 0 {
+		params.ToolChoice = anthropicToolChoice(choice)
+	}
+
+	var reply *anthropic.Message
+	var err error
+	if onDelta := TextDeltaFrom(ctx); onDelta != nil {
+		reply, err = ap.stream(ctx, params, onDelta)
+	} else {
+		reply, err = ap.client.Messages.New(ctx, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换回统一格式
+	response := &message.Response{
+		Model:      string(reply.Model),
+		StopReason: anthropicStopReason(reply.StopReason),
+		Usage: message.Usage{
+			InputTokens:  reply.Usage.InputTokens,
+			OutputTokens: reply.Usage.OutputTokens,
+			CachedTokens: reply.Usage.CacheReadInputTokens,
+		},
+	}
+	for _, content := range reply.Content {
+		switch content.Type {
+		case "text":
+			response.Content = content.Text
+		case "tool_use":
+			response.ToolCalls = append(response.ToolCalls, message.ToolCall{
+				ID:    content.ID,
+				Name:  content.Name,
+				Input: content.Input,
+			})
+		}
+	}
+
+	return response, nil
+}
+
+// stream 以流式方式请求，把文本片段交给 onDelta，返回累积的完整回复
+func (ap *Anthropic) stream(ctx context.Context, params anthropic.MessageNewParams, onDelta func(string)) (*anthropic.Message, error) {
+	stream := ap.client.Messages.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	reply := &anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		if err := reply.Accumulate(event); err != nil {
+			return nil, err
+		}
+		if event.Type == "content_block_delta" && event.Delta.Type == "text_delta" {
+			onDelta(event.Delta.Text)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// anthropicMessages 把对话转换为 Anthropic 的格式，系统消息放在 system 参数中。
+// 工具调用是助手消息中的 tool_use 块，结果是随后用户消息中的 tool_result 块，
+// 失败的结果带 is_error。同一角色的连续消息合并为一条，API 要求角色交替出现。
+func anthropicMessages(conversation []message.Message) ([]anthropic.TextBlockParam, []anthropic.MessageParam) {
+	system := []anthropic.TextBlockParam{}
+	messages := []anthropic.MessageParam{}
+	add := func(role anthropic.MessageParamRole, blocks ...anthropic.ContentBlockParamUnion) {
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, blocks...)
+			return
+		}
+		messages = append(messages, anthropic.MessageParam{Role: role, Content: blocks})
+	}
+	for _, e := range exchanges(conversation) {
+		switch e.Role {
+		case "system":
+			system = append(system, anthropic.TextBlockParam{Text: e.Content})
+		case "user":
+			add(anthropic.MessageParamRoleUser, anthropic.NewTextBlock(e.Content))
+		default:
+			var uses, results []anthropic.ContentBlockParamUnion
+			if e.Content != "" || len(e.results) == 0 {
+				uses = append(uses, anthropic.NewTextBlock(e.Content))
+			}
+			for _, result := range e.results {
+				input := result.ToolCall.Input
+				if !json.Valid(input) {
+					input = json.RawMessage(`{}`)
+				}
+				uses = append(uses, anthropic.NewToolUseBlock(result.ToolCall.ID, input, result.ToolCall.Name))
+				results = append(results, anthropic.NewToolResultBlock(result.ToolCall.ID, result.Content, result.IsError))
+			}
+			add(anthropic.MessageParamRoleAssistant, uses...)
+			if len(results) > 0 {
+				add(anthropic.MessageParamRoleUser, results...)
+			}
+		}
+	}
+	return system, messages
+}
+
+// anthropicStopReason 把 Anthropic 的停止原因转换为统一的取值
+func anthropicStopReason(reason anthropic.StopReason) string {
+	switch reason {
+	case anthropic.StopReasonEndTurn, anthropic.StopReasonStopSequence:
+		return message.StopEndTurn
+	case anthropic.StopReasonMaxTokens:
+		return message.StopMaxTokens
+	case anthropic.StopReasonToolUse:
+		return message.StopToolUse
+	case anthropic.StopReasonRefusal:
+		return message.StopRefusal
+	}
+	return string(reason)
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// cacheKey 是参与缓存键的请求内容，消息的元数据不参与
+type cacheKey struct {
+	Model    string          `json:"model"`
+	Messages []cachedMessage `json:"messages"`
+	Tools    []cachedTool    `json:"tools"`
+	// ToolChoice 只在不是 auto 时参与，原有的缓存键不变
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+type cachedMessage struct {
+	Role     string            `json:"role"`
+	Content  string            `json:"content"`
+	ToolCall *message.ToolCall `json:"tool_call,omitempty"`
+	IsError  bool              `json:"is_error,omitempty"`
+}
+
+type cachedTool struct {
+	Name        string                         `json:"name"`
+	Description string                         `json:"description"`
+	InputSchema anthropic.ToolInputSchemaParam `json:"input_schema"`
+}
+
+// Cache 返回把推理响应缓存在目录 dir 中的中间件，重放或重复相同的请求（例如测试和
+// 批量重构）时直接返回缓存的响应，不再调用 next。键是 model、对话、工具和工具选择的哈希，
+// model 应当包含所有影响回复的设置，例如提供方、模型名和输出长度上限。
+// 出错的推理不缓存；命中缓存的响应用量为零，因为没有消耗 token
+func Cache(dir, model string) Middleware {
+	return func(next Provider) Provider {
+		return Func(func(ctx context.Context, conversation []message.Message, defs []tools.ToolDefinition) (*message.Response, error) {
+			path, err := cachePath(dir, model, ToolChoiceFrom(ctx), conversation, defs)
+			if err != nil {
+				return next.RunInference(ctx, conversation, defs)
+			}
+			if data, err := os.ReadFile(path); err == nil {
+				var cached message.Response
+				// 损坏的缓存文件当作没有缓存，稍后会被覆盖
+				if json.Unmarshal(data, &cached) == nil {
+					cached.Usage = message.Usage{}
+					return &cached, nil
+				}
+			}
+
+			response, err := next.RunInference(ctx, conversation, defs)
+			if err != nil {
+				return nil, err
+			}
+			// 写缓存失败不影响这次推理
+			_ = writeCacheFile(path, response)
+			return response, nil
+		})
+	}
+}
+
+// cachePath 返回请求的缓存文件
+func cachePath(dir, model string, choice ToolChoice, conversation []message.Message, defs []tools.ToolDefinition) (string, error) {
+	key := cacheKey{Model: model, Messages: []cachedMessage{}, Tools: []cachedTool{}}
+	if !choice.IsAuto() {
+		key.ToolChoice = &choice
+	}
+	for _, msg := range conversation {
+		key.Messages = append(key.Messages, cachedMessage{Role: msg.Role, Content: msg.Content, ToolCall: msg.ToolCall, IsError: msg.IsError})
+	}
+	for _, def := range defs {
+		key.Tools = append(key.Tools, cachedTool{Name: def.Name, Description: def.Description, InputSchema: def.InputSchema})
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// writeCacheFile 先写临时文件再改名，并发的进程不会读到写了一半的文件
+func writeCacheFile(path string, response *message.Response) error {
+	data, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	var fail error
+	base := Func(func(context.Context, []message.Message, []tools.ToolDefinition) (*message.Response, error) {
+		calls++
+		if fail != nil {
+			return nil, fail
+		}
+		return &message.Response{Content: "hi", Model: "m", Usage: message.Usage{InputTokens: 10, OutputTokens: 2}}, nil
+	})
+	provider := Chain(base, Cache(dir, "model-a"))
+	conversation := []message.Message{{Role: "user", Content: "hello", Meta: message.Now()}}
+	defs := []tools.ToolDefinition{{Name: "read_file", Description: "read"}}
+
+	first, err := provider.RunInference(context.Background(), conversation, defs)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), first.Usage.InputTokens)
+
+	t.Run("相同的请求命中缓存", func(t *testing.T) {
+		again := []message.Message{{Role: "user", Content: "hello", Meta: message.Now()}}
+		cached, err := provider.RunInference(context.Background(), again, defs)
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls, "元数据不同也命中")
+		assert.Equal(t, "hi", cached.Content)
+		assert.Zero(t, cached.Usage, "命中时没有消耗 token")
+	})
+
+	t.Run("模型、对话或工具不同时不命中", func(t *testing.T) {
+		_, err := Chain(base, Cache(dir, "model-b")).RunInference(context.Background(), conversation, defs)
+		require.NoError(t, err)
+		_, err = provider.RunInference(context.Background(), []message.Message{{Role: "user", Content: "hello!"}}, defs)
+		require.NoError(t, err)
+		_, err = provider.RunInference(context.Background(), conversation, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("出错的推理不缓存", func(t *testing.T) {
+		fail = errors.New("overloaded")
+		defer func() { fail = nil }()
+		other := []message.Message{{Role: "user", Content: "error"}}
+		_, err := provider.RunInference(context.Background(), other, defs)
+		assert.ErrorContains(t, err, "overloaded")
+		fail = nil
+		_, err = provider.RunInference(context.Background(), other, defs)
+		require.NoError(t, err)
+		assert.Equal(t, 6, calls)
+	})
+
+	t.Run("损坏的缓存文件当作没有缓存", func(t *testing.T) {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		require.NoError(t, err)
+		require.Len(t, files, 5)
+		for _, file := range files {
+			require.NoError(t, os.WriteFile(file, []byte("{"), 0644))
+		}
+		_, err = provider.RunInference(context.Background(), conversation, defs)
+		require.NoError(t, err)
+		assert.Equal(t, 7, calls)
+	})
+}
@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// EmbeddingProvider 计算文本的向量，embed_text 工具等语义功能通过它使用不同厂商的模型，
+// 它满足 tools.Embedder
+type EmbeddingProvider interface {
+	// Embed 返回每段文本的向量，顺序与 texts 相同
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// OpenAIEmbeddings 通过 OpenAI Embeddings API 计算向量，也适用于兼容的服务
+type OpenAIEmbeddings struct {
+	client openai.Client
+	Model  string
+}
+
+// NewOpenAIEmbeddings 创建 OpenAI 向量提供方，opts 可以设置 base URL 等
+func NewOpenAIEmbeddings(apiKey string, opts ...option.RequestOption) *OpenAIEmbeddings {
+	return &OpenAIEmbeddings{
+		client: openai.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, opts...)...),
+		Model:  openai.EmbeddingModelTextEmbedding3Small,
+	}
+}
+
+func (e *OpenAIEmbeddings) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	response, err := e.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Model: e.Model,
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+	})
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for _, data := range response.Data {
+		if data.Index < 0 || int(data.Index) >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		vector := make([]float32, len(data.Embedding))
+		for i, v := range data.Embedding {
+			vector[i] = float32(v)
+		}
+		vectors[data.Index] = vector
+	}
+	return checkVectors(vectors)
+}
+
+// Gemini 通过 Google Gemini API 计算向量
+type Gemini struct {
+	APIKey string
+	Model  string
+	// BaseURL 为空时使用 Google 的 API 地址
+	BaseURL string
+	// HTTP 为空时使用默认客户端
+	HTTP *http.Client
+}
+
+// NewGemini 创建 Gemini 向量提供方
+func NewGemini(apiKey string) *Gemini {
+	return &Gemini{APIKey: apiKey, Model: "text-embedding-004", BaseURL: "https://generativelanguage.googleapis.com"}
+}
+
+func (g *Gemini) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	type part struct {
+		Text string `json:"text"`
+	}
+	type request struct {
+		Model   string `json:"model"`
+		Content struct {
+			Parts []part `json:"parts"`
+		} `json:"content"`
+	}
+	body := struct {
+		Requests []request `json:"requests"`
+	}{}
+	for _, text := range texts {
+		r := request{Model: "models/" + g.Model}
+		r.Content.Parts = []part{{Text: text}}
+		body.Requests = append(body.Requests, r)
+	}
+	var response struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:batchEmbedContents", strings.TrimSuffix(g.BaseURL, "/"), url.PathEscape(g.Model))
+	if err := postJSON(ctx, g.HTTP, endpoint, http.Header{"X-Goog-Api-Key": {g.APIKey}}, body, &response); err != nil {
+		return nil, fmt.Errorf("gemini: %w", err)
+	}
+	vectors := make([][]float32, 0, len(response.Embeddings))
+	for _, embedding := range response.Embeddings {
+		vectors = append(vectors, embedding.Values)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("gemini returned %d embeddings for %d texts", len(vectors), len(texts))
+	}
+	return checkVectors(vectors)
+}
+
+// Ollama 通过本地 Ollama 服务计算向量，不需要 API key
+type Ollama struct {
+	Model string
+	// BaseURL 为空时使用 http://localhost:11434
+	BaseURL string
+	// HTTP 为空时使用默认客户端
+	HTTP *http.Client
+}
+
+// NewOllama 创建 Ollama 向量提供方
+func NewOllama() *Ollama {
+	return &Ollama{Model: "nomic-embed-text", BaseURL: "http://localhost:11434"}
+}
+
+func (o *Ollama) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body := struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}{Model: o.Model, Input: texts}
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := postJSON(ctx, o.HTTP, strings.TrimSuffix(o.BaseURL, "/")+"/api/embed", nil, body, &response); err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d texts", len(response.Embeddings), len(texts))
+	}
+	return checkVectors(response.Embeddings)
+}
+
+// checkVectors 确认每段文本都得到了向量
+func checkVectors(vectors [][]float32) ([][]float32, error) {
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("no embedding for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// postJSON 发送带有 header 的 JSON 请求并解码 JSON 响应，非 2xx 状态码作为错误返回
+func postJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, body, out any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddings(t *testing.T) {
+	texts := []string{"parse the config", "serve HTTP"}
+
+	t.Run("OpenAI 按 index 排列向量", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/embeddings", r.URL.Path)
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "text-embedding-3-small", body["model"])
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"object": "list", "data": [{"object": "embedding", "index": 1, "embedding": [0, 1]}, {"object": "embedding", "index": 0, "embedding": [1, 0]}]}`))
+		}))
+		defer server.Close()
+		vectors, err := NewOpenAIEmbeddings("test-key", option.WithBaseURL(server.URL), option.WithMaxRetries(0)).Embed(context.Background(), texts)
+		require.NoError(t, err)
+		assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+	})
+
+	t.Run("Gemini 批量计算", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1beta/models/text-embedding-004:batchEmbedContents", r.URL.Path)
+			assert.Equal(t, "test-key", r.Header.Get("X-Goog-Api-Key"))
+			var body struct {
+				Requests []struct {
+					Model string `json:"model"`
+				} `json:"requests"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Len(t, body.Requests, 2)
+			assert.Equal(t, "models/text-embedding-004", body.Requests[0].Model)
+			w.Write([]byte(`{"embeddings": [{"values": [1, 0]}, {"values": [0, 1]}]}`))
+		}))
+		defer server.Close()
+		gemini := NewGemini("test-key")
+		gemini.BaseURL = server.URL
+		vectors, err := gemini.Embed(context.Background(), texts)
+		require.NoError(t, err)
+		assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+	})
+
+	t.Run("Ollama", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/embed", r.URL.Path)
+			w.Write([]byte(`{"embeddings": [[1, 0], [0, 1]]}`))
+		}))
+		defer server.Close()
+		ollama := NewOllama()
+		ollama.BaseURL = server.URL
+		vectors, err := ollama.Embed(context.Background(), texts)
+		require.NoError(t, err)
+		assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+	})
+
+	t.Run("错误", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/embed" {
+				w.Write([]byte(`{"embeddings": [[1, 0]]}`))
+				return
+			}
+			http.Error(w, "model not found", http.StatusNotFound)
+		}))
+		defer server.Close()
+		gemini := NewGemini("test-key")
+		gemini.BaseURL = server.URL
+		_, err := gemini.Embed(context.Background(), texts)
+		assert.ErrorContains(t, err, "404 Not Found: model not found")
+
+		ollama := NewOllama()
+		ollama.BaseURL = server.URL
+		_, err = ollama.Embed(context.Background(), texts)
+		assert.ErrorContains(t, err, "1 embeddings for 2 texts", "向量数量不对")
+	})
+}
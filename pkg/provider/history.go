@@ -0,0 +1,53 @@
+package provider
+
+import "agent/pkg/message"
+
+// exchange 是对话中的一段：一条普通消息，或者一次推理的助手消息和它调用的工具的结果
+type exchange struct {
+	message.Message
+	// results 是助手消息调用的工具的结果，按对话中的顺序
+	results []message.Message
+}
+
+// exchanges 把对话分段，让 provider 把工具调用放回产生它们的助手消息中。
+// 助手消息的 Meta.ToolCallIDs 列出的调用归这条消息；推理只返回了工具调用、没有文字时
+// 对话中没有助手消息，为这些结果补上内容为空的助手消息。不知道工具名的结果（例如修复
+// 对话时补上的）无法对应到调用，作为普通的用户消息。
+func exchanges(conversation []message.Message) []exchange {
+	var out []exchange
+	for i := 0; i < len(conversation); i++ {
+		msg := conversation[i]
+		switch {
+		case isToolResult(msg):
+			j := i
+			for j < len(conversation) && isToolResult(conversation[j]) {
+				j++
+			}
+			out = append(out, exchange{Message: message.Message{Role: "assistant"}, results: conversation[i:j]})
+			i = j - 1
+		case msg.Role == "assistant":
+			declared := map[string]bool{}
+			if msg.Meta != nil {
+				for _, id := range msg.Meta.ToolCallIDs {
+					declared[id] = true
+				}
+			}
+			j := i + 1
+			for j < len(conversation) && isToolResult(conversation[j]) && declared[conversation[j].ToolCall.ID] {
+				j++
+			}
+			out = append(out, exchange{Message: msg, results: conversation[i+1 : j]})
+			i = j - 1
+		default:
+			if msg.ToolCall != nil {
+				msg.Role, msg.ToolCall = "user", nil
+			}
+			out = append(out, exchange{Message: msg})
+		}
+	}
+	return out
+}
+
+func isToolResult(msg message.Message) bool {
+	return msg.ToolCall != nil && msg.ToolCall.Name != ""
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/param"
+)
+
+// OpenAI 通过 OpenAI Chat Completions API 运行推理，也适用于兼容的本地服务
+type OpenAI struct {
+	client openai.Client
+	Model  string
+	// MaxTokens 限制回复长度，0 表示使用 API 的默认值
+	MaxTokens int64
+	// tools 缓存转换成 API 格式的工具，在多轮之间复用
+	tools toolCache[openai.ChatCompletionToolParam]
+}
+
+// NewOpenAI 创建 OpenAI 提供方，opts 可以设置 base URL 等
+func NewOpenAI(apiKey string, opts ...option.RequestOption) *OpenAI {
+	return &OpenAI{
+		client: openai.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, opts...)...),
+		Model:  openai.ChatModelGPT4o,
+	}
+}
+
+func (op *OpenAI) RunInference(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (*message.Response, error) {
+	openaiMessages := openAIMessages(conversation)
+
+	// 每组工具只转换一次
+	openaiTools := op.tools.get(tools, openAITool)
+
+	params := openai.ChatCompletionNewParams{
+		Model:    op.Model,
+		Messages: openaiMessages,
+	}
+	if op.MaxTokens > 0 {
+		params.MaxCompletionTokens = param.NewOpt(op.MaxTokens)
+	}
+	if len(openaiTools) > 0 {
+		params.Tools = openaiTools
+		if choice := ToolChoiceFrom(ctx); !choice.IsAuto() {
+			params.ToolChoice = openAIToolChoice(choice)
+		}
+	}
+
+	var completion *openai.ChatCompletion
+	var err error
+	if onDelta := TextDeltaFrom(ctx); onDelta != nil {
+		completion, err = op.stream(ctx, params, onDelta)
+	} else {
+		completion, err = op.client.Chat.Completions.New(ctx, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 转换回统一格式
+	response := &message.Response{
+		Model: completion.Model,
+		Usage: message.Usage{
+			InputTokens:  completion.Usage.PromptTokens,
+			OutputTokens: completion.Usage.CompletionTokens,
+			CachedTokens: completion.Usage.PromptTokensDetails.CachedTokens,
+		},
+	}
+	if len(completion.Choices) > 0 {
+		choice := completion.Choices[0]
+		response.Content = choice.Message.Content
+		response.StopReason = openAIStopReason(choice.FinishReason)
+		if choice.Message.Refusal != "" {
+			// 拒绝的说明不在 Content 中，作为回复显示给用户
+			response.StopReason = message.StopRefusal
+			if response.Content == "" {
+				response.Content = choice.Message.Refusal
+			}
+		}
+		for _, toolCall := range choice.Message.ToolCalls {
+			response.ToolCalls = append(response.ToolCalls, message.ToolCall{
+				ID:    toolCall.ID,
+				Name:  toolCall.Function.Name,
+				Input: json.RawMessage(toolCall.Function.Arguments),
+			})
+		}
+	}
+
+	return response, nil
+}
+
+// stream 以流式方式请求，把文本片段交给 onDelta，返回累积的完整回复
+func (op *OpenAI) stream(ctx context.Context, params openai.ChatCompletionNewParams, onDelta func(string)) (*openai.ChatCompletion, error) {
+	// 流式回复默认不带用量
+	params.StreamOptions.IncludeUsage = param.NewOpt(true)
+	stream := op.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var acc openai.ChatCompletionAccumulator
+	var cached int64
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		// 累积器不累加缓存命中的 token
+		cached += chunk.Usage.PromptTokensDetails.CachedTokens
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	acc.Usage.PromptTokensDetails.CachedTokens = cached
+	return &acc.ChatCompletion, nil
+}
+
+// openAIMessages 把对话转换为 OpenAI 的消息格式：工具调用放在助手消息的 tool_calls 中，
+// 结果是随后 role=tool 的消息
+func openAIMessages(conversation []message.Message) []openai.ChatCompletionMessageParamUnion {
+	var out []openai.ChatCompletionMessageParamUnion
+	for _, e := range exchanges(conversation) {
+		switch {
+		case e.Role == "system":
+			out = append(out, openai.SystemMessage(e.Content))
+		case e.Role == "user":
+			out = append(out, openai.UserMessage(e.Content))
+		case len(e.results) == 0:
+			out = append(out, openai.AssistantMessage(e.Content))
+		default:
+			assistant := openai.ChatCompletionAssistantMessageParam{}
+			if e.Content != "" {
+				assistant.Content.OfString = param.NewOpt(e.Content)
+			}
+			out = append(out, openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant})
+			for _, result := range e.results {
+				arguments := string(result.ToolCall.Input)
+				if arguments == "" {
+					arguments = "{}"
+				}
+				assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+					ID:       result.ToolCall.ID,
+					Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: result.ToolCall.Name, Arguments: arguments},
+				})
+				out = append(out, openai.ToolMessage(result.Content, result.ToolCall.ID))
+			}
+		}
+	}
+	return out
+}
+
+// openAIStopReason 把 OpenAI 的 finish_reason 转换为统一的取值
+func openAIStopReason(reason string) string {
+	switch reason {
+	case "stop":
+		return message.StopEndTurn
+	case "length":
+		return message.StopMaxTokens
+	case "tool_calls", "function_call":
+		return message.StopToolUse
+	case "content_filter":
+		return message.StopRefusal
+	}
+	return reason
+}
@@ -0,0 +1,65 @@
+// Package provider 把不同模型提供方的 API 统一为 Provider 接口，
+// 并提供在推理调用外层添加日志、预算等行为的中间件
+package provider
+
+import (
+	"context"
+
+	"agent/pkg/message"
+	"agent/tools"
+)
+
+// Provider 根据对话和可用工具运行一次推理
+type Provider interface {
+	RunInference(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (*message.Response, error)
+}
+
+// Func 把普通函数适配为 Provider
+type Func func(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (*message.Response, error)
+
+func (f Func) RunInference(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (*message.Response, error) {
+	return f(ctx, conversation, tools)
+}
+
+// Middleware 包装 Provider，添加日志、重试、缓存或预算统计等横切行为
+type Middleware func(next Provider) Provider
+
+// Chain 用 middlewares 包装 p，第一个中间件在最外层。p 能计算 token 数时包装后仍然能
+func Chain(p Provider, middlewares ...Middleware) Provider {
+	counter, _ := p.(TokenCounter)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		p = middlewares[i](p)
+	}
+	if _, ok := p.(TokenCounter); counter != nil && !ok {
+		return counting{Provider: p, counter: counter}
+	}
+	return p
+}
+
+// counting 是保留了内层 TokenCounter 的 Provider
+type counting struct {
+	Provider
+	counter TokenCounter
+}
+
+func (c counting) CountTokens(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (int64, string, error) {
+	return c.counter.CountTokens(ctx, conversation, tools)
+}
+
+// Validate 返回在推理前检查对话结构的中间件。能修复的问题修复后把修复过的副本交给
+// next，修改说明交给 report（可以为 nil）；无法修复时直接返回 *message.ValidationError，
+// 不发出请求
+func Validate(report func(repairs []string)) Middleware {
+	return func(next Provider) Provider {
+		return Func(func(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (*message.Response, error) {
+			repaired, repairs, err := message.Repair(conversation)
+			if err != nil {
+				return nil, err
+			}
+			if len(repairs) > 0 && report != nil {
+				report(repairs)
+			}
+			return next.RunInference(ctx, repaired, tools)
+		})
+	}
+}
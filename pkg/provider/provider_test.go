@@ -0,0 +1,243 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent/pkg/message"
+	"agent/pkg/provider/vcr"
+	"agent/tools"
+
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/openai/openai-go/option"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cassette 返回录制或回放 testdata/name.json 的 HTTP 客户端。默认回放录制的交互；
+// AGENT_VCR=record 时向真实服务发请求并重新录制，需要 keyEnv 中的 API key。
+func cassette(t *testing.T, name, keyEnv string) *http.Client {
+	t.Helper()
+	path := filepath.Join("testdata", name+".json")
+	mode := vcr.ModeFromEnv()
+	if mode == vcr.ModeRecord && os.Getenv(keyEnv) == "" {
+		t.Skipf("录制需要 %s 环境变量", keyEnv)
+	}
+	recorder, err := vcr.New(path, mode, nil)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skipf("没有录制 %s，用 %s=record 录制", path, vcr.EnvMode)
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if !t.Failed() {
+			assert.NoError(t, recorder.Save())
+		}
+	})
+	return &http.Client{Transport: recorder}
+}
+
+// apiKey 在回放时返回假的 key，录制时返回环境变量中的 key
+func apiKey(keyEnv string) string {
+	if vcr.ModeFromEnv() == vcr.ModeRecord {
+		return os.Getenv(keyEnv)
+	}
+	return "test-key"
+}
+
+// toolConversation 是录制用的对话：系统提示、用户消息和 read_file 工具
+func toolConversation() ([]message.Message, []tools.ToolDefinition) {
+	conversation := []message.Message{
+		{Role: "system", Content: "You are a coding assistant. Use the tools to answer."},
+		{Role: "user", Content: "Read the file go.mod in the current directory."},
+	}
+	defs := []tools.ToolDefinition{tools.ReadFileTool(&tools.Workspace{Root: "."})}
+	return conversation, defs
+}
+
+func TestAnthropic(t *testing.T) {
+	provider := NewAnthropic(
+		anthropicoption.WithAPIKey(apiKey("ANTHROPIC_API_KEY")),
+		anthropicoption.WithHTTPClient(cassette(t, "anthropic_tool_use", "ANTHROPIC_API_KEY")),
+		anthropicoption.WithMaxRetries(0),
+	)
+	conversation, defs := toolConversation()
+
+	response, err := provider.RunInference(context.Background(), conversation, defs)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(response.Model, "claude-"), "模型: %s", response.Model)
+	assert.Positive(t, response.Usage.InputTokens)
+	assert.Positive(t, response.Usage.OutputTokens)
+	assert.Equal(t, message.StopToolUse, response.StopReason)
+	require.Len(t, response.ToolCalls, 1)
+	call := response.ToolCalls[0]
+	assert.NotEmpty(t, call.ID)
+	assert.Equal(t, "read_file", call.Name)
+	assert.JSONEq(t, `{"path": "go.mod"}`, string(call.Input))
+}
+
+func TestOpenAI(t *testing.T) {
+	provider := NewOpenAI(apiKey("OPENAI_API_KEY"),
+		option.WithHTTPClient(cassette(t, "openai_tool_use", "OPENAI_API_KEY")),
+		option.WithMaxRetries(0),
+	)
+	conversation, defs := toolConversation()
+
+	response, err := provider.RunInference(context.Background(), conversation, defs)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(response.Model, "gpt-4o"), "模型: %s", response.Model)
+	assert.Positive(t, response.Usage.InputTokens)
+	assert.Positive(t, response.Usage.OutputTokens)
+	assert.Equal(t, message.StopToolUse, response.StopReason)
+	require.Len(t, response.ToolCalls, 1)
+	call := response.ToolCalls[0]
+	assert.NotEmpty(t, call.ID)
+	assert.Equal(t, "read_file", call.Name)
+	assert.JSONEq(t, `{"path": "go.mod"}`, string(call.Input))
+}
+
+func TestOpenAIMessages(t *testing.T) {
+	read := func(id, path, output string) message.Message {
+		return message.Message{Role: "user", Content: output, ToolCall: &message.ToolCall{ID: id, Name: "read_file", Input: []byte(`{"path":"` + path + `"}`)}}
+	}
+	conversation := []message.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "read both"},
+		{Role: "assistant", Content: "reading", Meta: &message.Metadata{ToolCallIDs: []string{"1", "2"}}},
+		read("1", "a.go", "package a"),
+		read("2", "b.go", "package b"),
+		// 第二次推理只返回了工具调用，对话中没有它的助手消息
+		read("3", "c.go", "package c"),
+		{Role: "user", Content: "(no result)", ToolCall: &message.ToolCall{ID: "4"}},
+		{Role: "assistant", Content: "done"},
+	}
+	data, err := json.Marshal(openAIMessages(conversation))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"role": "system", "content": "sys"},
+		{"role": "user", "content": "read both"},
+		{"role": "assistant", "content": "reading", "tool_calls": [
+			{"id": "1", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"a.go\"}"}},
+			{"id": "2", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"b.go\"}"}}
+		]},
+		{"role": "tool", "tool_call_id": "1", "content": "package a"},
+		{"role": "tool", "tool_call_id": "2", "content": "package b"},
+		{"role": "assistant", "tool_calls": [
+			{"id": "3", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"c.go\"}"}}
+		]},
+		{"role": "tool", "tool_call_id": "3", "content": "package c"},
+		{"role": "user", "content": "(no result)"},
+		{"role": "assistant", "content": "done"}
+	]`, string(data))
+}
+
+func TestAnthropicMessages(t *testing.T) {
+	failed := message.Message{Role: "user", Content: "no such file", IsError: true,
+		ToolCall: &message.ToolCall{ID: "2", Name: "read_file", Input: []byte(`{"path":"b.go"}`)}}
+	conversation := []message.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "read both"},
+		{Role: "assistant", Content: "reading", Meta: &message.Metadata{ToolCallIDs: []string{"1", "2"}}},
+		{Role: "user", Content: "package a", ToolCall: &message.ToolCall{ID: "1", Name: "read_file", Input: []byte(`{"path":"a.go"}`)}},
+		failed,
+		{Role: "user", Content: "stop"},
+		{Role: "assistant", Content: "ok"},
+	}
+	system, messages := anthropicMessages(conversation)
+	require.Len(t, system, 1)
+	data, err := json.Marshal(messages)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"role": "user", "content": [{"type": "text", "text": "read both"}]},
+		{"role": "assistant", "content": [
+			{"type": "text", "text": "reading"},
+			{"type": "tool_use", "id": "1", "name": "read_file", "input": {"path": "a.go"}},
+			{"type": "tool_use", "id": "2", "name": "read_file", "input": {"path": "b.go"}}
+		]},
+		{"role": "user", "content": [
+			{"type": "tool_result", "tool_use_id": "1", "is_error": false, "content": [{"type": "text", "text": "package a"}]},
+			{"type": "tool_result", "tool_use_id": "2", "is_error": true, "content": [{"type": "text", "text": "no such file"}]},
+			{"type": "text", "text": "stop"}
+		]},
+		{"role": "assistant", "content": [{"type": "text", "text": "ok"}]}
+	]`, string(data))
+}
+
+func TestStopReasons(t *testing.T) {
+	assert.Equal(t, message.StopMaxTokens, anthropicStopReason("max_tokens"))
+	assert.Equal(t, message.StopEndTurn, anthropicStopReason("stop_sequence"))
+	assert.Equal(t, "pause_turn", anthropicStopReason("pause_turn"), "其他原因原样保留")
+	assert.Equal(t, message.StopMaxTokens, openAIStopReason("length"))
+	assert.Equal(t, message.StopRefusal, openAIStopReason("content_filter"))
+	assert.Equal(t, message.StopEndTurn, openAIStopReason("stop"))
+}
+
+// tracingMiddleware 记录调用进入和退出的顺序
+func tracingMiddleware(name string, trace *[]string) Middleware {
+	return func(next Provider) Provider {
+		return Func(func(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (*message.Response, error) {
+			*trace = append(*trace, name+" in")
+			response, err := next.RunInference(ctx, conversation, tools)
+			*trace = append(*trace, name+" out")
+			return response, err
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Run("按顺序嵌套中间件", func(t *testing.T) {
+		trace := []string{}
+		base := Func(func(context.Context, []message.Message, []tools.ToolDefinition) (*message.Response, error) {
+			return &message.Response{Content: "ok"}, nil
+		})
+		provider := Chain(base, tracingMiddleware("outer", &trace), tracingMiddleware("inner", &trace))
+
+		response, err := provider.RunInference(context.Background(), nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", response.Content)
+		assert.Equal(t, []string{"outer in", "inner in", "inner out", "outer out"}, trace)
+	})
+
+	t.Run("没有中间件时返回原始provider", func(t *testing.T) {
+		base := NewOpenAI("test-key")
+		assert.Same(t, base, Chain(base))
+	})
+}
+
+func TestValidate(t *testing.T) {
+	var received []message.Message
+	base := Func(func(_ context.Context, conversation []message.Message, _ []tools.ToolDefinition) (*message.Response, error) {
+		received = conversation
+		return &message.Response{Content: "ok"}, nil
+	})
+
+	t.Run("修复后发送副本", func(t *testing.T) {
+		var reported []string
+		provider := Chain(base, Validate(func(repairs []string) { reported = repairs }))
+		conversation := []message.Message{
+			{Role: "user", Content: "hi"},
+			{Role: "user", Content: ""},
+			{Role: "assistant", Content: "hello"},
+		}
+		_, err := provider.RunInference(context.Background(), conversation, nil)
+		require.NoError(t, err)
+		assert.Len(t, received, 2)
+		assert.Len(t, reported, 1)
+		assert.Len(t, conversation, 3, "原来的对话不变")
+	})
+
+	t.Run("无法修复时不发出请求", func(t *testing.T) {
+		received = nil
+		provider := Chain(base, Validate(nil))
+		_, err := provider.RunInference(context.Background(), []message.Message{{Role: "tool", Content: "x"}}, nil)
+		var invalid *message.ValidationError
+		assert.ErrorAs(t, err, &invalid)
+		assert.Nil(t, received)
+	})
+}
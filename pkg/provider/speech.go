@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"io"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Transcriber 把录音转成文字，语音输入通过它使用兼容 Whisper 的服务
+type Transcriber interface {
+	// Transcribe 返回录音中说的话，name 是带扩展名的文件名，服务据此判断音频格式
+	Transcribe(ctx context.Context, audio io.Reader, name string) (string, error)
+}
+
+// Synthesizer 把文字读成语音
+type Synthesizer interface {
+	// Speak 返回朗读 text 的音频
+	Speak(ctx context.Context, text string) ([]byte, error)
+}
+
+// OpenAISpeech 通过 OpenAI Audio API 转写录音和朗读文字，也适用于兼容的服务，
+// 例如本地的 whisper.cpp 或 LocalAI；它同时满足 Transcriber 和 Synthesizer
+type OpenAISpeech struct {
+	client             openai.Client
+	TranscriptionModel string
+	SpeechModel        string
+	Voice              string
+	// Format 是朗读音频的格式：mp3、opus、aac、flac、wav 或 pcm
+	Format string
+}
+
+// NewOpenAISpeech 创建 OpenAI 语音提供方，默认使用 whisper-1 转写、tts-1 的 alloy 声音朗读 mp3；
+// opts 可以设置 base URL 等
+func NewOpenAISpeech(apiKey string, opts ...option.RequestOption) *OpenAISpeech {
+	return &OpenAISpeech{
+		client:             openai.NewClient(append([]option.RequestOption{option.WithAPIKey(apiKey)}, opts...)...),
+		TranscriptionModel: openai.AudioModelWhisper1,
+		SpeechModel:        openai.SpeechModelTTS1,
+		Voice:              string(openai.AudioSpeechNewParamsVoiceAlloy),
+		Format:             string(openai.AudioSpeechNewParamsResponseFormatMP3),
+	}
+}
+
+func (s *OpenAISpeech) Transcribe(ctx context.Context, audio io.Reader, name string) (string, error) {
+	transcription, err := s.client.Audio.Transcriptions.New(ctx, openai.AudioTranscriptionNewParams{
+		File:  openai.File(audio, name, ""),
+		Model: s.TranscriptionModel,
+	})
+	if err != nil {
+		return "", err
+	}
+	return transcription.Text, nil
+}
+
+func (s *OpenAISpeech) Speak(ctx context.Context, text string) ([]byte, error) {
+	response, err := s.client.Audio.Speech.New(ctx, openai.AudioSpeechNewParams{
+		Input:          text,
+		Model:          s.SpeechModel,
+		Voice:          openai.AudioSpeechNewParamsVoice(s.Voice),
+		ResponseFormat: openai.AudioSpeechNewParamsResponseFormat(s.Format),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return io.ReadAll(response.Body)
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAISpeech(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/audio/transcriptions":
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			assert.Equal(t, "whisper-1", r.FormValue("model"))
+			file, header, err := r.FormFile("file")
+			require.NoError(t, err)
+			assert.Equal(t, "voice.wav", header.Filename)
+			data, _ := io.ReadAll(file)
+			assert.Equal(t, "RIFF", string(data))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"text": "fix the failing test"}`))
+		case "/audio/speech":
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "tts-1", body["model"])
+			assert.Equal(t, "nova", body["voice"])
+			assert.Equal(t, "mp3", body["response_format"])
+			assert.Equal(t, "Done.", body["input"])
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Write([]byte("ID3 audio"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	speech := NewOpenAISpeech("test-key", option.WithBaseURL(server.URL), option.WithMaxRetries(0))
+	speech.Voice = "nova"
+
+	t.Run("转写录音", func(t *testing.T) {
+		text, err := speech.Transcribe(context.Background(), strings.NewReader("RIFF"), "voice.wav")
+		require.NoError(t, err)
+		assert.Equal(t, "fix the failing test", text)
+	})
+
+	t.Run("朗读文字", func(t *testing.T) {
+		audio, err := speech.Speak(context.Background(), "Done.")
+		require.NoError(t, err)
+		assert.Equal(t, "ID3 audio", string(audio))
+	})
+}
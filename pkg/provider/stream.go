@@ -0,0 +1,18 @@
+package provider
+
+import "context"
+
+type textDeltaKey struct{}
+
+// WithTextDelta 返回带有 onDelta 的 ctx。用它发起推理时提供方以流式方式请求，
+// 回复的文本每到达一段就交给 onDelta，返回的 Response 与不流式时相同。
+// 与 WithToolChoice 一样通过 ctx 传递，中间件不需要了解这个设置
+func WithTextDelta(ctx context.Context, onDelta func(text string)) context.Context {
+	return context.WithValue(ctx, textDeltaKey{}, onDelta)
+}
+
+// TextDeltaFrom 返回 ctx 中接收文本片段的函数，没有设置时返回 nil
+func TextDeltaFrom(ctx context.Context) func(text string) {
+	onDelta, _ := ctx.Value(textDeltaKey{}).(func(text string))
+	return onDelta
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent/pkg/message"
+
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/openai/openai-go/option"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseServer 返回一个对任何请求都回复 events 的 SSE 服务，并记录请求体
+func sseServer(t *testing.T, events []string, body *string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		*body = string(data)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTextDelta(t *testing.T) {
+	conversation, defs := toolConversation()
+
+	t.Run("OpenAI 流式请求并逐段回调文本", func(t *testing.T) {
+		var body string
+		server := sseServer(t, []string{
+			`data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"先读"}}]}`,
+			`data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"文件"}}]}`,
+			`data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":"}}]}}]}`,
+			`data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go.mod\"}"}}]}}]}`,
+			`data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":20,"completion_tokens":5,"total_tokens":25,"prompt_tokens_details":{"cached_tokens":8}}}`,
+			`data: [DONE]`,
+		}, &body)
+		provider := NewOpenAI("test-key", option.WithBaseURL(server.URL), option.WithMaxRetries(0))
+
+		var deltas []string
+		ctx := WithTextDelta(context.Background(), func(text string) { deltas = append(deltas, text) })
+		response, err := provider.RunInference(ctx, conversation, defs)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"先读", "文件"}, deltas)
+		assert.Equal(t, "先读文件", response.Content)
+		assert.Equal(t, message.StopToolUse, response.StopReason)
+		assert.Equal(t, message.Usage{InputTokens: 20, OutputTokens: 5, CachedTokens: 8}, response.Usage)
+		require.Len(t, response.ToolCalls, 1)
+		assert.Equal(t, "read_file", response.ToolCalls[0].Name)
+		assert.JSONEq(t, `{"path":"go.mod"}`, string(response.ToolCalls[0].Input))
+		assert.Contains(t, body, `"stream":true`)
+		assert.Contains(t, body, `"include_usage":true`)
+	})
+
+	t.Run("Anthropic 流式请求并逐段回调文本", func(t *testing.T) {
+		var body string
+		server := sseServer(t, []string{
+			"event: message_start\ndata: " + `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-7-sonnet-latest","content":[],"usage":{"input_tokens":20,"output_tokens":1}}}`,
+			"event: content_block_start\ndata: " + `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			"event: content_block_delta\ndata: " + `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"先读"}}`,
+			"event: content_block_delta\ndata: " + `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"文件"}}`,
+			"event: content_block_stop\ndata: " + `{"type":"content_block_stop","index":0}`,
+			"event: content_block_start\ndata: " + `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{}}}`,
+			"event: content_block_delta\ndata: " + `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\": \"go.mod\"}"}}`,
+			"event: content_block_stop\ndata: " + `{"type":"content_block_stop","index":1}`,
+			"event: message_delta\ndata: " + `{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+			"event: message_stop\ndata: " + `{"type":"message_stop"}`,
+		}, &body)
+		provider := NewAnthropic(anthropicoption.WithAPIKey("test-key"), anthropicoption.WithBaseURL(server.URL), anthropicoption.WithMaxRetries(0))
+
+		var deltas []string
+		ctx := WithTextDelta(context.Background(), func(text string) { deltas = append(deltas, text) })
+		response, err := provider.RunInference(ctx, conversation, defs)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"先读", "文件"}, deltas)
+		assert.Equal(t, "先读文件", response.Content)
+		assert.Equal(t, message.StopToolUse, response.StopReason)
+		assert.Equal(t, int64(20), response.Usage.InputTokens)
+		assert.Equal(t, int64(5), response.Usage.OutputTokens)
+		require.Len(t, response.ToolCalls, 1)
+		assert.Equal(t, "read_file", response.ToolCalls[0].Name)
+		assert.JSONEq(t, `{"path":"go.mod"}`, string(response.ToolCalls[0].Input))
+		assert.Contains(t, body, `"stream":true`)
+	})
+
+	t.Run("没有回调时不流式请求", func(t *testing.T) {
+		assert.Nil(t, TextDeltaFrom(context.Background()))
+	})
+}
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// TokenCounter 是能在发送请求之前计算其输入 token 数的提供方
+type TokenCounter interface {
+	// CountTokens 返回请求的输入 token 数和计算方式的说明
+	CountTokens(ctx context.Context, conversation []message.Message, tools []tools.ToolDefinition) (int64, string, error)
+}
+
+// CountTokens 用 p 计算请求的输入 token 数；p 不能计算或计算失败（例如离线）时
+// 用 tiktoken 的 cl100k_base 编码在本地估算
+func CountTokens(ctx context.Context, p Provider, conversation []message.Message, defs []tools.ToolDefinition) (int64, string, error) {
+	if counter, ok := p.(TokenCounter); ok {
+		if n, method, err := counter.CountTokens(ctx, conversation, defs); err == nil {
+			return n, method, nil
+		}
+	}
+	return Tiktoken(tiktoken.MODEL_CL100K_BASE, conversation, defs)
+}
+
+func init() {
+	// 编码随程序一起发布，不在运行时下载
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// encodings 缓存加载过的编码，加载一个编码需要几十毫秒
+var encodings sync.Map
+
+func encoding(name string) (*tiktoken.Tiktoken, error) {
+	if enc, ok := encodings.Load(name); ok {
+		return enc.(*tiktoken.Tiktoken), nil
+	}
+	enc, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	encodings.Store(name, enc)
+	return enc, nil
+}
+
+// Tiktoken 用名为 name 的 tiktoken 编码在本地计算请求的 token 数。每条消息按
+// OpenAI 的格式另加 3 个 token，回复前再加 3 个；工具按 JSON 定义计算
+func Tiktoken(name string, conversation []message.Message, defs []tools.ToolDefinition) (int64, string, error) {
+	enc, err := encoding(name)
+	if err != nil {
+		return 0, "", err
+	}
+	count := func(s string) int64 { return int64(len(enc.EncodeOrdinary(s))) }
+	n := int64(3)
+	for _, msg := range conversation {
+		n += 3 + count(msg.Role) + count(msg.Content)
+		if msg.ToolCall != nil {
+			n += count(msg.ToolCall.Name) + count(string(msg.ToolCall.Input))
+		}
+	}
+	for _, def := range defs {
+		schema, err := json.Marshal(def.InputSchema)
+		if err != nil {
+			return 0, "", err
+		}
+		n += count(def.Name) + count(def.Description) + count(string(schema))
+	}
+	return n, "tiktoken " + name, nil
+}
+
+// CountTokens 用 Anthropic 的 count_tokens API 计算，结果与推理时计费的输入 token 一致
+func (ap *Anthropic) CountTokens(ctx context.Context, conversation []message.Message, defs []tools.ToolDefinition) (int64, string, error) {
+	system, messages := anthropicMessages(conversation)
+	params := anthropic.MessageCountTokensParams{
+		Model:    anthropic.Model(ap.Model),
+		Messages: messages,
+	}
+	if len(system) > 0 {
+		params.System = anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: system}
+	}
+	for _, tool := range ap.tools.get(defs, anthropicTool) {
+		params.Tools = append(params.Tools, anthropic.MessageCountTokensToolUnionParam{OfTool: tool.OfTool})
+	}
+	count, err := ap.client.Messages.CountTokens(ctx, params)
+	if err != nil {
+		return 0, "", err
+	}
+	return count.InputTokens, "Anthropic count_tokens API", nil
+}
+
+// encodingForModel 返回 OpenAI 模型的 tiktoken 编码名
+func encodingForModel(model string) string {
+	if name, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return name
+	}
+	for prefix, name := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if strings.HasPrefix(model, prefix) {
+			return name
+		}
+	}
+	return tiktoken.MODEL_O200K_BASE
+}
+
+// CountTokens 用模型对应的 tiktoken 编码在本地计算，不认识的模型使用 o200k_base
+func (op *OpenAI) CountTokens(_ context.Context, conversation []message.Message, defs []tools.ToolDefinition) (int64, string, error) {
+	return Tiktoken(encodingForModel(op.Model), conversation, defs)
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountTokens(t *testing.T) {
+	conversation := []message.Message{{Role: "user", Content: "hello world"}}
+
+	t.Run("用 tiktoken 在本地计算", func(t *testing.T) {
+		n, method, err := Tiktoken("cl100k_base", conversation, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3+3+1+2), n, "回复前 3 个、每条消息 3 个、角色 1 个、内容 2 个")
+		assert.Equal(t, "tiktoken cl100k_base", method)
+
+		withTools, _, err := Tiktoken("cl100k_base", conversation, []tools.ToolDefinition{tools.ReadFileTool(&tools.Workspace{Root: "."})})
+		require.NoError(t, err)
+		assert.Greater(t, withTools, n, "工具定义也计入")
+	})
+
+	t.Run("提供方不能计算时在本地估算", func(t *testing.T) {
+		p := Func(func(context.Context, []message.Message, []tools.ToolDefinition) (*message.Response, error) {
+			return nil, nil
+		})
+		n, method, err := CountTokens(context.Background(), p, conversation, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(9), n)
+		assert.Equal(t, "tiktoken cl100k_base", method)
+	})
+
+	t.Run("OpenAI 按模型选择编码", func(t *testing.T) {
+		assert.Equal(t, "o200k_base", encodingForModel("gpt-4o-2024-08-06"))
+		assert.Equal(t, "cl100k_base", encodingForModel("gpt-4"))
+		assert.Equal(t, "o200k_base", encodingForModel("local-model"))
+	})
+
+	t.Run("Anthropic 调用 count_tokens，包装后仍然可用", func(t *testing.T) {
+		var request map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/messages/count_tokens", r.URL.Path)
+			body, _ := io.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(body, &request))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"input_tokens": 42}`))
+		}))
+		defer server.Close()
+		anthropic := NewAnthropic(anthropicoption.WithAPIKey("test-key"), anthropicoption.WithBaseURL(server.URL), anthropicoption.WithMaxRetries(0))
+		p := Chain(anthropic, Validate(nil))
+
+		system := append([]message.Message{{Role: "system", Content: "Be brief."}}, conversation...)
+		n, method, err := CountTokens(context.Background(), p, system, []tools.ToolDefinition{tools.ReadFileTool(&tools.Workspace{Root: "."})})
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), n)
+		assert.Equal(t, "Anthropic count_tokens API", method)
+		assert.NotEmpty(t, request["system"])
+		assert.Len(t, request["tools"], 1)
+
+		server.Close()
+		_, method, err = CountTokens(context.Background(), p, conversation, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "tiktoken cl100k_base", method, "API 不可用时在本地估算")
+	})
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"sync"
+
+	"agent/tools"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/param"
+	"github.com/openai/openai-go/shared"
+)
+
+// toolCache 保存转换成提供方格式的一组工具。agent 每次推理都传入同一个切片，
+// 工具变化时（切换配置档、/tools）换成新的切片，所以用切片本身判断能否复用转换结果
+type toolCache[T any] struct {
+	mu        sync.Mutex
+	tools     []tools.ToolDefinition
+	converted []T
+}
+
+// get 返回用 convert 转换后的 defs，只有 defs 不是上次转换的那一组时才重新转换
+func (c *toolCache[T]) get(defs []tools.ToolDefinition, convert func(tools.ToolDefinition) T) []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.converted != nil && sameTools(c.tools, defs) {
+		return c.converted
+	}
+	converted := make([]T, len(defs))
+	for i, tool := range defs {
+		converted[i] = convert(tool)
+	}
+	c.tools, c.converted = defs, converted
+	return converted
+}
+
+// sameTools 判断 a 和 b 是否是同一个切片
+func sameTools(a, b []tools.ToolDefinition) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// anthropicTool 把工具转换为 Anthropic 的工具参数
+func anthropicTool(tool tools.ToolDefinition) anthropic.ToolUnionParam {
+	return anthropic.ToolUnionParam{
+		OfTool: &anthropic.ToolParam{
+			InputSchema: tool.InputSchema,
+			Name:        tool.Name,
+			Description: anthropic.String(tool.Description),
+		},
+	}
+}
+
+// openAITool 把工具的输入 schema 转换为 OpenAI 的函数参数。Properties 可能是
+// jsonschema 的有序 map，也可能是普通 map，原样交给 JSON 编码。
+func openAITool(tool tools.ToolDefinition) openai.ChatCompletionToolParam {
+	properties := tool.InputSchema.Properties
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	params := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(tool.InputSchema.Required) > 0 {
+		params["required"] = tool.InputSchema.Required
+	}
+	return openai.ChatCompletionToolParam{
+		Function: shared.FunctionDefinitionParam{
+			Name:        tool.Name,
+			Description: param.NewOpt(tool.Description),
+			Parameters:  shared.FunctionParameters(params),
+		},
+	}
+}
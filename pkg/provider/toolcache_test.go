@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolCache(t *testing.T) {
+	var cache toolCache[string]
+	conversions := 0
+	convert := func(tool tools.ToolDefinition) string {
+		conversions++
+		return tool.Name
+	}
+	defs := (&tools.Workspace{Root: t.TempDir()}).Tools()
+
+	first := cache.get(defs, convert)
+	assert.Equal(t, len(defs), conversions)
+	assert.Equal(t, defs[0].Name, first[0])
+
+	t.Run("同一组工具只转换一次", func(t *testing.T) {
+		cache.get(defs, convert)
+		assert.Equal(t, len(defs), conversions)
+	})
+
+	t.Run("工具变化后重新转换", func(t *testing.T) {
+		fewer := append([]tools.ToolDefinition{}, defs[1:]...)
+		converted := cache.get(fewer, convert)
+		assert.Equal(t, 2*len(defs)-1, conversions)
+		assert.Equal(t, defs[1].Name, converted[0])
+	})
+
+	t.Run("转换后的 OpenAI 工具带有描述", func(t *testing.T) {
+		tool := openAITool(defs[0])
+		assert.Equal(t, defs[0].Name, tool.Function.Name)
+		assert.Equal(t, defs[0].Description, tool.Function.Description.Value)
+	})
+
+	t.Run("转换后的 OpenAI 工具带有参数 schema", func(t *testing.T) {
+		tool := openAITool(tools.ReadFileTool(&tools.Workspace{Root: t.TempDir()}))
+		data, err := json.Marshal(tool.Function.Parameters)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type": "object", "required": ["path"], "properties": {"path": {"type": "string", "description": "The relative path of a file in the working directory."}}}`, string(data))
+	})
+}
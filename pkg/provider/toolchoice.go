@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/param"
+)
+
+// ToolChoice 控制一次推理中模型如何使用工具，零值等同于 ToolAuto
+type ToolChoice struct {
+	Mode string `json:"mode"`
+	// Name 是 Mode 为 ToolForce 时必须调用的工具
+	Name string `json:"name,omitempty"`
+}
+
+const (
+	// ToolAuto 由模型决定是否调用工具
+	ToolAuto = "auto"
+	// ToolRequired 要求模型至少调用一个工具
+	ToolRequired = "required"
+	// ToolNone 禁止调用工具，工具定义仍然发送，对话中的工具调用保持有效
+	ToolNone = "none"
+	// ToolForce 要求模型调用 Name 指定的工具
+	ToolForce = "tool"
+)
+
+// ParseToolChoice 解析 auto、required、none 或工具名称
+func ParseToolChoice(s string) ToolChoice {
+	switch s {
+	case "", ToolAuto:
+		return ToolChoice{}
+	case ToolRequired, ToolNone:
+		return ToolChoice{Mode: s}
+	}
+	return ToolChoice{Mode: ToolForce, Name: s}
+}
+
+// IsAuto 判断是否由模型决定，此时请求中不带 tool_choice
+func (c ToolChoice) IsAuto() bool {
+	return c.Mode == "" || c.Mode == ToolAuto
+}
+
+func (c ToolChoice) String() string {
+	switch {
+	case c.IsAuto():
+		return ToolAuto
+	case c.Mode == ToolForce:
+		return c.Name
+	}
+	return c.Mode
+}
+
+type toolChoiceKey struct{}
+
+// WithToolChoice 返回带有 choice 的 ctx，用它发起的推理按 choice 使用工具。
+// 通过 ctx 传递，中间件不需要了解这个设置
+func WithToolChoice(ctx context.Context, choice ToolChoice) context.Context {
+	return context.WithValue(ctx, toolChoiceKey{}, choice)
+}
+
+// ToolChoiceFrom 返回 ctx 中的工具选择，没有设置时返回零值
+func ToolChoiceFrom(ctx context.Context) ToolChoice {
+	choice, _ := ctx.Value(toolChoiceKey{}).(ToolChoice)
+	return choice
+}
+
+// anthropicToolChoice 把工具选择转换为 Anthropic 的参数，ToolRequired 对应 any
+func anthropicToolChoice(choice ToolChoice) anthropic.ToolChoiceUnionParam {
+	switch choice.Mode {
+	case ToolRequired:
+		return anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{}}
+	case ToolNone:
+		return anthropic.ToolChoiceUnionParam{OfNone: &anthropic.ToolChoiceNoneParam{}}
+	case ToolForce:
+		return anthropic.ToolChoiceParamOfTool(choice.Name)
+	}
+	return anthropic.ToolChoiceUnionParam{OfAuto: &anthropic.ToolChoiceAutoParam{}}
+}
+
+// openAIToolChoice 把工具选择转换为 OpenAI 的参数
+func openAIToolChoice(choice ToolChoice) openai.ChatCompletionToolChoiceOptionUnionParam {
+	if choice.Mode == ToolForce {
+		return openai.ChatCompletionToolChoiceOptionParamOfChatCompletionNamedToolChoice(
+			openai.ChatCompletionNamedToolChoiceFunctionParam{Name: choice.Name})
+	}
+	return openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: param.NewOpt(choice.String())}
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolChoice(t *testing.T) {
+	t.Run("解析", func(t *testing.T) {
+		assert.True(t, ParseToolChoice("").IsAuto())
+		assert.True(t, ParseToolChoice("auto").IsAuto())
+		assert.Equal(t, ToolChoice{Mode: ToolNone}, ParseToolChoice("none"))
+		assert.Equal(t, ToolChoice{Mode: ToolForce, Name: "edit_file"}, ParseToolChoice("edit_file"))
+		assert.Equal(t, "edit_file", ParseToolChoice("edit_file").String())
+		assert.Equal(t, "auto", ToolChoice{}.String())
+	})
+
+	t.Run("通过 ctx 传递", func(t *testing.T) {
+		assert.True(t, ToolChoiceFrom(context.Background()).IsAuto())
+		ctx := WithToolChoice(context.Background(), ToolChoice{Mode: ToolRequired})
+		assert.Equal(t, ToolRequired, ToolChoiceFrom(ctx).Mode)
+	})
+
+	t.Run("转换为提供方的参数", func(t *testing.T) {
+		for choice, want := range map[ToolChoice][2]string{
+			{Mode: ToolRequired}:                 {`{"type":"any"}`, `"required"`},
+			{Mode: ToolNone}:                     {`{"type":"none"}`, `"none"`},
+			{Mode: ToolForce, Name: "edit_file"}: {`{"name":"edit_file","type":"tool"}`, `{"function":{"name":"edit_file"},"type":"function"}`},
+		} {
+			data, err := json.Marshal(anthropicToolChoice(choice))
+			require.NoError(t, err)
+			assert.JSONEq(t, want[0], string(data), choice.String())
+			data, err = json.Marshal(openAIToolChoice(choice))
+			require.NoError(t, err)
+			assert.JSONEq(t, want[1], string(data), choice.String())
+		}
+	})
+
+	t.Run("工具选择参与缓存键", func(t *testing.T) {
+		calls := 0
+		base := Func(func(context.Context, []message.Message, []tools.ToolDefinition) (*message.Response, error) {
+			calls++
+			return &message.Response{Content: "hi"}, nil
+		})
+		provider := Chain(base, Cache(t.TempDir(), "model"))
+		conversation := []message.Message{{Role: "user", Content: "hello"}}
+		for _, ctx := range []context.Context{
+			context.Background(),
+			WithToolChoice(context.Background(), ToolChoice{Mode: ToolAuto}),
+			WithToolChoice(context.Background(), ToolChoice{Mode: ToolNone}),
+		} {
+			_, err := provider.RunInference(ctx, conversation, nil)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, calls, "显式的 auto 与不设置相同")
+	})
+}
@@ -0,0 +1,244 @@
+// Package vcr 在 HTTP 层录制和回放与模型服务的交互。录制时请求发往真实服务，
+// 交互保存为 JSON 文件；回放时按请求找到录制的响应，不需要网络和 API key，
+// 这样 provider 的请求和响应转换可以在 CI 中用真实数据测试。
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EnvMode 是选择模式的环境变量，值为 record 时录制，否则回放
+const EnvMode = "AGENT_VCR"
+
+// Mode 是录制器的工作模式
+type Mode int
+
+const (
+	// ModeReplay 只回放录制的交互，没有匹配的录制时请求失败
+	ModeReplay Mode = iota
+	// ModeRecord 把请求发往真实服务并录制交互
+	ModeRecord
+)
+
+// ModeFromEnv 根据 EnvMode 环境变量返回模式
+func ModeFromEnv() Mode {
+	if os.Getenv(EnvMode) == "record" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// droppedHeaders 是不保存的响应头，它们可能带有账号信息
+var droppedHeaders = []string{"Set-Cookie", "Openai-Organization", "Openai-Project", "Anthropic-Organization-Id"}
+
+// Request 是录制的请求。请求头不保存，其中有 API key。
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response 是录制的响应
+type Response struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Interaction 是一次请求和它的响应
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette 是保存到文件的一组交互
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder 是录制或回放交互的 http.RoundTripper
+type Recorder struct {
+	path string
+	mode Mode
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	// used 记录回放时已经用过的交互，相同的请求按录制顺序回放
+	used []bool
+}
+
+// New 返回使用文件 path 的录制器。回放时读取文件，文件不存在时返回的错误
+// 满足 errors.Is(err, os.ErrNotExist)；录制时请求经 next 发出，next 为 nil
+// 时使用 http.DefaultTransport。
+func New(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{path: path, mode: mode, next: next}
+	if mode == ModeRecord {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	recorded := Request{Method: req.Method, Path: req.URL.Path, Body: encodeBody(body)}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	for _, name := range droppedHeaders {
+		header.Del(name)
+	}
+	header.Del("Content-Length")
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  recorded,
+		Response: Response{Status: resp.StatusCode, Header: header, Body: encodeBody(respBody)},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay 返回第一个还没用过、方法、路径和请求体都相同的录制
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !sameRequest(interaction.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+		body := decodeBody(interaction.Response.Body)
+		header := interaction.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+			StatusCode:    interaction.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("vcr: %s has no recorded interaction for %s %s with this body; record it again with %s=record",
+		r.path, recorded.Method, recorded.Path, EnvMode)
+}
+
+// Save 在录制模式下把录制的交互写入文件，回放模式下什么也不做
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cassette.Interactions) == 0 {
+		return errors.New("vcr: nothing was recorded")
+	}
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// sameRequest 比较方法、路径和请求体，JSON 请求体比较解析后的值，不管空白和键的顺序
+func sameRequest(a, b Request) bool {
+	if a.Method != b.Method || a.Path != b.Path {
+		return false
+	}
+	bodyA, bodyB := decodeBody(a.Body), decodeBody(b.Body)
+	var valueA, valueB interface{}
+	if json.Unmarshal(bodyA, &valueA) == nil && json.Unmarshal(bodyB, &valueB) == nil {
+		return reflect.DeepEqual(valueA, valueB)
+	}
+	return bytes.Equal(bodyA, bodyB)
+}
+
+// encodeBody 把 JSON 请求体原样保存以便阅读，其他内容（例如 SSE 流）保存为 JSON 字符串
+func encodeBody(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, body); err == nil && !strings.HasPrefix(compact.String(), `"`) {
+			return compact.Bytes()
+		}
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
+
+// decodeBody 是 encodeBody 的逆操作，JSON 请求体以紧凑形式返回
+func decodeBody(raw json.RawMessage) []byte {
+	if len(raw) == 0 {
+		return nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return []byte(s)
+		}
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return raw
+	}
+	return compact.Bytes()
+}
@@ -0,0 +1,117 @@
+package vcr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func post(t *testing.T, client *http.Client, url, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret-key")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
+
+func TestRecorder(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=private")
+		w.Header().Set("Request-Id", "req_1")
+		if strings.Contains(string(body), "stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"n\":"+string(rune('0'+calls))+"}\n\n")
+			return
+		}
+		io.WriteString(w, `{"reply": "hello", "n": `+string(rune('0'+calls))+`}`)
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+
+	t.Run("录制真实交互", func(t *testing.T) {
+		rec, err := New(path, ModeRecord, nil)
+		require.NoError(t, err)
+		client := &http.Client{Transport: rec}
+
+		resp, body := post(t, client, server.URL+"/v1/messages", `{"prompt": "hi"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `{"reply": "hello", "n": 1}`, body)
+		_, body = post(t, client, server.URL+"/v1/messages", `{"prompt": "hi"}`)
+		assert.Equal(t, `{"reply": "hello", "n": 2}`, body)
+		_, body = post(t, client, server.URL+"/v1/messages", `{"stream": true}`)
+		assert.Equal(t, "data: {\"n\":3}\n\n", body)
+		require.NoError(t, rec.Save())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "secret-key", "不保存请求头")
+		assert.NotContains(t, string(data), "private", "不保存 Set-Cookie")
+		assert.NotContains(t, string(data), server.URL, "只保存路径")
+		assert.Contains(t, string(data), `"prompt": "hi"`, "JSON 请求体保存为对象")
+		assert.Contains(t, string(data), "req_1")
+	})
+
+	t.Run("回放不访问网络", func(t *testing.T) {
+		rec, err := New(path, ModeReplay, nil)
+		require.NoError(t, err)
+		client := &http.Client{Transport: rec}
+		before := calls
+
+		resp, body := post(t, client, "https://api.example.com/v1/messages", `{"prompt":"hi"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `{"reply":"hello","n":1}`, body, "忽略空白的差异")
+		_, body = post(t, client, "https://api.example.com/v1/messages", `{"prompt":"hi"}`)
+		assert.Equal(t, `{"reply":"hello","n":2}`, body, "相同请求按录制顺序回放")
+		resp, body = post(t, client, "https://api.example.com/v1/messages", `{"stream":true}`)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "data: {\"n\":3}\n\n", body, "非 JSON 响应原样回放")
+		assert.Equal(t, before, calls)
+		assert.NoError(t, rec.Save(), "回放模式不写文件")
+	})
+
+	t.Run("没有匹配的录制", func(t *testing.T) {
+		rec, err := New(path, ModeReplay, nil)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "https://api.example.com/v1/messages", strings.NewReader(`{"prompt":"bye"}`))
+		require.NoError(t, err)
+		_, err = rec.RoundTrip(req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no recorded interaction for POST /v1/messages")
+		assert.Contains(t, err.Error(), "AGENT_VCR=record")
+	})
+
+	t.Run("录制文件不存在", func(t *testing.T) {
+		_, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("没有录制到交互", func(t *testing.T) {
+		rec, err := New(filepath.Join(t.TempDir(), "empty.json"), ModeRecord, nil)
+		require.NoError(t, err)
+		assert.Error(t, rec.Save())
+	})
+}
+
+func TestModeFromEnv(t *testing.T) {
+	t.Setenv(EnvMode, "record")
+	assert.Equal(t, ModeRecord, ModeFromEnv())
+	t.Setenv(EnvMode, "")
+	assert.Equal(t, ModeReplay, ModeFromEnv())
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+)
+
+// replyProcessor rewrites an assistant reply before it is shown and saved
+type replyProcessor func(ctx context.Context, a *Agent, reply string) (string, error)
+
+// replyProcessors are the built-in post-processors by name (see config.PostProcessors)
+var replyProcessors = map[string]replyProcessor{
+	"strip_thinking": stripThinking,
+	"language":       enforceLanguage,
+	"extract_code":   extractCode,
+}
+
+// thinkingBlock matches the chain-of-thought some models wrap in tags
+var thinkingBlock = regexp.MustCompile(`(?is)<(thinking|think|reasoning)>.*?</(thinking|think|reasoning)>`)
+
+// inlineCode matches code in a reply, which says nothing about its language
+var inlineCode = regexp.MustCompile("(?s)```.*?```|`[^`]*`")
+
+// namedCodeBlock matches a fenced code block whose info string names a file,
+// e.g. ```go cmd/main.go
+var namedCodeBlock = regexp.MustCompile("(?ms)^```[\\w+-]* +([^\\s`]+)\\n(.*?)^```[ \\t]*$")
+
+const languagePrompt = `Rewrite the following reply in %s. Keep code blocks, identifiers, commands and
+file paths unchanged. Reply with the rewritten text only.
+
+%s`
+
+// postProcess passes a reply through the session's post-processors, then the
+// scripts' after_reply hooks. A failing processor is reported and skipped, so
+// the reply is never lost.
+func (a *Agent) postProcess(ctx context.Context, reply string) string {
+	for _, name := range a.postProcessors {
+		processed, err := replyProcessors[name](ctx, a, reply)
+		if err != nil {
+			a.log().Warn("reply post-processor failed", "processor", name, "error", err)
+			a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: %s: %v", theme.Error(i18n.T("Post-processing failed")), name, err)})
+			continue
+		}
+		reply = processed
+	}
+	if a.scripts != nil {
+		processed, err := a.scripts.AfterReply(a.hookWorkspace().ScriptEnv(nil), reply)
+		if err != nil {
+			a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: %v", theme.Error(i18n.T("Script error")), err)})
+		} else {
+			reply = processed
+		}
+	}
+	return reply
+}
+
+// stripThinking removes <thinking> blocks and the blank lines they leave behind
+func stripThinking(_ context.Context, _ *Agent, reply string) (string, error) {
+	return strings.TrimSpace(thinkingBlock.ReplaceAllString(reply, "")), nil
+}
+
+// enforceLanguage asks the model to rewrite a reply that is not in the
+// configured language (the interface language by default)
+func enforceLanguage(ctx context.Context, a *Agent, reply string) (string, error) {
+	want := a.config.Replies.Language
+	if want == "" {
+		want = i18n.Current()
+	}
+	got := replyLanguage(reply)
+	if got == "" || got == want {
+		return reply, nil
+	}
+	name := map[string]string{"zh": "Chinese", "en": "English"}[want]
+	response, err := a.provider.RunInference(ctx, []Message{{Role: "user", Content: fmt.Sprintf(languagePrompt, name, reply)}}, nil)
+	if err != nil {
+		return "", err
+	}
+	a.recordUsage(response.Model, response.Usage)
+	if strings.TrimSpace(response.Content) == "" {
+		return "", fmt.Errorf("the model returned an empty rewrite")
+	}
+	return response.Content, nil
+}
+
+// replyLanguage guesses whether prose is Chinese or English from its letters
+// outside code; it returns "" when there is too little prose to tell
+func replyLanguage(reply string) string {
+	prose := inlineCode.ReplaceAllString(reply, "")
+	han, latin := 0, 0
+	for _, r := range prose {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin++
+		}
+	}
+	switch {
+	case han+latin < 20:
+		return ""
+	case han*4 >= latin:
+		// A Han character carries about as much as a word of four letters
+		return "zh"
+	}
+	return "en"
+}
+
+// extractCode writes code blocks that name a file into the workspace; the
+// reply itself is left as it is
+func extractCode(_ context.Context, a *Agent, reply string) (string, error) {
+	if a.config.Remote.Host != "" {
+		return "", fmt.Errorf("files cannot be extracted into a remote workspace")
+	}
+	workspace := &tools.Workspace{Root: currentWorkspace.Root}
+	var written []string
+	for _, match := range namedCodeBlock.FindAllStringSubmatch(reply, -1) {
+		name := match[1]
+		if slices.Contains(written, name) {
+			continue
+		}
+		path, err := workspace.Resolve(name)
+		if err != nil {
+			return "", err
+		}
+		if limit := a.config.Limits.MaxWriteBytes; limit > 0 && int64(len(match[2])) > limit {
+			return "", fmt.Errorf("%s is larger than the write limit of %d bytes", name, limit)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(match[2]), 0644); err != nil {
+			return "", err
+		}
+		a.reads.Forget(path)
+		written = append(written, name)
+	}
+	if len(written) > 0 {
+		a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("Wrote %s", strings.Join(written, ", "))})
+	}
+	return reply, nil
+}
+
+// runPostProcessCommand shows or sets the session's reply post-processors
+func runPostProcessCommand(a *Agent, args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "off":
+		a.postProcessors = nil
+	case len(args) > 0:
+		for _, name := range args {
+			if !slices.Contains(config.PostProcessors, name) {
+				return fmt.Errorf("unknown post-processor %q, want one of %s", name, strings.Join(config.PostProcessors, ", "))
+			}
+		}
+		a.postProcessors = args
+	}
+	if len(a.postProcessors) == 0 {
+		fmt.Println(i18n.T("Replies are shown as the model wrote them"))
+		return nil
+	}
+	fmt.Println(i18n.Sprintf("Replies pass through %s", strings.Join(a.postProcessors, " → ")))
+	return nil
+}
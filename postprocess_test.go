@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostProcess(t *testing.T) {
+	t.Run("去掉思考过程", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "<thinking>\nThe user wants a sum.\n</thinking>\n\nIt is 4."}}}
+		agent := NewAgent(provider, nil, nil)
+		agent.onEvent = func(AgentEvent) {}
+		agent.postProcessors = []string{"strip_thinking"}
+		require.NoError(t, agent.runTurn(context.Background(), "2+2?"))
+		messages := agent.Messages()
+		assert.Equal(t, "It is 4.", messages[len(messages)-1].Content, "保存处理后的回复")
+	})
+
+	t.Run("回复不是要求的语言时请模型改写", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "这个函数在解析配置文件时没有检查空值。"}}}
+		agent := NewAgent(provider, nil, nil)
+		agent.config.Replies.Language = "zh"
+		reply, err := enforceLanguage(context.Background(), agent, "The function does not check for nil while parsing the config file.")
+		require.NoError(t, err)
+		assert.Equal(t, "这个函数在解析配置文件时没有检查空值。", reply)
+		assert.Contains(t, provider.calls[0][0].Content, "Rewrite the following reply in Chinese")
+
+		reply, err = enforceLanguage(context.Background(), agent, reply)
+		require.NoError(t, err)
+		assert.Len(t, provider.calls, 1, "已经是中文时不改写")
+		assert.Equal(t, "", replyLanguage("OK, `go test ./...`"), "文字太少时无法判断")
+	})
+
+	t.Run("把标明文件的代码块写入工作区", func(t *testing.T) {
+		dir := t.TempDir()
+		chdir(t, dir)
+		agent := NewAgent(nil, nil, nil)
+		var notices []string
+		agent.onEvent = func(e AgentEvent) { notices = append(notices, e.Content) }
+		reply := "Here it is:\n\n```go cmd/hello.go\npackage main\n```\n\n```sh\ngo run ./cmd\n```\n"
+		out, err := extractCode(context.Background(), agent, reply)
+		require.NoError(t, err)
+		assert.Equal(t, reply, out)
+		data, err := os.ReadFile(filepath.Join(dir, "cmd", "hello.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "package main\n", string(data))
+		assert.Equal(t, []string{"Wrote cmd/hello.go"}, notices)
+
+		_, err = extractCode(context.Background(), agent, "```go ../escape.go\nx\n```\n")
+		assert.Error(t, err, "不能写到工作区之外")
+	})
+
+	t.Run("处理失败时保留原回复", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{}, nil, nil)
+		agent.onEvent = func(AgentEvent) {}
+		agent.postProcessors = []string{"language", "strip_thinking"}
+		agent.config.Replies.Language = "zh"
+		reply := "<think>hmm</think>The function does not check for nil while parsing the config file."
+		assert.Equal(t, "The function does not check for nil while parsing the config file.", agent.postProcess(context.Background(), reply))
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/search"
+	"agent/tools"
+)
+
+// applyConfig builds the provider, tools and instructions described by cfg and
+// installs them, keeping the session budget in force; it also captures the
+// git context of the workspace
+func (a *Agent) applyConfig(cfg *config.Config) (string, error) {
+	logger := a.logFor(subsystemProvider)
+	if a.requests == nil {
+		a.requests = &requestDump{session: a.sessionID}
+	}
+	a.requests.logger = logger
+	a.requests.dir = ""
+	if cfg.DumpRequests != "" {
+		dir, err := cfg.ResolvePath(cfg.DumpRequests)
+		if err != nil {
+			return "", err
+		}
+		a.requests.dir = dir
+	}
+	recorders := []exchangeRecorder{a.requests}
+	transcripts, err := defaultTranscriptLog(cfg, a.sessionID, logger)
+	if err != nil {
+		return "", err
+	}
+	if transcripts != nil {
+		recorders = append(recorders, transcripts)
+	}
+	provider, name, err := newProvider(cfg, logger, recorders...)
+	if err != nil {
+		return "", err
+	}
+	if a.reads == nil {
+		a.reads = &tools.ReadCache{}
+	}
+	tools, err := enabledTools(cfg, a.reads)
+	if err != nil {
+		return "", err
+	}
+	instructions, err := loadInstructions(cfg)
+	if err != nil {
+		return "", err
+	}
+	knowledge, err := loadKnowledge(cfg)
+	if err != nil {
+		return "", err
+	}
+	sections, err := loadSystemSections(cfg)
+	if err != nil {
+		return "", err
+	}
+	var repoIndex *search.Index
+	if cfg.System.RepoMap && cfg.Remote.Host == "" {
+		if repoIndex, err = workspaceIndex(currentWorkspace.Root); err != nil {
+			return "", fmt.Errorf("workspace index: %w", err)
+		}
+	}
+	if a.metrics != nil {
+		provider = Chain(provider, MetricsMiddleware(a.metrics, providerName(cfg)))
+	}
+	if a.budget != nil {
+		provider = Chain(provider, BudgetMiddleware(a.budget))
+	}
+
+	a.provider = provider
+	a.setTools(tools)
+	// enabledTools has loaded the scripts; remember the version the tools come from
+	a.scripts, _ = scriptLoader(cfg).Load()
+	a.instructions = instructions
+	a.knowledge = knowledge
+	a.customSections = sections
+	a.repoIndex = repoIndex
+	a.config = cfg
+	a.postProcessors = cfg.Replies.PostProcess
+	a.refreshGitContext()
+	return name, nil
+}
+
+// runProfileCommand lists profiles, or switches the session to another one
+func runProfileCommand(a *Agent, args []string) error {
+	if len(args) == 0 {
+		names := a.config.ProfileNames()
+		if len(names) == 0 {
+			fmt.Println(i18n.T("No profiles configured; add a profiles section to config.yaml"))
+			return nil
+		}
+		for _, name := range names {
+			marker := " "
+			if name == a.config.Profile {
+				marker = "*"
+			}
+			p := a.config.Profiles[name]
+			fmt.Printf("%s %-20s %s %s\n", marker, name, p.Provider, p.Model)
+		}
+		return nil
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /profile [name]")
+	}
+	if a.resolveProfile == nil {
+		return fmt.Errorf("switching profiles is not supported here")
+	}
+
+	cfg, err := a.resolveProfile(args[0])
+	if err != nil {
+		return err
+	}
+	name, err := a.applyConfig(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Println(i18n.Sprintf("Switched to profile %s (%s)", args[0], name))
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileCommand(t *testing.T) {
+	// 不在 Go 模块中，只提供内置的 5 个工具
+	chdir(t, t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+provider: openai
+base_url: http://localhost:1/v1
+profile: small
+profiles:
+  small:
+    model: gpt-4o-mini
+  local:
+    model: qwen2.5-coder
+    tools:
+      disabled: [edit_file, shell]
+`), 0644))
+	t.Setenv("AGENT_PROFILE", "")
+	global := &globalOptions{configPath: path}
+
+	cfg, err := global.resolveConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, "small", cfg.Profile, "默认使用配置中的 profile")
+
+	agent := NewAgent(nil, nil, nil)
+	_, err = agent.applyConfig(cfg)
+	require.NoError(t, err)
+	agent.resolveProfile = global.resolveConfig
+	require.Len(t, agent.tools, 5)
+
+	t.Run("列出配置档", func(t *testing.T) {
+		out := captureStdout(func() {
+			_, err := agent.handleCommand("/profile")
+			require.NoError(t, err)
+		})
+		assert.Contains(t, out, "* small")
+		assert.Contains(t, out, "  local")
+	})
+
+	t.Run("切换配置档重建工具", func(t *testing.T) {
+		out := captureStdout(func() {
+			_, err := agent.handleCommand("/profile local")
+			require.NoError(t, err)
+		})
+		assert.Contains(t, out, "Switched to profile local (OpenAI qwen2.5-coder)")
+		assert.Equal(t, "qwen2.5-coder", agent.config.Model)
+		require.Len(t, agent.tools, 3)
+		assert.Equal(t, "read_file", agent.tools[0].Name)
+	})
+
+	t.Run("未知配置档保持不变", func(t *testing.T) {
+		_, err := agent.handleCommand("/profile nope")
+		assert.Error(t, err)
+		assert.Equal(t, "local", agent.config.Profile)
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"agent/config"
+	"agent/tools"
+)
+
+// loadInstructions reads the instruction files listed in cfg into a single
+// system prompt, each file under a heading with its name
+func loadInstructions(cfg *config.Config) (string, error) {
+	var b strings.Builder
+	for _, name := range cfg.Instructions {
+		path, err := cfg.ResolvePath(name)
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read instruction file: %w", err)
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "Instructions from %s:\n\n%s", name, strings.TrimSpace(string(data)))
+	}
+	return b.String(), nil
+}
+
+// requestConversation is the conversation sent to the provider: the stored
+// messages preceded by the sections of the system prompt that have content,
+// by default the project instructions, the knowledge base, the git context
+// and the pinned files
+func (a *Agent) requestConversation() []Message {
+	var conversation []Message
+	for _, section := range a.systemSections() {
+		if !section.disabled && section.content != "" {
+			conversation = append(conversation, Message{Role: "system", Content: section.content})
+		}
+	}
+	return append(conversation, a.Messages()...)
+}
+
+// sandboxTools restricts tools to roots. Tools that read or write files must
+// name a path inside one of roots; a missing path means the whole workspace,
+// which is only allowed when the workspace itself is inside. Tools that run
+// programs (shell, custom and script tools, MCP servers, docker and the like)
+// can reach any file whatever their arguments say, so they are left out
+// rather than run in a container: the sandbox is meant to hold with the host
+// shell backend too. Tools that touch no files pass through.
+func sandboxTools(defs []tools.ToolDefinition, roots []string) []tools.ToolDefinition {
+	wrapped := []tools.ToolDefinition{}
+	check := func(input json.RawMessage) error {
+		path := toolPathHint(input)
+		if path == "" {
+			path = "."
+		}
+		if !insideRoots(path, roots) {
+			return tools.WithKind(tools.ErrorPermissionDenied, fmt.Errorf("path %s is outside the sandbox (%s)", path, strings.Join(roots, ", ")))
+		}
+		return nil
+	}
+	for _, def := range defs {
+		if runsPrograms(def) {
+			continue
+		}
+		if !slices.Contains(def.Permissions, tools.PermissionRead) && !slices.Contains(def.Permissions, tools.PermissionWrite) {
+			wrapped = append(wrapped, def)
+			continue
+		}
+		run, stream := def.Function, def.Stream
+		def.Function = func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+			if err := check(input); err != nil {
+				return tools.ErrorResult(err)
+			}
+			return run(ctx, input)
+		}
+		if stream != nil {
+			def.Stream = func(ctx context.Context, input json.RawMessage, output io.Writer) tools.ToolResult {
+				if err := check(input); err != nil {
+					return tools.ErrorResult(err)
+				}
+				return stream(ctx, input, output)
+			}
+		}
+		wrapped = append(wrapped, def)
+	}
+	return wrapped
+}
+
+// runsPrograms reports whether a tool executes programs; tools that declare
+// no permissions are assumed to, as for server users
+func runsPrograms(def tools.ToolDefinition) bool {
+	return len(def.Permissions) == 0 || slices.Contains(def.Permissions, tools.PermissionExec)
+}
+
+// sandboxRoots returns the absolute sandbox paths of cfg, nil when there is
+// no sandbox
+func sandboxRoots(cfg *config.Config) ([]string, error) {
+	var roots []string
+	for _, path := range cfg.Sandbox.Paths {
+		root, err := cfg.ResolvePath(path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+// insideRoots reports whether path, after resolving symlinks where possible,
+// is one of roots or below one
+func insideRoots(path string, roots []string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	for _, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"agent/config"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadInstructions(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "AGENTS.md"), []byte("Use testify for tests.\n"), 0644))
+	cfg := config.Default()
+	cfg.ProjectDir = root
+
+	t.Run("没有指令文件", func(t *testing.T) {
+		instructions, err := loadInstructions(cfg)
+		require.NoError(t, err)
+		assert.Empty(t, instructions)
+	})
+
+	t.Run("指令作为系统消息发送", func(t *testing.T) {
+		cfg.Instructions = []string{"AGENTS.md"}
+		instructions, err := loadInstructions(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "Instructions from AGENTS.md:\n\nUse testify for tests.", instructions)
+
+		provider := &mockProvider{responses: []*Response{{Content: "ok"}}}
+		agent := NewAgent(provider, nil, nil)
+		agent.instructions = instructions
+		require.NoError(t, agent.runTurn(context.Background(), "hi"))
+
+		require.Len(t, provider.calls[0], 2)
+		assert.Equal(t, "system", provider.calls[0][0].Role)
+		assert.Len(t, agent.conversation, 2, "系统消息不写入对话")
+	})
+
+	t.Run("指令文件不存在", func(t *testing.T) {
+		cfg.Instructions = []string{"MISSING.md"}
+		_, err := loadInstructions(cfg)
+		assert.Error(t, err)
+	})
+}
+
+func TestSandboxTools(t *testing.T) {
+	root := t.TempDir()
+	inside := filepath.Join(root, "pkg")
+	require.NoError(t, os.MkdirAll(inside, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(inside, "a.txt"), []byte("inside"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret.txt"), []byte("outside"), 0644))
+
+	chdir(t, root)
+	cfg := config.Default()
+	cfg.ProjectDir = root
+	cfg.Sandbox.Paths = []string{"pkg"}
+	defs, err := enabledTools(cfg, nil)
+	require.NoError(t, err)
+	readFile := defs[0]
+	require.Equal(t, "read_file", readFile.Name)
+
+	result, err := readFile.Function(context.Background(), []byte(`{"path":"pkg/a.txt"}`)).Output()
+	require.NoError(t, err)
+	assert.Equal(t, "inside", result)
+
+	_, err = readFile.Function(context.Background(), []byte(`{"path":"pkg/../secret.txt"}`)).Output()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "outside the sandbox")
+
+	assert.False(t, insideRoots(root+"-other/x", []string{root}), "同名前缀的目录不算在内")
+
+	t.Run("不提供能执行程序的工具", func(t *testing.T) {
+		names := toolNames(defs)
+		assert.NotContains(t, names, "shell")
+		assert.Contains(t, names, "grep")
+	})
+
+	t.Run("没有路径时按整个工作区检查", func(t *testing.T) {
+		grep := defs[slices.IndexFunc(defs, func(def tools.ToolDefinition) bool { return def.Name == "grep" })]
+		_, err := grep.Function(context.Background(), []byte(`{"pattern":"outside"}`)).Output()
+		assert.ErrorContains(t, err, "outside the sandbox")
+		result, err := grep.Function(context.Background(), []byte(`{"pattern":"inside","path":"pkg"}`)).Output()
+		require.NoError(t, err)
+		assert.Contains(t, result, "a.txt")
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/prompts"
+)
+
+// defaultPromptStore serves templates from the prompts section of the config
+// and from <name>.md files under the config directory
+func defaultPromptStore(cfg *config.Config) (*prompts.Store, error) {
+	dir, err := agentConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &prompts.Store{Dir: filepath.Join(dir, "prompts"), Inline: cfg.Prompts}, nil
+}
+
+// renderPromptTemplate fills the named template from key=value args; any
+// other args are appended to the prompt as extra instructions
+func renderPromptTemplate(store *prompts.Store, name string, args []string) (string, error) {
+	tmpl, err := store.Load(name)
+	if err != nil {
+		return "", err
+	}
+	values, rest := prompts.ParseArgs(args)
+	text, err := tmpl.Render(values)
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+	if len(rest) > 0 {
+		text += "\n\n" + strings.Join(rest, " ")
+	}
+	return text, nil
+}
+
+// runPromptCommand lists templates, or renders one and queues it as the next message
+func runPromptCommand(a *Agent, args []string) error {
+	store, err := defaultPromptStore(a.config)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		templates, err := store.List()
+		if err != nil {
+			return err
+		}
+		if len(templates) == 0 {
+			fmt.Println(i18n.Sprintf("No prompt templates; add <name>.md files to %s", store.Dir))
+			return nil
+		}
+		for _, t := range templates {
+			placeholders := ""
+			for _, p := range t.Placeholders() {
+				placeholders += " " + p + "=…"
+			}
+			fmt.Printf("  %-20s %s\n", t.Name+placeholders, truncate(t.Description(), 60))
+		}
+		return nil
+	}
+
+	text, err := renderPromptTemplate(store, args[0], args[1:])
+	if err != nil {
+		return err
+	}
+	a.nextMessage = text
+	return nil
+}
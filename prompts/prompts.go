@@ -0,0 +1,119 @@
+package prompts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPattern 匹配 {{name}} 形式的占位符
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_-]+)\s*\}\}`)
+
+// Template 是一个具名的提示词模板
+type Template struct {
+	Name string
+	Body string
+}
+
+// Description 返回模板正文的第一行，用于列表展示
+func (t *Template) Description() string {
+	line, _, _ := strings.Cut(strings.TrimSpace(t.Body), "\n")
+	return strings.TrimSpace(line)
+}
+
+// Placeholders 返回模板中的占位符名称，按首次出现的顺序去重
+func (t *Template) Placeholders() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(t.Body, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render 用 values 填充占位符，缺少任何值时返回错误
+func (t *Template) Render(values map[string]string) (string, error) {
+	missing := []string{}
+	for _, name := range t.Placeholders() {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %s needs values for: %s", t.Name, strings.Join(missing, ", "))
+	}
+	return placeholderPattern.ReplaceAllStringFunc(t.Body, func(s string) string {
+		return values[placeholderPattern.FindStringSubmatch(s)[1]]
+	}), nil
+}
+
+// ParseArgs 把 key=value 形式的参数解析为占位符取值，其余参数原样返回
+func ParseArgs(args []string) (map[string]string, []string) {
+	values := map[string]string{}
+	rest := []string{}
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok && key != "" {
+			values[key] = value
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return values, rest
+}
+
+// Store 从目录中加载模板，每个 <name>.md 文件是一个模板；Inline 中的模板
+// （来自配置文件）优先于同名文件
+type Store struct {
+	Dir    string
+	Inline map[string]string
+}
+
+// Load 按名称读取模板
+func (s *Store) Load(name string) (*Template, error) {
+	if body, ok := s.Inline[name]; ok {
+		return &Template{Name: name, Body: body}, nil
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, name+".md"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("template %s not found in %s", name, s.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Template{Name: name, Body: string(data)}, nil
+}
+
+// List 返回全部模板，按名称排序；目录不存在时只返回内联模板
+func (s *Store) List() ([]*Template, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	templates := []*Template{}
+	for name, body := range s.Inline {
+		templates = append(templates, &Template{Name: name, Body: body})
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".md")
+		if _, ok := s.Inline[name]; ok || entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		t, err := s.Load(name)
+		if err != nil {
+			continue
+		}
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
@@ -0,0 +1,73 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	tmpl := &Template{Name: "review-pr", Body: "Review the pull request {{url}}.\nFocus on {{ focus }} and {{url}} only."}
+
+	t.Run("占位符去重且保持顺序", func(t *testing.T) {
+		assert.Equal(t, []string{"url", "focus"}, tmpl.Placeholders())
+		assert.Equal(t, "Review the pull request {{url}}.", tmpl.Description())
+	})
+
+	t.Run("填充全部占位符", func(t *testing.T) {
+		out, err := tmpl.Render(map[string]string{"url": "https://example.com/pr/1", "focus": "tests"})
+		require.NoError(t, err)
+		assert.Equal(t, "Review the pull request https://example.com/pr/1.\nFocus on tests and https://example.com/pr/1 only.", out)
+	})
+
+	t.Run("缺少取值时报错", func(t *testing.T) {
+		_, err := tmpl.Render(map[string]string{"url": "x"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "focus")
+	})
+}
+
+func TestParseArgs(t *testing.T) {
+	values, rest := ParseArgs([]string{"url=https://x/y?a=b", "please", "=odd", "focus="})
+	assert.Equal(t, map[string]string{"url": "https://x/y?a=b", "focus": ""}, values)
+	assert.Equal(t, []string{"please", "=odd"}, rest)
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := &Store{Dir: dir}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fix-tests.md"), []byte("Fix the failing tests in {{pkg}}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644))
+
+	templates, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, "fix-tests", templates[0].Name)
+
+	_, err = store.Load("missing")
+	assert.Error(t, err)
+	_, err = store.Load("../secrets")
+	assert.Error(t, err)
+
+	empty, err := (&Store{Dir: filepath.Join(dir, "none")}).List()
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestStoreInline(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "review.md"), []byte("from file"), 0644))
+	store := &Store{Dir: dir, Inline: map[string]string{"review": "from config", "fix": "fix {{pkg}}"}}
+
+	tmpl, err := store.Load("review")
+	require.NoError(t, err)
+	assert.Equal(t, "from config", tmpl.Body, "配置中的模板优先")
+
+	templates, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, templates, 2)
+	assert.Equal(t, "fix", templates[0].Name)
+}
@@ -5,54 +5,15 @@ import (
 	"os"
 	"testing"
 
-	"agent/tools"
-
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestAnthropicProvider(t *testing.T) {
-	t.Skip("需要 ANTHROPIC_API_KEY 环境变量")
-
-	provider := NewAnthropicProvider()
-	require.NotNil(t, provider)
-
-	// 测试基本对话
-	conversation := []Message{
-		{Role: "user", Content: "Hello"},
-	}
-
-	tools := []tools.ToolDefinition{tools.ReadFileDefinition}
-
-	response, err := provider.RunInference(context.Background(), conversation, tools)
-	assert.NoError(t, err)
-	assert.NotNil(t, response)
-	t.Logf("Anthropic response: %+v", response)
-}
-
-func TestOpenAIProvider(t *testing.T) {
-	t.Skip("需要 OPENAI_API_KEY 环境变量")
-
-	provider := NewOpenAIProvider("test-key")
-	require.NotNil(t, provider)
-
-	// 测试基本对话
-	conversation := []Message{
-		{Role: "user", Content: "Hello"},
-	}
-
-	tools := []tools.ToolDefinition{tools.ReadFileDefinition}
-
-	response, err := provider.RunInference(context.Background(), conversation, tools)
-	assert.NoError(t, err)
-	assert.NotNil(t, response)
-	t.Logf("OpenAI response: %+v", response)
-}
-
 func TestReadFileTool(t *testing.T) {
 	// 创建一个测试文件
 	testContent := "Hello, World!"
-	testFile := "/tmp/test_file.txt"
+	chdir(t, t.TempDir())
+	testFile := "test_file.txt"
 
 	err := os.WriteFile(testFile, []byte(testContent), 0644)
 	require.NoError(t, err)
@@ -63,8 +24,8 @@ func TestReadFileTool(t *testing.T) {
 	}()
 
 	// 测试 ReadFile 工具
-	input := `{"path": "/tmp/test_file.txt"}`
-	result, err := tools.ReadFile([]byte(input))
+	input := `{"path": "test_file.txt"}`
+	result, err := currentWorkspace.ReadFile(context.Background(), []byte(input))
 
 	assert.NoError(t, err)
 	assert.Equal(t, testContent, result)
@@ -72,8 +33,8 @@ func TestReadFileTool(t *testing.T) {
 
 func TestReadFileToolError(t *testing.T) {
 	// 测试读取不存在的文件
-	input := `{"path": "/nonexistent/file.txt"}`
-	result, err := tools.ReadFile([]byte(input))
+	input := `{"path": "nonexistent/file.txt"}`
+	result, err := currentWorkspace.ReadFile(context.Background(), []byte(input))
 
 	assert.Error(t, err)
 	assert.Empty(t, result)
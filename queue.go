@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Statuses of a queued task before its run is reported; a finished task
+// takes the status of its report: success, error or budget_exceeded
+const (
+	taskQueued  = "queued"
+	taskRunning = "running"
+)
+
+// queuedTask is a prompt lined up with `agent queue add` to run unattended
+// with `agent queue run`
+type queuedTask struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	// Workspace is the directory the task was queued in; it runs there,
+	// with the project config found from there
+	Workspace    string  `json:"workspace"`
+	BudgetTokens int64   `json:"budget_tokens,omitempty"`
+	BudgetUSD    float64 `json:"budget_usd,omitempty"`
+	Status       string  `json:"status"`
+
+	AddedAt   time.Time  `json:"added_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// Report summarizes the run once the task has finished
+	Report *ciSummary `json:"report,omitempty"`
+}
+
+// queueStore keeps the queued tasks as JSON files, each with the log of its
+// run beside it
+type queueStore struct {
+	Dir string
+}
+
+func defaultQueueStore() (*queueStore, error) {
+	dir, err := agentConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &queueStore{Dir: filepath.Join(dir, "queue")}, nil
+}
+
+func (q *queueStore) path(id string) string {
+	return filepath.Join(q.Dir, id+".json")
+}
+
+// logPath is where the run of a task is logged
+func (q *queueStore) logPath(id string) string {
+	return filepath.Join(q.Dir, id+".log")
+}
+
+// Save writes the task to disk
+func (q *queueStore) Save(task *queuedTask) error {
+	if err := os.MkdirAll(q.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	data, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write atomically so a crash never leaves a half-written task
+	tmp := q.path(task.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save task %s: %w", task.ID, err)
+	}
+	return os.Rename(tmp, q.path(task.ID))
+}
+
+// Load reads a task by ID
+func (q *queueStore) Load(id string) (*queuedTask, error) {
+	data, err := os.ReadFile(q.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("task %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var task queuedTask
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("failed to parse task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+// Delete removes a task and the log of its run
+func (q *queueStore) Delete(id string) error {
+	if err := os.Remove(q.path(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("task %s not found", id)
+		}
+		return err
+	}
+	if err := os.Remove(q.logPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns the tasks in the order they were queued
+func (q *queueStore) List() ([]*queuedTask, error) {
+	entries, err := os.ReadDir(q.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tasks := []*queuedTask{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		task, err := q.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].AddedAt.Equal(tasks[j].AddedAt) {
+			return tasks[i].AddedAt.Before(tasks[j].AddedAt)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks, nil
+}
+
+// taskRunner runs a task's prompt to completion, logging its events to log
+type taskRunner func(ctx context.Context, task *queuedTask, log io.Writer) (ciSummary, error)
+
+// runQueue runs the queued tasks one after another, oldest first, until none
+// is left; tasks queued in the meantime run too. A failed task does not stop
+// the others. When ctx is cancelled the interrupted task goes back to the
+// queue. It returns the tasks it ran.
+func runQueue(ctx context.Context, store *queueStore, run taskRunner, out io.Writer) ([]*queuedTask, error) {
+	var done []*queuedTask
+	for {
+		task, err := nextQueuedTask(store)
+		if err != nil || task == nil {
+			return done, err
+		}
+		now := time.Now()
+		task.Status, task.StartedAt = taskRunning, &now
+		if err := store.Save(task); err != nil {
+			return done, err
+		}
+		fmt.Fprintf(out, "Running %s: %s\n", task.ID, truncate(firstLine(task.Prompt), 60))
+
+		log, err := os.Create(store.logPath(task.ID))
+		if err != nil {
+			return done, err
+		}
+		summary, runErr := run(ctx, task, log)
+		log.Close()
+		if ctx.Err() != nil {
+			task.Status, task.StartedAt = taskQueued, nil
+			if err := store.Save(task); err != nil {
+				return done, err
+			}
+			return done, ctx.Err()
+		}
+		if summary.Status == "" {
+			// the task could not start, e.g. its workspace is gone
+			summary.Status, summary.Error = "error", runErr.Error()
+		}
+		task.Status, task.Report = summary.Status, &summary
+		if err := store.Save(task); err != nil {
+			return done, err
+		}
+		fmt.Fprintf(out, "%s: %s\n", task.ID, taskOutcome(task))
+		done = append(done, task)
+	}
+}
+
+// nextQueuedTask is the oldest task waiting to run, or nil
+func nextQueuedTask(store *queueStore) (*queuedTask, error) {
+	tasks, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.Status == taskQueued {
+			return task, nil
+		}
+	}
+	return nil, nil
+}
+
+// taskOutcome describes how a task's run ended, for the report
+func taskOutcome(task *queuedTask) string {
+	r := task.Report
+	if r == nil {
+		return task.Status
+	}
+	outcome := fmt.Sprintf("%s in %s, $%.4f, %d files changed", r.Status, formatMS(r.DurationMS), r.CostUSD, len(r.FilesChanged))
+	if r.Error != "" {
+		outcome += ": " + r.Error
+	}
+	return outcome
+}
+
+// writeQueue prints the tasks as a table, one row per task
+func writeQueue(w io.Writer, tasks []*queuedTask) error {
+	if len(tasks) == 0 {
+		fmt.Fprintln(w, "No queued tasks; add one with agent queue add \"<task>\"")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tADDED\tDURATION\tCOST\tFILES\tTASK")
+	for _, task := range tasks {
+		duration, cost, files := "-", "-", "-"
+		if r := task.Report; r != nil {
+			duration, cost, files = formatMS(r.DurationMS), fmt.Sprintf("$%.4f", r.CostUSD), fmt.Sprint(len(r.FilesChanged))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", task.ID, task.Status, task.AddedAt.Format("2006-01-02 15:04"),
+			duration, cost, files, truncate(firstLine(task.Prompt), 50))
+	}
+	return tw.Flush()
+}
+
+// writeTask prints the details of a task and where its run was logged
+func writeTask(w io.Writer, store *queueStore, task *queuedTask) {
+	fmt.Fprintf(w, "Task:      %s\n", task.Prompt)
+	fmt.Fprintf(w, "Workspace: %s\n", task.Workspace)
+	fmt.Fprintf(w, "Status:    %s\n", task.Status)
+	if task.BudgetTokens > 0 {
+		fmt.Fprintf(w, "Budget:    %d tokens\n", task.BudgetTokens)
+	}
+	if task.BudgetUSD > 0 {
+		fmt.Fprintf(w, "Budget:    $%.2f\n", task.BudgetUSD)
+	}
+	r := task.Report
+	if r == nil {
+		return
+	}
+	if r.Session != "" {
+		fmt.Fprintf(w, "Session:   %s\n", r.Session)
+	}
+	fmt.Fprintf(w, "Duration:  %s\n", formatMS(r.DurationMS))
+	fmt.Fprintf(w, "Usage:     %d input, %d output tokens, $%.4f\n", r.Usage.InputTokens, r.Usage.OutputTokens, r.CostUSD)
+	fmt.Fprintf(w, "Tools:     %d calls, %d failed\n", r.ToolCalls, r.ToolErrors)
+	if len(r.FilesChanged) > 0 {
+		fmt.Fprintf(w, "Files:     %s\n", strings.Join(r.FilesChanged, ", "))
+	}
+	if r.Error != "" {
+		fmt.Fprintf(w, "Error:     %s\n", r.Error)
+	}
+	if r.Result != "" {
+		fmt.Fprintf(w, "Result:    %s\n", r.Result)
+	}
+	fmt.Fprintf(w, "Log:       %s\n", store.logPath(task.ID))
+}
+
+// runQueuedTask runs a task in its workspace with the config found there, in
+// a session of its own and within its budget, the way `agent run --ci` does
+func runQueuedTask(global *globalOptions, defaultBudget Budget) taskRunner {
+	return func(ctx context.Context, task *queuedTask, log io.Writer) (ciSummary, error) {
+		if _, err := changeWorkspace(task.Workspace); err != nil {
+			return ciSummary{}, err
+		}
+		cfg, err := global.resolveConfig(global.profile)
+		if err != nil {
+			return ciSummary{}, err
+		}
+		agent := NewAgent(nil, nil, nil)
+		agent.logger = global.logger(log, slog.LevelWarn)
+		budget := Budget{MaxTokens: task.BudgetTokens, MaxCost: task.BudgetUSD}
+		if budget.MaxTokens == 0 && budget.MaxCost == 0 {
+			budget = defaultBudget
+		}
+		if budget.MaxTokens > 0 || budget.MaxCost > 0 {
+			budget.Strict = true
+			agent.budget = &budget
+		}
+		if _, err := agent.applyConfig(cfg); err != nil {
+			return ciSummary{}, err
+		}
+		if store, err := DefaultSessionStore(); err == nil {
+			agent.store = store
+		}
+		if audit, err := defaultAuditLog(cfg); err == nil {
+			agent.audit = audit
+		}
+		return runSummarized(ctx, agent, task.Prompt, &ciLog{out: log, plain: true})
+	}
+}
+
+func newQueueCommand(global *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "List the tasks queued to run unattended, one after another",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := defaultQueueStore()
+			if err != nil {
+				return err
+			}
+			tasks, err := store.List()
+			if err != nil {
+				return err
+			}
+			return writeQueue(cmd.OutOrStdout(), tasks)
+		},
+	}
+
+	var budgetTokens int64
+	var budgetUSD float64
+	add := &cobra.Command{
+		Use:   "add <task>",
+		Short: "Queue a task to run in the current workspace; it is read from stdin when omitted or \"-\"",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompt := strings.Join(args, " ")
+			if prompt == "" || prompt == "-" {
+				data, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				prompt = string(data)
+			}
+			prompt = strings.TrimSpace(prompt)
+			if prompt == "" {
+				return fmt.Errorf("no task given")
+			}
+			workspace, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			store, err := defaultQueueStore()
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			task := &queuedTask{ID: newSessionID(now), Prompt: prompt, Workspace: workspace,
+				BudgetTokens: budgetTokens, BudgetUSD: budgetUSD, Status: taskQueued, AddedAt: now}
+			if err := store.Save(task); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Queued %s\n", task.ID)
+			return nil
+		},
+	}
+	add.Flags().Int64Var(&budgetTokens, "budget-tokens", 0, "maximum tokens to spend on the task (0 = the default of queue run)")
+	add.Flags().Float64Var(&budgetUSD, "budget-usd", 0, "maximum estimated cost in USD for the task (0 = the default of queue run)")
+
+	var defaultTokens int64
+	var defaultUSD float64
+	run := &cobra.Command{
+		Use:   "run",
+		Short: "Run the queued tasks one after another until none is left, each in its own session",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := defaultQueueStore()
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			done, err := runQueue(ctx, store, runQueuedTask(global, Budget{MaxTokens: defaultTokens, MaxCost: defaultUSD}), cmd.OutOrStdout())
+			if errors.Is(err, context.Canceled) {
+				fmt.Fprintln(cmd.OutOrStdout(), "Interrupted; the unfinished task is queued again")
+				err = nil
+			}
+			if len(done) > 0 {
+				fmt.Fprintln(cmd.OutOrStdout())
+				if writeErr := writeQueue(cmd.OutOrStdout(), done); writeErr != nil && err == nil {
+					err = writeErr
+				}
+			}
+			return err
+		},
+	}
+	run.Flags().Int64Var(&defaultTokens, "budget-tokens", 0, "maximum tokens per task without a budget of its own (0 = unlimited)")
+	run.Flags().Float64Var(&defaultUSD, "budget-usd", 0, "maximum estimated cost in USD per task without a budget of its own (0 = unlimited)")
+
+	cmd.AddCommand(add, run,
+		&cobra.Command{
+			Use:   "show <id>",
+			Short: "Show a task's report and where its run was logged",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				store, err := defaultQueueStore()
+				if err != nil {
+					return err
+				}
+				task, err := store.Load(args[0])
+				if err != nil {
+					return err
+				}
+				writeTask(cmd.OutOrStdout(), store, task)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "rm <id>...",
+			Short: "Remove tasks from the queue, with their reports",
+			Args:  cobra.MinimumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				store, err := defaultQueueStore()
+				if err != nil {
+					return err
+				}
+				for _, id := range args {
+					if err := store.Delete(id); err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Removed %s\n", id)
+				}
+				return nil
+			},
+		},
+	)
+	return cmd
+}
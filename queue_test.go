@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queueTask(t *testing.T, store *queueStore, id, prompt string, added time.Time) *queuedTask {
+	t.Helper()
+	task := &queuedTask{ID: id, Prompt: prompt, Workspace: t.TempDir(), Status: taskQueued, AddedAt: added}
+	require.NoError(t, store.Save(task))
+	return task
+}
+
+func TestQueueStore(t *testing.T) {
+	store := &queueStore{Dir: t.TempDir()}
+	tasks, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+
+	now := time.Now()
+	queueTask(t, store, "b", "second", now.Add(time.Minute))
+	queueTask(t, store, "a", "first", now)
+	tasks, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "first", tasks[0].Prompt, "按加入队列的顺序排列")
+
+	require.NoError(t, os.WriteFile(store.logPath("a"), []byte("log"), 0600))
+	require.NoError(t, store.Delete("a"))
+	_, err = os.Stat(store.logPath("a"))
+	assert.True(t, os.IsNotExist(err), "删除任务时一并删除日志")
+	assert.ErrorContains(t, store.Delete("a"), "not found")
+	_, err = store.Load("a")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestRunQueue(t *testing.T) {
+	t.Run("依次运行任务并记录报告", func(t *testing.T) {
+		store := &queueStore{Dir: t.TempDir()}
+		now := time.Now()
+		queueTask(t, store, "1", "模块名是什么？", now)
+		queueTask(t, store, "2", "workspace gone", now.Add(time.Second))
+		done := &queuedTask{ID: "0", Prompt: "already done", Status: "success", AddedAt: now.Add(-time.Hour)}
+		require.NoError(t, store.Save(done))
+
+		prompts := []string{}
+		run := func(ctx context.Context, task *queuedTask, log io.Writer) (ciSummary, error) {
+			prompts = append(prompts, task.Prompt)
+			running, err := store.Load(task.ID)
+			require.NoError(t, err)
+			assert.Equal(t, taskRunning, running.Status)
+			if task.ID == "2" {
+				// 运行中加入的任务也会运行
+				queueTask(t, store, "3", "模块名是什么？", now.Add(time.Minute))
+				return ciSummary{}, errors.New("workspace gone")
+			}
+			return runSummarized(ctx, oneShotAgent(), task.Prompt, &ciLog{out: log, plain: true})
+		}
+		var out bytes.Buffer
+		ran, err := runQueue(context.Background(), store, run, &out)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"模块名是什么？", "workspace gone", "模块名是什么？"}, prompts)
+		require.Len(t, ran, 3)
+
+		first, err := store.Load("1")
+		require.NoError(t, err)
+		assert.Equal(t, "success", first.Status)
+		require.NotNil(t, first.Report)
+		assert.Equal(t, "模块名是 agent", first.Report.Result)
+		assert.NotNil(t, first.StartedAt)
+		log, err := os.ReadFile(store.logPath("1"))
+		require.NoError(t, err)
+		assert.Contains(t, string(log), "== Tool: read_file go.mod\n")
+		assert.NotContains(t, string(log), "::group::")
+
+		failed, err := store.Load("2")
+		require.NoError(t, err)
+		assert.Equal(t, "error", failed.Status)
+		assert.Equal(t, "workspace gone", failed.Report.Error)
+		assert.Contains(t, out.String(), "2: error in ")
+
+		var list bytes.Buffer
+		tasks, err := store.List()
+		require.NoError(t, err)
+		require.NoError(t, writeQueue(&list, tasks))
+		assert.Contains(t, list.String(), "already done")
+	})
+
+	t.Run("中断时任务回到队列", func(t *testing.T) {
+		store := &queueStore{Dir: t.TempDir()}
+		queueTask(t, store, "1", "long", time.Now())
+		ctx, cancel := context.WithCancel(context.Background())
+		run := func(ctx context.Context, task *queuedTask, log io.Writer) (ciSummary, error) {
+			cancel()
+			return ciSummary{}, ctx.Err()
+		}
+		ran, err := runQueue(ctx, store, run, io.Discard)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, ran)
+		task, err := store.Load("1")
+		require.NoError(t, err)
+		assert.Equal(t, taskQueued, task.Status)
+		assert.Nil(t, task.StartedAt)
+	})
+
+	t.Run("队列为空", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeQueue(&out, nil))
+		assert.Contains(t, out.String(), "No queued tasks")
+	})
+}
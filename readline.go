@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"agent/i18n"
+	"agent/theme"
+
+	"github.com/chzyer/readline"
+)
+
+// inputPrompt and continuationPrompt are functions so they follow the active theme
+func inputPrompt() string { return theme.User(i18n.T("You")) + ": " }
+
+func continuationPrompt() string { return theme.Muted("...") + "  " }
+
+// newReadlineInput returns a line reader with cursor movement, Emacs key
+// bindings, Ctrl-R reverse search and history that persists across sessions.
+// Ctrl-C calls onInterrupt instead of ending input. Multi-line messages are
+// supported as described in multilineInput. It returns nil when stdin
+// is not a terminal, in which case callers fall back to plain line scanning.
+// Tab completes @mentions of workspace files when complete is set.
+func newReadlineInput(onInterrupt func(), complete readline.AutoCompleter) (func() (string, bool), func(), error) {
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, nil, nil
+	}
+
+	historyFile := ""
+	if dir, err := agentConfigDir(); err == nil {
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			historyFile = filepath.Join(dir, "history")
+		}
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:            inputPrompt(),
+		HistoryFile:       historyFile,
+		HistorySearchFold: true,
+		InterruptPrompt:   "^C",
+		AutoComplete:      complete,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readLine := func() (string, bool) {
+		for {
+			line, err := rl.Readline()
+			if errors.Is(err, readline.ErrInterrupt) {
+				onInterrupt()
+				continue
+			}
+			if err != nil {
+				// io.EOF (Ctrl-D) or a closed terminal ends input
+				return "", false
+			}
+			return line, true
+		}
+	}
+	setContinuation := func(more bool) {
+		if more {
+			rl.SetPrompt(continuationPrompt())
+		} else {
+			rl.SetPrompt(inputPrompt())
+		}
+	}
+	return multilineInput(readLine, setContinuation), func() { rl.Close() }, nil
+}
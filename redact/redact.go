@@ -0,0 +1,30 @@
+package redact
+
+import "regexp"
+
+// rule 描述一类敏感信息；正则的第一个分组（如果有）是需要保留的前缀，例如变量名
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var rules = []rule{
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"anthropic-key", regexp.MustCompile(`sk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"openai-key", regexp.MustCompile(`sk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{20,}`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)(aws_secret_access_key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40}`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"bearer-token", regexp.MustCompile(`(?i)(authorization:\s*bearer\s+)[A-Za-z0-9._~+/=-]{16,}`)},
+	// 形如 .env 文件中 DB_PASSWORD=... 的赋值，只匹配大写变量名，避免误伤普通代码
+	{"secret", regexp.MustCompile(`\b([A-Z0-9_]*(?:API_KEY|SECRET|TOKEN|PASSWORD)[A-Z0-9_]*["']?\s*[=:]\s*["']?)[^\s"'$]{8,}`)},
+}
+
+// String 将文本中的 API 密钥、AWS 凭证、私钥等敏感信息替换为 [REDACTED:类型] 占位符
+func String(s string) string {
+	for _, r := range rules {
+		s = r.re.ReplaceAllString(s, "${1}[REDACTED:"+r.name+"]")
+	}
+	return s
+}
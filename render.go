@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"agent/i18n"
+	"agent/theme"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/chzyer/readline"
+)
+
+// markdownRenderer renders assistant Markdown (headings, lists, tables, code
+// fences) for the terminal. A nil renderer prints text unchanged.
+type markdownRenderer struct {
+	renderer *glamour.TermRenderer
+}
+
+// newMarkdownRenderer returns nil when colors are disabled (NO_COLOR, or stdout
+// is not a terminal), so piped output stays plain
+func newMarkdownRenderer() (*markdownRenderer, error) {
+	if !theme.Enabled() {
+		return nil, nil
+	}
+	width := readline.GetScreenWidth()
+	if width <= 0 {
+		width = 80
+	}
+	renderer, err := glamour.NewTermRenderer(
+		markdownStyleOption(theme.Current().Markdown),
+		glamour.WithWordWrap(width-4),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &markdownRenderer{renderer: renderer}, nil
+}
+
+// markdownStyleOption selects a glamour style; "auto" picks light or dark
+// from the terminal background
+func markdownStyleOption(style string) glamour.TermRendererOption {
+	if style == "auto" {
+		return glamour.WithAutoStyle()
+	}
+	return glamour.WithStandardStyle(style)
+}
+
+// Render returns the rendered text, falling back to the raw text on failure
+func (m *markdownRenderer) Render(text string) string {
+	if m == nil {
+		return text
+	}
+	out, err := m.renderer.Render(text)
+	if err != nil {
+		return text
+	}
+	return strings.Trim(out, "\n")
+}
+
+// printAssistant displays an assistant reply, rendered as Markdown when enabled
+func (a *Agent) printAssistant(content string, at time.Time) {
+	label := theme.Assistant(i18n.T("Assistant")) + a.timestamp(at)
+	if a.markdown == nil {
+		fmt.Printf("%s: %s\n", label, content)
+		return
+	}
+	fmt.Printf("%s:\n%s\n", label, a.markdown.Render(content))
+}
+
+// timestamp is the time shown after the label of a message when timestamps
+// are on, e.g. " · 2024-05-01 09:30:00"
+func (a *Agent) timestamp(at time.Time) string {
+	if !a.showTimestamps || at.IsZero() {
+		return ""
+	}
+	return theme.Muted(" · " + formatTime(at))
+}
+
+func runTimestampsCommand(a *Agent, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /timestamps [on|off]")
+	}
+	on := !a.showTimestamps
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			on = true
+		case "off":
+			on = false
+		default:
+			return fmt.Errorf("usage: /timestamps [on|off]")
+		}
+	}
+	a.showTimestamps = on
+	state := "off"
+	if on {
+		state = "on"
+	}
+	fmt.Println(i18n.Sprintf("Timestamps %s", i18n.T(state)))
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"agent/pkg/message"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestMarkdownRenderer(t *testing.T) {
+	t.Run("nil渲染器原样输出", func(t *testing.T) {
+		var m *markdownRenderer
+		assert.Equal(t, "**bold**", m.Render("**bold**"))
+	})
+
+	t.Run("渲染Markdown", func(t *testing.T) {
+		renderer, err := glamour.NewTermRenderer(glamour.WithStandardStyle("dark"), glamour.WithWordWrap(80))
+		require.NoError(t, err)
+		m := &markdownRenderer{renderer: renderer}
+
+		out := ansiPattern.ReplaceAllString(m.Render("Some **bold** text and a list:\n\n- one\n- two\n\n```go\nfmt.Println(1)\n```"), "")
+		assert.Contains(t, out, "bold")
+		assert.NotContains(t, out, "**")
+		assert.NotContains(t, out, "```")
+		assert.Contains(t, out, "fmt.Println(1)")
+		assert.Contains(t, out, "• one")
+	})
+}
+
+func TestTimestamps(t *testing.T) {
+	defer func() { timeFormat = "" }()
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+	agent := oneShotAgent()
+
+	t.Run("关闭时不显示时间", func(t *testing.T) {
+		out := captureStdout(func() { agent.printAssistant("完成", at) })
+		assert.Equal(t, "Assistant: 完成\n", ansiPattern.ReplaceAllString(out, ""))
+	})
+
+	t.Run("打开后回复和历史都显示时间", func(t *testing.T) {
+		captureStdout(func() { require.NoError(t, runTimestampsCommand(agent, nil)) })
+		require.True(t, agent.showTimestamps, "不带参数时切换")
+		timeFormat = "15:04"
+		out := captureStdout(func() { agent.printAssistant("完成", at) })
+		assert.Equal(t, "Assistant · 09:30: 完成\n", ansiPattern.ReplaceAllString(out, ""))
+
+		agent.setConversation([]Message{{Role: "user", Content: "你好", Meta: &message.Metadata{Time: at}}})
+		out = captureStdout(func() { require.NoError(t, runHistoryCommand(agent, nil)) })
+		assert.Equal(t, "  1 user      09:30 你好\n", ansiPattern.ReplaceAllString(out, ""))
+	})
+
+	t.Run("on 和 off", func(t *testing.T) {
+		captureStdout(func() { require.NoError(t, runTimestampsCommand(agent, []string{"off"})) })
+		assert.False(t, agent.showTimestamps)
+		assert.Error(t, runTimestampsCommand(agent, []string{"maybe"}))
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+)
+
+func newReplayCommand() *cobra.Command {
+	var step, rerun bool
+	cmd := &cobra.Command{
+		Use:   "replay <session file or ID>",
+		Short: "Re-render a recorded session message by message",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := loadSessionArg(args[0])
+			if err != nil {
+				return err
+			}
+			r := &replayer{
+				out:   cmd.OutOrStdout(),
+				in:    bufio.NewReader(cmd.InOrStdin()),
+				step:  step,
+				rerun: rerun,
+				tools: builtinTools(),
+			}
+			return r.replay(cmd.Context(), session)
+		},
+	}
+	cmd.Flags().BoolVar(&step, "step", true, "wait for Enter between messages")
+	cmd.Flags().BoolVar(&rerun, "rerun", false, "re-execute read-only tool calls and compare their results")
+	return cmd
+}
+
+// loadSessionArg accepts either a path to a session file or a stored session ID
+func loadSessionArg(arg string) (*Session, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return LoadSessionFile(arg)
+	}
+	store, err := DefaultSessionStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Load(arg)
+}
+
+type replayer struct {
+	out   io.Writer
+	in    *bufio.Reader
+	step  bool
+	rerun bool
+	tools []tools.ToolDefinition
+}
+
+func (r *replayer) replay(ctx context.Context, session *Session) error {
+	fmt.Fprintln(r.out, i18n.Sprintf("Session %s (%d messages, created %s)",
+		session.ID, len(session.Messages), formatTime(session.CreatedAt)))
+	if session.ParentID != "" {
+		fmt.Fprintln(r.out, i18n.Sprintf("Forked from %s at message %d", session.ParentID, session.ForkedAt))
+	}
+
+	for i, msg := range session.Messages {
+		if i > 0 && r.step && !r.waitForStep() {
+			return nil
+		}
+		fmt.Fprintf(r.out, "\n[%d/%d] ", i+1, len(session.Messages))
+		r.renderMessage(ctx, msg)
+	}
+	return nil
+}
+
+// waitForStep blocks until the user presses Enter; it reports false when the user quits
+func (r *replayer) waitForStep() bool {
+	fmt.Fprint(r.out, theme.Muted(i18n.T("-- Enter: next, q: quit --")))
+	line, err := r.in.ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	return strings.TrimSpace(line) != "q"
+}
+
+func (r *replayer) renderMessage(ctx context.Context, msg Message) {
+	if msg.ToolCall != nil {
+		fmt.Fprintf(r.out, "%s: %s %s\n", theme.Tool(i18n.T("Tool Call")), msg.ToolCall.Name, string(msg.ToolCall.Input))
+		fmt.Fprintf(r.out, "%s: %s\n", theme.Success(i18n.T("Tool Result")), msg.Content)
+		if r.rerun {
+			r.rerunTool(ctx, msg)
+		}
+		return
+	}
+
+	switch msg.Role {
+	case "user":
+		fmt.Fprintf(r.out, "%s: %s\n", theme.User(i18n.T("You")), msg.Content)
+	default:
+		fmt.Fprintf(r.out, "%s: %s\n", theme.Assistant(i18n.T("Assistant")), msg.Content)
+	}
+}
+
+// rerunTool re-executes a recorded read-only tool call and reports whether the result changed
+func (r *replayer) rerunTool(ctx context.Context, msg Message) {
+	call := msg.ToolCall
+	for _, tool := range r.tools {
+		if tool.Name != call.Name {
+			continue
+		}
+		if !tool.ReadOnly {
+			fmt.Fprintln(r.out, theme.Muted(i18n.Sprintf("Skipped re-execution: %s is not read-only", call.Name)))
+			return
+		}
+		result, err := tool.Function(ctx, call.Input).Output()
+		if err != nil {
+			fmt.Fprintf(r.out, "%s: %s\n", theme.Error(i18n.T("Re-executed: error")), err)
+			return
+		}
+		if toolResultContent(call.Name, result) == msg.Content {
+			fmt.Fprintln(r.out, theme.Success(i18n.T("Re-executed: same result")))
+		} else {
+			fmt.Fprintf(r.out, "%s: %s\n", theme.Error(i18n.T("Re-executed: result differs")), result)
+		}
+		return
+	}
+	fmt.Fprintln(r.out, theme.Muted(i18n.Sprintf("Skipped re-execution: unknown tool %s", call.Name)))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeReplaySession(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	target := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(target, []byte("v1"), 0644))
+
+	input, err := json.Marshal(map[string]string{"path": "notes.txt"})
+	require.NoError(t, err)
+
+	session := NewSession()
+	session.Messages = []Message{
+		{Role: "user", Content: "read my notes"},
+		{Role: "assistant", Content: toolResultContent("read_file", "v1"), ToolCall: &ToolCall{ID: "1", Name: "read_file", Input: input}},
+		{Role: "assistant", Content: "They say v1"},
+	}
+	path := filepath.Join(dir, "session.json")
+	data, err := json.Marshal(session)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path, target
+}
+
+func TestRunReplay(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	path, target := writeReplaySession(t, dir)
+
+	t.Run("一次性输出全部消息", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(newReplayCommand(), strings.NewReader(""), &out, "--step=false", path))
+		assert.Contains(t, out.String(), "[1/3]")
+		assert.Contains(t, out.String(), "read my notes")
+		assert.Contains(t, out.String(), "Tool Call")
+		assert.Contains(t, out.String(), "They say v1")
+		assert.NotContains(t, out.String(), "Re-executed")
+	})
+
+	t.Run("逐步回放并提前退出", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(newReplayCommand(), strings.NewReader("\nq\n"), &out, path))
+		assert.Contains(t, out.String(), "[2/3]")
+		assert.NotContains(t, out.String(), "[3/3]")
+	})
+
+	t.Run("重新执行只读工具", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(newReplayCommand(), strings.NewReader(""), &out, "--step=false", "--rerun", path))
+		assert.Contains(t, out.String(), "same result")
+
+		require.NoError(t, os.WriteFile(target, []byte("v2"), 0644))
+		out.Reset()
+		require.NoError(t, runCommand(newReplayCommand(), strings.NewReader(""), &out, "--step=false", "--rerun", path))
+		assert.Contains(t, out.String(), "result differs")
+	})
+
+	t.Run("缺少参数", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Error(t, runCommand(newReplayCommand(), strings.NewReader(""), &out))
+	})
+}
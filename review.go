@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agent/config"
+	"agent/diff"
+	"agent/github"
+	"agent/gitutil"
+
+	"github.com/spf13/cobra"
+)
+
+const reviewPrompt = `Review the code changes in the diff below as an experienced reviewer would.
+Look for bugs, missing error handling, security problems, race conditions, unclear names and
+missing tests. Do not comment on formatting or on code the diff does not change.
+
+Reply with a JSON array only, without code fences. Each finding is an object with these fields:
+- "file": the path of the file as it appears after "+++ b/" in the diff
+- "line": the line number in the new version of the file
+- "severity": "error" for bugs that must be fixed, "warning" for likely problems, "info" for suggestions
+- "message": what is wrong and why, in one or two sentences
+- "suggestion": how to fix it, optionally with replacement code
+Reply with [] when the changes look good.
+
+%s`
+
+// maxReviewDiff limits how much of the diff is sent to the model for review
+const maxReviewDiff = 64 * 1024
+
+// Review finding severities, from most to least important
+var reviewSeverities = []string{"error", "warning", "info"}
+
+// reviewFinding is one problem the model found in the diff
+type reviewFinding struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Severity   string `json:"severity"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func newReviewCommand(global *globalOptions) *cobra.Command {
+	var staged bool
+	var ref, output string
+	var pr int
+	cmd := &cobra.Command{
+		Use:   "review [--staged | --ref A..B]",
+		Short: "Review a diff with the model and list its findings",
+		Long: "Review the uncommitted changes, the staged changes or a range of commits with the model and list\n" +
+			"its findings by file, line and severity. With --github, post them as a review on a pull request.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != outputText && output != outputJSON {
+				return fmt.Errorf("unknown output format %q", output)
+			}
+			if staged && ref != "" {
+				return fmt.Errorf("--staged and --ref cannot be used together")
+			}
+			repo, err := gitutil.Open(".")
+			if err != nil {
+				return err
+			}
+			unified, err := repo.Run(reviewDiffArgs(staged, ref)...)
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(unified) == "" {
+				fmt.Fprintln(cmd.ErrOrStderr(), "No changes to review.")
+				return nil
+			}
+
+			cfg := global.cfg()
+			var client *github.Client
+			var target github.Repository
+			if pr > 0 {
+				if client, target, err = reviewGitHubClient(cfg.GitHub, repo); err != nil {
+					return err
+				}
+			}
+
+			provider, _, err := newProvider(cfg, global.logger(os.Stderr, slog.LevelWarn))
+			if err != nil {
+				return err
+			}
+			findings, err := reviewDiff(cmd.Context(), provider, unified)
+			if err != nil {
+				return err
+			}
+
+			if output == outputJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(findings); err != nil {
+					return err
+				}
+			} else {
+				printFindings(cmd.OutOrStdout(), findings)
+			}
+			if client == nil {
+				return nil
+			}
+			review, err := client.CreateReview(cmd.Context(), target, pr, githubReview(unified, findings))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Posted the review: %s\n", review.HTMLURL)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&staged, "staged", false, "review the staged changes instead of all uncommitted ones")
+	cmd.Flags().StringVar(&ref, "ref", "", "review a range of commits, e.g. main..HEAD")
+	cmd.Flags().StringVar(&output, "output", outputText, "output format: text or json")
+	cmd.Flags().IntVar(&pr, "github", 0, "post the findings as a review on this pull request number")
+	return cmd
+}
+
+// reviewDiffArgs returns the git command that prints the diff to review
+func reviewDiffArgs(staged bool, ref string) []string {
+	switch {
+	case staged:
+		return []string{"diff", "--cached"}
+	case ref != "":
+		return []string{"diff", ref}
+	}
+	return []string{"diff", "HEAD"}
+}
+
+// reviewGitHubClient returns a client for the GitHub repository of the configured remote
+func reviewGitHubClient(cfg config.GitHub, repo *gitutil.Repo) (*github.Client, github.Repository, error) {
+	token, _ := lookupAPIKey("github", cfg.TokenEnv)
+	if token == "" {
+		return nil, github.Repository{}, fmt.Errorf("--github needs a GitHub token: set GITHUB_TOKEN or run `agent auth login github`")
+	}
+	remote := cfg.Remote
+	if remote == "" {
+		remote = "origin"
+	}
+	remoteURL, err := repo.RemoteURL(remote)
+	if err != nil {
+		return nil, github.Repository{}, err
+	}
+	host, target, err := github.ParseRemote(remoteURL)
+	if err != nil {
+		return nil, github.Repository{}, err
+	}
+	client := &github.Client{BaseURL: cfg.APIURL, Token: token}
+	if client.BaseURL == "" && host != "github.com" {
+		client.BaseURL = "https://" + host + "/api/v3"
+	}
+	return client, target, nil
+}
+
+// reviewDiff asks the model to review the diff and returns its findings sorted by file and line
+func reviewDiff(ctx context.Context, provider AIProvider, unified string) ([]reviewFinding, error) {
+	if len(unified) > maxReviewDiff {
+		unified = unified[:maxReviewDiff] + "\n... (diff truncated)"
+	}
+	response, err := provider.RunInference(ctx, []Message{{Role: "user", Content: fmt.Sprintf(reviewPrompt, unified)}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := parseFindings(response.Content)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// parseFindings extracts the JSON array of findings from a model reply, which
+// may wrap it in code fences or prose
+func parseFindings(text string) ([]reviewFinding, error) {
+	start, end := strings.Index(text, "["), strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the model did not reply with a list of findings: %s", firstLine(strings.TrimSpace(text)))
+	}
+	var findings []reviewFinding
+	if err := json.Unmarshal([]byte(text[start:end+1]), &findings); err != nil {
+		return nil, fmt.Errorf("failed to parse the review findings: %w", err)
+	}
+	valid := []reviewFinding{}
+	for _, f := range findings {
+		if f.File == "" || strings.TrimSpace(f.Message) == "" {
+			continue
+		}
+		f.File = strings.TrimPrefix(f.File, "b/")
+		f.Severity = strings.ToLower(strings.TrimSpace(f.Severity))
+		if !slices.Contains(reviewSeverities, f.Severity) {
+			f.Severity = "info"
+		}
+		valid = append(valid, f)
+	}
+	return valid, nil
+}
+
+// location returns "file:line", or just the file when the finding has no line
+func (f reviewFinding) location() string {
+	if f.Line <= 0 {
+		return f.File
+	}
+	return f.File + ":" + strconv.Itoa(f.Line)
+}
+
+// printFindings lists the findings one per line, followed by their suggestions
+func printFindings(w io.Writer, findings []reviewFinding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No findings.")
+		return
+	}
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+		fmt.Fprintf(w, "%s: [%s] %s\n", f.location(), f.Severity, f.Message)
+		if f.Suggestion != "" {
+			fmt.Fprintf(w, "    suggestion: %s\n", strings.ReplaceAll(strings.TrimSpace(f.Suggestion), "\n", "\n    "))
+		}
+	}
+	summary := []string{}
+	for _, severity := range reviewSeverities {
+		if counts[severity] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	fmt.Fprintf(w, "\n%d findings: %s\n", len(findings), strings.Join(summary, ", "))
+}
+
+// githubReview turns the findings into a review: findings on lines shown in the
+// diff become inline comments, GitHub rejects comments on other lines, so the
+// rest are listed in the review body
+func githubReview(unified string, findings []reviewFinding) github.NewReview {
+	lines := diff.NewLines(unified)
+	review := github.NewReview{Event: "COMMENT"}
+	var other []string
+	for _, f := range findings {
+		body := fmt.Sprintf("**%s**: %s", f.Severity, f.Message)
+		if f.Suggestion != "" {
+			body += "\n\n" + f.Suggestion
+		}
+		if lines[f.File][f.Line] {
+			review.Comments = append(review.Comments, github.ReviewComment{Path: f.File, Line: f.Line, Body: body})
+			continue
+		}
+		other = append(other, fmt.Sprintf("- `%s` %s", f.location(), strings.ReplaceAll(body, "\n", "\n  ")))
+	}
+	switch {
+	case len(findings) == 0:
+		review.Body = "No findings."
+	case len(other) > 0:
+		review.Body = strings.Join(other, "\n")
+	}
+	return review
+}
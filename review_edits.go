@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agent/diff"
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+)
+
+// reviewEdit shows the hunks of an edit_file call one at a time, like
+// `git add -p`, before anything is written. It returns the input to run the
+// tool with, which applies only the accepted hunks, and a note about the
+// rejected ones for the model; rejecting every hunk denies the call. Calls
+// that cannot be previewed (invalid input, a remote workspace) run unreviewed
+// and fail or succeed as they would have.
+func (a *Agent) reviewEdit(call ToolCall) (json.RawMessage, string, error) {
+	workspace := &tools.Workspace{Root: currentWorkspace.Root, MaxWriteBytes: a.config.Limits.MaxWriteBytes}
+	if a.config.Remote.Host != "" {
+		return call.Input, "", nil
+	}
+	edit, err := workspace.ProposeEdit(call.Input)
+	if err != nil {
+		return call.Input, "", nil
+	}
+	hunks := diff.Hunks(edit.Before, edit.After)
+	if len(hunks) == 0 {
+		return call.Input, "", nil
+	}
+
+	fmt.Println(theme.Tool(i18n.Sprintf("Proposed edit to %s (%d hunks)", edit.Name, len(hunks))))
+	accepted := make([]bool, len(hunks))
+	all := ""
+	for i, hunk := range hunks {
+		fmt.Print(diff.Colorize(hunk.Text))
+		answer := all
+		for answer == "" {
+			fmt.Print(i18n.Sprintf("Apply this hunk (%d/%d)? [y,n,a,d]: ", i+1, len(hunks)))
+			line, ok := a.readInput()
+			if !ok {
+				answer = "d"
+				break
+			}
+			switch line = strings.ToLower(strings.TrimSpace(line)); line {
+			case "y", "yes", "n", "no":
+				answer = line[:1]
+			case "a", "d":
+				answer, all = line, line
+			default:
+				fmt.Println(i18n.T("y: apply this hunk, n: skip it, a: apply this and all later hunks, d: skip this and all later hunks"))
+			}
+		}
+		accepted[i] = answer == "y" || answer == "a"
+	}
+
+	var rejected []string
+	for i, ok := range accepted {
+		if !ok {
+			rejected = append(rejected, hunks[i].Text)
+		}
+	}
+	switch len(rejected) {
+	case 0:
+		return call.Input, "", nil
+	case len(hunks):
+		return nil, "", fmt.Errorf("the edit to %s was %w by the user", edit.Name, tools.ErrDenied)
+	}
+	input, err := json.Marshal(tools.EditFileInput{Path: edit.Name, OldStr: edit.Before, NewStr: diff.Apply(edit.Before, edit.After, accepted)})
+	if err != nil {
+		return nil, "", err
+	}
+	note := fmt.Sprintf("The user rejected %d of the %d hunks of this edit; only the others were applied. Rejected hunks:\n\n%s",
+		len(rejected), len(hunks), strings.Join(rejected, "\n"))
+	return input, note, nil
+}
+
+// runReviewEditsCommand shows whether edits are reviewed hunk by hunk, or
+// turns the review on or off
+func runReviewEditsCommand(a *Agent, args []string) error {
+	if len(args) == 0 {
+		state := "off"
+		if a.reviewEdits {
+			state = "on"
+		}
+		fmt.Println(i18n.Sprintf("Edit review is %s", i18n.T(state)))
+		return nil
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /review-edits [on|off]")
+	}
+	switch args[0] {
+	case "on":
+		if a.onEvent != nil {
+			return fmt.Errorf("edit review works in the line REPL only")
+		}
+		a.reviewEdits = true
+	case "off":
+		a.reviewEdits = false
+	default:
+		return fmt.Errorf("usage: /review-edits [on|off]")
+	}
+	fmt.Println(i18n.Sprintf("Edit review %s", i18n.T(args[0])))
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewEdits(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %d\n", i))
+	}
+	original := strings.Join(lines, "")
+	// 一次修改相距较远的两处，得到两个 hunk
+	edit := tools.EditFileInput{Path: "a.txt", OldStr: original, NewStr: strings.Replace(strings.Replace(original, "line 2\n", "second\n", 1), "line 18\n", "eighteenth\n", 1)}
+
+	run := func(t *testing.T, answers ...string) (*mockProvider, string) {
+		chdir(t, t.TempDir())
+		require.NoError(t, os.WriteFile("a.txt", []byte(original), 0644))
+		provider := &mockProvider{responses: []*Response{toolCall(t, "1", "edit_file", edit), {Content: "done"}}}
+		agent := NewAgent(provider, func() (string, bool) {
+			if len(answers) == 0 {
+				return "", false
+			}
+			answer := answers[0]
+			answers = answers[1:]
+			return answer, true
+		}, builtinTools())
+		agent.onEvent = func(AgentEvent) {}
+		agent.reviewEdits = true
+		require.NoError(t, agent.runTurn(context.Background(), "rename the lines"))
+		data, err := os.ReadFile("a.txt")
+		require.NoError(t, err)
+		return provider, string(data)
+	}
+
+	t.Run("只应用接受的 hunk，并告诉模型拒绝了哪些", func(t *testing.T) {
+		provider, content := run(t, "n", "y")
+		assert.Contains(t, content, "line 2\n")
+		assert.Contains(t, content, "eighteenth\n")
+		result := provider.calls[1][len(provider.calls[1])-1]
+		assert.Contains(t, result.Content, "The user rejected 1 of the 2 hunks")
+		assert.Contains(t, result.Content, "+second\n")
+		assert.False(t, result.IsError)
+	})
+
+	t.Run("全部接受", func(t *testing.T) {
+		_, content := run(t, "a")
+		assert.Equal(t, edit.NewStr, content)
+	})
+
+	t.Run("全部拒绝时不写入", func(t *testing.T) {
+		provider, content := run(t, "maybe", "d")
+		assert.Equal(t, original, content)
+		result := provider.calls[1][len(provider.calls[1])-1]
+		assert.True(t, result.IsError)
+		assert.Contains(t, result.Content, "denied by the user")
+	})
+
+	agent := oneShotAgent()
+	require.NoError(t, runReviewEditsCommand(agent, []string{"on"}))
+	assert.True(t, agent.reviewEdits)
+	require.NoError(t, runReviewEditsCommand(agent, []string{"off"}))
+	assert.False(t, agent.reviewEdits)
+	assert.Error(t, runReviewEditsCommand(agent, []string{"maybe"}))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"agent/github"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reviewTestDiff = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+ 
++import "os"
+ func main() {}
+`
+
+func TestReviewDiff(t *testing.T) {
+	provider := &mockProvider{responses: []*Response{{Content: "Here is my review:\n```json\n" + `[
+		{"file": "main.go", "line": 3, "severity": "Warning", "message": "os is not used.", "suggestion": "Remove the import."},
+		{"file": "b/go.mod", "line": 1, "severity": "critical", "message": "Module path changed."},
+		{"file": "main.go", "line": 1, "severity": "info", "message": ""}
+	]` + "\n```"}}}
+
+	findings, err := reviewDiff(context.Background(), provider, reviewTestDiff)
+	require.NoError(t, err)
+	assert.Contains(t, provider.calls[0][0].Content, `+import "os"`)
+	assert.Equal(t, []reviewFinding{
+		{File: "go.mod", Line: 1, Severity: "info", Message: "Module path changed."},
+		{File: "main.go", Line: 3, Severity: "warning", Message: "os is not used.", Suggestion: "Remove the import."},
+	}, findings, "按文件和行排序，未知的严重程度记为 info，没有说明的发现被丢弃")
+
+	var out bytes.Buffer
+	printFindings(&out, findings)
+	assert.Equal(t, "go.mod:1: [info] Module path changed.\nmain.go:3: [warning] os is not used.\n    suggestion: Remove the import.\n\n2 findings: 1 warning, 1 info\n", out.String())
+
+	t.Run("没有发现", func(t *testing.T) {
+		findings, err := parseFindings("[]")
+		require.NoError(t, err)
+		assert.Empty(t, findings)
+		_, err = parseFindings("The changes look good.")
+		assert.Error(t, err)
+	})
+
+	t.Run("GitHub 评审", func(t *testing.T) {
+		review := githubReview(reviewTestDiff, findings)
+		assert.Equal(t, "COMMENT", review.Event)
+		assert.Equal(t, []github.ReviewComment{{Path: "main.go", Line: 3, Body: "**warning**: os is not used.\n\nRemove the import."}}, review.Comments)
+		assert.Equal(t, "- `go.mod:1` **info**: Module path changed.", review.Body, "不在 diff 中的行写入评审正文")
+	})
+}
+
+func TestReviewDiffArgs(t *testing.T) {
+	assert.Equal(t, []string{"diff", "HEAD"}, reviewDiffArgs(false, ""))
+	assert.Equal(t, []string{"diff", "--cached"}, reviewDiffArgs(true, ""))
+	assert.Equal(t, []string{"diff", "main..HEAD"}, reviewDiffArgs(false, "main..HEAD"))
+}
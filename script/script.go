@@ -0,0 +1,388 @@
+// Package script 加载 .agent/tools 目录中的 Starlark 脚本。脚本用内置函数 tool 定义工具，
+// 用 hook 注册在工具调用前后和助手回复之后执行的钩子。脚本文件改变后，下一次 Load 重新加载它们
+package script
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkjson"
+	"go.starlark.net/syntax"
+)
+
+// Ext 是脚本文件的扩展名
+const Ext = ".star"
+
+// 钩子的事件
+const (
+	// BeforeTool 的钩子以 (name, args) 调用，返回字符串时拒绝这次调用，字符串是原因
+	BeforeTool = "before_tool"
+	// AfterTool 的钩子以 (name, args, result) 调用，返回字符串时替换工具的结果
+	AfterTool = "after_tool"
+	// AfterReply 的钩子以 (text) 调用，返回字符串时替换助手的回复
+	AfterReply = "after_reply"
+)
+
+// maxSteps 限制一次执行的计算步数，防止脚本陷入很长的循环
+const maxSteps = 10_000_000
+
+// toolName 与配置中自定义工具名字的规则相同
+var toolName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Env 是脚本执行时可以使用的环境
+type Env struct {
+	// ReadFile 供脚本中的 read_file 读取工作区中的文件，nil 时不能读取
+	ReadFile func(path string) (string, error)
+	// Print 接收脚本中 print 的输出，nil 时丢弃
+	Print func(msg string)
+}
+
+// Tool 是脚本定义的工具
+type Tool struct {
+	Name        string
+	Description string
+	// Properties 是参数的 JSON Schema，Required 是必需的参数
+	Properties map[string]any
+	Required   []string
+	// File 是定义工具的脚本
+	File string
+	fn   starlark.Callable
+}
+
+// Call 以 JSON 对象 input 作为参数调用工具。函数返回字符串时作为结果，
+// None 时结果为空，其他值编码成 JSON
+func (t *Tool) Call(env Env, input json.RawMessage) (string, error) {
+	thread := newThread(t.Name, env)
+	args, err := decode(thread, input)
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid input: %w", t.Name, err)
+	}
+	v, err := starlark.Call(thread, t.fn, starlark.Tuple{args}, nil)
+	if err != nil {
+		return "", scriptError(t.Name, err)
+	}
+	switch v := v.(type) {
+	case starlark.String:
+		return string(v), nil
+	case starlark.NoneType:
+		return "", nil
+	}
+	return encode(thread, v)
+}
+
+// Set 是一次加载得到的工具和钩子，加载后不再改变
+type Set struct {
+	Tools []Tool
+	hooks map[string][]hook
+}
+
+type hook struct {
+	file string
+	fn   starlark.Callable
+}
+
+// Tool 返回名为 name 的工具
+func (s *Set) Tool(name string) (Tool, bool) {
+	if s != nil {
+		for _, t := range s.Tools {
+			if t.Name == name {
+				return t, true
+			}
+		}
+	}
+	return Tool{}, false
+}
+
+// BeforeTool 依次执行 before_tool 钩子，返回第一个拒绝的原因，都允许时返回空字符串
+func (s *Set) BeforeTool(env Env, name string, input json.RawMessage) (string, error) {
+	if s == nil || len(s.hooks[BeforeTool]) == 0 {
+		return "", nil
+	}
+	thread := newThread(BeforeTool, env)
+	args, err := decode(thread, input)
+	if err != nil {
+		return "", fmt.Errorf("%s hook: invalid input: %w", BeforeTool, err)
+	}
+	for _, h := range s.hooks[BeforeTool] {
+		v, err := starlark.Call(thread, h.fn, starlark.Tuple{starlark.String(name), args}, nil)
+		if err != nil {
+			return "", scriptError(BeforeTool+" hook in "+filepath.Base(h.file), err)
+		}
+		if reason, ok := v.(starlark.String); ok {
+			return string(reason), nil
+		}
+	}
+	return "", nil
+}
+
+// AfterTool 依次执行 after_tool 钩子，每个钩子看到前一个钩子替换后的结果
+func (s *Set) AfterTool(env Env, name string, input json.RawMessage, result string) (string, error) {
+	if s == nil || len(s.hooks[AfterTool]) == 0 {
+		return result, nil
+	}
+	thread := newThread(AfterTool, env)
+	args, err := decode(thread, input)
+	if err != nil {
+		return "", fmt.Errorf("%s hook: invalid input: %w", AfterTool, err)
+	}
+	for _, h := range s.hooks[AfterTool] {
+		v, err := starlark.Call(thread, h.fn, starlark.Tuple{starlark.String(name), args, starlark.String(result)}, nil)
+		if err != nil {
+			return "", scriptError(AfterTool+" hook in "+filepath.Base(h.file), err)
+		}
+		if replaced, ok := v.(starlark.String); ok {
+			result = string(replaced)
+		}
+	}
+	return result, nil
+}
+
+// AfterReply 依次执行 after_reply 钩子，每个钩子看到前一个钩子替换后的回复
+func (s *Set) AfterReply(env Env, text string) (string, error) {
+	if s == nil {
+		return text, nil
+	}
+	thread := newThread(AfterReply, env)
+	for _, h := range s.hooks[AfterReply] {
+		v, err := starlark.Call(thread, h.fn, starlark.Tuple{starlark.String(text)}, nil)
+		if err != nil {
+			return "", scriptError(AfterReply+" hook in "+filepath.Base(h.file), err)
+		}
+		if replaced, ok := v.(starlark.String); ok {
+			text = string(replaced)
+		}
+	}
+	return text, nil
+}
+
+// Loader 加载一个目录中的脚本，目录不存在时没有工具和钩子
+type Loader struct {
+	Dir string
+
+	mu    sync.Mutex
+	stamp string
+	set   *Set
+	err   error
+}
+
+// Load 返回目录中脚本定义的工具和钩子。脚本没有改变时返回上一次的结果，
+// 改变后重新加载并返回新的 Set，因此可以比较指针判断是否重新加载过。
+// 有脚本出错时，返回的 Set 包含其余脚本的定义
+func (l *Loader) Load() (*Set, error) {
+	files, stamp, err := l.scan()
+	if err != nil {
+		return &Set{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.set != nil && stamp == l.stamp {
+		return l.set, l.err
+	}
+	l.set, l.err = load(files)
+	l.stamp = stamp
+	return l.set, l.err
+}
+
+// scan 返回目录中的脚本，以及由文件名、大小和修改时间组成的标记
+func (l *Loader) scan() ([]string, string, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read scripts: %w", err)
+	}
+	var files []string
+	var stamp strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != Ext {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(l.Dir, entry.Name()))
+		fmt.Fprintf(&stamp, "%s %d %d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return files, stamp.String(), nil
+}
+
+// load 按文件名顺序执行脚本，一个脚本出错时丢弃它的全部定义
+func load(files []string) (*Set, error) {
+	sort.Strings(files)
+	set := &Set{Tools: []Tool{}, hooks: map[string][]hook{}}
+	var errs []error
+	for _, file := range files {
+		tools, hooks, err := execFile(file)
+		if err == nil {
+			for _, t := range tools {
+				if existing, ok := set.Tool(t.Name); ok {
+					err = fmt.Errorf("%s: tool %s is already defined in %s", file, t.Name, filepath.Base(existing.File))
+					break
+				}
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		set.Tools = append(set.Tools, tools...)
+		for event, hs := range hooks {
+			set.hooks[event] = append(set.hooks[event], hs...)
+		}
+	}
+	return set, errors.Join(errs...)
+}
+
+// execFile 执行一个脚本，收集它定义的工具和钩子
+func execFile(file string) ([]Tool, map[string][]hook, error) {
+	var tools []Tool
+	hooks := map[string][]hook{}
+	thread := newThread(filepath.Base(file), Env{})
+	predeclared := starlark.StringDict{
+		"tool": starlark.NewBuiltin("tool", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var name, description string
+			var fn starlark.Callable
+			params, required := new(starlark.Dict), new(starlark.List)
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+				"name", &name, "fn", &fn, "description?", &description, "params?", &params, "required?", &required); err != nil {
+				return nil, err
+			}
+			if !toolName.MatchString(name) {
+				return nil, fmt.Errorf("tool: name %q must be 1-64 letters, digits, '_' or '-'", name)
+			}
+			t := Tool{Name: name, Description: description, Properties: map[string]any{}, File: file, fn: fn}
+			for _, item := range params.Items() {
+				param, ok := item[0].(starlark.String)
+				if !ok {
+					return nil, fmt.Errorf("tool %s: parameter names must be strings, got %s", name, item[0].Type())
+				}
+				schema, err := paramSchema(thread, item[1])
+				if err != nil {
+					return nil, fmt.Errorf("tool %s: parameter %s: %w", name, param, err)
+				}
+				t.Properties[string(param)] = schema
+			}
+			for i := 0; i < required.Len(); i++ {
+				param, ok := required.Index(i).(starlark.String)
+				if !ok || t.Properties[string(param)] == nil {
+					return nil, fmt.Errorf("tool %s: required parameter %s is not in params", name, required.Index(i))
+				}
+				t.Required = append(t.Required, string(param))
+			}
+			for _, existing := range tools {
+				if existing.Name == name {
+					return nil, fmt.Errorf("tool %s is defined twice", name)
+				}
+			}
+			tools = append(tools, t)
+			return starlark.None, nil
+		}),
+		"hook": starlark.NewBuiltin("hook", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var event string
+			var fn starlark.Callable
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "event", &event, "fn", &fn); err != nil {
+				return nil, err
+			}
+			if event != BeforeTool && event != AfterTool && event != AfterReply {
+				return nil, fmt.Errorf("hook: unknown event %q, want %s, %s or %s", event, BeforeTool, AfterTool, AfterReply)
+			}
+			hooks[event] = append(hooks[event], hook{file: file, fn: fn})
+			return starlark.None, nil
+		}),
+		"read_file": starlark.NewBuiltin("read_file", readFile),
+		"json":      starlarkjson.Module,
+	}
+	if _, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, file, nil, predeclared); err != nil {
+		return nil, nil, scriptError(file, err)
+	}
+	return tools, hooks, nil
+}
+
+// paramSchema 把参数的声明转换为 JSON Schema：字符串是类型，字典是完整的 schema
+func paramSchema(thread *starlark.Thread, v starlark.Value) (any, error) {
+	if typ, ok := v.(starlark.String); ok {
+		return map[string]any{"type": string(typ)}, nil
+	}
+	if _, ok := v.(*starlark.Dict); !ok {
+		return nil, fmt.Errorf("want a type name or a schema dict, got %s", v.Type())
+	}
+	s, err := encode(thread, v)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(s), &schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// envKey 是线程中保存 Env 的键
+const envKey = "env"
+
+func newThread(name string, env Env) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			if env.Print != nil {
+				env.Print(msg)
+			}
+		},
+	}
+	thread.SetLocal(envKey, env)
+	thread.SetMaxExecutionSteps(maxSteps)
+	return thread
+}
+
+// readFile 实现脚本中的 read_file(path)
+func readFile(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var path string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &path); err != nil {
+		return nil, err
+	}
+	env, _ := thread.Local(envKey).(Env)
+	if env.ReadFile == nil {
+		return nil, fmt.Errorf("read_file: files can only be read while a tool or hook runs")
+	}
+	content, err := env.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read_file: %w", err)
+	}
+	return starlark.String(content), nil
+}
+
+// decode 把 JSON 转换为 Starlark 的值，空输入是空字典
+func decode(thread *starlark.Thread, input json.RawMessage) (starlark.Value, error) {
+	if len(input) == 0 {
+		return new(starlark.Dict), nil
+	}
+	return starlark.Call(thread, starlarkjson.Module.Members["decode"], starlark.Tuple{starlark.String(input)}, nil)
+}
+
+// encode 把 Starlark 的值编码成 JSON
+func encode(thread *starlark.Thread, v starlark.Value) (string, error) {
+	s, err := starlark.Call(thread, starlarkjson.Module.Members["encode"], starlark.Tuple{v}, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(s.(starlark.String)), nil
+}
+
+// scriptError 为脚本中的错误加上调用栈，便于定位出错的行
+func scriptError(what string, err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return fmt.Errorf("%s: %s", what, strings.TrimSpace(evalErr.Backtrace()))
+	}
+	return fmt.Errorf("%s: %w", what, err)
+}
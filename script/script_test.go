@@ -0,0 +1,230 @@
+package script
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, dir, name, src string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(src), 0644))
+	// 保证修改时间变化，即使文件系统的时间精度较低
+	later := time.Now().Add(time.Duration(len(src)) * time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+}
+
+func TestLoader(t *testing.T) {
+	t.Run("目录不存在时没有工具", func(t *testing.T) {
+		set, err := (&Loader{Dir: filepath.Join(t.TempDir(), "missing")}).Load()
+		require.NoError(t, err)
+		assert.Empty(t, set.Tools)
+	})
+
+	dir := t.TempDir()
+	writeScript(t, dir, "word_count.star", `
+def count(args):
+    return str(len(args["text"].split()))
+
+tool(
+    name = "word_count",
+    description = "Count the words in a text",
+    params = {"text": "string", "limit": {"type": "integer", "minimum": 1}},
+    required = ["text"],
+    fn = count,
+)
+`)
+	writeScript(t, dir, "notes.txt", "not a script")
+	loader := &Loader{Dir: dir}
+
+	set, err := loader.Load()
+	require.NoError(t, err)
+	require.Len(t, set.Tools, 1)
+	tool := set.Tools[0]
+	assert.Equal(t, "word_count", tool.Name)
+	assert.Equal(t, "Count the words in a text", tool.Description)
+	assert.Equal(t, map[string]any{"type": "string"}, tool.Properties["text"])
+	assert.Equal(t, map[string]any{"type": "integer", "minimum": float64(1)}, tool.Properties["limit"])
+	assert.Equal(t, []string{"text"}, tool.Required)
+
+	out, err := tool.Call(Env{}, json.RawMessage(`{"text": "one two three"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "3", out)
+
+	t.Run("没有改变时返回同一个 Set", func(t *testing.T) {
+		again, err := loader.Load()
+		require.NoError(t, err)
+		assert.Same(t, set, again)
+	})
+
+	t.Run("改变后重新加载", func(t *testing.T) {
+		writeScript(t, dir, "word_count.star", `
+tool(name = "word_count", fn = lambda args: {"words": len(args["text"].split())})
+`)
+		reloaded, err := loader.Load()
+		require.NoError(t, err)
+		require.NotSame(t, set, reloaded)
+		tool, ok := reloaded.Tool("word_count")
+		require.True(t, ok)
+		out, err := tool.Call(Env{}, json.RawMessage(`{"text": "a b"}`))
+		require.NoError(t, err)
+		assert.Equal(t, `{"words":2}`, out, "非字符串的结果编码成 JSON")
+	})
+
+	t.Run("出错的脚本被跳过，其余脚本仍然加载", func(t *testing.T) {
+		writeScript(t, dir, "broken.star", "tool(name = \"broken\", fn = undefined_function)\n")
+		set, err := loader.Load()
+		assert.ErrorContains(t, err, "broken.star")
+		assert.ErrorContains(t, err, "undefined: undefined_function")
+		_, ok := set.Tool("word_count")
+		assert.True(t, ok)
+		_, ok = set.Tool("broken")
+		assert.False(t, ok)
+
+		_, again := loader.Load()
+		assert.Equal(t, err, again, "没有改变时返回同一个错误")
+		require.NoError(t, os.Remove(filepath.Join(dir, "broken.star")))
+	})
+
+	t.Run("不同脚本定义同名工具", func(t *testing.T) {
+		writeScript(t, dir, "zz_copy.star", "tool(name = \"word_count\", fn = lambda args: None)\n")
+		_, err := loader.Load()
+		assert.ErrorContains(t, err, "tool word_count is already defined in word_count.star")
+		require.NoError(t, os.Remove(filepath.Join(dir, "zz_copy.star")))
+	})
+
+	t.Run("工具名不合法", func(t *testing.T) {
+		_, err := (&Loader{Dir: dir}).Load()
+		require.NoError(t, err)
+		writeScript(t, dir, "bad_name.star", "tool(name = \"word count\", fn = lambda args: None)\n")
+		_, err = loader.Load()
+		assert.ErrorContains(t, err, `name "word count" must be`)
+		require.NoError(t, os.Remove(filepath.Join(dir, "bad_name.star")))
+	})
+}
+
+func TestToolCall(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "tools.star", `
+def head(args):
+    print("reading", args["path"])
+    return "\n".join(read_file(args["path"]).splitlines()[:1])
+
+def fails(args):
+    fail("nothing to do")
+
+def loop(args):
+    for i in range(1000000000):
+        pass
+
+tool(name = "head", params = {"path": "string"}, fn = head)
+tool(name = "fails", fn = fails)
+tool(name = "loop", fn = loop)
+`)
+	set, err := (&Loader{Dir: dir}).Load()
+	require.NoError(t, err)
+	call := func(name string, env Env, input string) (string, error) {
+		tool, ok := set.Tool(name)
+		require.True(t, ok, name)
+		return tool.Call(env, json.RawMessage(input))
+	}
+
+	t.Run("读取文件并输出日志", func(t *testing.T) {
+		var printed []string
+		env := Env{
+			ReadFile: func(path string) (string, error) { return "first\nsecond\n", nil },
+			Print:    func(msg string) { printed = append(printed, msg) },
+		}
+		out, err := call("head", env, `{"path": "a.txt"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "first", out)
+		assert.Equal(t, []string{"reading a.txt"}, printed)
+	})
+
+	t.Run("没有提供读取文件的函数", func(t *testing.T) {
+		_, err := call("head", Env{}, `{"path": "a.txt"}`)
+		assert.ErrorContains(t, err, "read_file: files can only be read while a tool or hook runs")
+	})
+
+	t.Run("脚本中的错误带有位置", func(t *testing.T) {
+		_, err := call("fails", Env{}, `{}`)
+		assert.ErrorContains(t, err, "fail: nothing to do")
+		assert.ErrorContains(t, err, "tools.star:7")
+	})
+
+	t.Run("限制计算步数", func(t *testing.T) {
+		_, err := call("loop", Env{}, "")
+		assert.ErrorContains(t, err, "too many steps")
+	})
+
+	t.Run("输入不是 JSON", func(t *testing.T) {
+		_, err := call("fails", Env{}, `{`)
+		assert.ErrorContains(t, err, "fails: invalid input")
+	})
+}
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "a_guard.star", `
+def guard(name, args):
+    if name == "shell" and "rm " in args.get("command", ""):
+        return "rm is not allowed"
+
+hook("before_tool", guard)
+hook("after_tool", lambda name, args, result: result.upper() if name == "shell" else None)
+`)
+	writeScript(t, dir, "b_suffix.star", `
+hook("after_tool", lambda name, args, result: result + "!")
+hook("after_reply", lambda text: text.replace("colour", "color"))
+`)
+	set, err := (&Loader{Dir: dir}).Load()
+	require.NoError(t, err)
+
+	t.Run("before_tool 拒绝调用", func(t *testing.T) {
+		reason, err := set.BeforeTool(Env{}, "shell", json.RawMessage(`{"command": "rm -rf build"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "rm is not allowed", reason)
+
+		reason, err = set.BeforeTool(Env{}, "shell", json.RawMessage(`{"command": "ls"}`))
+		require.NoError(t, err)
+		assert.Empty(t, reason)
+	})
+
+	t.Run("after_tool 按脚本顺序替换结果", func(t *testing.T) {
+		out, err := set.AfterTool(Env{}, "shell", json.RawMessage(`{}`), "done")
+		require.NoError(t, err)
+		assert.Equal(t, "DONE!", out)
+
+		out, err = set.AfterTool(Env{}, "read_file", json.RawMessage(`{}`), "text")
+		require.NoError(t, err)
+		assert.Equal(t, "text!", out)
+	})
+
+	t.Run("after_reply 替换回复", func(t *testing.T) {
+		out, err := set.AfterReply(Env{}, "The colour is red.")
+		require.NoError(t, err)
+		assert.Equal(t, "The color is red.", out)
+	})
+
+	t.Run("未知的事件", func(t *testing.T) {
+		writeScript(t, dir, "c_bad.star", `hook("on_start", lambda: None)`)
+		_, err := (&Loader{Dir: dir}).Load()
+		assert.ErrorContains(t, err, `unknown event "on_start"`)
+	})
+
+	t.Run("没有钩子时不改变结果", func(t *testing.T) {
+		var set *Set
+		reason, err := set.BeforeTool(Env{}, "shell", nil)
+		require.NoError(t, err)
+		assert.Empty(t, reason)
+		out, err := set.AfterTool(Env{}, "shell", nil, "done")
+		require.NoError(t, err)
+		assert.Equal(t, "done", out)
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/script"
+	"agent/theme"
+	"agent/tools"
+)
+
+// scriptDir holds the project's Starlark scripts defining tools and hooks,
+// relative to the project root
+var scriptDir = filepath.Join(".agent", "tools")
+
+// scriptLoaders shares one loader per script directory, so scripts are
+// evaluated again only after they change
+var scriptLoaders = struct {
+	sync.Mutex
+	m map[string]*script.Loader
+}{m: map[string]*script.Loader{}}
+
+// scriptLoader returns the loader for the scripts of cfg's project, or of
+// the workspace when there is no project config
+func scriptLoader(cfg *config.Config) *script.Loader {
+	root := cfg.ProjectDir
+	if root == "" {
+		root = currentWorkspace.Root
+	}
+	dir := filepath.Join(root, scriptDir)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	scriptLoaders.Lock()
+	defer scriptLoaders.Unlock()
+	loader, ok := scriptLoaders.m[dir]
+	if !ok {
+		loader = &script.Loader{Dir: dir}
+		scriptLoaders.m[dir] = loader
+	}
+	return loader
+}
+
+// scriptTools returns the tools defined by the project scripts, run in workspace
+func scriptTools(cfg *config.Config, workspace *tools.Workspace) ([]tools.ToolDefinition, error) {
+	loader := scriptLoader(cfg)
+	set, err := loader.Load()
+	if err != nil {
+		return nil, fmt.Errorf("scripts in %s: %w", loader.Dir, err)
+	}
+	defs := []tools.ToolDefinition{}
+	for _, t := range set.Tools {
+		_, builtin := tools.Default.Lookup(t.Name)
+		for _, custom := range cfg.Tools.Custom {
+			builtin = builtin || custom.Name == t.Name
+		}
+		if builtin {
+			return nil, fmt.Errorf("tool %s defined in %s has the name of another tool", t.Name, t.File)
+		}
+		defs = append(defs, tools.ScriptTool(workspace, t))
+	}
+	return defs, nil
+}
+
+// reloadScripts rebuilds the tools when the project scripts changed since
+// they were loaded, so edits apply from the next turn. Scripts with errors
+// are reported and the previous version stays in use until they are fixed.
+func (a *Agent) reloadScripts() {
+	if a.scripts == nil {
+		// tools not built from a config, e.g. an embedded agent
+		return
+	}
+	loader := scriptLoader(a.config)
+	set, err := loader.Load()
+	if set == a.scripts {
+		return
+	}
+	var defs []tools.ToolDefinition
+	if err == nil {
+		defs, err = enabledTools(a.config, a.reads)
+	}
+	if err != nil {
+		a.log().Warn("scripts not reloaded", "dir", loader.Dir, "error", err)
+		a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: %v; still using the previous version", theme.Error(i18n.T("Script error")), err)})
+		return
+	}
+	a.scripts = set
+	a.setTools(defs)
+	a.log().Info("scripts reloaded", "dir", loader.Dir, "tools", len(set.Tools))
+	a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("Reloaded scripts in %s", loader.Dir)})
+}
+
+// beforeToolHooks runs the scripts' before_tool hooks, refusing the call
+// when one of them returns a reason
+func (a *Agent) beforeToolHooks(call ToolCall) error {
+	if a.scripts == nil {
+		return nil
+	}
+	reason, err := a.scripts.BeforeTool(a.hookWorkspace().ScriptEnv(nil), call.Name, call.Input)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("%s %w by a script hook: %s", call.Name, tools.ErrDenied, reason)
+	}
+	return nil
+}
+
+// afterToolHooks passes the text of a tool's result through the scripts'
+// after_tool hooks; failed results are left alone
+func (a *Agent) afterToolHooks(call ToolCall, result tools.ToolResult) tools.ToolResult {
+	if a.scripts == nil || result.IsError {
+		return result
+	}
+	text, err := a.scripts.AfterTool(a.hookWorkspace().ScriptEnv(nil), call.Name, call.Input, result.Text)
+	if err != nil {
+		return tools.ErrorResult(err)
+	}
+	result.Text = text
+	return result
+}
+
+// hookWorkspace is where hooks may read files, with the configured limits
+func (a *Agent) hookWorkspace() *tools.Workspace {
+	return &tools.Workspace{Root: currentWorkspace.Root, MaxReadBytes: a.config.Limits.MaxReadBytes}
+}
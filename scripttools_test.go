@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptTools(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, scriptDir), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("one two three\n"), 0644))
+	modified := time.Now()
+	writeScript := func(src string) {
+		path := filepath.Join(dir, scriptDir, "tools.star")
+		require.NoError(t, os.WriteFile(path, []byte(src), 0644))
+		// 每次写入都推后修改时间，使改变一定能被发现
+		modified = modified.Add(time.Second)
+		require.NoError(t, os.Chtimes(path, modified, modified))
+	}
+	writeScript(`
+tool(
+    name = "word_count",
+    description = "Count the words in a file",
+    params = {"path": "string"},
+    required = ["path"],
+    fn = lambda args: str(len(read_file(args["path"]).split())),
+)
+
+def guard(name, args):
+    if name == "shell":
+        return "use word_count instead"
+
+hook("before_tool", guard)
+`)
+
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL = "openai", "http://localhost:1/v1"
+	agent := NewAgent(nil, nil, nil)
+	_, err := agent.applyConfig(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, toolNames(agent.tools), "word_count")
+	var events []AgentEvent
+	agent.onEvent = func(e AgentEvent) { events = append(events, e) }
+
+	run := func(calls ...ToolCall) []Message {
+		provider := &mockProvider{responses: []*Response{{ToolCalls: calls}, {Content: "done"}}}
+		agent.provider = provider
+		require.NoError(t, agent.runTurn(context.Background(), "go"))
+		return provider.calls[1][len(provider.calls[1])-len(calls):]
+	}
+
+	t.Run("调用脚本定义的工具", func(t *testing.T) {
+		results := run(ToolCall{ID: "1", Name: "word_count", Input: []byte(`{"path":"notes.txt"}`)})
+		assert.Contains(t, results[0].Content, "3")
+	})
+
+	t.Run("before_tool 钩子拒绝调用", func(t *testing.T) {
+		results := run(ToolCall{ID: "2", Name: "shell", Input: []byte(`{"command":"wc -w notes.txt"}`)})
+		assert.Contains(t, results[0].Content, "shell denied by a script hook: use word_count instead")
+	})
+
+	t.Run("脚本改变后在下一轮重新加载", func(t *testing.T) {
+		writeScript(`
+tool(name = "line_count", fn = lambda args: "1")
+hook("after_tool", lambda name, args, result: "lines: " + result)
+`)
+		events = nil
+		results := run(ToolCall{ID: "3", Name: "line_count", Input: []byte(`{}`)})
+		assert.Contains(t, results[0].Content, "lines: 1")
+		assert.NotContains(t, toolNames(agent.tools), "word_count")
+		require.NotEmpty(t, events)
+		assert.Equal(t, EventNotice, events[0].Type)
+		assert.Contains(t, events[0].Content, "Reloaded scripts")
+	})
+
+	t.Run("脚本出错时继续使用之前的版本", func(t *testing.T) {
+		writeScript("tool(name = \"line_count\", fn = missing)\n")
+		events = nil
+		results := run(ToolCall{ID: "4", Name: "line_count", Input: []byte(`{}`)})
+		assert.Contains(t, results[0].Content, "lines: 1")
+		require.NotEmpty(t, events)
+		assert.Contains(t, events[0].Content, "undefined: missing")
+	})
+
+	t.Run("与内置工具同名", func(t *testing.T) {
+		writeScript("tool(name = \"shell\", fn = lambda args: None)\n")
+		_, err := enabledTools(cfg, nil)
+		assert.ErrorContains(t, err, "has the name of another tool")
+	})
+}
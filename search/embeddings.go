@@ -0,0 +1,205 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// chunkLines 是每段参与检索的文件内容的行数
+	chunkLines = 60
+	// maxEmbedFileSize 以上的文件不参与语义检索，多半是生成的代码或数据
+	maxEmbedFileSize = 256 << 10
+	// embedBatch 是一次请求计算向量的段数
+	embedBatch = 64
+)
+
+// Embedder 计算文本的向量，由 provider 包中的向量提供方实现
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Passage 是语义检索找到的一段文件内容
+type Passage struct {
+	// Path 是相对 root、以 / 分隔的路径
+	Path string
+	// Start 和 End 是这段内容的首行和末行，从 1 开始
+	Start, End int
+	Text       string
+	// Score 是与查询的余弦相似度
+	Score float64
+
+	vector []float32
+}
+
+// embeddedFile 是一个文件的向量，version 是计算时文件在 Index 中的版本
+type embeddedFile struct {
+	version  uint64
+	sum      [sha256.Size]byte
+	passages []Passage
+}
+
+// Embeddings 是工作区文本文件的向量索引，建立在 Index 之上：检索前只为 Index 中版本变化的
+// 文件重新计算向量，内容没有变化的文件沿用原来的向量，删除的文件随之移除
+type Embeddings struct {
+	index    *Index
+	embedder Embedder
+
+	// mu 让同一时刻只有一次检索在更新向量
+	mu    sync.Mutex
+	files map[string]embeddedFile
+}
+
+// NewEmbeddings 创建 index 中文件的向量索引，向量在第一次检索时计算
+func NewEmbeddings(index *Index, embedder Embedder) *Embeddings {
+	return &Embeddings{index: index, embedder: embedder, files: map[string]embeddedFile{}}
+}
+
+// Search 返回与 query 语义最相近的 top 段文件内容，按相似度从高到低排序
+func (e *Embeddings) Search(ctx context.Context, query string, top int) ([]Passage, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.update(ctx); err != nil {
+		return nil, err
+	}
+	vectors, err := e.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("got %d embeddings for 1 text", len(vectors))
+	}
+	var found []Passage
+	for _, file := range e.files {
+		for _, p := range file.passages {
+			p.Score = Cosine(vectors[0], p.vector)
+			found = append(found, p)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Score != found[j].Score {
+			return found[i].Score > found[j].Score
+		}
+		if found[i].Path != found[j].Path {
+			return found[i].Path < found[j].Path
+		}
+		return found[i].Start < found[j].Start
+	})
+	if top > 0 && len(found) > top {
+		found = found[:top]
+	}
+	return found, nil
+}
+
+// update 为变化的文件重新计算向量。失败时已有的向量保持不变，下次检索再重试
+func (e *Embeddings) update(ctx context.Context) error {
+	versions := e.index.Versions()
+	for rel := range e.files {
+		if _, ok := versions[rel]; !ok {
+			delete(e.files, rel)
+		}
+	}
+
+	changed := map[string]embeddedFile{}
+	var pending []*Passage
+	for rel, version := range versions {
+		old, ok := e.files[rel]
+		if ok && old.version == version {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(e.index.Root(), filepath.FromSlash(rel)))
+		if err != nil {
+			// 刚被删除，Index 很快会移除它
+			continue
+		}
+		file := embeddedFile{version: version, sum: sha256.Sum256(data)}
+		switch {
+		case ok && old.sum == file.sum:
+			file.passages = old.passages
+		case len(data) <= maxEmbedFileSize && isText(data):
+			file.passages = split(rel, string(data))
+		}
+		changed[rel] = file
+	}
+	for rel := range changed {
+		file := changed[rel]
+		for i := range file.passages {
+			if file.passages[i].vector == nil {
+				pending = append(pending, &file.passages[i])
+			}
+		}
+	}
+
+	for start := 0; start < len(pending); start += embedBatch {
+		batch := pending[start:min(start+embedBatch, len(pending))]
+		texts := make([]string, len(batch))
+		for i, p := range batch {
+			// 路径也是内容的一部分，例如 auth/token.go 与 token 相关
+			texts[i] = fmt.Sprintf("%s:%d-%d\n%s", p.Path, p.Start, p.End, p.Text)
+		}
+		vectors, err := e.embedder.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		if len(vectors) != len(batch) {
+			return fmt.Errorf("got %d embeddings for %d texts", len(vectors), len(batch))
+		}
+		for i, p := range batch {
+			p.vector = vectors[i]
+		}
+	}
+	for rel, file := range changed {
+		e.files[rel] = file
+	}
+	return nil
+}
+
+// split 把文件内容按 chunkLines 行分段，跳过空白的段
+func split(rel, content string) []Passage {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var passages []Passage
+	for start := 0; start < len(lines); start += chunkLines {
+		end := min(start+chunkLines, len(lines))
+		text := strings.Join(lines[start:end], "")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		passages = append(passages, Passage{Path: rel, Start: start + 1, End: end, Text: strings.TrimSuffix(text, "\n")})
+	}
+	return passages
+}
+
+// isText 判断 data 是否像文本：合法的 UTF-8 且开头没有 NUL
+func isText(data []byte) bool {
+	head := data[:min(len(data), 8000)]
+	return utf8.Valid(data) && bytes.IndexByte(head, 0) < 0
+}
+
+// Cosine 返回两个向量的余弦相似度，长度不同或有零向量时返回 0
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordEmbedder 用几个词的出现次数作为向量，并记录计算过向量的文本
+type wordEmbedder struct{ texts []string }
+
+func (e *wordEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.texts = append(e.texts, texts...)
+	var vectors [][]float32
+	for _, text := range texts {
+		vector := make([]float32, 3)
+		for i, word := range []string{"parse", "config", "http"} {
+			vector[i] = float32(strings.Count(strings.ToLower(text), word))
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+func TestEmbeddings(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"config.go": "package main\n\n// parseConfig reads the config file\nfunc parseConfig() {}\n",
+		"server.go": "package main\n\n// serve answers http requests\nfunc serve() {}\n",
+		"logo.png":  "\x89PNG\x00\x00",
+	})
+	index, err := NewIndex(context.Background(), root)
+	require.NoError(t, err)
+	require.NoError(t, index.Watch())
+	t.Cleanup(func() { index.Close() })
+	embedder := &wordEmbedder{}
+	e := NewEmbeddings(index, embedder)
+
+	paths := func(passages []Passage) []string {
+		var paths []string
+		for _, p := range passages {
+			paths = append(paths, p.Path)
+		}
+		return paths
+	}
+
+	passages, err := e.Search(context.Background(), "http server", 1)
+	require.NoError(t, err)
+	require.Len(t, passages, 1)
+	assert.Equal(t, "server.go", passages[0].Path)
+	assert.Equal(t, 1, passages[0].Start)
+	assert.Equal(t, 4, passages[0].End)
+	assert.Contains(t, passages[0].Text, "func serve()")
+	assert.Len(t, embedder.texts, 3, "两个文件各一段加上查询，二进制文件不计算向量")
+
+	t.Run("只为变化的文件重新计算向量", func(t *testing.T) {
+		embedder.texts = nil
+		require.NoError(t, os.WriteFile(filepath.Join(root, "server.go"), []byte("package main\n\n// serve parses the config too\nfunc serve() {}\n"), 0644))
+		assert.Eventually(t, func() bool {
+			_, err := e.Search(context.Background(), "config", 1)
+			require.NoError(t, err)
+			return strings.Contains(strings.Join(embedder.texts, "\n"), "serve parses the config")
+		}, time.Second, 10*time.Millisecond)
+		for _, text := range embedder.texts {
+			assert.NotContains(t, text, "parseConfig", "没有变化的文件沿用原来的向量")
+		}
+	})
+
+	t.Run("删除的文件不再出现", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(root, "config.go")))
+		assert.Eventually(t, func() bool {
+			passages, err := e.Search(context.Background(), "config", 5)
+			require.NoError(t, err)
+			return assert.ObjectsAreEqual([]string{"server.go"}, paths(passages))
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestSplit(t *testing.T) {
+	content := strings.Repeat("line\n", chunkLines+1)
+	passages := split("a.txt", content)
+	require.Len(t, passages, 2)
+	assert.Equal(t, 1, passages[0].Start)
+	assert.Equal(t, chunkLines, passages[0].End)
+	assert.Equal(t, Passage{Path: "a.txt", Start: chunkLines + 1, End: chunkLines + 1, Text: "line"}, passages[1])
+
+	assert.Empty(t, split("empty.txt", ""))
+	assert.Empty(t, split("blank.txt", "\n\n  \n"), "空白的段不计算向量")
+}
+
+func TestCosine(t *testing.T) {
+	assert.InDelta(t, 1.0, Cosine([]float32{1, 2}, []float32{2, 4}), 1e-9)
+	assert.Equal(t, 0.0, Cosine([]float32{1, 0}, []float32{0, 0}), "零向量")
+	assert.Equal(t, 0.0, Cosine([]float32{1}, []float32{1, 0}), "长度不同")
+}
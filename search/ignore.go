@@ -0,0 +1,181 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule 是 .gitignore 中的一条规则
+type ignoreRule struct {
+	re *regexp.Regexp
+	// negate 对应以 ! 开头的规则，重新包含之前被忽略的路径
+	negate bool
+	// dirOnly 对应以 / 结尾的规则，只匹配目录
+	dirOnly bool
+	// anchored 的规则包含 /，相对 .gitignore 所在目录匹配；否则匹配任意层级的文件名
+	anchored bool
+}
+
+// ignoreSet 是某个目录生效的忽略规则：上级目录的规则加上本目录 .gitignore 中的规则
+type ignoreSet struct {
+	parent *ignoreSet
+	// base 是 .gitignore 所在目录相对搜索根目录的路径，根目录为 "."
+	base  string
+	rules []ignoreRule
+}
+
+// loadIgnore 读取 root 下 dir 目录的 .gitignore，返回在 parent 之上加入其规则的集合；
+// 没有 .gitignore 时直接返回 parent
+func loadIgnore(root, dir string, parent *ignoreSet) *ignoreSet {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), ".gitignore"))
+	if err != nil {
+		return parent
+	}
+	rules := parseIgnore(data)
+	if len(rules) == 0 {
+		return parent
+	}
+	return &ignoreSet{parent: parent, base: dir, rules: rules}
+}
+
+// parseIgnore 解析 .gitignore 的内容，跳过空行、注释和无法解析的规则
+func parseIgnore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		re, err := globRegexp(strings.TrimPrefix(line, "/"))
+		if line == "" || err != nil {
+			continue
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ignored 判断相对搜索根目录的路径 rel 是否被忽略。上级目录的规则先匹配，
+// 最后一条匹配的规则决定结果
+func (s *ignoreSet) ignored(rel string, isDir bool) bool {
+	if s == nil {
+		return false
+	}
+	ignored := s.parent.ignored(rel, isDir)
+	sub := rel
+	if s.base != "." {
+		if !strings.HasPrefix(rel, s.base+"/") {
+			return ignored
+		}
+		sub = rel[len(s.base)+1:]
+	}
+	for _, rule := range s.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := sub
+		if !rule.anchored {
+			target = path.Base(sub)
+		}
+		if rule.re.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// globRegexp 把 glob 转成正则：* 和 ? 不跨越 /，**/ 匹配零或多级目录，
+// 其他位置的 ** 匹配任意字符，[...] 是字符类，{a,b} 匹配其中之一
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + globBody(glob) + "$")
+}
+
+func globBody(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			switch {
+			case strings.HasPrefix(glob[i:], "**/"):
+				b.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(glob[i:], "**"):
+				b.WriteString(".*")
+				i++
+			default:
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			end := strings.IndexByte(glob[i+1:], '}')
+			if end < 0 {
+				b.WriteString(`\{`)
+				continue
+			}
+			alternatives := strings.Split(glob[i+1:i+1+end], ",")
+			for j, alternative := range alternatives {
+				alternatives[j] = globBody(alternative)
+			}
+			b.WriteString("(?:" + strings.Join(alternatives, "|") + ")")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Pattern 是编译后的文件名 glob。不含 / 的 glob 匹配任意层级的文件名，
+// 例如 *.go；含 / 的 glob 匹配相对搜索根目录的完整路径，例如 cmd/**/*.go
+type Pattern struct {
+	re   *regexp.Regexp
+	base bool
+}
+
+// CompilePattern 编译 glob
+func CompilePattern(glob string) (*Pattern, error) {
+	re, err := globRegexp(strings.TrimPrefix(glob, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return &Pattern{re: re, base: !strings.Contains(glob, "/")}, nil
+}
+
+// Match 判断相对搜索根目录、以 / 分隔的路径 rel 是否匹配
+func (p *Pattern) Match(rel string) bool {
+	if p.base {
+		return p.re.MatchString(path.Base(rel))
+	}
+	return p.re.MatchString(rel)
+}
@@ -0,0 +1,340 @@
+package search
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Symbol 是 Go 文件中的一个顶层声明
+type Symbol struct {
+	Name string
+	// Kind 为 func、method、type、const 或 var
+	Kind string
+	// Recv 是方法的接收者类型，其他声明为空
+	Recv string
+	// Path 是相对 root、以 / 分隔的路径
+	Path string
+	Line int
+}
+
+// Index 是工作区的文件列表和 Go 符号索引。Watch 之后它随文件的新建、修改、删除和重命名
+// 增量更新，只重新索引变化的文件，不论改动来自用户还是 agent；.gitignore 变化或事件丢失时才整体重建
+type Index struct {
+	root string
+
+	mu    sync.RWMutex
+	files map[string][]Symbol
+	// versions 记录每个文件最近一次被索引时的序号，供 Embeddings 找出变化的文件
+	versions map[string]uint64
+	gen      uint64
+
+	watcher *fsnotify.Watcher
+}
+
+// NewIndex 遍历 root 建立索引，与 Grep 一样遵守 .gitignore
+func NewIndex(ctx context.Context, root string) (*Index, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	x := &Index{root: root}
+	if err := x.rebuild(ctx); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Root 返回索引的目录
+func (x *Index) Root() string {
+	return x.root
+}
+
+func (x *Index) rebuild(ctx context.Context) error {
+	files := map[string][]Symbol{}
+	err := walk(ctx, x.root, "", func(rel string) error {
+		files[rel] = x.parse(rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	x.mu.Lock()
+	x.files = map[string][]Symbol{}
+	x.versions = map[string]uint64{}
+	x.set(files)
+	x.mu.Unlock()
+	return nil
+}
+
+// set 记录重新索引的文件，调用方持有 mu
+func (x *Index) set(files map[string][]Symbol) {
+	for rel, symbols := range files {
+		x.gen++
+		x.files[rel] = symbols
+		x.versions[rel] = x.gen
+	}
+}
+
+// parse 返回文件中的符号，非 Go 文件和无法解析的部分没有符号
+func (x *Index) parse(rel string) []Symbol {
+	if !strings.HasSuffix(rel, ".go") {
+		return nil
+	}
+	fset := token.NewFileSet()
+	// 有语法错误时仍使用解析出的部分
+	file, _ := parser.ParseFile(fset, filepath.Join(x.root, filepath.FromSlash(rel)), nil, parser.SkipObjectResolution)
+	if file == nil {
+		return nil
+	}
+	var symbols []Symbol
+	add := func(name *ast.Ident, kind, recv string) {
+		if name == nil || name.Name == "_" {
+			return
+		}
+		symbols = append(symbols, Symbol{Name: name.Name, Kind: kind, Recv: recv, Path: rel, Line: fset.Position(name.Pos()).Line})
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add(d.Name, "method", receiverType(d.Recv.List[0].Type))
+			} else {
+				add(d.Name, "func", "")
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name, "type", "")
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						add(name, d.Tok.String(), "")
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// receiverType 返回接收者的类型名，去掉指针和类型参数
+func receiverType(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// Files 返回索引中的文件，按路径排序
+func (x *Index) Files() []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	files := make([]string, 0, len(x.files))
+	for rel := range x.files {
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Versions 返回每个文件的版本。文件每次被重新索引时版本都会增大，版本不变说明文件没有变化
+func (x *Index) Versions() map[string]uint64 {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	versions := make(map[string]uint64, len(x.versions))
+	for rel, v := range x.versions {
+		versions[rel] = v
+	}
+	return versions
+}
+
+// Symbols 返回名称包含 name（不区分大小写）的符号，名称完全相同的排在前面，其余按路径和行号排序
+func (x *Index) Symbols(name string) []Symbol {
+	query := strings.ToLower(name)
+	x.mu.RLock()
+	var found []Symbol
+	for _, symbols := range x.files {
+		for _, s := range symbols {
+			if strings.Contains(strings.ToLower(s.Name), query) {
+				found = append(found, s)
+			}
+		}
+	}
+	x.mu.RUnlock()
+	sort.Slice(found, func(i, j int) bool {
+		if exact := found[i].Name == name; exact != (found[j].Name == name) {
+			return exact
+		}
+		if found[i].Path != found[j].Path {
+			return found[i].Path < found[j].Path
+		}
+		return found[i].Line < found[j].Line
+	})
+	return found
+}
+
+// Watch 开始监视 root 下未被忽略的目录。监视失败时索引仍可使用，只是不再更新
+func (x *Index) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	x.watcher = watcher
+	if err := x.watchTree("."); err != nil {
+		watcher.Close()
+		x.watcher = nil
+		return err
+	}
+	go x.run()
+	return nil
+}
+
+// watchTree 监视 root 下的 dir 及其中未被忽略的子目录
+func (x *Index) watchTree(dir string) error {
+	start := filepath.Join(x.root, filepath.FromSlash(dir))
+	return filepath.WalkDir(start, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(x.root, name)
+		if err != nil {
+			return err
+		}
+		if rel = filepath.ToSlash(rel); rel != dir && x.ignored(rel, true) {
+			return filepath.SkipDir
+		}
+		return x.watcher.Add(name)
+	})
+}
+
+// ignored 判断 rel 是否被 .git 目录或各级 .gitignore 排除
+func (x *Index) ignored(rel string, isDir bool) bool {
+	set := loadIgnore(x.root, ".", nil)
+	prefix := ""
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		if part == ".git" {
+			return true
+		}
+		prefix = path.Join(prefix, part)
+		last := i == len(parts)-1
+		if set.ignored(prefix, isDir || !last) {
+			return true
+		}
+		if !last {
+			set = loadIgnore(x.root, prefix, set)
+		}
+	}
+	return false
+}
+
+func (x *Index) run() {
+	for {
+		select {
+		case event, ok := <-x.watcher.Events:
+			if !ok {
+				return
+			}
+			x.update(event)
+		case _, ok := <-x.watcher.Errors:
+			if !ok {
+				return
+			}
+			// 事件可能已经丢失，例如队列溢出
+			x.rebuild(context.Background())
+		}
+	}
+}
+
+// update 按一个文件事件更新索引
+func (x *Index) update(event fsnotify.Event) {
+	rel, err := filepath.Rel(x.root, event.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if path.Base(rel) == ".gitignore" {
+		// 忽略规则变了，哪些文件在索引中也可能随之改变
+		x.rebuild(context.Background())
+		return
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// 重命名的新路径会另有一个 Create 事件
+		x.remove(rel)
+		return
+	}
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		return
+	}
+	switch {
+	case info.IsDir():
+		if x.ignored(rel, true) {
+			return
+		}
+		// 目录开始被监视前可能已经有文件写入，这里一并索引
+		x.watchTree(rel)
+		files := map[string][]Symbol{}
+		walk(context.Background(), x.root, rel, func(name string) error {
+			files[name] = x.parse(name)
+			return nil
+		})
+		x.mu.Lock()
+		x.set(files)
+		x.mu.Unlock()
+	case info.Mode().IsRegular():
+		if x.ignored(rel, false) {
+			return
+		}
+		symbols := x.parse(rel)
+		x.mu.Lock()
+		x.set(map[string][]Symbol{rel: symbols})
+		x.mu.Unlock()
+	}
+}
+
+// remove 从索引中删除 rel，rel 是目录时删除其中的全部文件
+func (x *Index) remove(rel string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.files, rel)
+	delete(x.versions, rel)
+	for name := range x.files {
+		if strings.HasPrefix(name, rel+"/") {
+			delete(x.files, name)
+			delete(x.versions, name)
+		}
+	}
+}
+
+// Close 停止监视
+func (x *Index) Close() error {
+	if x.watcher == nil {
+		return nil
+	}
+	return x.watcher.Close()
+}
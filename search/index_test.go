@@ -0,0 +1,92 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "build/\n",
+		"main.go":    "package main\n\ntype Agent struct{}\n\nfunc NewAgent() *Agent { return nil }\n\nfunc (a *Agent) Run() {}\n",
+		"consts.go":  "package main\n\nconst maxTurns = 3\n\nvar ErrStop error\n",
+		"README.md":  "# agent\n",
+		"build/x.go": "package build\n\nfunc Hidden() {}\n",
+	})
+	index, err := NewIndex(context.Background(), root)
+	require.NoError(t, err)
+
+	t.Run("列出未被忽略的文件", func(t *testing.T) {
+		assert.Equal(t, []string{".gitignore", "README.md", "consts.go", "main.go"}, index.Files())
+	})
+
+	t.Run("按名称查找声明", func(t *testing.T) {
+		assert.Equal(t, []Symbol{
+			{Name: "Agent", Kind: "type", Path: "main.go", Line: 3},
+			{Name: "NewAgent", Kind: "func", Path: "main.go", Line: 5},
+		}, index.Symbols("Agent"), "名称完全相同的排在前面")
+		assert.Equal(t, []Symbol{{Name: "Run", Kind: "method", Recv: "Agent", Path: "main.go", Line: 7}}, index.Symbols("run"))
+		assert.Equal(t, []Symbol{{Name: "maxTurns", Kind: "const", Path: "consts.go", Line: 3}}, index.Symbols("maxturns"))
+		assert.Equal(t, []Symbol{{Name: "ErrStop", Kind: "var", Path: "consts.go", Line: 5}}, index.Symbols("ErrStop"))
+		assert.Empty(t, index.Symbols("Hidden"), "被忽略的目录不建立索引")
+	})
+}
+
+func TestIndexWatch(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore": "*.log\n",
+		"a.go":       "package a\n\nfunc Old() {}\n",
+	})
+	index, err := NewIndex(context.Background(), root)
+	require.NoError(t, err)
+	require.NoError(t, index.Watch())
+	t.Cleanup(func() { index.Close() })
+
+	has := func(name string) func() bool {
+		return func() bool { return len(index.Symbols(name)) > 0 }
+	}
+	lists := func(files ...string) func() bool {
+		return func() bool { return assert.ObjectsAreEqual(files, index.Files()) }
+	}
+
+	t.Run("修改的文件重新索引", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n\nfunc New() {}\n"), 0644))
+		assert.Eventually(t, func() bool { return has("New")() && !has("Old")() }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("新建和删除文件", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "b.go"), []byte("package a\n\ntype B int\n"), 0644))
+		assert.Eventually(t, has("B"), time.Second, 10*time.Millisecond)
+		require.NoError(t, os.Remove(filepath.Join(root, "b.go")))
+		assert.Eventually(t, lists(".gitignore", "a.go"), time.Second, 10*time.Millisecond)
+		assert.Empty(t, index.Symbols("B"))
+	})
+
+	t.Run("监视新建的目录", func(t *testing.T) {
+		require.NoError(t, os.Mkdir(filepath.Join(root, "sub"), 0755))
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "c.go"), []byte("package sub\n\nfunc C() {}\n"), 0644))
+		assert.Eventually(t, has("C"), time.Second, 10*time.Millisecond)
+		require.NoError(t, os.RemoveAll(filepath.Join(root, "sub")))
+		assert.Eventually(t, lists(".gitignore", "a.go"), time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("忽略的文件不加入索引", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "debug.log"), []byte("x\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "d.go"), []byte("package a\n"), 0644))
+		assert.Eventually(t, lists(".gitignore", "a.go", "d.go"), time.Second, 10*time.Millisecond)
+	})
+
+	t.Run(".gitignore 变化后重建", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\nd.go\n"), 0644))
+		assert.Eventually(t, lists(".gitignore", "a.go"), time.Second, 10*time.Millisecond)
+	})
+}
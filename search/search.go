@@ -0,0 +1,205 @@
+// Package search 在工作区中并发地按内容或文件名搜索文件：遵守 .gitignore，
+// 跳过 .git 目录、符号链接和二进制文件，由固定数量的 goroutine 并发读取文件
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// binaryProbe 是判断二进制文件时检查的字节数，与 git 的判断方式相同
+const binaryProbe = 8000
+
+// Options 控制搜索的范围和结果数量
+type Options struct {
+	// Dir 是相对 root 的子目录，空表示整个 root
+	Dir string
+	// Include 非空时只搜索匹配这个 glob 的文件
+	Include *Pattern
+	// MaxResults 限制返回的结果数，0 表示不限制
+	MaxResults int
+	// Workers 是并发读取文件的 goroutine 数，0 表示 CPU 数
+	Workers int
+}
+
+// Match 是一行匹配的内容
+type Match struct {
+	// Path 是相对 root、以 / 分隔的路径
+	Path string
+	Line int
+	Text string
+}
+
+// Grep 在 root 下搜索内容匹配 re 的行，结果按路径和行号排序。
+// 结果超过 opts.MaxResults 时提前停止，并返回 truncated 为 true
+func Grep(ctx context.Context, root string, re *regexp.Regexp, opts Options) (matches []Match, truncated bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	paths := make(chan string, 256)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range paths {
+				found := grepFile(filepath.Join(root, filepath.FromSlash(rel)), rel, re)
+				if len(found) == 0 {
+					continue
+				}
+				mu.Lock()
+				matches = append(matches, found...)
+				if opts.MaxResults > 0 && len(matches) > opts.MaxResults {
+					truncated = true
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err = walk(ctx, root, opts.Dir, func(rel string) error {
+		if opts.Include != nil && !opts.Include.Match(rel) {
+			return nil
+		}
+		select {
+		case paths <- rel:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(paths)
+	wg.Wait()
+	if err != nil && !(truncated && errors.Is(err, context.Canceled)) {
+		return nil, false, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Line < matches[j].Line
+	})
+	if truncated {
+		matches = matches[:opts.MaxResults]
+	}
+	return matches, truncated, nil
+}
+
+// grepFile 返回文件中匹配 re 的行。读不了的文件和二进制文件返回 nil
+func grepFile(name, rel string, re *regexp.Regexp) []Match {
+	content, err := os.ReadFile(name)
+	if err != nil || isBinary(content) || !re.Match(content) {
+		return nil
+	}
+	var matches []Match
+	for line := 1; len(content) > 0; line++ {
+		text := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			text, content = content[:i], content[i+1:]
+		} else {
+			content = nil
+		}
+		text = bytes.TrimSuffix(text, []byte("\r"))
+		if re.Match(text) {
+			matches = append(matches, Match{Path: rel, Line: line, Text: string(text)})
+		}
+	}
+	return matches
+}
+
+// isBinary 判断开头的字节中是否有 NUL
+func isBinary(content []byte) bool {
+	if len(content) > binaryProbe {
+		content = content[:binaryProbe]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// Glob 返回 root 下路径匹配 pattern 的文件，按路径排序。只遍历目录而不读取文件内容
+func Glob(ctx context.Context, root string, pattern *Pattern, opts Options) (files []string, truncated bool, err error) {
+	err = walk(ctx, root, opts.Dir, func(rel string) error {
+		if pattern.Match(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Strings(files)
+	if opts.MaxResults > 0 && len(files) > opts.MaxResults {
+		files, truncated = files[:opts.MaxResults], true
+	}
+	return files, truncated, nil
+}
+
+// walk 遍历 root 下的 dir，对每个未被忽略的普通文件调用 fn，参数是相对 root、以 / 分隔的路径。
+// root 到 dir 之间各级目录的 .gitignore 也会生效
+func walk(ctx context.Context, root, dir string, fn func(rel string) error) error {
+	dir = path.Clean("/" + filepath.ToSlash(dir))[1:]
+	if dir == "" {
+		dir = "."
+	}
+	sets := map[string]*ignoreSet{}
+	set := loadIgnore(root, ".", nil)
+	sets["."] = set
+	if dir != "." {
+		prefix := ""
+		for _, part := range strings.Split(dir, "/") {
+			prefix = path.Join(prefix, part)
+			set = loadIgnore(root, prefix, set)
+			sets[prefix] = set
+		}
+	}
+
+	start := filepath.Join(root, filepath.FromSlash(dir))
+	return filepath.WalkDir(start, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == start {
+				return err
+			}
+			// 读不了的子目录直接跳过
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == dir {
+			return nil
+		}
+		parent := sets[path.Dir(rel)]
+		if d.IsDir() {
+			if d.Name() == ".git" || parent.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			sets[rel] = loadIgnore(root, rel, parent)
+			return nil
+		}
+		if !d.Type().IsRegular() || parent.ignored(rel, false) {
+			return nil
+		}
+		return fn(rel)
+	})
+}
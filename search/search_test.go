@@ -0,0 +1,195 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree 在 root 下创建 files 中的文件，键是以 / 分隔的相对路径
+func writeTree(t testing.TB, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/agent/main.go", true},
+		{"*.go", "main.go.orig", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"cmd/*.go", "cmd/agent/main.go", false},
+		{"cmd/**/*.go", "cmd/main.go", true},
+		{"cmd/**/*.go", "cmd/agent/main.go", true},
+		{"**/*_test.go", "tools/shell_test.go", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"*.{ts,tsx}", "web/app.tsx", true},
+		{"*.{ts,tsx}", "web/app.js", false},
+		{"file?.txt", "file1.txt", true},
+		{"[!a]*.txt", "abc.txt", false},
+		{"[!a]*.txt", "bcd.txt", true},
+	}
+	for _, tt := range tests {
+		p, err := CompilePattern(tt.glob)
+		require.NoError(t, err)
+		assert.Equal(t, tt.match, p.Match(tt.path), "%s ~ %s", tt.glob, tt.path)
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":         "# 构建产物\n*.log\n/build\nnode_modules/\n!keep.log\n",
+		"web/.gitignore":     "dist\n",
+		"main.go":            "package main\n",
+		"debug.log":          "x\n",
+		"keep.log":           "x\n",
+		"build/out.go":       "package out\n",
+		"cmd/build/main.go":  "package main\n",
+		"web/node_modules/a": "x\n",
+		"web/dist/app.js":    "x\n",
+		"web/src/app.js":     "x\n",
+		"dist/readme.md":     "x\n",
+		".git/config":        "x\n",
+	})
+
+	var files []string
+	require.NoError(t, walk(context.Background(), root, "", func(rel string) error {
+		files = append(files, rel)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{
+		".gitignore", "main.go", "keep.log", "cmd/build/main.go", "web/.gitignore", "web/src/app.js", "dist/readme.md",
+	}, files)
+
+	t.Run("从子目录开始时上级的 .gitignore 也生效", func(t *testing.T) {
+		var files []string
+		require.NoError(t, walk(context.Background(), root, "web", func(rel string) error {
+			files = append(files, rel)
+			return nil
+		}))
+		assert.ElementsMatch(t, []string{"web/.gitignore", "web/src/app.js"}, files)
+	})
+}
+
+func TestGrep(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":       "vendor/\n",
+		"main.go":          "package main\n\nfunc main() {\n\trun()\n}\n",
+		"run.go":           "package main\r\n\r\nfunc run() {}\r\n",
+		"vendor/lib/a.go":  "func main() {}\n",
+		"docs/notes.md":    "func main is the entry point\n",
+		"bin/agent":        "func main\x00\x01\x02",
+		"internal/util.go": "package internal\n",
+	})
+	re := regexp.MustCompile(`func \w+\(`)
+
+	matches, truncated, err := Grep(context.Background(), root, re, Options{})
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, []Match{
+		{Path: "main.go", Line: 3, Text: "func main() {"},
+		{Path: "run.go", Line: 3, Text: "func run() {}"},
+	}, matches, "跳过被忽略的目录和二进制文件，去掉行尾的 \\r")
+
+	t.Run("按 glob 过滤文件", func(t *testing.T) {
+		include, err := CompilePattern("*.md")
+		require.NoError(t, err)
+		matches, _, err := Grep(context.Background(), root, regexp.MustCompile("main"), Options{Include: include})
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		assert.Equal(t, "docs/notes.md", matches[0].Path)
+	})
+
+	t.Run("只搜索子目录", func(t *testing.T) {
+		matches, _, err := Grep(context.Background(), root, regexp.MustCompile("package"), Options{Dir: "internal"})
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		assert.Equal(t, "internal/util.go", matches[0].Path)
+	})
+
+	t.Run("超过上限时截断", func(t *testing.T) {
+		matches, truncated, err := Grep(context.Background(), root, regexp.MustCompile("."), Options{MaxResults: 2, Workers: 1})
+		require.NoError(t, err)
+		assert.True(t, truncated)
+		assert.Len(t, matches, 2)
+	})
+
+	t.Run("目录不存在时报错", func(t *testing.T) {
+		_, _, err := Grep(context.Background(), root, re, Options{Dir: "missing"})
+		assert.Error(t, err)
+	})
+}
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":          "*.gen.go\n",
+		"main.go":             "",
+		"main_test.go":        "",
+		"api.gen.go":          "",
+		"tools/shell.go":      "",
+		"tools/shell_test.go": "",
+	})
+	pattern, err := CompilePattern("*_test.go")
+	require.NoError(t, err)
+	files, truncated, err := Glob(context.Background(), root, pattern, Options{})
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, []string{"main_test.go", "tools/shell_test.go"}, files)
+
+	pattern, err = CompilePattern("*.go")
+	require.NoError(t, err)
+	files, truncated, err = Glob(context.Background(), root, pattern, Options{MaxResults: 3})
+	require.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, []string{"main.go", "main_test.go", "tools/shell.go"}, files)
+}
+
+// BenchmarkGrep 在一个模拟的大仓库（约 5000 个文件、20 万行）中搜索，
+// 比较单个 goroutine 和默认并发数的耗时
+func BenchmarkGrep(b *testing.B) {
+	root := b.TempDir()
+	line := "\tresult, err := client.Do(ctx, request) // 处理返回的错误\n"
+	body := "package pkg\n\n" + strings.Repeat(line, 40)
+	files := map[string]string{".gitignore": "node_modules/\n"}
+	for d := 0; d < 50; d++ {
+		for f := 0; f < 100; f++ {
+			files[fmt.Sprintf("pkg%02d/sub%d/file%03d.go", d, f%5, f)] = body
+		}
+		files[fmt.Sprintf("node_modules/dep%02d/index.js", d)] = body
+	}
+	files["pkg07/sub3/needle.go"] = body + "func findTheNeedle() {}\n"
+	writeTree(b, root, files)
+	re := regexp.MustCompile(`func findThe\w+`)
+
+	for _, workers := range []int{1, 0} {
+		name := fmt.Sprintf("workers=%d", workers)
+		if workers == 0 {
+			name = "workers=cpu"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matches, _, err := Grep(context.Background(), root, re, Options{Workers: workers})
+				if err != nil || len(matches) != 1 {
+					b.Fatalf("matches = %v, err = %v", matches, err)
+				}
+			}
+		})
+	}
+}
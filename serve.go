@@ -0,0 +1,704 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// EventTurnDone ends the events of a turn run by the HTTP API; Content holds
+// the error if the turn failed
+const EventTurnDone = "turn_done"
+
+// EventApprovalRequest asks the client to approve or deny ToolCall before it
+// runs, when the server requires approval
+const EventApprovalRequest = "approval_request"
+
+// apiServer exposes agent sessions over HTTP:
+//
+//	GET  /sessions                  list sessions
+//	POST /sessions                  create a session
+//	GET  /sessions/{id}             messages of a session
+//	POST /sessions/{id}/messages    start a turn with {"content": "..."}
+//	GET  /sessions/{id}/events      stream the session's events (SSE)
+//	POST /sessions/{id}/approvals/{call}  approve a tool call with {"approved": true}
+//	GET  /sessions/{id}/ws          both directions over a WebSocket
+//	GET  /me                        the authenticated user and their usage
+//
+// Turns run in the background; their events, ending with turn_done, are
+// delivered to event streams.
+type apiServer struct {
+	// newAgent builds an agent with the provider, tools and stores configured
+	newAgent func() (*Agent, error)
+	store    SessionStore
+	// ctx bounds running turns; cancelling it stops them
+	ctx context.Context
+	// requireApproval holds tools that change state until a client approves them
+	requireApproval bool
+	// idleTimeout unloads sessions unused for this long, saving them first;
+	// zero keeps them loaded until the server stops
+	idleTimeout time.Duration
+	// heartbeat is how often WebSocket clients are pinged; zero turns it off
+	heartbeat time.Duration
+	// auth authenticates every request when users are configured; each user
+	// then sees only their own sessions
+	auth *serverAuth
+	// token is the bearer token every request needs when no users are
+	// configured; empty leaves the API open
+	token string
+
+	mu       sync.Mutex
+	sessions map[string]*liveSession
+}
+
+// liveSession is a session loaded in the server, with the events of the
+// turns it has run since
+type liveSession struct {
+	agent *Agent
+
+	mu       sync.Mutex
+	busy     bool
+	messages []Message
+	events   []AgentEvent
+	// changed is closed and replaced whenever an event is added
+	changed chan struct{}
+	// pending receives the decisions for tool calls awaiting approval, by call ID
+	pending map[string]chan bool
+	// lastActive is when the session was last requested, streamed or changed
+	lastActive time.Time
+	// streams counts the open event streams; streamed sessions are never idle
+	streams int
+	// evicted is set once the server has unloaded the session
+	evicted bool
+}
+
+// sessionSummary describes a session in the list
+type sessionSummary struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  int       `json:"messages"`
+	Busy      bool      `json:"busy"`
+}
+
+func newAPIServer(ctx context.Context, store SessionStore, newAgent func() (*Agent, error)) *apiServer {
+	return &apiServer{newAgent: newAgent, store: store, ctx: ctx, idleTimeout: defaultIdleTimeout, heartbeat: defaultHeartbeat, sessions: map[string]*liveSession{}}
+}
+
+func (s *apiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sessions", s.handleSessions)
+	mux.HandleFunc("/sessions/", s.handleSession)
+	if s.auth == nil {
+		return s.guard(mux)
+	}
+	mux.HandleFunc("/me", s.handleMe)
+	return s.guard(s.auth.wrap(mux))
+}
+
+// guard keeps web pages from driving the API through the user's browser.
+// Without users the server is meant for a loopback address, so a Host naming
+// anything else comes from a DNS rebinding page, and every request needs the
+// token printed at startup. POSTs must be JSON, which a page on another
+// origin can only send after a CORS preflight this server never answers.
+func (s *apiServer) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil && !loopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %s is not a loopback address; configure server users to serve other hosts", r.Host))
+			return
+		}
+		if s.auth == nil && s.token != "" {
+			token := bearerToken(r)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="agent"`)
+				writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("POST requests need Content-Type: application/json"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loopbackHost reports whether the host of a Host header is localhost or a
+// loopback IP address
+func loopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]")
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newServerToken returns a random bearer token for a server without users
+func newServerToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *apiServer) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listSessions(w, requestUser(r))
+	case http.MethodPost:
+		live, err := s.liveFor(requestUser(r), "")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]string{"id": live.agent.session.ID})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (s *apiServer) handleSession(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/sessions/"), "/")
+	if parts[0] == "" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	live, err := s.liveFor(requestUser(r), parts[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	route := r.Method
+	if len(parts) >= 2 {
+		route += " " + parts[1]
+	}
+	if (len(parts) == 3) != (route == "POST approvals") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %s %s", r.Method, r.URL.Path))
+		return
+	}
+	switch route {
+	case "GET":
+		live.mu.Lock()
+		body := map[string]interface{}{"id": parts[0], "busy": live.busy, "messages": live.messages}
+		live.mu.Unlock()
+		writeJSON(w, http.StatusOK, body)
+	case "POST messages":
+		s.postMessage(w, r, live)
+	case "GET events":
+		s.streamEvents(w, r, live)
+	case "POST approvals":
+		var body struct {
+			Approved bool `json:"approved"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+			return
+		}
+		if err := live.decide(parts[2], body.Approved); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"approved": body.Approved})
+	case "GET ws":
+		s.serveWebSocket(w, r, live)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// live returns the loaded session with the given ID, loading it from the
+// store if needed; an empty ID creates a new session
+func (s *apiServer) live(id string) (*liveSession, error) {
+	return s.liveFor(nil, id)
+}
+
+// liveFor is live for an authenticated user: they can only reach their own
+// sessions, and the sessions they create get their tools and quota
+func (s *apiServer) liveFor(user *serverUser, id string) (*liveSession, error) {
+	if live, ok, err := s.loaded(user, id); ok || err != nil {
+		return live, err
+	}
+
+	// building an agent reads config and starts tools, so it happens
+	// without s.mu; another request may load the same session meanwhile
+	agent, err := s.newAgent()
+	if err != nil {
+		return nil, err
+	}
+	if id != "" {
+		if s.store == nil {
+			return nil, fmt.Errorf("session %s not found", id)
+		}
+		session, err := s.store.Load(id)
+		if err != nil || !user.owns(session) {
+			return nil, fmt.Errorf("session %s not found", id)
+		}
+		agent.resumeSession(session)
+	}
+	if user != nil {
+		agent.session.Owner = user.Name
+		agent.setTools(user.allowedTools(agent.toolDefinitions()))
+		agent.provider = Chain(agent.provider, QuotaMiddleware(user.quota))
+	}
+
+	live := &liveSession{agent: agent, messages: agent.Messages(), changed: make(chan struct{}), pending: map[string]chan bool{}, lastActive: time.Now()}
+	if s.requireApproval {
+		agent.approve = live.approve
+	}
+	agent.streamText = true
+	agent.onEvent = func(e AgentEvent) {
+		// spinner updates are only meaningful on a terminal
+		if e.Type != EventActivity {
+			live.add(e)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.sessions[agent.session.ID]; ok {
+		existing.mu.Lock()
+		existing.touch()
+		existing.mu.Unlock()
+		return existing, nil
+	}
+	s.sessions[agent.session.ID] = live
+	return live, nil
+}
+
+// loaded returns the session with the given ID if it is already in memory
+func (s *apiServer) loaded(user *serverUser, id string) (*liveSession, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	live, ok := s.sessions[id]
+	if !ok {
+		return nil, false, nil
+	}
+	if !user.owns(live.agent.session) {
+		return nil, false, fmt.Errorf("session %s not found", id)
+	}
+	live.mu.Lock()
+	live.touch()
+	live.mu.Unlock()
+	return live, true, nil
+}
+
+func (s *apiServer) listSessions(w http.ResponseWriter, user *serverUser) {
+	summaries := map[string]sessionSummary{}
+	if s.store != nil {
+		sessions, err := s.store.List()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, session := range sessions {
+			if !user.owns(session) {
+				continue
+			}
+			summaries[session.ID] = sessionSummary{ID: session.ID, CreatedAt: session.CreatedAt, UpdatedAt: session.UpdatedAt, Messages: len(session.Messages)}
+		}
+	}
+	s.mu.Lock()
+	for id, live := range s.sessions {
+		if !user.owns(live.agent.session) {
+			continue
+		}
+		live.mu.Lock()
+		session := live.agent.session
+		summaries[id] = sessionSummary{ID: id, CreatedAt: session.CreatedAt, UpdatedAt: session.UpdatedAt, Messages: len(live.messages), Busy: live.busy}
+		live.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	list := make([]sessionSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		list = append(list, summary)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt.After(list[j].UpdatedAt) })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": list})
+}
+
+func (s *apiServer) postMessage(w http.ResponseWriter, r *http.Request, live *liveSession) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+	if strings.TrimSpace(body.Content) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("content must not be empty"))
+		return
+	}
+
+	next, err := s.startTurn(live, body.Content)
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"status": "running", "events_since": next})
+}
+
+// startTurn starts a turn in the background and returns the index of its
+// first event, or an error if a turn is already running
+func (s *apiServer) startTurn(live *liveSession, content string) (int, error) {
+	live.mu.Lock()
+	if live.busy {
+		live.mu.Unlock()
+		return 0, fmt.Errorf("a turn is already running in this session")
+	}
+	if live.evicted {
+		live.mu.Unlock()
+		return 0, fmt.Errorf("the session was unloaded after being idle; retry to load it again")
+	}
+	live.busy = true
+	next := len(live.events)
+	live.mu.Unlock()
+	live.add(AgentEvent{Type: EventUserMessage, Content: content})
+
+	go s.runTurn(live, content)
+	return next, nil
+}
+
+// runTurn runs one turn in the background and saves the session after it
+func (s *apiServer) runTurn(live *liveSession, content string) {
+	agent := live.agent
+	err := agent.runTurn(s.ctx, content)
+	if saveErr := agent.saveSession(); err == nil {
+		err = saveErr
+	}
+
+	done := AgentEvent{Type: EventTurnDone}
+	if err != nil {
+		done.Content = err.Error()
+	}
+	live.mu.Lock()
+	live.busy = false
+	live.messages = agent.Messages()
+	live.touch()
+	live.mu.Unlock()
+	live.add(done)
+}
+
+// add records an event and wakes up event streams
+func (l *liveSession) add(e AgentEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+	l.touch()
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// streamEvents sends the session's events as server-sent events, starting
+// after Last-Event-ID or ?since=N (default: only new events), until the
+// client disconnects
+func (s *apiServer) streamEvents(w http.ResponseWriter, r *http.Request, live *liveSession) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+
+	next, err := eventIndex(r, live)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	s.follow(r.Context(), live, next, func(index int, e AgentEvent) error {
+		data, _ := json.Marshal(e)
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", index+1, e.Type, data)
+		flusher.Flush()
+		return err
+	})
+}
+
+// approve asks the session's clients to approve a tool call and waits for the
+// decision
+func (l *liveSession) approve(ctx context.Context, call ToolCall) error {
+	decision := make(chan bool, 1)
+	l.mu.Lock()
+	l.pending[call.ID] = decision
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.pending, call.ID)
+		l.mu.Unlock()
+	}()
+
+	l.add(AgentEvent{Type: EventApprovalRequest, ToolCall: &call})
+	select {
+	case approved := <-decision:
+		if !approved {
+			return deniedByUser(call)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deniedByUser is the error a tool call the user did not approve fails with
+func deniedByUser(call ToolCall) error {
+	return fmt.Errorf("%s %w by the user", call.Name, tools.ErrDenied)
+}
+
+// decide delivers the decision for a tool call awaiting approval
+func (l *liveSession) decide(id string, approved bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	decision, ok := l.pending[id]
+	if !ok {
+		return fmt.Errorf("no tool call %q is awaiting approval", id)
+	}
+	delete(l.pending, id)
+	decision <- approved
+	return nil
+}
+
+// eventIndex returns the index of the first event a stream sends: ?since=N or
+// Last-Event-ID, or by default the next new event
+func eventIndex(r *http.Request, live *liveSession) (int, error) {
+	live.mu.Lock()
+	next := len(live.events)
+	live.mu.Unlock()
+	for _, since := range []string{r.URL.Query().Get("since"), r.Header.Get("Last-Event-ID")} {
+		if since == "" {
+			continue
+		}
+		n, err := strconv.Atoi(since)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid event index %q", since)
+		}
+		next = n
+	}
+	return next, nil
+}
+
+// follow calls send for the session's events from index next on, waiting
+// for new ones, until ctx or the server is done or send fails
+func (s *apiServer) follow(ctx context.Context, live *liveSession, next int, send func(index int, e AgentEvent) error) error {
+	live.mu.Lock()
+	live.streams++
+	live.mu.Unlock()
+	defer func() {
+		live.mu.Lock()
+		live.streams--
+		live.touch()
+		live.mu.Unlock()
+	}()
+	for {
+		live.mu.Lock()
+		events := live.events[min(next, len(live.events)):]
+		changed := live.changed
+		live.mu.Unlock()
+
+		for _, e := range events {
+			if err := send(next, e); err != nil {
+				return err
+			}
+			next++
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// serverAgents returns how servers build the agent of each session:
+// configured like the CLI's, saving to the default session store, and
+// counting inference calls and tool executions in m if set. It fails early
+// if the configuration cannot build an agent.
+func serverAgents(global *globalOptions, logger *slog.Logger, m *metrics) (func() (*Agent, error), SessionStore, error) {
+	store, err := DefaultSessionStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	audit, err := defaultAuditLog(global.cfg())
+	if err != nil {
+		logger.Warn("audit log disabled", "error", err)
+	}
+	newAgent := func() (*Agent, error) {
+		agent := NewAgent(nil, nil, nil)
+		agent.logger = logger
+		agent.metrics = m
+		if _, err := agent.applyConfig(global.cfg()); err != nil {
+			return nil, err
+		}
+		agent.store = store
+		agent.audit = audit
+		return agent, nil
+	}
+	if _, err := newAgent(); err != nil {
+		return nil, nil, err
+	}
+	return newAgent, store, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func newServeCommand(global *globalOptions) *cobra.Command {
+	var addr, token string
+	var grpcAddr string
+	var requireApproval bool
+	var idleTimeout, heartbeat time.Duration
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve sessions over an HTTP API with server-sent events",
+		Long: `Serve sessions over an HTTP API, for web UIs and remote clients:
+
+  GET  /sessions                  list sessions
+  POST /sessions                  create a session
+  GET  /sessions/{id}             messages of a session
+  POST /sessions/{id}/messages    start a turn with {"content": "..."}
+  GET  /sessions/{id}/events      stream events (SSE); ?since=N replays from event N
+  POST /sessions/{id}/approvals/{call}  approve a tool call with {"approved": true}
+  GET  /sessions/{id}/ws          WebSocket: events out; messages and approvals in
+  GET  /me                        the authenticated user and their usage today
+  GET  /metrics                   Prometheus metrics
+
+With --grpc-addr, the same sessions are also served by the gRPC service
+defined in agentpb/agent.proto; clients send the same token as
+"authorization: Bearer <token>" metadata.
+
+Replies stream in as text_delta events, each a piece of the text, before the
+assistant_text event with the whole reply.
+
+With --require-approval, tools that change files or run commands wait for an
+approval_request event to be answered.
+
+Sessions with no running turn, open stream or request for --idle-timeout are
+saved and unloaded to free their memory; the next request loads them again.
+WebSocket clients are pinged every --heartbeat and disconnected when they
+stop answering.
+
+With server.users or server.oidc in the config, every request needs
+"Authorization: Bearer <token>" (or ?access_token= for browser WebSockets):
+a user's API key or an ID token from the OIDC provider. Each user sees only
+their own sessions, gets the tools their policy allows, and is refused
+inference once their daily token or cost quota is used up. GET /me shows
+the user and their usage today.
+
+Without users the server only answers requests for localhost or a loopback
+address, and every request needs "Authorization: Bearer <token>" with the
+--token given or the random one printed at startup. POST requests must have
+"Content-Type: application/json".`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			logger := global.logger(os.Stderr, slog.LevelWarn)
+			m := newMetrics()
+			newAgent, store, err := serverAgents(global, logger, m)
+			if err != nil {
+				return err
+			}
+
+			auth, err := newServerAuth(ctx, global.cfg().Server)
+			if err != nil {
+				return err
+			}
+			if auth != nil && grpcAddr != "" {
+				return fmt.Errorf("--grpc-addr has no authentication and cannot be used with server users")
+			}
+
+			api := newAPIServer(ctx, store, newAgent)
+			api.auth = auth
+			if auth == nil {
+				if token == "" {
+					if token, err = newServerToken(); err != nil {
+						return err
+					}
+				}
+				api.token = token
+			}
+			api.requireApproval = requireApproval
+			api.idleTimeout = idleTimeout
+			api.heartbeat = heartbeat
+			go api.reapIdle(ctx)
+			server := &http.Server{Addr: addr, Handler: m.serve(api.Handler())}
+			var grpcSrv *grpc.Server
+			if grpcAddr != "" {
+				listener, err := net.Listen("tcp", grpcAddr)
+				if err != nil {
+					return err
+				}
+				grpcSrv = newGRPCServer(api)
+				go grpcSrv.Serve(listener)
+				fmt.Fprintf(cmd.ErrOrStderr(), "Serving gRPC on %s\n", listener.Addr())
+			}
+			go func() {
+				<-ctx.Done()
+				shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				server.Shutdown(shutdown)
+				if grpcSrv != nil {
+					grpcSrv.Stop()
+				}
+			}()
+			fmt.Fprintf(cmd.ErrOrStderr(), "Serving on http://%s\n", addr)
+			if api.token != "" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Authorization: Bearer %s\n", api.token)
+			}
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().StringVar(&token, "token", "", "bearer token clients must send when no server users are configured (default a random one, printed at startup)")
+	cmd.Flags().StringVar(&grpcAddr, "grpc-addr", "", "also serve the gRPC API on this address")
+	cmd.Flags().BoolVar(&requireApproval, "require-approval", false, "ask clients to approve each tool call that changes state")
+	cmd.Flags().DurationVar(&idleTimeout, "idle-timeout", defaultIdleTimeout, "save and unload sessions unused for this long (0 keeps them loaded)")
+	cmd.Flags().DurationVar(&heartbeat, "heartbeat", defaultHeartbeat, "ping WebSocket clients this often and drop those that stop answering (0 disables)")
+	return cmd
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAPI 启动一个用 newAgent 创建会话的 API 服务，会话保存在临时目录
+func newTestAPI(t *testing.T, newAgent func() *Agent, requireApproval bool) (*httptest.Server, SessionStore) {
+	t.Helper()
+	store := &FileSessionStore{Dir: t.TempDir()}
+	ctx, cancel := context.WithCancel(context.Background())
+	api := newAPIServer(ctx, store, func() (*Agent, error) {
+		agent := newAgent()
+		agent.store = store
+		return agent, nil
+	})
+	api.requireApproval = requireApproval
+	server := httptest.NewServer(api.Handler())
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+	return server, store
+}
+
+func doJSON(t *testing.T, method, url, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+// readEvents 读取 SSE 事件直到 turn_done
+func readEvents(t *testing.T, url string) []AgentEvent {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := []AgentEvent{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e AgentEvent
+		require.NoError(t, json.Unmarshal([]byte(data), &e))
+		events = append(events, e)
+		if e.Type == EventTurnDone {
+			return events
+		}
+	}
+	t.Fatalf("event stream ended before turn_done: %v", scanner.Err())
+	return nil
+}
+
+func TestAPIServer(t *testing.T) {
+	t.Run("创建会话、发送消息并通过 SSE 接收事件", func(t *testing.T) {
+		server, store := newTestAPI(t, oneShotAgent, false)
+
+		var created map[string]string
+		require.Equal(t, http.StatusCreated, doJSON(t, "POST", server.URL+"/sessions", "", &created))
+		id := created["id"]
+		require.NotEmpty(t, id)
+
+		var accepted map[string]interface{}
+		require.Equal(t, http.StatusAccepted, doJSON(t, "POST", server.URL+"/sessions/"+id+"/messages", `{"content":"模块名是什么？"}`, &accepted))
+		assert.Equal(t, float64(0), accepted["events_since"])
+
+		events := readEvents(t, server.URL+"/sessions/"+id+"/events?since=0")
+		types := []string{}
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+		assert.Equal(t, []string{
+			EventUserMessage, EventUsage, EventAssistantText, EventToolCall, EventToolResult,
+			EventUsage, EventAssistantText, EventTiming, EventTurnDone,
+		}, types)
+		assert.Empty(t, events[len(events)-1].Content)
+
+		var session struct {
+			Busy     bool      `json:"busy"`
+			Messages []Message `json:"messages"`
+		}
+		require.Equal(t, http.StatusOK, doJSON(t, "GET", server.URL+"/sessions/"+id, "", &session))
+		assert.False(t, session.Busy)
+		assert.Equal(t, "模块名是 agent", session.Messages[len(session.Messages)-1].Content)
+
+		saved, err := store.Load(id)
+		require.NoError(t, err)
+		assert.Len(t, saved.Messages, len(session.Messages))
+
+		var list struct {
+			Sessions []sessionSummary `json:"sessions"`
+		}
+		require.Equal(t, http.StatusOK, doJSON(t, "GET", server.URL+"/sessions", "", &list))
+		require.Len(t, list.Sessions, 1)
+		assert.Equal(t, id, list.Sessions[0].ID)
+		assert.Equal(t, len(session.Messages), list.Sessions[0].Messages)
+	})
+
+	t.Run("SSE 流式发送回复的文本片段", func(t *testing.T) {
+		server, _ := newTestAPI(t, streamingAgent, false)
+		var created map[string]string
+		doJSON(t, "POST", server.URL+"/sessions", "", &created)
+		url := server.URL + "/sessions/" + created["id"]
+
+		doJSON(t, "POST", url+"/messages", `{"content":"你好"}`, nil)
+		var deltas []string
+		for _, e := range readEvents(t, url+"/events?since=0") {
+			if e.Type == EventTextDelta {
+				deltas = append(deltas, e.Content)
+			}
+		}
+		assert.Equal(t, []string{"你", "好"}, deltas)
+	})
+
+	t.Run("轮次出错时 turn_done 带有错误", func(t *testing.T) {
+		server, _ := newTestAPI(t, oneShotAgent, false)
+		var created map[string]string
+		doJSON(t, "POST", server.URL+"/sessions", "", &created)
+		url := server.URL + "/sessions/" + created["id"]
+
+		doJSON(t, "POST", url+"/messages", `{"content":"一"}`, nil)
+		readEvents(t, url+"/events?since=0")
+
+		// 模拟的模型已没有更多回复，第二轮失败
+		var accepted map[string]interface{}
+		doJSON(t, "POST", url+"/messages", `{"content":"二"}`, &accepted)
+		since := int(accepted["events_since"].(float64))
+		events := readEvents(t, url+"/events?since="+strconv.Itoa(since))
+		assert.Equal(t, EventUserMessage, events[0].Type)
+		assert.Equal(t, EventTurnDone, events[len(events)-1].Type)
+		assert.Contains(t, events[len(events)-1].Content, "no more responses")
+	})
+
+	t.Run("错误的请求", func(t *testing.T) {
+		server, _ := newTestAPI(t, oneShotAgent, false)
+		var created map[string]string
+		doJSON(t, "POST", server.URL+"/sessions", "", &created)
+		url := server.URL + "/sessions/" + created["id"]
+
+		var body map[string]string
+		assert.Equal(t, http.StatusNotFound, doJSON(t, "GET", server.URL+"/sessions/nope", "", &body))
+		assert.Contains(t, body["error"], "not found")
+		assert.Equal(t, http.StatusBadRequest, doJSON(t, "POST", url+"/messages", `{"content":"  "}`, &body))
+		assert.Equal(t, http.StatusBadRequest, doJSON(t, "POST", url+"/messages", `not json`, &body))
+		assert.Equal(t, http.StatusBadRequest, doJSON(t, "GET", url+"/events?since=-1", "", &body))
+		assert.Equal(t, http.StatusNotFound, doJSON(t, "DELETE", url, "", &body))
+		assert.Equal(t, http.StatusMethodNotAllowed, doJSON(t, "PUT", server.URL+"/sessions", "", &body))
+	})
+
+	t.Run("会话ID不能指向会话目录以外的文件", func(t *testing.T) {
+		dir := t.TempDir()
+		chdir(t, dir)
+		require.NoError(t, os.WriteFile("stolen.json", []byte(`{"id": "stolen", "messages": [{"role": "user", "content": "secret"}]}`), 0600))
+		server, _ := newTestAPI(t, oneShotAgent, false)
+
+		var body map[string]string
+		assert.Equal(t, http.StatusNotFound, doJSON(t, "GET", server.URL+"/sessions/stolen.json", "", &body))
+	})
+
+	t.Run("没有用户时拒绝非本机的 Host、缺少 token 和非 JSON 的 POST", func(t *testing.T) {
+		api := newAPIServer(context.Background(), &FileSessionStore{Dir: t.TempDir()}, func() (*Agent, error) { return oneShotAgent(), nil })
+		api.token = "secret"
+		handler := api.Handler()
+		do := func(host, auth, contentType, target string) int {
+			r := httptest.NewRequest("POST", target, strings.NewReader(""))
+			r.Host = host
+			if auth != "" {
+				r.Header.Set("Authorization", "Bearer "+auth)
+			}
+			if contentType != "" {
+				r.Header.Set("Content-Type", contentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusForbidden, do("evil.example:8080", "secret", "application/json", "/sessions"), "DNS rebinding")
+		assert.Equal(t, http.StatusUnauthorized, do("127.0.0.1:8080", "", "application/json", "/sessions"))
+		assert.Equal(t, http.StatusUnauthorized, do("localhost:8080", "wrong", "application/json", "/sessions"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, do("localhost:8080", "secret", "text/plain", "/sessions"), "表单可以跨站提交")
+		assert.Equal(t, http.StatusUnsupportedMediaType, do("localhost:8080", "secret", "", "/sessions"))
+		assert.Equal(t, http.StatusCreated, do("localhost:8080", "secret", "application/json; charset=utf-8", "/sessions"))
+		assert.Equal(t, http.StatusCreated, do("[::1]:8080", "", "application/json", "/sessions?access_token=secret"))
+
+		assert.True(t, loopbackHost("127.0.0.2"))
+		assert.False(t, loopbackHost("localhost.evil.example:80"))
+		token, err := newServerToken()
+		require.NoError(t, err)
+		assert.Len(t, token, 48)
+	})
+
+	t.Run("并发加载同一会话时不持锁创建代理且只保留一个", func(t *testing.T) {
+		store := &FileSessionStore{Dir: t.TempDir()}
+		saved := NewSession()
+		require.NoError(t, store.Save(saved))
+
+		started := make(chan struct{}, 2)
+		api := newAPIServer(context.Background(), store, func() (*Agent, error) {
+			// 等待另一个请求也开始创建代理；持锁创建时它会一直等到超时
+			started <- struct{}{}
+			deadline := time.Now().Add(2 * time.Second)
+			for len(started) < 2 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			assert.Len(t, started, 2, "两个请求应当同时创建代理")
+			agent := oneShotAgent()
+			agent.store = store
+			return agent, nil
+		})
+
+		results := make(chan *liveSession, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				live, err := api.live(saved.ID)
+				assert.NoError(t, err)
+				results <- live
+			}()
+		}
+		first, second := <-results, <-results
+		assert.Same(t, first, second, "同一会话只能有一个活动实例")
+		assert.Len(t, api.sessions, 1)
+	})
+
+	t.Run("需要批准时通过 HTTP 批准工具调用", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		server, _ := newTestAPI(t, editAgent, true)
+		var created map[string]string
+		doJSON(t, "POST", server.URL+"/sessions", "", &created)
+		url := server.URL + "/sessions/" + created["id"]
+
+		var body map[string]string
+		assert.Equal(t, http.StatusNotFound, doJSON(t, "POST", url+"/approvals/1", `{"approved":true}`, &body))
+
+		doJSON(t, "POST", url+"/messages", `{"content":"创建文件"}`, nil)
+		resp, err := http.Get(url + "/events?since=0")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if scanner.Text() == "event: "+EventApprovalRequest {
+				break
+			}
+		}
+		_, err = os.Stat("hello.txt")
+		assert.True(t, os.IsNotExist(err), "批准前不应执行工具")
+
+		assert.Equal(t, http.StatusOK, doJSON(t, "POST", url+"/approvals/1", `{"approved":true}`, nil))
+		events := readEvents(t, url+"/events?since=0")
+		assert.Empty(t, events[len(events)-1].Content)
+		data, err := os.ReadFile("hello.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "你好", string(data))
+	})
+}
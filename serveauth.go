@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/config"
+	"agent/tools"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// ErrQuotaExceeded is returned for inference refused because the user has
+// used up their daily quota
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// serverUser is an authenticated user of the API server, with the tools
+// they may use and what they have spent today
+type serverUser struct {
+	config.ServerUser
+	quota *quota
+}
+
+// owns reports whether the user may see session; without authentication
+// every session is visible
+func (u *serverUser) owns(session *Session) bool {
+	return u == nil || session.Owner == u.Name
+}
+
+// allowedTools returns the tools of defs the user's policy permits. Tools
+// that declare no permissions run code of unknown effect and count as exec.
+func (u *serverUser) allowedTools(defs []tools.ToolDefinition) []tools.ToolDefinition {
+	allowed := []tools.ToolDefinition{}
+	for _, def := range defs {
+		if len(u.Tools) > 0 && !slices.Contains(u.Tools, def.Name) {
+			continue
+		}
+		if len(u.Permissions) > 0 {
+			needed := def.Permissions
+			if len(needed) == 0 {
+				needed = []tools.Permission{tools.PermissionExec}
+			}
+			if slices.ContainsFunc(needed, func(p tools.Permission) bool { return !slices.Contains(u.Permissions, string(p)) }) {
+				continue
+			}
+		}
+		allowed = append(allowed, def)
+	}
+	return allowed
+}
+
+// quota counts what a user spends across all their sessions in a UTC day.
+// It lives in the server's memory, so a restart starts the day afresh.
+type quota struct {
+	maxTokens int64
+	maxCost   float64
+
+	mu     sync.Mutex
+	day    string
+	tokens int64
+	cost   float64
+}
+
+// used returns what was spent on the day of now, resetting the counts when
+// a new day has started; call with q.mu held
+func (q *quota) used(now time.Time) (int64, float64) {
+	if day := now.UTC().Format(time.DateOnly); day != q.day {
+		q.day, q.tokens, q.cost = day, 0, 0
+	}
+	return q.tokens, q.cost
+}
+
+// check returns ErrQuotaExceeded once a limit has been reached today
+func (q *quota) check(now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	tokens, cost := q.used(now)
+	if q.maxTokens > 0 && tokens >= q.maxTokens {
+		return fmt.Errorf("%w: %d/%d tokens", ErrQuotaExceeded, tokens, q.maxTokens)
+	}
+	if q.maxCost > 0 && cost >= q.maxCost {
+		return fmt.Errorf("%w: $%.4f/$%.2f", ErrQuotaExceeded, cost, q.maxCost)
+	}
+	return nil
+}
+
+func (q *quota) record(now time.Time, model string, usage Usage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.used(now)
+	q.tokens += usage.Total()
+	q.cost += estimateCost(model, usage)
+}
+
+// QuotaMiddleware refuses inference once q is used up and charges every
+// response against it
+func QuotaMiddleware(q *quota) Middleware {
+	return func(next AIProvider) AIProvider {
+		return ProviderFunc(func(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
+			if err := q.check(time.Now()); err != nil {
+				return nil, err
+			}
+			response, err := next.RunInference(ctx, conversation, tools)
+			if err != nil {
+				return nil, err
+			}
+			q.record(time.Now(), response.Model, response.Usage)
+			return response, nil
+		})
+	}
+}
+
+// serverAuth authenticates the requests to the API server with per-user API
+// keys or OIDC ID tokens
+type serverAuth struct {
+	// keys are the API keys of the users who have one
+	keys []apiKey
+	// users are the configured users by name, and the OIDC users matched by
+	// the * entry as they sign in
+	users map[string]*serverUser
+	// wildcard is the * entry, nil if there is none
+	wildcard *config.ServerUser
+	verifier *oidc.IDTokenVerifier
+	claim    string
+
+	mu sync.Mutex
+}
+
+type apiKey struct {
+	key  []byte
+	user *serverUser
+}
+
+// newServerAuth reads the users' API keys from the environment and
+// discovers the OIDC provider; it returns nil when no users are configured,
+// leaving the server open
+func newServerAuth(ctx context.Context, cfg config.Server) (*serverAuth, error) {
+	if len(cfg.Users) == 0 && cfg.OIDC.Issuer == "" {
+		return nil, nil
+	}
+	auth := &serverAuth{users: map[string]*serverUser{}, claim: cfg.OIDC.Claim}
+	for _, u := range cfg.Users {
+		if u.Name == "*" {
+			wildcard := u
+			auth.wildcard = &wildcard
+			continue
+		}
+		user := newServerUser(u)
+		auth.users[u.Name] = user
+		if u.APIKeyEnv == "" {
+			continue
+		}
+		key := os.Getenv(u.APIKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("server user %s: %s is not set", u.Name, u.APIKeyEnv)
+		}
+		auth.keys = append(auth.keys, apiKey{key: []byte(key), user: user})
+	}
+	if cfg.OIDC.Issuer != "" {
+		provider, err := oidc.NewProvider(ctx, cfg.OIDC.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: %w", err)
+		}
+		auth.verifier = provider.Verifier(&oidc.Config{ClientID: cfg.OIDC.ClientID})
+		if auth.claim == "" {
+			auth.claim = "email"
+		}
+	}
+	return auth, nil
+}
+
+func newServerUser(u config.ServerUser) *serverUser {
+	return &serverUser{ServerUser: u, quota: &quota{maxTokens: u.MaxTokensPerDay, maxCost: u.MaxCostPerDay}}
+}
+
+// authenticate returns the user a request's bearer token belongs to. Browsers
+// cannot set headers on WebSocket connections, so the token may also be given
+// as ?access_token=.
+func (a *serverAuth) authenticate(r *http.Request) (*serverUser, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, fmt.Errorf("missing bearer token")
+	}
+	var user *serverUser
+	for _, key := range a.keys {
+		// compare every key so the time taken does not reveal which one matched
+		if subtle.ConstantTimeCompare(key.key, []byte(token)) == 1 {
+			user = key.user
+		}
+	}
+	if user != nil {
+		return user, nil
+	}
+	if a.verifier == nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	idToken, err := a.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	name, _ := claims[a.claim].(string)
+	if name == "" {
+		return nil, fmt.Errorf("token has no %s claim", a.claim)
+	}
+	return a.oidcUser(name)
+}
+
+// bearerToken returns the token of a request's Authorization header, or of
+// its access_token parameter
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// oidcUser returns the configured user called name, or a user with the
+// policy of the * entry, created on their first request
+func (a *serverAuth) oidcUser(name string) (*serverUser, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if user, ok := a.users[name]; ok {
+		return user, nil
+	}
+	if a.wildcard == nil {
+		return nil, fmt.Errorf("user %s is not allowed", name)
+	}
+	u := *a.wildcard
+	u.Name = name
+	user := newServerUser(u)
+	a.users[name] = user
+	return user, nil
+}
+
+type serverUserKey struct{}
+
+// wrap rejects requests without valid credentials and passes on the user
+// of the others in their context
+func (a *serverAuth) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="agent"`)
+			writeError(w, http.StatusUnauthorized, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), serverUserKey{}, user)))
+	})
+}
+
+// requestUser returns the authenticated user of a request, nil when the
+// server has no authentication
+func requestUser(r *http.Request) *serverUser {
+	user, _ := r.Context().Value(serverUserKey{}).(*serverUser)
+	return user
+}
+
+// handleMe describes the requesting user: name, tools and what they have
+// spent today against their quota
+func (s *apiServer) handleMe(w http.ResponseWriter, r *http.Request) {
+	user := requestUser(r)
+	if user == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("the server has no users configured"))
+		return
+	}
+	user.quota.mu.Lock()
+	tokens, cost := user.quota.used(time.Now())
+	user.quota.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":               user.Name,
+		"tools":              user.Tools,
+		"permissions":        user.Permissions,
+		"tokens_today":       tokens,
+		"cost_today":         cost,
+		"max_tokens_per_day": user.MaxTokensPerDay,
+		"max_cost_per_day":   user.MaxCostPerDay,
+	})
+}
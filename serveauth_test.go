@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"agent/config"
+	"agent/tools"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthTestAPI 启动一个有 alice 和 bob 两个 API key 用户的服务
+func newAuthTestAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv("ALICE_KEY", "alice-secret")
+	t.Setenv("BOB_KEY", "bob-secret")
+	users := []config.ServerUser{{Name: "alice", APIKeyEnv: "ALICE_KEY"}, {Name: "bob", APIKeyEnv: "BOB_KEY"}}
+	auth, err := newServerAuth(context.Background(), config.Server{Users: users})
+	require.NoError(t, err)
+	store := &FileSessionStore{Dir: t.TempDir()}
+	ctx, cancel := context.WithCancel(context.Background())
+	api := newAPIServer(ctx, store, func() (*Agent, error) {
+		agent := oneShotAgent()
+		agent.store = store
+		return agent, nil
+	})
+	api.auth = auth
+	server := httptest.NewServer(api.Handler())
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+	return server
+}
+
+// doAs 以 key 认证发送请求，返回状态码并把响应解码到 out
+func doAs(t *testing.T, key, method, url string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestServerAuth(t *testing.T) {
+	server := newAuthTestAPI(t)
+
+	t.Run("没有或错误的 token 被拒绝", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, doAs(t, "", "GET", server.URL+"/sessions", nil))
+		assert.Equal(t, http.StatusUnauthorized, doAs(t, "wrong", "GET", server.URL+"/sessions", nil))
+	})
+
+	t.Run("用户只能看到自己的会话", func(t *testing.T) {
+		var created map[string]string
+		require.Equal(t, http.StatusCreated, doAs(t, "alice-secret", "POST", server.URL+"/sessions", &created))
+		id := created["id"]
+
+		assert.Equal(t, http.StatusOK, doAs(t, "alice-secret", "GET", server.URL+"/sessions/"+id, nil))
+		assert.Equal(t, http.StatusNotFound, doAs(t, "bob-secret", "GET", server.URL+"/sessions/"+id, nil), "其他用户的会话应当不存在")
+
+		var list struct{ Sessions []sessionSummary }
+		doAs(t, "bob-secret", "GET", server.URL+"/sessions", &list)
+		assert.Empty(t, list.Sessions)
+		doAs(t, "alice-secret", "GET", server.URL+"/sessions", &list)
+		require.Len(t, list.Sessions, 1)
+		assert.Equal(t, id, list.Sessions[0].ID)
+	})
+
+	t.Run("WebSocket 可以用查询参数传 token", func(t *testing.T) {
+		var me map[string]interface{}
+		assert.Equal(t, http.StatusOK, doAs(t, "", "GET", server.URL+"/me?access_token=bob-secret", &me))
+		assert.Equal(t, "bob", me["name"])
+	})
+
+	t.Run("未设置 API key 的环境变量", func(t *testing.T) {
+		_, err := newServerAuth(context.Background(), config.Server{Users: []config.ServerUser{{Name: "carol", APIKeyEnv: "CAROL_KEY_UNSET"}}})
+		assert.ErrorContains(t, err, "CAROL_KEY_UNSET")
+	})
+
+	t.Run("没有配置用户时不认证", func(t *testing.T) {
+		auth, err := newServerAuth(context.Background(), config.Server{})
+		require.NoError(t, err)
+		assert.Nil(t, auth)
+	})
+}
+
+func TestServerUserTools(t *testing.T) {
+	defs := []tools.ToolDefinition{
+		{Name: "read_file", Permissions: []tools.Permission{tools.PermissionRead}},
+		{Name: "edit_file", Permissions: []tools.Permission{tools.PermissionRead, tools.PermissionWrite}},
+		{Name: "shell", Permissions: []tools.Permission{tools.PermissionExec}},
+		{Name: "custom"},
+	}
+	names := func(defs []tools.ToolDefinition) []string {
+		var names []string
+		for _, def := range defs {
+			names = append(names, def.Name)
+		}
+		return names
+	}
+
+	t.Run("按权限筛选，没有声明权限的工具视为 exec", func(t *testing.T) {
+		user := &serverUser{ServerUser: config.ServerUser{Permissions: []string{"read", "write"}}}
+		assert.Equal(t, []string{"read_file", "edit_file"}, names(user.allowedTools(defs)))
+		user.Permissions = []string{"read", "exec"}
+		assert.Equal(t, []string{"read_file", "shell", "custom"}, names(user.allowedTools(defs)))
+	})
+
+	t.Run("按工具名单筛选", func(t *testing.T) {
+		user := &serverUser{ServerUser: config.ServerUser{Tools: []string{"shell", "read_file"}, Permissions: []string{"read"}}}
+		assert.Equal(t, []string{"read_file"}, names(user.allowedTools(defs)))
+	})
+}
+
+func TestQuota(t *testing.T) {
+	q := &quota{maxTokens: 100}
+	provider := Chain(&mockProvider{responses: []*Response{
+		{Content: "一", Usage: Usage{InputTokens: 80, OutputTokens: 30}},
+		{Content: "二"},
+	}}, QuotaMiddleware(q))
+
+	_, err := provider.RunInference(context.Background(), nil, nil)
+	require.NoError(t, err)
+	_, err = provider.RunInference(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, ErrQuotaExceeded, "当天用完配额后应当拒绝推理")
+
+	assert.NoError(t, q.check(time.Now().Add(24*time.Hour)), "第二天配额重新计算")
+}
+
+func TestServerAuthOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	require.NoError(t, err)
+	const issuer = "https://id.example.com"
+	idToken := func(email string) string {
+		claims, _ := json.Marshal(map[string]interface{}{"iss": issuer, "aud": "agent", "exp": time.Now().Add(time.Hour).Unix(), "email": email})
+		signed, err := signer.Sign(claims)
+		require.NoError(t, err)
+		token, err := signed.CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	auth := &serverAuth{
+		users:    map[string]*serverUser{"alice@example.com": newServerUser(config.ServerUser{Name: "alice@example.com", MaxTokensPerDay: 10})},
+		verifier: oidc.NewVerifier(issuer, &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&key.PublicKey}}, &oidc.Config{ClientID: "agent"}),
+		claim:    "email",
+	}
+	request := func(token string) *http.Request {
+		r := httptest.NewRequest("GET", "/sessions", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return r
+	}
+
+	t.Run("按 claim 匹配配置的用户", func(t *testing.T) {
+		user, err := auth.authenticate(request(idToken("alice@example.com")))
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), user.MaxTokensPerDay)
+	})
+
+	t.Run("未配置的用户没有 * 条目时被拒绝", func(t *testing.T) {
+		_, err := auth.authenticate(request(idToken("mallory@example.com")))
+		assert.ErrorContains(t, err, "not allowed")
+	})
+
+	t.Run("* 条目适用于其他用户，配额各自计算", func(t *testing.T) {
+		auth.wildcard = &config.ServerUser{Name: "*", Permissions: []string{"read"}}
+		user, err := auth.authenticate(request(idToken("carol@example.com")))
+		require.NoError(t, err)
+		assert.Equal(t, "carol@example.com", user.Name)
+		assert.Equal(t, []string{"read"}, user.Permissions)
+		again, err := auth.authenticate(request(idToken("carol@example.com")))
+		require.NoError(t, err)
+		assert.Same(t, user.quota, again.quota)
+	})
+
+	t.Run("签名错误的 token 被拒绝", func(t *testing.T) {
+		_, err := auth.authenticate(request(idToken("alice@example.com") + "x"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// defaultIdleTimeout is how long a server keeps a session loaded after
+	// its last request, turn or event stream
+	defaultIdleTimeout = 30 * time.Minute
+	// defaultHeartbeat is how often WebSocket clients are pinged; a client
+	// that misses two pongs is disconnected
+	defaultHeartbeat = 30 * time.Second
+)
+
+// touch marks the session as in use now; call with l.mu held
+func (l *liveSession) touch() {
+	l.lastActive = time.Now()
+}
+
+// idleSince reports whether the session has been unused since before
+// cutoff: no turn running, no event stream open and no request since
+func (l *liveSession) idleSince(cutoff time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !l.busy && l.streams == 0 && l.lastActive.Before(cutoff)
+}
+
+// evictIdle saves and unloads the sessions idle for longer than the idle
+// timeout, and returns how many it unloaded. A session whose save fails
+// stays loaded so nothing is lost; the next request loads evicted sessions
+// back from the store, with an empty event history.
+func (s *apiServer) evictIdle(now time.Time) int {
+	cutoff := now.Add(-s.idleTimeout)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	evicted := 0
+	for id, live := range s.sessions {
+		if !live.idleSince(cutoff) {
+			continue
+		}
+		if err := live.agent.saveSession(); err != nil {
+			live.agent.log().Warn("failed to save idle session", "session", id, "error", err)
+			continue
+		}
+		live.mu.Lock()
+		live.evicted = true
+		live.mu.Unlock()
+		delete(s.sessions, id)
+		evicted++
+	}
+	return evicted
+}
+
+// reapIdle evicts idle sessions periodically until ctx is done; it does
+// nothing when the idle timeout is off
+func (s *apiServer) reapIdle(ctx context.Context) {
+	if s.idleTimeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(s.idleTimeout/2, time.Minute))
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.evictIdle(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
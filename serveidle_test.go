@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIdleTestAPI 返回一个会话保存在临时目录、代理由 oneShotAgent 创建的 API 服务
+func newIdleTestAPI(t *testing.T) (*apiServer, SessionStore) {
+	t.Helper()
+	store := &FileSessionStore{Dir: t.TempDir()}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	api := newAPIServer(ctx, store, func() (*Agent, error) {
+		agent := oneShotAgent()
+		agent.store = store
+		return agent, nil
+	})
+	return api, store
+}
+
+func TestEvictIdle(t *testing.T) {
+	t.Run("保存并卸载空闲的会话，之后可以重新加载", func(t *testing.T) {
+		api, store := newIdleTestAPI(t)
+		live, err := api.live("")
+		require.NoError(t, err)
+		id := live.agent.session.ID
+		_, err = api.startTurn(live, "你好")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			live.mu.Lock()
+			defer live.mu.Unlock()
+			return !live.busy
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, 0, api.evictIdle(time.Now()), "未超过空闲时间的会话不应卸载")
+		assert.Equal(t, 1, api.evictIdle(time.Now().Add(api.idleTimeout+time.Second)))
+		assert.Empty(t, api.sessions)
+		_, err = store.Load(id)
+		assert.NoError(t, err, "卸载前应当保存会话")
+
+		_, err = api.startTurn(live, "还在吗")
+		assert.ErrorContains(t, err, "unloaded", "已卸载的会话不能再开始轮次")
+		reloaded, err := api.live(id)
+		require.NoError(t, err)
+		assert.NotSame(t, live, reloaded)
+		assert.Len(t, reloaded.messages, len(live.messages))
+	})
+
+	t.Run("有事件流的会话不会卸载", func(t *testing.T) {
+		api, _ := newIdleTestAPI(t)
+		live, err := api.live("")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			api.follow(ctx, live, 0, func(int, AgentEvent) error { return nil })
+			close(done)
+		}()
+		require.Eventually(t, func() bool {
+			live.mu.Lock()
+			defer live.mu.Unlock()
+			return live.streams == 1
+		}, time.Second, 10*time.Millisecond)
+
+		later := time.Now().Add(api.idleTimeout + time.Second)
+		assert.Equal(t, 0, api.evictIdle(later))
+		cancel()
+		<-done
+		assert.Equal(t, 1, api.evictIdle(later.Add(api.idleTimeout+time.Second)))
+	})
+
+	t.Run("关闭空闲超时时不会启动回收", func(t *testing.T) {
+		api, _ := newIdleTestAPI(t)
+		api.idleTimeout = 0
+		done := make(chan struct{})
+		go func() {
+			api.reapIdle(context.Background())
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("reapIdle should return when the idle timeout is off")
+		}
+	})
+}
+
+func TestWebSocketHeartbeat(t *testing.T) {
+	api, _ := newIdleTestAPI(t)
+	api.heartbeat = 50 * time.Millisecond
+	server := httptest.NewServer(api.Handler())
+	t.Cleanup(server.Close)
+	dial := func() (*websocket.Conn, *liveSession) {
+		live, err := api.live("")
+		require.NoError(t, err)
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/sessions/"+live.agent.session.ID+"/ws", nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn, live
+	}
+
+	t.Run("回应 ping 的客户端保持连接", func(t *testing.T) {
+		conn, live := dial()
+		var pings atomic.Int32
+		conn.SetPingHandler(func(data string) error {
+			pings.Add(1)
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+		require.Eventually(t, func() bool { return pings.Load() >= 4 }, 2*time.Second, 10*time.Millisecond)
+		live.mu.Lock()
+		defer live.mu.Unlock()
+		assert.Equal(t, 1, live.streams, "连接应当仍然打开")
+	})
+
+	t.Run("不回应的客户端被断开", func(t *testing.T) {
+		// 不读取消息的客户端不会回应 ping
+		_, live := dial()
+		streams := func() int {
+			live.mu.Lock()
+			defer live.mu.Unlock()
+			return live.streams
+		}
+		require.Eventually(t, func() bool { return streams() == 1 }, time.Second, time.Millisecond)
+		require.Eventually(t, func() bool { return streams() == 0 }, 2*time.Second, 10*time.Millisecond)
+	})
+}
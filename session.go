@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/config"
+)
+
+// Session is a persisted conversation
+type Session struct {
+	ID        string    `json:"id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	ForkedAt  int       `json:"forked_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  []Message `json:"messages"`
+	// Usage is what the session has used so far
+	Usage *SessionUsage `json:"usage,omitempty"`
+	// Pins are the files pinned with /pin
+	Pins []string `json:"pins,omitempty"`
+	// Owner is the server user who created the session; only they can see it
+	Owner string `json:"owner,omitempty"`
+}
+
+// NewSession creates an empty session with a fresh ID
+func NewSession() *Session {
+	now := time.Now()
+	return &Session{
+		ID:        newSessionID(now),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  []Message{},
+	}
+}
+
+func newSessionID(now time.Time) string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// Fork returns a new session containing the first n messages of s
+func (s *Session) Fork(n int) (*Session, error) {
+	if n < 1 || n > len(s.Messages) {
+		return nil, fmt.Errorf("message index %d out of range (1-%d)", n, len(s.Messages))
+	}
+	forked := NewSession()
+	forked.ParentID = s.ID
+	forked.ForkedAt = n
+	forked.Owner = s.Owner
+	forked.Messages = append([]Message{}, s.Messages[:n]...)
+	return forked, nil
+}
+
+// SessionStore persists sessions. FileSessionStore keeps one JSON file per
+// session; SQLiteSessionStore keeps them in one database, which suits a
+// server running many sessions at once. Another backend (e.g. Postgres for
+// server deployments) only needs these methods.
+type SessionStore interface {
+	// Save stores s, setting its UpdatedAt to now
+	Save(s *Session) error
+	// Import stores s as it is, keeping its UpdatedAt, e.g. when migrating
+	Import(s *Session) error
+	Load(id string) (*Session, error)
+	Delete(id string) error
+	// List returns all stored sessions, most recently updated first
+	List() ([]*Session, error)
+}
+
+// sessionStorage is where DefaultSessionStore keeps sessions, from the
+// sessions section of the loaded config
+var sessionStorage config.Sessions
+
+// DefaultSessionStore returns the configured store: session files under the
+// user config directory, or the SQLite database
+func DefaultSessionStore() (SessionStore, error) {
+	dir, err := agentConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	files := &FileSessionStore{Dir: filepath.Join(dir, "sessions")}
+	if sessionStorage.Backend != "sqlite" {
+		return files, nil
+	}
+	path := sessionStorage.Path
+	if path == "" {
+		path = filepath.Join(dir, "sessions.db")
+	}
+	store, err := openSQLiteSessionStore(path, files)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// FileSessionStore saves sessions as JSON files in a directory
+type FileSessionStore struct {
+	Dir string
+}
+
+func (st *FileSessionStore) path(id string) string {
+	return filepath.Join(st.Dir, id+".json")
+}
+
+// Save writes the session to disk
+func (st *FileSessionStore) Save(s *Session) error {
+	s.UpdatedAt = time.Now()
+	return st.Import(s)
+}
+
+// Import writes the session to disk as it is
+func (st *FileSessionStore) Import(s *Session) error {
+	if err := os.MkdirAll(st.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write atomically so a crash never leaves a half-written session
+	tmp := st.path(s.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save session %s: %w", s.ID, err)
+	}
+	return os.Rename(tmp, st.path(s.ID))
+}
+
+// Load reads a session by ID. IDs come from users and API clients, so
+// anything that could name a file outside Dir is rejected; use
+// LoadSessionFile for explicit paths
+func (st *FileSessionStore) Load(id string) (*Session, error) {
+	if !validSessionID(id) {
+		return nil, fmt.Errorf("invalid session ID %q", id)
+	}
+	return LoadSessionFile(st.path(id))
+}
+
+// validSessionID reports whether id is a plain file name, which covers the
+// IDs newSessionID generates
+func validSessionID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`) && filepath.Base(id) == id
+}
+
+// LoadSessionFile reads a session from an explicit file path
+func LoadSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", path, err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Delete removes a stored session
+func (st *FileSessionStore) Delete(id string) error {
+	if !validSessionID(id) {
+		return fmt.Errorf("invalid session ID %q", id)
+	}
+	if err := os.Remove(st.path(id)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("session %s not found", id)
+		}
+		return err
+	}
+	return nil
+}
+
+// List returns all stored sessions, most recently updated first
+func (st *FileSessionStore) List() ([]*Session, error) {
+	entries, err := os.ReadDir(st.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*Session{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		s, err := LoadSessionFile(filepath.Join(st.Dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
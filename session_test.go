@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionFork(t *testing.T) {
+	s := NewSession()
+	s.Messages = []Message{
+		{Role: "user", Content: "one"},
+		{Role: "assistant", Content: "two"},
+		{Role: "user", Content: "three"},
+	}
+
+	t.Run("保留前N条消息", func(t *testing.T) {
+		forked, err := s.Fork(2)
+		require.NoError(t, err)
+		assert.NotEqual(t, s.ID, forked.ID)
+		assert.Equal(t, s.ID, forked.ParentID)
+		assert.Equal(t, 2, forked.ForkedAt)
+		assert.Equal(t, s.Messages[:2], forked.Messages)
+
+		// 修改分支不影响原会话
+		forked.Messages[0].Content = "changed"
+		assert.Equal(t, "one", s.Messages[0].Content)
+	})
+
+	t.Run("越界的消息编号", func(t *testing.T) {
+		_, err := s.Fork(0)
+		assert.Error(t, err)
+		_, err = s.Fork(4)
+		assert.Error(t, err)
+	})
+}
+
+func TestSessionStore(t *testing.T) {
+	store := &FileSessionStore{Dir: filepath.Join(t.TempDir(), "sessions")}
+
+	t.Run("空目录", func(t *testing.T) {
+		sessions, err := store.List()
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
+
+	first := NewSession()
+	first.Messages = append(first.Messages, Message{Role: "user", Content: "hello"})
+	require.NoError(t, store.Save(first))
+	second, err := first.Fork(1)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(second))
+
+	t.Run("按ID加载", func(t *testing.T) {
+		loaded, err := store.Load(first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, first.Messages, loaded.Messages)
+
+		loaded, err = LoadSessionFile(filepath.Join(store.Dir, second.ID+".json"))
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, loaded.ParentID)
+	})
+
+	t.Run("拒绝路径形式的ID", func(t *testing.T) {
+		outside := filepath.Join(t.TempDir(), "outside.json")
+		require.NoError(t, os.WriteFile(outside, []byte(`{"id": "outside"}`), 0600))
+		for _, id := range []string{outside, "../sessions/" + first.ID, "..", ""} {
+			_, err := store.Load(id)
+			assert.ErrorContains(t, err, "invalid session ID", id)
+		}
+		assert.ErrorContains(t, store.Delete("../"+first.ID), "invalid session ID")
+	})
+
+	t.Run("列出会话", func(t *testing.T) {
+		sessions, err := store.List()
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, second.ID, sessions[0].ID)
+	})
+
+	t.Run("加载不存在的会话", func(t *testing.T) {
+		_, err := store.Load("missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("不会留下临时文件", func(t *testing.T) {
+		entries, err := os.ReadDir(store.Dir)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			assert.Equal(t, ".json", filepath.Ext(entry.Name()))
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"agent/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+// maxSearchMatches bounds the matches /search shows
+const maxSearchMatches = 50
+
+// sessionMatch is a message that contains the text searched for
+type sessionMatch struct {
+	session *Session
+	// index is the position of the message in the session
+	index int
+}
+
+// searchSessions finds the messages of sessions that contain query, ignoring
+// case; tool results also match on the name and input of their call. It stops
+// after max matches unless max is 0.
+func searchSessions(sessions []*Session, query string, max int) []sessionMatch {
+	query = strings.ToLower(query)
+	var matches []sessionMatch
+	for _, s := range sessions {
+		for i, msg := range s.Messages {
+			if !strings.Contains(strings.ToLower(searchableText(msg)), query) {
+				continue
+			}
+			matches = append(matches, sessionMatch{session: s, index: i})
+			if max > 0 && len(matches) == max {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// searchableText is the text of a message that searches look at
+func searchableText(msg Message) string {
+	if msg.ToolCall == nil {
+		return msg.Content
+	}
+	return msg.ToolCall.Name + " " + string(msg.ToolCall.Input) + "\n" + msg.Content
+}
+
+// writeMatches prints each match under its session and message number, with
+// up to context messages before and after it. The matching message, marked
+// with >, shows the line that matched.
+func writeMatches(w io.Writer, matches []sessionMatch, query string, context int) {
+	for i, m := range matches {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		s := m.session
+		header := fmt.Sprintf("%s · message %d", s.ID, m.index+1)
+		if meta := s.Messages[m.index].Meta; meta != nil {
+			header += " · " + formatTime(meta.Time)
+		}
+		fmt.Fprintln(w, header)
+
+		from, to := max(0, m.index-context), min(len(s.Messages), m.index+context+1)
+		for j := from; j < to; j++ {
+			msg := s.Messages[j]
+			marker, role, text := " ", msg.Role, firstLine(msg.Content)
+			if msg.ToolCall != nil {
+				role, text = "tool", msg.ToolCall.Name+" "+string(msg.ToolCall.Input)
+			}
+			if j == m.index {
+				marker, text = ">", matchingLine(searchableText(msg), query)
+			}
+			fmt.Fprintf(w, "%s %3d %-9s %s\n", marker, j+1, role, text)
+		}
+	}
+}
+
+// matchingSnippetRunes is how much of the matching line is shown
+const matchingSnippetRunes = 100
+
+// matchingLine returns the first line of text that contains query, shortened
+// around the match when it is long
+func matchingLine(text, query string) string {
+	query = strings.ToLower(query)
+	for _, line := range strings.Split(text, "\n") {
+		lower := strings.ToLower(line)
+		pos := strings.Index(lower, query)
+		if pos < 0 {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		runes := []rune(line)
+		if len(runes) <= matchingSnippetRunes {
+			return line
+		}
+		// start a little before the match so it is seen in context
+		start := max(0, utf8.RuneCountInString(lower[:pos])-matchingSnippetRunes/4)
+		prefix := ""
+		if start > 0 {
+			prefix = "…"
+		}
+		return prefix + truncate(string(runes[min(start, len(runes)):]), matchingSnippetRunes)
+	}
+	return truncate(firstLine(text), matchingSnippetRunes)
+}
+
+// searchableSessions returns the live session followed by the saved ones,
+// most recent first
+func (a *Agent) searchableSessions() ([]*Session, error) {
+	current := *a.session
+	current.Messages = a.Messages()
+	sessions := []*Session{&current}
+	if a.store == nil {
+		return sessions, nil
+	}
+	saved, err := a.store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range saved {
+		if s.ID != current.ID {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+func runSearchCommand(a *Agent, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: /search TEXT")
+	}
+	query := strings.Join(args, " ")
+	sessions, err := a.searchableSessions()
+	if err != nil {
+		return err
+	}
+	matches := searchSessions(sessions, query, maxSearchMatches)
+	if len(matches) == 0 {
+		fmt.Println(i18n.Sprintf("No matches for %q", query))
+		return nil
+	}
+	writeMatches(os.Stdout, matches, query, 1)
+	return nil
+}
+
+func newSessionsSearchCommand() *cobra.Command {
+	var context, limit int
+	cmd := &cobra.Command{
+		Use:   "search <text>...",
+		Short: "Search saved sessions for text, showing matching messages with context",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := DefaultSessionStore()
+			if err != nil {
+				return err
+			}
+			sessions, err := store.List()
+			if err != nil {
+				return err
+			}
+			query := strings.Join(args, " ")
+			matches := searchSessions(sessions, query, limit)
+			if len(matches) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), i18n.Sprintf("No matches for %q", query))
+				return nil
+			}
+			writeMatches(cmd.OutOrStdout(), matches, query, context)
+			return nil
+		},
+	}
+	cmd.Flags().IntVarP(&context, "context", "C", 1, "messages to show before and after each match")
+	cmd.Flags().IntVar(&limit, "max", maxSearchMatches, "maximum number of matches to show (0 = all)")
+	return cmd
+}
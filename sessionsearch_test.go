@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func searchTestSessions() []*Session {
+	older := NewSession()
+	older.ID = "older"
+	older.Messages = []Message{
+		{Role: "user", Content: "重命名 Login 函数"},
+		{Role: "user", Content: toolResultContent("edit_file", "OK"), ToolCall: &ToolCall{ID: "1", Name: "edit_file", Input: json.RawMessage(`{"path":"auth/login.go"}`)}},
+		{Role: "assistant", Content: "已经把 Login 改名为 SignIn"},
+	}
+	newer := NewSession()
+	newer.ID = "newer"
+	newer.Messages = []Message{
+		{Role: "user", Content: "hello"},
+		{Role: "assistant", Content: "Hi!"},
+	}
+	return []*Session{newer, older}
+}
+
+func TestSearchSessions(t *testing.T) {
+	sessions := searchTestSessions()
+
+	t.Run("忽略大小写", func(t *testing.T) {
+		matches := searchSessions(sessions, "login", 0)
+		require.Len(t, matches, 3)
+		assert.Equal(t, "older", matches[0].session.ID)
+		assert.Equal(t, []int{0, 1, 2}, []int{matches[0].index, matches[1].index, matches[2].index})
+	})
+
+	t.Run("工具结果按调用的输入匹配", func(t *testing.T) {
+		matches := searchSessions(sessions, "auth/login.go", 0)
+		require.Len(t, matches, 1)
+		assert.Equal(t, 1, matches[0].index)
+	})
+
+	t.Run("限制匹配数", func(t *testing.T) {
+		assert.Len(t, searchSessions(sessions, "login", 2), 2)
+		assert.Empty(t, searchSessions(sessions, "logout", 0))
+	})
+}
+
+func TestWriteMatches(t *testing.T) {
+	sessions := searchTestSessions()
+	var out bytes.Buffer
+	writeMatches(&out, searchSessions(sessions, "SignIn", 0), "SignIn", 1)
+	assert.Equal(t, "older · message 3\n"+
+		`    2 tool      edit_file {"path":"auth/login.go"}`+"\n"+
+		">   3 assistant 已经把 Login 改名为 SignIn\n", out.String())
+
+	t.Run("匹配在长行中间时截取附近的文本", func(t *testing.T) {
+		line := strings.Repeat("a", 200) + " needle " + strings.Repeat("b", 200)
+		snippet := matchingLine("first\n"+line, "NEEDLE")
+		assert.True(t, strings.HasPrefix(snippet, "…"), snippet)
+		assert.Contains(t, snippet, "needle")
+		assert.LessOrEqual(t, len([]rune(snippet)), matchingSnippetRunes+2)
+	})
+}
+
+func TestSessionsSearchCommand(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	store, err := DefaultSessionStore()
+	require.NoError(t, err)
+	for _, s := range searchTestSessions() {
+		require.NoError(t, store.Save(s))
+	}
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		cmd := newSessionsSearchCommand()
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		require.NoError(t, cmd.Execute())
+		return out.String()
+	}
+
+	assert.Contains(t, run("-C", "0", "SignIn"), ">   3 assistant")
+	assert.Contains(t, run("rename", "logout"), `No matches for "rename logout"`)
+
+	t.Run("/search 也搜索当前会话", func(t *testing.T) {
+		agent := NewAgent(nil, nil, nil)
+		agent.store = &FileSessionStore{Dir: filepath.Join(t.TempDir(), "sessions")}
+		agent.appendMessages(Message{Role: "user", Content: "find the SignIn handler"})
+		sessions, err := agent.searchableSessions()
+		require.NoError(t, err)
+		matches := searchSessions(sessions, "signin", 0)
+		require.Len(t, matches, 1)
+		assert.Equal(t, agent.session.ID, matches[0].session.ID)
+
+		_, err = agent.handleCommand("/search")
+		assert.ErrorContains(t, err, "usage: /search TEXT")
+	})
+}
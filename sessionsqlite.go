@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteStores shares one database handle per file, so every store opened
+// by DefaultSessionStore in a process uses the same connection pool
+var sqliteStores = struct {
+	sync.Mutex
+	m map[string]*SQLiteSessionStore
+}{m: map[string]*SQLiteSessionStore{}}
+
+// SQLiteSessionStore saves sessions as JSON documents in a SQLite database.
+// WAL mode and a busy timeout let several processes, or a server's
+// concurrent sessions, read and write at once.
+type SQLiteSessionStore struct {
+	db *sql.DB
+}
+
+const sessionsSchema = `CREATE TABLE sessions (
+	id         TEXT PRIMARY KEY,
+	updated_at INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX sessions_updated_at ON sessions (updated_at DESC);`
+
+// openSQLiteSessionStore opens the database at path, creating it when it
+// does not exist. A new database starts with the sessions in legacy, so
+// switching the backend keeps the history.
+func openSQLiteSessionStore(path string, legacy SessionStore) (*SQLiteSessionStore, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sqliteStores.Lock()
+	defer sqliteStores.Unlock()
+	if store, ok := sqliteStores.m[path]; ok {
+		return store, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	store := &SQLiteSessionStore{db: db}
+	created, err := store.migrate()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open session database %s: %w", path, err)
+	}
+	if created && legacy != nil {
+		if _, err := migrateSessions(legacy, store); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to import sessions into %s: %w", path, err)
+		}
+	}
+	sqliteStores.m[path] = store
+	return store, nil
+}
+
+// migrate creates the schema of a new database and reports whether it did
+func (st *SQLiteSessionStore) migrate() (bool, error) {
+	var n int
+	if err := st.db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'sessions'`).Scan(&n); err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return false, nil
+	}
+	_, err := st.db.Exec(sessionsSchema)
+	return err == nil, err
+}
+
+// Save stores the session
+func (st *SQLiteSessionStore) Save(s *Session) error {
+	s.UpdatedAt = time.Now()
+	return st.Import(s)
+}
+
+// Import stores the session as it is
+func (st *SQLiteSessionStore) Import(s *Session) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = st.db.Exec(`INSERT INTO sessions (id, updated_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET updated_at = excluded.updated_at, data = excluded.data`,
+		s.ID, s.UpdatedAt.UnixNano(), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save session %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// Load reads a session by ID; a path to a session file is read from disk
+func (st *SQLiteSessionStore) Load(id string) (*Session, error) {
+	if strings.HasSuffix(id, ".json") {
+		return LoadSessionFile(id)
+	}
+	var data string
+	err := st.db.QueryRow(`SELECT data FROM sessions WHERE id = ?`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("session %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+	var s Session
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// Delete removes a stored session
+func (st *SQLiteSessionStore) Delete(id string) error {
+	result, err := st.db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("session %s not found", id)
+	}
+	return nil
+}
+
+// List returns all stored sessions, most recently updated first
+func (st *SQLiteSessionStore) List() ([]*Session, error) {
+	rows, err := st.db.Query(`SELECT data FROM sessions ORDER BY updated_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []*Session{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var s Session
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			continue
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, rows.Err()
+}
+
+// migrateSessions copies the sessions in from that to does not have yet,
+// keeping their times, and returns how many it copied
+func migrateSessions(from, to SessionStore) (int, error) {
+	sessions, err := from.List()
+	if err != nil {
+		return 0, err
+	}
+	copied := 0
+	for _, s := range sessions {
+		if _, err := to.Load(s.ID); err == nil {
+			continue
+		}
+		if err := to.Import(s); err != nil {
+			return copied, err
+		}
+		copied++
+	}
+	return copied, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteSessionStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openSQLiteSessionStore(filepath.Join(dir, "sessions.db"), nil)
+	require.NoError(t, err)
+
+	t.Run("空数据库", func(t *testing.T) {
+		sessions, err := store.List()
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
+
+	first := NewSession()
+	first.Messages = append(first.Messages, Message{Role: "user", Content: "hello"})
+	require.NoError(t, store.Save(first))
+	second, err := first.Fork(1)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(second))
+
+	t.Run("保存后加载", func(t *testing.T) {
+		loaded, err := store.Load(first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, first.Messages, loaded.Messages)
+		assert.True(t, first.UpdatedAt.Equal(loaded.UpdatedAt))
+	})
+
+	t.Run("最近更新的排在前面", func(t *testing.T) {
+		require.NoError(t, store.Save(first))
+		sessions, err := store.List()
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, first.ID, sessions[0].ID)
+	})
+
+	t.Run("同一文件共用一个连接", func(t *testing.T) {
+		again, err := openSQLiteSessionStore(filepath.Join(dir, "sessions.db"), nil)
+		require.NoError(t, err)
+		assert.Same(t, store, again)
+	})
+
+	t.Run("删除会话", func(t *testing.T) {
+		require.NoError(t, store.Delete(second.ID))
+		_, err := store.Load(second.ID)
+		assert.ErrorContains(t, err, "not found")
+		assert.Error(t, store.Delete(second.ID), "删除不存在的会话应当报错")
+	})
+}
+
+func TestSQLiteSessionStoreImportsFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := &FileSessionStore{Dir: filepath.Join(dir, "sessions")}
+	old := NewSession()
+	old.Messages = append(old.Messages, Message{Role: "user", Content: "from a file"})
+	require.NoError(t, files.Save(old))
+
+	store, err := openSQLiteSessionStore(filepath.Join(dir, "sessions.db"), files)
+	require.NoError(t, err)
+
+	t.Run("新数据库导入已有的会话文件", func(t *testing.T) {
+		loaded, err := store.Load(old.ID)
+		require.NoError(t, err)
+		assert.Equal(t, old.Messages, loaded.Messages)
+		assert.True(t, old.UpdatedAt.Equal(loaded.UpdatedAt), "导入时应当保留更新时间")
+	})
+
+	t.Run("再次迁移跳过已有的会话", func(t *testing.T) {
+		newer := NewSession()
+		require.NoError(t, files.Save(newer))
+		copied, err := migrateSessions(files, store)
+		require.NoError(t, err)
+		assert.Equal(t, 1, copied)
+		sessions, err := store.List()
+		require.NoError(t, err)
+		assert.Len(t, sessions, 2)
+	})
+}
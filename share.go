@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+
+	"agent/redact"
+
+	"github.com/spf13/cobra"
+)
+
+// fileTools are the tools whose results are file contents, elided by
+// `sessions share --elide-files`
+var fileTools = map[string]bool{"read_file": true, "edit_file": true}
+
+// shareableSession returns a copy of s that is safe to paste into a bug
+// report: secrets are redacted and the home directory becomes ~. With elide,
+// file contents read or written by the tools are replaced by their size.
+func shareableSession(s *Session, elide bool) *Session {
+	home, _ := os.UserHomeDir()
+	scrub := func(text string) string {
+		text = redact.String(text)
+		if home != "" && home != "/" {
+			text = strings.ReplaceAll(text, home, "~")
+		}
+		return text
+	}
+
+	shared := &Session{ID: s.ID, ParentID: s.ParentID, ForkedAt: s.ForkedAt, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt}
+	for _, msg := range s.Messages {
+		msg.Content = scrub(msg.Content)
+		if call := msg.ToolCall; call != nil {
+			copied := *call
+			copied.Input = json.RawMessage(scrub(string(call.Input)))
+			if elide && fileTools[call.Name] {
+				copied.Input = elideEditInput(copied.Input)
+				if !msg.IsError {
+					msg.Content = elideResult(call.Name, msg.Content)
+				}
+			}
+			msg.ToolCall = &copied
+		}
+		shared.Messages = append(shared.Messages, msg)
+	}
+	return shared
+}
+
+// elideEditInput replaces the old and new text of an edit_file call
+func elideEditInput(input json.RawMessage) json.RawMessage {
+	var fields map[string]any
+	if err := json.Unmarshal(input, &fields); err != nil {
+		return input
+	}
+	for _, key := range []string{"old_str", "new_str"} {
+		if text, ok := fields[key].(string); ok && text != "" {
+			fields[key] = elided(text)
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return input
+	}
+	return data
+}
+
+// elideResult replaces the file contents (and any diff) in a tool result
+func elideResult(name, content string) string {
+	prefix := toolResultContent(name, "")
+	if !strings.HasPrefix(content, prefix) {
+		return content
+	}
+	return prefix + elided(strings.TrimPrefix(content, prefix))
+}
+
+func elided(text string) string {
+	if n := strings.Count(strings.TrimRight(text, "\n"), "\n") + 1; n > 1 {
+		return fmt.Sprintf("[%d lines elided]", n)
+	}
+	return "[1 line elided]"
+}
+
+// shareTemplate renders a session as a single HTML page with inline styles,
+// so it can be attached to an issue or opened offline
+var shareTemplate = template.Must(template.New("share").Funcs(template.FuncMap{
+	"meta":   formatMeta,
+	"pretty": func(raw json.RawMessage) string { return prettyJSON(raw) },
+	"result": func(msg Message) string {
+		return strings.TrimPrefix(msg.Content, toolResultContent(msg.ToolCall.Name, ""))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Session {{.ID}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+.message { border-left: 3px solid #d0d7de; margin: 1rem 0; padding: 0.25rem 1rem; }
+.user { border-color: #0969da; }
+.assistant { border-color: #1a7f37; }
+.tool { border-color: #8250df; }
+.error { border-color: #cf222e; }
+.role { font-weight: 600; }
+.meta { color: #656d76; font-size: 0.85em; }
+pre { background: #f6f8fa; padding: 0.75rem; overflow-x: auto; white-space: pre-wrap; }
+.text { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Session {{.ID}}</h1>
+<p class="meta">Created {{.CreatedAt.Format "2006-01-02 15:04:05"}} · {{len .Messages}} messages{{if .ParentID}} · forked from {{.ParentID}} (message {{.ForkedAt}}){{end}}</p>
+{{range .Messages}}{{if .ToolCall}}<div class="message tool{{if .IsError}} error{{end}}">
+<details>
+<summary><span class="role">Tool call: {{.ToolCall.Name}}</span>{{with meta .Meta}} <span class="meta">{{.}}</span>{{end}}</summary>
+<pre>{{pretty .ToolCall.Input}}</pre>
+<pre>{{result .}}</pre>
+</details>
+</div>
+{{else}}<div class="message {{.Role}}">
+<p><span class="role">{{if eq .Role "user"}}User{{else}}Assistant{{end}}</span>{{with meta .Meta}} <span class="meta">{{.}}</span>{{end}}</p>
+<div class="text">{{.Content}}</div>
+</div>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// ExportHTML writes the session as a self-contained HTML page
+func ExportHTML(w io.Writer, s *Session) error {
+	return shareTemplate.Execute(w, s)
+}
+
+func newSessionsShareCommand() *cobra.Command {
+	var format, output string
+	var elide bool
+	cmd := &cobra.Command{
+		Use:   "share <id>",
+		Short: "Export a session for a bug report or review, with secrets redacted",
+		Long: `Export a session as a self-contained HTML page or as Markdown ready to paste
+into a gist. API keys, tokens and other secrets are redacted and the home
+directory is shown as ~. --elide-files also leaves out file contents read or
+written by the tools.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := loadSessionArg(args[0])
+			if err != nil {
+				return err
+			}
+			if format == "" {
+				format = "md"
+				if strings.HasSuffix(strings.ToLower(output), ".html") {
+					format = "html"
+				}
+			}
+			write := ExportMarkdown
+			switch format {
+			case "md", "markdown":
+			case "html":
+				write = ExportHTML
+			default:
+				return fmt.Errorf("unknown share format %q (want html or md)", format)
+			}
+			shared := shareableSession(session, elide)
+			if output == "" {
+				return write(cmd.OutOrStdout(), shared)
+			}
+			f, err := os.Create(output)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", output, err)
+			}
+			if err := write(f, shared); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "html or md (default from -o extension, else md)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write to file instead of stdout")
+	cmd.Flags().BoolVar(&elide, "elide-files", false, "leave out file contents read or written by the tools")
+	return cmd
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareSession(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	s := NewSession()
+	s.Messages = []Message{
+		{Role: "user", Content: "my key is sk-ant-REDACTED, config in " + filepath.Join(home, "proj")},
+		{
+			Role:     "user",
+			Content:  toolResultContent("read_file", "API_TOKEN=supersecretvalue\nline 2\n"),
+			ToolCall: &ToolCall{ID: "1", Name: "read_file", Input: json.RawMessage(`{"path":".env"}`)},
+		},
+		{
+			Role:     "user",
+			Content:  toolResultContent("edit_file", "OK"),
+			ToolCall: &ToolCall{ID: "2", Name: "edit_file", Input: json.RawMessage(`{"path":"a.go","old_str":"a\nb","new_str":"<script>"}`)},
+		},
+		{Role: "assistant", Content: "Done <b>now</b>"},
+	}
+
+	t.Run("去掉密钥和主目录", func(t *testing.T) {
+		shared := shareableSession(s, false)
+		assert.Equal(t, "my key is [REDACTED:anthropic-key], config in ~/proj", shared.Messages[0].Content)
+		assert.Contains(t, shared.Messages[1].Content, "API_TOKEN=[REDACTED:secret]")
+		assert.Contains(t, s.Messages[0].Content, "sk-ant-", "不修改原会话")
+	})
+
+	t.Run("省略文件内容", func(t *testing.T) {
+		shared := shareableSession(s, true)
+		assert.Equal(t, toolResultContent("read_file", "[2 lines elided]"), shared.Messages[1].Content)
+		assert.JSONEq(t, `{"path":"a.go","old_str":"[2 lines elided]","new_str":"[1 line elided]"}`, string(shared.Messages[2].ToolCall.Input))
+		assert.Equal(t, `{"path":"a.go","old_str":"a\nb","new_str":"<script>"}`, string(s.Messages[2].ToolCall.Input), "不修改原会话")
+	})
+
+	t.Run("HTML 转义内容", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, ExportHTML(&out, shareableSession(s, false)))
+		html := out.String()
+		assert.Contains(t, html, "<title>Session "+s.ID+"</title>")
+		assert.Contains(t, html, "Done &lt;b&gt;now&lt;/b&gt;")
+		assert.Contains(t, html, "Tool call: edit_file")
+		assert.NotContains(t, html, "<script>")
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+)
+
+// maxShellEscapeOutput is how much of the output of a !!command, from its
+// end, is attached to the next message
+const maxShellEscapeOutput = 16 * 1024
+
+// parseShellEscape recognizes a REPL line that runs a command: !command
+// only shows the output, !!command also attaches it to the next message
+func parseShellEscape(line string) (command string, attach, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "!") {
+		return "", false, false
+	}
+	command, attach = strings.TrimPrefix(line, "!"), false
+	if strings.HasPrefix(command, "!") {
+		command, attach = strings.TrimPrefix(command, "!"), true
+	}
+	return strings.TrimSpace(command), attach, true
+}
+
+// runShellEscape runs a command the user typed in the workspace, on the
+// remote machine when working remotely, showing its output as it comes.
+// The model sees nothing of it unless attach is set, in which case the
+// command and its output go with the next message. The user, not the model,
+// wrote it, so only the patterns in shell.user_deny are refused. Cancelling
+// ctx stops the command.
+func (a *Agent) runShellEscape(ctx context.Context, command string, attach bool) error {
+	if command == "" {
+		return fmt.Errorf("usage: !COMMAND, or !!COMMAND to attach its output to your next message")
+	}
+	w := a.pinWorkspace()
+	w.HostShell = a.config.Shell.Program
+	input, err := json.Marshal(tools.ShellInput{Command: command})
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	_, err = w.StreamShell(ctx, tools.CommandPolicy{Deny: a.config.Shell.UserDeny, UserTyped: true}, nil, input, io.MultiWriter(os.Stdout, &output))
+	status := "exit status 0"
+	if err != nil {
+		if output.Len() == 0 && !strings.HasPrefix(err.Error(), "command failed") {
+			return err
+		}
+		status, _, _ = strings.Cut(err.Error(), ":\n")
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			status = exitErr.Error()
+		}
+		fmt.Println(theme.Warning(status))
+	}
+	if !attach {
+		return nil
+	}
+	text := output.String()
+	if len(text) > maxShellEscapeOutput {
+		text = "(earlier output omitted)\n" + strings.ToValidUTF8(text[len(text)-maxShellEscapeOutput:], "")
+	}
+	a.shellContext = append(a.shellContext, fmt.Sprintf("I ran `%s` in the workspace (%s):\n```\n%s\n```", command, status, strings.TrimRight(text, "\n")))
+	fmt.Println(theme.Muted(i18n.T("The output will be attached to your next message")))
+	return nil
+}
+
+// withShellContext attaches the output of the !!commands run since the last
+// message to the message
+func (a *Agent) withShellContext(message string) string {
+	if len(a.shellContext) == 0 {
+		return message
+	}
+	message = strings.Join(a.shellContext, "\n\n") + "\n\n" + message
+	a.shellContext = nil
+	return message
+}
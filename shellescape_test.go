@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShellEscape(t *testing.T) {
+	tests := []struct {
+		line    string
+		command string
+		attach  bool
+		ok      bool
+	}{
+		{"!git status", "git status", false, true},
+		{"  !! go test ./...", "go test ./...", true, true},
+		{"!", "", false, true},
+		{"fix the tests!", "", false, false},
+		{"/help", "", false, false},
+	}
+	for _, tt := range tests {
+		command, attach, ok := parseShellEscape(tt.line)
+		assert.Equal(t, tt.ok, ok, tt.line)
+		assert.Equal(t, tt.command, command, tt.line)
+		assert.Equal(t, tt.attach, attach, tt.line)
+	}
+}
+
+func TestShellEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用 sh 命令")
+	}
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("notes.txt", []byte("remember the milk\n"), 0644))
+
+	t.Run("!!命令的输出附在下一条消息中", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "好的"}}}
+		agent := NewAgent(provider, scriptedInput("!cat notes.txt", "!!cat notes.txt", "!!exit 3", "看看输出"), nil)
+		out := captureStdout(func() { require.NoError(t, agent.Run(context.Background())) })
+		assert.Equal(t, 2, strings.Count(out, "remember the milk"), "两条命令的输出都显示出来")
+		assert.Contains(t, out, "exit status 3")
+
+		require.Len(t, provider.calls, 1, "执行命令不会调用模型")
+		last := provider.calls[0][len(provider.calls[0])-1].Content
+		assert.Equal(t, 1, strings.Count(last, "remember the milk"), "只有 !! 命令的输出附在消息中")
+		assert.Contains(t, last, "I ran `cat notes.txt` in the workspace (exit status 0)")
+		assert.Contains(t, last, "I ran `exit 3` in the workspace (exit status 3)")
+		assert.True(t, strings.HasSuffix(last, "看看输出"))
+		assert.Empty(t, agent.shellContext, "附上后清空")
+	})
+
+	t.Run("只拒绝用户为自己的命令配置的模式", func(t *testing.T) {
+		agent := NewAgent(nil, nil, nil)
+		agent.config.Shell.Deny = []string{"cat *"}
+		agent.config.Shell.UserDeny = []string{"rm *"}
+		captureStdout(func() {
+			assert.NoError(t, agent.runShellEscape(context.Background(), "cat notes.txt", false), "模型的拒绝列表不适用")
+			assert.NoError(t, agent.runShellEscape(context.Background(), "git push", false), "内置的危险命令列表不适用")
+			assert.ErrorContains(t, agent.runShellEscape(context.Background(), "rm notes.txt", false), `"rm *"`)
+		})
+		assert.FileExists(t, "notes.txt")
+	})
+
+	t.Run("空命令", func(t *testing.T) {
+		agent := NewAgent(nil, nil, nil)
+		assert.Error(t, agent.runShellEscape(context.Background(), "", false))
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+	"github.com/spf13/cobra"
+)
+
+// Block action IDs of the approval buttons
+const (
+	slackApproveAction = "approve_tool"
+	slackDenyAction    = "deny_tool"
+)
+
+// maxSlackText is the longest text a Slack section block accepts
+const maxSlackText = 3000
+
+// slackChat is the part of the Slack Web API the bot uses
+type slackChat interface {
+	// post sends a reply in a thread and returns its timestamp
+	post(ctx context.Context, channel, thread, text string, blocks ...slack.Block) (string, error)
+	// update replaces the text of a message, removing its blocks
+	update(ctx context.Context, channel, ts, text string) error
+}
+
+// slackWebAPI implements slackChat with the Slack Web API
+type slackWebAPI struct {
+	client *slack.Client
+}
+
+func (s slackWebAPI) post(ctx context.Context, channel, thread, text string, blocks ...slack.Block) (string, error) {
+	options := []slack.MsgOption{slack.MsgOptionText(text, false), slack.MsgOptionTS(thread)}
+	if len(blocks) > 0 {
+		options = append(options, slack.MsgOptionBlocks(blocks...))
+	}
+	_, ts, err := s.client.PostMessageContext(ctx, channel, options...)
+	return ts, err
+}
+
+func (s slackWebAPI) update(ctx context.Context, channel, ts, text string) error {
+	_, _, _, err := s.client.UpdateMessageContext(ctx, channel, ts, slack.MsgOptionText(text, false), slack.MsgOptionBlocks())
+	return err
+}
+
+// slackBot drives agent sessions from Slack: each thread the bot is mentioned
+// in is a conversation of the chat bot, "channel/thread timestamp", and its
+// activity is posted as replies in the thread. Only allowed users can talk
+// to the bot.
+type slackBot struct {
+	bot  *chatBot
+	chat slackChat
+	// botUserID is the bot's own user, whose mentions are stripped from messages
+	botUserID string
+	// allowed holds the IDs of the users the bot answers
+	allowed map[string]bool
+}
+
+func newSlackBot(api *apiServer, chat slackChat, botUserID string, allowed []string, logger *slog.Logger) *slackBot {
+	s := &slackBot{chat: chat, botUserID: botUserID, allowed: map[string]bool{}}
+	for _, user := range allowed {
+		s.allowed[user] = true
+	}
+	s.bot = newChatBot(api, s, maxSlackText, logger)
+	return s
+}
+
+// run handles Socket Mode events until ctx is done
+func (s *slackBot) run(ctx context.Context, client *socketmode.Client) error {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-client.Events:
+				s.handleEvent(ctx, client, evt)
+			}
+		}
+	}()
+	err := client.RunContext(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func (s *slackBot) handleEvent(ctx context.Context, client *socketmode.Client, evt socketmode.Event) {
+	switch evt.Type {
+	case socketmode.EventTypeConnecting:
+		s.bot.logger.Info("connecting to Slack")
+	case socketmode.EventTypeConnected:
+		s.bot.logger.Info("connected to Slack")
+	case socketmode.EventTypeEventsAPI:
+		client.Ack(*evt.Request)
+		event, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			return
+		}
+		switch ev := event.InnerEvent.Data.(type) {
+		case *slackevents.AppMentionEvent:
+			s.handleMessage(ctx, ev.Channel, threadOf(ev.ThreadTimeStamp, ev.TimeStamp), ev.User, ev.Text)
+		case *slackevents.MessageEvent:
+			// mentions arrive as app_mention events too; edits and bot
+			// messages have a subtype or bot ID
+			if ev.SubType != "" || ev.BotID != "" || ev.User == s.botUserID || strings.Contains(ev.Text, "<@"+s.botUserID+">") {
+				return
+			}
+			thread := threadOf(ev.ThreadTimeStamp, ev.TimeStamp)
+			if ev.ChannelType == "im" || s.bot.session(ev.Channel+"/"+thread) != "" {
+				s.handleMessage(ctx, ev.Channel, thread, ev.User, ev.Text)
+			}
+		}
+	case socketmode.EventTypeInteractive:
+		client.Ack(*evt.Request)
+		callback, ok := evt.Data.(slack.InteractionCallback)
+		if !ok || callback.Type != slack.InteractionTypeBlockActions {
+			return
+		}
+		for _, action := range callback.ActionCallback.BlockActions {
+			s.handleAction(ctx, action.ActionID, action.Value, callback.Channel.ID, callback.Message.Timestamp, callback.Message.Text, callback.User.ID)
+		}
+	}
+}
+
+// threadOf is the thread a message belongs to: its parent, or the message
+// itself when it starts a thread
+func threadOf(threadTS, ts string) string {
+	if threadTS != "" {
+		return threadTS
+	}
+	return ts
+}
+
+// handleMessage runs a message of an allowed user, without the bot's
+// mention, in the thread's session
+func (s *slackBot) handleMessage(ctx context.Context, channel, thread, user, text string) {
+	if !s.allowed[user] {
+		return
+	}
+	s.bot.handleMessage(ctx, channel+"/"+thread, strings.ReplaceAll(text, "<@"+s.botUserID+">", ""))
+}
+
+func (s *slackBot) say(ctx context.Context, conversation, text string) error {
+	channel, thread, _ := strings.Cut(conversation, "/")
+	_, err := s.chat.post(ctx, channel, thread, text)
+	return err
+}
+
+// askApproval posts a tool call with buttons to approve or deny it
+func (s *slackBot) askApproval(ctx context.Context, conversation, text, value string) error {
+	channel, thread, _ := strings.Cut(conversation, "/")
+	buttons := slack.NewActionBlock("approval",
+		slack.NewButtonBlockElement(slackApproveAction, value, slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false)).WithStyle(slack.StylePrimary),
+		slack.NewButtonBlockElement(slackDenyAction, value, slack.NewTextBlockObject(slack.PlainTextType, "Deny", false, false)).WithStyle(slack.StyleDanger),
+	)
+	section := slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)
+	_, err := s.chat.post(ctx, channel, thread, text, section, buttons)
+	return err
+}
+
+// handleAction delivers the decision of an approval button and replaces the
+// buttons with who decided
+func (s *slackBot) handleAction(ctx context.Context, actionID, value, channel, ts, text, user string) {
+	if actionID != slackApproveAction && actionID != slackDenyAction {
+		return
+	}
+	if !s.allowed[user] {
+		s.bot.logger.Warn("approval from a user who is not allowed", "user", user)
+		return
+	}
+	approved := actionID == slackApproveAction
+	if err := s.bot.decide(value, approved); err != nil {
+		s.bot.logger.Warn("approval failed", "value", value, "error", err)
+		return
+	}
+
+	verdict := "✅ Approved"
+	if !approved {
+		verdict = "⛔ Denied"
+	}
+	if err := s.chat.update(ctx, channel, ts, fmt.Sprintf("%s\n%s by <@%s>", text, verdict, user)); err != nil {
+		s.bot.logger.Warn("updating Slack message failed", "channel", channel, "error", err)
+	}
+}
+
+func newSlackCommand(global *globalOptions) *cobra.Command {
+	var allowed []string
+	var requireApproval bool
+	cmd := &cobra.Command{
+		Use:   "slack",
+		Short: "Run a Slack bot that drives sessions from Slack threads",
+		Long: `Run a Slack bot over Socket Mode, so no public URL is needed.
+
+Mention the bot to start a session; each thread is one session, and replies
+in the thread (or direct messages) continue it. Tool calls, errors and file
+edits are posted as replies in the thread. Tools that change files or run
+commands wait for an allowed user to press Approve or Deny.
+
+The bot only answers the users given with --allow-user (member IDs, like
+U012AB3CD), since it runs commands on this machine.
+
+The bot needs an app-level token with connections:write (SLACK_APP_TOKEN)
+and a bot token with app_mentions:read, chat:write, channels:history and
+im:history (SLACK_BOT_TOKEN). Either can instead be saved with
+"agent auth login slack-app" and "agent auth login slack".`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(allowed) == 0 {
+				return fmt.Errorf("--allow-user is required: the bot runs commands on this machine")
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			appToken, _ := lookupAPIKey("slack-app", "")
+			botToken, _ := lookupAPIKey("slack", "")
+			if appToken == "" || botToken == "" {
+				return fmt.Errorf("set SLACK_APP_TOKEN and SLACK_BOT_TOKEN (or run `agent auth login slack-app` and `agent auth login slack`)")
+			}
+
+			logger := global.logger(os.Stderr, slog.LevelInfo)
+			newAgent, store, err := serverAgents(global, logger, nil)
+			if err != nil {
+				return err
+			}
+
+			client := slack.New(botToken, slack.OptionAppLevelToken(appToken))
+			auth, err := client.AuthTestContext(ctx)
+			if err != nil {
+				return fmt.Errorf("slack auth: %w", err)
+			}
+			api := newAPIServer(ctx, store, newAgent)
+			api.requireApproval = requireApproval
+			go api.reapIdle(ctx)
+			bot := newSlackBot(api, slackWebAPI{client: client}, auth.UserID, allowed, logger)
+			fmt.Fprintf(cmd.ErrOrStderr(), "Running as @%s in %s\n", auth.User, auth.Team)
+			return bot.run(ctx, socketmode.New(client))
+		},
+	}
+	cmd.Flags().StringSliceVar(&allowed, "allow-user", nil, "Slack member ID allowed to use the bot (repeatable)")
+	cmd.Flags().BoolVar(&requireApproval, "require-approval", true, "ask in the thread before each tool call that changes state")
+	return cmd
+}
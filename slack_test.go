@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSlack 记录机器人发送和更新的消息
+type fakeSlack struct {
+	mu      sync.Mutex
+	posts   []slackPost
+	updates []string
+}
+
+type slackPost struct {
+	channel, thread, text string
+	blocks                []slack.Block
+}
+
+func (f *fakeSlack) post(ctx context.Context, channel, thread, text string, blocks ...slack.Block) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, slackPost{channel: channel, thread: thread, text: text, blocks: blocks})
+	return fmt.Sprintf("1700000000.%06d", len(f.posts)), nil
+}
+
+func (f *fakeSlack) update(ctx context.Context, channel, ts, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, text)
+	return nil
+}
+
+// waitPost 等待包含 text 的消息并返回它
+func (f *fakeSlack) waitPost(t *testing.T, text string) slackPost {
+	t.Helper()
+	var found slackPost
+	require.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, post := range f.posts {
+			if strings.HasPrefix(post.text, text) {
+				found = post
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond, "no Slack post starting with %q", text)
+	return found
+}
+
+func newTestSlackBot(t *testing.T, newAgent func() *Agent, requireApproval bool) (*slackBot, *fakeSlack) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store := &FileSessionStore{Dir: t.TempDir()}
+	api := newAPIServer(ctx, store, func() (*Agent, error) {
+		agent := newAgent()
+		agent.store = store
+		return agent, nil
+	})
+	api.requireApproval = requireApproval
+	chat := &fakeSlack{}
+	return newSlackBot(api, chat, "UBOT", []string{"U1"}, slog.New(slog.NewTextHandler(io.Discard, nil))), chat
+}
+
+func TestSlackBot(t *testing.T) {
+	t.Run("提及机器人开始会话，工具活动回复在线程中", func(t *testing.T) {
+		bot, chat := newTestSlackBot(t, oneShotAgent, false)
+		bot.handleMessage(context.Background(), "C1", "100.1", "U1", "<@UBOT> 模块名是什么？")
+
+		post := chat.waitPost(t, "模块名是 agent")
+		assert.Equal(t, "C1", post.channel)
+		assert.Equal(t, "100.1", post.thread)
+		chat.waitPost(t, "🔧 `read_file`")
+		assert.NotEmpty(t, bot.bot.session("C1/100.1"))
+		assert.Empty(t, bot.bot.session("C1/200.2"))
+	})
+
+	t.Run("同一线程使用同一会话", func(t *testing.T) {
+		bot, chat := newTestSlackBot(t, oneShotAgent, false)
+		bot.handleMessage(context.Background(), "C1", "100.1", "U1", "<@UBOT> 模块名是什么？")
+		chat.waitPost(t, "模块名是 agent")
+		id := bot.bot.session("C1/100.1")
+
+		bot.handleMessage(context.Background(), "C1", "100.1", "U1", "再说一遍")
+		// mock 提供者的回复已用完，第二轮在同一会话中失败
+		chat.waitPost(t, "⚠️")
+		assert.Equal(t, id, bot.bot.session("C1/100.1"))
+	})
+
+	t.Run("按钮批准工具调用", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		bot, chat := newTestSlackBot(t, editAgent, true)
+		bot.handleMessage(context.Background(), "C1", "100.1", "U1", "<@UBOT> 创建 hello.txt")
+
+		post := chat.waitPost(t, "✋ Allow `edit_file`?")
+		require.Len(t, post.blocks, 2)
+		actions := post.blocks[1].(*slack.ActionBlock)
+		approve := actions.Elements.ElementSet[0].(*slack.ButtonBlockElement)
+		assert.Equal(t, slackApproveAction, approve.ActionID)
+
+		bot.handleAction(context.Background(), approve.ActionID, approve.Value, "C1", "100.2", post.text, "U1")
+		chat.waitPost(t, "✏️ Edited `hello.txt`")
+		chat.waitPost(t, "完成")
+		chat.mu.Lock()
+		assert.Equal(t, []string{post.text + "\n✅ Approved by <@U1>"}, chat.updates)
+		chat.mu.Unlock()
+	})
+
+	t.Run("忽略不允许的用户的消息和按钮", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		bot, chat := newTestSlackBot(t, editAgent, true)
+		bot.handleMessage(context.Background(), "C1", "200.1", "U2", "<@UBOT> 创建 hello.txt")
+		assert.Empty(t, bot.bot.session("C1/200.1"))
+
+		bot.handleMessage(context.Background(), "C1", "100.1", "U1", "<@UBOT> 创建 hello.txt")
+		post := chat.waitPost(t, "✋")
+		approve := post.blocks[1].(*slack.ActionBlock).Elements.ElementSet[0].(*slack.ButtonBlockElement)
+		bot.handleAction(context.Background(), approve.ActionID, approve.Value, "C1", "100.2", post.text, "U2")
+		chat.mu.Lock()
+		assert.Empty(t, chat.updates)
+		chat.mu.Unlock()
+		assert.NoFileExists(t, "hello.txt")
+	})
+
+	t.Run("按钮拒绝工具调用", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		bot, chat := newTestSlackBot(t, editAgent, true)
+		bot.handleMessage(context.Background(), "C1", "100.1", "U1", "<@UBOT> 创建 hello.txt")
+
+		post := chat.waitPost(t, "✋")
+		actions := post.blocks[1].(*slack.ActionBlock)
+		deny := actions.Elements.ElementSet[1].(*slack.ButtonBlockElement)
+		bot.handleAction(context.Background(), deny.ActionID, deny.Value, "C1", "100.2", post.text, "U1")
+		chat.waitPost(t, "❌ edit_file denied by the user")
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"agent/i18n"
+	"agent/theme"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+const spinnerInterval = 100 * time.Millisecond
+
+// spinner shows an animated activity line with elapsed time until stopped
+type spinner struct {
+	out      io.Writer
+	activity string
+	start    time.Time
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func startSpinner(out io.Writer, activity string) *spinner {
+	s := &spinner{
+		out:      out,
+		activity: activity,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *spinner) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		elapsed := time.Since(s.start).Seconds()
+		fmt.Fprintf(s.out, "\r\u001b[K%s", theme.Muted(fmt.Sprintf("%s %s %.1fs", spinnerFrames[frame%len(spinnerFrames)], s.activity, elapsed)))
+		select {
+		case <-s.stop:
+			fmt.Fprint(s.out, "\r\u001b[K")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop clears the spinner line; it is safe to call more than once
+func (s *spinner) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+}
+
+// showProgress reports the current activity, as a spinner in the terminal or as
+// activity events when a front-end handles events, and returns a function that ends it
+func (a *Agent) showProgress(activity string) func() {
+	if a.onEvent != nil {
+		a.emit(AgentEvent{Type: EventActivity, Content: activity})
+		return func() { a.emit(AgentEvent{Type: EventActivity}) }
+	}
+	if !a.progress {
+		return func() {}
+	}
+	return startSpinner(os.Stdout, activity).Stop
+}
+
+// toolActivity describes a tool call for the progress line, e.g. "running read_file main.go…"
+func toolActivity(call ToolCall) string {
+	if path := toolPathHint(call.Input); path != "" {
+		return i18n.Sprintf("running %s %s…", call.Name, path)
+	}
+	return i18n.Sprintf("running %s…", call.Name)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer 是可以被多个 goroutine 并发写入的缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSpinner(t *testing.T) {
+	var out syncBuffer
+	s := startSpinner(&out, "thinking…")
+	time.Sleep(2 * spinnerInterval)
+	s.Stop()
+	s.Stop()
+
+	assert.Contains(t, out.String(), "thinking…")
+	assert.Contains(t, out.String(), "s\u001b[0m")
+	assert.True(t, bytes.HasSuffix([]byte(out.String()), []byte("\r\u001b[K")), "停止后清除该行")
+}
+
+func TestToolActivity(t *testing.T) {
+	assert.Equal(t, "running read_file main.go…", toolActivity(ToolCall{Name: "read_file", Input: json.RawMessage(`{"path":"main.go"}`)}))
+	assert.Equal(t, "running list…", toolActivity(ToolCall{Name: "list", Input: json.RawMessage(`{}`)}))
+}
+
+func TestShowProgressDisabled(t *testing.T) {
+	agent := NewAgent(&mockProvider{}, nil, nil)
+	stop := agent.showProgress("thinking…")
+	stop()
+}
@@ -0,0 +1,90 @@
+package main
+
+import "agent/tools"
+
+// The methods below guard the state that front-ends read while a turn runs
+// in another goroutine: the conversation, the tools offered to the model and
+// the usage counters. Turns of one agent run one at a time (see runTurn);
+// separate agents share no state, so a server can run a turn for each of its
+// sessions at once.
+
+// Messages returns a copy of the conversation
+func (a *Agent) Messages() []Message {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Message{}, a.conversation...)
+}
+
+// appendMessages adds messages to the end of the conversation
+func (a *Agent) appendMessages(msgs ...Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.journalChange(len(a.conversation), journalEntry{Type: journalMessage, Messages: msgs})
+	a.conversation = append(a.conversation, msgs...)
+}
+
+// setConversation replaces the conversation with a copy of msgs
+func (a *Agent) setConversation(msgs []Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.conversation = append([]Message{}, msgs...)
+}
+
+// conversationLen returns the number of messages in the conversation
+func (a *Agent) conversationLen() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.conversation)
+}
+
+// truncateConversation drops the messages after the first n
+func (a *Agent) truncateConversation(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n < len(a.conversation) {
+		a.journalChange(len(a.conversation), journalEntry{Type: journalTruncate, Base: n})
+		a.conversation = a.conversation[:n]
+	}
+}
+
+// toolDefinitions returns the tools offered to the model
+func (a *Agent) toolDefinitions() []tools.ToolDefinition {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.tools
+}
+
+// setTools replaces the tools offered to the model from the next inference on
+func (a *Agent) setTools(defs []tools.ToolDefinition) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tools = defs
+}
+
+// sessionStats returns a copy of the stats shown in the status line
+func (a *Agent) sessionStats() sessionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// recordUsage adds the usage of one inference call to the stats
+func (a *Agent) recordUsage(model string, usage Usage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.Record(model, usage)
+}
+
+// recordTurn counts a turn for the usage report
+func (a *Agent) recordTurn() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usageTracker.turns++
+}
+
+// recordToolCall counts a tool call for the usage report
+func (a *Agent) recordToolCall(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usageTracker.recordTool(name)
+}
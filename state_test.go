@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"agent/pkg/provider"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentSessions(t *testing.T) {
+	// 回复最后一条用户消息；started 收到信号后等 release 关闭才返回
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	echo := provider.Func(func(ctx context.Context, conversation []Message, _ []tools.ToolDefinition) (*Response, error) {
+		last := conversation[len(conversation)-1]
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return &Response{Content: "re: " + last.Content, Model: "gpt-4o", Usage: Usage{InputTokens: 1, OutputTokens: 1}}, nil
+	})
+	newAgent := func() *Agent {
+		a := NewAgent(echo, nil, builtinTools())
+		a.onEvent = func(AgentEvent) {}
+		return a
+	}
+
+	t.Run("一轮对话进行时可以读取状态", func(t *testing.T) {
+		a := newAgent()
+		done := make(chan error)
+		go func() { done <- a.runTurn(context.Background(), "hello") }()
+		<-started
+		messages := a.Messages()
+		require.Len(t, messages, 1)
+		assert.Equal(t, "hello", messages[0].Content)
+		a.setTools(a.toolDefinitions())
+		assert.Equal(t, 1, a.usage().Turns)
+		close(release)
+		require.NoError(t, <-done)
+		assert.Len(t, a.Messages(), 2)
+		assert.Equal(t, int64(1), a.sessionStats().Usage.InputTokens)
+	})
+
+	t.Run("同一个 agent 的多轮对话依次执行", func(t *testing.T) {
+		a := newAgent()
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, a.runTurn(context.Background(), fmt.Sprint(i)))
+			}(i)
+		}
+		wg.Wait()
+		messages := a.Messages()
+		require.Len(t, messages, 10)
+		for i := 0; i < len(messages); i += 2 {
+			assert.Equal(t, "re: "+messages[i].Content, messages[i+1].Content)
+		}
+	})
+
+	t.Run("多个会话同时运行", func(t *testing.T) {
+		agents := []*Agent{newAgent(), newAgent(), newAgent()}
+		var wg sync.WaitGroup
+		for i, a := range agents {
+			wg.Add(1)
+			go func(a *Agent, i int) {
+				defer wg.Done()
+				assert.NoError(t, a.runTurn(context.Background(), fmt.Sprint("session ", i)))
+				assert.NoError(t, a.saveSession())
+			}(a, i)
+		}
+		wg.Wait()
+		for i, a := range agents {
+			assert.Equal(t, fmt.Sprint("re: session ", i), a.Messages()[1].Content)
+			assert.Len(t, a.session.Messages, 2)
+			assert.Equal(t, 1, a.usage().Turns)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"agent/i18n"
+	"agent/theme"
+)
+
+// contextWarnFraction is the share of the context window at which the status
+// line is highlighted, since the conversation will soon need compacting
+const contextWarnFraction = 0.8
+
+// sessionStats tracks what the status line reports: the current model, how
+// much of its context the conversation fills, and the session's spend
+type sessionStats struct {
+	Model string
+	// Context is the size of the last inference (prompt plus reply), i.e.
+	// roughly what the next request will send
+	Context int64
+	Usage   Usage
+	Cost    float64
+}
+
+// Record updates the stats with the usage of one inference call
+func (s *sessionStats) Record(model string, usage Usage) {
+	if model != "" {
+		s.Model = model
+	}
+	s.Context = usage.Total()
+	s.Usage.InputTokens += usage.InputTokens
+	s.Usage.OutputTokens += usage.OutputTokens
+	s.Usage.CachedTokens += usage.CachedTokens
+	s.Cost += estimateCost(model, usage)
+}
+
+// contextFraction is the share of the context window in use, or 0 if unknown
+func (s *sessionStats) contextFraction() float64 {
+	limit := contextWindow(s.Model)
+	if limit == 0 {
+		return 0
+	}
+	return float64(s.Context) / float64(limit)
+}
+
+// String formats the stats as "model · context 12.3k/200k (6%) · 45.6k tokens · $0.1234"
+func (s *sessionStats) String() string {
+	model := s.Model
+	if model == "" {
+		model = i18n.T("model pending")
+	}
+	context := i18n.T("context") + " " + formatTokens(s.Context)
+	if limit := contextWindow(s.Model); limit > 0 {
+		context += fmt.Sprintf("/%s (%.0f%%)", formatTokens(limit), s.contextFraction()*100)
+	}
+	return strings.Join([]string{
+		model,
+		context,
+		formatTokens(s.Usage.Total()) + " tokens",
+		fmt.Sprintf("$%.4f", s.Cost),
+	}, " · ")
+}
+
+// formatTokens abbreviates token counts: 950, 12.3k, 1.2M
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1000000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e6), ".0") + "M"
+	case n >= 1000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e3), ".0") + "k"
+	default:
+		return fmt.Sprint(n)
+	}
+}
+
+// printStatus shows the status line after a turn, highlighted when the
+// context is nearly full
+func (a *Agent) printStatus() {
+	stats := a.sessionStats()
+	line := stats.String()
+	if stats.contextFraction() >= contextWarnFraction {
+		fmt.Println(theme.Warning(line + " · " + i18n.T("context nearly full")))
+		return
+	}
+	fmt.Println(theme.Muted(line))
+}
+
+func runStatusCommand(a *Agent, args []string) error {
+	stats := a.sessionStats()
+	fmt.Println(stats.String())
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatTokens(t *testing.T) {
+	assert.Equal(t, "950", formatTokens(950))
+	assert.Equal(t, "12.3k", formatTokens(12345))
+	assert.Equal(t, "200k", formatTokens(200000))
+	assert.Equal(t, "1.2M", formatTokens(1234567))
+}
+
+func TestSessionStats(t *testing.T) {
+	t.Run("上下文取最近一次调用，用量累加", func(t *testing.T) {
+		var stats sessionStats
+		stats.Record("claude-3-7-sonnet-20250219", Usage{InputTokens: 10000, OutputTokens: 500})
+		stats.Record("claude-3-7-sonnet-20250219", Usage{InputTokens: 20000, OutputTokens: 1000})
+
+		assert.Equal(t, int64(21000), stats.Context)
+		assert.Equal(t, int64(31500), stats.Usage.Total())
+		assert.Equal(t, "claude-3-7-sonnet-20250219 · context 21k/200k (10%) · 31.5k tokens · $0.1125", stats.String())
+	})
+
+	t.Run("未知模型不显示上限", func(t *testing.T) {
+		var stats sessionStats
+		assert.Equal(t, "model pending · context 0 · 0 tokens · $0.0000", stats.String())
+
+		stats.Record("local-llm", Usage{InputTokens: 100})
+		assert.Equal(t, "local-llm · context 100 · 100 tokens · $0.0000", stats.String())
+		assert.Zero(t, stats.contextFraction())
+	})
+}
+
+func TestRunPrintsStatusAfterTurn(t *testing.T) {
+	provider := &mockProvider{responses: []*Response{{Content: "好的", Model: "gpt-4o", Usage: Usage{InputTokens: 120000, OutputTokens: 100}}}}
+	agent := NewAgent(provider, scriptedInput("你好"), nil)
+	agent.showStatus = true
+
+	out := captureStdout(func() { require.NoError(t, agent.Run(context.Background())) })
+	assert.Contains(t, out, "gpt-4o · context 120.1k/128k (94%)")
+	assert.Contains(t, out, "context nearly full")
+}
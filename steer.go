@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+
+	"agent/i18n"
+	"agent/pkg/message"
+	"agent/theme"
+)
+
+// steeredToolContent is the result of a tool call skipped because the user
+// sent a message before it ran
+const steeredToolContent = "Not run: the user sent a message before this tool ran. Read it and decide what to do next."
+
+// steer sends a message to the running turn without cancelling it: the model
+// reads it at the next inference step, and the tool calls of the current step
+// that have not run yet are skipped so it can change course. It is safe to
+// call while runTurn runs in another goroutine.
+func (a *Agent) steer(text string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.steering = append(a.steering, text)
+}
+
+// takeSteering removes and returns the messages sent with steer
+func (a *Agent) takeSteering() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	steering := a.steering
+	a.steering = nil
+	return steering
+}
+
+// steerPrefix starts a line typed at the REPL during a turn that steers it;
+// other lines wait as follow-ups and do not stop the tools the model asked for
+const steerPrefix = ">>"
+
+// steeringText returns the message of a REPL line that starts with
+// steerPrefix
+func steeringText(line string) (string, bool) {
+	text, ok := strings.CutPrefix(strings.TrimSpace(line), steerPrefix)
+	return strings.TrimSpace(text), ok
+}
+
+// isSteeringLine reports whether a queued REPL line steers the turn
+func isSteeringLine(line string) bool {
+	text, ok := steeringText(line)
+	return ok && text != ""
+}
+
+// steered reports whether a steering message is waiting: one sent with steer,
+// or a line typed at the REPL during the turn with steerPrefix
+func (a *Agent) steered() bool {
+	return a.hasSteering() || a.input != nil && a.input.Pending(isSteeringLine)
+}
+
+// skipToolCalls answers the tool calls the model asked for before the user
+// steered the turn, so every call still has a result
+func (a *Agent) skipToolCalls(calls []ToolCall) {
+	for _, call := range calls {
+		call := call
+		a.appendMessages(Message{Role: "user", Content: steeredToolContent, ToolCall: &call, IsError: true, Meta: message.Now()})
+	}
+	a.emit(AgentEvent{Type: EventNotice, Content: i18n.Sprintf("%s: skipped %d tool calls so the model reads your message first", theme.Warning(i18n.T("Steering")), len(calls))})
+}
+
+// hasSteering reports whether a message sent with steer is waiting
+func (a *Agent) hasSteering() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.steering) > 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSteer(t *testing.T) {
+	// 模型按顺序给出 responses，第 steerAt 次推理时用户插话
+	run := func(t *testing.T, steerAt int, responses ...*Response) (*Agent, [][]Message, []AgentEvent) {
+		var calls [][]Message
+		var agent *Agent
+		agent = NewAgent(ProviderFunc(func(_ context.Context, conversation []Message, _ []tools.ToolDefinition) (*Response, error) {
+			calls = append(calls, append([]Message{}, conversation...))
+			if len(calls) == steerAt {
+				agent.steer("stop editing main.go, focus on the tests")
+			}
+			return responses[len(calls)-1], nil
+		}), nil, builtinTools())
+		var events []AgentEvent
+		agent.onEvent = func(e AgentEvent) { events = append(events, e) }
+		require.NoError(t, agent.runTurn(context.Background(), "fix the bug"))
+		require.Len(t, calls, len(responses))
+		return agent, calls, events
+	}
+
+	t.Run("跳过剩下的工具调用，下一步读到插话", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		_, calls, events := run(t, 1,
+			&Response{ToolCalls: []ToolCall{
+				{ID: "1", Name: "edit_file", Input: json.RawMessage(`{"path":"main.go","old_str":"","new_str":"x"}`)},
+				{ID: "2", Name: "edit_file", Input: json.RawMessage(`{"path":"main.go","old_str":"x","new_str":"y"}`)},
+			}},
+			&Response{Content: "OK, looking at the tests."},
+		)
+		second := calls[1]
+		require.GreaterOrEqual(t, len(second), 3)
+		results := second[len(second)-3 : len(second)-1]
+		for _, msg := range results {
+			require.NotNil(t, msg.ToolCall, "每个工具调用都有结果")
+			assert.True(t, msg.IsError)
+			assert.Equal(t, steeredToolContent, msg.Content)
+		}
+		assert.Equal(t, "stop editing main.go, focus on the tests", second[len(second)-1].Content)
+		assert.NoFileExists(t, "main.go")
+
+		var notices, messages int
+		for _, e := range events {
+			switch e.Type {
+			case EventNotice:
+				notices++
+				assert.Contains(t, e.Content, "skipped 2 tool calls")
+			case EventUserMessage:
+				messages++
+			}
+		}
+		assert.Equal(t, 1, notices)
+		assert.Equal(t, 1, messages)
+	})
+
+	t.Run("最后一次推理时插话，回合继续", func(t *testing.T) {
+		agent, calls, _ := run(t, 1,
+			&Response{Content: "Done editing main.go."},
+			&Response{Content: "OK, the tests instead."},
+		)
+		second := calls[1]
+		assert.Equal(t, "stop editing main.go, focus on the tests", second[len(second)-1].Content)
+		assert.Equal(t, "OK, the tests instead.", agent.conversation[len(agent.conversation)-1].Content)
+		assert.Empty(t, agent.takeSteering())
+	})
+
+	t.Run("没有插话时照常运行工具", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{}, nil, nil)
+		assert.False(t, agent.steered())
+		agent.input = &inputQueue{pending: []string{"/history", "also the docs", ">>"}}
+		assert.False(t, agent.steered(), "排队的命令和普通消息都不算插话")
+		agent.input.pending = append(agent.input.pending, ">> focus on the tests")
+		assert.True(t, agent.steered())
+	})
+}
+
+func TestQueuedLinesDuringTurn(t *testing.T) {
+	// 第一次推理时用户在 REPL 输入 line，模型要求连续两次写文件
+	run := func(t *testing.T, line string) (*Agent, [][]Message) {
+		chdir(t, t.TempDir())
+		var calls [][]Message
+		var agent *Agent
+		responses := []*Response{
+			{ToolCalls: []ToolCall{
+				{ID: "1", Name: "edit_file", Input: json.RawMessage(`{"path":"a.txt","old_str":"","new_str":"a"}`)},
+				{ID: "2", Name: "edit_file", Input: json.RawMessage(`{"path":"b.txt","old_str":"","new_str":"b"}`)},
+			}},
+			{Content: "Done."},
+		}
+		agent = NewAgent(ProviderFunc(func(_ context.Context, conversation []Message, _ []tools.ToolDefinition) (*Response, error) {
+			calls = append(calls, append([]Message{}, conversation...))
+			if len(calls) == 1 {
+				agent.input.mu.Lock()
+				agent.input.pending = append(agent.input.pending, line)
+				agent.input.mu.Unlock()
+			}
+			return responses[len(calls)-1], nil
+		}), nil, builtinTools())
+		agent.input = &inputQueue{}
+		require.NoError(t, agent.runTurn(context.Background(), "write the files"))
+		require.Len(t, calls, 2)
+		return agent, calls
+	}
+
+	t.Run("普通的排队消息不跳过工具调用", func(t *testing.T) {
+		_, calls := run(t, "also update the docs")
+		assert.FileExists(t, "a.txt")
+		assert.FileExists(t, "b.txt")
+		second := calls[1]
+		assert.Equal(t, "also update the docs", second[len(second)-1].Content, "下一步读到排队的消息")
+		for _, msg := range second[len(second)-3 : len(second)-1] {
+			require.NotNil(t, msg.ToolCall)
+			assert.False(t, msg.IsError)
+		}
+	})
+
+	t.Run("带前缀的行插话并跳过剩下的工具调用", func(t *testing.T) {
+		_, calls := run(t, ">> stop, only a.txt")
+		assert.NoFileExists(t, "a.txt")
+		assert.NoFileExists(t, "b.txt")
+		second := calls[1]
+		assert.Equal(t, "stop, only a.txt", second[len(second)-1].Content, "去掉插话前缀")
+		assert.Equal(t, steeredToolContent, second[len(second)-2].Content)
+	})
+}
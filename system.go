@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/pkg/provider"
+	"agent/search"
+	"agent/theme"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// maxRepoMapFiles is the most files the repo map lists
+const maxRepoMapFiles = 300
+
+// promptSection is a part of the system prompt, sent as a system message of
+// its own ahead of the conversation
+type promptSection struct {
+	name    string
+	content string
+	// disabled sections are shown by /system but not sent
+	disabled bool
+}
+
+// loadSystemSections reads the sections the config adds or overrides, by name
+func loadSystemSections(cfg *config.Config) (map[string]string, error) {
+	sections := map[string]string{}
+	for _, section := range cfg.System.Sections {
+		content := section.Text
+		if section.File != "" {
+			path, err := cfg.ResolvePath(section.File)
+			if err != nil {
+				return nil, err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("system section %s: %w", section.Name, err)
+			}
+			content = string(data)
+		}
+		sections[section.Name] = strings.TrimSpace(content)
+	}
+	return sections, nil
+}
+
+// repoMap lists the files of the workspace index for the repo_map section,
+// leaving out hidden directories and node_modules. The index follows the
+// changes to the workspace, so the map is current on every request.
+func repoMap(index *search.Index) string {
+	if index == nil {
+		return ""
+	}
+	var files []string
+	for _, file := range index.Files() {
+		if !skipRepoMapPath(file) {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Files in the workspace:\n")
+	for i, file := range files {
+		if i == maxRepoMapFiles {
+			fmt.Fprintf(&b, "… and %d more\n", len(files)-maxRepoMapFiles)
+			break
+		}
+		b.WriteString(file + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// skipRepoMapPath reports whether file is in a directory the repo map leaves out
+func skipRepoMapPath(file string) bool {
+	dirs := strings.Split(file, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if strings.HasPrefix(dir, ".") || dir == "node_modules" {
+			return true
+		}
+	}
+	return false
+}
+
+// systemSections returns the sections of the system prompt in the configured
+// order: the built-in ones, each replaced by a configured section of the same
+// name, then the sections the config adds
+func (a *Agent) systemSections() []promptSection {
+	cfg := a.config
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	names := append([]string{}, cfg.System.Order...)
+	for _, name := range config.SystemSections {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, section := range cfg.System.Sections {
+		if !slices.Contains(names, section.Name) {
+			names = append(names, section.Name)
+		}
+	}
+
+	sections := make([]promptSection, 0, len(names))
+	for _, name := range names {
+		content, ok := a.customSections[name]
+		if !ok {
+			switch name {
+			case "repo_map":
+				content = repoMap(a.repoIndex)
+			case "instructions":
+				content = a.instructions
+			case "knowledge":
+				content = a.knowledge
+			case "git":
+				content = a.currentGitContext()
+			case "pinned":
+				content = a.pinnedContext()
+			}
+		}
+		sections = append(sections, promptSection{name: name, content: content, disabled: slices.Contains(cfg.System.Disabled, name)})
+	}
+	return sections
+}
+
+// runSystemCommand shows the system prompt section by section, with the
+// tokens each takes; with a name it shows only that section
+func runSystemCommand(a *Agent, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /system [SECTION]")
+	}
+	sections := a.systemSections()
+	if len(args) == 1 {
+		var selected []promptSection
+		for _, section := range sections {
+			if section.name == args[0] {
+				selected = append(selected, section)
+			}
+		}
+		if len(selected) == 0 {
+			names := make([]string, len(sections))
+			for i, section := range sections {
+				names[i] = section.name
+			}
+			return fmt.Errorf("unknown section %q (use one of %s)", args[0], strings.Join(names, ", "))
+		}
+		sections = selected
+	}
+	for _, section := range sections {
+		var state string
+		switch {
+		case section.disabled:
+			state = i18n.T("disabled")
+		case section.content == "":
+			state = i18n.T("empty")
+		default:
+			n, _, err := provider.Tiktoken(tiktoken.MODEL_CL100K_BASE, []Message{{Role: "system", Content: section.content}}, nil)
+			if err != nil {
+				return err
+			}
+			state = i18n.Sprintf("~%s tokens", formatTokens(n))
+		}
+		fmt.Println(theme.Tool("── "+section.name) + " " + theme.Muted("("+state+")"))
+		if section.content != "" && !section.disabled {
+			fmt.Println(section.content)
+			fmt.Println()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent/config"
+	"agent/search"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemSections(t *testing.T) {
+	root := t.TempDir()
+	chdir(t, root)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "STYLE.md"), []byte("Wrap at 80 columns.\n"), 0644))
+
+	newAgent := func(t *testing.T, system config.System) *Agent {
+		cfg := config.Default()
+		cfg.ProjectDir = root
+		cfg.System = system
+		require.NoError(t, cfg.Validate())
+		sections, err := loadSystemSections(cfg)
+		require.NoError(t, err)
+		agent := NewAgent(&mockProvider{}, nil, nil)
+		agent.config, agent.customSections = cfg, sections
+		agent.instructions = "Instructions from AGENTS.md:\n\nUse testify."
+		agent.knowledge = "## Gotchas\n- Tests need Docker."
+		agent.appendMessages(Message{Role: "user", Content: "hi"})
+		return agent
+	}
+	contents := func(conversation []Message) []string {
+		var system []string
+		for _, msg := range conversation {
+			if msg.Role == "system" {
+				system = append(system, msg.Content)
+			}
+		}
+		return system
+	}
+
+	t.Run("默认只发送有内容的部分", func(t *testing.T) {
+		agent := newAgent(t, config.System{})
+		conversation := agent.requestConversation()
+		assert.Equal(t, []string{agent.instructions, agent.knowledge}, contents(conversation))
+		assert.Equal(t, "hi", conversation[len(conversation)-1].Content)
+	})
+
+	t.Run("添加、覆盖、排序和关闭部分", func(t *testing.T) {
+		agent := newAgent(t, config.System{
+			Order:    []string{"knowledge", "style"},
+			Disabled: []string{"instructions"},
+			Sections: []config.SystemSection{
+				{Name: "persona", Text: "You are a careful Go reviewer."},
+				{Name: "style", File: "STYLE.md"},
+			},
+		})
+		assert.Equal(t, []string{agent.knowledge, "Wrap at 80 columns.", "You are a careful Go reviewer."}, contents(agent.requestConversation()))
+	})
+
+	t.Run("列出工作区的文件", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "cmd"), 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(root, ".cache"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package main\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".cache", "x"), []byte("x"), 0644))
+		index, err := search.NewIndex(context.Background(), root)
+		require.NoError(t, err)
+		require.NoError(t, index.Watch())
+		defer index.Close()
+		assert.Equal(t, "Files in the workspace:\nSTYLE.md\ncmd/main.go", repoMap(index), "跳过隐藏目录")
+
+		agent := newAgent(t, config.System{RepoMap: true})
+		agent.repoIndex = index
+		assert.Equal(t, repoMap(index), contents(agent.requestConversation())[0], "repo_map 排在指令之前")
+
+		require.NoError(t, os.WriteFile(filepath.Join(root, "cmd", "flags.go"), []byte("package main\n"), 0644))
+		assert.Eventually(t, func() bool {
+			return contents(agent.requestConversation())[0] == "Files in the workspace:\nSTYLE.md\ncmd/flags.go\ncmd/main.go"
+		}, time.Second, 10*time.Millisecond, "新建的文件出现在下一次请求中")
+	})
+
+	t.Run("/system 逐部分显示", func(t *testing.T) {
+		agent := newAgent(t, config.System{Disabled: []string{"knowledge"}})
+		out := captureStdout(func() { require.NoError(t, runSystemCommand(agent, nil)) })
+		out = ansiPattern.ReplaceAllString(out, "")
+		assert.Contains(t, out, "── persona (empty)")
+		assert.Regexp(t, `── instructions \(~\d+ tokens\)\nInstructions from AGENTS.md`, out)
+		assert.Contains(t, out, "── knowledge (disabled)")
+		assert.NotContains(t, out, "Tests need Docker", "关闭的部分不显示内容")
+		assert.Less(t, strings.Index(out, "── persona"), strings.Index(out, "── pinned"))
+
+		out = captureStdout(func() { require.NoError(t, runSystemCommand(agent, []string{"instructions"})) })
+		assert.NotContains(t, out, "persona")
+		assert.ErrorContains(t, runSystemCommand(agent, []string{"memory"}), "unknown section")
+	})
+}
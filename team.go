@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"agent/config"
+	"agent/gitutil"
+	agentlib "agent/pkg/agent"
+	"agent/tools"
+
+	"github.com/spf13/cobra"
+)
+
+// Team roles, in the order they work on the goal
+const (
+	rolePlanner     = "planner"
+	roleImplementer = "implementer"
+	roleReviewer    = "reviewer"
+)
+
+// Built-in system prompts of the roles, used when the config sets none
+const (
+	plannerPrompt     = `You are the planner of a team of coding agents. Study the repository with your tools, then break the user's goal into a short sequence of concrete, independently reviewable tasks and add each to the task board with add_task, in the order they should be done. Do not change any files. Finish with a one-paragraph summary of the plan.`
+	implementerPrompt = `You are the implementer of a team of coding agents. You get one task from the task board at a time: make the code changes it asks for with your tools, keeping them focused on the task, and check they build and pass the tests. When a reviewer sends the task back, address every point of the feedback. Finish with a short summary of what you changed.`
+	reviewerPrompt    = `You are the reviewer of a team of coding agents. Check the implementer's changes for the task below against its description: look for bugs, missing error handling, missing tests and changes the task did not ask for. Use your tools to read the code. Then call review_task exactly once: approve the task, or send it back with specific, actionable feedback.`
+)
+
+// Statuses of the tasks on the board
+const (
+	boardTodo       = "todo"
+	boardReview     = "in_review"
+	boardDone       = "done"
+	boardUnresolved = "unresolved"
+)
+
+// boardTask is a unit of work on the task board
+type boardTask struct {
+	ID          int
+	Title       string
+	Description string
+	Status      string
+	// Notes are what the roles reported while working on the task
+	Notes []string
+}
+
+// taskBoard is the shared state the roles coordinate through: the planner
+// adds tasks, the implementer works through them and the reviewer decides
+// when each is done
+type taskBoard struct {
+	mu    sync.Mutex
+	tasks []*boardTask
+}
+
+func (b *taskBoard) add(title, description string) *boardTask {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	task := &boardTask{ID: len(b.tasks) + 1, Title: title, Description: description, Status: boardTodo}
+	b.tasks = append(b.tasks, task)
+	return task
+}
+
+// update changes a task's status, noting what role reported
+func (b *taskBoard) update(task *boardTask, status, role, note string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	task.Status = status
+	if note = strings.TrimSpace(note); note != "" {
+		task.Notes = append(task.Notes, role+": "+note)
+	}
+}
+
+// list returns the tasks themselves, for the team to update
+func (b *taskBoard) list() []*boardTask {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.tasks)
+}
+
+// Tasks returns copies of the tasks, in the order they were added
+func (b *taskBoard) Tasks() []boardTask {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tasks := make([]boardTask, len(b.tasks))
+	for i, task := range b.tasks {
+		tasks[i] = *task
+		tasks[i].Notes = slices.Clone(task.Notes)
+	}
+	return tasks
+}
+
+// String renders the board for the roles and the final report
+func (b *taskBoard) String() string {
+	tasks := b.Tasks()
+	if len(tasks) == 0 {
+		return "The task board is empty."
+	}
+	var s strings.Builder
+	for _, task := range tasks {
+		fmt.Fprintf(&s, "#%d [%s] %s\n", task.ID, task.Status, task.Title)
+		if task.Description != "" {
+			fmt.Fprintf(&s, "    %s\n", strings.ReplaceAll(strings.TrimSpace(task.Description), "\n", "\n    "))
+		}
+		for _, note := range task.Notes {
+			fmt.Fprintf(&s, "    - %s\n", truncate(strings.ReplaceAll(note, "\n", " "), 200))
+		}
+	}
+	return strings.TrimRight(s.String(), "\n")
+}
+
+// addTaskInput is the input of the planner's add_task tool
+type addTaskInput struct {
+	Title       string `json:"title" jsonschema_description:"A short title for the task."`
+	Description string `json:"description" jsonschema_description:"What to change and how to tell it is done, for an implementer who has not seen the plan."`
+}
+
+// reviewTaskInput is the input of the reviewer's review_task tool
+type reviewTaskInput struct {
+	Approved bool   `json:"approved" jsonschema_description:"Whether the task is done as described."`
+	Feedback string `json:"feedback" jsonschema_description:"What must change before the task is approved, or a short note when approving."`
+}
+
+// reviewVerdict is what the reviewer decided about the task under review
+type reviewVerdict struct {
+	given    bool
+	approved bool
+	feedback string
+}
+
+func viewBoardTool(board *taskBoard) tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:        "view_board",
+		Description: "Show the team's task board: every task with its status and the notes of the roles.",
+		InputSchema: tools.GenerateSchema[struct{}](),
+		ReadOnly:    true,
+		Function: func(context.Context, json.RawMessage) tools.ToolResult {
+			return tools.ToolResult{Text: board.String()}
+		},
+	}
+}
+
+func addTaskTool(board *taskBoard) tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:        "add_task",
+		Description: "Add a task to the end of the team's task board for the implementer.",
+		InputSchema: tools.GenerateSchema[addTaskInput](),
+		Function: func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+			var params addTaskInput
+			if err := json.Unmarshal(input, &params); err != nil {
+				return tools.ErrorResult(fmt.Errorf("failed to parse input: %w", err))
+			}
+			if strings.TrimSpace(params.Title) == "" {
+				return tools.ErrorResult(fmt.Errorf("the task needs a title"))
+			}
+			task := board.add(strings.TrimSpace(params.Title), params.Description)
+			return tools.ToolResult{Text: fmt.Sprintf("Added task #%d", task.ID)}
+		},
+	}
+}
+
+func reviewTaskTool(verdict *reviewVerdict) tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:        "review_task",
+		Description: "Approve the task under review, or send it back to the implementer with feedback.",
+		InputSchema: tools.GenerateSchema[reviewTaskInput](),
+		Function: func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+			var params reviewTaskInput
+			if err := json.Unmarshal(input, &params); err != nil {
+				return tools.ErrorResult(fmt.Errorf("failed to parse input: %w", err))
+			}
+			if !params.Approved && strings.TrimSpace(params.Feedback) == "" {
+				return tools.ErrorResult(fmt.Errorf("say what must change when sending the task back"))
+			}
+			*verdict = reviewVerdict{given: true, approved: params.Approved, feedback: params.Feedback}
+			return tools.ToolResult{Text: "Recorded the review"}
+		},
+	}
+}
+
+// team is the set of role agents working on a goal; Planner and Reviewer
+// are nil when the config disables them
+type team struct {
+	Planner, Implementer, Reviewer *agentlib.Agent
+	Board                          *taskBoard
+	// verdict is where the reviewer's review_task call is recorded
+	verdict *reviewVerdict
+	// Rounds bounds how often a task is reviewed before it is left unresolved
+	Rounds int
+	// diff returns the uncommitted changes the reviewer is shown; nil
+	// outside a git repository
+	diff func() string
+}
+
+// run works on goal until every task on the board is done or unresolved
+func (t *team) run(ctx context.Context, goal string, out io.Writer) error {
+	if t.Planner != nil {
+		fmt.Fprintf(out, "== %s\n", rolePlanner)
+		if _, err := t.Planner.Send(ctx, "Goal:\n"+goal); err != nil {
+			return fmt.Errorf("%s: %w", rolePlanner, err)
+		}
+	}
+	if len(t.Board.Tasks()) == 0 {
+		// without a plan, the goal is the only task
+		t.Board.add(firstLine(goal), goal)
+	}
+
+	for _, task := range t.Board.list() {
+		if err := t.work(ctx, goal, task, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// work has the implementer do a task and the reviewer check it, sending it
+// back with the feedback up to Rounds times
+func (t *team) work(ctx context.Context, goal string, task *boardTask, out io.Writer) error {
+	// every task starts from a fresh conversation; the board carries what
+	// earlier tasks did
+	t.Implementer.Reset()
+	prompt := fmt.Sprintf("Overall goal:\n%s\n\nTask board:\n%s\n\nDo task #%d: %s\n%s", goal, t.Board, task.ID, task.Title, task.Description)
+	for round := 1; ; round++ {
+		fmt.Fprintf(out, "== %s: task #%d %s (round %d)\n", roleImplementer, task.ID, task.Title, round)
+		reply, err := t.Implementer.Send(ctx, prompt)
+		if err != nil {
+			return fmt.Errorf("%s on task #%d: %w", roleImplementer, task.ID, err)
+		}
+		if t.Reviewer == nil {
+			t.Board.update(task, boardDone, roleImplementer, reply.Content)
+			return nil
+		}
+		t.Board.update(task, boardReview, roleImplementer, reply.Content)
+
+		fmt.Fprintf(out, "== %s: task #%d %s (round %d)\n", roleReviewer, task.ID, task.Title, round)
+		*t.verdict = reviewVerdict{}
+		t.Reviewer.Reset()
+		review := fmt.Sprintf("Task #%d: %s\n%s\n\nThe implementer reported:\n%s", task.ID, task.Title, task.Description, reply.Content)
+		if t.diff != nil {
+			if d := t.diff(); d != "" {
+				review += "\n\nUncommitted changes in the repository (including earlier tasks):\n" + d
+			}
+		}
+		if _, err := t.Reviewer.Send(ctx, review); err != nil {
+			return fmt.Errorf("%s on task #%d: %w", roleReviewer, task.ID, err)
+		}
+		verdict := *t.verdict
+		switch {
+		case verdict.approved:
+			t.Board.update(task, boardDone, roleReviewer, verdict.feedback)
+			return nil
+		case round >= t.Rounds:
+			t.Board.update(task, boardUnresolved, roleReviewer, verdict.feedback)
+			return nil
+		case !verdict.given:
+			verdict.feedback = "The reviewer gave no verdict; check the task is complete."
+		}
+		t.Board.update(task, boardTodo, roleReviewer, verdict.feedback)
+		prompt = "The reviewer sent the task back:\n" + verdict.feedback
+	}
+}
+
+// teamPrinter prints what a role does, prefixed with its name
+func teamPrinter(role string, out io.Writer) func(agentlib.Event) {
+	return func(e agentlib.Event) {
+		switch e.Type {
+		case agentlib.EventAssistantText:
+			fmt.Fprintf(out, "[%s] %s\n", role, e.Content)
+		case agentlib.EventToolCall:
+			detail := e.ToolCall.Name
+			if path := toolPathHint(e.ToolCall.Input); path != "" {
+				detail += " " + path
+			}
+			fmt.Fprintf(out, "[%s] → %s\n", role, detail)
+		case agentlib.EventToolError:
+			fmt.Fprintf(out, "[%s] %s failed: %s\n", role, e.ToolCall.Name, firstLine(e.Content))
+		}
+	}
+}
+
+// selectTools returns the tools of all named in names, in that order
+func selectTools(all []tools.ToolDefinition, names []string) ([]tools.ToolDefinition, error) {
+	selected := []tools.ToolDefinition{}
+	for _, name := range names {
+		i := slices.IndexFunc(all, func(tool tools.ToolDefinition) bool { return tool.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown or disabled tool %q", name)
+		}
+		selected = append(selected, all[i])
+	}
+	return selected, nil
+}
+
+// roleTools are the workspace tools of a role: the ones the role lists, or
+// by default the read-only ones for roles that should not change files
+func roleTools(all []tools.ToolDefinition, role config.Role, readOnly bool) ([]tools.ToolDefinition, error) {
+	if role.Tools != nil {
+		return selectTools(all, role.Tools)
+	}
+	selected := []tools.ToolDefinition{}
+	for _, tool := range all {
+		if tool.ReadOnly || !readOnly {
+			selected = append(selected, tool)
+		}
+	}
+	return selected, nil
+}
+
+// newTeamAgent builds the agent of a role from the config, switched to the
+// role's profile if it has one
+func newTeamAgent(global *globalOptions, name string, role config.Role, defaultPrompt string, readOnly bool, extra []tools.ToolDefinition, logger *slog.Logger, out io.Writer) (*agentlib.Agent, error) {
+	cfg := global.cfg()
+	if role.Profile != "" {
+		var err error
+		if cfg, err = global.resolveConfig(role.Profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	provider, _, err := newProvider(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	all, err := enabledTools(cfg, &tools.ReadCache{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	defs, err := roleTools(all, role, readOnly)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	instructions, err := loadInstructions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	prompt := role.Prompt
+	if prompt == "" {
+		prompt = defaultPrompt
+	}
+	if instructions != "" {
+		prompt += "\n\n" + instructions
+	}
+	return agentlib.New(provider,
+		agentlib.WithSystemPrompt(prompt),
+		agentlib.WithTools(append(defs, extra...)...),
+		agentlib.WithEventHandler(teamPrinter(name, out)),
+	), nil
+}
+
+// newTeam builds the roles the config enables around a new task board
+func newTeam(global *globalOptions, logger *slog.Logger, out io.Writer) (*team, error) {
+	settings := global.cfg().Team
+	t := &team{Board: &taskBoard{}, verdict: &reviewVerdict{}, Rounds: max(settings.Rounds, 1)}
+	board := viewBoardTool(t.Board)
+	var err error
+	if !settings.Planner.Disabled {
+		extra := []tools.ToolDefinition{board, addTaskTool(t.Board)}
+		if t.Planner, err = newTeamAgent(global, rolePlanner, settings.Planner, plannerPrompt, true, extra, logger, out); err != nil {
+			return nil, err
+		}
+	}
+	if t.Implementer, err = newTeamAgent(global, roleImplementer, settings.Implementer, implementerPrompt, false, []tools.ToolDefinition{board}, logger, out); err != nil {
+		return nil, err
+	}
+	if !settings.Reviewer.Disabled {
+		extra := []tools.ToolDefinition{board, reviewTaskTool(t.verdict)}
+		if t.Reviewer, err = newTeamAgent(global, roleReviewer, settings.Reviewer, reviewerPrompt, true, extra, logger, out); err != nil {
+			return nil, err
+		}
+	}
+	if repo, err := gitutil.Open("."); err == nil {
+		t.diff = func() string {
+			d, err := repo.Run("diff", "HEAD")
+			if err != nil {
+				return ""
+			}
+			return truncate(d, maxReviewDiff)
+		}
+	}
+	return t, nil
+}
+
+func newTeamCommand(global *globalOptions) *cobra.Command {
+	var rounds int
+	cmd := &cobra.Command{
+		Use:   "team [goal]",
+		Short: "Work on a goal with a team of agents: a planner, an implementer and a reviewer",
+		Long: "Work on a goal with a team of agents coordinated through a shared task board: the planner breaks\n" +
+			"the goal into tasks, the implementer does them one by one and the reviewer approves each or sends\n" +
+			"it back with feedback. Each role can have its own prompt, profile (provider and model) and tools;\n" +
+			"see team in the config. The goal is read from stdin when omitted or \"-\".",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			goal := strings.Join(args, " ")
+			if goal == "" || goal == "-" {
+				data, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				goal = string(data)
+			}
+			goal = strings.TrimSpace(goal)
+			if goal == "" {
+				return fmt.Errorf("no goal given")
+			}
+			out := cmd.OutOrStdout()
+			t, err := newTeam(global, global.logger(os.Stderr, slog.LevelWarn), out)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("rounds") {
+				t.Rounds = max(rounds, 1)
+			}
+			runErr := t.run(cmd.Context(), goal, out)
+			fmt.Fprintf(out, "\nTask board:\n%s\n", t.Board)
+			return runErr
+		},
+	}
+	cmd.Flags().IntVar(&rounds, "rounds", 0, "how often a task is reviewed before it is left unresolved (default from the config, 3)")
+	return cmd
+}
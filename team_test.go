@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"agent/config"
+	agentlib "agent/pkg/agent"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolCall 返回调用 name 工具的响应
+func toolCall(t *testing.T, id, name string, input interface{}) *Response {
+	t.Helper()
+	data, err := json.Marshal(input)
+	require.NoError(t, err)
+	return &Response{ToolCalls: []ToolCall{{ID: id, Name: name, Input: data}}}
+}
+
+func TestTeam(t *testing.T) {
+	t.Run("规划、实现并审查每个任务", func(t *testing.T) {
+		board, verdict := &taskBoard{}, &reviewVerdict{}
+		planner := &mockProvider{responses: []*Response{
+			toolCall(t, "p1", "add_task", addTaskInput{Title: "Add the flag", Description: "Add --dry-run"}),
+			toolCall(t, "p2", "add_task", addTaskInput{Title: "Document it"}),
+			{Content: "Two tasks."},
+		}}
+		implementer := &mockProvider{responses: []*Response{
+			{Content: "Added the flag."},
+			{Content: "Added the missing test."},
+			{Content: "Documented it."},
+		}}
+		reviewer := &mockProvider{responses: []*Response{
+			toolCall(t, "r1", "review_task", reviewTaskInput{Feedback: "The flag has no test."}),
+			{Content: "Sent back."},
+			toolCall(t, "r2", "review_task", reviewTaskInput{Approved: true, Feedback: "Looks good."}),
+			{Content: "Approved."},
+			toolCall(t, "r3", "review_task", reviewTaskInput{Approved: true}),
+			{Content: "Approved."},
+		}}
+		var out bytes.Buffer
+		team := &team{
+			Planner:     agentlib.New(planner, agentlib.WithTools(viewBoardTool(board), addTaskTool(board)), agentlib.WithEventHandler(teamPrinter(rolePlanner, &out))),
+			Implementer: agentlib.New(implementer),
+			Reviewer:    agentlib.New(reviewer, agentlib.WithTools(reviewTaskTool(verdict))),
+			Board:       board,
+			verdict:     verdict,
+			Rounds:      3,
+			diff:        func() string { return "+dry-run" },
+		}
+		require.NoError(t, team.run(context.Background(), "Add a dry-run mode", &out))
+
+		tasks := board.Tasks()
+		require.Len(t, tasks, 2)
+		assert.Equal(t, boardDone, tasks[0].Status)
+		assert.Equal(t, []string{
+			"implementer: Added the flag.",
+			"reviewer: The flag has no test.",
+			"implementer: Added the missing test.",
+			"reviewer: Looks good.",
+		}, tasks[0].Notes)
+		assert.Equal(t, boardDone, tasks[1].Status)
+
+		// 审查者看到任务、实现者的报告和改动
+		review := reviewer.calls[0][0].Content
+		assert.Contains(t, review, "Task #1: Add the flag\nAdd --dry-run")
+		assert.Contains(t, review, "The implementer reported:\nAdded the flag.")
+		assert.Contains(t, review, "+dry-run")
+		// 被退回时实现者在同一个对话中收到意见，新任务从新的对话开始
+		assert.Equal(t, "The reviewer sent the task back:\nThe flag has no test.", implementer.calls[1][len(implementer.calls[1])-1].Content)
+		assert.Len(t, implementer.calls[2], 1)
+		assert.Contains(t, implementer.calls[2][0].Content, "Do task #2: Document it")
+		assert.Contains(t, out.String(), "[planner] → add_task")
+		assert.Contains(t, out.String(), "== reviewer: task #1 Add the flag (round 2)")
+	})
+
+	t.Run("没有规划者和审查者时目标就是唯一的任务", func(t *testing.T) {
+		board := &taskBoard{}
+		implementer := &mockProvider{responses: []*Response{{Content: "Done."}}}
+		team := &team{Implementer: agentlib.New(implementer), Board: board, verdict: &reviewVerdict{}, Rounds: 3}
+		require.NoError(t, team.run(context.Background(), "Fix the typo\nin README", &bytes.Buffer{}))
+		tasks := board.Tasks()
+		require.Len(t, tasks, 1)
+		assert.Equal(t, "Fix the typo", tasks[0].Title)
+		assert.Equal(t, boardDone, tasks[0].Status)
+	})
+
+	t.Run("审查次数用完时任务未解决", func(t *testing.T) {
+		board, verdict := &taskBoard{}, &reviewVerdict{}
+		implementer := &mockProvider{responses: []*Response{{Content: "Tried."}}}
+		reviewer := &mockProvider{responses: []*Response{{Content: "Hmm."}}}
+		team := &team{Implementer: agentlib.New(implementer), Reviewer: agentlib.New(reviewer, agentlib.WithTools(reviewTaskTool(verdict))),
+			Board: board, verdict: verdict, Rounds: 1}
+		require.NoError(t, team.run(context.Background(), "Refactor", &bytes.Buffer{}))
+		assert.Equal(t, boardUnresolved, board.Tasks()[0].Status)
+		assert.Contains(t, board.String(), "#1 [unresolved] Refactor")
+	})
+
+	t.Run("退回任务必须给出意见", func(t *testing.T) {
+		result := reviewTaskTool(&reviewVerdict{}).Function(context.Background(), json.RawMessage(`{"approved": false}`))
+		_, err := result.Output()
+		assert.Error(t, err)
+	})
+}
+
+func TestRoleTools(t *testing.T) {
+	all := []tools.ToolDefinition{{Name: "read_file", ReadOnly: true}, {Name: "edit_file"}, {Name: "grep", ReadOnly: true}}
+	names := func(defs []tools.ToolDefinition) []string {
+		out := []string{}
+		for _, def := range defs {
+			out = append(out, def.Name)
+		}
+		return out
+	}
+
+	defs, err := roleTools(all, config.Role{}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read_file", "grep"}, names(defs), "默认只有只读工具")
+
+	defs, err = roleTools(all, config.Role{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read_file", "edit_file", "grep"}, names(defs))
+
+	defs, err = roleTools(all, config.Role{Tools: []string{"edit_file"}}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"edit_file"}, names(defs), "列出的工具优先")
+
+	_, err = roleTools(all, config.Role{Tools: []string{"shell"}}, false)
+	assert.ErrorContains(t, err, "shell")
+}
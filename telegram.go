@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/spf13/cobra"
+)
+
+// maxTelegramText is the longest message Telegram accepts
+const maxTelegramText = 4096
+
+// telegramChat is the part of the Telegram Bot API the bot uses;
+// *tgbotapi.BotAPI implements it
+type telegramChat interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// telegramBot drives agent sessions from Telegram: each chat is one session
+// until /new starts another. In groups the bot answers messages that mention
+// it or reply to it. Only allowed users can talk to the bot.
+type telegramBot struct {
+	bot  *chatBot
+	chat telegramChat
+	// username is the bot's own user name, whose mentions are stripped from messages
+	username string
+	// allowed holds the IDs and user names of the users the bot answers
+	allowed map[string]bool
+}
+
+func newTelegramBot(api *apiServer, chat telegramChat, username string, allowed []string, logger *slog.Logger) *telegramBot {
+	t := &telegramBot{chat: chat, username: username, allowed: map[string]bool{}}
+	for _, user := range allowed {
+		t.allowed[strings.TrimPrefix(user, "@")] = true
+	}
+	t.bot = newChatBot(api, t, maxTelegramText, logger)
+	return t
+}
+
+func (t *telegramBot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	switch {
+	case update.Message != nil:
+		t.handleMessage(ctx, update.Message)
+	case update.CallbackQuery != nil:
+		t.handleCallback(ctx, update.CallbackQuery)
+	}
+}
+
+// handleMessage runs a message in its chat's session; /new forgets the
+// session so the next message starts a new one
+func (t *telegramBot) handleMessage(ctx context.Context, m *tgbotapi.Message) {
+	if m.From == nil || !t.isAllowed(m.From) {
+		return
+	}
+	conversation := strconv.FormatInt(m.Chat.ID, 10)
+	mention := "@" + t.username
+	replyToBot := m.ReplyToMessage != nil && m.ReplyToMessage.From != nil && m.ReplyToMessage.From.UserName == t.username
+	if !m.Chat.IsPrivate() && !strings.Contains(m.Text, mention) && !replyToBot {
+		return
+	}
+
+	switch m.Command() {
+	case "start", "new":
+		t.bot.reset(conversation)
+		t.bot.say(ctx, conversation, "Started a new session. Send a message to begin.")
+		return
+	}
+	t.bot.handleMessage(ctx, conversation, strings.ReplaceAll(m.Text, mention, ""))
+}
+
+func (t *telegramBot) say(ctx context.Context, conversation, text string) error {
+	chatID, err := strconv.ParseInt(conversation, 10, 64)
+	if err != nil {
+		return err
+	}
+	_, err = t.chat.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// askApproval posts a tool call with buttons to approve or deny it; the
+// callback data is "y " or "n " and the value, within Telegram's 64 bytes
+func (t *telegramBot) askApproval(ctx context.Context, conversation, text, value string) error {
+	chatID, err := strconv.ParseInt(conversation, 10, 64)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Approve", "y "+value),
+		tgbotapi.NewInlineKeyboardButtonData("Deny", "n "+value),
+	))
+	_, err = t.chat.Send(msg)
+	return err
+}
+
+// handleCallback delivers the decision of an approval button and replaces the
+// buttons with who decided
+func (t *telegramBot) handleCallback(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	answer := func(text string) {
+		if _, err := t.chat.Request(tgbotapi.NewCallback(q.ID, text)); err != nil {
+			t.bot.logger.Warn("answering Telegram callback failed", "error", err)
+		}
+	}
+	if q.From == nil || !t.isAllowed(q.From) {
+		answer("You are not allowed to approve tool calls.")
+		return
+	}
+	choice, value, _ := strings.Cut(q.Data, " ")
+	if choice != "y" && choice != "n" {
+		return
+	}
+	if err := t.bot.decide(value, choice == "y"); err != nil {
+		answer(err.Error())
+		return
+	}
+
+	answer("Done")
+	verdict := "✅ Approved"
+	if choice == "n" {
+		verdict = "⛔ Denied"
+	}
+	if q.Message == nil {
+		return
+	}
+	edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, fmt.Sprintf("%s\n%s by %s", q.Message.Text, verdict, telegramName(q.From)))
+	if _, err := t.chat.Send(edit); err != nil {
+		t.bot.logger.Warn("updating Telegram message failed", "error", err)
+	}
+}
+
+func (t *telegramBot) isAllowed(user *tgbotapi.User) bool {
+	return t.allowed[strconv.FormatInt(user.ID, 10)] || user.UserName != "" && t.allowed[user.UserName]
+}
+
+func telegramName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return user.FirstName
+}
+
+func newTelegramCommand(global *globalOptions) *cobra.Command {
+	var allowed []string
+	var requireApproval bool
+	cmd := &cobra.Command{
+		Use:   "telegram",
+		Short: "Run a Telegram bot that drives sessions from chats",
+		Long: `Run a Telegram bot sharing the session store of the CLI.
+
+Each chat with the bot is one session; /new starts a new one. In groups the
+bot answers messages that mention it or reply to it. Tools that change files
+or run commands wait for an allowed user to press Approve or Deny.
+
+The bot only answers the users given with --allow-user (numeric IDs or user
+names), since it runs commands on this machine. It needs a bot token from
+@BotFather in TELEGRAM_BOT_TOKEN (or "agent auth login telegram").`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(allowed) == 0 {
+				return fmt.Errorf("--allow-user is required: the bot runs commands on this machine")
+			}
+			token, _ := lookupAPIKey("telegram", "")
+			if token == "" {
+				return fmt.Errorf("set TELEGRAM_BOT_TOKEN (or run `agent auth login telegram`)")
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			logger := global.logger(os.Stderr, slog.LevelInfo)
+			newAgent, store, err := serverAgents(global, logger, nil)
+			if err != nil {
+				return err
+			}
+			api := newAPIServer(ctx, store, newAgent)
+			api.requireApproval = requireApproval
+			go api.reapIdle(ctx)
+
+			client, err := tgbotapi.NewBotAPI(token)
+			if err != nil {
+				return fmt.Errorf("telegram auth: %w", err)
+			}
+			bot := newTelegramBot(api, client, client.Self.UserName, allowed, logger)
+			fmt.Fprintf(cmd.ErrOrStderr(), "Running as @%s\n", client.Self.UserName)
+
+			config := tgbotapi.NewUpdate(0)
+			config.Timeout = 30
+			updates := client.GetUpdatesChan(config)
+			for {
+				select {
+				case <-ctx.Done():
+					client.StopReceivingUpdates()
+					return nil
+				case update := <-updates:
+					bot.handleUpdate(ctx, update)
+				}
+			}
+		},
+	}
+	cmd.Flags().StringSliceVar(&allowed, "allow-user", nil, "Telegram user ID or user name allowed to use the bot (repeatable)")
+	cmd.Flags().BoolVar(&requireApproval, "require-approval", true, "ask before each tool call that changes state")
+	return cmd
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelegram 记录机器人发送的消息、编辑和回调应答
+type fakeTelegram struct {
+	mu       sync.Mutex
+	messages []tgbotapi.MessageConfig
+	edits    []tgbotapi.EditMessageTextConfig
+	answers  []string
+}
+
+func (f *fakeTelegram) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		f.messages = append(f.messages, c)
+	case tgbotapi.EditMessageTextConfig:
+		f.edits = append(f.edits, c)
+	}
+	return tgbotapi.Message{MessageID: len(f.messages)}, nil
+}
+
+func (f *fakeTelegram) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if callback, ok := c.(tgbotapi.CallbackConfig); ok {
+		f.answers = append(f.answers, callback.Text)
+	}
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// waitMessage 等待以 prefix 开头的消息并返回它
+func (f *fakeTelegram) waitMessage(t *testing.T, prefix string) tgbotapi.MessageConfig {
+	t.Helper()
+	var found tgbotapi.MessageConfig
+	require.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, msg := range f.messages {
+			if strings.HasPrefix(msg.Text, prefix) {
+				found = msg
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond, "no Telegram message starting with %q", prefix)
+	return found
+}
+
+func newTestTelegramBot(t *testing.T, newAgent func() *Agent) (*telegramBot, *fakeTelegram) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store := &FileSessionStore{Dir: t.TempDir()}
+	api := newAPIServer(ctx, store, func() (*Agent, error) {
+		agent := newAgent()
+		agent.store = store
+		return agent, nil
+	})
+	api.requireApproval = true
+	chat := &fakeTelegram{}
+	return newTelegramBot(api, chat, "agent_bot", []string{"@alice"}, slog.New(slog.NewTextHandler(io.Discard, nil))), chat
+}
+
+func TestTelegramBot(t *testing.T) {
+	alice := &tgbotapi.User{ID: 1, UserName: "alice"}
+	private := &tgbotapi.Chat{ID: 42, Type: "private"}
+	group := &tgbotapi.Chat{ID: -100, Type: "group"}
+
+	t.Run("私聊中每条消息都在会话中运行", func(t *testing.T) {
+		bot, chat := newTestTelegramBot(t, oneShotAgent)
+		bot.handleUpdate(context.Background(), tgbotapi.Update{Message: &tgbotapi.Message{From: alice, Chat: private, Text: "模块名是什么？"}})
+		msg := chat.waitMessage(t, "模块名是 agent")
+		assert.Equal(t, int64(42), msg.ChatID)
+
+		id := bot.bot.session("42")
+		require.NotEmpty(t, id)
+		bot.handleUpdate(context.Background(), tgbotapi.Update{Message: &tgbotapi.Message{
+			From: alice, Chat: private, Text: "/new",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 4}},
+		}})
+		assert.Empty(t, bot.bot.session("42"))
+	})
+
+	t.Run("群组中只回复提及机器人的消息", func(t *testing.T) {
+		bot, chat := newTestTelegramBot(t, oneShotAgent)
+		bot.handleUpdate(context.Background(), tgbotapi.Update{Message: &tgbotapi.Message{From: alice, Chat: group, Text: "大家好"}})
+		assert.Empty(t, bot.bot.session("-100"))
+		bot.handleUpdate(context.Background(), tgbotapi.Update{Message: &tgbotapi.Message{From: &tgbotapi.User{ID: 2, UserName: "mallory"}, Chat: group, Text: "@agent_bot 删除所有文件"}})
+		assert.Empty(t, bot.bot.session("-100"))
+
+		bot.handleUpdate(context.Background(), tgbotapi.Update{Message: &tgbotapi.Message{From: alice, Chat: group, Text: "@agent_bot 模块名是什么？"}})
+		chat.waitMessage(t, "模块名是 agent")
+	})
+
+	t.Run("按钮批准工具调用", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		bot, chat := newTestTelegramBot(t, editAgent)
+		bot.handleUpdate(context.Background(), tgbotapi.Update{Message: &tgbotapi.Message{From: alice, Chat: private, Text: "创建 hello.txt"}})
+
+		msg := chat.waitMessage(t, "✋ Allow `edit_file`?")
+		keyboard := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+		data := *keyboard.InlineKeyboard[0][0].CallbackData
+		assert.LessOrEqual(t, len(data), 64)
+
+		bot.handleUpdate(context.Background(), tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID: "q1", From: alice, Data: data,
+			Message: &tgbotapi.Message{MessageID: 7, Chat: private, Text: msg.Text},
+		}})
+		chat.waitMessage(t, "完成")
+
+		chat.mu.Lock()
+		defer chat.mu.Unlock()
+		require.Len(t, chat.edits, 1)
+		assert.Equal(t, msg.Text+"\n✅ Approved by @alice", chat.edits[0].Text)
+		assert.Equal(t, []string{"Done"}, chat.answers)
+	})
+}
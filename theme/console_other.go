@@ -0,0 +1,10 @@
+//go:build !windows
+
+package theme
+
+import "os"
+
+// EnableANSI 让终端 f 解释颜色和光标控制序列。Windows 以外的终端本来就支持，总是返回 true
+func EnableANSI(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package theme
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// EnableANSI 打开 Windows 控制台的虚拟终端处理，使其解释颜色和光标控制序列。
+// f 不是控制台或控制台不支持（Windows 10 之前的版本）时返回 false
+func EnableANSI(f *os.File) bool {
+	h := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
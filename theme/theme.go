@@ -0,0 +1,124 @@
+package theme
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Theme 定义终端输出各类元素使用的 SGR 颜色参数，例如 "94" 或 "38;5;208"
+type Theme struct {
+	Name      string
+	User      string
+	Assistant string
+	Tool      string
+	Success   string
+	Warning   string
+	Error     string
+	Muted     string
+
+	DiffHeader string
+	DiffHunk   string
+	DiffAdd    string
+	DiffDelete string
+
+	// Markdown 是 glamour 样式名，Code 是 chroma 样式名
+	Markdown string
+	Code     string
+}
+
+// Themes 是内置主题
+var Themes = map[string]*Theme{
+	"default": {
+		Name: "default", User: "94", Assistant: "93", Tool: "96", Success: "92", Warning: "93", Error: "91", Muted: "90",
+		DiffHeader: "1", DiffHunk: "36", DiffAdd: "32", DiffDelete: "31",
+		Markdown: "auto", Code: "monokai",
+	},
+	"light": {
+		Name: "light", User: "34", Assistant: "35", Tool: "36", Success: "32", Warning: "33", Error: "31", Muted: "90",
+		DiffHeader: "1", DiffHunk: "36", DiffAdd: "32", DiffDelete: "31",
+		Markdown: "light", Code: "github",
+	},
+	"mono": {
+		Name: "mono", User: "1", Assistant: "1", Tool: "4", Success: "1", Warning: "1", Error: "1;7", Muted: "2",
+		DiffHeader: "1", DiffHunk: "2", DiffAdd: "1", DiffDelete: "2",
+		Markdown: "notty", Code: "bw",
+	},
+}
+
+var (
+	current = Themes["default"]
+	enabled = true
+)
+
+// Names 返回内置主题名称，按字母排序
+func Names() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Use 切换到指定名称的主题
+func Use(name string) error {
+	t, ok := Themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	current = t
+	return nil
+}
+
+// Current 返回当前主题
+func Current() *Theme {
+	return current
+}
+
+// SetEnabled 打开或关闭颜色输出
+func SetEnabled(on bool) {
+	enabled = on
+}
+
+// Enabled 报告是否输出颜色
+func Enabled() bool {
+	return enabled
+}
+
+// ColorWanted 根据 --color 模式（auto、always、never）、NO_COLOR 环境变量
+// 以及输出是否为终端决定是否使用颜色
+func ColorWanted(mode string, isTerminal bool) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		return isTerminal, nil
+	default:
+		return false, fmt.Errorf("invalid color mode %q (use auto, always or never)", mode)
+	}
+}
+
+// Paint 用 SGR 参数 code 包裹 s；关闭颜色或 code 为空时原样返回
+func Paint(code, s string) string {
+	if !enabled || code == "" || s == "" {
+		return s
+	}
+	return "\u001b[" + code + "m" + s + "\u001b[0m"
+}
+
+// 以下函数按当前主题为对应元素着色
+
+func User(s string) string      { return Paint(current.User, s) }
+func Assistant(s string) string { return Paint(current.Assistant, s) }
+func Tool(s string) string      { return Paint(current.Tool, s) }
+func Success(s string) string   { return Paint(current.Success, s) }
+func Warning(s string) string   { return Paint(current.Warning, s) }
+func Error(s string) string     { return Paint(current.Error, s) }
+func Muted(s string) string     { return Paint(current.Muted, s) }
@@ -0,0 +1,51 @@
+package theme
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColorWanted(t *testing.T) {
+	t.Run("auto 跟随终端", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "")
+		on, err := ColorWanted("auto", true)
+		require.NoError(t, err)
+		assert.True(t, on)
+
+		on, err = ColorWanted("auto", false)
+		require.NoError(t, err)
+		assert.False(t, on)
+	})
+
+	t.Run("NO_COLOR 关闭颜色", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		on, err := ColorWanted("auto", true)
+		require.NoError(t, err)
+		assert.False(t, on)
+
+		on, err = ColorWanted("always", false)
+		require.NoError(t, err)
+		assert.True(t, on, "显式指定 always 优先")
+	})
+
+	t.Run("无效模式", func(t *testing.T) {
+		_, err := ColorWanted("rainbow", true)
+		assert.Error(t, err)
+	})
+}
+
+func TestPaint(t *testing.T) {
+	defer SetEnabled(true)
+	defer Use("default")
+
+	assert.Equal(t, "\u001b[91mError\u001b[0m", Error("Error"))
+	require.NoError(t, Use("light"))
+	assert.Equal(t, "\u001b[31mError\u001b[0m", Error("Error"))
+	assert.Error(t, Use("neon"))
+
+	SetEnabled(false)
+	assert.Equal(t, "Error", Error("Error"))
+	assert.Equal(t, "", Paint("1", ""))
+}
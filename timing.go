@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/i18n"
+	"agent/theme"
+)
+
+// TurnTiming breaks down where a turn's time went: waiting on the model or
+// running tools. It is sent as a timing event when the turn ends.
+type TurnTiming struct {
+	TotalMS int64 `json:"total_ms"`
+	// FirstTokenMS is the time from the start of the turn to the first byte
+	// of the model's first response; 0 when it is unknown (no HTTP involved)
+	FirstTokenMS int64        `json:"first_token_ms,omitempty"`
+	InferenceMS  int64        `json:"inference_ms"`
+	Inferences   int          `json:"inferences"`
+	Tools        []ToolTiming `json:"tools,omitempty"`
+}
+
+// ToolTiming is how long one tool call took, including waiting for approval
+type ToolTiming struct {
+	Name       string `json:"name"`
+	ID         string `json:"id"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// ToolsMS is the total time spent running tools
+func (t TurnTiming) ToolsMS() int64 {
+	var total int64
+	for _, tool := range t.Tools {
+		total += tool.DurationMS
+	}
+	return total
+}
+
+// String summarizes the timing on one line, e.g.
+// "12.3s total · first token 1.2s · model 8.1s (3 calls) · tools 4.0s (shell 3.5s, read_file 12ms)"
+func (t TurnTiming) String() string {
+	parts := []string{formatMS(t.TotalMS) + " total"}
+	if t.FirstTokenMS > 0 {
+		parts = append(parts, "first token "+formatMS(t.FirstTokenMS))
+	}
+	calls := "calls"
+	if t.Inferences == 1 {
+		calls = "call"
+	}
+	parts = append(parts, fmt.Sprintf("model %s (%d %s)", formatMS(t.InferenceMS), t.Inferences, calls))
+	if len(t.Tools) > 0 {
+		tools := make([]string, len(t.Tools))
+		for i, tool := range t.Tools {
+			tools[i] = tool.Name + " " + formatMS(tool.DurationMS)
+		}
+		parts = append(parts, fmt.Sprintf("tools %s (%s)", formatMS(t.ToolsMS()), strings.Join(tools, ", ")))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatMS shows milliseconds as a duration, to the millisecond below a
+// second and to a tenth of a second above
+func formatMS(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if d < time.Second {
+		return d.String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// turnTimer collects the timing of the running turn
+type turnTimer struct {
+	start  time.Time
+	timing TurnTiming
+
+	// firstByte is set from the HTTP transport's goroutine
+	mu        sync.Mutex
+	firstByte time.Time
+}
+
+func newTurnTimer() *turnTimer {
+	return &turnTimer{start: time.Now()}
+}
+
+// trace returns ctx with an HTTP trace that notes when the first response
+// byte of the turn arrives. Replies are not streamed, so this is as close
+// to the first token as the agent can see.
+func (t *turnTimer) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.firstByte.IsZero() {
+				t.firstByte = time.Now()
+			}
+		},
+	})
+}
+
+func (t *turnTimer) recordInference(elapsed time.Duration) {
+	t.timing.Inferences++
+	t.timing.InferenceMS += elapsed.Milliseconds()
+}
+
+// recordTool notes a tool call's duration; it does nothing on a nil timer,
+// when tools run outside a turn
+func (t *turnTimer) recordTool(call ToolCall, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	t.timing.Tools = append(t.timing.Tools, ToolTiming{Name: call.Name, ID: call.ID, DurationMS: elapsed.Milliseconds()})
+}
+
+// finish returns the timing of the turn so far
+func (t *turnTimer) finish() TurnTiming {
+	timing := t.timing
+	timing.TotalMS = time.Since(t.start).Milliseconds()
+	t.mu.Lock()
+	if !t.firstByte.IsZero() {
+		timing.FirstTokenMS = t.firstByte.Sub(t.start).Milliseconds()
+	}
+	t.mu.Unlock()
+	return timing
+}
+
+// printTiming shows a turn's timing in the line REPL when timings are on
+func (a *Agent) printTiming(timing TurnTiming) {
+	if a.showTimings {
+		fmt.Println(theme.Muted(i18n.T("Timing") + ": " + timing.String()))
+	}
+}
+
+// runTimingsCommand shows whether timings are displayed, or turns them on or off
+func runTimingsCommand(a *Agent, args []string) error {
+	if len(args) == 0 {
+		state := "off"
+		if a.showTimings {
+			state = "on"
+		}
+		fmt.Println(i18n.Sprintf("Turn timings are %s", i18n.T(state)))
+		return nil
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /timings [on|off]")
+	}
+	switch args[0] {
+	case "on":
+		a.showTimings = true
+	case "off":
+		a.showTimings = false
+	default:
+		return fmt.Errorf("usage: /timings [on|off]")
+	}
+	fmt.Println(i18n.Sprintf("Turn timings %s", i18n.T(args[0])))
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// turnTiming 运行一轮并返回轮次结束时发出的 timing 事件
+func turnTiming(t *testing.T, agent *Agent, prompt string) TurnTiming {
+	var timing *TurnTiming
+	agent.onEvent = func(e AgentEvent) {
+		if e.Type == EventTiming {
+			timing = e.Timing
+		}
+	}
+	require.NoError(t, agent.runTurn(context.Background(), prompt))
+	require.NotNil(t, timing, "轮次结束时发出 timing 事件")
+	return *timing
+}
+
+func TestTurnTiming(t *testing.T) {
+	timing := turnTiming(t, oneShotAgent(), "模块名是什么？")
+
+	assert.Equal(t, 2, timing.Inferences)
+	require.Len(t, timing.Tools, 1)
+	assert.Equal(t, "read_file", timing.Tools[0].Name)
+	assert.Zero(t, timing.FirstTokenMS, "没有 HTTP 请求时首 token 时间未知")
+	assert.GreaterOrEqual(t, timing.TotalMS, timing.InferenceMS+timing.ToolsMS())
+}
+
+func TestTurnTimingFirstToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "你好"}}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}}`)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.Provider = "openai"
+	cfg.BaseURL = server.URL
+	agent := NewAgent(nil, nil, nil)
+	_, err := agent.applyConfig(cfg)
+	require.NoError(t, err)
+
+	timing := turnTiming(t, agent, "你好")
+	assert.Equal(t, 1, timing.Inferences)
+	assert.GreaterOrEqual(t, timing.FirstTokenMS, int64(50))
+	assert.GreaterOrEqual(t, timing.InferenceMS, int64(50))
+}
+
+func TestTurnTimingString(t *testing.T) {
+	timing := TurnTiming{
+		TotalMS:      12340,
+		FirstTokenMS: 1210,
+		InferenceMS:  8100,
+		Inferences:   3,
+		Tools: []ToolTiming{
+			{Name: "shell", DurationMS: 3500},
+			{Name: "read_file", DurationMS: 12},
+		},
+	}
+	assert.Equal(t, "12.3s total · first token 1.2s · model 8.1s (3 calls) · tools 3.5s (shell 3.5s, read_file 12ms)", timing.String())
+
+	t.Run("没有工具和首 token 时省略", func(t *testing.T) {
+		assert.Equal(t, "850ms total · model 800ms (1 call)", TurnTiming{TotalMS: 850, InferenceMS: 800, Inferences: 1}.String())
+	})
+}
+
+func TestTimingsCommand(t *testing.T) {
+	agent := oneShotAgent()
+	require.NoError(t, runTimingsCommand(agent, []string{"on"}))
+	assert.True(t, agent.showTimings)
+	require.NoError(t, runTimingsCommand(agent, []string{"off"}))
+	assert.False(t, agent.showTimings)
+	assert.Error(t, runTimingsCommand(agent, []string{"maybe"}))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"agent/i18n"
+	"agent/pkg/provider"
+	"agent/tools"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// ErrContextFull is returned when the next request would not fit in the
+// model's context window
+var ErrContextFull = errors.New("context window full")
+
+// checkContext counts the next request once the last one came near the
+// context window, and refuses to send it when it no longer fits; far from
+// the limit the size of the last inference is a good enough guide
+func (a *Agent) checkContext(ctx context.Context, conversation []Message, defs []tools.ToolDefinition) error {
+	stats := a.sessionStats()
+	limit := contextWindow(stats.Model)
+	if limit == 0 || stats.contextFraction() < contextWarnFraction {
+		return nil
+	}
+	n, method, err := provider.CountTokens(ctx, a.provider, conversation, defs)
+	if err != nil {
+		a.log().Warn("counting tokens failed", "error", err)
+		return nil
+	}
+	a.log().Info("counted tokens", "tokens", n, "method", method, "limit", limit)
+	a.mu.Lock()
+	a.stats.Context = n
+	a.mu.Unlock()
+	if n >= limit {
+		return fmt.Errorf("%w: the next request needs %s tokens and %s allows %s; /fork from an earlier message or start a new session",
+			ErrContextFull, formatTokens(n), stats.Model, formatTokens(limit))
+	}
+	return nil
+}
+
+// runTokensCommand shows the size of the next request, counted by the
+// provider when it can, with a local breakdown of where the tokens go
+func runTokensCommand(a *Agent, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: /tokens")
+	}
+	conversation, defs := a.requestConversation(), a.toolDefinitions()
+	n, method, err := provider.CountTokens(context.Background(), a.provider, conversation, defs)
+	if err != nil {
+		return err
+	}
+	line := i18n.Sprintf("Next request: %s tokens (%s)", formatTokens(n), method)
+	if limit := contextWindow(a.sessionStats().Model); limit > 0 {
+		line += i18n.Sprintf(", %.0f%% of the context window (%s)", float64(n)/float64(limit)*100, formatTokens(limit))
+	}
+	fmt.Println(line)
+
+	var system, messages []Message
+	for _, msg := range conversation {
+		if msg.Role == "system" {
+			system = append(system, msg)
+		} else {
+			messages = append(messages, msg)
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, part := range []struct {
+		name         string
+		conversation []Message
+		defs         []tools.ToolDefinition
+	}{
+		{i18n.T("Instructions and context"), system, nil},
+		{i18n.T("Messages"), messages, nil},
+		{i18n.T("Tools"), nil, defs},
+	} {
+		n, _, err := provider.Tiktoken(tiktoken.MODEL_CL100K_BASE, part.conversation, part.defs)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "  %s\t~%s\n", part.name, formatTokens(n))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"agent/pkg/message"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProvider 是能计算 token 数的 mockProvider
+type countingProvider struct {
+	*mockProvider
+	tokens int64
+}
+
+func (p countingProvider) CountTokens(context.Context, []message.Message, []tools.ToolDefinition) (int64, string, error) {
+	return p.tokens, "test", nil
+}
+
+func TestCheckContext(t *testing.T) {
+	newAgent := func(tokens, context int64) (*Agent, *mockProvider) {
+		mock := &mockProvider{responses: []*Response{{Content: "Hi."}}}
+		agent := NewAgent(countingProvider{mockProvider: mock, tokens: tokens}, nil, nil)
+		agent.onEvent = func(AgentEvent) {}
+		agent.stats = sessionStats{Model: "gpt-4o", Context: context}
+		return agent, mock
+	}
+
+	t.Run("离上限还远时不计算", func(t *testing.T) {
+		agent, mock := newAgent(200000, 1000)
+		require.NoError(t, agent.runTurn(context.Background(), "hello"))
+		assert.Len(t, mock.calls, 1)
+	})
+
+	t.Run("接近上限时计算，放得下就发送", func(t *testing.T) {
+		agent, mock := newAgent(110000, 120000)
+		require.NoError(t, agent.runTurn(context.Background(), "hello"))
+		assert.Len(t, mock.calls, 1)
+	})
+
+	t.Run("超出上下文窗口时不发送", func(t *testing.T) {
+		agent, mock := newAgent(130000, 120000)
+		err := agent.runTurn(context.Background(), "hello")
+		assert.ErrorIs(t, err, ErrContextFull)
+		assert.ErrorContains(t, err, "needs 130k tokens and gpt-4o allows 128k")
+		assert.Empty(t, mock.calls)
+		assert.Equal(t, int64(130000), agent.sessionStats().Context, "状态栏显示计算出的大小")
+	})
+}
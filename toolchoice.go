@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"agent/i18n"
+	"agent/pkg/provider"
+	"agent/tools"
+)
+
+// setToolChoice parses auto, required, none or a tool name; a forced tool
+// must be one of the agent's current tools
+func (a *Agent) setToolChoice(s string) error {
+	choice := provider.ParseToolChoice(s)
+	if choice.Mode == provider.ToolForce && !slices.ContainsFunc(a.toolDefinitions(), func(def tools.ToolDefinition) bool { return def.Name == choice.Name }) {
+		return fmt.Errorf("unknown tool %q: use auto, required, none or an enabled tool", choice.Name)
+	}
+	a.toolChoice = choice
+	return nil
+}
+
+// runToolChoiceCommand shows or sets how the model uses tools at the start of each turn
+func runToolChoiceCommand(a *Agent, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /tool-choice [auto|required|none|TOOL]")
+	}
+	if len(args) == 1 {
+		if err := a.setToolChoice(args[0]); err != nil {
+			return err
+		}
+	}
+	switch a.toolChoice.Mode {
+	case provider.ToolRequired:
+		fmt.Println(i18n.T("The model must call a tool at the start of each turn"))
+	case provider.ToolNone:
+		fmt.Println(i18n.T("The model answers without calling tools"))
+	case provider.ToolForce:
+		fmt.Println(i18n.Sprintf("The model calls %s at the start of each turn", a.toolChoice.Name))
+	default:
+		fmt.Println(i18n.T("The model decides when to call tools"))
+	}
+	return nil
+}
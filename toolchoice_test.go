@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"agent/pkg/message"
+	"agent/pkg/provider"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolChoice(t *testing.T) {
+	var choices []provider.ToolChoice
+	responses := []*Response{
+		{ToolCalls: []ToolCall{{ID: "1", Name: "read_file", Input: []byte(`{"path": "go.mod"}`)}}},
+		{Content: "Read it."},
+	}
+	agent := NewAgent(ProviderFunc(func(ctx context.Context, _ []message.Message, _ []tools.ToolDefinition) (*Response, error) {
+		choices = append(choices, provider.ToolChoiceFrom(ctx))
+		response := responses[0]
+		responses = responses[1:]
+		return response, nil
+	}), nil, builtinTools())
+	agent.onEvent = func(AgentEvent) {}
+
+	assert.ErrorContains(t, agent.setToolChoice("teleport"), "unknown tool")
+	require.NoError(t, agent.setToolChoice("read_file"))
+	require.NoError(t, agent.runTurn(context.Background(), "What module is this?"))
+	assert.Equal(t, []provider.ToolChoice{{Mode: provider.ToolForce, Name: "read_file"}, {}}, choices, "只有每轮的第一次推理强制调用工具")
+
+	require.NoError(t, agent.setToolChoice("auto"))
+	assert.True(t, agent.toolChoice.IsAuto())
+}
@@ -0,0 +1,394 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// benchmarkTimeout 是一次运行基准测试的最长时间
+const benchmarkTimeout = 10 * time.Minute
+
+// benchmarkAlpha 是判断差异显著的 p 值上限，与 benchstat 相同
+const benchmarkAlpha = 0.05
+
+// BenchmarkInput 定义 run_benchmarks 工具的输入参数
+type BenchmarkInput struct {
+	Package  string `json:"package,omitempty" jsonschema_description:"The package pattern to benchmark, default ./..."`
+	Bench    string `json:"bench,omitempty" jsonschema_description:"Regular expression selecting the benchmarks, default all of them."`
+	Count    int    `json:"count,omitempty" jsonschema_description:"How many times to run each benchmark, default 6. Use at least 5 for a meaningful comparison."`
+	SaveAs   string `json:"save_as,omitempty" jsonschema_description:"Save the results under this name, e.g. before, to compare later runs against them."`
+	Baseline string `json:"baseline,omitempty" jsonschema_description:"Compare against the results saved under this name, or against a file in the workspace holding go test -bench output."`
+}
+
+// Benchmarks 运行 Go 基准测试，并保存命名的结果供之后比较
+type Benchmarks struct {
+	// Go 是 go 命令，为空时为 go
+	Go string
+
+	mu    sync.Mutex
+	saved map[string]benchmarkResults
+}
+
+// benchmarkResults 是每个基准测试每个指标（ns/op、B/op 等）的多次测量值
+type benchmarkResults map[string]map[string][]float64
+
+func init() {
+	// 基准测试会执行项目的代码，因此不注册为只读
+	Register(Spec{Name: "run_benchmarks", Category: CategoryCode, Permissions: []Permission{PermissionRead, PermissionExec},
+		New: func(env Env) (ToolDefinition, bool) {
+			return BenchmarksTool(env.Workspace, &Benchmarks{}), env.GoModule
+		}})
+}
+
+// BenchmarksTool 返回在工作区 w 中运行基准测试并与基线比较的工具定义
+func BenchmarksTool(w *Workspace, b *Benchmarks) ToolDefinition {
+	return ToolDefinition{
+		Name: "run_benchmarks",
+		Description: "Run Go benchmarks with go test -bench and summarize them like benchstat. To check that an optimization helps, " +
+			"run the benchmarks with save_as before changing the code, then again with baseline set to that name: the " +
+			"comparison shows the change of each metric and whether it is statistically significant.",
+		InputSchema: GenerateSchema[BenchmarkInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.RunBenchmarks(ctx, b, input))
+		},
+	}
+}
+
+// RunBenchmarks 运行基准测试，返回结果的汇总或与基线的比较
+func (w *Workspace) RunBenchmarks(ctx context.Context, b *Benchmarks, input json.RawMessage) (string, error) {
+	var params BenchmarkInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	var baseline benchmarkResults
+	if params.Baseline != "" {
+		var err error
+		if baseline, err = w.benchmarkBaseline(b, params.Baseline); err != nil {
+			return "", err
+		}
+	}
+
+	if strings.HasPrefix(params.Package, "-") {
+		return "", invalidInput("package %q must be a package pattern such as ./... or ./internal/cache", params.Package)
+	}
+	args := b.command(params)
+	ctx, cancel := context.WithTimeout(ctx, benchmarkTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	killOnCancel(cmd)
+	cmd.Dir = w.Root
+	output := &tailBuffer{limit: maxCommandOutput}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = output
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("benchmarks timed out after %s; select fewer with bench or lower count", benchmarkTimeout)
+	}
+	results := parseBenchmarks(stdout.Bytes())
+	if err != nil {
+		// 失败时 go test 把编译错误和失败的测试写到标准输出
+		output.Write(stdout.Bytes())
+		return "", fmt.Errorf("go test failed (%w):\n%s", err, output)
+	}
+	if len(results) == 0 {
+		return "", fmt.Errorf("no benchmarks ran; check the bench pattern and the package")
+	}
+
+	if params.SaveAs != "" {
+		b.mu.Lock()
+		if b.saved == nil {
+			b.saved = map[string]benchmarkResults{}
+		}
+		b.saved[params.SaveAs] = results
+		b.mu.Unlock()
+	}
+	var text string
+	if baseline != nil {
+		text = compareBenchmarks(params.Baseline, baseline, results)
+	} else {
+		text = summarizeBenchmarks(results)
+	}
+	if params.SaveAs != "" {
+		text += fmt.Sprintf("\nSaved as %q.\n", params.SaveAs)
+	}
+	return text, nil
+}
+
+// command 返回运行 params 的 go test 命令行
+func (b *Benchmarks) command(params BenchmarkInput) []string {
+	goCmd := b.Go
+	if goCmd == "" {
+		goCmd = "go"
+	}
+	pkg, bench, count := params.Package, params.Bench, params.Count
+	if pkg == "" {
+		pkg = "./..."
+	}
+	if bench == "" {
+		bench = "."
+	}
+	if count <= 0 {
+		count = 6
+	}
+	return []string{goCmd, "test", "-run", "^$", "-benchmem", "-count", strconv.Itoa(count), "-bench", bench, pkg}
+}
+
+// benchmarkBaseline 返回保存的结果，或者工作区中保存了 go test -bench 输出的文件
+func (w *Workspace) benchmarkBaseline(b *Benchmarks, name string) (benchmarkResults, error) {
+	b.mu.Lock()
+	results, ok := b.saved[name]
+	b.mu.Unlock()
+	if ok {
+		return results, nil
+	}
+	path, err := w.Resolve(name)
+	if err != nil {
+		return nil, invalidInput("no results saved as %q", name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, invalidInput("no results saved as %q", name)
+	}
+	if results = parseBenchmarks(data); len(results) == 0 {
+		return nil, invalidInput("%s contains no benchmark results", name)
+	}
+	return results, nil
+}
+
+// parseBenchmarks 解析 go test -bench 的输出。多个包有同名基准测试时名字前加上包路径
+func parseBenchmarks(output []byte) benchmarkResults {
+	results := benchmarkResults{}
+	pkg := ""
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			continue
+		}
+		fields := strings.Fields(line)
+		// BenchmarkName-8  1000  1234 ns/op  56 B/op  2 allocs/op
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := fields[0]
+		if pkg != "" {
+			name = pkg + "." + name
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				break
+			}
+			if results[name] == nil {
+				results[name] = map[string][]float64{}
+			}
+			results[name][fields[i+1]] = append(results[name][fields[i+1]], value)
+		}
+	}
+	return results.shortNames()
+}
+
+// shortNames 在所有基准测试都来自同一个包时去掉名字中的包路径
+func (r benchmarkResults) shortNames() benchmarkResults {
+	pkgs := map[string]bool{}
+	for name := range r {
+		if i := strings.LastIndex(name, ".Benchmark"); i >= 0 {
+			pkgs[name[:i]] = true
+		}
+	}
+	if len(pkgs) > 1 {
+		return r
+	}
+	short := benchmarkResults{}
+	for name, metrics := range r {
+		if i := strings.LastIndex(name, ".Benchmark"); i >= 0 {
+			name = name[i+1:]
+		}
+		short[name] = metrics
+	}
+	return short
+}
+
+// names 返回排序后的基准测试名
+func (r benchmarkResults) names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// benchmarkUnits 是指标的显示顺序，其他指标按名字排在后面
+var benchmarkUnits = []string{"ns/op", "B/op", "allocs/op", "MB/s"}
+
+func sortedUnits(metrics ...map[string][]float64) []string {
+	seen := map[string]bool{}
+	var units []string
+	for _, unit := range benchmarkUnits {
+		for _, m := range metrics {
+			if _, ok := m[unit]; ok && !seen[unit] {
+				seen[unit] = true
+				units = append(units, unit)
+			}
+		}
+	}
+	var other []string
+	for _, m := range metrics {
+		for unit := range m {
+			if !seen[unit] {
+				seen[unit] = true
+				other = append(other, unit)
+			}
+		}
+	}
+	sort.Strings(other)
+	return append(units, other...)
+}
+
+// summarizeBenchmarks 列出每个指标的平均值和波动范围
+func summarizeBenchmarks(results benchmarkResults) string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tunit\tmean\t±\tn")
+	for _, name := range results.names() {
+		for _, unit := range sortedUnits(results[name]) {
+			values := results[name][unit]
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", name, unit, formatMetric(mean(values)), formatSpread(values), len(values))
+		}
+	}
+	tw.Flush()
+	return b.String()
+}
+
+// compareBenchmarks 比较基线和新结果，用 Mann-Whitney U 检验判断差异是否显著，
+// 不显著的变化显示为 ~
+func compareBenchmarks(name string, old, new benchmarkResults) string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "benchmark\tunit\t%s\t±\tnew\t±\tdelta\tp\n", name)
+	var missing []string
+	for _, bench := range new.names() {
+		before, ok := old[bench]
+		if !ok {
+			missing = append(missing, bench)
+			continue
+		}
+		for _, unit := range sortedUnits(before, new[bench]) {
+			x, y := before[unit], new[bench][unit]
+			if len(x) == 0 || len(y) == 0 {
+				continue
+			}
+			delta := "~"
+			p := mannWhitneyU(x, y)
+			if p <= benchmarkAlpha && mean(x) != 0 {
+				delta = fmt.Sprintf("%+.2f%%", (mean(y)/mean(x)-1)*100)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", bench, unit, formatMetric(mean(x)), formatSpread(x), formatMetric(mean(y)), formatSpread(y), delta, formatP(p, len(x), len(y)))
+		}
+	}
+	tw.Flush()
+	if len(missing) > 0 {
+		fmt.Fprintf(&b, "\nNot in %s: %s\n", name, strings.Join(missing, ", "))
+	}
+	return b.String()
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// formatSpread 返回测量值偏离平均值的最大百分比
+func formatSpread(values []float64) string {
+	m := mean(values)
+	if m == 0 {
+		return "0%"
+	}
+	spread := 0.0
+	for _, v := range values {
+		spread = math.Max(spread, math.Abs(v-m)/m)
+	}
+	return fmt.Sprintf("%.0f%%", spread*100)
+}
+
+func formatMetric(v float64) string {
+	switch {
+	case v >= 100 || v == math.Trunc(v):
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	case v >= 10:
+		return strconv.FormatFloat(v, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func formatP(p float64, n, m int) string {
+	return fmt.Sprintf("p=%.3f n=%d+%d", p, n, m)
+}
+
+// mannWhitneyU 返回两组测量值来自同一分布的双侧 p 值，使用带连续性和结值修正的正态近似
+func mannWhitneyU(x, y []float64) float64 {
+	n1, n2 := float64(len(x)), float64(len(y))
+	type sample struct {
+		value float64
+		first bool
+	}
+	all := make([]sample, 0, len(x)+len(y))
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].value < all[j].value })
+
+	// 相同的值取平均秩
+	r1, ties := 0.0, 0.0
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				r1 += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+	u := r1 - n1*(n1+1)/2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		// 所有值都相同
+		return 1
+	}
+	z := (math.Abs(u-n1*n2/2) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		return 1
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
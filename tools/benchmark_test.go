@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const benchmarkOutput = `goos: linux
+goarch: amd64
+pkg: example.com/cache
+cpu: Intel(R) Xeon(R)
+BenchmarkGet-8   	 1000000	      1000 ns/op	      64 B/op	       2 allocs/op
+BenchmarkGet-8   	 1000000	      1100 ns/op	      64 B/op	       2 allocs/op
+BenchmarkGet-8   	 1000000	      1050 ns/op	      64 B/op	       2 allocs/op
+BenchmarkGet-8   	 1000000	      1020 ns/op	      64 B/op	       2 allocs/op
+BenchmarkGet-8   	 1000000	      1080 ns/op	      64 B/op	       2 allocs/op
+BenchmarkSet-8   	  500000	      2000 ns/op
+PASS
+ok  	example.com/cache	6.1s
+`
+
+func TestParseBenchmarks(t *testing.T) {
+	results := parseBenchmarks([]byte(benchmarkOutput))
+	assert.Equal(t, []string{"BenchmarkGet-8", "BenchmarkSet-8"}, results.names(), "只有一个包时去掉包路径")
+	assert.Equal(t, []float64{1000, 1100, 1050, 1020, 1080}, results["BenchmarkGet-8"]["ns/op"])
+	assert.Equal(t, []string{"ns/op", "B/op", "allocs/op"}, sortedUnits(results["BenchmarkGet-8"]))
+
+	two := parseBenchmarks([]byte("pkg: a\nBenchmarkX 10 5 ns/op\npkg: b\nBenchmarkX 10 6 ns/op\n"))
+	assert.Equal(t, []string{"a.BenchmarkX", "b.BenchmarkX"}, two.names(), "多个包有同名基准测试")
+
+	summary := summarizeBenchmarks(results)
+	assert.Contains(t, summary, "BenchmarkGet-8  ns/op      1050  5%  5")
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	old := benchmarkResults{"BenchmarkGet": {"ns/op": {1000, 1010, 990, 1005, 995}, "B/op": {64, 64, 64, 64, 64}}}
+	faster := benchmarkResults{"BenchmarkGet": {"ns/op": {800, 810, 790, 805, 795}, "B/op": {64, 64, 64, 64, 64}}, "BenchmarkNew": {"ns/op": {5}}}
+
+	text := compareBenchmarks("before", old, faster)
+	lines := strings.Split(text, "\n")
+	assert.Contains(t, lines[1], "ns/op")
+	assert.Contains(t, lines[1], "-20.00%", "显著的变化显示百分比")
+	assert.Contains(t, lines[2], "B/op")
+	assert.Contains(t, lines[2], " ~ ", "相同的值没有变化")
+	assert.Contains(t, text, "Not in before: BenchmarkNew")
+
+	assert.Less(t, mannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}), benchmarkAlpha)
+	assert.Greater(t, mannWhitneyU([]float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}), benchmarkAlpha)
+}
+
+func TestRunBenchmarks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	dir := t.TempDir()
+	goCmd := filepath.Join(dir, "go")
+	// 每次运行比上一次快一倍
+	require.NoError(t, os.WriteFile(goCmd, []byte(`#!/bin/sh
+n=$(cat `+dir+`/ns 2>/dev/null || echo 4000)
+echo "$@" > `+dir+`/args
+for i in 1 2 3 4 5; do echo "BenchmarkGet-8 1000 $((n+i)) ns/op"; done
+echo $((n/2)) > `+dir+`/ns
+`), 0755))
+	w := &Workspace{Root: t.TempDir()}
+	b := &Benchmarks{Go: goCmd}
+
+	out, err := w.RunBenchmarks(context.Background(), b, json.RawMessage(`{"bench":"Get","package":"./cache","save_as":"before"}`))
+	require.NoError(t, err)
+	assert.Contains(t, out, "BenchmarkGet-8")
+	assert.Contains(t, out, `Saved as "before".`)
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.Equal(t, "test -run ^$ -benchmem -count 6 -bench Get ./cache\n", string(args))
+
+	out, err = w.RunBenchmarks(context.Background(), b, json.RawMessage(`{"bench":"Get","package":"./cache","baseline":"before"}`))
+	require.NoError(t, err)
+	assert.Contains(t, out, "-49.96%")
+
+	t.Run("无效的输入", func(t *testing.T) {
+		_, err := w.RunBenchmarks(context.Background(), b, json.RawMessage(`{"baseline":"missing"}`))
+		assert.Equal(t, ErrorInvalidInput, KindOf(err))
+		_, err = w.RunBenchmarks(context.Background(), b, json.RawMessage(`{"package":"-exec=evil"}`))
+		assert.Equal(t, ErrorInvalidInput, KindOf(err))
+	})
+}
@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"agent/github"
+	"agent/gitutil"
+)
+
+// CILogsInput 定义 ci_logs 工具的输入参数
+type CILogsInput struct {
+	Branch string `json:"branch,omitempty" jsonschema_description:"The branch whose latest workflow runs to fetch; defaults to the current branch."`
+	Run    int64  `json:"run,omitempty" jsonschema_description:"The ID of a specific workflow run to fetch instead of the latest ones, as shown in its URL."`
+}
+
+// maxCILogLines 是每个失败作业的日志最多返回的行数，取日志末尾，错误通常在那里
+const maxCILogLines = 150
+
+// ciLogsTimeout 限制一次调用的全部 API 请求和日志下载的时间
+const ciLogsTimeout = 2 * time.Minute
+
+// maxCIFailedJobs 是最多获取日志的失败作业数量
+const maxCIFailedJobs = 5
+
+// ciTimestampPattern 匹配 GitHub Actions 日志每行开头的时间戳
+var ciTimestampPattern = regexp.MustCompile(`(?m)^\d{4}-\d\d-\d\dT[\d:.]+Z ?`)
+
+func init() {
+	Register(Spec{Name: "ci_logs", Category: CategoryIntegrations, ReadOnly: true, Permissions: []Permission{PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) {
+			return CILogsTool(env.Workspace, env.GitHub), env.Repo && env.GitHub != nil
+		}})
+}
+
+// CILogsTool 返回获取工作区仓库在 GitHub Actions 上最近的运行状态和失败作业日志的工具定义
+func CILogsTool(w *Workspace, gh *GitHub) ToolDefinition {
+	return ToolDefinition{
+		Name:        "ci_logs",
+		Description: "Fetch the status of the latest GitHub Actions workflow runs for the current branch (or a given branch or run), with the failed steps and the end of the log of each failed job. Use it to find out why CI is failing before fixing it.",
+		InputSchema: GenerateSchema[CILogsInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.CILogs(ctx, gh, input))
+		},
+		ReadOnly: true,
+	}
+}
+
+// CILogs 获取工作流运行的状态和失败作业的日志并格式化为文本
+func (w *Workspace) CILogs(ctx context.Context, gh *GitHub, input json.RawMessage) (string, error) {
+	var params CILogsInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	repo, err := gitutil.Open(w.Root)
+	if err != nil {
+		return "", err
+	}
+	remoteURL, err := repo.RemoteURL(gh.remote())
+	if err != nil {
+		return "", err
+	}
+	host, target, err := github.ParseRemote(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	client := gh.client(host)
+	ctx, cancel := context.WithTimeout(ctx, ciLogsTimeout)
+	defer cancel()
+
+	var runs []github.WorkflowRun
+	if params.Run != 0 {
+		run, err := client.WorkflowRun(ctx, target, params.Run)
+		if err != nil {
+			return "", err
+		}
+		runs = []github.WorkflowRun{*run}
+	} else {
+		branch := params.Branch
+		if branch == "" {
+			if branch, err = repo.CurrentBranch(); err != nil {
+				return "", err
+			}
+		}
+		all, err := client.WorkflowRuns(ctx, target, branch)
+		if err != nil {
+			return "", err
+		}
+		if len(all) == 0 {
+			return fmt.Sprintf("No workflow runs for branch %s in %s.", branch, target), nil
+		}
+		runs = latestRuns(all)
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "Workflow runs for commit %s on %s in %s:\n", shortSHA(runs[0].HeadSHA), runs[0].HeadBranch, target)
+	var failed []github.WorkflowRun
+	for _, run := range runs {
+		fmt.Fprintf(&out, "- %s #%d (%s): %s %s\n", run.Name, run.RunNumber, run.Event, runState(run.Status, run.Conclusion), run.HTMLURL)
+		if run.Conclusion == "failure" || run.Conclusion == "timed_out" {
+			failed = append(failed, run)
+		}
+	}
+
+	fetched := 0
+	for _, run := range failed {
+		jobs, err := client.RunJobs(ctx, target, run.ID)
+		if err != nil {
+			fmt.Fprintf(&out, "\n(could not list the jobs of %s: %v)\n", run.Name, err)
+			continue
+		}
+		for _, job := range jobs {
+			if job.Conclusion != "failure" && job.Conclusion != "timed_out" {
+				continue
+			}
+			fmt.Fprintf(&out, "\n--- %s / %s: %s %s\n", run.Name, job.Name, runState(job.Status, job.Conclusion), job.HTMLURL)
+			for _, step := range job.Steps {
+				if step.Conclusion == "failure" || step.Conclusion == "timed_out" {
+					fmt.Fprintf(&out, "Failed step %d: %s\n", step.Number, step.Name)
+				}
+			}
+			if fetched == maxCIFailedJobs {
+				out.WriteString("(log not fetched: too many failed jobs)\n")
+				continue
+			}
+			fetched++
+			log, err := client.JobLog(ctx, target, job.ID)
+			if err != nil {
+				fmt.Fprintf(&out, "(could not fetch the log: %v)\n", err)
+				continue
+			}
+			writeCILog(&out, log)
+		}
+	}
+	if len(failed) == 0 {
+		out.WriteString("\nNo failed runs.\n")
+	}
+	return out.String(), nil
+}
+
+// latestRuns 返回最近一次运行的提交上每个工作流最新的运行，runs 按时间从新到旧排列
+func latestRuns(runs []github.WorkflowRun) []github.WorkflowRun {
+	sha := runs[0].HeadSHA
+	var latest []github.WorkflowRun
+	seen := map[string]bool{}
+	for _, run := range runs {
+		if run.HeadSHA != sha || seen[run.Name] {
+			continue
+		}
+		seen[run.Name] = true
+		latest = append(latest, run)
+	}
+	return latest
+}
+
+// runState 描述运行或作业的状态，结束的显示结论
+func runState(status, conclusion string) string {
+	if status == "completed" && conclusion != "" {
+		return conclusion
+	}
+	return strings.ReplaceAll(status, "_", " ")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// writeCILog 写入去掉时间戳的日志的最后 maxCILogLines 行
+func writeCILog(out *strings.Builder, log string) {
+	log = strings.TrimRight(ciTimestampPattern.ReplaceAllString(log, ""), "\n")
+	if log == "" {
+		out.WriteString("(empty log)\n")
+		return
+	}
+	lines := strings.Split(log, "\n")
+	if len(lines) > maxCILogLines {
+		fmt.Fprintf(out, "... (log truncated: showing the last %d of %d lines)\n", maxCILogLines, len(lines))
+		lines = lines[len(lines)-maxCILogLines:]
+	}
+	out.WriteString(strings.Join(lines, "\n") + "\n")
+}
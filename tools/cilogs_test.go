@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCILogs(t *testing.T) {
+	dir, _ := gitRepo(t)
+	require.NoError(t, exec.Command("git", "-C", dir, "remote", "set-url", "origin", "https://github.com/owner/repo.git").Run())
+
+	var log strings.Builder
+	for i := 1; i <= 200; i++ {
+		fmt.Fprintf(&log, "2024-05-01T10:00:00.1234567Z line %d\n", i)
+	}
+	log.WriteString("2024-05-01T10:00:01.0000000Z ##[error]Process completed with exit code 1.\n")
+
+	var branches []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/repos/owner/repo/actions/runs":
+			branches = append(branches, r.URL.Query().Get("branch"))
+			if r.URL.Query().Get("branch") == "empty" {
+				w.Write([]byte(`{"workflow_runs": []}`))
+				return
+			}
+			w.Write([]byte(`{"workflow_runs": [
+				{"id": 3, "name": "CI", "run_number": 12, "event": "push", "head_branch": "main", "head_sha": "abcdef1234", "status": "completed", "conclusion": "failure", "html_url": "https://github.com/owner/repo/actions/runs/3"},
+				{"id": 2, "name": "Lint", "run_number": 8, "event": "push", "head_branch": "main", "head_sha": "abcdef1234", "status": "in_progress", "html_url": "https://github.com/owner/repo/actions/runs/2"},
+				{"id": 1, "name": "CI", "run_number": 11, "event": "push", "head_branch": "main", "head_sha": "0123456789", "status": "completed", "conclusion": "success"}
+			]}`))
+		case "/repos/owner/repo/actions/runs/3/jobs":
+			w.Write([]byte(`{"jobs": [
+				{"id": 30, "name": "build", "status": "completed", "conclusion": "success"},
+				{"id": 31, "name": "test", "status": "completed", "conclusion": "failure", "html_url": "https://github.com/owner/repo/actions/runs/3/job/31",
+				 "steps": [{"number": 1, "name": "Checkout", "status": "completed", "conclusion": "success"}, {"number": 4, "name": "Run go test", "status": "completed", "conclusion": "failure"}]}
+			]}`))
+		case "/repos/owner/repo/actions/jobs/31/logs":
+			w.Write([]byte(log.String()))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tool := CILogsTool(&Workspace{Root: dir}, &GitHub{APIURL: server.URL, Token: func() string { return "secret" }})
+	call := func(input CILogsInput) (string, error) {
+		data, err := json.Marshal(input)
+		require.NoError(t, err)
+		return tool.Function(context.Background(), data).Output()
+	}
+
+	t.Run("最近提交上的运行和失败作业的日志", func(t *testing.T) {
+		out, err := call(CILogsInput{})
+		require.NoError(t, err)
+		assert.Equal(t, "main", branches[len(branches)-1], "默认使用当前分支")
+		assert.Contains(t, out, "Workflow runs for commit abcdef1 on main in owner/repo:")
+		assert.Contains(t, out, "- CI #12 (push): failure https://github.com/owner/repo/actions/runs/3")
+		assert.Contains(t, out, "- Lint #8 (push): in progress")
+		assert.NotContains(t, out, "#11", "只显示最近提交上的运行")
+		assert.Contains(t, out, "--- CI / test: failure")
+		assert.NotContains(t, out, "CI / build", "成功的作业不显示")
+		assert.Contains(t, out, "Failed step 4: Run go test")
+		assert.Contains(t, out, "showing the last 150 of 201 lines")
+		assert.Contains(t, out, "\nline 200\n##[error]Process completed with exit code 1.\n")
+		assert.NotContains(t, out, "line 51\n", "只保留日志末尾")
+		assert.NotContains(t, out, "2024-05-01T", "去掉时间戳")
+	})
+
+	t.Run("没有运行", func(t *testing.T) {
+		out, err := call(CILogsInput{Branch: "empty"})
+		require.NoError(t, err)
+		assert.Equal(t, "No workflow runs for branch empty in owner/repo.", out)
+	})
+
+	t.Run("API 错误", func(t *testing.T) {
+		_, err := call(CILogsInput{Run: 99})
+		assert.ErrorContains(t, err, "GitHub API error 404")
+	})
+}
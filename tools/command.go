@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// maxCommandNesting 限制 sh -c、eval 等嵌套脚本的检查深度
+const maxCommandNesting = 8
+
+// parsedCommand 是解析 shell 命令得到的、要逐一检查的内容
+type parsedCommand struct {
+	// commands 是每条简单命令规范化后的文本，包括管道、后台命令、子 shell、
+	// 命令替换和 sh -c 等嵌套脚本中的命令
+	commands []string
+	// pipelines 是每条管道规范化后的文本，供 curl * | sh 这样的模式匹配
+	pipelines []string
+}
+
+// parseCommand 用 shell 语法解析 command，找出其中会执行的每一条简单命令。
+// 命令名去掉路径，env、command、xargs 等包装命令替换为它们执行的命令，git 去掉全局选项。
+// strict 为 true 时（配置了允许列表）拒绝文件重定向、命令替换和无法确定的命令名，
+// 因为它们能在不匹配任何模式的情况下写文件或执行命令
+func parseCommand(command string, strict bool) (*parsedCommand, error) {
+	parsed := &parsedCommand{}
+	return parsed, parsed.parse(command, strict, 0)
+}
+
+func (p *parsedCommand) parse(script string, strict bool, depth int) error {
+	if depth > maxCommandNesting {
+		return fmt.Errorf("scripts are nested too deeply")
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(script), "")
+	if err != nil {
+		return fmt.Errorf("cannot parse the command: %w", err)
+	}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			err = p.call(n, strict, depth)
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.Pipe || n.Op == syntax.PipeAll {
+				p.pipelines = append(p.pipelines, pipelineText(n))
+			}
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			if strict {
+				err = fmt.Errorf("command substitution is not allowed with an allow list")
+			}
+		case *syntax.Redirect:
+			if strict && !harmlessRedirect(n) {
+				err = fmt.Errorf("redirection %q is not allowed with an allow list", nodeText(n))
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+// call 记录一条简单命令，并检查它执行的嵌套脚本
+func (p *parsedCommand) call(call *syntax.CallExpr, strict bool, depth int) error {
+	if len(call.Args) == 0 {
+		// 只有变量赋值
+		return nil
+	}
+	words := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		text, literal := wordText(arg)
+		if i == 0 && !literal && strict {
+			return fmt.Errorf("the command name %q is not a literal word", text)
+		}
+		words[i] = text
+	}
+	words, script := unwrapCommand(words)
+	if len(words) > 0 {
+		p.commands = append(p.commands, strings.Join(words, " "))
+	}
+	if script != "" {
+		return p.parse(script, strict, depth+1)
+	}
+	return nil
+}
+
+// shellPrograms 是 -c 参数为脚本的 shell
+var shellPrograms = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "ash": true}
+
+// wrapperOptions 是执行其他命令的包装命令，以及它们带参数的选项
+var wrapperOptions = map[string][]string{
+	"env":     {"-u", "--unset", "-C", "--chdir", "-S", "--split-string"},
+	"command": nil,
+	"builtin": nil,
+	"exec":    {"-a"},
+	"nohup":   nil,
+	"setsid":  nil,
+	"time":    nil,
+	"nice":    {"-n", "--adjustment"},
+	"ionice":  {"-c", "-n", "-p"},
+	"stdbuf":  {"-i", "-o", "-e"},
+	"timeout": {"-s", "--signal", "-k", "--kill-after"},
+	"xargs":   {"-a", "-d", "-E", "-I", "-L", "-n", "-P", "-s", "--arg-file", "--delimiter", "--max-args", "--max-procs"},
+}
+
+// gitOptions 是 git 带参数的全局选项
+var gitOptions = []string{"-C", "-c", "--git-dir", "--work-tree", "--namespace", "--super-prefix", "--config-env"}
+
+// unwrapCommand 规范化一条命令的各个词：命令名去掉路径，包装命令替换为它执行的命令，
+// git 去掉子命令之前的全局选项。sh -c、eval 和 env -S 执行的脚本单独返回
+func unwrapCommand(words []string) ([]string, string) {
+	for len(words) > 0 {
+		words[0] = path.Base(words[0])
+		name := words[0]
+		switch {
+		case name == "eval":
+			return nil, strings.Join(words[1:], " ")
+		case shellPrograms[name]:
+			if script, ok := shellScript(words[1:]); ok {
+				return nil, script
+			}
+			return words, ""
+		case name == "git":
+			rest := skipOptions(words[1:], gitOptions)
+			return append([]string{"git"}, rest...), ""
+		}
+		withArg, ok := wrapperOptions[name]
+		if !ok {
+			return words, ""
+		}
+		for i := 1; i < len(words); i++ {
+			if name == "env" && (words[i] == "-S" || words[i] == "--split-string") && i+1 < len(words) {
+				return nil, strings.Join(words[i+1:], " ")
+			}
+			if !strings.HasPrefix(words[i], "-") {
+				break
+			}
+		}
+		rest := skipOptions(words[1:], withArg)
+		switch name {
+		case "env":
+			for len(rest) > 0 && strings.Contains(rest[0], "=") {
+				rest = rest[1:]
+			}
+		case "timeout":
+			if len(rest) > 0 {
+				// 时长
+				rest = rest[1:]
+			}
+		}
+		if len(rest) == 0 {
+			return words[:1], ""
+		}
+		words = rest
+	}
+	return words, ""
+}
+
+// shellScript 返回 sh -c 执行的脚本：-c 之后第一个不是选项的参数
+func shellScript(args []string) (string, bool) {
+	script := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-o" || arg == "+o" || arg == "-O" || arg == "+O":
+			// 选项名是下一个参数
+			i++
+		case arg == "--":
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+"):
+			script = script || strings.Contains(arg[1:], "c")
+		default:
+			return arg, script
+		}
+	}
+	return "", false
+}
+
+// skipOptions 去掉开头以 - 开头的选项，withArg 中的选项连同其后的参数一起去掉
+func skipOptions(words, withArg []string) []string {
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") && words[0] != "-" {
+		option := words[0]
+		words = words[1:]
+		if option == "--" {
+			break
+		}
+		for _, o := range withArg {
+			if option == o && len(words) > 0 {
+				words = words[1:]
+				break
+			}
+		}
+	}
+	return words
+}
+
+// fdPattern 匹配 >& 之后的文件描述符，- 表示关闭
+var fdPattern = regexp.MustCompile(`^(\d+|-)$`)
+
+// harmlessRedirect 判断重定向是否只是复制文件描述符（2>&1）或丢弃输出（>/dev/null）
+func harmlessRedirect(r *syntax.Redirect) bool {
+	target, _ := wordText(r.Word)
+	switch r.Op {
+	case syntax.DplOut, syntax.DplIn:
+		return fdPattern.MatchString(target)
+	case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll:
+		return target == "/dev/null"
+	}
+	return false
+}
+
+// pipelineText 返回管道中各命令规范化后的文本，以 | 连接
+func pipelineText(cmd *syntax.BinaryCmd) string {
+	var parts []string
+	for _, stmt := range []*syntax.Stmt{cmd.X, cmd.Y} {
+		switch c := stmt.Cmd.(type) {
+		case *syntax.BinaryCmd:
+			if c.Op == syntax.Pipe || c.Op == syntax.PipeAll {
+				parts = append(parts, pipelineText(c))
+				continue
+			}
+		case *syntax.CallExpr:
+			var words []string
+			for _, arg := range c.Args {
+				text, _ := wordText(arg)
+				words = append(words, text)
+			}
+			if words, _ = unwrapCommand(words); len(words) > 0 {
+				parts = append(parts, strings.Join(words, " "))
+				continue
+			}
+		}
+		parts = append(parts, nodeText(stmt))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// wordText 返回 shell 执行时这个词的文本，去掉引号和转义。
+// 含有变量展开或命令替换时返回它的源码，literal 为 false
+func wordText(word *syntax.Word) (text string, literal bool) {
+	if word == nil {
+		return "", true
+	}
+	var b strings.Builder
+	literal = true
+	for _, part := range word.Parts {
+		switch x := part.(type) {
+		case *syntax.Lit:
+			b.WriteString(unescape(x.Value))
+		case *syntax.SglQuoted:
+			b.WriteString(x.Value)
+		case *syntax.DblQuoted:
+			for _, inner := range x.Parts {
+				if lit, ok := inner.(*syntax.Lit); ok {
+					b.WriteString(lit.Value)
+				} else {
+					b.WriteString(nodeText(inner))
+					literal = false
+				}
+			}
+		default:
+			b.WriteString(nodeText(x))
+			literal = false
+		}
+	}
+	return b.String(), literal
+}
+
+// unescape 去掉未加引号的文本中的反斜杠转义
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// nodeText 返回语法节点的源码
+func nodeText(node syntax.Node) string {
+	var b strings.Builder
+	syntax.NewPrinter(syntax.SingleLine(true)).Print(&b, node)
+	return b.String()
+}
@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+type confirmKey struct{}
+
+// WithConfirm 返回带有 confirm 的 ctx。工具中修改本机状态的操作（例如 docker 的 build 和 compose）
+// 执行前用它征得用户同意；ctx 中没有时视为没有人可以回答，操作被拒绝
+func WithConfirm(ctx context.Context, confirm func(ctx context.Context, question string) bool) context.Context {
+	return context.WithValue(ctx, confirmKey{}, confirm)
+}
+
+// Approved 返回调用已经得到批准的 ctx，其中的确认都直接同意
+func Approved(ctx context.Context) context.Context {
+	return WithConfirm(ctx, func(context.Context, string) bool { return true })
+}
+
+// confirm 用 ctx 中的确认函数询问是否执行 command，没有人可以回答或用户不同意时返回 ErrorPermissionDenied 错误
+func confirm(ctx context.Context, command string) error {
+	ask, _ := ctx.Value(confirmKey{}).(func(ctx context.Context, question string) bool)
+	if ask == nil {
+		return WithKind(ErrorPermissionDenied, fmt.Errorf("%s needs the user's approval, and nobody can give it in this run", command))
+	}
+	if !ask(ctx, fmt.Sprintf("Run %s?", command)) {
+		return WithKind(ErrorPermissionDenied, fmt.Errorf("the user declined to run %s", command))
+	}
+	return nil
+}
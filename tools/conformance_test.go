@@ -0,0 +1,30 @@
+package tools_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"agent/tools"
+	"agent/tools/tooltest"
+)
+
+func TestToolConformance(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		tool  tooltest.Factory
+		valid []string
+	}{
+		{"read_file", tools.ReadFileTool, []string{`{"path": "a.go"}`, `{"path": "sub/b.txt"}`}},
+		{"edit_file", tools.EditFileTool, []string{`{"path": "a.go", "old_str": "A()", "new_str": "B()"}`, `{"path": "new/c.txt", "old_str": "", "new_str": "c\n"}`}},
+		{"grep", tools.GrepTool, []string{`{"pattern": "func"}`, `{"pattern": "hello", "path": "sub"}`}},
+		{"glob", tools.GlobTool, []string{`{"pattern": "**/*.txt"}`, `{"pattern": "*.go", "path": "sub"}`}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var valid []json.RawMessage
+			for _, input := range c.valid {
+				valid = append(valid, json.RawMessage(input))
+			}
+			tooltest.Run(t, c.tool, tooltest.Options{Valid: valid})
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// coverageTimeout 是运行测试收集覆盖率的最长时间
+const coverageTimeout = 10 * time.Minute
+
+// maxCoverageFunctions 是结果中最多列出的函数数
+const maxCoverageFunctions = 40
+
+// CoverageInput 定义 coverage 工具的输入参数
+type CoverageInput struct {
+	Package string `json:"package,omitempty" jsonschema_description:"The package pattern to test, default ./..."`
+	File    string `json:"file,omitempty" jsonschema_description:"A relative path of a Go file: list all of its functions and the line ranges no test runs."`
+}
+
+// Coverage 运行测试收集 Go 代码的覆盖率
+type Coverage struct {
+	// Go 是 go 命令，为空时为 go
+	Go string
+}
+
+func init() {
+	// 测试会执行项目的代码，因此不注册为只读
+	Register(Spec{Name: "coverage", Category: CategoryCode, Permissions: []Permission{PermissionRead, PermissionExec},
+		New: func(env Env) (ToolDefinition, bool) {
+			return CoverageTool(env.Workspace, &Coverage{}), env.GoModule
+		}})
+}
+
+// CoverageTool 返回在工作区 w 中统计测试覆盖率的工具定义
+func CoverageTool(w *Workspace, c *Coverage) ToolDefinition {
+	return ToolDefinition{
+		Name: "coverage",
+		Description: "Run the Go tests with a coverage profile and report the statement coverage of each package and the least covered " +
+			"functions. Give a file to see all of its functions and the exact lines no test runs. Use it to decide which tests to write " +
+			"when asked to improve test coverage.",
+		InputSchema: GenerateSchema[CoverageInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.Coverage(ctx, c, input))
+		},
+	}
+}
+
+// coverBlock 是覆盖率文件中的一个语句块
+type coverBlock struct {
+	startLine, startCol, endLine, endCol int
+	statements                           int
+	covered                              bool
+}
+
+// Coverage 运行测试并返回覆盖率报告
+func (w *Workspace) Coverage(ctx context.Context, c *Coverage, input json.RawMessage) (string, error) {
+	var params CoverageInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	pkg := params.Package
+	if pkg == "" {
+		pkg = "./..."
+	}
+	if strings.HasPrefix(pkg, "-") {
+		return "", invalidInput("package %q must be a package pattern such as ./... or ./internal/cache", pkg)
+	}
+	if params.File != "" {
+		if _, err := w.Resolve(params.File); err != nil {
+			return "", err
+		}
+	}
+	module, err := w.modulePath()
+	if err != nil {
+		return "", err
+	}
+
+	profile, err := os.CreateTemp("", "coverage-*.out")
+	if err != nil {
+		return "", err
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	goCmd := c.Go
+	if goCmd == "" {
+		goCmd = "go"
+	}
+	ctx, cancel := context.WithTimeout(ctx, coverageTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, goCmd, "test", "-covermode=set", "-coverprofile="+profile.Name(), pkg)
+	killOnCancel(cmd)
+	cmd.Dir = w.Root
+	output := &tailBuffer{limit: maxCommandOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("tests timed out after %s; select fewer packages", coverageTimeout)
+	}
+
+	data, err := os.ReadFile(profile.Name())
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		if runErr != nil {
+			return "", fmt.Errorf("go test failed (%w):\n%s", runErr, output)
+		}
+		return "", fmt.Errorf("go test wrote no coverage profile:\n%s", output)
+	}
+	files, err := parseCoverProfile(data)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if runErr != nil {
+		// 有测试失败时覆盖率仍然有意义，但要让模型知道
+		fmt.Fprintf(&b, "Some tests failed, so the coverage may be lower than it should be:\n%s\n", output)
+	}
+	writePackageCoverage(&b, files)
+	funcs := w.functionCoverage(module, files)
+	if params.File != "" {
+		name := path.Join(module, filepath.ToSlash(filepath.Clean(params.File)))
+		writeFileCoverage(&b, params.File, funcs[name], files[name])
+	} else {
+		writeLeastCovered(&b, funcs)
+	}
+	return b.String(), nil
+}
+
+// modulePath 返回工作区 go.mod 中声明的模块路径
+func (w *Workspace) modulePath() (string, error) {
+	data, err := os.ReadFile(filepath.Join(w.Root, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("go.mod has no module line")
+}
+
+// parseCoverProfile 解析覆盖率文件，返回每个文件（以导入路径表示）的语句块。
+// 同时测试多个包时同一个块可能出现多次，任意一次执行过即算覆盖
+func parseCoverProfile(data []byte) (map[string][]coverBlock, error) {
+	files := map[string][]coverBlock{}
+	index := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// name.go:12.34,15.2 3 1
+		name, rest, ok := cutLastString(line, ":")
+		fields := strings.Fields(rest)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+		var block coverBlock
+		if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &block.startLine, &block.startCol, &block.endLine, &block.endCol); err != nil {
+			return nil, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+		block.statements, _ = strconv.Atoi(fields[1])
+		count, _ := strconv.Atoi(fields[2])
+		block.covered = count > 0
+
+		key := name + ":" + fields[0]
+		if i, ok := index[key]; ok {
+			files[name][i].covered = files[name][i].covered || block.covered
+			continue
+		}
+		index[key] = len(files[name])
+		files[name] = append(files[name], block)
+	}
+	return files, scanner.Err()
+}
+
+func cutLastString(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// coverageCount 返回语句块中被覆盖的语句数和总语句数
+func coverageCount(blocks []coverBlock) (covered, total int) {
+	for _, block := range blocks {
+		total += block.statements
+		if block.covered {
+			covered += block.statements
+		}
+	}
+	return covered, total
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(covered) * 100 / float64(total)
+}
+
+// writePackageCoverage 按包列出覆盖率
+func writePackageCoverage(b *strings.Builder, files map[string][]coverBlock) {
+	packages := map[string][]coverBlock{}
+	all := []coverBlock{}
+	for name, blocks := range files {
+		packages[path.Dir(name)] = append(packages[path.Dir(name)], blocks...)
+		all = append(all, blocks...)
+	}
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "package\tcoverage\tstatements")
+	for _, name := range names {
+		covered, total := coverageCount(packages[name])
+		fmt.Fprintf(tw, "%s\t%.1f%%\t%d/%d\n", name, percent(covered, total), covered, total)
+	}
+	covered, total := coverageCount(all)
+	fmt.Fprintf(tw, "total\t%.1f%%\t%d/%d\n", percent(covered, total), covered, total)
+	tw.Flush()
+}
+
+// funcCoverage 是一个函数的覆盖率
+type funcCoverage struct {
+	file           string
+	name           string
+	line           int
+	covered, total int
+}
+
+// functionCoverage 解析模块中各文件的源码，统计每个函数的覆盖率，结果按文件分组
+func (w *Workspace) functionCoverage(module string, files map[string][]coverBlock) map[string][]funcCoverage {
+	result := map[string][]funcCoverage{}
+	for name, blocks := range files {
+		rel, ok := strings.CutPrefix(name, module+"/")
+		if !ok {
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filepath.Join(w.Root, filepath.FromSlash(rel)), nil, 0)
+		if err != nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+			f := funcCoverage{file: rel, name: funcName(fn), line: start.Line}
+			for _, block := range blocks {
+				after := block.startLine > start.Line || block.startLine == start.Line && block.startCol >= start.Column
+				before := block.endLine < end.Line || block.endLine == end.Line && block.endCol <= end.Column
+				if !after || !before {
+					continue
+				}
+				f.total += block.statements
+				if block.covered {
+					f.covered += block.statements
+				}
+			}
+			result[name] = append(result[name], f)
+		}
+	}
+	return result
+}
+
+// funcName 返回函数名，方法带上接收者类型，例如 (*Cache).Get
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	var recv func(ast.Expr) string
+	recv = func(expr ast.Expr) string {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			return "*" + recv(t.X)
+		case *ast.IndexExpr:
+			return recv(t.X)
+		case *ast.IndexListExpr:
+			return recv(t.X)
+		case *ast.Ident:
+			return t.Name
+		}
+		return "?"
+	}
+	return "(" + recv(fn.Recv.List[0].Type) + ")." + fn.Name.Name
+}
+
+// writeLeastCovered 列出覆盖率最低的函数
+func writeLeastCovered(b *strings.Builder, files map[string][]funcCoverage) {
+	var funcs []funcCoverage
+	for _, fs := range files {
+		for _, f := range fs {
+			if f.total > 0 && f.covered < f.total {
+				funcs = append(funcs, f)
+			}
+		}
+	}
+	if len(funcs) == 0 {
+		b.WriteString("\nAll functions are fully covered.\n")
+		return
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		pi, pj := percent(funcs[i].covered, funcs[i].total), percent(funcs[j].covered, funcs[j].total)
+		if pi != pj {
+			return pi < pj
+		}
+		// 同样的覆盖率时未覆盖语句多的排在前面
+		if ui, uj := funcs[i].total-funcs[i].covered, funcs[j].total-funcs[j].covered; ui != uj {
+			return ui > uj
+		}
+		if funcs[i].file != funcs[j].file {
+			return funcs[i].file < funcs[j].file
+		}
+		return funcs[i].line < funcs[j].line
+	})
+	fmt.Fprintf(b, "\nLeast covered functions (%d not fully covered):\n", len(funcs))
+	tw := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	for i, f := range funcs {
+		if i == maxCoverageFunctions {
+			fmt.Fprintf(tw, "... %d more; give a file to see all of its functions\n", len(funcs)-i)
+			break
+		}
+		fmt.Fprintf(tw, "%s:%d\t%s\t%.1f%%\n", f.file, f.line, f.name, percent(f.covered, f.total))
+	}
+	tw.Flush()
+}
+
+// writeFileCoverage 列出文件中每个函数的覆盖率和未覆盖的行
+func writeFileCoverage(b *strings.Builder, file string, funcs []funcCoverage, blocks []coverBlock) {
+	if len(blocks) == 0 {
+		fmt.Fprintf(b, "\n%s has no coverage data; it may be a test file or not in the tested packages.\n", file)
+		return
+	}
+	fmt.Fprintf(b, "\nFunctions in %s:\n", file)
+	tw := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	for _, f := range funcs {
+		fmt.Fprintf(tw, "%d\t%s\t%.1f%%\n", f.line, f.name, percent(f.covered, f.total))
+	}
+	tw.Flush()
+
+	var ranges []string
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].startLine < blocks[j].startLine })
+	for _, block := range blocks {
+		if block.covered {
+			continue
+		}
+		r := strconv.Itoa(block.startLine)
+		if block.endLine > block.startLine {
+			r += "-" + strconv.Itoa(block.endLine)
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		b.WriteString("\nEvery statement is covered.\n")
+		return
+	}
+	fmt.Fprintf(b, "\nLines not run by any test: %s\n", strings.Join(ranges, ", "))
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const coverageSource = `package cache
+
+type Cache struct{ m map[string]int }
+
+func (c *Cache) Get(key string) int {
+	if v, ok := c.m[key]; ok {
+		return v
+	}
+	return -1
+}
+
+func New() *Cache {
+	return &Cache{m: map[string]int{}}
+}
+`
+
+const coverageProfile = `mode: set
+example.com/m/cache/cache.go:5.37,6.26 1 1
+example.com/m/cache/cache.go:6.26,8.3 1 0
+example.com/m/cache/cache.go:9.2,9.11 1 1
+example.com/m/cache/cache.go:12.18,14.2 1 0
+example.com/m/cache/cache.go:12.18,14.2 1 1
+`
+
+func TestParseCoverProfile(t *testing.T) {
+	files, err := parseCoverProfile([]byte(coverageProfile))
+	require.NoError(t, err)
+	blocks := files["example.com/m/cache/cache.go"]
+	require.Len(t, blocks, 4, "重复的块只保留一个")
+	assert.True(t, blocks[3].covered, "任意一次执行过即算覆盖")
+	covered, total := coverageCount(blocks)
+	assert.Equal(t, [2]int{3, 4}, [2]int{covered, total})
+
+	_, err = parseCoverProfile([]byte("mode: set\nbroken\n"))
+	assert.Error(t, err)
+}
+
+func TestCoverage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/m\n\ngo 1.21\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(root, "cache"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cache", "cache.go"), []byte(coverageSource), 0644))
+	profile := filepath.Join(t.TempDir(), "profile")
+	require.NoError(t, os.WriteFile(profile, []byte(coverageProfile), 0644))
+	goCmd := filepath.Join(t.TempDir(), "go")
+	require.NoError(t, os.WriteFile(goCmd, []byte(`#!/bin/sh
+for arg; do case $arg in -coverprofile=*) cp `+profile+` "${arg#-coverprofile=}";; esac; done
+`), 0755))
+	w := &Workspace{Root: root}
+	c := &Coverage{Go: goCmd}
+
+	out, err := w.Coverage(context.Background(), c, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.Contains(t, out, "example.com/m/cache  75.0%     3/4")
+	assert.Contains(t, out, "cache/cache.go:5  (*Cache).Get  66.7%")
+	assert.NotContains(t, out, "New", "完全覆盖的函数不列出")
+
+	out, err = w.Coverage(context.Background(), c, json.RawMessage(`{"file":"cache/cache.go"}`))
+	require.NoError(t, err)
+	assert.Contains(t, out, "12  New           100.0%")
+	assert.Contains(t, out, "Lines not run by any test: 6-8")
+
+	_, err = w.Coverage(context.Background(), c, json.RawMessage(`{"file":"../x.go"}`))
+	assert.Equal(t, ErrorInvalidInput, KindOf(err))
+}
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dockerTimeout 是 ps、logs 等查看命令的最长执行时间，dockerBuildTimeout 是构建镜像和启动服务的
+const (
+	dockerTimeout      = time.Minute
+	dockerBuildTimeout = 15 * time.Minute
+)
+
+// dockerLogTail 是 logs 默认显示的行数
+const dockerLogTail = 200
+
+// dockerChangesState 是修改本机状态的操作，执行前要征得用户同意，见 WithConfirm
+var dockerChangesState = map[string]bool{"build": true, "compose_up": true, "compose_down": true}
+
+// DockerInput 定义 docker 工具的输入参数
+type DockerInput struct {
+	Action    string   `json:"action" jsonschema:"enum=ps,enum=logs,enum=images,enum=build,enum=compose_up,enum=compose_down" jsonschema_description:"ps: list containers. logs: show the logs of a container. images: list images. build: build an image from a Dockerfile in the workspace. compose_up: build and start the compose services in the background. compose_down: stop and remove them."`
+	Container string   `json:"container,omitempty" jsonschema_description:"The container name or ID for logs."`
+	Tail      int      `json:"tail,omitempty" jsonschema_description:"Number of log lines to show, default 200."`
+	Tag       string   `json:"tag,omitempty" jsonschema_description:"The image tag for build, e.g. myapp:dev."`
+	Context   string   `json:"context,omitempty" jsonschema_description:"The relative build context directory for build, default the workspace root."`
+	File      string   `json:"file,omitempty" jsonschema_description:"The relative path of the Dockerfile for build, or of the compose file for compose_up and compose_down."`
+	Services  []string `json:"services,omitempty" jsonschema_description:"The compose services to start; all of them when empty."`
+}
+
+// Docker 是 docker 工具使用的容器运行时
+type Docker struct {
+	// Runtime 为 docker 或 podman，为空时为 docker
+	Runtime string
+}
+
+func init() {
+	// build 和 compose 会修改本机状态，因此不注册为只读，执行前总要征得同意：
+	// 逐次批准调用的前端在调用前询问，其他前端由工具通过 WithConfirm 询问
+	Register(Spec{Name: "docker", Category: CategoryIntegrations, Permissions: []Permission{PermissionRead, PermissionExec},
+		New: func(env Env) (ToolDefinition, bool) {
+			// docker 在本机执行，远程工作区中不提供
+			return DockerTool(env.Workspace, env.Docker), env.Docker != nil && env.Workspace.Remote == nil
+		}})
+}
+
+// DockerTool 返回在工作区 w 中构建镜像、管理 compose 服务和查看容器的工具定义
+func DockerTool(w *Workspace, d *Docker) ToolDefinition {
+	return ToolDefinition{
+		Name:        "docker",
+		Description: "Build images and run the compose services of the project, and inspect running containers and their logs. Use it to check that the project still builds and starts in its containers.",
+		InputSchema: GenerateSchema[DockerInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.Docker(ctx, d, input, nil))
+		},
+		Stream: func(ctx context.Context, input json.RawMessage, output io.Writer) ToolResult {
+			return Result(w.Docker(ctx, d, input, output))
+		},
+	}
+}
+
+// Docker 执行 docker 工具的操作，live 不为 nil 时同时把输出实时写入 live
+func (w *Workspace) Docker(ctx context.Context, d *Docker, input json.RawMessage, live io.Writer) (string, error) {
+	var params DockerInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	args, timeout, err := w.dockerCommand(d, params)
+	if err != nil {
+		return "", err
+	}
+	if dockerChangesState[params.Action] {
+		if err := confirm(ctx, strings.Join(args, " ")); err != nil {
+			return "", err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	killOnCancel(cmd)
+	cmd.Dir = w.Root
+	output := &tailBuffer{limit: maxCommandOutput}
+	var sink io.Writer = output
+	if live != nil {
+		sink = io.MultiWriter(output, live)
+	}
+	cmd.Stdout = sink
+	cmd.Stderr = sink
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out after %s:\n%s", params.Action, timeout, output)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed (%w):\n%s", params.Action, err, output)
+	}
+	if output.Len() == 0 {
+		return "(no output)", nil
+	}
+	return output.String(), nil
+}
+
+// dockerCommand 返回执行 params 的命令行和时间上限，路径参数必须在工作区内
+func (w *Workspace) dockerCommand(d *Docker, params DockerInput) ([]string, time.Duration, error) {
+	runtime := d.Runtime
+	if runtime == "" {
+		runtime = "docker"
+	}
+	for _, path := range []string{params.Context, params.File} {
+		if path != "" {
+			if _, err := w.Resolve(path); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	compose := []string{runtime, "compose"}
+	if params.File != "" {
+		compose = append(compose, "-f", params.File)
+	}
+
+	switch params.Action {
+	case "ps":
+		return []string{runtime, "ps", "--all"}, dockerTimeout, nil
+	case "images":
+		return []string{runtime, "images"}, dockerTimeout, nil
+	case "logs":
+		if params.Container == "" {
+			return nil, 0, invalidInput("container is required for logs")
+		}
+		tail := params.Tail
+		if tail <= 0 {
+			tail = dockerLogTail
+		}
+		return []string{runtime, "logs", "--tail", strconv.Itoa(tail), "--", params.Container}, dockerTimeout, nil
+	case "build":
+		args := []string{runtime, "build"}
+		if params.Tag != "" {
+			args = append(args, "-t", params.Tag)
+		}
+		if params.File != "" {
+			args = append(args, "-f", params.File)
+		}
+		dir := params.Context
+		if dir == "" {
+			dir = "."
+		}
+		return append(args, dir), dockerBuildTimeout, nil
+	case "compose_up":
+		return append(append(compose, "up", "--build", "--detach"), params.Services...), dockerBuildTimeout, nil
+	case "compose_down":
+		return append(compose, "down"), dockerTimeout, nil
+	}
+	return nil, 0, invalidInput("unknown action %q (use ps, logs, images, build, compose_up or compose_down)", params.Action)
+}
+
+// tailBuffer 保存写入内容的最后 limit 个字节，构建失败的原因通常在输出的末尾
+type tailBuffer struct {
+	limit   int
+	buf     []byte
+	dropped bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = append(b.buf[:0:0], b.buf[over:]...)
+		b.dropped = true
+	}
+	return len(p), nil
+}
+
+// Len 返回保存的字节数
+func (b *tailBuffer) Len() int {
+	return len(b.buf)
+}
+
+// String 返回保存的内容，丢弃过开头时加上说明
+func (b *tailBuffer) String() string {
+	if !b.dropped {
+		return string(b.buf)
+	}
+	return fmt.Sprintf("(earlier output omitted; showing the last %s)\n", formatBytes(int64(b.limit))) + string(bytes.ToValidUTF8(b.buf, nil))
+}
@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerCommand(t *testing.T) {
+	w := &Workspace{Root: t.TempDir()}
+	d := &Docker{}
+
+	for _, c := range []struct {
+		params DockerInput
+		want   []string
+	}{
+		{DockerInput{Action: "ps"}, []string{"docker", "ps", "--all"}},
+		{DockerInput{Action: "logs", Container: "api"}, []string{"docker", "logs", "--tail", "200", "--", "api"}},
+		{DockerInput{Action: "build", Tag: "app:dev", File: "deploy/Dockerfile"}, []string{"docker", "build", "-t", "app:dev", "-f", "deploy/Dockerfile", "."}},
+		{DockerInput{Action: "compose_up", Services: []string{"db"}}, []string{"docker", "compose", "up", "--build", "--detach", "db"}},
+		{DockerInput{Action: "compose_down", File: "compose.dev.yml"}, []string{"docker", "compose", "-f", "compose.dev.yml", "down"}},
+	} {
+		args, _, err := w.dockerCommand(d, c.params)
+		require.NoError(t, err, c.params.Action)
+		assert.Equal(t, c.want, args)
+	}
+
+	args, _, err := w.dockerCommand(&Docker{Runtime: "podman"}, DockerInput{Action: "images"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"podman", "images"}, args)
+
+	t.Run("无效的输入", func(t *testing.T) {
+		for _, params := range []DockerInput{{Action: "logs"}, {Action: "run"}, {Action: "build", Context: "../other"}, {Action: "compose_up", File: "/etc/compose.yml"}} {
+			_, _, err := w.dockerCommand(d, params)
+			assert.Equal(t, ErrorInvalidInput, KindOf(err), params)
+		}
+	})
+}
+
+func TestDockerRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	fake := func(script string) *Docker {
+		path := filepath.Join(t.TempDir(), "docker")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+		return &Docker{Runtime: path}
+	}
+	w := &Workspace{Root: t.TempDir()}
+
+	t.Run("输出实时写出", func(t *testing.T) {
+		var live bytes.Buffer
+		out, err := w.Docker(context.Background(), fake(`echo "$@"`), json.RawMessage(`{"action":"ps"}`), &live)
+		require.NoError(t, err)
+		assert.Equal(t, "ps --all\n", out)
+		assert.Equal(t, out, live.String())
+	})
+
+	t.Run("失败时只保留输出的末尾", func(t *testing.T) {
+		_, err := w.Docker(Approved(context.Background()), fake(`i=0; while [ $i -lt 3000 ]; do echo "step $i of the build"; i=$((i+1)); done; echo "ERROR: go build failed" >&2; exit 1`), json.RawMessage(`{"action":"build"}`), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "earlier output omitted")
+		assert.True(t, strings.HasSuffix(err.Error(), "ERROR: go build failed\n"))
+	})
+
+	t.Run("构建和 compose 要征得用户同意", func(t *testing.T) {
+		docker := fake(`echo "$@"`)
+		input := json.RawMessage(`{"action":"compose_down"}`)
+		_, err := w.Docker(context.Background(), docker, input, nil)
+		assert.Equal(t, ErrorPermissionDenied, KindOf(err), "没有人可以回答时拒绝")
+
+		var questions []string
+		answer := false
+		ctx := WithConfirm(context.Background(), func(_ context.Context, question string) bool {
+			questions = append(questions, question)
+			return answer
+		})
+		_, err = w.Docker(ctx, docker, input, nil)
+		assert.Equal(t, ErrorPermissionDenied, KindOf(err))
+		assert.ErrorContains(t, err, "the user declined")
+
+		answer = true
+		out, err := w.Docker(ctx, docker, input, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "compose down\n", out)
+		assert.Equal(t, []string{"Run " + docker.Runtime + " compose down?", "Run " + docker.Runtime + " compose down?"}, questions)
+
+		_, err = w.Docker(context.Background(), docker, json.RawMessage(`{"action":"ps"}`), nil)
+		assert.NoError(t, err, "查看命令不需要确认")
+	})
+}
+
+// FuzzDockerCommand 检查生成的命令行以容器运行时开头，引用的路径都在工作区内
+func FuzzDockerCommand(f *testing.F) {
+	for _, input := range []string{`{"action": "ps"}`, `{"action": "logs", "container": "api", "tail": 50}`, `{"action": "logs", "container": "--help"}`,
+		`{"action": "build", "context": "app", "file": "app/Dockerfile", "tag": "api:dev"}`, `{"action": "build", "context": "../.."}`,
+		`{"action": "compose_up", "file": "/etc/compose.yml", "services": ["api"]}`, `{"action": "compose_down"}`, `{"action": "rm"}`} {
+		f.Add([]byte(input))
+	}
+	w := &Workspace{Root: f.TempDir()}
+	d := &Docker{}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		var params DockerInput
+		if json.Unmarshal(input, &params) != nil {
+			return
+		}
+		args, timeout, err := w.dockerCommand(d, params)
+		if err != nil {
+			return
+		}
+		assert.Equal(t, "docker", args[0])
+		assert.Positive(t, timeout)
+		for _, path := range []string{params.Context, params.File} {
+			if path != "" {
+				resolved, err := w.Resolve(path)
+				assert.NoError(t, err)
+				assert.True(t, within(w.Root, resolved), path)
+			}
+		}
+		if params.Action == "logs" {
+			assert.Equal(t, []string{"--", params.Container}, args[len(args)-2:], "容器名不能被当作参数")
+		}
+	})
+}
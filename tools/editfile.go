@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/diff"
+)
+
+// EditFileInput 定义编辑文件工具的输入参数
+type EditFileInput struct {
+	Path   string `json:"path" jsonschema_description:"The relative path of the file to edit."`
+	OldStr string `json:"old_str" jsonschema_description:"Text to search for. Must match exactly and appear exactly once in the file. Use an empty string to create a new file."`
+	NewStr string `json:"new_str" jsonschema_description:"Text to replace old_str with."`
+}
+
+// ProposedEdit 是一次 edit_file 调用将要做的修改
+type ProposedEdit struct {
+	// Name 是模型给出的相对路径，Path 是它在工作区中的位置
+	Name string
+	Path string
+	// Before 和 After 是修改前后的内容，新建文件时 Before 为空
+	Before string
+	After  string
+	// Create 表示文件不存在，将被创建
+	Create bool
+	mode   os.FileMode
+}
+
+// ProposeEdit 检查 edit_file 的输入并计算修改后的内容，不写入文件；远程工作区不支持
+func (w *Workspace) ProposeEdit(input json.RawMessage) (*ProposedEdit, error) {
+	var params EditFileInput
+	err := json.Unmarshal(input, &params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input: %w", err)
+	}
+	if w.Remote != nil {
+		return nil, fmt.Errorf("edits in a remote workspace cannot be previewed")
+	}
+	path, err := w.Resolve(params.Path)
+	if err != nil {
+		return nil, err
+	}
+	if params.OldStr == params.NewStr {
+		return nil, invalidInput("old_str and new_str must be different")
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) && params.OldStr == "" {
+		if err := checkSize(params.Path, int64(len(params.NewStr)), w.MaxWriteBytes, "write"); err != nil {
+			return nil, err
+		}
+		return &ProposedEdit{Name: params.Path, Path: path, After: params.NewStr, Create: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", params.Path, err)
+	}
+	if err := checkSize(params.Path, info.Size(), w.MaxWriteBytes, "write"); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", params.Path, err)
+	}
+
+	if params.OldStr == "" {
+		return nil, invalidInput("file %s already exists; old_str must not be empty", params.Path)
+	}
+	count := strings.Count(string(content), params.OldStr)
+	if count == 0 {
+		return nil, invalidInput("old_str not found in %s", params.Path)
+	}
+	if count > 1 {
+		return nil, invalidInput("old_str appears %d times in %s; include more context to make it unique", count, params.Path)
+	}
+
+	updated := strings.Replace(string(content), params.OldStr, params.NewStr, 1)
+	if err := checkSize(params.Path, int64(len(updated)), w.MaxWriteBytes, "write"); err != nil {
+		return nil, fmt.Errorf("edited %w", err)
+	}
+	return &ProposedEdit{Name: params.Path, Path: path, Before: string(content), After: updated, mode: info.Mode().Perm()}, nil
+}
+
+// EditFile 将工作区文件中唯一出现的 old_str 替换为 new_str；old_str 为空且文件不存在时创建文件
+func (w *Workspace) EditFile(ctx context.Context, input json.RawMessage) (string, error) {
+	if w.Remote != nil {
+		var params EditFileInput
+		if err := json.Unmarshal(input, &params); err != nil {
+			return "", fmt.Errorf("failed to parse input: %w", err)
+		}
+		text, _, _, err := w.remoteEditFile(ctx, params)
+		return text, err
+	}
+	edit, err := w.ProposeEdit(input)
+	if err != nil {
+		return "", err
+	}
+	if edit.Create {
+		return createFile(edit.Path, edit.Name, edit.After)
+	}
+	if err := os.WriteFile(edit.Path, []byte(edit.After), edit.mode); err != nil {
+		return "", fmt.Errorf("failed to write file %s: %w", edit.Name, err)
+	}
+	w.Reads.Forget(edit.Path)
+	if err := verifyWritten(edit.Path, edit.Name, edit.After); err != nil {
+		return "", err
+	}
+	return "OK", nil
+}
+
+// editFile 执行 EditFile，结果带有修改的 diff
+func (w *Workspace) editFile(ctx context.Context, input json.RawMessage) ToolResult {
+	var params EditFileInput
+	if w.Remote != nil {
+		if err := json.Unmarshal(input, &params); err != nil {
+			return ErrorResult(fmt.Errorf("failed to parse input: %w", err))
+		}
+		text, before, after, err := w.remoteEditFile(ctx, params)
+		if err != nil {
+			return ErrorResult(err)
+		}
+		return ToolResult{Text: text, Diff: diff.Unified(params.Path, before, after), Files: []FileRef{{Path: filepath.ToSlash(params.Path)}}}
+	}
+	before := ""
+	if json.Unmarshal(input, &params) == nil {
+		if path, err := w.Resolve(params.Path); err == nil {
+			content, _ := os.ReadFile(path)
+			before = string(content)
+		}
+	}
+	text, err := w.EditFile(ctx, input)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	path, err := w.Resolve(params.Path)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		return ErrorResult(fmt.Errorf("failed to read file %s: %w", params.Path, err))
+	}
+	return ToolResult{
+		Text:  text,
+		Diff:  diff.Unified(params.Path, before, string(after)),
+		Files: []FileRef{{Path: filepath.ToSlash(params.Path)}},
+	}
+}
+
+// createFile 在 path 处创建文件，name 为模型给出的相对路径，用于提示信息
+func createFile(path, name, content string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", filepath.Dir(name), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to create file %s: %w", name, err)
+	}
+	if err := verifyWritten(path, name, content); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Created %s", name), nil
+}
+
+// verifyWritten 重新读取写入的文件，确认内容就是预期的内容；编辑器、格式化工具或
+// 另一个进程可能在写入后立刻改动了文件
+func verifyWritten(path, name, want string) error {
+	got, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s after writing it: %w", name, err)
+	}
+	if string(got) != want {
+		return fmt.Errorf("the edit to %s did not apply: its contents differ from what was written; read the file again before editing it", name)
+	}
+	return nil
+}
+
+// EditFileTool 返回在工作区 w 中编辑文件的工具定义
+func EditFileTool(w *Workspace) ToolDefinition {
+	return ToolDefinition{
+		Name: "edit_file",
+		Description: `Make edits to a text file.
+
+Replaces 'old_str' with 'new_str' in the given file. 'old_str' and 'new_str' MUST be different from each other, and 'old_str' must appear exactly once in the file.
+
+If the file specified with path doesn't exist and 'old_str' is empty, it will be created with 'new_str' as its content.`,
+		InputSchema: GenerateSchema[EditFileInput](),
+		Function:    w.editFile,
+	}
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func editFile(t *testing.T, ws *Workspace, input EditFileInput) (string, error) {
+	t.Helper()
+	inputJSON, err := json.Marshal(input)
+	require.NoError(t, err)
+	return ws.EditFile(context.Background(), inputJSON)
+}
+
+func TestEditFile(t *testing.T) {
+	dir := t.TempDir()
+	ws := &Workspace{Root: dir}
+
+	t.Run("替换唯一匹配的文本", func(t *testing.T) {
+		path := filepath.Join(dir, "edit.txt")
+		require.NoError(t, os.WriteFile(path, []byte("hello world\n"), 0600))
+
+		result, err := editFile(t, ws, EditFileInput{Path: rel(t, dir, path), OldStr: "world", NewStr: "gopher"})
+		require.NoError(t, err)
+		assert.Equal(t, "OK", result)
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "hello gopher\n", string(content))
+
+		// 保留原文件权限
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("创建新文件和目录", func(t *testing.T) {
+		path := filepath.Join(dir, "nested", "new.txt")
+		result, err := editFile(t, ws, EditFileInput{Path: rel(t, dir, path), NewStr: "fresh"})
+		require.NoError(t, err)
+		assert.Contains(t, result, "Created")
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "fresh", string(content))
+	})
+
+	t.Run("文本不存在", func(t *testing.T) {
+		path := filepath.Join(dir, "missing.txt")
+		require.NoError(t, os.WriteFile(path, []byte("abc"), 0644))
+		_, err := editFile(t, ws, EditFileInput{Path: rel(t, dir, path), OldStr: "xyz", NewStr: "1"})
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("文本出现多次", func(t *testing.T) {
+		path := filepath.Join(dir, "dup.txt")
+		require.NoError(t, os.WriteFile(path, []byte("a a"), 0644))
+		_, err := editFile(t, ws, EditFileInput{Path: rel(t, dir, path), OldStr: "a", NewStr: "b"})
+		assert.ErrorContains(t, err, "appears 2 times")
+	})
+
+	t.Run("已存在的文件不能用空old_str覆盖", func(t *testing.T) {
+		path := filepath.Join(dir, "exists.txt")
+		require.NoError(t, os.WriteFile(path, []byte("keep"), 0644))
+		_, err := editFile(t, ws, EditFileInput{Path: rel(t, dir, path), NewStr: "overwrite"})
+		assert.Error(t, err)
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "keep", string(content))
+	})
+
+	t.Run("参数错误", func(t *testing.T) {
+		_, err := editFile(t, ws, EditFileInput{Path: "", NewStr: "x"})
+		assert.Error(t, err)
+		_, err = editFile(t, ws, EditFileInput{Path: "x", OldStr: "same", NewStr: "same"})
+		assert.Error(t, err)
+		_, err = ws.EditFile(context.Background(), json.RawMessage(`{"path": 1}`))
+		assert.ErrorContains(t, err, "failed to parse input")
+	})
+}
+
+func TestVerifyWritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(path, []byte("written"), 0644))
+
+	assert.NoError(t, verifyWritten(path, "a.txt", "written"))
+	// 写入后文件被其他程序改动
+	assert.ErrorContains(t, verifyWritten(path, "a.txt", "expected"), "did not apply")
+	assert.ErrorContains(t, verifyWritten(path+".missing", "a.txt", "written"), "failed to read back")
+}
+
+func TestEditFileTool(t *testing.T) {
+	def := EditFileTool(&Workspace{Root: "."})
+	assert.Equal(t, "edit_file", def.Name)
+	assert.False(t, def.ReadOnly)
+	assert.NotNil(t, def.Function)
+	assert.ElementsMatch(t, []string{"path", "old_str", "new_str"}, def.InputSchema.Required)
+}
+
+// rel 返回 path 相对于工作区根目录 dir 的路径
+func rel(t *testing.T, dir, path string) string {
+	t.Helper()
+	r, err := filepath.Rel(dir, path)
+	require.NoError(t, err)
+	return r
+}
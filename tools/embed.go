@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/search"
+)
+
+// embedTimeout 是计算一次向量的最长时间
+const embedTimeout = time.Minute
+
+// maxEmbedCandidates 限制一次比较的候选文本数
+const maxEmbedCandidates = 100
+
+// Embedder 计算文本的向量，由 provider 包中的向量提供方实现
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedTextInput 定义 embed_text 工具的输入参数
+type EmbedTextInput struct {
+	Text       string   `json:"text" jsonschema_description:"The text to embed, e.g. a question or a description of the code you are looking for."`
+	Candidates []string `json:"candidates,omitempty" jsonschema_description:"Optional texts to rank by semantic similarity to text, e.g. function docs or file summaries. Without candidates the tool returns the vector itself."`
+	Top        int      `json:"top,omitempty" jsonschema_description:"How many of the most similar candidates to return, 5 by default."`
+}
+
+func init() {
+	Register(Spec{Name: "embed_text", Category: CategorySearch, ReadOnly: true, Permissions: []Permission{PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) { return EmbedTextTool(env.Embeddings), env.Embeddings != nil }})
+}
+
+// EmbedTextTool 返回计算文本向量并按语义相似度排序候选文本的工具定义
+func EmbedTextTool(e Embedder) ToolDefinition {
+	return ToolDefinition{
+		Name:        "embed_text",
+		Description: fmt.Sprintf("Compute the embedding of a text with the configured embedding model. Pass candidates to rank up to %d texts by cosine similarity to it, which finds related code or docs by meaning rather than by exact words.", maxEmbedCandidates),
+		InputSchema: GenerateSchema[EmbedTextInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(embedText(ctx, e, input))
+		},
+		ReadOnly: true,
+	}
+}
+
+func embedText(ctx context.Context, e Embedder, input json.RawMessage) (string, error) {
+	var in EmbedTextInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(in.Text) == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	if len(in.Candidates) > maxEmbedCandidates {
+		return "", fmt.Errorf("at most %d candidates can be compared at once, got %d", maxEmbedCandidates, len(in.Candidates))
+	}
+	ctx, cancel := context.WithTimeout(ctx, embedTimeout)
+	defer cancel()
+	vectors, err := e.Embed(ctx, append([]string{in.Text}, in.Candidates...))
+	if err != nil {
+		return "", err
+	}
+	if len(in.Candidates) == 0 {
+		data, err := json.Marshal(vectors[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d dimensions: %s", len(vectors[0]), data), nil
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+	ranked := make([]scored, len(in.Candidates))
+	for i := range in.Candidates {
+		ranked[i] = scored{i, search.Cosine(vectors[0], vectors[i+1])}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	top := in.Top
+	if top <= 0 {
+		top = 5
+	}
+	if top > len(ranked) {
+		top = len(ranked)
+	}
+	var b strings.Builder
+	for _, r := range ranked[:top] {
+		fmt.Fprintf(&b, "%.3f  [%d] %s\n", r.score, r.index, in.Candidates[r.index])
+	}
+	return b.String(), nil
+}
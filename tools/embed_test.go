@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordEmbedder 用几个词的出现次数作为向量
+type wordEmbedder struct{ texts []string }
+
+func (e *wordEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.texts = texts
+	var vectors [][]float32
+	for _, text := range texts {
+		vector := make([]float32, 3)
+		for i, word := range []string{"parse", "config", "http"} {
+			vector[i] = float32(strings.Count(strings.ToLower(text), word))
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(context.Context, []string) ([][]float32, error) {
+	return nil, errors.New("401 Unauthorized")
+}
+
+func TestEmbedText(t *testing.T) {
+	run := func(e Embedder, input string) ToolResult {
+		return EmbedTextTool(e).Function(context.Background(), json.RawMessage(input))
+	}
+
+	t.Run("按相似度排序候选文本", func(t *testing.T) {
+		e := &wordEmbedder{}
+		result := run(e, `{"text": "where is the config parsed", "candidates": ["serve HTTP requests", "parse the config file", "config defaults"], "top": 2}`)
+		require.False(t, result.IsError, result.Text)
+		assert.Equal(t, "1.000  [1] parse the config file\n0.707  [2] config defaults\n", result.Text)
+		assert.Len(t, e.texts, 4, "查询和候选文本一次计算")
+	})
+
+	t.Run("没有候选时返回向量", func(t *testing.T) {
+		result := run(&wordEmbedder{}, `{"text": "parse http"}`)
+		require.False(t, result.IsError, result.Text)
+		assert.Equal(t, "3 dimensions: [1,0,1]", result.Text)
+	})
+
+	t.Run("错误", func(t *testing.T) {
+		assert.True(t, run(&wordEmbedder{}, `{"text": " "}`).IsError, "文本不能为空")
+		assert.True(t, run(&wordEmbedder{}, `{"text": "x", "candidates": [`+strings.Repeat(`"c",`, maxEmbedCandidates)+`"c"]}`).IsError, "候选太多")
+		assert.Contains(t, run(failingEmbedder{}, `{"text": "x"}`).Text, "401 Unauthorized")
+	})
+}
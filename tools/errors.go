@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+
+	"agent/github"
+	"agent/gitlab"
+	"agent/jira"
+	"agent/linear"
+)
+
+// ErrorKind 是工具错误的分类，agent 据此决定如何处理失败的调用
+type ErrorKind string
+
+const (
+	// ErrorInvalidInput 表示输入有误，模型修正输入后可以重试
+	ErrorInvalidInput ErrorKind = "invalid_input"
+	// ErrorNotFound 表示输入指向的文件或对象不存在，模型可以换一个再试
+	ErrorNotFound ErrorKind = "not_found"
+	// ErrorPermissionDenied 表示调用被策略、限制或用户拒绝，原样重试没有意义
+	ErrorPermissionDenied ErrorKind = "permission_denied"
+	// ErrorTransient 表示暂时性的失败，例如网络错误或服务过载，稍后重试可能成功
+	ErrorTransient ErrorKind = "transient"
+)
+
+// Error 是带有分类的工具错误
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithKind 给 err 加上分类，err 为 nil 时返回 nil
+func WithKind(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// invalidInput 返回格式化的 ErrorInvalidInput 错误
+func invalidInput(format string, args ...any) error {
+	return WithKind(ErrorInvalidInput, fmt.Errorf(format, args...))
+}
+
+// KindOf 返回 err 的分类。没有用 WithKind 分类的错误按原因推断：
+// ErrDenied 和没有权限的文件是拒绝，不存在的文件是找不到，无法解析的 JSON 输入是输入有误，
+// 网络错误是暂时性的，代码托管和项目管理平台的错误按 HTTP 状态分类。无法推断时返回空字符串
+func KindOf(err error) ErrorKind {
+	var kinded *Error
+	if errors.As(err, &kinded) {
+		return kinded.Kind
+	}
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrDenied), errors.Is(err, fs.ErrPermission):
+		return ErrorPermissionDenied
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorNotFound
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrorInvalidInput
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorTransient
+	}
+	var githubErr *github.APIError
+	var gitlabErr *gitlab.APIError
+	var jiraErr *jira.APIError
+	var linearErr *linear.APIError
+	switch {
+	case errors.As(err, &githubErr):
+		return statusKind(githubErr.Status)
+	case errors.As(err, &gitlabErr):
+		return statusKind(gitlabErr.Status)
+	case errors.As(err, &jiraErr):
+		return statusKind(jiraErr.Status)
+	case errors.As(err, &linearErr):
+		return statusKind(linearErr.Status)
+	}
+	return ""
+}
+
+// statusKind 按 HTTP 状态码分类 API 错误
+func statusKind(status int) ErrorKind {
+	switch {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return ErrorPermissionDenied
+	case status == http.StatusNotFound:
+		return ErrorNotFound
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests, status >= 500:
+		return ErrorTransient
+	case status >= 400:
+		return ErrorInvalidInput
+	}
+	return ""
+}
+
+// ErrorKind 返回失败结果的分类，成功时返回空字符串
+func (r ToolResult) ErrorKind() ErrorKind {
+	return KindOf(r.Err())
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"agent/github"
+	"agent/jira"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindOf(t *testing.T) {
+	var input EditFileInput
+	_, statErr := os.Stat("/does/not/exist")
+	cases := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"没有错误", nil, ""},
+		{"无法推断", errors.New("boom"), ""},
+		{"显式分类", fmt.Errorf("wrapped: %w", WithKind(ErrorTransient, errors.New("busy"))), ErrorTransient},
+		{"被策略拒绝", fmt.Errorf("command %w", ErrDenied), ErrorPermissionDenied},
+		{"文件不存在", fmt.Errorf("failed to read: %w", statErr), ErrorNotFound},
+		{"输入不是 JSON", fmt.Errorf("failed to parse input: %w", json.Unmarshal([]byte(`{`), &input)), ErrorInvalidInput},
+		{"输入类型不对", fmt.Errorf("failed to parse input: %w", json.Unmarshal([]byte(`{"path": 1}`), &input)), ErrorInvalidInput},
+		{"网络错误", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorTransient},
+		{"API 限流", &github.APIError{Status: 429}, ErrorTransient},
+		{"API 找不到", &jira.APIError{Status: 404}, ErrorNotFound},
+		{"API 没有权限", &github.APIError{Status: 403}, ErrorPermissionDenied},
+		{"API 参数有误", &github.APIError{Status: 422}, ErrorInvalidInput},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, KindOf(c.err), c.name)
+	}
+}
+
+func TestToolErrorKinds(t *testing.T) {
+	w := &Workspace{Root: t.TempDir()}
+	edit := EditFileTool(w).Function
+	assert.Equal(t, ErrorNotFound, edit(context.Background(), json.RawMessage(`{"path": "missing.go", "old_str": "a", "new_str": "b"}`)).ErrorKind())
+	assert.Equal(t, ErrorInvalidInput, edit(context.Background(), json.RawMessage(`{"path": "../outside.go", "old_str": "", "new_str": "b"}`)).ErrorKind())
+	assert.Equal(t, ErrorInvalidInput, GrepTool(w).Function(context.Background(), json.RawMessage(`{"pattern": "("}`)).ErrorKind())
+}
@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// defaultExternalTimeout 是外部工具默认的执行时间上限
+const defaultExternalTimeout = time.Minute
+
+// External 是以外部可执行文件实现的工具：参数以 JSON 写入标准输入，标准输出是结果
+type External struct {
+	Name        string
+	Description string
+	// Command 是要执行的命令和参数
+	Command []string
+	// Schema 是输入参数的 JSON Schema，nil 表示没有参数
+	Schema   map[string]any
+	ReadOnly bool
+	// Timeout 为 0 时使用 defaultExternalTimeout
+	Timeout time.Duration
+}
+
+// ExternalTool 返回在工作区 w 中执行外部命令的工具定义
+func ExternalTool(w *Workspace, ext External) ToolDefinition {
+	schema := anthropic.ToolInputSchemaParam{Properties: map[string]any{}}
+	if properties, ok := ext.Schema["properties"]; ok {
+		schema.Properties = properties
+	}
+	if required, ok := ext.Schema["required"].([]any); ok {
+		for _, name := range required {
+			if s, ok := name.(string); ok {
+				schema.Required = append(schema.Required, s)
+			}
+		}
+	}
+	return ToolDefinition{
+		Name:        ext.Name,
+		Description: ext.Description,
+		InputSchema: schema,
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.RunExternal(ctx, ext, input))
+		},
+		ReadOnly:    ext.ReadOnly,
+		Category:    CategoryCustom,
+		Permissions: []Permission{PermissionExec},
+	}
+}
+
+// RunExternal 在工作区根目录执行外部工具，把 input 写入标准输入并返回标准输出。
+// 命令可以从环境变量 AGENT_TOOL 和 AGENT_WORKSPACE 得到工具名和工作区的绝对路径
+func (w *Workspace) RunExternal(ctx context.Context, ext External, input json.RawMessage) (string, error) {
+	root, err := filepath.Abs(w.Root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace root: %w", err)
+	}
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	timeout := ext.Timeout
+	if timeout <= 0 {
+		timeout = defaultExternalTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ext.Command[0], ext.Command[1:]...)
+	killOnCancel(cmd)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), "AGENT_TOOL="+ext.Name, "AGENT_WORKSPACE="+root)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s timed out after %s", ext.Name, timeout)
+	}
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		return "", fmt.Errorf("%s failed (%v): %s", ext.Name, err, message)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalTool(t *testing.T) {
+	root := t.TempDir()
+	w := &Workspace{Root: root}
+	script := filepath.Join(t.TempDir(), "tool.sh")
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+input=$(cat)
+case "$input" in
+  *fail*) echo "bad input" >&2; exit 3 ;;
+  *sleep*) sleep 5 ;;
+esac
+echo "$AGENT_TOOL in $(pwd): $input"
+`), 0755))
+
+	tool := ExternalTool(w, External{
+		Name:        "echo_input",
+		Description: "Echo the input",
+		Command:     []string{script},
+		Schema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"text": map[string]any{"type": "string"}},
+			"required":   []any{"text"},
+		},
+		ReadOnly: true,
+	})
+	assert.Equal(t, "echo_input", tool.Name)
+	assert.True(t, tool.ReadOnly)
+	assert.Equal(t, CategoryCustom, tool.Category)
+	assert.Equal(t, []string{"text"}, tool.InputSchema.Required)
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	require.NoError(t, err)
+
+	t.Run("参数写入标准输入，标准输出是结果", func(t *testing.T) {
+		out, err := tool.Function(context.Background(), json.RawMessage(`{"text": "hi"}`)).Output()
+		require.NoError(t, err)
+		assert.Equal(t, `echo_input in `+realRoot+`: {"text": "hi"}`+"\n", out)
+	})
+
+	t.Run("非零退出时返回标准错误", func(t *testing.T) {
+		_, err := tool.Function(context.Background(), json.RawMessage(`{"text": "fail"}`)).Output()
+		assert.ErrorContains(t, err, "echo_input failed (exit status 3): bad input")
+	})
+
+	t.Run("超时", func(t *testing.T) {
+		slow := ExternalTool(w, External{Name: "slow", Command: []string{script}, Timeout: 100 * time.Millisecond})
+		start := time.Now()
+		_, err := slow.Function(context.Background(), json.RawMessage(`{"text": "sleep"}`)).Output()
+		assert.ErrorContains(t, err, "slow timed out after 100ms")
+		assert.Less(t, time.Since(start), 4*time.Second)
+	})
+
+	t.Run("没有 schema 时不需要参数", func(t *testing.T) {
+		bare := ExternalTool(w, External{Name: "bare", Command: []string{script}})
+		assert.Empty(t, bare.InputSchema.Required)
+		out, err := bare.Function(context.Background(), nil).Output()
+		require.NoError(t, err)
+		assert.Contains(t, out, ": {}")
+	})
+}
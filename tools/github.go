@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"agent/github"
+	"agent/gitutil"
+)
+
+// GitHubInput 定义 github 工具的输入参数
+type GitHubInput struct {
+	Action string `json:"action" jsonschema:"enum=create_branch,enum=push,enum=create_pull_request" jsonschema_description:"create_branch: create and switch to a new branch. push: push the current branch. create_pull_request: push the current branch and open a pull request from it."`
+	Branch string `json:"branch,omitempty" jsonschema_description:"The branch name for create_branch, e.g. fix/issue-42."`
+	Title  string `json:"title,omitempty" jsonschema_description:"The pull request title: a short summary of the change."`
+	Body   string `json:"body,omitempty" jsonschema_description:"The pull request description: what changed, why, and how it was tested."`
+	Base   string `json:"base,omitempty" jsonschema_description:"The branch to merge into; defaults to the repository's default branch."`
+	Draft  bool   `json:"draft,omitempty" jsonschema_description:"Open the pull request as a draft."`
+}
+
+// GitHub 是 github 工具使用的远程仓库和认证设置
+type GitHub struct {
+	// Remote 是推送和解析仓库地址使用的远程仓库名，默认为 origin
+	Remote string
+	// APIURL 为空时根据远程仓库的主机推断；token 只发给 github.com 和 APIURL 所在的主机
+	APIURL string
+	// Token 返回访问 API 和通过 https 推送使用的 token，没有时返回空字符串
+	Token func() string
+	// HTTP 为空时使用默认客户端
+	HTTP *http.Client
+}
+
+// githubTimeout 限制创建拉取请求时 API 请求的总时间
+const githubTimeout = time.Minute
+
+func init() {
+	Register(Spec{Name: "github", Category: CategoryIntegrations, Permissions: []Permission{PermissionExec, PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) {
+			return GitHubTool(env.Workspace, env.GitHub), env.Repo && env.GitHub != nil
+		}})
+}
+
+// GitHubTool 返回在工作区 w 的 git 仓库中创建分支、推送并创建拉取请求的工具定义
+func GitHubTool(w *Workspace, gh *GitHub) ToolDefinition {
+	return ToolDefinition{
+		Name:        "github",
+		Description: "Work with the GitHub repository of the workspace: create a branch for your changes, push it, and open a pull request. Commit your changes (e.g. with the shell tool) before pushing. Pushing the default branch is not allowed.",
+		InputSchema: GenerateSchema[GitHubInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.GitHub(ctx, gh, input))
+		},
+	}
+}
+
+// GitHub 执行 github 工具的操作
+func (w *Workspace) GitHub(ctx context.Context, gh *GitHub, input json.RawMessage) (string, error) {
+	var params GitHubInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	repo, err := gitutil.Open(w.Root)
+	if err != nil {
+		return "", err
+	}
+
+	switch params.Action {
+	case "create_branch":
+		if params.Branch == "" {
+			return "", fmt.Errorf("branch is required for create_branch")
+		}
+		if err := repo.CreateBranch(params.Branch); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Created and switched to branch %s.", params.Branch), nil
+	case "push":
+		branch, err := gh.push(repo)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Pushed %s to %s.", branch, gh.remote()), nil
+	case "create_pull_request":
+		return gh.createPullRequest(ctx, repo, params)
+	default:
+		return "", fmt.Errorf("unknown action %q (use create_branch, push or create_pull_request)", params.Action)
+	}
+}
+
+func (gh *GitHub) remote() string {
+	if gh.Remote == "" {
+		return "origin"
+	}
+	return gh.Remote
+}
+
+func (gh *GitHub) token() string {
+	if gh.Token == nil {
+		return ""
+	}
+	return gh.Token()
+}
+
+// trustedHost 报告 token 能否发给 host：github.com 或用户配置的 APIURL 所在的主机
+func (gh *GitHub) trustedHost(host string) bool {
+	if host == "github.com" {
+		return true
+	}
+	u, err := url.Parse(gh.APIURL)
+	return gh.APIURL != "" && err == nil && u.Host == host
+}
+
+// push 推送当前分支，拒绝推送默认分支
+func (gh *GitHub) push(repo *gitutil.Repo) (string, error) {
+	branch, err := repo.CurrentBranch()
+	if err != nil {
+		return "", err
+	}
+	if defaultBranch(repo, gh.remote()) == branch {
+		return "", fmt.Errorf("push %w: %s is the default branch; create a branch for your changes first", ErrDenied, branch)
+	}
+	remoteURL, err := repo.RemoteURL(gh.remote())
+	if err != nil {
+		return "", err
+	}
+
+	var env []string
+	if token := gh.token(); token != "" && strings.HasPrefix(remoteURL, "https://") {
+		host, _, err := github.ParseRemote(remoteURL)
+		if err != nil {
+			return "", err
+		}
+		if gh.trustedHost(host) {
+			// 通过环境变量传入认证头，token 不会出现在命令行参数中
+			auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+			env = []string{
+				"GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=http.https://" + host + "/.extraheader",
+				"GIT_CONFIG_VALUE_0=AUTHORIZATION: basic " + auth,
+			}
+		}
+	}
+	if _, err := repo.Push(gh.remote(), branch, env); err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+// createPullRequest 推送当前分支并创建拉取请求
+func (gh *GitHub) createPullRequest(ctx context.Context, repo *gitutil.Repo, params GitHubInput) (string, error) {
+	if strings.TrimSpace(params.Title) == "" {
+		return "", fmt.Errorf("title is required for create_pull_request")
+	}
+	if gh.token() == "" {
+		return "", fmt.Errorf("no GitHub token: set GITHUB_TOKEN or run `agent auth login github`")
+	}
+	remoteURL, err := repo.RemoteURL(gh.remote())
+	if err != nil {
+		return "", err
+	}
+	host, target, err := github.ParseRemote(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	client := gh.client(host)
+	if client.Token == "" {
+		return "", fmt.Errorf("the GitHub token is only sent to github.com and github.api_url; set api_url in the user config to use %s", host)
+	}
+
+	head, err := gh.push(repo)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, githubTimeout)
+	defer cancel()
+	base := params.Base
+	if base == "" {
+		if base, err = client.DefaultBranch(ctx, target); err != nil {
+			return "", err
+		}
+	}
+	pr, err := client.CreatePullRequest(ctx, target, github.NewPullRequest{
+		Title: params.Title,
+		Body:  params.Body,
+		Head:  head,
+		Base:  base,
+		Draft: params.Draft,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Pushed %s and opened pull request #%d (%s into %s): %s", head, pr.Number, head, base, pr.HTMLURL), nil
+}
+
+// defaultBranch 返回远程仓库的默认分支，未知时按惯例为 main 或 master 中存在的那个
+func defaultBranch(repo *gitutil.Repo, remote string) string {
+	if out, err := repo.Run("symbolic-ref", "--short", "-q", "refs/remotes/"+remote+"/HEAD"); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(out), remote+"/")
+	}
+	for _, name := range []string{"main", "master"} {
+		if _, err := repo.Run("rev-parse", "--verify", "-q", "refs/heads/"+name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitRepo 创建一个在 main 分支上有初始提交、origin 指向本地裸仓库的临时仓库，返回仓库和远程仓库的目录
+func gitRepo(t testing.TB) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git 不可用")
+	}
+	dir, remote := t.TempDir(), t.TempDir()
+	git := func(dir string, args ...string) {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	git(remote, "init", "-q", "--bare")
+	git(dir, "init", "-q", "-b", "main")
+	git(dir, "config", "user.email", "test@example.com")
+	git(dir, "config", "user.name", "test")
+	git(dir, "config", "commit.gpgsign", "false")
+	git(dir, "remote", "add", "origin", remote)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0644))
+	git(dir, "add", ".")
+	git(dir, "commit", "-q", "-m", "init")
+	return dir, remote
+}
+
+func TestGitHub(t *testing.T) {
+	dir, remote := gitRepo(t)
+	w := &Workspace{Root: dir}
+	tool := GitHubTool(w, &GitHub{})
+	call := func(input GitHubInput) (string, error) {
+		data, err := json.Marshal(input)
+		require.NoError(t, err)
+		return tool.Function(context.Background(), data).Output()
+	}
+
+	t.Run("拒绝推送默认分支", func(t *testing.T) {
+		_, err := call(GitHubInput{Action: "push"})
+		assert.ErrorIs(t, err, ErrDenied)
+	})
+
+	t.Run("创建分支并推送", func(t *testing.T) {
+		out, err := call(GitHubInput{Action: "create_branch", Branch: "fix/typo"})
+		require.NoError(t, err)
+		assert.Equal(t, "Created and switched to branch fix/typo.", out)
+
+		out, err = call(GitHubInput{Action: "push"})
+		require.NoError(t, err)
+		assert.Equal(t, "Pushed fix/typo to origin.", out)
+		assert.NoError(t, exec.Command("git", "-C", remote, "rev-parse", "--verify", "refs/heads/fix/typo").Run())
+	})
+
+	t.Run("缺少参数", func(t *testing.T) {
+		_, err := call(GitHubInput{Action: "create_branch"})
+		assert.ErrorContains(t, err, "branch is required")
+		_, err = call(GitHubInput{Action: "create_pull_request"})
+		assert.ErrorContains(t, err, "title is required")
+		_, err = call(GitHubInput{Action: "merge"})
+		assert.ErrorContains(t, err, "unknown action")
+	})
+
+	t.Run("没有 token 时不能创建拉取请求", func(t *testing.T) {
+		_, err := call(GitHubInput{Action: "create_pull_request", Title: "修复拼写"})
+		assert.ErrorContains(t, err, "no GitHub token")
+	})
+}
+
+func TestGitHubCreatePullRequest(t *testing.T) {
+	dir, remote := gitRepo(t)
+	// 仓库地址指向 GitHub，推送仍然使用本地裸仓库
+	require.NoError(t, exec.Command("git", "-C", dir, "remote", "set-url", "origin", "https://github.com/owner/repo.git").Run())
+	require.NoError(t, exec.Command("git", "-C", dir, "remote", "set-url", "--push", "origin", remote).Run())
+
+	var created map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/repos/owner/repo":
+			w.Write([]byte(`{"default_branch": "main"}`))
+		case "/repos/owner/repo/pulls":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 12, "html_url": "https://github.com/owner/repo/pull/12"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	w := &Workspace{Root: dir}
+	gh := &GitHub{APIURL: server.URL, Token: func() string { return "secret" }}
+	_, err := w.GitHub(context.Background(), gh, json.RawMessage(`{"action": "create_branch", "branch": "feature"}`))
+	require.NoError(t, err)
+
+	out, err := w.GitHub(context.Background(), gh, json.RawMessage(`{"action": "create_pull_request", "title": "添加功能", "body": "说明"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Pushed feature and opened pull request #12 (feature into main): https://github.com/owner/repo/pull/12", out)
+	assert.Equal(t, map[string]interface{}{"title": "添加功能", "body": "说明", "head": "feature", "base": "main"}, created)
+
+	refs, err := exec.Command("git", "-C", remote, "for-each-ref", "--format=%(refname)").Output()
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(refs), "refs/heads/feature"))
+}
@@ -0,0 +1,287 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"agent/github"
+	"agent/gitlab"
+	"agent/gitutil"
+)
+
+// FetchIssueInput 定义 fetch_issue 工具的输入参数
+type FetchIssueInput struct {
+	Issue string `json:"issue" jsonschema_description:"The issue or pull request to fetch: a GitHub or GitLab URL, owner/repo#123, or #123 (or !123 for a GitLab merge request) in the workspace's repository."`
+}
+
+// GitLab 是访问 GitLab API 的设置
+type GitLab struct {
+	// APIURL 为空时根据主机推断
+	APIURL string
+	// Token 返回访问 API 使用的 token，没有时返回空字符串
+	Token func() string
+	// HTTP 为空时使用默认客户端
+	HTTP *http.Client
+}
+
+// maxIssueDiffBytes 是每个 diff 最多返回的字节数，避免大的改动占满上下文
+const maxIssueDiffBytes = 20000
+
+// maxLinkedDiffs 是 issue 最多附带的关联拉取请求 diff 数量
+const maxLinkedDiffs = 3
+
+func init() {
+	Register(Spec{Name: "fetch_issue", Category: CategoryIntegrations, ReadOnly: true, Permissions: []Permission{PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) {
+			return FetchIssueTool(env.Workspace, env.GitHub, env.GitLab), env.Repo && env.GitHub != nil && env.GitLab != nil
+		}})
+}
+
+// FetchIssueTool 返回从 GitHub 或 GitLab 获取 issue 或拉取请求（包括评论和相关 diff）的工具定义
+func FetchIssueTool(w *Workspace, gh *GitHub, gl *GitLab) ToolDefinition {
+	return ToolDefinition{
+		Name:        "fetch_issue",
+		Description: "Fetch a GitHub or GitLab issue or pull/merge request: its title, description and comments, plus the diff of the pull request (or of the pull requests linked to the issue). Use it when a task refers to an issue, to read what is being asked before making changes.",
+		InputSchema: GenerateSchema[FetchIssueInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.FetchIssue(ctx, gh, gl, input))
+		},
+		ReadOnly: true,
+	}
+}
+
+// issueRef 标识一个 issue 或拉取请求
+type issueRef struct {
+	gitlab bool
+	host   string
+	// repo 是 GitHub 的 owner/repo 或 GitLab 的项目路径
+	repo   string
+	number int
+	// mergeRequest 表示 GitLab 的合并请求；GitHub 的 issue 接口同时支持拉取请求，不需要区分
+	mergeRequest bool
+}
+
+var (
+	gitLabURLPattern = regexp.MustCompile(`^/(.+?)/-/(issues|merge_requests)/(\d+)`)
+	gitHubURLPattern = regexp.MustCompile(`^/([^/]+/[^/]+)/(?:issues|pull)/(\d+)`)
+	shortRefPattern  = regexp.MustCompile(`^([\w.-]+(?:/[\w.-]+)+)?([#!]?)(\d+)$`)
+)
+
+// parseIssueRef 解析 URL 或简写的引用，简写中省略的主机和仓库从工作区的远程仓库 remote 中获取
+func (w *Workspace) parseIssueRef(ref, remote string) (issueRef, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return issueRef{}, err
+		}
+		if u.Host == "" {
+			return issueRef{}, fmt.Errorf("%s has no host", ref)
+		}
+		if m := gitLabURLPattern.FindStringSubmatch(u.Path); m != nil {
+			number, _ := strconv.Atoi(m[3])
+			return issueRef{gitlab: true, host: u.Host, repo: m[1], number: number, mergeRequest: m[2] == "merge_requests"}, nil
+		}
+		if m := gitHubURLPattern.FindStringSubmatch(u.Path); m != nil {
+			number, _ := strconv.Atoi(m[2])
+			return issueRef{host: u.Host, repo: m[1], number: number}, nil
+		}
+		return issueRef{}, fmt.Errorf("%s is not a GitHub or GitLab issue or pull request URL", ref)
+	}
+
+	m := shortRefPattern.FindStringSubmatch(ref)
+	if m == nil {
+		return issueRef{}, fmt.Errorf("cannot parse issue %q: use a URL, owner/repo#123 or #123", ref)
+	}
+	number, _ := strconv.Atoi(m[3])
+	result := issueRef{repo: m[1], number: number, mergeRequest: m[2] == "!"}
+
+	remoteURL := ""
+	if repo, err := gitutil.Open(w.Root); err == nil {
+		remoteURL, _ = repo.RemoteURL(remote)
+	}
+	if remoteURL == "" {
+		if result.repo == "" {
+			return issueRef{}, fmt.Errorf("the workspace has no %s remote: give the issue as a URL or owner/repo#123", remote)
+		}
+		result.host = "github.com"
+		result.gitlab = result.mergeRequest
+		return result, nil
+	}
+	host, project, err := gitlab.ParseRemote(remoteURL)
+	if err != nil {
+		return issueRef{}, err
+	}
+	result.host = host
+	result.gitlab = result.mergeRequest || strings.Contains(host, "gitlab")
+	if result.repo == "" {
+		result.repo = project
+	}
+	return result, nil
+}
+
+// FetchIssue 获取 issue 或拉取请求并格式化为文本
+func (w *Workspace) FetchIssue(ctx context.Context, gh *GitHub, gl *GitLab, input json.RawMessage) (string, error) {
+	var params FetchIssueInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	ref, err := w.parseIssueRef(params.Issue, gh.remote())
+	if err != nil {
+		return "", err
+	}
+	if ref.gitlab {
+		return gl.fetch(ctx, ref)
+	}
+	return gh.fetch(ctx, ref)
+}
+
+// client 返回访问 host 上仓库的 API 客户端。只有请求发往用户配置的 APIURL 或 github.com 时
+// 才带上 token：其他主机来自远程仓库地址或 issue 链接，可能由别人控制
+func (gh *GitHub) client(host string) *github.Client {
+	client := &github.Client{BaseURL: gh.APIURL, HTTP: gh.HTTP}
+	if client.BaseURL == "" && host != "github.com" {
+		client.BaseURL = "https://" + host + "/api/v3"
+	} else {
+		client.Token = gh.token()
+	}
+	return client
+}
+
+func (gh *GitHub) fetch(ctx context.Context, ref issueRef) (string, error) {
+	owner, name, _ := strings.Cut(ref.repo, "/")
+	repo := github.Repository{Owner: owner, Name: name}
+	client := gh.client(ref.host)
+	issue, err := client.GetIssue(ctx, repo, ref.number)
+	if err != nil {
+		return "", err
+	}
+	comments, err := client.IssueComments(ctx, repo, ref.number)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	kind := "Issue"
+	if issue.PullRequest != nil {
+		kind = "Pull request"
+	}
+	writeIssueHeader(&out, kind, "#", issue.Number, issue.Title, issue.State, issue.User.Login, issue.HTMLURL, issue.Body)
+	for _, comment := range comments {
+		fmt.Fprintf(&out, "\n--- Comment by %s on %s\n%s\n", comment.User.Login, comment.CreatedAt.Format("2006-01-02"), strings.TrimSpace(comment.Body))
+	}
+
+	if issue.PullRequest != nil {
+		diff, err := client.PullRequestDiff(ctx, repo, issue.Number)
+		if err != nil {
+			return "", err
+		}
+		writeIssueDiff(&out, "Diff", diff)
+		return out.String(), nil
+	}
+	linked, err := client.LinkedPullRequests(ctx, repo, issue.Number)
+	if err != nil {
+		fmt.Fprintf(&out, "\n(could not list linked pull requests: %v)\n", err)
+		return out.String(), nil
+	}
+	for i, pr := range linked {
+		title := fmt.Sprintf("Linked pull request #%d: %s (%s) %s", pr.Number, pr.Title, pr.State, pr.HTMLURL)
+		if i >= maxLinkedDiffs {
+			fmt.Fprintf(&out, "\n--- %s\n", title)
+			continue
+		}
+		diff, err := client.PullRequestDiff(ctx, repo, pr.Number)
+		if err != nil {
+			fmt.Fprintf(&out, "\n--- %s\n(could not fetch the diff: %v)\n", title, err)
+			continue
+		}
+		writeIssueDiff(&out, title, diff)
+	}
+	return out.String(), nil
+}
+
+func (gl *GitLab) fetch(ctx context.Context, ref issueRef) (string, error) {
+	client := &gitlab.Client{BaseURL: gl.APIURL, HTTP: gl.HTTP}
+	if client.BaseURL == "" && ref.host != "gitlab.com" {
+		// 与 GitHub 一样，token 不发给 issue 链接中的其他主机
+		client.BaseURL = "https://" + ref.host + "/api/v4"
+	} else if gl.Token != nil {
+		client.Token = gl.Token()
+	}
+	kind, name, sigil := gitlab.Issues, "Issue", "#"
+	if ref.mergeRequest {
+		kind, name, sigil = gitlab.MergeRequests, "Merge request", "!"
+	}
+	issue, err := client.GetIssue(ctx, ref.repo, kind, ref.number)
+	if err != nil {
+		return "", err
+	}
+	notes, err := client.Notes(ctx, ref.repo, kind, ref.number)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	writeIssueHeader(&out, name, sigil, issue.IID, issue.Title, issue.State, issue.Author.Username, issue.WebURL, issue.Description)
+	for _, note := range notes {
+		fmt.Fprintf(&out, "\n--- Comment by %s on %s\n%s\n", note.Author.Username, note.CreatedAt.Format("2006-01-02"), strings.TrimSpace(note.Body))
+	}
+
+	if ref.mergeRequest {
+		diff, err := client.MergeRequestDiff(ctx, ref.repo, issue.IID)
+		if err != nil {
+			return "", err
+		}
+		writeIssueDiff(&out, "Diff", diff)
+		return out.String(), nil
+	}
+	related, err := client.RelatedMergeRequests(ctx, ref.repo, issue.IID)
+	if err != nil {
+		fmt.Fprintf(&out, "\n(could not list related merge requests: %v)\n", err)
+		return out.String(), nil
+	}
+	for i, mr := range related {
+		title := fmt.Sprintf("Related merge request !%d: %s (%s) %s", mr.IID, mr.Title, mr.State, mr.WebURL)
+		if i >= maxLinkedDiffs {
+			fmt.Fprintf(&out, "\n--- %s\n", title)
+			continue
+		}
+		diff, err := client.MergeRequestDiff(ctx, ref.repo, mr.IID)
+		if err != nil {
+			fmt.Fprintf(&out, "\n--- %s\n(could not fetch the diff: %v)\n", title, err)
+			continue
+		}
+		writeIssueDiff(&out, title, diff)
+	}
+	return out.String(), nil
+}
+
+func writeIssueHeader(out *strings.Builder, kind, sigil string, number int, title, state, author, link, body string) {
+	fmt.Fprintf(out, "%s %s%d: %s (%s, opened by %s)\n%s\n", kind, sigil, number, title, state, author, link)
+	if body = strings.TrimSpace(body); body != "" {
+		fmt.Fprintf(out, "\n%s\n", body)
+	}
+}
+
+// writeIssueDiff 写入 diff，超过 maxIssueDiffBytes 时截断
+func writeIssueDiff(out *strings.Builder, title, diff string) {
+	fmt.Fprintf(out, "\n--- %s\n", title)
+	if diff == "" {
+		out.WriteString("(empty diff)\n")
+		return
+	}
+	if len(diff) > maxIssueDiffBytes {
+		fmt.Fprintf(out, "%s\n... (diff truncated: showing %d of %d bytes)\n", diff[:maxIssueDiffBytes], maxIssueDiffBytes, len(diff))
+		return
+	}
+	out.WriteString(diff)
+	if !strings.HasSuffix(diff, "\n") {
+		out.WriteString("\n")
+	}
+}
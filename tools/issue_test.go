@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssueRef(t *testing.T) {
+	dir, _ := gitRepo(t)
+	require.NoError(t, exec.Command("git", "-C", dir, "remote", "set-url", "origin", "git@gitlab.example.com:group/sub/project.git").Run())
+	w := &Workspace{Root: dir}
+
+	tests := []struct {
+		name string
+		ref  string
+		want issueRef
+	}{
+		{"GitHub issue 地址", "https://github.com/owner/repo/issues/12", issueRef{host: "github.com", repo: "owner/repo", number: 12}},
+		{"GitHub 拉取请求地址", "https://github.com/owner/repo/pull/7/files", issueRef{host: "github.com", repo: "owner/repo", number: 7}},
+		{"GitLab 合并请求地址", "https://gitlab.com/group/sub/project/-/merge_requests/3", issueRef{gitlab: true, host: "gitlab.com", repo: "group/sub/project", number: 3, mergeRequest: true}},
+		{"工作区仓库的编号", "#5", issueRef{gitlab: true, host: "gitlab.example.com", repo: "group/sub/project", number: 5}},
+		{"只有数字", "5", issueRef{gitlab: true, host: "gitlab.example.com", repo: "group/sub/project", number: 5}},
+		{"合并请求简写", "!6", issueRef{gitlab: true, host: "gitlab.example.com", repo: "group/sub/project", number: 6, mergeRequest: true}},
+		{"指定仓库", "other/repo#8", issueRef{gitlab: true, host: "gitlab.example.com", repo: "other/repo", number: 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := w.parseIssueRef(tt.ref, "origin")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref)
+		})
+	}
+
+	t.Run("无法解析", func(t *testing.T) {
+		_, err := w.parseIssueRef("https://example.com/docs", "origin")
+		assert.ErrorContains(t, err, "not a GitHub or GitLab")
+		_, err = w.parseIssueRef("the login bug", "origin")
+		assert.ErrorContains(t, err, "cannot parse issue")
+	})
+
+	t.Run("没有远程仓库时需要完整引用", func(t *testing.T) {
+		w := &Workspace{Root: t.TempDir()}
+		_, err := w.parseIssueRef("#5", "origin")
+		assert.ErrorContains(t, err, "no origin remote")
+		ref, err := w.parseIssueRef("owner/repo#5", "origin")
+		require.NoError(t, err)
+		assert.Equal(t, issueRef{host: "github.com", repo: "owner/repo", number: 5}, ref)
+	})
+}
+
+func TestFetchIssueGitHub(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/issues/12":
+			w.Write([]byte(`{"number": 12, "title": "登录失败", "state": "open", "body": "输入密码后报错", "html_url": "https://github.com/owner/repo/issues/12", "user": {"login": "alice"}}`))
+		case "/repos/owner/repo/issues/12/comments":
+			w.Write([]byte(`[{"user": {"login": "bob"}, "body": "我也遇到了", "created_at": "2024-05-01T10:00:00Z"}]`))
+		case "/repos/owner/repo/issues/12/timeline":
+			w.Write([]byte(`[
+				{"event": "labeled"},
+				{"event": "cross-referenced", "source": {"issue": {"number": 13, "title": "修复登录", "state": "open", "html_url": "https://github.com/owner/repo/pull/13", "pull_request": {}, "repository": {"full_name": "owner/repo"}}}},
+				{"event": "cross-referenced", "source": {"issue": {"number": 99, "title": "其他仓库", "pull_request": {}, "repository": {"full_name": "fork/repo"}}}}
+			]`))
+		case "/repos/owner/repo/pulls/13":
+			assert.Equal(t, "application/vnd.github.diff", r.Header.Get("Accept"))
+			w.Write([]byte("diff --git a/login.go b/login.go\n+fixed\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	w := &Workspace{Root: t.TempDir()}
+	tool := FetchIssueTool(w, &GitHub{APIURL: server.URL}, &GitLab{})
+	out, err := tool.Function(context.Background(), json.RawMessage(`{"issue": "https://github.com/owner/repo/issues/12"}`)).Output()
+	require.NoError(t, err)
+	assert.Equal(t, `Issue #12: 登录失败 (open, opened by alice)
+https://github.com/owner/repo/issues/12
+
+输入密码后报错
+
+--- Comment by bob on 2024-05-01
+我也遇到了
+
+--- Linked pull request #13: 修复登录 (open) https://github.com/owner/repo/pull/13
+diff --git a/login.go b/login.go
++fixed
+`, out)
+
+	t.Run("不存在的 issue", func(t *testing.T) {
+		_, err := tool.Function(context.Background(), json.RawMessage(`{"issue": "owner/repo#404"}`)).Output()
+		assert.ErrorContains(t, err, "GitHub API error 404")
+	})
+}
+
+func TestFetchIssueGitLab(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+		switch r.URL.EscapedPath() {
+		case "/projects/group%2Fproject/merge_requests/3":
+			w.Write([]byte(`{"iid": 3, "title": "更新文档", "state": "merged", "description": "", "web_url": "https://gitlab.com/group/project/-/merge_requests/3", "author": {"username": "carol"}}`))
+		case "/projects/group%2Fproject/merge_requests/3/notes":
+			w.Write([]byte(`[{"author": {"username": "dave"}, "body": "看起来不错", "created_at": "2024-06-02T08:00:00Z"}, {"author": {"username": "dave"}, "body": "approved this merge request", "system": true}]`))
+		case "/projects/group%2Fproject/merge_requests/3/changes":
+			w.Write([]byte(`{"changes": [{"old_path": "README.md", "new_path": "README.md", "diff": "@@ -1 +1 @@\n-old\n+new"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	w := &Workspace{Root: t.TempDir()}
+	gl := &GitLab{APIURL: server.URL, Token: func() string { return "secret" }}
+	out, err := w.FetchIssue(context.Background(), &GitHub{}, gl, json.RawMessage(`{"issue": "https://gitlab.com/group/project/-/merge_requests/3"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `Merge request !3: 更新文档 (merged, opened by carol)
+https://gitlab.com/group/project/-/merge_requests/3
+
+--- Comment by dave on 2024-06-02
+看起来不错
+
+--- Diff
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-old
++new
+`, out)
+}
+
+func TestIssueClientToken(t *testing.T) {
+	token := func() string { return "secret" }
+
+	t.Run("token 只发给 github.com 和配置的 API 地址", func(t *testing.T) {
+		gh := &GitHub{Token: token}
+		assert.Equal(t, "secret", gh.client("github.com").Token)
+		client := gh.client("evil.example")
+		assert.Equal(t, "https://evil.example/api/v3", client.BaseURL)
+		assert.Empty(t, client.Token, "issue 链接中的主机拿不到 token")
+
+		gh.APIURL = "https://ghe.example/api/v3"
+		assert.Equal(t, "secret", gh.client("ghe.example").Token)
+		assert.Equal(t, "https://ghe.example/api/v3", gh.client("evil.example").BaseURL, "请求仍然发往配置的地址")
+		assert.True(t, gh.trustedHost("ghe.example"))
+		assert.False(t, gh.trustedHost("evil.example"))
+	})
+
+	t.Run("GitLab 同样只发给 gitlab.com 和配置的 API 地址", func(t *testing.T) {
+		var got string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get("PRIVATE-TOKEN")
+			http.NotFound(w, r)
+		}))
+		defer server.Close()
+		w := &Workspace{Root: t.TempDir()}
+		_, err := w.FetchIssue(context.Background(), &GitHub{}, &GitLab{APIURL: server.URL, Token: token}, json.RawMessage(`{"issue": "https://gitlab.example/group/project/-/issues/1"}`))
+		require.Error(t, err)
+		assert.Equal(t, "secret", got)
+	})
+}
+
+func TestWriteIssueDiff(t *testing.T) {
+	var out strings.Builder
+	writeIssueDiff(&out, "Diff", strings.Repeat("x", maxIssueDiffBytes+10))
+	assert.Contains(t, out.String(), "diff truncated: showing 20000 of 20010 bytes")
+}
+
+// FuzzParseIssueRef 检查解析出的引用总有主机、仓库和编号
+func FuzzParseIssueRef(f *testing.F) {
+	for _, ref := range []string{"#12", "!7", "42", "owner/repo#3", "group/sub/project!5", "https://github.com/o/r/pull/9",
+		"https://gitlab.example.com/g/p/-/merge_requests/4", "https://example.com/x", "http://[::1", "owner/repo#99999999999999999999999"} {
+		f.Add(ref)
+	}
+	dir, _ := gitRepo(f)
+	require.NoError(f, exec.Command("git", "-C", dir, "remote", "set-url", "origin", "git@github.com:owner/repo.git").Run())
+	w := &Workspace{Root: dir}
+
+	f.Fuzz(func(t *testing.T, ref string) {
+		parsed, err := w.parseIssueRef(ref, "origin")
+		if err != nil {
+			return
+		}
+		assert.NotEmpty(t, parsed.host, ref)
+		assert.NotEmpty(t, parsed.repo, ref)
+		assert.GreaterOrEqual(t, parsed.number, 0, ref)
+	})
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// kubectlTimeout 是一条 kubectl 命令的最长执行时间
+const kubectlTimeout = 30 * time.Second
+
+// ReadOnlyVerbs 是 kubernetes 工具默认允许的 kubectl 命令，它们都不修改集群
+var ReadOnlyVerbs = []string{"get", "describe", "logs", "top", "events", "explain", "api-resources"}
+
+// kubectlDeniedFlags 会切换集群或身份，或让命令一直运行，模型不能使用
+var kubectlDeniedFlags = []string{"--kubeconfig", "--context", "--cluster", "--user", "--token", "--as", "--as-group", "--server", "-s", "--follow", "-f", "--watch", "-w"}
+
+// kubectlRawFlag 读取任意 API 路径，可以绕过对 Secret 的限制
+const kubectlRawFlag = "--raw"
+
+// KubernetesInput 定义 kubernetes 工具的输入参数
+type KubernetesInput struct {
+	Verb      string   `json:"verb" jsonschema_description:"The kubectl command, e.g. get, describe or logs."`
+	Args      []string `json:"args,omitempty" jsonschema_description:"Arguments after the command, e.g. [\"pods\", \"-l\", \"app=api\", \"-o\", \"wide\"] or [\"deploy/api\", \"--tail=200\"]."`
+	Namespace string   `json:"namespace,omitempty" jsonschema_description:"Namespace to use instead of the configured one."`
+}
+
+// Kubernetes 是 kubernetes 工具访问的集群
+type Kubernetes struct {
+	// Context 和 Namespace 为空时使用 kubeconfig 中的当前值
+	Context   string
+	Namespace string
+	// Verbs 是在 ReadOnlyVerbs 之外允许的命令
+	Verbs []string
+	// Secrets 允许读取 Secret 资源，Raw 允许用 --raw 访问任意 API 路径；默认都不允许，
+	// 只读的命令也会把凭据交给模型
+	Secrets bool
+	Raw     bool
+	// Kubectl 是 kubectl 命令，为空时为 kubectl
+	Kubectl string
+}
+
+func init() {
+	// 配置可以允许修改集群的命令，因此不注册为只读，调用需要确认的前端会先征得同意
+	Register(Spec{Name: "kubernetes", Category: CategoryIntegrations, Permissions: []Permission{PermissionExec, PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) { return KubernetesTool(env.Kubernetes), env.Kubernetes != nil }})
+}
+
+// KubernetesTool 返回用 kubectl 查看集群的工具定义
+func KubernetesTool(k *Kubernetes) ToolDefinition {
+	verbs := ReadOnlyVerbs
+	if k != nil {
+		verbs = k.verbs()
+	}
+	description := fmt.Sprintf("Inspect the Kubernetes cluster the code is deployed to with kubectl, e.g. to check pods, events and logs while debugging. Allowed commands: %s. Following or watching is not supported; use --tail or --since for logs.", strings.Join(verbs, ", "))
+	if k == nil || !k.Secrets {
+		description += " Secrets cannot be read."
+	}
+	return ToolDefinition{
+		Name:        "kubernetes",
+		Description: description,
+		InputSchema: GenerateSchema[KubernetesInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(k.Run(ctx, input))
+		},
+	}
+}
+
+// verbs 返回允许的全部命令
+func (k *Kubernetes) verbs() []string {
+	verbs := append([]string{}, ReadOnlyVerbs...)
+	for _, verb := range k.Verbs {
+		if !slices.Contains(verbs, verb) {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
+
+// Run 执行一条 kubectl 命令，返回它的输出
+func (k *Kubernetes) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var params KubernetesInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	args, err := k.command(params)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kubectlTimeout)
+	defer cancel()
+	out, truncated, err := runLimited(ctx, args, maxCommandOutput)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("kubectl %s timed out after %s", params.Verb, kubectlTimeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	text := string(out)
+	if text == "" {
+		return "(no output)", nil
+	}
+	if truncated {
+		text += fmt.Sprintf("\n(showing the first %s; narrow the selection, e.g. with -l, --tail or --since)\n", formatBytes(maxCommandOutput))
+	}
+	return text, nil
+}
+
+// namesSecrets 报告参数是否指向 Secret 资源，例如 secrets、secret/db、pods,secrets 或 secrets.v1
+func namesSecrets(arg string) bool {
+	for _, resource := range strings.Split(arg, ",") {
+		resource, _, _ = strings.Cut(resource, "/")
+		resource, _, _ = strings.Cut(resource, ".")
+		if resource = strings.ToLower(resource); resource == "secret" || resource == "secrets" {
+			return true
+		}
+	}
+	return false
+}
+
+// command 返回执行 params 的 kubectl 命令行
+func (k *Kubernetes) command(params KubernetesInput) ([]string, error) {
+	if params.Verb == "" {
+		return nil, invalidInput("verb must not be empty")
+	}
+	if !slices.Contains(k.verbs(), params.Verb) {
+		return nil, fmt.Errorf("kubectl %s %w: allowed commands are %s", params.Verb, ErrDenied, strings.Join(k.verbs(), ", "))
+	}
+	for _, arg := range params.Args {
+		name, _, _ := strings.Cut(arg, "=")
+		// 短选项的值可以紧跟在后面，例如 -shttps://other-cluster
+		if len(name) > 2 && name[0] == '-' && name[1] != '-' {
+			name = name[:2]
+		}
+		if slices.Contains(kubectlDeniedFlags, name) || name == kubectlRawFlag && !k.Raw {
+			return nil, fmt.Errorf("kubectl flag %s %w", name, ErrDenied)
+		}
+		if !k.Secrets && !strings.HasPrefix(arg, "-") && namesSecrets(arg) {
+			return nil, fmt.Errorf("kubectl %s %s %w: reading secrets is not enabled", params.Verb, arg, ErrDenied)
+		}
+	}
+
+	kubectl := k.Kubectl
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+	args := []string{kubectl}
+	if k.Context != "" {
+		args = append(args, "--context", k.Context)
+	}
+	namespace := k.Namespace
+	if params.Namespace != "" {
+		namespace = params.Namespace
+	}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	args = append(args, params.Verb)
+	return append(args, params.Args...), nil
+}
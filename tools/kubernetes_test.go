@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesCommand(t *testing.T) {
+	k := &Kubernetes{Context: "staging", Namespace: "api"}
+
+	args, err := k.command(KubernetesInput{Verb: "logs", Args: []string{"deploy/api", "--tail=200"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kubectl", "--context", "staging", "--namespace", "api", "logs", "deploy/api", "--tail=200"}, args)
+
+	args, err = k.command(KubernetesInput{Verb: "get", Args: []string{"pods"}, Namespace: "jobs"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kubectl", "--context", "staging", "--namespace", "jobs", "get", "pods"}, args)
+
+	t.Run("默认只允许只读的命令", func(t *testing.T) {
+		for _, verb := range []string{"delete", "apply", "exec", "rollout"} {
+			_, err := k.command(KubernetesInput{Verb: verb, Args: []string{"pod/x"}})
+			assert.Equal(t, ErrorPermissionDenied, KindOf(err), verb)
+		}
+		_, err := (&Kubernetes{Verbs: []string{"rollout"}}).command(KubernetesInput{Verb: "rollout", Args: []string{"restart", "deploy/api"}})
+		assert.NoError(t, err, "配置允许的命令")
+	})
+
+	t.Run("默认不能读取 Secret 和使用 --raw", func(t *testing.T) {
+		for _, input := range []KubernetesInput{
+			{Verb: "get", Args: []string{"secrets", "-o", "yaml"}},
+			{Verb: "get", Args: []string{"secret/db-password", "-o", "jsonpath={.data}"}},
+			{Verb: "get", Args: []string{"pods,Secrets"}},
+			{Verb: "describe", Args: []string{"secrets.v1"}},
+			{Verb: "get", Args: []string{"--raw", "/api/v1/namespaces/api/secrets"}},
+			{Verb: "get", Args: []string{"--raw=/api/v1/secrets"}},
+		} {
+			_, err := k.command(input)
+			assert.Equal(t, ErrorPermissionDenied, KindOf(err), input.Args)
+		}
+		_, err := k.command(KubernetesInput{Verb: "get", Args: []string{"pods", "-l", "app=secrets"}})
+		assert.NoError(t, err, "选项的值不是资源")
+
+		allowed := &Kubernetes{Secrets: true, Raw: true}
+		_, err = allowed.command(KubernetesInput{Verb: "get", Args: []string{"secrets"}})
+		assert.NoError(t, err, "配置允许时可以读取")
+		_, err = allowed.command(KubernetesInput{Verb: "get", Args: []string{"--raw", "/healthz"}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("拒绝切换集群和持续运行的参数", func(t *testing.T) {
+		for _, args := range [][]string{{"pods", "--context=prod"}, {"pods", "--kubeconfig", "/tmp/x"}, {"pods", "-w"}, {"deploy/api", "-f"}, {"pods", "--as=admin"}, {"pods", "-shttps://other:6443"}} {
+			_, err := k.command(KubernetesInput{Verb: "get", Args: args})
+			assert.Equal(t, ErrorPermissionDenied, KindOf(err), args)
+		}
+		_, err := k.command(KubernetesInput{})
+		assert.Equal(t, ErrorInvalidInput, KindOf(err))
+		_, err = k.command(KubernetesInput{Verb: "get", Args: []string{"pods", "-lapp=api", "-owide"}})
+		assert.NoError(t, err, "其他短选项的值可以紧跟在后面")
+	})
+}
+
+func TestKubernetesRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	kubectl := filepath.Join(t.TempDir(), "kubectl")
+	require.NoError(t, os.WriteFile(kubectl, []byte("#!/bin/sh\necho \"$@\"\n"), 0755))
+	k := &Kubernetes{Namespace: "api", Kubectl: kubectl}
+
+	out, err := k.Run(context.Background(), json.RawMessage(`{"verb":"describe","args":["pod/api-0"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "--namespace api describe pod/api-0\n", out)
+
+	defs := Default.Snapshot(Env{Workspace: &Workspace{Root: "."}, Kubernetes: k})
+	names := []string{}
+	for _, def := range defs {
+		names = append(names, def.Name)
+		if def.Name == "kubernetes" {
+			assert.False(t, def.ReadOnly, "配置可以允许修改集群的命令")
+		}
+	}
+	assert.Contains(t, names, "kubernetes")
+}
+
+// FuzzKubernetesCommand 检查生成的命令行只有允许的命令，也不带切换集群或持续运行的参数
+func FuzzKubernetesCommand(f *testing.F) {
+	for _, input := range []string{`{"verb": "get", "args": ["pods", "-o", "wide"]}`, `{"verb": "logs", "args": ["deploy/api", "--tail=200"]}`,
+		`{"verb": "delete", "args": ["pod/x"]}`, `{"verb": "get", "args": ["pods", "--context=prod"]}`, `{"verb": "get", "args": ["-shttps://x"]}`,
+		`{"verb": "get", "namespace": "kube-system"}`, `{"verb": ""}`, `[]`} {
+		f.Add([]byte(input))
+	}
+	k := &Kubernetes{Context: "staging", Namespace: "api"}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		var params KubernetesInput
+		if json.Unmarshal(input, &params) != nil {
+			return
+		}
+		args, err := k.command(params)
+		if err != nil {
+			return
+		}
+		assert.Equal(t, []string{"kubectl", "--context", "staging"}, args[:3])
+		assert.Contains(t, k.verbs(), params.Verb)
+		for _, arg := range params.Args {
+			for _, flag := range kubectlDeniedFlags {
+				denied := arg == flag || strings.HasPrefix(arg, flag+"=") || len(flag) == 2 && strings.HasPrefix(arg, flag)
+				assert.False(t, denied, "参数 %q 是 %s", arg, flag)
+			}
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"agent/lsp"
+)
+
+// SymbolInput 通过文件、行号和符号名定位一个符号，比让模型计算列号更可靠
+type SymbolInput struct {
+	Path   string `json:"path" jsonschema_description:"The relative path of the file containing the symbol."`
+	Line   int    `json:"line" jsonschema_description:"The 1-based line number where the symbol appears."`
+	Symbol string `json:"symbol" jsonschema_description:"The identifier on that line, e.g. a function, type, method or variable name."`
+}
+
+// RenameSymbolInput 定义 rename_symbol 工具的输入参数
+type RenameSymbolInput struct {
+	Path    string `json:"path" jsonschema_description:"The relative path of the file containing the symbol."`
+	Line    int    `json:"line" jsonschema_description:"The 1-based line number where the symbol appears."`
+	Symbol  string `json:"symbol" jsonschema_description:"The identifier on that line to rename."`
+	NewName string `json:"new_name" jsonschema_description:"The new name for the symbol."`
+}
+
+func init() {
+	for i, spec := range []Spec{
+		{Name: "go_to_definition", ReadOnly: true, Permissions: []Permission{PermissionRead}},
+		{Name: "find_references", ReadOnly: true, Permissions: []Permission{PermissionRead}},
+		{Name: "rename_symbol", Permissions: []Permission{PermissionRead, PermissionWrite}},
+	} {
+		i := i
+		spec.Category = CategoryCode
+		spec.New = func(env Env) (ToolDefinition, bool) {
+			return LSPTools(env.Workspace, env.LSP)[i], env.LSP != nil
+		}
+		Register(spec)
+	}
+}
+
+// LSPTools 返回通过语言服务器 client 查找定义、引用和重命名符号的工具定义
+func LSPTools(w *Workspace, client *lsp.Client) []ToolDefinition {
+	return []ToolDefinition{
+		{
+			Name:        "go_to_definition",
+			Description: "Find where a Go symbol is defined, using the gopls language server. Give the file, the line where the symbol is used and its name. Returns file:line:column and the source line of each definition.",
+			InputSchema: GenerateSchema[SymbolInput](),
+			Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+				return Result(w.findLocations(ctx, client.Definition, input))
+			},
+			ReadOnly: true,
+		},
+		{
+			Name:        "find_references",
+			Description: "Find all references to a Go symbol, including its declaration, using the gopls language server. More accurate than searching for the name, since it follows the type checker.",
+			InputSchema: GenerateSchema[SymbolInput](),
+			Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+				return Result(w.findLocations(ctx, client.References, input))
+			},
+			ReadOnly: true,
+		},
+		{
+			Name:        "rename_symbol",
+			Description: "Rename a Go symbol and update every reference to it across the workspace, using the gopls language server. Safer than editing each occurrence by hand.",
+			InputSchema: GenerateSchema[RenameSymbolInput](),
+			Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+				return Result(w.renameSymbol(ctx, client, input))
+			},
+		},
+	}
+}
+
+// findLocations 解析输入，用 find 查询符号的位置并格式化结果
+func (w *Workspace) findLocations(ctx context.Context, find func(context.Context, string, lsp.Position) ([]lsp.Location, error), input json.RawMessage) (string, error) {
+	var params SymbolInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	path, pos, err := w.symbolPosition(params.Path, params.Line, params.Symbol)
+	if err != nil {
+		return "", err
+	}
+	locations, err := find(ctx, path, pos)
+	if err != nil {
+		return "", err
+	}
+	if len(locations) == 0 {
+		return fmt.Sprintf("No results for %s.", params.Symbol), nil
+	}
+
+	lines := []string{}
+	for _, location := range locations {
+		lines = append(lines, w.formatLocation(location))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+// renameSymbol 重命名符号并把修改写入工作区中的文件；任何文件在工作区之外时不做修改
+func (w *Workspace) renameSymbol(ctx context.Context, client *lsp.Client, input json.RawMessage) (string, error) {
+	var params RenameSymbolInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	if params.NewName == "" {
+		return "", fmt.Errorf("new_name must not be empty")
+	}
+	path, pos, err := w.symbolPosition(params.Path, params.Line, params.Symbol)
+	if err != nil {
+		return "", err
+	}
+	edit, err := client.Rename(ctx, path, pos, params.NewName)
+	if err != nil {
+		return "", err
+	}
+	files, err := edit.Files()
+	if err != nil {
+		return "", err
+	}
+
+	// 先计算所有文件的新内容，全部通过检查后再写入
+	updated := map[string]string{}
+	names := []string{}
+	count := 0
+	for file, edits := range files {
+		name := w.relative(file)
+		if _, err := w.Resolve(name); err != nil {
+			return "", fmt.Errorf("rename would change %s: %w", file, err)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		text, err := lsp.ApplyEdits(string(content), edits)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply rename to %s: %w", name, err)
+		}
+		if err := checkSize(name, int64(len(text)), w.MaxWriteBytes, "write"); err != nil {
+			return "", err
+		}
+		updated[file] = text
+		names = append(names, fmt.Sprintf("%s (%d)", name, len(edits)))
+		count += len(edits)
+	}
+	if count == 0 {
+		return fmt.Sprintf("Nothing to rename for %s.", params.Symbol), nil
+	}
+	for file, text := range updated {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(file, []byte(text), info.Mode()); err != nil {
+			return "", err
+		}
+		w.Reads.Forget(file)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("Renamed %s to %s: %d edits in %d files:\n%s", params.Symbol, params.NewName, count, len(names), strings.Join(names, "\n")), nil
+}
+
+// symbolPosition 返回符号在文件中的绝对路径和 LSP 位置，符号需要作为完整的标识符出现在该行
+func (w *Workspace) symbolPosition(name string, line int, symbol string) (string, lsp.Position, error) {
+	path, err := w.Resolve(name)
+	if err != nil {
+		return "", lsp.Position{}, err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", lsp.Position{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", lsp.Position{}, fmt.Errorf("failed to read file %s: %w", name, err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if line < 1 || line > len(lines) {
+		return "", lsp.Position{}, fmt.Errorf("line %d is out of range: %s has %d lines", line, name, len(lines))
+	}
+	text := lines[line-1]
+	match := regexp.MustCompile(`(^|[^\pL\pN_])(` + regexp.QuoteMeta(symbol) + `)($|[^\pL\pN_])`).FindStringSubmatchIndex(text)
+	if symbol == "" || match == nil {
+		return "", lsp.Position{}, fmt.Errorf("symbol %q not found on line %d of %s: %s", symbol, line, name, strings.TrimSpace(text))
+	}
+	return path, lsp.Position{Line: line - 1, Character: lsp.Character(text, match[4])}, nil
+}
+
+// formatLocation 把位置格式化为 path:line:column 加上该行的源码，工作区内的路径使用相对路径
+func (w *Workspace) formatLocation(location lsp.Location) string {
+	path, err := lsp.URIToPath(location.URI)
+	if err != nil {
+		return location.URI
+	}
+	start := location.Range.Start
+	result := fmt.Sprintf("%s:%d:%d", w.relative(path), start.Line+1, start.Character+1)
+	if content, err := os.ReadFile(path); err == nil {
+		if lines := strings.Split(string(content), "\n"); start.Line < len(lines) {
+			result += ": " + strings.TrimSpace(lines[start.Line])
+		}
+	}
+	return result
+}
+
+// relative 返回 path 相对于工作区根目录的路径，不在工作区内时返回原路径
+func (w *Workspace) relative(path string) string {
+	root, err := filepath.Abs(w.Root)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
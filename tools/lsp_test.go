@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"agent/lsp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGoModule 在临时目录中创建一个小的 Go 模块，返回工作区和语言服务器客户端
+func newGoModule(t *testing.T) (*Workspace, *lsp.Client) {
+	t.Helper()
+	if _, err := exec.LookPath("gopls"); err != nil {
+		t.Skip("需要 gopls")
+	}
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":          "module example\n\ngo 1.21\n",
+		"main.go":         "package main\n\nimport \"example/greet\"\n\nfunc main() {\n\tgreet.Hello(\"world\")\n}\n",
+		"greet/greet.go":  "package greet\n\nimport \"fmt\"\n\n// Hello 打印问候\nfunc Hello(name string) {\n\tfmt.Println(\"hello\", name)\n}\n",
+		"greet/helper.go": "package greet\n\nfunc twice(name string) {\n\tHello(name)\n\tHello(name)\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	client := &lsp.Client{Root: root, Command: []string{"gopls"}, Extensions: []string{".go", "go.mod"}}
+	t.Cleanup(func() { client.Close() })
+	return &Workspace{Root: root}, client
+}
+
+func callTool(t *testing.T, tools []ToolDefinition, name, input string) (string, error) {
+	t.Helper()
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool.Function(context.Background(), json.RawMessage(input)).Output()
+		}
+	}
+	t.Fatalf("tool %s not found", name)
+	return "", nil
+}
+
+func TestLSPTools(t *testing.T) {
+	ws, client := newGoModule(t)
+	tools := LSPTools(ws, client)
+
+	t.Run("跳转到定义", func(t *testing.T) {
+		result, err := callTool(t, tools, "go_to_definition", `{"path":"main.go","line":6,"symbol":"Hello"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "greet/greet.go:6:6: func Hello(name string) {", result)
+	})
+
+	t.Run("查找引用", func(t *testing.T) {
+		result, err := callTool(t, tools, "find_references", `{"path":"greet/greet.go","line":6,"symbol":"Hello"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "greet/greet.go:6:6: func Hello(name string) {\n"+
+			"greet/helper.go:4:2: Hello(name)\n"+
+			"greet/helper.go:5:2: Hello(name)\n"+
+			"main.go:6:8: greet.Hello(\"world\")", result)
+	})
+
+	t.Run("符号不在该行", func(t *testing.T) {
+		_, err := callTool(t, tools, "find_references", `{"path":"main.go","line":6,"symbol":"Hell"}`)
+		assert.ErrorContains(t, err, `symbol "Hell" not found on line 6 of main.go`)
+		_, err = callTool(t, tools, "find_references", `{"path":"main.go","line":60,"symbol":"Hello"}`)
+		assert.ErrorContains(t, err, "out of range")
+		_, err = callTool(t, tools, "find_references", `{"path":"../main.go","line":6,"symbol":"Hello"}`)
+		assert.ErrorContains(t, err, "outside the workspace")
+	})
+
+	t.Run("重命名后查询反映新内容", func(t *testing.T) {
+		result, err := callTool(t, tools, "rename_symbol", `{"path":"main.go","line":6,"symbol":"Hello","new_name":"Greet"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed Hello to Greet: 5 edits in 3 files:\ngreet/greet.go (2)\ngreet/helper.go (2)\nmain.go (1)", result)
+		data, err := os.ReadFile(filepath.Join(ws.Root, "greet/helper.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "\tGreet(name)\n\tGreet(name)\n")
+		data, err = os.ReadFile(filepath.Join(ws.Root, "greet/greet.go"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "// Greet 打印问候\nfunc Greet(", "文档注释也一起更新")
+
+		// 在磁盘上修改文件后，语言服务器应看到新的调用
+		main := filepath.Join(ws.Root, "main.go")
+		require.NoError(t, os.WriteFile(main, []byte("package main\n\nimport \"example/greet\"\n\nfunc main() {\n\tgreet.Greet(\"a\")\n\tgreet.Greet(\"b\")\n}\n"), 0644))
+		result, err = callTool(t, tools, "find_references", `{"path":"greet/greet.go","line":6,"symbol":"Greet"}`)
+		require.NoError(t, err)
+		assert.Contains(t, result, "main.go:7:8: greet.Greet(\"b\")")
+	})
+}
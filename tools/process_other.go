@@ -0,0 +1,20 @@
+//go:build !windows
+
+package tools
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// killOnCancel 让 cmd 在自己的进程组中运行，ctx 取消或超时时终止整个进程组，
+// 这样 sh -c 等启动的子进程也会停止，不会在调用结束后继续修改工作区
+func killOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// 仍持有输出管道的进程不会让 Wait 一直等待
+	cmd.WaitDelay = time.Second
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package tools
+
+import (
+	"os/exec"
+	"time"
+)
+
+// killOnCancel 在 ctx 取消或超时时终止 cmd。Windows 上只终止 cmd 本身
+func killOnCancel(cmd *exec.Cmd) {
+	cmd.WaitDelay = time.Second
+}
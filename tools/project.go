@@ -0,0 +1,259 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// projectTimeout 是 run_tests、build 和 format 运行一个项目命令的最长时间
+const projectTimeout = 10 * time.Minute
+
+// Project 是在工作区中识别出的一个项目及其构建、测试和格式化命令，
+// 不支持的操作命令为空
+type Project struct {
+	// Kind 是 go、node、python 或 rust
+	Kind string
+	// Dir 是项目相对工作区根目录的目录，根目录为 .
+	Dir    string
+	Build  []string
+	Test   []string
+	Format []string
+}
+
+// projectDetector 在目录中识别一种项目，不是这种项目时返回 false
+type projectDetector func(dir string) (Project, bool)
+
+// projectDetectors 按顺序识别各种项目，一个目录可以同时是几种项目
+var projectDetectors = []projectDetector{detectGo, detectNode, detectPython, detectRust}
+
+// skippedProjectDirs 是识别子目录中的项目时跳过的目录
+var skippedProjectDirs = map[string]bool{"node_modules": true, "vendor": true, "target": true, "dist": true, "build": true, "testdata": true}
+
+// DetectProjects 识别工作区根目录和第一层子目录中的项目，例如根目录的 Go 模块和
+// web 目录中的 Node 项目
+func DetectProjects(root string) []Project {
+	dirs := []string{"."}
+	entries, _ := os.ReadDir(root)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() && !strings.HasPrefix(name, ".") && !skippedProjectDirs[name] {
+			dirs = append(dirs, name)
+		}
+	}
+	var projects []Project
+	for _, dir := range dirs {
+		for _, detect := range projectDetectors {
+			if project, ok := detect(filepath.Join(root, dir)); ok {
+				project.Dir = dir
+				projects = append(projects, project)
+			}
+		}
+	}
+	return projects
+}
+
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+func detectGo(dir string) (Project, bool) {
+	return Project{
+		Kind:   "go",
+		Build:  []string{"go", "build", "./..."},
+		Test:   []string{"go", "test", "./..."},
+		Format: []string{"gofmt", "-l", "-w", "."},
+	}, exists(dir, "go.mod")
+}
+
+// detectNode 按锁文件选择 npm、pnpm 或 yarn，只提供 package.json 中定义了脚本的操作；
+// 没有 format 脚本时用 prettier 格式化
+func detectNode(dir string) (Project, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return Project{}, false
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	_ = json.Unmarshal(data, &pkg)
+	manager := "npm"
+	switch {
+	case exists(dir, "pnpm-lock.yaml"):
+		manager = "pnpm"
+	case exists(dir, "yarn.lock"):
+		manager = "yarn"
+	}
+	project := Project{Kind: "node", Format: []string{"npx", "prettier", "--write", "."}}
+	if _, ok := pkg.Scripts["build"]; ok {
+		project.Build = []string{manager, "run", "build"}
+	}
+	if _, ok := pkg.Scripts["test"]; ok {
+		project.Test = []string{manager, "test"}
+	}
+	if _, ok := pkg.Scripts["format"]; ok {
+		project.Format = []string{manager, "run", "format"}
+	}
+	return project, true
+}
+
+// detectPython 用 uv 管理的项目通过 uv run 执行命令；配置了 ruff 时用它格式化，否则用 black
+func detectPython(dir string) (Project, bool) {
+	if !exists(dir, "pyproject.toml") && !exists(dir, "setup.py") && !exists(dir, "requirements.txt") {
+		return Project{}, false
+	}
+	python := []string{"python", "-m"}
+	if exists(dir, "uv.lock") {
+		python = []string{"uv", "run", "python", "-m"}
+	}
+	run := func(args ...string) []string { return append(append([]string{}, python...), args...) }
+	pyproject, _ := os.ReadFile(filepath.Join(dir, "pyproject.toml"))
+	format := run("black", ".")
+	if exists(dir, "ruff.toml") || strings.Contains(string(pyproject), "[tool.ruff") {
+		format = run("ruff", "format", ".")
+	}
+	return Project{
+		Kind:   "python",
+		Build:  run("compileall", "-q", "."),
+		Test:   run("pytest"),
+		Format: format,
+	}, true
+}
+
+func detectRust(dir string) (Project, bool) {
+	return Project{
+		Kind:   "rust",
+		Build:  []string{"cargo", "build"},
+		Test:   []string{"cargo", "test"},
+		Format: []string{"cargo", "fmt"},
+	}, exists(dir, "Cargo.toml")
+}
+
+// ProjectCommandInput 定义 run_tests、build 和 format 工具的输入参数
+type ProjectCommandInput struct {
+	Project string   `json:"project,omitempty" jsonschema_description:"Run only for this project, given by its directory (e.g. web) or its kind (go, node, python or rust). Default: every detected project."`
+	Args    []string `json:"args,omitempty" jsonschema_description:"Extra arguments appended to the command, e.g. a package or a test name filter. Only allowed when a single project is selected."`
+}
+
+// projectActions 是 run_tests、build 和 format 工具各自运行的命令
+var projectActions = []struct {
+	tool, verb string
+	command    func(Project) []string
+}{
+	{"run_tests", "Run the tests of", func(p Project) []string { return p.Test }},
+	{"build", "Build", func(p Project) []string { return p.Build }},
+	{"format", "Format the source files of", func(p Project) []string { return p.Format }},
+}
+
+func init() {
+	for _, action := range projectActions {
+		action := action
+		// 这些命令会执行项目的代码或改写文件，因此不注册为只读
+		Register(Spec{Name: action.tool, Category: CategoryCode, Permissions: []Permission{PermissionRead, PermissionWrite, PermissionExec},
+			New: func(env Env) (ToolDefinition, bool) {
+				return ProjectTool(env.Workspace, action.tool, action.verb, env.Projects, action.command), hasCommand(env.Projects, action.command)
+			}})
+	}
+}
+
+func hasCommand(projects []Project, command func(Project) []string) bool {
+	for _, project := range projects {
+		if len(command(project)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ProjectTool 返回在工作区 w 的每个项目中运行 command 给出的命令的工具定义
+func ProjectTool(w *Workspace, name, verb string, projects []Project, command func(Project) []string) ToolDefinition {
+	var lines []string
+	for _, project := range projects {
+		if args := command(project); len(args) > 0 {
+			lines = append(lines, fmt.Sprintf("%s (%s): `%s`", project.Kind, project.Dir, strings.Join(args, " ")))
+		}
+	}
+	description := verb + " the projects in the workspace, each with the command its language uses, so you need not know the build system."
+	if len(lines) > 0 {
+		description += " Detected projects: " + strings.Join(lines, ", ")
+	}
+	return ToolDefinition{
+		Name:        name,
+		Description: description,
+		InputSchema: GenerateSchema[ProjectCommandInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.RunProjectCommand(ctx, projects, command, input))
+		},
+	}
+}
+
+// RunProjectCommand 在选中的项目中依次运行命令，全部成功时返回输出，否则返回包含失败输出的错误
+func (w *Workspace) RunProjectCommand(ctx context.Context, projects []Project, command func(Project) []string, input json.RawMessage) (string, error) {
+	var params ProjectCommandInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	var selected []Project
+	for _, project := range projects {
+		if len(command(project)) == 0 {
+			continue
+		}
+		if params.Project == "" || params.Project == project.Kind || filepath.Clean(params.Project) == project.Dir {
+			selected = append(selected, project)
+		}
+	}
+	if len(selected) == 0 {
+		var names []string
+		for _, project := range projects {
+			if len(command(project)) > 0 {
+				names = append(names, project.Kind+" ("+project.Dir+")")
+			}
+		}
+		sort.Strings(names)
+		return "", invalidInput("no project %q; detected: %s", params.Project, strings.Join(names, ", "))
+	}
+	if len(params.Args) > 0 && len(selected) > 1 {
+		return "", invalidInput("args need a single project; set project to one of the directories")
+	}
+
+	var b strings.Builder
+	var failed []string
+	for _, project := range selected {
+		args := append(append([]string{}, command(project)...), params.Args...)
+		fmt.Fprintf(&b, "$ %s  (in %s)\n", strings.Join(args, " "), project.Dir)
+		output, err := w.runInProject(ctx, project.Dir, args)
+		b.WriteString(output)
+		if err != nil {
+			fmt.Fprintf(&b, "%v\n", err)
+			failed = append(failed, project.Kind+" ("+project.Dir+")")
+		}
+		b.WriteString("\n")
+	}
+	if len(failed) > 0 {
+		return "", fmt.Errorf("failed in %s:\n%s", strings.Join(failed, ", "), strings.TrimRight(b.String(), "\n"))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (w *Workspace) runInProject(ctx context.Context, dir string, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, projectTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	killOnCancel(cmd)
+	cmd.Dir = filepath.Join(w.Root, dir)
+	output := &tailBuffer{limit: maxCommandOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output.String(), fmt.Errorf("timed out after %s", projectTimeout)
+	}
+	return output.String(), err
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProjects(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write("go.mod", "module example\n")
+	write("web/package.json", `{"scripts": {"test": "vitest run"}}`)
+	write("web/pnpm-lock.yaml", "")
+	write("ml/pyproject.toml", "[tool.ruff]\nline-length = 100\n")
+	write("ml/uv.lock", "")
+	write("engine/Cargo.toml", "[package]\n")
+	write("web/node_modules/dep/package.json", "{}")
+	write("docs/README.md", "")
+
+	projects := DetectProjects(root)
+	require.Len(t, projects, 4)
+	assert.Equal(t, Project{Kind: "go", Dir: ".", Build: []string{"go", "build", "./..."}, Test: []string{"go", "test", "./..."}, Format: []string{"gofmt", "-l", "-w", "."}}, projects[0])
+	assert.Equal(t, "rust", projects[1].Kind)
+	assert.Equal(t, "engine", projects[1].Dir)
+
+	python := projects[2]
+	assert.Equal(t, "ml", python.Dir)
+	assert.Equal(t, []string{"uv", "run", "python", "-m", "pytest"}, python.Test)
+	assert.Equal(t, []string{"uv", "run", "python", "-m", "ruff", "format", "."}, python.Format, "配置了 ruff 时用它格式化")
+
+	node := projects[3]
+	assert.Equal(t, "web", node.Dir)
+	assert.Equal(t, []string{"pnpm", "test"}, node.Test, "按锁文件选择包管理器")
+	assert.Empty(t, node.Build, "没有 build 脚本时不提供构建")
+}
+
+func TestRunProjectCommand(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "web"), 0755))
+	w := &Workspace{Root: root}
+	projects := []Project{
+		{Kind: "go", Dir: ".", Test: []string{"sh", "-c", `echo "ok in $(basename "$PWD")" "$@"`, "sh"}},
+		{Kind: "node", Dir: "web", Test: []string{"sh", "-c", "echo 1 failing test; exit 1"}},
+		{Kind: "rust", Dir: "engine"},
+	}
+	test := func(p Project) []string { return p.Test }
+	run := func(input string) (string, error) {
+		return w.RunProjectCommand(context.Background(), projects, test, json.RawMessage(input))
+	}
+
+	out, err := run(`{"project": "go", "args": ["-run", "TestX"]}`)
+	require.NoError(t, err)
+	assert.Contains(t, out, "ok in "+filepath.Base(root)+" -run TestX")
+
+	_, err = run(`{}`)
+	assert.ErrorContains(t, err, "failed in node (web)")
+	assert.ErrorContains(t, err, "1 failing test")
+
+	out, err = run(`{"project": "web/"}`)
+	assert.Error(t, err, "可以用目录选择项目")
+	assert.Empty(t, out)
+
+	_, err = run(`{"project": "rust"}`)
+	assert.ErrorContains(t, err, "detected: go (.), node (web)", "没有测试命令的项目不能选择")
+	_, err = run(`{"args": ["-v"]}`)
+	assert.ErrorContains(t, err, "single project")
+
+	offered := func(projects []Project) []string {
+		var names []string
+		for _, def := range Default.Snapshot(Env{Workspace: w, Projects: projects}) {
+			names = append(names, def.Name)
+		}
+		return names
+	}
+	assert.Contains(t, offered(projects), "run_tests")
+	assert.NotContains(t, offered(projects), "build", "没有项目能构建时不提供 build")
+}
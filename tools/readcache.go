@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// ReadCache 记录本会话中 read_file 读过的文件内容，以及读取时的修改时间和大小，
+// 用于在文件未变化时告诉模型"自上次读取后未改变"，而不是再次发送整个文件。
+// 零值可以直接使用，nil 表示不缓存
+type ReadCache struct {
+	mu    sync.Mutex
+	files map[string]cachedFile
+}
+
+type cachedFile struct {
+	content string
+	modTime time.Time
+	size    int64
+	readAt  time.Time
+}
+
+// unchanged 判断 path 自上次读取后是否未变化。修改时间和大小都相同、且修改时间早于
+// 上次读取时间一个精度单位以上时直接认为未变化；否则重新读取并比较内容，
+// 这样文件系统的时间精度不足时也不会漏掉修改
+func (c *ReadCache) unchanged(path string, info os.FileInfo) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	cached, ok := c.files[path]
+	c.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if info.ModTime().Equal(cached.modTime) && info.Size() == cached.size &&
+		info.ModTime().Before(cached.readAt.Add(-time.Second)) {
+		return true
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != cached.content {
+		return false
+	}
+	c.put(path, info, cached.content)
+	return true
+}
+
+// put 记录刚读到的文件内容
+func (c *ReadCache) put(path string, info os.FileInfo, content string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.files == nil {
+		c.files = map[string]cachedFile{}
+	}
+	c.files[path] = cachedFile{content: content, modTime: info.ModTime(), size: info.Size(), readAt: time.Now()}
+}
+
+// Forget 删除 path 的记录，例如文件被修改之后
+func (c *ReadCache) Forget(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.files, path)
+}
+
+// Reset 清空全部记录。对话被截断或替换时需要调用，
+// 因为模型可能已经看不到之前读取的内容
+func (c *ReadCache) Reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = nil
+}
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCache(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	// 修改时间设在过去，避免落在"刚读取"的精度窗口内
+	old := time.Now().Add(-time.Hour)
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(path, old, old))
+	}
+	write("package main\n")
+
+	w := &Workspace{Root: root, Reads: &ReadCache{}}
+	read := func() string {
+		out, err := w.ReadFile(context.Background(), json.RawMessage(`{"path": "main.go"}`))
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.Equal(t, "package main\n", read())
+
+	t.Run("未变化的文件再次读取只返回提示", func(t *testing.T) {
+		assert.Contains(t, read(), "File main.go is unchanged since you last read it")
+	})
+
+	t.Run("修改时间在读取时间附近时比较内容", func(t *testing.T) {
+		// 大小不变，修改时间的精度不足以区分时也能发现修改
+		require.NoError(t, os.WriteFile(path, []byte("package mian\n"), 0644))
+		assert.Equal(t, "package mian\n", read())
+	})
+
+	t.Run("编辑后返回新内容", func(t *testing.T) {
+		_, err := w.EditFile(context.Background(), json.RawMessage(`{"path": "main.go", "old_str": "mian", "new_str": "main"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "package main\n", read())
+	})
+
+	t.Run("Reset 后重新返回内容", func(t *testing.T) {
+		assert.Contains(t, read(), "unchanged")
+		w.Reads.Reset()
+		assert.Equal(t, "package main\n", read())
+	})
+
+	t.Run("没有缓存时总是返回内容", func(t *testing.T) {
+		plain := &Workspace{Root: root}
+		for i := 0; i < 2; i++ {
+			out, err := plain.ReadFile(context.Background(), json.RawMessage(`{"path": "main.go"}`))
+			require.NoError(t, err)
+			assert.Equal(t, "package main\n", out)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,26 +12,52 @@ type ReadFileInput struct {
 	Path string `json:"path" jsonschema_description:"The relative path of a file in the working directory."`
 }
 
-// ReadFile 实现文件读取功能
-func ReadFile(input json.RawMessage) (string, error) {
+// ReadFile 读取工作区中的文件
+func (w *Workspace) ReadFile(ctx context.Context, input json.RawMessage) (string, error) {
 	var params ReadFileInput
 	err := json.Unmarshal(input, &params)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse input: %w", err)
 	}
+	if w.Remote != nil {
+		return w.remoteReadFile(ctx, params.Path)
+	}
+
+	path, err := w.Resolve(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", params.Path, err)
+	}
+	if err := checkSize(params.Path, info.Size(), w.MaxReadBytes, "read"); err != nil {
+		return "", fmt.Errorf("%w; use the shell tool to read part of it, e.g. with head or grep", err)
+	}
 
-	content, err := os.ReadFile(params.Path)
+	if w.Reads.unchanged(path, info) {
+		return fmt.Sprintf("File %s is unchanged since you last read it in this conversation; use that content instead of reading it again.", params.Path), nil
+	}
+
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file %s: %w", params.Path, err)
 	}
+	w.Reads.put(path, info, string(content))
 
 	return string(content), nil
 }
 
-// ReadFileDefinition 文件读取工具的完整定义
-var ReadFileDefinition = ToolDefinition{
-	Name:        "read_file",
-	Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names.",
-	InputSchema: GenerateSchema[ReadFileInput](),
-	Function:    ReadFile,
+// ReadFileTool 返回在工作区 w 中读取文件的工具定义
+func ReadFileTool(w *Workspace) ToolDefinition {
+	return ToolDefinition{
+		Name:        "read_file",
+		Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names.",
+		InputSchema: GenerateSchema[ReadFileInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.ReadFile(ctx, input))
+		},
+		ReadOnly: true,
+	}
 }
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -10,6 +11,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// currentDir 以当前工作目录为根的工作区，测试会先切换到临时目录
+var currentDir = &Workspace{Root: "."}
+
 // 测试清理辅助函数
 func cleanupTestDir(t *testing.T, dir string) {
 	t.Helper()
@@ -85,7 +89,7 @@ func TestReadFile(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		require.NoError(t, err)
 		assert.Equal(t, testContent, result)
 	})
@@ -102,7 +106,7 @@ func TestReadFile(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		require.NoError(t, err)
 		assert.Equal(t, "", result)
 	})
@@ -120,7 +124,7 @@ func TestReadFile(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		require.NoError(t, err)
 		assert.Equal(t, specialContent, result)
 	})
@@ -143,7 +147,7 @@ func TestReadFile(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		require.NoError(t, err)
 		assert.Equal(t, subContent, result)
 	})
@@ -155,7 +159,7 @@ func TestReadFile(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		assert.Error(t, err)
 		assert.Empty(t, result)
 		assert.Contains(t, err.Error(), "failed to read file")
@@ -174,7 +178,7 @@ func TestReadFile(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		assert.Error(t, err)
 		assert.Empty(t, result)
 		assert.Contains(t, err.Error(), "failed to read file")
@@ -187,7 +191,7 @@ func TestReadFile(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		assert.Error(t, err)
 		assert.Empty(t, result)
 	})
@@ -197,7 +201,7 @@ func TestReadFile(t *testing.T) {
 		invalidJSON := json.RawMessage(`{"path": 123}`) // path应该是字符串，不是数字
 
 		// 调用ReadFile函数
-		result, err := ReadFile(invalidJSON)
+		result, err := currentDir.ReadFile(context.Background(), invalidJSON)
 		assert.Error(t, err)
 		assert.Empty(t, result)
 		assert.Contains(t, err.Error(), "failed to parse input")
@@ -208,16 +212,16 @@ func TestReadFile(t *testing.T) {
 		malformedJSON := json.RawMessage(`{"path": "test.txt"`) // 缺少结束括号
 
 		// 调用ReadFile函数
-		result, err := ReadFile(malformedJSON)
+		result, err := currentDir.ReadFile(context.Background(), malformedJSON)
 		assert.Error(t, err)
 		assert.Empty(t, result)
 		assert.Contains(t, err.Error(), "failed to parse input")
 	})
 }
 
-func TestReadFileDefinition(t *testing.T) {
+func TestReadFileTool(t *testing.T) {
 	t.Run("验证工具定义结构", func(t *testing.T) {
-		def := ReadFileDefinition
+		def := ReadFileTool(currentDir)
 
 		// 验证基本字段
 		assert.Equal(t, "read_file", def.Name)
@@ -229,7 +233,7 @@ func TestReadFileDefinition(t *testing.T) {
 	})
 
 	t.Run("验证输入模式", func(t *testing.T) {
-		def := ReadFileDefinition
+		def := ReadFileTool(currentDir)
 		schema := def.InputSchema
 
 		// 验证模式不为空
@@ -264,7 +268,7 @@ func TestReadFileDefinition(t *testing.T) {
 		require.NoError(t, err)
 
 		// 通过定义调用函数
-		result, err := ReadFileDefinition.Function(inputJSON)
+		result, err := ReadFileTool(currentDir).Function(context.Background(), inputJSON).Output()
 		require.NoError(t, err)
 		assert.Equal(t, testContent, result)
 	})
@@ -301,7 +305,7 @@ func TestReadFileBehaviorEdgeCases(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		require.NoError(t, err)
 		assert.Equal(t, string(largeContent), result)
 		assert.Len(t, result, 1024*1024)
@@ -329,7 +333,7 @@ func TestReadFileBehaviorEdgeCases(t *testing.T) {
 		require.NoError(t, err)
 
 		// 调用ReadFile函数
-		result, err := ReadFile(inputJSON)
+		result, err := currentDir.ReadFile(context.Background(), inputJSON)
 		require.NoError(t, err)
 		assert.Equal(t, newlineContent, result)
 	})
@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"agent/lsp"
+	"agent/search"
+)
+
+// Category 是工具的用途分类
+type Category string
+
+const (
+	CategoryFiles        Category = "files"
+	CategorySearch       Category = "search"
+	CategoryShell        Category = "shell"
+	CategoryCode         Category = "code"
+	CategoryIntegrations Category = "integrations"
+	// CategoryCustom 是配置中声明的外部工具和脚本定义的工具，见 ExternalTool 和 ScriptTool
+	CategoryCustom Category = "custom"
+)
+
+// categoryOrder 决定快照中工具的顺序，同一分类内按注册顺序
+var categoryOrder = []Category{CategoryFiles, CategorySearch, CategoryShell, CategoryCode, CategoryIntegrations, CategoryCustom}
+
+// Permission 是工具执行时需要的权限
+type Permission string
+
+const (
+	// PermissionRead 读取工作区中的文件
+	PermissionRead Permission = "read"
+	// PermissionWrite 修改工作区中的文件
+	PermissionWrite Permission = "write"
+	// PermissionExec 执行命令
+	PermissionExec Permission = "exec"
+	// PermissionNetwork 访问网络上的服务
+	PermissionNetwork Permission = "network"
+)
+
+// Env 是创建工具所需的环境。可选的字段为 nil 时不提供依赖它的工具
+type Env struct {
+	Workspace *Workspace
+	Policy    CommandPolicy
+	// Container 不为 nil 时 shell 命令在容器中执行
+	Container *Container
+	// Repo 表示工作区是 git 仓库
+	Repo bool
+	// LSP 是代码智能工具使用的语言服务器
+	LSP *lsp.Client
+	// Index 是工作区的符号索引，为 nil 表示没有启用
+	Index *search.Index
+	// GitHub 和 GitLab 是访问代码托管平台的设置
+	GitHub *GitHub
+	GitLab *GitLab
+	// Jira 和 Linear 为 nil 表示没有配置
+	Jira   *Jira
+	Linear *Linear
+	// Storage 为 nil 表示没有配置可以读取的存储桶
+	Storage *Storage
+	// Kubernetes 和 Docker 为 nil 表示没有启用对应的工具
+	Kubernetes *Kubernetes
+	Docker     *Docker
+	// GoModule 表示工作区是 Go 模块
+	GoModule bool
+	// Projects 是工作区中识别出的项目，run_tests、build 和 format 工具使用它们
+	Projects []Project
+	// Embeddings 为 nil 表示没有配置向量模型
+	Embeddings Embedder
+	// Semantic 是工作区的向量索引，启用索引并配置了向量模型时才有
+	Semantic *search.Embeddings
+}
+
+// Spec 描述一个注册的工具
+type Spec struct {
+	Name        string
+	Category    Category
+	ReadOnly    bool
+	Permissions []Permission
+	// New 在 env 中创建工具，返回 false 表示这个环境不提供它。
+	// 不提供时也返回工具定义，供列出工具时显示说明
+	New func(env Env) (ToolDefinition, bool)
+}
+
+// Registry 保存注册的工具。工具在 init 中注册，使用方按环境取得快照
+type Registry struct {
+	mu    sync.Mutex
+	specs []Spec
+}
+
+// Default 是内置工具注册的 Registry
+var Default = &Registry{}
+
+// Register 把工具注册到 Default
+func Register(spec Spec) {
+	Default.Register(spec)
+}
+
+// Register 注册工具，名字重复时 panic
+func (r *Registry) Register(spec Spec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.specs {
+		if existing.Name == spec.Name {
+			panic(fmt.Sprintf("tools: tool %s registered twice", spec.Name))
+		}
+	}
+	r.specs = append(r.specs, spec)
+}
+
+// Specs 返回注册的全部工具，按分类排序
+func (r *Registry) Specs() []Spec {
+	r.mu.Lock()
+	specs := append([]Spec{}, r.specs...)
+	r.mu.Unlock()
+	rank := func(c Category) int {
+		for i, category := range categoryOrder {
+			if category == c {
+				return i
+			}
+		}
+		return len(categoryOrder)
+	}
+	sort.SliceStable(specs, func(i, j int) bool {
+		return rank(specs[i].Category) < rank(specs[j].Category)
+	})
+	return specs
+}
+
+// Lookup 返回名为 name 的工具
+func (r *Registry) Lookup(name string) (Spec, bool) {
+	for _, spec := range r.Specs() {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// Snapshot 创建 env 中提供的全部工具，工具带有注册时的元数据。
+// 返回的切片不会再改变，可以在多轮推理之间共享；环境变化时重新取得快照
+func (r *Registry) Snapshot(env Env) []ToolDefinition {
+	defs := []ToolDefinition{}
+	for _, spec := range r.Specs() {
+		def, ok := spec.New(env)
+		if !ok {
+			continue
+		}
+		def.ReadOnly = spec.ReadOnly
+		def.Category = spec.Category
+		def.Permissions = spec.Permissions
+		defs = append(defs, def)
+	}
+	return defs
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"testing"
+
+	"agent/lsp"
+	"agent/search"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := &Registry{}
+	define := func(name string) func(Env) (ToolDefinition, bool) {
+		return func(env Env) (ToolDefinition, bool) {
+			return ToolDefinition{Name: name, Description: name + " tool"}, env.Repo
+		}
+	}
+	r.Register(Spec{Name: "push", Category: CategoryIntegrations, Permissions: []Permission{PermissionNetwork}, New: define("push")})
+	r.Register(Spec{Name: "cat", Category: CategoryFiles, ReadOnly: true, Permissions: []Permission{PermissionRead}, New: define("cat")})
+	r.Register(Spec{Name: "ls", Category: CategoryFiles, ReadOnly: true, New: define("ls")})
+
+	t.Run("按分类排序，同一分类内按注册顺序", func(t *testing.T) {
+		var names []string
+		for _, spec := range r.Specs() {
+			names = append(names, spec.Name)
+		}
+		assert.Equal(t, []string{"cat", "ls", "push"}, names)
+	})
+
+	t.Run("快照只包含环境中提供的工具并带有元数据", func(t *testing.T) {
+		assert.Empty(t, r.Snapshot(Env{}))
+		defs := r.Snapshot(Env{Repo: true})
+		require.Len(t, defs, 3)
+		assert.True(t, defs[0].ReadOnly)
+		assert.Equal(t, CategoryFiles, defs[0].Category)
+		assert.Equal(t, []Permission{PermissionNetwork}, defs[2].Permissions)
+	})
+
+	t.Run("重复注册时 panic", func(t *testing.T) {
+		assert.Panics(t, func() { r.Register(Spec{Name: "cat", New: define("cat")}) })
+	})
+
+	t.Run("查找", func(t *testing.T) {
+		spec, ok := r.Lookup("push")
+		require.True(t, ok)
+		assert.Equal(t, CategoryIntegrations, spec.Category)
+		_, ok = r.Lookup("rm")
+		assert.False(t, ok)
+	})
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	w := &Workspace{Root: t.TempDir()}
+	names := func(defs []ToolDefinition) []string {
+		var names []string
+		for _, def := range defs {
+			names = append(names, def.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"read_file", "edit_file", "grep", "glob", "shell"}, names(Default.Snapshot(Env{Workspace: w})))
+
+	env := Env{Workspace: w, Repo: true, LSP: &lsp.Client{}, Index: &search.Index{}, Semantic: &search.Embeddings{}, GitHub: &GitHub{}, GitLab: &GitLab{}, Linear: &Linear{}}
+	assert.Equal(t, []string{
+		"read_file", "edit_file", "grep", "glob", "semantic_search", "find_symbol", "shell",
+		"go_to_definition", "find_references", "rename_symbol", "ci_logs", "github", "fetch_issue", "tracker",
+	}, names(Default.Snapshot(env)))
+
+	for _, spec := range Default.Specs() {
+		def, _ := spec.New(env)
+		assert.Equal(t, spec.Name, def.Name, "注册的名字与工具定义一致")
+		assert.Equal(t, def.ReadOnly, spec.ReadOnly, "%s 的只读标记与工具定义一致", spec.Name)
+		assert.NotEmpty(t, spec.Permissions, spec.Name)
+	}
+}
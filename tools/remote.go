@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/search"
+)
+
+// remoteFileTimeout 是读写或列出远程文件的最长时间
+const remoteFileTimeout = 30 * time.Second
+
+// remoteMissing 是远程脚本报告文件不存在时的退出码
+const remoteMissing = 3
+
+// Remote 描述通过 SSH 访问的远程工作区。命令用系统的 ssh 执行，因此 ~/.ssh/config、
+// ssh-agent 和 known_hosts 照常生效；文件通过远程的 cat、mkdir 和 find 读写和列出，
+// 远程机器只需要 POSIX shell
+type Remote struct {
+	// Host 是 ssh 的目标，例如 user@devbox
+	Host string
+	// Root 是远程的工作区目录，留空时为登录用户的主目录
+	Root string
+	// Port 和 Identity 为 0 或空时使用 ssh 的默认设置
+	Port     int
+	Identity string
+	// SSH 是 ssh 命令，留空时为 ssh
+	SSH string
+}
+
+// String 返回 host:root 形式的位置
+func (r *Remote) String() string {
+	if r.Root == "" {
+		return r.Host
+	}
+	return r.Host + ":" + r.Root
+}
+
+// remoteRootName 描述远程的工作区目录
+func remoteRootName(r *Remote) string {
+	if r.Root == "" {
+		return "the login directory"
+	}
+	return r.Root
+}
+
+// args 返回在远程工作区中用 sh 执行 script 的 ssh 命令行。BatchMode 让 ssh
+// 在需要输入密码时直接失败，而不是等待终端输入
+func (r *Remote) args(script string) []string {
+	ssh := r.SSH
+	if ssh == "" {
+		ssh = "ssh"
+	}
+	args := []string{ssh, "-o", "BatchMode=yes"}
+	if r.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(r.Port))
+	}
+	if r.Identity != "" {
+		args = append(args, "-i", r.Identity)
+	}
+	if r.Root != "" {
+		script = "cd " + shellQuote(r.Root) + " && " + script
+	}
+	return append(args, r.Host, "--", "sh -c "+shellQuote(script))
+}
+
+// run 在远程工作区中执行 script，stdin 为 nil 时不提供输入
+func (r *Remote) run(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+	args := r.args(script)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	killOnCancel(cmd)
+	var stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s did not answer within %s", r.Host, remoteFileTimeout)
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == remoteMissing {
+		return fs.ErrNotExist
+	}
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+// ReadFile 读取远程工作区中的文件，文件不存在时返回 fs.ErrNotExist
+func (r *Remote) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteFileTimeout)
+	defer cancel()
+	q := shellQuote(name)
+	var out bytes.Buffer
+	err := r.run(ctx, fmt.Sprintf("[ -e %s ] || exit %d; cat -- %s", q, remoteMissing, q), nil, &out)
+	return out.Bytes(), err
+}
+
+// WriteFile 写入远程工作区中的文件，需要时创建上级目录。已有文件保留原来的权限
+func (r *Remote) WriteFile(ctx context.Context, name string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, remoteFileTimeout)
+	defer cancel()
+	script := fmt.Sprintf("mkdir -p -- %s && cat > %s", shellQuote(path.Dir(name)), shellQuote(name))
+	return r.run(ctx, script, bytes.NewReader(data), io.Discard)
+}
+
+// List 返回远程工作区的 dir 下的文件，路径相对工作区、以 / 分隔并排序。
+// 在 git 仓库中跳过 .gitignore 忽略的文件，否则只跳过 .git 目录
+func (r *Remote) List(ctx context.Context, dir string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteFileTimeout)
+	defer cancel()
+	q := shellQuote(dir)
+	script := fmt.Sprintf("git ls-files -co --exclude-standard -- %s 2>/dev/null || find %s -name .git -prune -o -type f -print", q, q)
+	var out bytes.Buffer
+	if err := r.run(ctx, script, nil, &out); err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimPrefix(line, "./"); line != "" {
+			files = append(files, line)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// remotePath 检查 name 是相对工作区、没有用 ../ 跳出工作区的路径，返回清理后以 / 分隔的路径。
+// 远程的符号链接无法在本地解析，不做检查
+func remotePath(name string) (string, error) {
+	if name == "" {
+		return "", invalidInput("path must not be empty")
+	}
+	if strings.ContainsRune(name, 0) {
+		return "", invalidInput("path %q contains a NUL byte", name)
+	}
+	slashed := filepath.ToSlash(name)
+	if path.IsAbs(slashed) || filepath.VolumeName(name) != "" {
+		return "", invalidInput("path %s must be relative to the workspace", name)
+	}
+	clean := path.Clean(slashed)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", invalidInput("path %s is outside the workspace", name)
+	}
+	return clean, nil
+}
+
+// shellQuote 用单引号括起 s，使 POSIX shell 把它当作一个词
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// remoteReadFile 实现远程工作区的 ReadFile
+func (w *Workspace) remoteReadFile(ctx context.Context, name string) (string, error) {
+	rel, err := remotePath(name)
+	if err != nil {
+		return "", err
+	}
+	content, err := w.Remote.ReadFile(ctx, rel)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", name, err)
+	}
+	if err := checkSize(name, int64(len(content)), w.MaxReadBytes, "read"); err != nil {
+		return "", fmt.Errorf("%w; use the shell tool to read part of it, e.g. with head or grep", err)
+	}
+	return string(content), nil
+}
+
+// remoteEditFile 实现远程工作区的 EditFile，同时返回修改前后的内容
+func (w *Workspace) remoteEditFile(ctx context.Context, params EditFileInput) (text, before, after string, err error) {
+	rel, err := remotePath(params.Path)
+	if err != nil {
+		return "", "", "", err
+	}
+	if params.OldStr == params.NewStr {
+		return "", "", "", invalidInput("old_str and new_str must be different")
+	}
+	content, err := w.Remote.ReadFile(ctx, rel)
+	if errors.Is(err, fs.ErrNotExist) && params.OldStr == "" {
+		if err := checkSize(params.Path, int64(len(params.NewStr)), w.MaxWriteBytes, "write"); err != nil {
+			return "", "", "", err
+		}
+		if err := w.Remote.WriteFile(ctx, rel, []byte(params.NewStr)); err != nil {
+			return "", "", "", fmt.Errorf("failed to create file %s: %w", params.Path, err)
+		}
+		return fmt.Sprintf("Created %s", params.Path), "", params.NewStr, nil
+	}
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to read file %s: %w", params.Path, err)
+	}
+	if err := checkSize(params.Path, int64(len(content)), w.MaxWriteBytes, "write"); err != nil {
+		return "", "", "", err
+	}
+	if params.OldStr == "" {
+		return "", "", "", invalidInput("file %s already exists; old_str must not be empty", params.Path)
+	}
+	before = string(content)
+	count := strings.Count(before, params.OldStr)
+	if count == 0 {
+		return "", "", "", invalidInput("old_str not found in %s", params.Path)
+	}
+	if count > 1 {
+		return "", "", "", invalidInput("old_str appears %d times in %s; include more context to make it unique", count, params.Path)
+	}
+	after = strings.Replace(before, params.OldStr, params.NewStr, 1)
+	if err := checkSize(params.Path, int64(len(after)), w.MaxWriteBytes, "write"); err != nil {
+		return "", "", "", fmt.Errorf("edited %w", err)
+	}
+	if err := w.Remote.WriteFile(ctx, rel, []byte(after)); err != nil {
+		return "", "", "", fmt.Errorf("failed to write file %s: %w", params.Path, err)
+	}
+	return "OK", before, after, nil
+}
+
+// remoteGlob 在远程工作区的 dir 下查找匹配 pattern 的文件，dir 已由 searchDir 检查
+func (w *Workspace) remoteGlob(ctx context.Context, dir string, pattern *search.Pattern) (files []string, truncated bool, err error) {
+	all, err := w.Remote.List(ctx, dir)
+	if err != nil {
+		return nil, false, fmt.Errorf("listing files on %s: %w", w.Remote.Host, err)
+	}
+	for _, file := range all {
+		if pattern.Match(file) {
+			files = append(files, file)
+		}
+	}
+	if len(files) > maxSearchResults {
+		files, truncated = files[:maxSearchResults], true
+	}
+	return files, truncated, nil
+}
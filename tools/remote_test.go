@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSH 返回一个代替 ssh 的脚本：忽略选项和主机，在本机执行远程命令
+func fakeSSH(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	path := filepath.Join(t.TempDir(), "ssh")
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nexec sh -c \"$*\"\n"
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestRemoteArgs(t *testing.T) {
+	r := &Remote{Host: "me@devbox", Root: "src/it's", Port: 2222, Identity: "~/.ssh/dev"}
+	assert.Equal(t, []string{"ssh", "-o", "BatchMode=yes", "-p", "2222", "-i", "~/.ssh/dev", "me@devbox", "--",
+		`sh -c 'cd '\''src/it'\''\'\'''\''s'\'' && go test ./...'`}, r.args("go test ./..."))
+	assert.Equal(t, "me@devbox:src/it's", r.String())
+}
+
+func TestRemoteWorkspace(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644))
+	w := &Workspace{Root: t.TempDir(), Remote: &Remote{Host: "devbox", Root: root, SSH: fakeSSH(t)}}
+
+	t.Run("读取远程文件", func(t *testing.T) {
+		content, err := w.ReadFile(context.Background(), json.RawMessage(`{"path":"main.go"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "package main\n", content)
+
+		_, err = w.ReadFile(context.Background(), json.RawMessage(`{"path":"missing.go"}`))
+		assert.Equal(t, ErrorNotFound, KindOf(err))
+	})
+
+	t.Run("编辑和创建远程文件", func(t *testing.T) {
+		result := EditFileTool(w).Function(context.Background(), json.RawMessage(`{"path":"main.go","old_str":"package main","new_str":"package app"}`))
+		require.False(t, result.IsError, result.Text)
+		assert.Contains(t, result.Diff, "+package app")
+		content, err := os.ReadFile(filepath.Join(root, "main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "package app\n", string(content))
+
+		text, err := w.EditFile(context.Background(), json.RawMessage(`{"path":"cmd/app/app.go","old_str":"","new_str":"package app\n"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "Created cmd/app/app.go", text)
+		assert.FileExists(t, filepath.Join(root, "cmd", "app", "app.go"))
+	})
+
+	t.Run("列出远程文件", func(t *testing.T) {
+		text, err := w.Glob(context.Background(), json.RawMessage(`{"pattern":"*.go"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "cmd/app/app.go\nmain.go\n", text)
+
+		text, err = w.Glob(context.Background(), json.RawMessage(`{"pattern":"*.go","path":"cmd"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "cmd/app/app.go\n", text)
+	})
+
+	t.Run("命令在远程工作区中执行", func(t *testing.T) {
+		output, err := w.Shell(context.Background(), CommandPolicy{}, nil, json.RawMessage(`{"command":"pwd && ls"}`))
+		require.NoError(t, err)
+		real, err := filepath.EvalSymlinks(root)
+		require.NoError(t, err)
+		assert.Contains(t, output, "cmd\nmain.go\n")
+		assert.Contains(t, []string{root, real}, output[:len(output)-len("cmd\nmain.go\n")-1])
+	})
+
+	t.Run("拒绝工作区之外的路径", func(t *testing.T) {
+		for _, path := range []string{"/etc/passwd", "../secret", "a/../../secret", ""} {
+			_, err := w.ReadFile(context.Background(), json.RawMessage(`{"path":"`+path+`"}`))
+			assert.Equal(t, ErrorInvalidInput, KindOf(err), path)
+		}
+	})
+
+	t.Run("远程工作区不提供 grep", func(t *testing.T) {
+		names := []string{}
+		for _, tool := range Default.Snapshot(Env{Workspace: w}) {
+			names = append(names, tool.Name)
+		}
+		assert.NotContains(t, names, "grep")
+		assert.Contains(t, names, "glob")
+	})
+}
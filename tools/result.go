@@ -0,0 +1,57 @@
+package tools
+
+import "errors"
+
+// ToolResult 是工具执行的结构化结果
+type ToolResult struct {
+	// Text 是发送给模型的内容，失败时是错误说明
+	Text string `json:"text"`
+	// Diff 是工具修改文件时的统一 diff
+	Diff string `json:"diff,omitempty"`
+	// Files 是结果引用的文件位置
+	Files []FileRef `json:"files,omitempty"`
+	// IsError 表示工具执行失败
+	IsError bool `json:"is_error,omitempty"`
+	// Metadata 是给前端和日志的附加信息，不发送给模型
+	Metadata map[string]any `json:"metadata,omitempty"`
+	// err 是失败的原因，供调用方用 errors.Is 判断
+	err error
+}
+
+// FileRef 是结果引用的文件，Line 为 0 表示整个文件
+type FileRef struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+}
+
+// Result 把 (text, err) 形式的返回值转换为 ToolResult
+func Result(text string, err error) ToolResult {
+	if err != nil {
+		return ErrorResult(err)
+	}
+	return ToolResult{Text: text}
+}
+
+// ErrorResult 返回以 err 失败的结果
+func ErrorResult(err error) ToolResult {
+	return ToolResult{Text: err.Error(), IsError: true, err: err}
+}
+
+// Err 返回失败的原因，成功时返回 nil
+func (r ToolResult) Err() error {
+	if !r.IsError {
+		return nil
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return errors.New(r.Text)
+}
+
+// Output 以 (text, err) 的形式返回结果，供只需要文本的调用方使用
+func (r ToolResult) Output() (string, error) {
+	if r.IsError {
+		return "", r.Err()
+	}
+	return r.Text, nil
+}
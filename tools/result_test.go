@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolResult(t *testing.T) {
+	t.Run("成功的结果", func(t *testing.T) {
+		r := Result("text", nil)
+		assert.False(t, r.IsError)
+		require.NoError(t, r.Err())
+		out, err := r.Output()
+		require.NoError(t, err)
+		assert.Equal(t, "text", out)
+	})
+
+	t.Run("失败的结果保留原来的错误", func(t *testing.T) {
+		r := Result("ignored", fmt.Errorf("command %w: rm", ErrDenied))
+		assert.True(t, r.IsError)
+		assert.Equal(t, "command denied: rm", r.Text)
+		assert.ErrorIs(t, r.Err(), ErrDenied)
+		out, err := r.Output()
+		assert.Empty(t, out)
+		assert.ErrorIs(t, err, ErrDenied)
+	})
+
+	t.Run("直接构造的失败结果以文本作为错误", func(t *testing.T) {
+		r := ToolResult{Text: "not found", IsError: true}
+		assert.Equal(t, errors.New("not found"), r.Err())
+	})
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"agent/script"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// ScriptTool 返回在工作区 w 中执行脚本工具的定义。脚本只能读取工作区中的文件，
+// 因此工具是只读的；脚本中 print 的输出作为工具的实时输出
+func ScriptTool(w *Workspace, t script.Tool) ToolDefinition {
+	return ToolDefinition{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: anthropic.ToolInputSchemaParam{Properties: t.Properties, Required: t.Required},
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(t.Call(w.ScriptEnv(nil), input))
+		},
+		Stream: func(ctx context.Context, input json.RawMessage, output io.Writer) ToolResult {
+			return Result(t.Call(w.ScriptEnv(output), input))
+		},
+		ReadOnly:    true,
+		Category:    CategoryCustom,
+		Permissions: []Permission{PermissionRead},
+	}
+}
+
+// ScriptEnv 返回脚本在工作区 w 中执行的环境，print 的输出逐行写入 output，nil 时丢弃
+func (w *Workspace) ScriptEnv(output io.Writer) script.Env {
+	env := script.Env{
+		ReadFile: func(name string) (string, error) {
+			path, err := w.Resolve(name)
+			if err != nil {
+				return "", err
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read file %s: %w", name, err)
+			}
+			if err := checkSize(name, info.Size(), w.MaxReadBytes, "read"); err != nil {
+				return "", err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read file %s: %w", name, err)
+			}
+			return string(content), nil
+		},
+	}
+	if output != nil {
+		env.Print = func(msg string) { fmt.Fprintln(output, msg) }
+	}
+	return env
+}
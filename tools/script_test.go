@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/script"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptTool(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("a\nb\nc\n"), 0644))
+	w := &Workspace{Root: root, MaxReadBytes: 100}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lines.star"), []byte(`
+def count_lines(args):
+    print("counting", args["path"])
+    return str(len(read_file(args["path"]).splitlines()))
+
+tool(name = "count_lines", description = "Count lines", params = {"path": "string"}, required = ["path"], fn = count_lines)
+`), 0644))
+	set, err := (&script.Loader{Dir: dir}).Load()
+	require.NoError(t, err)
+	tool := ScriptTool(w, set.Tools[0])
+
+	assert.Equal(t, "count_lines", tool.Name)
+	assert.True(t, tool.ReadOnly)
+	assert.Equal(t, CategoryCustom, tool.Category)
+	assert.Equal(t, []string{"path"}, tool.InputSchema.Required)
+
+	t.Run("读取工作区中的文件", func(t *testing.T) {
+		out, err := tool.Function(context.Background(), json.RawMessage(`{"path": "notes.txt"}`)).Output()
+		require.NoError(t, err)
+		assert.Equal(t, "3", out)
+	})
+
+	t.Run("print 的输出实时写出", func(t *testing.T) {
+		var output bytes.Buffer
+		out, err := tool.Stream(context.Background(), json.RawMessage(`{"path": "notes.txt"}`), &output).Output()
+		require.NoError(t, err)
+		assert.Equal(t, "3", out)
+		assert.Equal(t, "counting notes.txt\n", output.String())
+	})
+
+	t.Run("不能读取工作区之外的文件", func(t *testing.T) {
+		_, err := tool.Function(context.Background(), json.RawMessage(`{"path": "../secret"}`)).Output()
+		assert.ErrorContains(t, err, "outside the workspace")
+	})
+
+	t.Run("遵守读取大小的限制", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "big.txt"), bytes.Repeat([]byte("x\n"), 100), 0644))
+		_, err := tool.Function(context.Background(), json.RawMessage(`{"path": "big.txt"}`)).Output()
+		assert.ErrorContains(t, err, "big.txt")
+	})
+}
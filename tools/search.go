@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"agent/search"
+)
+
+// maxSearchResults 限制 grep 和 glob 返回的结果数，maxMatchLine 限制每行匹配显示的字符数
+const (
+	maxSearchResults = 200
+	maxMatchLine     = 300
+)
+
+func init() {
+	Register(Spec{Name: "grep", Category: CategorySearch, ReadOnly: true, Permissions: []Permission{PermissionRead},
+		// 远程工作区中没有 grep 工具，模型可以在 shell 中执行 grep
+		New: func(env Env) (ToolDefinition, bool) {
+			return GrepTool(env.Workspace), env.Workspace == nil || env.Workspace.Remote == nil
+		}})
+	Register(Spec{Name: "glob", Category: CategorySearch, ReadOnly: true, Permissions: []Permission{PermissionRead},
+		New: func(env Env) (ToolDefinition, bool) { return GlobTool(env.Workspace), true }})
+}
+
+// GrepInput 定义按内容搜索工具的输入参数
+type GrepInput struct {
+	Pattern string `json:"pattern" jsonschema_description:"Regular expression to search for (Go RE2 syntax), e.g. func\\s+New or (?i)todo."`
+	Path    string `json:"path,omitempty" jsonschema_description:"Optional relative directory to search in. Defaults to the whole workspace."`
+	Include string `json:"include,omitempty" jsonschema_description:"Optional glob limiting which files are searched, e.g. *.go or internal/**/*.{ts,tsx}."`
+}
+
+// GlobInput 定义按文件名查找工具的输入参数
+type GlobInput struct {
+	Pattern string `json:"pattern" jsonschema_description:"Glob matched against file paths relative to the workspace, e.g. **/*_test.go or cmd/*/main.go. A pattern without / matches file names in any directory."`
+	Path    string `json:"path,omitempty" jsonschema_description:"Optional relative directory to search in. Defaults to the whole workspace."`
+}
+
+// searchDir 把可选的 path 参数解析为相对工作区根目录的子目录。远程工作区没有本地的 root
+func (w *Workspace) searchDir(path string) (root, dir string, err error) {
+	if w.Remote != nil {
+		if path == "" {
+			return "", ".", nil
+		}
+		dir, err = remotePath(path)
+		return "", dir, err
+	}
+	root, err = filepath.Abs(w.Root)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve workspace root: %w", err)
+	}
+	if path == "" || path == "." {
+		return root, "", nil
+	}
+	resolved, err := w.Resolve(path)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err = filepath.Rel(root, resolved)
+	return root, dir, err
+}
+
+// Grep 在工作区中按正则表达式搜索文件内容
+func (w *Workspace) Grep(ctx context.Context, input json.RawMessage) (string, error) {
+	return w.grep(ctx, input).Output()
+}
+
+// grep 执行 Grep，结果引用每一行匹配
+func (w *Workspace) grep(ctx context.Context, input json.RawMessage) ToolResult {
+	var params GrepInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return ErrorResult(fmt.Errorf("failed to parse input: %w", err))
+	}
+	if w.Remote != nil {
+		return ErrorResult(fmt.Errorf("grep is not available in a remote workspace; run grep with the shell tool"))
+	}
+	root, dir, err := w.searchDir(params.Path)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	if params.Pattern == "" {
+		return ErrorResult(invalidInput("pattern must not be empty"))
+	}
+	re, err := regexp.Compile(params.Pattern)
+	if err != nil {
+		return ErrorResult(invalidInput("invalid pattern: %w", err))
+	}
+	opts := search.Options{Dir: dir, MaxResults: maxSearchResults}
+	if params.Include != "" {
+		if opts.Include, err = search.CompilePattern(params.Include); err != nil {
+			return ErrorResult(invalidInput("invalid include glob: %w", err))
+		}
+	}
+
+	matches, truncated, err := search.Grep(ctx, root, re, opts)
+	if err != nil {
+		return ErrorResult(fmt.Errorf("failed to search: %w", err))
+	}
+	result := ToolResult{Metadata: map[string]any{"matches": len(matches), "truncated": truncated}}
+	if len(matches) == 0 {
+		result.Text = "No matches found."
+		return result
+	}
+	var b strings.Builder
+	for _, m := range matches {
+		text := m.Text
+		if len(text) > maxMatchLine {
+			text = text[:maxMatchLine] + "…"
+		}
+		fmt.Fprintf(&b, "%s:%d: %s\n", m.Path, m.Line, text)
+		result.Files = append(result.Files, FileRef{Path: m.Path, Line: m.Line})
+	}
+	if truncated {
+		fmt.Fprintf(&b, "(showing the first %d matches; narrow the pattern, path or include glob to see the rest)\n", maxSearchResults)
+	}
+	result.Text = b.String()
+	return result
+}
+
+// Glob 在工作区中按 glob 查找文件
+func (w *Workspace) Glob(ctx context.Context, input json.RawMessage) (string, error) {
+	return w.glob(ctx, input).Output()
+}
+
+// glob 执行 Glob，结果引用找到的文件
+func (w *Workspace) glob(ctx context.Context, input json.RawMessage) ToolResult {
+	var params GlobInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return ErrorResult(fmt.Errorf("failed to parse input: %w", err))
+	}
+	root, dir, err := w.searchDir(params.Path)
+	if err != nil {
+		return ErrorResult(err)
+	}
+	if params.Pattern == "" {
+		return ErrorResult(invalidInput("pattern must not be empty"))
+	}
+	pattern, err := search.CompilePattern(params.Pattern)
+	if err != nil {
+		return ErrorResult(invalidInput("invalid pattern: %w", err))
+	}
+
+	var files []string
+	var truncated bool
+	if w.Remote != nil {
+		files, truncated, err = w.remoteGlob(ctx, dir, pattern)
+	} else {
+		files, truncated, err = search.Glob(ctx, root, pattern, search.Options{Dir: dir, MaxResults: maxSearchResults})
+	}
+	if err != nil {
+		return ErrorResult(fmt.Errorf("failed to search: %w", err))
+	}
+	result := ToolResult{Metadata: map[string]any{"files": len(files), "truncated": truncated}}
+	if len(files) == 0 {
+		result.Text = "No files found."
+		return result
+	}
+	for _, file := range files {
+		result.Files = append(result.Files, FileRef{Path: file})
+	}
+	result.Text = strings.Join(files, "\n") + "\n"
+	if truncated {
+		result.Text += fmt.Sprintf("(showing the first %d files; narrow the pattern or path to see the rest)\n", maxSearchResults)
+	}
+	return result
+}
+
+// GrepTool 返回在工作区 w 中按内容搜索的工具定义
+func GrepTool(w *Workspace) ToolDefinition {
+	return ToolDefinition{
+		Name:        "grep",
+		Description: "Search file contents in the workspace with a regular expression and list matching lines as path:line: text. Files ignored by .gitignore, the .git directory and binary files are skipped. Prefer this over running grep in the shell.",
+		InputSchema: GenerateSchema[GrepInput](),
+		Function:    w.grep,
+		ReadOnly:    true,
+	}
+}
+
+// GlobTool 返回在工作区 w 中按文件名查找的工具定义
+func GlobTool(w *Workspace) ToolDefinition {
+	return ToolDefinition{
+		Name:        "glob",
+		Description: "Find files in the workspace whose paths match a glob pattern. Files ignored by .gitignore and the .git directory are skipped. Prefer this over running find or ls -R in the shell.",
+		InputSchema: GenerateSchema[GlobInput](),
+		Function:    w.glob,
+		ReadOnly:    true,
+	}
+}
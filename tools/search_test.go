@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchTools(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cmd"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.out\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package main\n\n// TODO: flags\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes.md"), []byte("todo list\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "run.out"), []byte("TODO\n"), 0644))
+	w := &Workspace{Root: root}
+
+	t.Run("grep 返回路径、行号和内容", func(t *testing.T) {
+		out, err := w.Grep(context.Background(), json.RawMessage(`{"pattern": "(?i)todo"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "cmd/main.go:3: // TODO: flags\nnotes.md:1: todo list\n", out)
+
+		out, err = w.Grep(context.Background(), json.RawMessage(`{"pattern": "TODO", "include": "*.md"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "No matches found.", out)
+	})
+
+	t.Run("grep 拒绝无效的正则", func(t *testing.T) {
+		_, err := w.Grep(context.Background(), json.RawMessage(`{"pattern": "func("}`))
+		assert.ErrorContains(t, err, "invalid pattern")
+	})
+
+	t.Run("glob 列出匹配的文件", func(t *testing.T) {
+		out, err := w.Glob(context.Background(), json.RawMessage(`{"pattern": "**/*.{go,md}"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "cmd/main.go\nnotes.md\n", out)
+
+		out, err = w.Glob(context.Background(), json.RawMessage(`{"pattern": "*", "path": "cmd"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "cmd/main.go\n", out)
+	})
+
+	t.Run("结果过多时截断并提示", func(t *testing.T) {
+		var b strings.Builder
+		for i := 0; i < maxSearchResults+10; i++ {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(root, "many.txt"), []byte(b.String()), 0644))
+		out, err := w.Grep(context.Background(), json.RawMessage(`{"pattern": "^line", "include": "many.txt"}`))
+		require.NoError(t, err)
+		assert.Contains(t, out, fmt.Sprintf("showing the first %d matches", maxSearchResults))
+	})
+}
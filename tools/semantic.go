@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agent/search"
+)
+
+// maxSemanticResults 限制 semantic_search 一次返回的段数
+const maxSemanticResults = 20
+
+// SemanticSearchInput 定义语义检索工具的输入参数
+type SemanticSearchInput struct {
+	Query string `json:"query" jsonschema_description:"What you are looking for, described in words, e.g. where API keys are read from the keychain."`
+	Top   int    `json:"top,omitempty" jsonschema_description:"How many passages to return, 5 by default and at most 20."`
+}
+
+func init() {
+	Register(Spec{Name: "semantic_search", Category: CategorySearch, ReadOnly: true, Permissions: []Permission{PermissionRead, PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) { return SemanticSearchTool(env.Semantic), env.Semantic != nil }})
+}
+
+// SemanticSearchTool 返回按语义在工作区中检索文件内容的工具定义
+func SemanticSearchTool(e *search.Embeddings) ToolDefinition {
+	return ToolDefinition{
+		Name:        "semantic_search",
+		Description: "Find the parts of the workspace's files that are about something, by meaning rather than exact words, using the configured embedding model. Returns the most similar passages as path:start-end with their text. Use grep when you know the exact identifier or string.",
+		InputSchema: GenerateSchema[SemanticSearchInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return semanticSearch(ctx, e, input)
+		},
+		ReadOnly: true,
+	}
+}
+
+func semanticSearch(ctx context.Context, e *search.Embeddings, input json.RawMessage) ToolResult {
+	var params SemanticSearchInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return ErrorResult(fmt.Errorf("failed to parse input: %w", err))
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return ErrorResult(fmt.Errorf("query must not be empty"))
+	}
+	top := params.Top
+	if top <= 0 {
+		top = 5
+	}
+	top = min(top, maxSemanticResults)
+
+	ctx, cancel := context.WithTimeout(ctx, embedTimeout)
+	defer cancel()
+	passages, err := e.Search(ctx, params.Query, top)
+	if err != nil {
+		return ErrorResult(fmt.Errorf("failed to search: %w", err))
+	}
+	if len(passages) == 0 {
+		return ToolResult{Text: "No text files to search."}
+	}
+	var result ToolResult
+	var b strings.Builder
+	for _, p := range passages {
+		fmt.Fprintf(&b, "%s:%d-%d (%.3f)\n%s\n\n", p.Path, p.Start, p.End, p.Score, p.Text)
+		result.Files = append(result.Files, FileRef{Path: p.Path, Line: p.Start})
+	}
+	result.Text = strings.TrimSuffix(b.String(), "\n")
+	return result
+}
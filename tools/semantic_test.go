@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/search"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemanticSearchTool(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "config.go"), []byte("package main\n\n// parseConfig reads the config file\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "server.go"), []byte("package main\n\n// serve answers http requests\n"), 0644))
+	index, err := search.NewIndex(context.Background(), root)
+	require.NoError(t, err)
+	run := func(e search.Embedder, input string) ToolResult {
+		return SemanticSearchTool(search.NewEmbeddings(index, e)).Function(context.Background(), json.RawMessage(input))
+	}
+
+	t.Run("返回最相近的段落", func(t *testing.T) {
+		result := run(&wordEmbedder{}, `{"query": "http", "top": 1}`)
+		require.False(t, result.IsError, result.Text)
+		assert.Equal(t, "server.go:1-3 (1.000)\npackage main\n\n// serve answers http requests\n", result.Text)
+		assert.Equal(t, []FileRef{{Path: "server.go", Line: 1}}, result.Files)
+	})
+
+	t.Run("错误", func(t *testing.T) {
+		assert.True(t, run(&wordEmbedder{}, `{"query": " "}`).IsError, "查询不能为空")
+		assert.Contains(t, run(failingEmbedder{}, `{"query": "x"}`).Text, "401 Unauthorized")
+	})
+}
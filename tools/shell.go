@@ -0,0 +1,350 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// shellTimeout 是单条命令的最长执行时间
+const shellTimeout = 2 * time.Minute
+
+// DefaultDeniedCommands 是无论配置如何都禁止执行的危险命令
+var DefaultDeniedCommands = []string{
+	"rm -rf /",
+	"rm -rf /*",
+	"rm -rf ~",
+	"rm -rf ~/*",
+	"rm -fr /",
+	"curl * | sh",
+	"curl * | bash",
+	"wget * | sh",
+	"wget * | bash",
+	"git push",
+	"mkfs*",
+	"dd * of=/dev/*",
+	"sudo",
+	":(){ :|:& };:",
+	// Windows 上等价的命令
+	"rd /s /q *:\\",
+	"rmdir /s /q *:\\",
+	"del /s /q *:\\*",
+	"format *:",
+}
+
+// CommandPolicy 决定 shell 工具可以执行哪些命令，Deny 优先于 Allow，
+// 除了用户亲自输入的命令，DefaultDeniedCommands 始终生效
+type CommandPolicy struct {
+	Allow []string
+	Deny  []string
+	// UserTyped 表示命令由用户亲自输入而不是模型生成，只检查 Deny 和 Allow
+	UserTyped bool
+}
+
+// Check 检查命令是否允许执行，不允许时返回说明原因的错误。
+// 命令按 shell 语法解析，其中的每一条简单命令都要通过检查，包括管道、后台命令、子 shell、
+// 命令替换和 sh -c 执行的脚本，见 parseCommand。无法解析的命令（例如 cmd 的语法）
+// 按 ;、&&、|| 和换行拆分后检查拒绝列表；配置了允许列表时不执行无法解析的命令
+func (p CommandPolicy) Check(command string) error {
+	segments := splitCommand(command)
+	if len(segments) == 0 {
+		return fmt.Errorf("command must not be empty")
+	}
+	parsed, err := parseCommand(command, len(p.Allow) > 0)
+	if err != nil {
+		if len(p.Allow) > 0 {
+			return fmt.Errorf("command %w: %v", ErrDenied, err)
+		}
+		parsed = &parsedCommand{}
+	}
+	deny := p.Deny
+	if !p.UserTyped {
+		deny = append(append([]string{}, DefaultDeniedCommands...), p.Deny...)
+	}
+	checked := append(append(append(segments, normalizeCommand(command)), parsed.commands...), parsed.pipelines...)
+	for _, segment := range checked {
+		for _, pattern := range deny {
+			if matchCommand(pattern, segment) {
+				return fmt.Errorf("command %w: %q matches the deny pattern %q", ErrDenied, segment, pattern)
+			}
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, segment := range parsed.commands {
+		allowed := false
+		for _, pattern := range p.Allow {
+			allowed = allowed || matchCommand(pattern, segment)
+		}
+		if !allowed {
+			return fmt.Errorf("command %w: %q does not match any allowed pattern (%s)", ErrDenied, segment, strings.Join(p.Allow, ", "))
+		}
+	}
+	return nil
+}
+
+var (
+	commandSeparators = regexp.MustCompile(`\s*(;|&&|\|\||\n)\s*`)
+	pipeSeparator     = regexp.MustCompile(`\s*\|\s*`)
+	whitespace        = regexp.MustCompile(`\s+`)
+)
+
+// splitCommand 将命令拆分成规范化后的各段，管道仍属于同一段
+func splitCommand(command string) []string {
+	segments := []string{}
+	for _, segment := range commandSeparators.Split(command, -1) {
+		if segment = normalizeCommand(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// normalizeCommand 合并连续空白，并统一管道符两侧的空格
+func normalizeCommand(command string) string {
+	command = whitespace.ReplaceAllString(strings.TrimSpace(command), " ")
+	return pipeSeparator.ReplaceAllString(command, " | ")
+}
+
+// matchCommand 判断模式是否匹配命令开头的若干个完整的词，模式中的 * 匹配任意文本
+func matchCommand(pattern, command string) bool {
+	pattern = normalizeCommand(pattern)
+	if pattern == "" {
+		return false
+	}
+	expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`)
+	return regexp.MustCompile(`^` + expr + `(\s.*)?$`).MatchString(command)
+}
+
+// ShellInput 定义 shell 工具的输入参数
+type ShellInput struct {
+	Command string `json:"command" jsonschema_description:"The shell command to run in the workspace root."`
+}
+
+// HostShells 是可以在主机上执行命令的 shell
+var HostShells = []string{"sh", "bash", "cmd", "powershell", "pwsh"}
+
+// hostShell 返回在 goos 平台的主机上用 program 执行 command 的命令行。
+// program 为空时 Windows 上使用 cmd，其他平台使用 sh
+func hostShell(goos, program, command string) ([]string, error) {
+	switch program = shellProgram(goos, program); program {
+	case "sh", "bash":
+		return []string{program, "-c", command}, nil
+	case "cmd":
+		return []string{"cmd", "/C", command}, nil
+	case "powershell", "pwsh":
+		return []string{program, "-NoProfile", "-NonInteractive", "-Command", command}, nil
+	}
+	return nil, fmt.Errorf("unknown shell %q (use %s)", program, strings.Join(HostShells, ", "))
+}
+
+// shellProgram 返回 program，为空时返回 goos 平台默认使用的 shell
+func shellProgram(goos, program string) string {
+	switch {
+	case program != "":
+		return program
+	case goos == "windows":
+		return "cmd"
+	}
+	return "sh"
+}
+
+// Container 描述在 Docker 或 Podman 容器中执行 shell 命令的方式：
+// 工作区挂载到容器内的 /workspace，默认不联网
+type Container struct {
+	// Runtime 为 docker 或 podman
+	Runtime string
+	Image   string
+	// Network 允许容器访问网络
+	Network bool
+}
+
+// containerWorkdir 是工作区在容器内的挂载点
+const containerWorkdir = "/workspace"
+
+// containerRemoveTimeout 限制取消命令时删除容器的等待时间
+const containerRemoveTimeout = 10 * time.Second
+
+// args 返回在名为 name 的容器中以 root 为工作区执行 command 的命令行
+func (c *Container) args(name, root, command string) []string {
+	args := []string{c.Runtime, "run", "--rm", "-i", "--name", name, "-v", root + ":" + containerWorkdir, "-w", containerWorkdir}
+	if !c.Network {
+		args = append(args, "--network", "none")
+	}
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 && c.Runtime == "docker" {
+		// 让容器中创建的文件仍属于当前用户；podman 默认使用 rootless 映射
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, gid))
+	}
+	return append(args, c.Image, "sh", "-c", command)
+}
+
+// newContainerName 返回一个随机的容器名，取消命令时用它删除容器
+func newContainerName() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to name the container: %w", err)
+	}
+	return "agent-" + hex.EncodeToString(b), nil
+}
+
+// removeOnCancel 让 cmd 在 ctx 取消或超时时强制删除容器 name。
+// 只终止 docker run 客户端时，容器中的命令会继续运行
+func (c *Container) removeOnCancel(cmd *exec.Cmd, name string) {
+	kill := cmd.Cancel
+	cmd.Cancel = func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), containerRemoveTimeout)
+		defer cancel()
+		_ = exec.CommandContext(ctx, c.Runtime, "rm", "-f", name).Run()
+		return kill()
+	}
+}
+
+// Shell 在工作区根目录执行一条经过 policy 检查的 shell 命令，返回合并后的标准输出和标准错误。
+// container 不为 nil 时在容器中执行
+func (w *Workspace) Shell(ctx context.Context, policy CommandPolicy, container *Container, input json.RawMessage) (string, error) {
+	return w.StreamShell(ctx, policy, container, input, nil)
+}
+
+// StreamShell 与 Shell 相同，live 不为 nil 时同时把输出实时写入 live
+func (w *Workspace) StreamShell(ctx context.Context, policy CommandPolicy, container *Container, input json.RawMessage, live io.Writer) (string, error) {
+	var params ShellInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	if err := policy.Check(params.Command); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, shellTimeout)
+	defer cancel()
+	cmd, err := w.shellCommand(ctx, container, params.Command)
+	if err != nil {
+		return "", err
+	}
+	output, err := runCommand(cmd, live)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("command timed out after %s:\n%s", shellTimeout, output)
+	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("command cancelled (%w):\n%s", ctx.Err(), output)
+	}
+	if err != nil {
+		return "", fmt.Errorf("command failed (%w):\n%s", err, output)
+	}
+	return output, nil
+}
+
+// RunShell 像 shell 工具一样执行 command：先按 policy 检查，再在同样的位置（容器、远程机器或主机）
+// 执行，返回合并后的标准输出和标准错误。命令以非零状态退出时同时返回输出和 *exec.ExitError
+func (w *Workspace) RunShell(ctx context.Context, policy CommandPolicy, container *Container, command string) (string, error) {
+	if err := policy.Check(command); err != nil {
+		return "", err
+	}
+	cmd, err := w.shellCommand(ctx, container, command)
+	if err != nil {
+		return "", err
+	}
+	return runCommand(cmd, nil)
+}
+
+// shellCommand 返回在工作区根目录执行 command 的命令：container 不为 nil 时在容器中，
+// 远程工作区在远程机器上，否则用主机的 shell
+func (w *Workspace) shellCommand(ctx context.Context, container *Container, command string) (*exec.Cmd, error) {
+	var args []string
+	var name string
+	if container != nil {
+		root, err := filepath.Abs(w.Root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve workspace root: %w", err)
+		}
+		if name, err = newContainerName(); err != nil {
+			return nil, err
+		}
+		args = container.args(name, root, command)
+	} else if w.Remote != nil {
+		args = w.Remote.args(command)
+	} else {
+		var err error
+		if args, err = hostShell(runtime.GOOS, w.HostShell, command); err != nil {
+			return nil, err
+		}
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	killOnCancel(cmd)
+	if container != nil {
+		container.removeOnCancel(cmd, name)
+	}
+	cmd.Dir = w.Root
+	return cmd, nil
+}
+
+// runCommand 执行 cmd，返回合并后的标准输出和标准错误，live 不为 nil 时同时实时写入 live
+func runCommand(cmd *exec.Cmd, live io.Writer) (string, error) {
+	var output bytes.Buffer
+	// 标准输出和标准错误使用同一个 writer，exec 保证同一时刻只有一个 goroutine 写入
+	var sink io.Writer = &output
+	if live != nil {
+		sink = io.MultiWriter(&output, live)
+	}
+	cmd.Stdout = sink
+	cmd.Stderr = sink
+	err := cmd.Run()
+	return output.String(), err
+}
+
+func init() {
+	Register(Spec{Name: "shell", Category: CategoryShell, Permissions: []Permission{PermissionExec},
+		New: func(env Env) (ToolDefinition, bool) { return ShellTool(env.Workspace, env.Policy, env.Container), true }})
+}
+
+// ShellTool 返回在工作区 w 中按 policy 执行命令的工具定义，container 不为 nil 时命令在容器中执行
+func ShellTool(w *Workspace, policy CommandPolicy, container *Container) ToolDefinition {
+	description := "Run a shell command in the workspace root and return its combined output, e.g. to build, run tests or list files. Some commands are denied by policy; the error explains why."
+	if container != nil {
+		description += fmt.Sprintf(" Commands run in a %s container (image %s) with the workspace mounted at %s", container.Runtime, container.Image, containerWorkdir)
+		if !container.Network {
+			description += " and no network access"
+		}
+		description += "."
+	} else if w.Remote != nil {
+		description += fmt.Sprintf(" Commands run with sh on %s over SSH, in %s.", w.Remote.Host, remoteRootName(w.Remote))
+	} else if shell := shellProgram(runtime.GOOS, w.HostShell); shell != "sh" {
+		description += fmt.Sprintf(" Commands run with %s on %s, so use its syntax.", shell, runtime.GOOS)
+	}
+	return ToolDefinition{
+		Name:        "shell",
+		Description: description,
+		InputSchema: GenerateSchema[ShellInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return shellResult(w.Shell(ctx, policy, container, input))
+		},
+		Stream: func(ctx context.Context, input json.RawMessage, output io.Writer) ToolResult {
+			return shellResult(w.StreamShell(ctx, policy, container, input, output))
+		},
+	}
+}
+
+// shellResult 把命令的输出转换为结果，命令执行完时记录退出码
+func shellResult(output string, err error) ToolResult {
+	result := Result(output, err)
+	var exit *exec.ExitError
+	if err == nil {
+		result.Metadata = map[string]any{"exit_code": 0}
+	} else if errors.As(err, &exit) {
+		result.Metadata = map[string]any{"exit_code": exit.ExitCode()}
+	}
+	return result
+}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// storageTimeout 是列出或读取一次对象存储的最长时间
+const storageTimeout = time.Minute
+
+// maxCommandOutput 限制 storage 和 kubernetes 工具返回的字节数，更长的输出只返回开头部分
+const maxCommandOutput = 64 * 1024
+
+// StorageInput 定义 storage 工具的输入参数
+type StorageInput struct {
+	Action string `json:"action" jsonschema:"enum=list,enum=get" jsonschema_description:"list: list the buckets, or the objects and folders under a prefix. get: fetch the contents of one object."`
+	URL    string `json:"url" jsonschema_description:"An S3 or GCS location: s3://bucket/prefix or gs://bucket/prefix for list (s3:// or gs:// alone lists the buckets), s3://bucket/key or gs://bucket/key for get."`
+}
+
+// Storage 是 storage 工具访问的对象存储。访问通过 aws 和 gcloud 命令进行，
+// 凭据照常来自环境变量、配置文件或实例元数据
+type Storage struct {
+	// Buckets 是可以读取的存储桶，例如 s3://pipeline-data，* 匹配任意文本
+	Buckets []string
+	// AWS 和 GCloud 是 aws 和 gcloud 命令，为空时分别为 aws 和 gcloud
+	AWS    string
+	GCloud string
+}
+
+func init() {
+	Register(Spec{Name: "storage", Category: CategoryIntegrations, ReadOnly: true, Permissions: []Permission{PermissionExec, PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) { return StorageTool(env.Storage), env.Storage != nil }})
+}
+
+// StorageTool 返回列出和读取 S3、GCS 对象的工具定义
+func StorageTool(s *Storage) ToolDefinition {
+	description := fmt.Sprintf("List and read objects in S3 (s3://) and GCS (gs://) buckets, e.g. the inputs and outputs of a data pipeline. Objects larger than %s are cut off; binary objects are refused.", formatBytes(maxCommandOutput))
+	if s != nil {
+		description += " Readable buckets: " + strings.Join(s.Buckets, ", ") + "."
+	}
+	return ToolDefinition{
+		Name:        "storage",
+		Description: description,
+		InputSchema: GenerateSchema[StorageInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(s.Run(ctx, input))
+		},
+		ReadOnly: true,
+	}
+}
+
+// Run 执行 storage 工具的操作
+func (s *Storage) Run(ctx context.Context, input json.RawMessage) (string, error) {
+	var params StorageInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	args, err := s.command(params.Action, params.URL)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storageTimeout)
+	defer cancel()
+	out, truncated, err := runLimited(ctx, args, maxCommandOutput)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out after %s", params.URL, storageTimeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	if params.Action == "get" && bytes.IndexByte(out, 0) >= 0 {
+		return "", invalidInput("%s looks like a binary object; use the shell tool to inspect it", params.URL)
+	}
+	text := string(out)
+	if text == "" && params.Action == "list" {
+		return "No objects found.", nil
+	}
+	if truncated {
+		text += fmt.Sprintf("\n(showing the first %s; narrow the prefix, or use the shell tool to read the rest)\n", formatBytes(maxCommandOutput))
+	}
+	return text, nil
+}
+
+// command 返回执行 action 的命令行。url 指向的存储桶必须在 Buckets 中
+func (s *Storage) command(action, url string) ([]string, error) {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok || scheme != "s3" && scheme != "gs" {
+		return nil, invalidInput("url %q must start with s3:// or gs://", url)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" && action != "list" {
+		return nil, invalidInput("url %q names no bucket", url)
+	}
+	if bucket != "" && !s.allowed(scheme+"://"+bucket) {
+		return nil, fmt.Errorf("bucket %s://%s %w: the config allows only %s", scheme, bucket, ErrDenied, strings.Join(s.Buckets, ", "))
+	}
+
+	aws, gcloud := s.AWS, s.GCloud
+	if aws == "" {
+		aws = "aws"
+	}
+	if gcloud == "" {
+		gcloud = "gcloud"
+	}
+	switch {
+	case action == "list" && scheme == "s3":
+		return []string{aws, "s3", "ls", url}, nil
+	case action == "list":
+		return []string{gcloud, "storage", "ls", "-l", url}, nil
+	case action != "get":
+		return nil, invalidInput("unknown action %q (use list or get)", action)
+	case key == "" || strings.HasSuffix(key, "/"):
+		return nil, invalidInput("url %q names no object; list it to see the objects", url)
+	case scheme == "s3":
+		return []string{aws, "s3", "cp", url, "-"}, nil
+	default:
+		return []string{gcloud, "storage", "cat", url}, nil
+	}
+}
+
+// allowed 判断存储桶是否匹配 Buckets 中的某个模式
+func (s *Storage) allowed(bucket string) bool {
+	for _, pattern := range s.Buckets {
+		if ok, _ := path.Match(pattern, bucket); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runLimited 执行命令并返回标准输出的前 limit 个字节。输出更多时停止命令并报告截断，
+// 命令失败时错误中带有标准错误的内容
+func runLimited(ctx context.Context, args []string, limit int) ([]byte, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	killOnCancel(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, false, err
+	}
+	out, err := io.ReadAll(io.LimitReader(stdout, int64(limit)+1))
+	truncated := len(out) > limit
+	if truncated {
+		// 不再需要剩下的输出
+		out = out[:limit]
+		cancel()
+	}
+	if werr := cmd.Wait(); werr != nil && !truncated {
+		return nil, false, fmt.Errorf("%s failed (%w): %s", args[0], werr, strings.TrimSpace(stderr.String()))
+	}
+	return out, truncated, err
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageCommand(t *testing.T) {
+	s := &Storage{Buckets: []string{"s3://pipeline-data", "gs://exports-*"}}
+
+	for _, c := range []struct {
+		action, url string
+		want        []string
+	}{
+		{"list", "s3://pipeline-data/2024/", []string{"aws", "s3", "ls", "s3://pipeline-data/2024/"}},
+		{"list", "s3://", []string{"aws", "s3", "ls", "s3://"}},
+		{"list", "gs://exports-eu/daily", []string{"gcloud", "storage", "ls", "-l", "gs://exports-eu/daily"}},
+		{"get", "s3://pipeline-data/2024/run.json", []string{"aws", "s3", "cp", "s3://pipeline-data/2024/run.json", "-"}},
+		{"get", "gs://exports-eu/daily/part-0.csv", []string{"gcloud", "storage", "cat", "gs://exports-eu/daily/part-0.csv"}},
+	} {
+		args, err := s.command(c.action, c.url)
+		require.NoError(t, err, c.url)
+		assert.Equal(t, c.want, args)
+	}
+
+	t.Run("拒绝未配置的存储桶", func(t *testing.T) {
+		_, err := s.command("list", "s3://billing/")
+		assert.Equal(t, ErrorPermissionDenied, KindOf(err))
+		_, err = s.command("get", "gs://exports/x")
+		assert.Equal(t, ErrorPermissionDenied, KindOf(err))
+	})
+
+	t.Run("无效的地址", func(t *testing.T) {
+		for _, c := range [][2]string{{"list", "https://example.com/x"}, {"get", "s3://"}, {"get", "s3://pipeline-data/2024/"}, {"delete", "s3://pipeline-data/x"}} {
+			_, err := s.command(c[0], c[1])
+			assert.Equal(t, ErrorInvalidInput, KindOf(err), c)
+		}
+	})
+}
+
+func TestStorageRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	fake := func(script string) string {
+		path := filepath.Join(t.TempDir(), "aws")
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+		return path
+	}
+	run := func(s *Storage, input string) (string, error) {
+		s.Buckets = []string{"s3://data"}
+		return s.Run(context.Background(), json.RawMessage(input))
+	}
+
+	t.Run("返回对象内容", func(t *testing.T) {
+		out, err := run(&Storage{AWS: fake(`echo "id,name"`)}, `{"action":"get","url":"s3://data/users.csv"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "id,name\n", out)
+	})
+
+	t.Run("大对象只返回开头", func(t *testing.T) {
+		out, err := run(&Storage{AWS: fake(`while :; do echo aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa; done`)}, `{"action":"get","url":"s3://data/big.log"}`)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(out, "aaaa"))
+		assert.Contains(t, out, "(showing the first 64.0 KiB")
+	})
+
+	t.Run("拒绝二进制对象", func(t *testing.T) {
+		_, err := run(&Storage{AWS: fake(`printf 'PAR1\000\000'`)}, `{"action":"get","url":"s3://data/part.parquet"}`)
+		assert.ErrorContains(t, err, "binary")
+	})
+
+	t.Run("命令失败时返回标准错误", func(t *testing.T) {
+		_, err := run(&Storage{AWS: fake(`echo "An error occurred (NoSuchKey)" >&2; exit 1`)}, `{"action":"get","url":"s3://data/missing"}`)
+		assert.ErrorContains(t, err, "NoSuchKey")
+	})
+
+	t.Run("列表为空", func(t *testing.T) {
+		out, err := run(&Storage{AWS: fake(`true`)}, `{"action":"list","url":"s3://data/none/"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "No objects found.", out)
+	})
+}
+
+// FuzzStorageCommand 检查只有配置允许的存储桶能被读取
+func FuzzStorageCommand(f *testing.F) {
+	for _, c := range [][2]string{{"list", "s3://pipeline-data/logs/"}, {"get", "s3://pipeline-data/a.json"}, {"get", "gs://exports-2024/x.csv"},
+		{"get", "s3://other/a.json"}, {"list", "s3://"}, {"get", "s3:///a"}, {"get", "file:///etc/passwd"}, {"delete", "s3://pipeline-data/a"}} {
+		f.Add(c[0], c[1])
+	}
+	s := &Storage{Buckets: []string{"s3://pipeline-data", "gs://exports-*"}}
+
+	f.Fuzz(func(t *testing.T, action, url string) {
+		args, err := s.command(action, url)
+		if err != nil {
+			return
+		}
+		assert.Contains(t, []string{"aws", "gcloud"}, args[0])
+		assert.Contains(t, args, url)
+		scheme, rest, _ := strings.Cut(url, "://")
+		bucket, _, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			assert.Equal(t, "list", action, "没有存储桶时只能列出")
+			return
+		}
+		allowed := scheme+"://"+bucket == "s3://pipeline-data" || scheme == "gs" && strings.HasPrefix(bucket, "exports-")
+		assert.True(t, allowed, "读取了存储桶 %s://%s", scheme, bucket)
+	})
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agent/search"
+)
+
+// maxSymbols 限制 find_symbol 返回的声明数
+const maxSymbols = 100
+
+func init() {
+	Register(Spec{Name: "find_symbol", Category: CategorySearch, ReadOnly: true, Permissions: []Permission{PermissionRead},
+		New: func(env Env) (ToolDefinition, bool) { return FindSymbolTool(env.Index), env.Index != nil }})
+}
+
+// FindSymbolInput 定义按名称查找声明工具的输入参数
+type FindSymbolInput struct {
+	Name string `json:"name" jsonschema_description:"The symbol name or part of it, matched case-insensitively, e.g. NewAgent or parse."`
+	Kind string `json:"kind,omitempty" jsonschema_description:"Optional kind to limit the results to: func, method, type, const or var."`
+}
+
+// FindSymbol 在索引中按名称查找 Go 声明
+func FindSymbol(index *search.Index, input json.RawMessage) (string, error) {
+	return findSymbol(index, input).Output()
+}
+
+// findSymbol 执行 FindSymbol，结果引用找到的声明
+func findSymbol(index *search.Index, input json.RawMessage) ToolResult {
+	var params FindSymbolInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return ErrorResult(fmt.Errorf("failed to parse input: %w", err))
+	}
+	if strings.TrimSpace(params.Name) == "" {
+		return ErrorResult(fmt.Errorf("name must not be empty"))
+	}
+
+	var result ToolResult
+	var b strings.Builder
+	for _, s := range index.Symbols(params.Name) {
+		if params.Kind != "" && s.Kind != params.Kind {
+			continue
+		}
+		if len(result.Files) == maxSymbols {
+			fmt.Fprintf(&b, "(showing the first %d declarations; use a longer name or a kind to see the rest)\n", maxSymbols)
+			break
+		}
+		name := s.Name
+		if s.Recv != "" {
+			name = s.Recv + "." + s.Name
+		}
+		fmt.Fprintf(&b, "%s:%d: %s %s\n", s.Path, s.Line, s.Kind, name)
+		result.Files = append(result.Files, FileRef{Path: s.Path, Line: s.Line})
+	}
+	result.Metadata = map[string]any{"symbols": len(result.Files)}
+	if len(result.Files) == 0 {
+		result.Text = "No declarations found."
+		return result
+	}
+	result.Text = b.String()
+	return result
+}
+
+// FindSymbolTool 返回在工作区索引中按名称查找 Go 声明的工具定义
+func FindSymbolTool(index *search.Index) ToolDefinition {
+	return ToolDefinition{
+		Name:        "find_symbol",
+		Description: "Find where Go functions, methods, types, constants and variables are declared, by name, and list them as path:line: kind name. Uses an index of the workspace kept up to date as files change, so it is faster than grep for declarations.",
+		InputSchema: GenerateSchema[FindSymbolInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return findSymbol(index, input)
+		},
+		ReadOnly: true,
+	}
+}
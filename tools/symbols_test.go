@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/search"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSymbolTool(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "agent.go"), []byte("package agent\n\ntype Agent struct{}\n\nfunc (a *Agent) Run() {}\n\nfunc run() {}\n"), 0644))
+	index, err := search.NewIndex(context.Background(), root)
+	require.NoError(t, err)
+	tool := FindSymbolTool(index)
+
+	t.Run("列出声明的位置", func(t *testing.T) {
+		out, err := tool.Function(context.Background(), json.RawMessage(`{"name": "run"}`)).Output()
+		require.NoError(t, err)
+		assert.Equal(t, "agent.go:7: func run\nagent.go:5: method Agent.Run\n", out)
+
+		result := tool.Function(context.Background(), json.RawMessage(`{"name": "run"}`))
+		assert.Equal(t, []FileRef{{Path: "agent.go", Line: 7}, {Path: "agent.go", Line: 5}}, result.Files)
+	})
+
+	t.Run("按种类过滤", func(t *testing.T) {
+		out, err := tool.Function(context.Background(), json.RawMessage(`{"name": "run", "kind": "method"}`)).Output()
+		require.NoError(t, err)
+		assert.Equal(t, "agent.go:5: method Agent.Run\n", out)
+	})
+
+	t.Run("没有结果", func(t *testing.T) {
+		out, err := tool.Function(context.Background(), json.RawMessage(`{"name": "missing"}`)).Output()
+		require.NoError(t, err)
+		assert.Equal(t, "No declarations found.", out)
+	})
+
+	t.Run("名称不能为空", func(t *testing.T) {
+		_, err := tool.Function(context.Background(), json.RawMessage(`{"name": " "}`)).Output()
+		assert.ErrorContains(t, err, "name must not be empty")
+	})
+}
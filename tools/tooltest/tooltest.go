@@ -0,0 +1,334 @@
+// Package tooltest 提供工具定义的一致性测试。内置工具和第三方工具都可以用 Run
+// 检查是否遵守工具的约定：schema 有效、拒绝无法解析的输入、不访问工作区以外的
+// 文件、同样的输入得到同样的错误、只读工具不修改工作区。
+//
+//	func TestMyTool(t *testing.T) {
+//		tooltest.Run(t, func(w *tools.Workspace) tools.ToolDefinition { return MyTool(w) }, tooltest.Options{
+//			Valid: []json.RawMessage{json.RawMessage(`{"path": "a.go"}`)},
+//		})
+//	}
+package tooltest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"agent/tools"
+)
+
+// Factory 在工作区 w 中创建要测试的工具
+type Factory func(w *tools.Workspace) tools.ToolDefinition
+
+// Options 调整一致性测试
+type Options struct {
+	// Valid 是工具应当执行成功的输入。只读工具执行这些输入后工作区必须不变
+	Valid []json.RawMessage
+	// PathFields 是输入中表示工作区内路径的字段，为空时取 schema 中名为
+	// path、file、dir 或 directory 的字符串字段
+	PathFields []string
+	// Setup 在每个子测试创建工作区后调用，可以写入工具需要的文件
+	Setup func(t testing.TB, root string)
+}
+
+// outside 目录中的文件，工具不能读出或修改它
+const (
+	secretName    = "secret.txt"
+	secretContent = "tooltest secret outside the workspace\n"
+)
+
+// Files 是每个测试工作区中的文件，Options.Valid 中的输入可以引用它们
+var Files = map[string]string{
+	"a.go":      "package a\n\nfunc A() {}\n",
+	"sub/b.txt": "hello\nworld\n",
+}
+
+// namePattern 是模型服务接受的工具名
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// malformedInputs 是任何工具都应当拒绝的输入
+var malformedInputs = []string{`not json`, `{`, `{"a": }`, `[1, 2]`, `"text"`, `42`}
+
+// Run 以子测试运行全部检查
+func Run(t *testing.T, factory Factory, opts Options) {
+	t.Helper()
+	t.Run("schema", func(t *testing.T) {
+		w, _ := Workspace(t, opts)
+		CheckSchema(t, factory(w))
+	})
+	t.Run("malformed input", func(t *testing.T) {
+		w, _ := Workspace(t, opts)
+		def := factory(w)
+		for _, input := range malformedInputs {
+			r := call(t, def, json.RawMessage(input))
+			if !r.IsError {
+				t.Errorf("%s accepted malformed input %q: %s", def.Name, input, r.Text)
+				continue
+			}
+			if kind := r.ErrorKind(); kind != "" && kind != tools.ErrorInvalidInput {
+				t.Errorf("%s classified malformed input %q as %s, want %s", def.Name, input, kind, tools.ErrorInvalidInput)
+			}
+		}
+		for _, field := range stringFields(t, def) {
+			input := fmt.Sprintf(`{%q: 42}`, field)
+			if r := call(t, def, json.RawMessage(input)); !r.IsError {
+				t.Errorf("%s accepted a number for the string field %s: %s", def.Name, field, r.Text)
+			}
+		}
+	})
+	t.Run("sandbox", func(t *testing.T) {
+		w, outside := Workspace(t, opts)
+		CheckSandbox(t, factory(w), outside, opts.PathFields)
+	})
+	t.Run("deterministic errors", func(t *testing.T) {
+		w, outside := Workspace(t, opts)
+		def := factory(w)
+		inputs := append([]json.RawMessage{}, rawInputs(malformedInputs)...)
+		for _, field := range pathFields(t, def, opts.PathFields) {
+			inputs = append(inputs, withPath(t, def, field, filepath.Join(outside, secretName)))
+		}
+		for _, input := range inputs {
+			first, second := call(t, def, input), call(t, def, input)
+			if first.IsError != second.IsError || first.Text != second.Text {
+				t.Errorf("%s gave different results for %s:\n%s\n%s", def.Name, input, first.Text, second.Text)
+			}
+			if def.Stream != nil && first.IsError {
+				var output strings.Builder
+				if streamed := def.Stream(context.Background(), input, &output); streamed.Text != first.Text {
+					t.Errorf("%s Stream and Function fail differently for %s:\n%s\n%s", def.Name, input, streamed.Text, first.Text)
+				}
+			}
+		}
+	})
+	t.Run("valid input", func(t *testing.T) {
+		w, _ := Workspace(t, opts)
+		def := factory(w)
+		for _, input := range opts.Valid {
+			before := snapshot(t, w.Root)
+			if r := call(t, def, input); r.IsError {
+				t.Errorf("%s failed for valid input %s: %s", def.Name, input, r.Text)
+			}
+			if def.ReadOnly && !maps.Equal(before, snapshot(t, w.Root)) {
+				t.Errorf("%s is read-only but changed the workspace for %s", def.Name, input)
+			}
+		}
+	})
+}
+
+// CheckSchema 检查工具的名称、说明和输入 schema
+func CheckSchema(t testing.TB, def tools.ToolDefinition) {
+	t.Helper()
+	if !namePattern.MatchString(def.Name) {
+		t.Errorf("tool name %q must match %s", def.Name, namePattern)
+	}
+	if strings.TrimSpace(def.Description) == "" {
+		t.Errorf("%s has no description", def.Name)
+	}
+	if def.Function == nil {
+		t.Fatalf("%s has no Function", def.Name)
+	}
+	if _, err := json.Marshal(def.InputSchema); err != nil {
+		t.Fatalf("%s input schema cannot be encoded: %v", def.Name, err)
+	}
+	properties := schemaProperties(t, def)
+	for name, property := range properties {
+		_, typed := property["type"]
+		_, anyOf := property["anyOf"]
+		_, oneOf := property["oneOf"]
+		if !typed && !anyOf && !oneOf {
+			t.Errorf("%s property %s has no type", def.Name, name)
+		}
+		if description, _ := property["description"].(string); strings.TrimSpace(description) == "" {
+			t.Errorf("%s property %s has no description", def.Name, name)
+		}
+	}
+	seen := map[string]bool{}
+	for _, name := range def.InputSchema.Required {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("%s requires %s, which is not a property", def.Name, name)
+		}
+		if seen[name] {
+			t.Errorf("%s requires %s twice", def.Name, name)
+		}
+		seen[name] = true
+	}
+}
+
+// CheckSandbox 检查指向 Workspace 返回的 outside 目录的路径被拒绝，工具既不读出也不修改那里的文件
+func CheckSandbox(t testing.TB, def tools.ToolDefinition, outside string, fields []string) {
+	t.Helper()
+	secret := filepath.Join(outside, secretName)
+	paths := []string{"../outside", "../outside/" + secretName, "sub/../../outside/" + secretName, outside, secret}
+	if _, err := os.Lstat(filepath.Join(filepath.Dir(outside), "root", "link")); err == nil {
+		paths = append(paths, "link", "link/"+secretName, "link/new.txt")
+	}
+	before := snapshot(t, outside)
+	for _, field := range pathFields(t, def, fields) {
+		for _, path := range paths {
+			input := withPath(t, def, field, path)
+			r := call(t, def, input)
+			if !r.IsError {
+				t.Errorf("%s accepted %s outside the workspace: %s", def.Name, input, r.Text)
+			}
+			if kind := r.ErrorKind(); kind != "" && kind != tools.ErrorInvalidInput && kind != tools.ErrorPermissionDenied && kind != tools.ErrorNotFound {
+				t.Errorf("%s classified %s as %s", def.Name, input, kind)
+			}
+			if strings.Contains(r.Text, strings.TrimSpace(secretContent)) {
+				t.Errorf("%s revealed a file outside the workspace for %s", def.Name, input)
+			}
+		}
+	}
+	if !maps.Equal(before, snapshot(t, outside)) {
+		t.Errorf("%s changed files outside the workspace", def.Name)
+	}
+}
+
+// Workspace 创建测试用的工作区 dir/root，其中有 Files 中的文件，以及指向工作区旁边
+// dir/outside 目录的符号链接 link。返回工作区和 outside 目录，后者可以传给 CheckSandbox
+func Workspace(t testing.TB, opts Options) (*tools.Workspace, string) {
+	t.Helper()
+	dir := t.TempDir()
+	root, outside := filepath.Join(dir, "root"), filepath.Join(dir, "outside")
+	for name, content := range Files {
+		write(t, filepath.Join(root, name), content)
+	}
+	write(t, filepath.Join(outside, secretName), secretContent)
+	// 不支持符号链接的系统上跳过相关的检查
+	_ = os.Symlink(outside, filepath.Join(root, "link"))
+	if opts.Setup != nil {
+		opts.Setup(t, root)
+	}
+	return &tools.Workspace{Root: root}, outside
+}
+
+func write(t testing.TB, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// call 执行工具，把 panic 报告为测试失败
+func call(t testing.TB, def tools.ToolDefinition, input json.RawMessage) (r tools.ToolResult) {
+	t.Helper()
+	defer func() {
+		if p := recover(); p != nil {
+			t.Errorf("%s panicked for %s: %v", def.Name, input, p)
+			r = tools.ErrorResult(fmt.Errorf("panic: %v", p))
+		}
+	}()
+	return def.Function(context.Background(), input)
+}
+
+// schemaProperties 返回 schema 中的属性，属性可能是任何能编码为 JSON 对象的值
+func schemaProperties(t testing.TB, def tools.ToolDefinition) map[string]map[string]any {
+	t.Helper()
+	properties := map[string]map[string]any{}
+	if def.InputSchema.Properties == nil {
+		return properties
+	}
+	data, err := json.Marshal(def.InputSchema.Properties)
+	if err != nil {
+		t.Fatalf("%s properties cannot be encoded: %v", def.Name, err)
+	}
+	if err := json.Unmarshal(data, &properties); err != nil {
+		t.Fatalf("%s properties must be an object of schemas: %v", def.Name, err)
+	}
+	return properties
+}
+
+// stringFields 返回 schema 中类型为 string 的属性
+func stringFields(t testing.TB, def tools.ToolDefinition) []string {
+	t.Helper()
+	var fields []string
+	for name, property := range schemaProperties(t, def) {
+		if property["type"] == "string" {
+			fields = append(fields, name)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// pathFields 返回表示路径的字段
+func pathFields(t testing.TB, def tools.ToolDefinition, fields []string) []string {
+	t.Helper()
+	if len(fields) > 0 {
+		return fields
+	}
+	for _, name := range stringFields(t, def) {
+		if slices.Contains([]string{"path", "file", "dir", "directory"}, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// withPath 返回把 field 设为 path 的输入，其他必需的字段取各自类型的空值。
+// 字符串取空字符串，对 edit_file 这样的工具意味着新建文件，是最需要拦住的情况
+func withPath(t testing.TB, def tools.ToolDefinition, field, path string) json.RawMessage {
+	t.Helper()
+	properties := schemaProperties(t, def)
+	input := map[string]any{}
+	for _, name := range def.InputSchema.Required {
+		switch properties[name]["type"] {
+		case "integer", "number":
+			input[name] = 0
+		case "boolean":
+			input[name] = false
+		case "array":
+			input[name] = []any{}
+		case "object":
+			input[name] = map[string]any{}
+		default:
+			input[name] = ""
+		}
+	}
+	input[field] = path
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func rawInputs(inputs []string) []json.RawMessage {
+	raw := make([]json.RawMessage, len(inputs))
+	for i, input := range inputs {
+		raw[i] = json.RawMessage(input)
+	}
+	return raw
+}
+
+// snapshot 返回目录中每个文件的内容，不跟随符号链接
+func snapshot(t testing.TB, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			files[path] = "-> " + target
+			return err
+		}
+		data, err := os.ReadFile(path)
+		files[path] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
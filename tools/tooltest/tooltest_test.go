@@ -0,0 +1,124 @@
+package tooltest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder 记录检查报告的失败，而不让外层测试失败
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (r *recorder) Fatal(args ...any) {
+	r.Fatalf("%s", fmt.Sprint(args...))
+}
+
+// check 在单独的 goroutine 中运行 fn，Fatalf 只结束这个 goroutine
+func check(t *testing.T, fn func(tb testing.TB)) []string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(r)
+	}()
+	<-done
+	return r.failures
+}
+
+// leakyTool 读取任何路径，包括工作区以外的
+func leakyTool(w *tools.Workspace) tools.ToolDefinition {
+	return tools.ToolDefinition{
+		Name:        "leaky_read",
+		Description: "Read a file.",
+		InputSchema: tools.GenerateSchema[tools.ReadFileInput](),
+		Function: func(ctx context.Context, input json.RawMessage) tools.ToolResult {
+			var params tools.ReadFileInput
+			if err := json.Unmarshal(input, &params); err != nil {
+				return tools.ErrorResult(err)
+			}
+			path := params.Path
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(w.Root, path)
+			}
+			data, err := os.ReadFile(path)
+			return tools.Result(string(data), err)
+		},
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	t.Run("内置工具", func(t *testing.T) {
+		w, _ := Workspace(t, Options{})
+		assert.Empty(t, check(t, func(tb testing.TB) { CheckSchema(tb, tools.EditFileTool(w)) }))
+	})
+
+	t.Run("报告名称、说明和必需字段的问题", func(t *testing.T) {
+		def := tools.ToolDefinition{
+			Name:        "read file",
+			InputSchema: tools.GenerateSchema[tools.ReadFileInput](),
+			Function:    func(context.Context, json.RawMessage) tools.ToolResult { return tools.ToolResult{} },
+		}
+		def.InputSchema.Required = []string{"path", "line", "path"}
+		failures := strings.Join(check(t, func(tb testing.TB) { CheckSchema(tb, def) }), "\n")
+		assert.Contains(t, failures, `tool name "read file" must match`)
+		assert.Contains(t, failures, "has no description")
+		assert.Contains(t, failures, "requires line, which is not a property")
+		assert.Contains(t, failures, "requires path twice")
+	})
+
+	t.Run("没有 Function", func(t *testing.T) {
+		failures := check(t, func(tb testing.TB) { CheckSchema(tb, tools.ToolDefinition{Name: "x", Description: "x"}) })
+		assert.Equal(t, []string{"x has no Function"}, failures)
+	})
+}
+
+func TestCheckSandbox(t *testing.T) {
+	t.Run("内置工具", func(t *testing.T) {
+		w, outside := Workspace(t, Options{})
+		assert.Empty(t, check(t, func(tb testing.TB) { CheckSandbox(tb, tools.ReadFileTool(w), outside, nil) }))
+	})
+
+	t.Run("报告读出工作区以外文件的工具", func(t *testing.T) {
+		w, outside := Workspace(t, Options{})
+		failures := strings.Join(check(t, func(tb testing.TB) { CheckSandbox(tb, leakyTool(w), outside, nil) }), "\n")
+		assert.Contains(t, failures, "leaky_read accepted")
+		assert.Contains(t, failures, "revealed a file outside the workspace")
+	})
+}
+
+func TestWorkspace(t *testing.T) {
+	w, outside := Workspace(t, Options{Setup: func(t testing.TB, root string) {
+		write(t, filepath.Join(root, "extra.txt"), "extra\n")
+	}})
+	for name, content := range Files {
+		data, err := os.ReadFile(filepath.Join(w.Root, name))
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+	assert.FileExists(t, filepath.Join(w.Root, "extra.txt"))
+	assert.FileExists(t, filepath.Join(outside, secretName))
+	assert.Equal(t, filepath.Dir(w.Root), filepath.Dir(outside))
+}
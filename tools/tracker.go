@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"agent/jira"
+	"agent/linear"
+)
+
+// TrackerInput 定义 tracker 工具的输入参数
+type TrackerInput struct {
+	Action  string `json:"action" jsonschema:"enum=get,enum=comment" jsonschema_description:"get: fetch the issue with its description and comments. comment: post a comment on the issue."`
+	Issue   string `json:"issue" jsonschema_description:"The issue key (e.g. PROJ-123) or its Jira or Linear URL."`
+	Body    string `json:"body,omitempty" jsonschema_description:"The comment to post for comment, e.g. a summary of the change and how it was tested."`
+	Tracker string `json:"tracker,omitempty" jsonschema:"enum=jira,enum=linear" jsonschema_description:"The tracker the key belongs to, when both Jira and Linear are configured and the issue is not a URL."`
+}
+
+// Jira 是访问 Jira 的设置
+type Jira struct {
+	// URL 是站点地址，例如 https://example.atlassian.net
+	URL string
+	// Email 不为空时与 token 一起用于基本认证（Jira Cloud）
+	Email string
+	// Token 返回 API token，没有时返回空字符串
+	Token func() string
+	// HTTP 为空时使用默认客户端
+	HTTP *http.Client
+}
+
+// Linear 是访问 Linear 的设置
+type Linear struct {
+	// URL 为空时使用 Linear 的 API 地址
+	URL string
+	// Token 返回 API key，没有时返回空字符串
+	Token func() string
+	// HTTP 为空时使用默认客户端
+	HTTP *http.Client
+}
+
+func init() {
+	Register(Spec{Name: "tracker", Category: CategoryIntegrations, Permissions: []Permission{PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) {
+			return TrackerTool(env.Jira, env.Linear), env.Jira != nil || env.Linear != nil
+		}})
+}
+
+// TrackerTool 返回获取和评论 Jira 或 Linear issue 的工具定义，jira 或 linear 为 nil 表示没有配置
+func TrackerTool(jira *Jira, linear *Linear) ToolDefinition {
+	return ToolDefinition{
+		Name:        "tracker",
+		Description: "Fetch or comment on a Jira or Linear issue. Fetch the issue a task refers to, to read its description and acceptance criteria before making changes; comment on it when the work is done, to report what changed.",
+		InputSchema: GenerateSchema[TrackerInput](),
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(Tracker(ctx, jira, linear, input))
+		},
+	}
+}
+
+var (
+	jiraBrowsePattern = regexp.MustCompile(`/browse/([A-Z][A-Z0-9_]*-\d+)`)
+	linearURLPattern  = regexp.MustCompile(`^/[^/]+/issue/([A-Za-z0-9]+-\d+)`)
+	trackerKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*-\d+$`)
+)
+
+// parseTrackerRef 解析 issue 的 URL 或键，返回所属的跟踪系统（jira 或 linear）和键
+func parseTrackerRef(ref, tracker string, jiraEnabled, linearEnabled bool) (string, string, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", "", err
+		}
+		if m := linearURLPattern.FindStringSubmatch(u.Path); m != nil && strings.HasSuffix(u.Host, "linear.app") {
+			tracker, ref = "linear", m[1]
+		} else if m := jiraBrowsePattern.FindStringSubmatch(u.Path); m != nil {
+			tracker, ref = "jira", m[1]
+		} else {
+			return "", "", fmt.Errorf("%s is not a Jira or Linear issue URL", ref)
+		}
+	}
+	if !trackerKeyPattern.MatchString(ref) {
+		return "", "", fmt.Errorf("cannot parse issue %q: use a key like PROJ-123 or the issue URL", ref)
+	}
+	ref = strings.ToUpper(ref)
+
+	switch {
+	case tracker == "" && jiraEnabled && linearEnabled:
+		return "", "", fmt.Errorf("both Jira and Linear are configured: set tracker to jira or linear")
+	case tracker == "" && jiraEnabled:
+		tracker = "jira"
+	case tracker == "" && linearEnabled:
+		tracker = "linear"
+	}
+	switch {
+	case tracker == "jira" && !jiraEnabled, tracker == "linear" && !linearEnabled:
+		return "", "", fmt.Errorf("%s is not configured", tracker)
+	case tracker != "jira" && tracker != "linear":
+		return "", "", fmt.Errorf("unknown tracker %q (use jira or linear)", tracker)
+	}
+	return tracker, ref, nil
+}
+
+// Tracker 执行 tracker 工具的操作
+func Tracker(ctx context.Context, j *Jira, l *Linear, input json.RawMessage) (string, error) {
+	var params TrackerInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	if params.Action != "get" && params.Action != "comment" {
+		return "", fmt.Errorf("unknown action %q (use get or comment)", params.Action)
+	}
+	if params.Action == "comment" && strings.TrimSpace(params.Body) == "" {
+		return "", fmt.Errorf("body is required for comment")
+	}
+	tracker, key, err := parseTrackerRef(params.Issue, params.Tracker, j != nil, l != nil)
+	if err != nil {
+		return "", err
+	}
+
+	if tracker == "jira" {
+		client := &jira.Client{BaseURL: j.URL, Email: j.Email, HTTP: j.HTTP}
+		if j.Token != nil {
+			client.Token = j.Token()
+		}
+		if params.Action == "comment" {
+			if _, err := client.AddComment(ctx, key, params.Body); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Commented on %s: %s", key, client.BrowseURL(key)), nil
+		}
+		return getJiraIssue(ctx, client, key)
+	}
+
+	client := &linear.Client{URL: l.URL, HTTP: l.HTTP}
+	if l.Token != nil {
+		client.Token = l.Token()
+	}
+	issue, err := client.GetIssue(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if params.Action == "comment" {
+		comment, err := client.CreateComment(ctx, issue.ID, params.Body)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Commented on %s: %s", issue.Identifier, comment.URL), nil
+	}
+	var out strings.Builder
+	writeTrackerHeader(&out, "Linear issue", issue.Identifier, issue.Title, issue.State.Name, linearName(issue.Creator), linearName(issue.Assignee), issue.URL, issue.Description)
+	for _, comment := range issue.Comments.Nodes {
+		fmt.Fprintf(&out, "\n--- Comment by %s on %s\n%s\n", linearName(comment.User), comment.CreatedAt.Format("2006-01-02"), strings.TrimSpace(comment.Body))
+	}
+	return out.String(), nil
+}
+
+func getJiraIssue(ctx context.Context, client *jira.Client, key string) (string, error) {
+	issue, err := client.GetIssue(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	fields := issue.Fields
+	kind := "Jira issue"
+	if fields.IssueType.Name != "" {
+		kind = "Jira " + strings.ToLower(fields.IssueType.Name)
+	}
+	writeTrackerHeader(&out, kind, issue.Key, fields.Summary, fields.Status.Name, jiraName(fields.Reporter), jiraName(fields.Assignee), client.BrowseURL(issue.Key), fields.Description)
+	for _, comment := range fields.Comment.Comments {
+		created := comment.Created
+		if len(created) > 10 {
+			created = created[:10]
+		}
+		fmt.Fprintf(&out, "\n--- Comment by %s on %s\n%s\n", comment.Author.DisplayName, created, strings.TrimSpace(comment.Body))
+	}
+	return out.String(), nil
+}
+
+func writeTrackerHeader(out *strings.Builder, kind, key, title, state, reporter, assignee, link, body string) {
+	fmt.Fprintf(out, "%s %s: %s (%s, reported by %s, assigned to %s)\n%s\n", kind, key, title, state, reporter, assignee, link)
+	if body = strings.TrimSpace(body); body != "" {
+		fmt.Fprintf(out, "\n%s\n", body)
+	}
+}
+
+func jiraName(user *jira.User) string {
+	if user == nil {
+		return "nobody"
+	}
+	return user.DisplayName
+}
+
+func linearName(user *linear.User) string {
+	if user == nil {
+		return "nobody"
+	}
+	return user.Name
+}
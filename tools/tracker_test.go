@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrackerRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		tracker string
+		jira    bool
+		linear  bool
+		want    string
+		key     string
+	}{
+		{"Jira 地址", "https://example.atlassian.net/browse/PROJ-12", "", true, true, "jira", "PROJ-12"},
+		{"Linear 地址", "https://linear.app/acme/issue/ENG-7/fix-login", "", true, true, "linear", "ENG-7"},
+		{"只配置了 Jira 时的键", "proj-3", "", true, false, "jira", "PROJ-3"},
+		{"只配置了 Linear 时的键", "ENG-3", "", false, true, "linear", "ENG-3"},
+		{"都配置时指定跟踪系统", "ENG-3", "linear", true, true, "linear", "ENG-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, key, err := parseTrackerRef(tt.ref, tt.tracker, tt.jira, tt.linear)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tracker)
+			assert.Equal(t, tt.key, key)
+		})
+	}
+
+	t.Run("都配置时需要指定跟踪系统", func(t *testing.T) {
+		_, _, err := parseTrackerRef("ENG-3", "", true, true)
+		assert.ErrorContains(t, err, "set tracker")
+	})
+	t.Run("跟踪系统没有配置", func(t *testing.T) {
+		_, _, err := parseTrackerRef("https://linear.app/acme/issue/ENG-7", "", true, false)
+		assert.ErrorContains(t, err, "linear is not configured")
+	})
+	t.Run("无法解析", func(t *testing.T) {
+		_, _, err := parseTrackerRef("the login bug", "", true, false)
+		assert.ErrorContains(t, err, "cannot parse issue")
+	})
+}
+
+func TestTrackerJira(t *testing.T) {
+	var comment map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "me@example.com", user)
+		assert.Equal(t, "secret", pass)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/PROJ-12":
+			w.Write([]byte(`{"key": "PROJ-12", "fields": {
+				"summary": "Login fails", "description": "h3. Acceptance criteria\n* users can log in",
+				"status": {"name": "In Progress"}, "issuetype": {"name": "Story"},
+				"reporter": {"displayName": "Alice"}, "assignee": null,
+				"comment": {"comments": [{"author": {"displayName": "Bob"}, "body": "Seen on Safari too", "created": "2024-05-01T10:00:00.000+0000"}]}
+			}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/PROJ-12/comment":
+			json.NewDecoder(r.Body).Decode(&comment)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "10001"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorMessages": ["Issue does not exist or you do not have permission to see it."]}`))
+		}
+	}))
+	defer server.Close()
+	jira := &Jira{URL: server.URL, Email: "me@example.com", Token: func() string { return "secret" }}
+
+	t.Run("获取 issue", func(t *testing.T) {
+		out, err := Tracker(context.Background(), jira, nil, json.RawMessage(`{"action": "get", "issue": "PROJ-12"}`))
+		require.NoError(t, err)
+		assert.Contains(t, out, "Jira story PROJ-12: Login fails (In Progress, reported by Alice, assigned to nobody)\n"+server.URL+"/browse/PROJ-12\n")
+		assert.Contains(t, out, "* users can log in")
+		assert.Contains(t, out, "--- Comment by Bob on 2024-05-01\nSeen on Safari too")
+	})
+
+	t.Run("发表评论", func(t *testing.T) {
+		out, err := Tracker(context.Background(), jira, nil, json.RawMessage(`{"action": "comment", "issue": "PROJ-12", "body": "Fixed in #42"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "Commented on PROJ-12: "+server.URL+"/browse/PROJ-12", out)
+		assert.Equal(t, map[string]string{"body": "Fixed in #42"}, comment)
+	})
+
+	t.Run("评论需要内容", func(t *testing.T) {
+		_, err := Tracker(context.Background(), jira, nil, json.RawMessage(`{"action": "comment", "issue": "PROJ-12"}`))
+		assert.ErrorContains(t, err, "body is required")
+	})
+
+	t.Run("API 错误", func(t *testing.T) {
+		_, err := Tracker(context.Background(), jira, nil, json.RawMessage(`{"action": "get", "issue": "PROJ-99"}`))
+		assert.EqualError(t, err, "Jira API error 404: Issue does not exist or you do not have permission to see it.")
+	})
+}
+
+func TestTrackerLinear(t *testing.T) {
+	var mutation map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "lin_api_key", r.Header.Get("Authorization"))
+		data, _ := io.ReadAll(r.Body)
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.Unmarshal(data, &req))
+		if req.Variables["body"] != nil {
+			mutation = req.Variables
+			w.Write([]byte(`{"data": {"commentCreate": {"success": true, "comment": {"url": "https://linear.app/acme/issue/ENG-7#comment-1"}}}}`))
+			return
+		}
+		assert.Equal(t, "ENG-7", req.Variables["id"])
+		w.Write([]byte(`{"data": {"issue": {
+			"id": "uuid-7", "identifier": "ENG-7", "title": "Fix login", "description": "Users must be able to log in.",
+			"url": "https://linear.app/acme/issue/ENG-7/fix-login", "state": {"name": "Todo"},
+			"creator": {"name": "Alice"}, "assignee": {"name": "Bob"},
+			"comments": {"nodes": [{"body": "Repro attached", "createdAt": "2024-05-02T08:00:00Z", "user": {"name": "Carol"}}]}
+		}}}`))
+	}))
+	defer server.Close()
+	linear := &Linear{URL: server.URL, Token: func() string { return "lin_api_key" }}
+
+	t.Run("获取 issue", func(t *testing.T) {
+		out, err := Tracker(context.Background(), nil, linear, json.RawMessage(`{"action": "get", "issue": "https://linear.app/acme/issue/ENG-7/fix-login"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "Linear issue ENG-7: Fix login (Todo, reported by Alice, assigned to Bob)\nhttps://linear.app/acme/issue/ENG-7/fix-login\n\nUsers must be able to log in.\n\n--- Comment by Carol on 2024-05-02\nRepro attached\n", out)
+	})
+
+	t.Run("评论使用 issue 的内部 ID", func(t *testing.T) {
+		out, err := Tracker(context.Background(), nil, linear, json.RawMessage(`{"action": "comment", "issue": "ENG-7", "body": "Done"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "Commented on ENG-7: https://linear.app/acme/issue/ENG-7#comment-1", out)
+		assert.Equal(t, "uuid-7", mutation["issueId"])
+		assert.Equal(t, "Done", mutation["body"])
+	})
+}
+
+// FuzzParseTrackerRef 检查解析出的总是启用的跟踪系统和合法的键
+func FuzzParseTrackerRef(f *testing.F) {
+	for _, ref := range []string{"PROJ-123", "eng-42", "https://acme.atlassian.net/browse/PROJ-7", "https://linear.app/acme/issue/ENG-9/title",
+		"https://linear.app.evil.com/acme/issue/ENG-9", "http://%zz", " PROJ-1 ", "#12", "PROJ-"} {
+		f.Add(ref, "")
+		f.Add(ref, "linear")
+	}
+
+	f.Fuzz(func(t *testing.T, ref, tracker string) {
+		kind, key, err := parseTrackerRef(ref, tracker, true, true)
+		if err != nil {
+			return
+		}
+		assert.Contains(t, []string{"jira", "linear"}, kind)
+		assert.Regexp(t, `^[A-Z][A-Z0-9_]*-\d+$`, key)
+	})
+}
@@ -1,22 +1,48 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/invopop/jsonschema"
 )
 
+// ErrDenied 表示工具调用因策略或限制被拒绝，没有执行
+var ErrDenied = errors.New("denied")
+
 // ToolDefinition 定义了一个工具的完整信息
 type ToolDefinition struct {
 	Name        string                         `json:"name"`
 	Description string                         `json:"description"`
 	InputSchema anthropic.ToolInputSchemaParam `json:"input_schema"`
-	Function    func(input json.RawMessage) (string, error)
+	// Function 执行工具，返回结构化的结果，见 ToolResult。ctx 在调用被取消时结束，
+	// 工具应随之停止，包括终止它启动的进程
+	Function func(ctx context.Context, input json.RawMessage) ToolResult
+	// Stream 可选，与 Function 相同，但执行过程中把输出实时写入 output，
+	// 供终端在长时间运行的命令结束前显示输出
+	Stream func(ctx context.Context, input json.RawMessage, output io.Writer) ToolResult `json:"-"`
+	// ReadOnly 表示工具不会修改任何状态，可以安全地重复执行
+	ReadOnly bool `json:"-"`
+	// Category 和 Permissions 是注册时的元数据，见 Registry
+	Category    Category     `json:"-"`
+	Permissions []Permission `json:"-"`
 }
 
-// GenerateSchema 为任何Go结构体生成JSON Schema
+// schemas 按类型缓存 GenerateSchema 的结果，每个类型只反射一次
+var schemas sync.Map
+
+// GenerateSchema 为任何Go结构体生成JSON Schema。结果按类型缓存并在工具之间共享，调用方不应修改
 func GenerateSchema[T any]() anthropic.ToolInputSchemaParam {
+	key := reflect.TypeOf((*T)(nil)).Elem()
+	if cached, ok := schemas.Load(key); ok {
+		return cached.(anthropic.ToolInputSchemaParam)
+	}
+
 	reflector := jsonschema.Reflector{
 		AllowAdditionalProperties: false,
 		DoNotReference:            true,
@@ -25,8 +51,10 @@ func GenerateSchema[T any]() anthropic.ToolInputSchemaParam {
 
 	schema := reflector.Reflect(v)
 
-	return anthropic.ToolInputSchemaParam{
+	generated := anthropic.ToolInputSchemaParam{
 		Properties: schema.Properties,
 		Required:   schema.Required,
 	}
+	schemas.Store(key, generated)
+	return generated
 }
@@ -0,0 +1,226 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// vulncheckTimeout 是一次扫描的最长时间，第一次扫描需要下载漏洞数据库
+const vulncheckTimeout = 5 * time.Minute
+
+// VulncheckInput 定义 vulncheck 工具的输入参数
+type VulncheckInput struct {
+	Package string `json:"package,omitempty" jsonschema_description:"The package pattern to scan, default ./..."`
+}
+
+// Vulncheck 用 govulncheck 扫描模块中的已知漏洞
+type Vulncheck struct {
+	// Command 是 govulncheck 命令，为空时为 govulncheck
+	Command string
+}
+
+func init() {
+	Register(Spec{Name: "vulncheck", Category: CategoryCode, ReadOnly: true, Permissions: []Permission{PermissionRead, PermissionExec, PermissionNetwork},
+		New: func(env Env) (ToolDefinition, bool) {
+			return VulncheckTool(env.Workspace, &Vulncheck{}), env.GoModule
+		}})
+}
+
+// VulncheckTool 返回扫描工作区 w 中已知漏洞的工具定义
+func VulncheckTool(w *Workspace, v *Vulncheck) ToolDefinition {
+	return ToolDefinition{
+		Name: "vulncheck",
+		Description: "Scan the Go module for known vulnerabilities with govulncheck. Reports the vulnerabilities the code actually " +
+			"calls, with the affected module, the version that fixes it and the call paths, and counts those in dependencies " +
+			"the code does not call. Fix them by upgrading the module, e.g. go get module@fixed-version && go mod tidy.",
+		InputSchema: GenerateSchema[VulncheckInput](),
+		ReadOnly:    true,
+		Function: func(ctx context.Context, input json.RawMessage) ToolResult {
+			return Result(w.Vulncheck(ctx, v, input))
+		},
+	}
+}
+
+// govulncheck -json 输出的消息，只解析用到的字段
+type vulnMessage struct {
+	OSV *struct {
+		ID      string   `json:"id"`
+		Summary string   `json:"summary"`
+		Aliases []string `json:"aliases"`
+	} `json:"osv"`
+	Finding *vulnFinding `json:"finding"`
+}
+
+type vulnFinding struct {
+	OSV          string      `json:"osv"`
+	FixedVersion string      `json:"fixed_version"`
+	Trace        []vulnFrame `json:"trace"`
+}
+
+type vulnFrame struct {
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	Package  string `json:"package"`
+	Function string `json:"function"`
+	Receiver string `json:"receiver"`
+	Position *struct {
+		Filename string `json:"filename"`
+		Line     int    `json:"line"`
+	} `json:"position"`
+}
+
+// symbol 返回帧所在的符号，例如 net/http.(*Client).Do
+func (f vulnFrame) symbol() string {
+	if f.Function == "" {
+		return f.Package
+	}
+	if f.Receiver != "" {
+		return f.Package + "." + f.Receiver + "." + f.Function
+	}
+	return f.Package + "." + f.Function
+}
+
+// Vulncheck 扫描漏洞并返回报告
+func (w *Workspace) Vulncheck(ctx context.Context, v *Vulncheck, input json.RawMessage) (string, error) {
+	var params VulncheckInput
+	if err := json.Unmarshal(input, &params); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+	pkg := params.Package
+	if pkg == "" {
+		pkg = "./..."
+	}
+	if strings.HasPrefix(pkg, "-") {
+		return "", invalidInput("package %q must be a package pattern such as ./... or ./internal/cache", pkg)
+	}
+	command := v.Command
+	if command == "" {
+		command = "govulncheck"
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return "", fmt.Errorf("govulncheck is not installed; install it with go install golang.org/x/vuln/cmd/govulncheck@latest")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vulncheckTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command, "-json", pkg)
+	killOnCancel(cmd)
+	cmd.Dir = w.Root
+	var stdout bytes.Buffer
+	stderr := &tailBuffer{limit: maxCommandOutput}
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	// -json 模式下发现漏洞时退出码仍为 0，非 0 表示扫描失败
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("govulncheck timed out after %s", vulncheckTimeout)
+		}
+		return "", fmt.Errorf("govulncheck failed (%w):\n%s", err, stderr)
+	}
+	return w.formatVulns(&stdout)
+}
+
+// formatVulns 汇总 govulncheck -json 的输出：被调用的漏洞列出调用路径，其余只计数
+func (w *Workspace) formatVulns(r io.Reader) (string, error) {
+	summaries := map[string]string{}
+	aliases := map[string][]string{}
+	// 每个漏洞最详细的发现：调用了符号、导入了包，或者只依赖了模块
+	called := map[string][]vulnFinding{}
+	imported := map[string]bool{}
+	required := map[string]bool{}
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg vulnMessage
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("failed to parse govulncheck output: %w", err)
+		}
+		if msg.OSV != nil {
+			summaries[msg.OSV.ID] = msg.OSV.Summary
+			aliases[msg.OSV.ID] = msg.OSV.Aliases
+		}
+		f := msg.Finding
+		if f == nil || len(f.Trace) == 0 {
+			continue
+		}
+		switch {
+		case f.Trace[0].Function != "":
+			called[f.OSV] = append(called[f.OSV], *f)
+		case f.Trace[0].Package != "":
+			imported[f.OSV] = true
+		default:
+			required[f.OSV] = true
+		}
+	}
+	for id := range called {
+		delete(imported, id)
+		delete(required, id)
+	}
+	for id := range imported {
+		delete(required, id)
+	}
+
+	if len(called) == 0 {
+		if len(imported)+len(required) == 0 {
+			return "No vulnerabilities found.\n", nil
+		}
+		return fmt.Sprintf("No vulnerable code is called. %d vulnerabilities are in imported packages and %d in required modules the code does not call.\n", len(imported), len(required)), nil
+	}
+
+	ids := make([]string, 0, len(called))
+	for id := range called {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d vulnerabilities are called by the code:\n", len(ids))
+	for _, id := range ids {
+		findings := called[id]
+		vulnerable := findings[0].Trace[0]
+		name := id
+		if len(aliases[id]) > 0 {
+			name += " (" + strings.Join(aliases[id], ", ") + ")"
+		}
+		fmt.Fprintf(&b, "\n%s: %s\n", name, summaries[id])
+		fixed := findings[0].FixedVersion
+		if fixed == "" {
+			fixed = "no fixed version yet"
+		}
+		fmt.Fprintf(&b, "  module: %s@%s, fixed in %s\n", vulnerable.Module, vulnerable.Version, fixed)
+		seen := map[string]bool{}
+		for _, f := range findings {
+			path := w.vulnCallPath(f.Trace)
+			if !seen[path] {
+				seen[path] = true
+				fmt.Fprintf(&b, "  called: %s\n", path)
+			}
+		}
+	}
+	if n := len(imported) + len(required); n > 0 {
+		fmt.Fprintf(&b, "\n%d more vulnerabilities are in dependencies the code does not call.\n", n)
+	}
+	return b.String(), nil
+}
+
+// vulnCallPath 把调用栈写成从项目代码到漏洞符号的一行，栈的最后一帧是项目中的调用位置
+func (w *Workspace) vulnCallPath(trace []vulnFrame) string {
+	var parts []string
+	for i := len(trace) - 1; i >= 0; i-- {
+		part := trace[i].symbol()
+		if i == len(trace)-1 && trace[i].Position != nil {
+			part = fmt.Sprintf("%s:%d %s", w.relative(trace[i].Position.Filename), trace[i].Position.Line, part)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " -> ")
+}
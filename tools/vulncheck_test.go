@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vulncheckOutput = `{"config":{"protocol_version":"v1.0.0","scanner_name":"govulncheck"}}
+{"progress":{"message":"Scanning your code and 42 packages across 3 dependent modules for known vulnerabilities..."}}
+{"osv":{"id":"GO-2024-0001","summary":"Denial of service in golang.org/x/net/html","aliases":["CVE-2024-0001"]}}
+{"osv":{"id":"GO-2024-0002","summary":"Header smuggling in golang.org/x/net/http2"}}
+{"finding":{"osv":"GO-2024-0001","fixed_version":"v0.23.0","trace":[{"module":"golang.org/x/net","version":"v0.17.0"}]}}
+{"finding":{"osv":"GO-2024-0001","fixed_version":"v0.23.0","trace":[{"module":"golang.org/x/net","version":"v0.17.0","package":"golang.org/x/net/html"}]}}
+{"finding":{"osv":"GO-2024-0001","fixed_version":"v0.23.0","trace":[{"module":"golang.org/x/net","version":"v0.17.0","package":"golang.org/x/net/html","function":"Parse"},{"module":"example.com/m","package":"example.com/m/page","function":"Render","position":{"filename":"ROOT/page/render.go","line":31}}]}}
+{"finding":{"osv":"GO-2024-0002","fixed_version":"v0.25.0","trace":[{"module":"golang.org/x/net","version":"v0.17.0","package":"golang.org/x/net/http2"}]}}
+`
+
+func TestVulncheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("使用 sh 语法")
+	}
+	root := t.TempDir()
+	output := filepath.Join(t.TempDir(), "output")
+	require.NoError(t, os.WriteFile(output, []byte(strings.ReplaceAll(vulncheckOutput, "ROOT", root)), 0644))
+	command := filepath.Join(t.TempDir(), "govulncheck")
+	require.NoError(t, os.WriteFile(command, []byte("#!/bin/sh\ncat "+output+"\n"), 0755))
+	w := &Workspace{Root: root}
+
+	out, err := w.Vulncheck(context.Background(), &Vulncheck{Command: command}, json.RawMessage(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, `1 vulnerabilities are called by the code:
+
+GO-2024-0001 (CVE-2024-0001): Denial of service in golang.org/x/net/html
+  module: golang.org/x/net@v0.17.0, fixed in v0.23.0
+  called: page/render.go:31 example.com/m/page.Render -> golang.org/x/net/html.Parse
+
+1 more vulnerabilities are in dependencies the code does not call.
+`, out)
+
+	t.Run("没有被调用的漏洞", func(t *testing.T) {
+		out, err := w.formatVulns(strings.NewReader(`{"finding":{"osv":"GO-2024-0002","trace":[{"module":"golang.org/x/net","package":"golang.org/x/net/http2"}]}}`))
+		require.NoError(t, err)
+		assert.Equal(t, "No vulnerable code is called. 1 vulnerabilities are in imported packages and 0 in required modules the code does not call.\n", out)
+	})
+
+	t.Run("没有安装 govulncheck", func(t *testing.T) {
+		_, err := w.Vulncheck(context.Background(), &Vulncheck{Command: filepath.Join(root, "missing")}, json.RawMessage(`{}`))
+		assert.ErrorContains(t, err, "go install golang.org/x/vuln/cmd/govulncheck@latest")
+	})
+}
@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinkHops 限制跟随悬空符号链接的次数，防止链接成环
+const maxSymlinkHops = 40
+
+// Workspace 表示文件工具可以访问的目录，所有路径都相对于 Root 解析
+type Workspace struct {
+	Root string
+	// MaxReadBytes 和 MaxWriteBytes 限制读取和写入的文件大小，0 表示不限制
+	MaxReadBytes  int64
+	MaxWriteBytes int64
+	// Reads 记录读过的文件，未变化的文件再次读取时只返回提示，nil 表示不缓存
+	Reads *ReadCache
+	// HostShell 是在主机上执行命令的 shell，取值见 HostShells，留空时使用平台默认的 shell
+	HostShell string
+	// Remote 不为 nil 时文件和命令都在远程机器上，Root 不再使用，见 Remote
+	Remote *Remote
+}
+
+// Resolve 将相对路径解析为工作区内的绝对路径。绝对路径、用 ../ 跳出 Root 的路径，
+// 以及经过符号链接（包括指向不存在文件的链接）最终落在 Root 之外的路径都会被拒绝
+func (w *Workspace) Resolve(path string) (string, error) {
+	if path == "" {
+		return "", invalidInput("path must not be empty")
+	}
+	if strings.ContainsRune(path, 0) {
+		return "", invalidInput("path %q contains a NUL byte", path)
+	}
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" {
+		return "", invalidInput("path %s must be relative to the workspace", path)
+	}
+	root, err := filepath.Abs(w.Root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace root: %w", err)
+	}
+	resolved := filepath.Join(root, path)
+	if !within(root, resolved) {
+		return "", invalidInput("path %s is outside the workspace", path)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workspace root: %w", err)
+	}
+	real, err := evalExisting(resolved)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	if !within(realRoot, real) {
+		return "", invalidInput("path %s is outside the workspace (it links to %s)", path, real)
+	}
+	return resolved, nil
+}
+
+func init() {
+	Register(Spec{Name: "read_file", Category: CategoryFiles, ReadOnly: true, Permissions: []Permission{PermissionRead},
+		New: func(env Env) (ToolDefinition, bool) { return ReadFileTool(env.Workspace), true }})
+	Register(Spec{Name: "edit_file", Category: CategoryFiles, Permissions: []Permission{PermissionRead, PermissionWrite},
+		New: func(env Env) (ToolDefinition, bool) { return EditFileTool(env.Workspace), true }})
+}
+
+// Tools 返回绑定到该工作区的全部文件工具
+func (w *Workspace) Tools() []ToolDefinition {
+	return []ToolDefinition{ReadFileTool(w), EditFileTool(w), GrepTool(w), GlobTool(w)}
+}
+
+// checkSize 在 size 超过 limit 时返回说明原因的错误，limit 为 0 表示不限制
+func checkSize(path string, size, limit int64, action string) error {
+	if limit > 0 && size > limit {
+		return fmt.Errorf("file %s is %s, over the %s %s limit", path, formatBytes(size), formatBytes(limit), action)
+	}
+	return nil
+}
+
+// formatBytes 以易读的单位显示字节数
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// within 判断 path 是否为 root 本身或位于 root 之下，两者都必须是干净的绝对路径
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// evalExisting 解析 path 中所有符号链接后的真实路径。path 的末尾部分可以不存在（例如要新建的文件），
+// 但悬空的符号链接会被继续跟随，因为写入它会在链接目标处创建文件
+func evalExisting(path string) (string, error) {
+	var missing []string
+	for hops := 0; ; {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+			if hops++; hops > maxSymlinkHops {
+				return "", fmt.Errorf("too many levels of symbolic links")
+			}
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			path = target
+			continue
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceResolve(t *testing.T) {
+	root := t.TempDir()
+	ws := &Workspace{Root: root}
+
+	t.Run("相对路径", func(t *testing.T) {
+		path, err := ws.Resolve("a/b.txt")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "a", "b.txt"), path)
+
+		path, err = ws.Resolve("a/../b.txt")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "b.txt"), path)
+
+		path, err = ws.Resolve(".")
+		require.NoError(t, err)
+		assert.Equal(t, root, path)
+	})
+
+	t.Run("拒绝绝对路径和越界路径", func(t *testing.T) {
+		for _, path := range []string{"", "a\x00b", "/etc/passwd", filepath.Join(root, "a.txt"), "..", "../x", "a/../../x"} {
+			_, err := ws.Resolve(path)
+			assert.Error(t, err, path)
+		}
+	})
+}
+
+func TestWorkspaceSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("package main"), 0644))
+	ws := &Workspace{Root: root}
+
+	link := func(target, name string) {
+		t.Helper()
+		require.NoError(t, os.Symlink(target, filepath.Join(root, name)))
+	}
+	link(outside, "out")                                     // 指向外部的目录链接
+	link(filepath.Join(outside, "secret.txt"), "secret.txt") // 指向外部的文件链接
+	link(filepath.Join(outside, "missing.txt"), "dangling")  // 指向外部不存在文件的悬空链接
+	link("../"+filepath.Base(outside), "relative")           // 用相对路径跳出的链接
+	link("src", "code")                                      // 工作区内部的链接
+	link("loop", "loop")                                     // 自身成环的链接
+
+	t.Run("拒绝跳出工作区的链接", func(t *testing.T) {
+		for _, path := range []string{"out", "out/secret.txt", "out/new.txt", "out/a/b/new.txt", "secret.txt", "dangling", "relative/secret.txt"} {
+			_, err := ws.Resolve(path)
+			assert.ErrorContains(t, err, "outside the workspace", path)
+		}
+	})
+
+	t.Run("成环的链接", func(t *testing.T) {
+		_, err := ws.Resolve("loop")
+		assert.Error(t, err)
+	})
+
+	t.Run("允许工作区内部的链接和新文件", func(t *testing.T) {
+		for _, path := range []string{"code/main.go", "code/new.go", "src/new/dir/file.go", "code/../src/main.go"} {
+			resolved, err := ws.Resolve(path)
+			require.NoError(t, err, path)
+			assert.Equal(t, filepath.Join(root, path), resolved)
+		}
+	})
+
+	t.Run("工作区根目录本身是链接", func(t *testing.T) {
+		alias := filepath.Join(t.TempDir(), "alias")
+		require.NoError(t, os.Symlink(root, alias))
+		_, err := (&Workspace{Root: alias}).Resolve("src/main.go")
+		assert.NoError(t, err)
+		_, err = (&Workspace{Root: alias}).Resolve("out/secret.txt")
+		assert.Error(t, err)
+	})
+
+	t.Run("编辑悬空链接不会在外部创建文件", func(t *testing.T) {
+		_, err := ws.EditFile(context.Background(), []byte(`{"path":"dangling","old_str":"","new_str":"pwned"}`))
+		assert.Error(t, err)
+		_, err = os.Stat(filepath.Join(outside, "missing.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestWorkspaceTools(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("secret"), 0644))
+	root := t.TempDir()
+	ws := &Workspace{Root: root}
+
+	for _, tool := range ws.Tools() {
+		input, err := json.Marshal(map[string]string{"path": outside, "new_str": "x"})
+		require.NoError(t, err)
+		_, err = tool.Function(context.Background(), input).Output()
+		assert.ErrorContains(t, err, "relative to the workspace", tool.Name)
+
+		input, err = json.Marshal(map[string]string{"path": "../" + filepath.Base(root) + "/../x.txt", "new_str": "x"})
+		require.NoError(t, err)
+		_, err = tool.Function(context.Background(), input).Output()
+		assert.ErrorContains(t, err, "outside the workspace", tool.Name)
+	}
+
+	content, err := os.ReadFile(outside)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(content))
+}
+
+func TestWorkspaceLimits(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "small.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "big.txt"), make([]byte, 3000), 0644))
+	ws := &Workspace{Root: root, MaxReadBytes: 2048, MaxWriteBytes: 16}
+	call := func(fn func(context.Context, json.RawMessage) (string, error), input map[string]string) error {
+		data, err := json.Marshal(input)
+		require.NoError(t, err)
+		_, err = fn(context.Background(), data)
+		return err
+	}
+
+	assert.NoError(t, call(ws.ReadFile, map[string]string{"path": "small.txt"}))
+	assert.ErrorContains(t, call(ws.ReadFile, map[string]string{"path": "big.txt"}), "2.9 KiB, over the 2.0 KiB read limit")
+
+	assert.ErrorContains(t, call(ws.EditFile, map[string]string{"path": "big.txt", "old_str": "a", "new_str": "b"}), "write limit")
+	assert.ErrorContains(t, call(ws.EditFile, map[string]string{"path": "new.txt", "new_str": "this is more than sixteen bytes"}), "write limit")
+	assert.ErrorContains(t, call(ws.EditFile, map[string]string{"path": "small.txt", "old_str": "hello", "new_str": "hello, this is too long"}), "edited file small.txt")
+	assert.NoError(t, call(ws.EditFile, map[string]string{"path": "small.txt", "old_str": "hello", "new_str": "hi"}))
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatBytes(512))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "500.0 MiB", formatBytes(500<<20))
+}
+
+// fuzzWorkspace 创建 dir/ws 工作区和工作区外的 dir/outside，工作区中有指向外面的符号链接
+func fuzzWorkspace(f *testing.F) (dir string, w *Workspace) {
+	dir = f.TempDir()
+	root := filepath.Join(dir, "ws")
+	require.NoError(f, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	require.NoError(f, os.MkdirAll(filepath.Join(dir, "outside"), 0755))
+	require.NoError(f, os.WriteFile(filepath.Join(root, "a.go"), []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(f, os.WriteFile(filepath.Join(root, "sub", "b.txt"), []byte("hello\nworld\n"), 0644))
+	require.NoError(f, os.WriteFile(filepath.Join(dir, "outside", "secret.txt"), []byte("secret\n"), 0644))
+	if err := os.Symlink(filepath.Join(dir, "outside"), filepath.Join(root, "link")); err != nil {
+		f.Logf("无法创建符号链接: %v", err)
+	}
+	return dir, &Workspace{Root: root}
+}
+
+// FuzzResolve 检查解析成功的路径总在工作区内，符号链接也不能指向外面
+func FuzzResolve(f *testing.F) {
+	for _, path := range []string{"a.go", "sub/b.txt", "new/file.go", "../outside/secret.txt", "sub/../../outside", "/etc/passwd",
+		"link/secret.txt", "link", "a\x00b", "", ".", `C:\Windows`, "sub/./../a.go", "...", "sub//b.txt"} {
+		f.Add(path)
+	}
+	_, w := fuzzWorkspace(f)
+	realRoot, err := filepath.EvalSymlinks(w.Root)
+	require.NoError(f, err)
+
+	f.Fuzz(func(t *testing.T, path string) {
+		resolved, err := w.Resolve(path)
+		if err != nil {
+			return
+		}
+		assert.True(t, within(w.Root, resolved), "%q 解析为 %s", path, resolved)
+		real, err := evalExisting(resolved)
+		if err == nil {
+			assert.True(t, within(realRoot, real), "%q 指向 %s", path, real)
+		}
+	})
+}
+
+// FuzzFileTools 把任意输入交给文件工具，它们不能 panic，也不能改动工作区外的文件
+func FuzzFileTools(f *testing.F) {
+	f.Add(uint8(0), []byte(`{"path": "a.go"}`))
+	f.Add(uint8(0), []byte(`{"path": "link/secret.txt"}`))
+	f.Add(uint8(1), []byte(`{"path": "a.go", "old_str": "A()", "new_str": "B()"}`))
+	f.Add(uint8(1), []byte(`{"path": "link/new.txt", "old_str": "", "new_str": "x"}`))
+	f.Add(uint8(1), []byte(`{"path": "../outside/secret.txt", "old_str": "secret", "new_str": "x"}`))
+	f.Add(uint8(2), []byte(`{"pattern": "func", "path": "."}`))
+	f.Add(uint8(2), []byte(`{"pattern": "(", "path": "../outside"}`))
+	f.Add(uint8(3), []byte(`{"pattern": "**/*.go"}`))
+	f.Add(uint8(3), []byte(`{"pattern": "../*"}`))
+	f.Add(uint8(0), []byte(`not json`))
+	f.Add(uint8(1), []byte(`{"path": 1}`))
+	dir, w := fuzzWorkspace(f)
+	defs := []ToolDefinition{ReadFileTool(w), EditFileTool(w), GrepTool(w), GlobTool(w)}
+
+	f.Fuzz(func(t *testing.T, tool uint8, input []byte) {
+		def := defs[int(tool)%len(defs)]
+		def.Function(context.Background(), input)
+
+		entries, err := os.ReadDir(filepath.Join(dir, "outside"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "%s %s 在工作区外创建了文件", def.Name, input)
+		data, err := os.ReadFile(filepath.Join(dir, "outside", "secret.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "secret\n", string(data), "%s %s 修改了工作区外的文件", def.Name, input)
+		entries, err = os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "%s %s 在工作区外创建了文件", def.Name, input)
+	})
+}
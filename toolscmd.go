@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+)
+
+// runToolsCommand lists tools, enables/disables one for the rest of the
+// session, or shows per-tool stats. The tool list sent to the provider is
+// rebuilt from the updated config.
+func runToolsCommand(a *Agent, args []string) error {
+	if len(args) > 0 && args[0] == "stats" {
+		return runToolStatsCommand(a, args[1:])
+	}
+	if len(args) == 0 {
+		for _, tool := range builtinTools() {
+			status := theme.Success(fmt.Sprintf("%-3s", i18n.T("on")))
+			if a.config.IsToolDisabled(tool.Name) {
+				status = theme.Muted(fmt.Sprintf("%-3s", i18n.T("off")))
+			}
+			fmt.Printf("  %s %-12s %s\n", status, tool.Name, truncate(firstLine(tool.Description), 60))
+		}
+		return nil
+	}
+	if len(args) != 2 || (args[0] != "enable" && args[0] != "disable") {
+		return fmt.Errorf("usage: /tools [enable|disable NAME | stats [calls|failures|latency]]")
+	}
+
+	name := args[1]
+	if _, ok := tools.Default.Lookup(name); !ok && !isCustomTool(a.config, name) {
+		return fmt.Errorf("unknown tool %s", name)
+	}
+
+	cfg := *a.config
+	cfg.Tools.Disabled = without(cfg.Tools.Disabled, name)
+	if args[0] == "disable" {
+		cfg.Tools.Disabled = append(cfg.Tools.Disabled, name)
+	} else if len(cfg.Tools.Allowed) > 0 && cfg.IsToolDisabled(name) {
+		cfg.Tools.Allowed = append(append([]string{}, cfg.Tools.Allowed...), name)
+	}
+
+	tools, err := enabledTools(&cfg, nil)
+	if err != nil {
+		return err
+	}
+	a.config = &cfg
+	a.setTools(tools)
+	msg := "Tool %s enabled for this session (use `agent config set tools.disabled` to keep it)"
+	if args[0] == "disable" {
+		msg = "Tool %s disabled for this session (use `agent config set tools.disabled` to keep it)"
+	}
+	fmt.Println(i18n.Sprintf(msg, name))
+	return nil
+}
+
+// without returns a copy of list with every occurrence of s removed
+func without(list []string, s string) []string {
+	out := []string{}
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolsCommand(t *testing.T) {
+	chdir(t, t.TempDir())
+	provider := &mockProvider{responses: []*Response{{Content: "ok"}}}
+	agent := NewAgent(provider, nil, builtinTools())
+
+	t.Run("列出工具状态", func(t *testing.T) {
+		out := captureStdout(func() {
+			_, err := agent.handleCommand("/tools")
+			require.NoError(t, err)
+		})
+		assert.Regexp(t, `on\s*(\x1b\[0m)?\s+edit_file`, out)
+	})
+
+	t.Run("禁用后不再发送给模型", func(t *testing.T) {
+		captureStdout(func() {
+			_, err := agent.handleCommand("/tools disable edit_file")
+			require.NoError(t, err)
+		})
+		require.NoError(t, agent.runTurn(context.Background(), "hi"))
+		var names []string
+		for _, tool := range agent.tools {
+			names = append(names, tool.Name)
+		}
+		assert.Equal(t, []string{"read_file", "grep", "glob", "shell"}, names)
+		assert.True(t, agent.config.IsToolDisabled("edit_file"))
+	})
+
+	t.Run("重新启用", func(t *testing.T) {
+		agent.config.Tools.Allowed = []string{"read_file"}
+		captureStdout(func() {
+			_, err := agent.handleCommand("/tools enable edit_file")
+			require.NoError(t, err)
+		})
+		assert.Len(t, agent.tools, 2, "只允许 read_file 和重新启用的 edit_file")
+	})
+
+	t.Run("参数错误", func(t *testing.T) {
+		_, err := agent.handleCommand("/tools disable nope")
+		assert.Error(t, err)
+		_, err = agent.handleCommand("/tools toggle edit_file")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// toolStat summarizes the calls of one tool across sessions
+type toolStat struct {
+	Name     string
+	Calls    int
+	Failures int
+	// TotalMS is the summed duration of the Timed calls; sessions saved
+	// before durations were recorded have none
+	TotalMS int64
+	Timed   int
+}
+
+// FailureRate is the share of calls that failed, from 0 to 1
+func (s toolStat) FailureRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Calls)
+}
+
+// Average is the mean duration of the timed calls
+func (s toolStat) Average() time.Duration {
+	if s.Timed == 0 {
+		return 0
+	}
+	return time.Duration(s.TotalMS/int64(s.Timed)) * time.Millisecond
+}
+
+// toolStatOrders are the ways `agent tools stats --sort` can rank tools
+var toolStatOrders = map[string]func(a, b toolStat) bool{
+	"calls":    func(a, b toolStat) bool { return a.Calls > b.Calls },
+	"failures": func(a, b toolStat) bool { return a.FailureRate() > b.FailureRate() },
+	"latency":  func(a, b toolStat) bool { return a.Average() > b.Average() },
+}
+
+// collectToolStats counts the tool results in sessions by tool, ranked by
+// order. Messages a fork copied from its parent session are skipped so the
+// calls are counted once. Calls to tools that do not exist count as failures,
+// which shows the names the model makes up.
+func collectToolStats(sessions []*Session, order string) []toolStat {
+	byName := map[string]*toolStat{}
+	for _, s := range sessions {
+		messages := s.Messages
+		if s.ParentID != "" && s.ForkedAt <= len(messages) {
+			messages = messages[s.ForkedAt:]
+		}
+		for _, msg := range messages {
+			if msg.ToolCall == nil || msg.ToolCall.Name == "" {
+				continue
+			}
+			stat := byName[msg.ToolCall.Name]
+			if stat == nil {
+				stat = &toolStat{Name: msg.ToolCall.Name}
+				byName[msg.ToolCall.Name] = stat
+			}
+			stat.Calls++
+			if toolResultFailed(msg) {
+				stat.Failures++
+			}
+			if msg.Meta != nil {
+				stat.TotalMS += msg.Meta.DurationMS
+				stat.Timed++
+			}
+		}
+	}
+
+	stats := make([]toolStat, 0, len(byName))
+	for _, stat := range byName {
+		stats = append(stats, *stat)
+	}
+	less := toolStatOrders[order]
+	if less == nil {
+		less = toolStatOrders["calls"]
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if less(stats[i], stats[j]) != less(stats[j], stats[i]) {
+			return less(stats[i], stats[j])
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// toolResultFailed reports whether msg is the result of a failed call.
+// Sessions saved before results were flagged are recognized by the text
+// agentlib.ToolErrorContent and ToolNotFoundContent produce.
+func toolResultFailed(msg Message) bool {
+	if msg.IsError {
+		return true
+	}
+	prefix := "Tool " + msg.ToolCall.Name + " "
+	return strings.HasPrefix(msg.Content, prefix+"failed: ") ||
+		strings.HasPrefix(msg.Content, prefix+"was denied: ") ||
+		msg.Content == prefix+"not found"
+}
+
+// writeToolStats prints the stats as a table, one row per tool
+func writeToolStats(w io.Writer, stats []toolStat) error {
+	if len(stats) == 0 {
+		fmt.Fprintln(w, "No tool calls in the saved sessions")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tCALLS\tFAILED\tFAILURE RATE\tAVG TIME")
+	for _, s := range stats {
+		average := "-"
+		if s.Timed > 0 {
+			average = s.Average().String()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%s\n", s.Name, s.Calls, s.Failures, 100*s.FailureRate(), average)
+	}
+	return tw.Flush()
+}
+
+// runToolStatsCommand shows /tools stats for the saved sessions and the live one
+func runToolStatsCommand(a *Agent, args []string) error {
+	order := "calls"
+	if len(args) > 0 {
+		order = args[0]
+	}
+	if _, ok := toolStatOrders[order]; !ok || len(args) > 1 {
+		return fmt.Errorf("usage: /tools stats [calls|failures|latency]")
+	}
+	sessions, err := a.searchableSessions()
+	if err != nil {
+		return err
+	}
+	return writeToolStats(os.Stdout, collectToolStats(sessions, order))
+}
+
+func newToolStatsCommand() *cobra.Command {
+	var order string
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show how often each tool was called, how often it failed and how long it took, across saved sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := toolStatOrders[order]; !ok {
+				return fmt.Errorf("unknown sort order %q (calls, failures or latency)", order)
+			}
+			store, err := DefaultSessionStore()
+			if err != nil {
+				return err
+			}
+			sessions, err := store.List()
+			if err != nil {
+				return err
+			}
+			return writeToolStats(cmd.OutOrStdout(), collectToolStats(sessions, order))
+		},
+	}
+	cmd.Flags().StringVar(&order, "sort", "calls", "rank tools by calls, failures (failure rate) or latency (average time)")
+	return cmd
+}
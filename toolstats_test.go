@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"agent/pkg/message"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func toolStatSessions() []*Session {
+	result := func(name, content string, isError bool, ms int64) Message {
+		return Message{Role: "user", Content: content, IsError: isError, ToolCall: &ToolCall{ID: name, Name: name},
+			Meta: &message.Metadata{DurationMS: ms}}
+	}
+	parent := NewSession()
+	parent.Messages = []Message{
+		{Role: "user", Content: "look around"},
+		result("read_file", "package a", false, 10),
+		result("read_file", "no such file", true, 30),
+		result("bash", "ok", false, 900),
+	}
+	fork, _ := parent.Fork(3)
+	fork.Messages = append(fork.Messages,
+		// 记录 is_error 之前保存的会话按文本识别失败
+		Message{Role: "user", Content: "Tool grep failed: bad pattern", ToolCall: &ToolCall{ID: "g", Name: "grep"}},
+		result("read_file", "Tool read_file was denied: outside the workspace", false, 20),
+		result("open_file", "Tool open_file not found", true, 0),
+	)
+	return []*Session{fork, parent}
+}
+
+func TestCollectToolStats(t *testing.T) {
+	stats := collectToolStats(toolStatSessions(), "calls")
+	require.Len(t, stats, 4)
+	assert.Equal(t, toolStat{Name: "read_file", Calls: 3, Failures: 2, TotalMS: 60, Timed: 3}, stats[0], "分叉复制的消息只算一次")
+	assert.Equal(t, "20ms", stats[0].Average().String())
+	assert.Equal(t, []string{"bash", "grep", "open_file"}, []string{stats[1].Name, stats[2].Name, stats[3].Name})
+	assert.Equal(t, 1, stats[2].Failures)
+	assert.Zero(t, stats[2].Timed)
+
+	t.Run("按失败率和耗时排序", func(t *testing.T) {
+		byFailures := collectToolStats(toolStatSessions(), "failures")
+		assert.Equal(t, []string{"grep", "open_file", "read_file", "bash"},
+			[]string{byFailures[0].Name, byFailures[1].Name, byFailures[2].Name, byFailures[3].Name})
+		assert.Equal(t, "bash", collectToolStats(toolStatSessions(), "latency")[0].Name)
+	})
+
+	t.Run("表格", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeToolStats(&out, stats))
+		assert.Contains(t, out.String(), "TOOL       CALLS  FAILED  FAILURE RATE  AVG TIME\n")
+		assert.Contains(t, out.String(), "read_file  3      2       66.7%         20ms\n")
+		assert.Contains(t, out.String(), "grep       1      1       100.0%        -\n")
+	})
+}
+
+func TestToolStatsCommand(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		cmd := newToolStatsCommand()
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	assert.Contains(t, out, "No tool calls")
+
+	store, err := DefaultSessionStore()
+	require.NoError(t, err)
+	for _, s := range toolStatSessions() {
+		require.NoError(t, store.Save(s))
+	}
+	out, err = run("--sort", "latency")
+	require.NoError(t, err)
+	assert.Contains(t, out, "bash")
+
+	_, err = run("--sort", "name")
+	assert.ErrorContains(t, err, "unknown sort order")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"agent/i18n"
+	"agent/redact"
+	"agent/theme"
+)
+
+// streamRedactor masks secrets in streamed tool output before it is shown.
+// It passes on whole lines only, so that a secret split across writes is
+// still found, and holds back a private key block until its end line.
+type streamRedactor struct {
+	emit    func(chunk string)
+	pending string
+}
+
+func (r *streamRedactor) write(chunk string) {
+	r.pending += chunk
+	end := strings.LastIndexByte(r.pending, '\n') + 1
+	if end == 0 || strings.Count(r.pending[:end], "PRIVATE KEY-----")%2 == 1 {
+		return
+	}
+	r.emit(redact.String(r.pending[:end]))
+	r.pending = r.pending[end:]
+}
+
+// flush passes on what is left once the tool has finished
+func (r *streamRedactor) flush() {
+	if r.pending != "" {
+		r.emit(redact.String(r.pending))
+		r.pending = ""
+	}
+}
+
+// printToolOutput shows a tool's output in the terminal as it runs, under a
+// heading before the first chunk
+func (a *Agent) printToolOutput(call ToolCall, chunk string) {
+	if a.streamedCall != call.ID {
+		a.streamedCall = call.ID
+		fmt.Printf("%s: %s\n", theme.Tool(i18n.T("Tool Output")), call.Name)
+	}
+	fmt.Print(theme.Muted(chunk))
+	a.streamedLine = strings.HasSuffix(chunk, "\n")
+}
+
+// endStreamedOutput reports whether call's output was already streamed to
+// the terminal, ending its last line if needed
+func (a *Agent) endStreamedOutput(call *ToolCall) bool {
+	if call == nil || a.streamedCall != call.ID {
+		return false
+	}
+	a.streamedCall = ""
+	if !a.streamedLine {
+		fmt.Println()
+	}
+	return true
+}
+
+// lastLine returns the last line of s that is not blank
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			return strings.TrimSpace(lines[i])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"agent/config"
+	"agent/tools"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the agent's spans
+const tracerName = "agent"
+
+// tracer returns the tracer of the installed provider, a no-op one unless
+// tracing is enabled
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// tracingEnabled reports whether spans should be exported: when the config
+// or the standard OTEL_EXPORTER_OTLP_* variables give an endpoint
+func tracingEnabled(cfg config.Tracing) bool {
+	return cfg.Endpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// setupTracing installs a tracer provider exporting spans over OTLP/HTTP,
+// if tracing is enabled. The exporter also honors the other standard
+// OTEL_EXPORTER_OTLP_* variables, such as headers, and OTEL_SERVICE_NAME.
+func setupTracing(ctx context.Context, cfg config.Tracing) error {
+	if !tracingEnabled(cfg) {
+		return nil
+	}
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "code-editing-agent")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return err
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)))
+	return nil
+}
+
+// shutdownTracing flushes the spans not exported yet, giving up after a
+// few seconds so an unreachable collector does not hold up exiting
+func shutdownTracing() {
+	provider, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	provider.Shutdown(ctx)
+}
+
+// TracingMiddleware records each inference call as a span with the model and
+// token usage, following the OpenTelemetry GenAI conventions; system is the
+// provider, anthropic or openai
+func TracingMiddleware(system string) Middleware {
+	return func(next AIProvider) AIProvider {
+		return ProviderFunc(func(ctx context.Context, conversation []Message, tools []tools.ToolDefinition) (*Response, error) {
+			ctx, span := tracer().Start(ctx, "chat", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+				attribute.String("gen_ai.operation.name", "chat"),
+				attribute.String("gen_ai.system", system),
+				attribute.Int("agent.messages", len(conversation)),
+				attribute.Int("agent.tools", len(tools)),
+			))
+			defer span.End()
+
+			response, err := next.RunInference(ctx, conversation, tools)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return nil, err
+			}
+			span.SetName("chat " + response.Model)
+			span.SetAttributes(
+				attribute.String("gen_ai.response.model", response.Model),
+				attribute.Int64("gen_ai.usage.input_tokens", response.Usage.InputTokens),
+				attribute.Int64("gen_ai.usage.output_tokens", response.Usage.OutputTokens),
+				attribute.Int("agent.tool_calls", len(response.ToolCalls)),
+			)
+			return response, nil
+		})
+	}
+}
+
+// endSpan ends span, marking it failed if err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans 安装记录 span 的 tracer provider，测试结束时恢复原来的
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestTracing(t *testing.T) {
+	recorder := recordSpans(t)
+
+	agent := oneShotAgent()
+	agent.provider = Chain(agent.provider, TracingMiddleware("openai"))
+	agent.onEvent = func(AgentEvent) {}
+	require.NoError(t, agent.runTurn(context.Background(), "模块名是什么？"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+	names := []string{}
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	assert.Equal(t, []string{"chat gpt-4o", "execute_tool read_file", "chat gpt-4o", "agent.turn"}, names)
+
+	turn := spans[3]
+	for _, span := range spans[:3] {
+		assert.Equal(t, turn.SpanContext().SpanID(), span.Parent().SpanID(), "%s 是轮次的子 span", span.Name())
+	}
+
+	t.Run("推理调用记录模型和用量", func(t *testing.T) {
+		attrs := spanAttributes(spans[0])
+		assert.Equal(t, "openai", attrs["gen_ai.system"].AsString())
+		assert.Equal(t, "gpt-4o", attrs["gen_ai.response.model"].AsString())
+		assert.Equal(t, int64(100), attrs["gen_ai.usage.input_tokens"].AsInt64())
+		assert.Equal(t, int64(1), attrs["agent.tool_calls"].AsInt64())
+	})
+
+	t.Run("工具执行记录工具名", func(t *testing.T) {
+		attrs := spanAttributes(spans[1])
+		assert.Equal(t, "read_file", attrs["gen_ai.tool.name"].AsString())
+		assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	})
+
+	t.Run("轮次汇总步数和用量", func(t *testing.T) {
+		attrs := spanAttributes(turn)
+		assert.Equal(t, int64(2), attrs["agent.steps"].AsInt64())
+		assert.Equal(t, int64(300), attrs["gen_ai.usage.input_tokens"].AsInt64())
+		assert.Equal(t, int64(30), attrs["gen_ai.usage.output_tokens"].AsInt64())
+	})
+}
+
+func TestTracingErrors(t *testing.T) {
+	recorder := recordSpans(t)
+
+	agent := NewAgent(Chain(&mockProvider{}, TracingMiddleware("anthropic")), nil, builtinTools())
+	assert.Error(t, agent.runTurn(context.Background(), "你好"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	for _, span := range spans {
+		assert.Equal(t, codes.Error, span.Status().Code, span.Name())
+		assert.Equal(t, "mock provider: no more responses", span.Status().Description)
+	}
+}
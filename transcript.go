@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent/config"
+	"agent/redact"
+)
+
+// transcriptRecord is one line of a provider transcript: an HTTP exchange
+// with the provider, bodies as sent and received on the wire
+type transcriptRecord struct {
+	Time     time.Time       `json:"time"`
+	Session  string          `json:"session"`
+	Provider string          `json:"provider"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	// Status is the HTTP status code, 0 when no response arrived
+	Status     int             `json:"status"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// transcriptLog appends each provider request and response to
+// <dir>/<session>.jsonl, after masking secrets. Headers are not recorded,
+// so API keys never reach the files.
+type transcriptLog struct {
+	dir      string
+	provider string
+	// session returns the ID of the session the requests belong to
+	session func() string
+	logger  *slog.Logger
+	mu      sync.Mutex
+}
+
+// defaultTranscriptLog returns the transcript log configured by cfg, in the
+// agent config directory unless transcripts.dir is set; nil unless
+// transcripts.enabled is set
+func defaultTranscriptLog(cfg *config.Config, session func() string, logger *slog.Logger) (*transcriptLog, error) {
+	if !cfg.Transcripts.Enabled {
+		return nil, nil
+	}
+	l := &transcriptLog{provider: providerName(cfg), session: session, logger: logger}
+	if cfg.Transcripts.Dir != "" {
+		dir, err := cfg.ResolvePath(cfg.Transcripts.Dir)
+		if err != nil {
+			return nil, err
+		}
+		l.dir = dir
+		return l, nil
+	}
+	dir, err := agentConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	l.dir = filepath.Join(dir, "transcripts")
+	return l, nil
+}
+
+// httpExchange is one HTTP request to the provider and its response, with
+// the bodies exactly as sent and received
+type httpExchange struct {
+	Time     time.Time
+	Method   string
+	URL      string
+	Request  []byte
+	Status   int
+	Response []byte
+	Err      error
+	Duration time.Duration
+}
+
+// exchangeRecorder receives every HTTP exchange with the provider
+type exchangeRecorder interface {
+	recordExchange(exchange httpExchange)
+}
+
+// captureExchange sends req through next and hands the exchange to each
+// recorder. The response body is read in full and handed back unread.
+func captureExchange(req *http.Request, next func(*http.Request) (*http.Response, error), recorders []exchangeRecorder) (*http.Response, error) {
+	exchange := httpExchange{
+		Time:   time.Now().UTC(),
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		exchange.Request = body
+	}
+
+	start := time.Now()
+	resp, err := next(req)
+	if resp != nil && resp.Body != nil {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		exchange.Status = resp.StatusCode
+		exchange.Response = body
+		if readErr != nil && err == nil {
+			err = readErr
+		}
+	}
+	exchange.Duration = time.Since(start)
+	exchange.Err = err
+	for _, recorder := range recorders {
+		recorder.recordExchange(exchange)
+	}
+	return resp, err
+}
+
+// recordExchange appends the exchange to the session's transcript, masking
+// secrets; a failure to write is logged and never fails the request
+func (l *transcriptLog) recordExchange(exchange httpExchange) {
+	record := transcriptRecord{
+		Time:       exchange.Time,
+		Provider:   l.provider,
+		Method:     exchange.Method,
+		URL:        exchange.URL,
+		Request:    transcriptBody(exchange.Request),
+		Status:     exchange.Status,
+		Response:   transcriptBody(exchange.Response),
+		DurationMS: exchange.Duration.Milliseconds(),
+	}
+	if exchange.Err != nil {
+		record.Error = redact.String(exchange.Err.Error())
+	}
+	if l.session != nil {
+		record.Session = l.session()
+	}
+	if err := l.Append(record); err != nil {
+		l.logger.Warn("writing transcript failed", "error", err)
+	}
+}
+
+// transcriptBody masks secrets in an HTTP body, keeping it as JSON when it
+// is JSON and as a string otherwise
+func transcriptBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	masked := redact.String(string(body))
+	if json.Valid([]byte(masked)) {
+		return json.RawMessage(masked)
+	}
+	data, _ := json.Marshal(masked)
+	return data
+}
+
+// Append writes one record as a line of JSON to its session's file
+func (l *transcriptLog) Append(record transcriptRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	name := record.Session
+	if name == "" {
+		name = "no-session"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(l.dir, name+".jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sessionID returns the ID of the agent's session, empty before it has one
+func (a *Agent) sessionID() string {
+	if a.session == nil {
+		return ""
+	}
+	return a.session.ID
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscripts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o",
+			"choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "你好"}}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Provider = "openai"
+	cfg.BaseURL = server.URL
+	cfg.Transcripts = config.Transcripts{Enabled: true, Dir: dir}
+
+	agent := NewAgent(nil, nil, nil)
+	agent.onEvent = func(AgentEvent) {}
+	_, err := agent.applyConfig(cfg)
+	require.NoError(t, err)
+	require.NoError(t, agent.runTurn(context.Background(), "我的 key 是 sk-abcdefghijklmnopqrstuvwxyz123456"))
+
+	f, err := os.Open(filepath.Join(dir, agent.session.ID+".jsonl"))
+	require.NoError(t, err)
+	defer f.Close()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 1)
+	record := records[0]
+
+	assert.Equal(t, agent.session.ID, record["session"])
+	assert.Equal(t, "openai", record["provider"])
+	assert.Equal(t, "POST", record["method"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+
+	t.Run("请求正文按原样记录并脱敏", func(t *testing.T) {
+		request, ok := record["request"].(map[string]interface{})
+		require.True(t, ok, "请求正文是 JSON")
+		data, _ := json.Marshal(request["messages"])
+		assert.Contains(t, string(data), "[REDACTED:openai-key]")
+		assert.NotContains(t, string(data), "sk-abcdefghijklmnopqrstuvwxyz123456")
+	})
+
+	t.Run("记录响应正文且不影响解析", func(t *testing.T) {
+		response, ok := record["response"].(map[string]interface{})
+		require.True(t, ok, "响应正文是 JSON")
+		assert.Equal(t, "chatcmpl-1", response["id"])
+		last := agent.conversation[len(agent.conversation)-1]
+		assert.Equal(t, "你好", last.Content)
+	})
+}
+
+func TestTranscriptsDisabled(t *testing.T) {
+	transcripts, err := defaultTranscriptLog(config.Default(), nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, transcripts)
+}
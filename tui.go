@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"agent/diff"
+	"agent/i18n"
+	"agent/pkg/message"
+	"agent/theme"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+const (
+	tuiSidebarWidth = 32
+	tuiInputHeight  = 3
+	// maxSidebarEntries keeps the tool activity list bounded in long sessions
+	maxSidebarEntries = 200
+	// tuiTickInterval is how often relative times in the sidebar are updated
+	tuiTickInterval = 30 * time.Second
+)
+
+var (
+	tuiUserStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+	tuiAssistantStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+	tuiErrorStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	tuiMutedStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	tuiStatusStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Background(lipgloss.Color("4")).Padding(0, 1)
+	tuiStatusWarnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("3")).Padding(0, 1)
+	tuiSidebarStyle    = lipgloss.NewStyle().Border(lipgloss.NormalBorder(), false, false, false, true).BorderForeground(lipgloss.Color("8")).PaddingLeft(1)
+	tuiInputStyle      = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8"))
+)
+
+// agentEventMsg carries an agent event into the Bubble Tea update loop
+type agentEventMsg AgentEvent
+
+// turnDoneMsg is sent when a turn started from the TUI finishes
+type turnDoneMsg struct {
+	err error
+	// notSent is set when the message was held back before the turn started
+	notSent bool
+}
+
+// tuiQuestionMsg asks the user a yes/no question from a running turn; the
+// next line typed in the input box answers it
+type tuiQuestionMsg struct {
+	question string
+	answer   chan<- bool
+}
+
+// tuiTickMsg redraws the TUI so relative times stay current
+type tuiTickMsg time.Time
+
+// sidebarEntry is a line of the tool activity sidebar and when it happened
+type sidebarEntry struct {
+	text  string
+	style lipgloss.Style
+	at    time.Time
+}
+
+// tuiModel is the Bubble Tea model for `agent -tui`: a scrollable conversation
+// pane, an input box, a collapsible tool activity sidebar and a status bar.
+type tuiModel struct {
+	agent *Agent
+	// send delivers a message to the running program from another goroutine
+	send func(tea.Msg)
+
+	conversation viewport.Model
+	input        textarea.Model
+	markdown     *markdownRenderer
+	// markdownStyle is resolved before the program starts; querying the
+	// terminal background while Bubble Tea owns stdin would race with it
+	markdownStyle string
+
+	transcript  strings.Builder
+	sidebar     []sidebarEntry
+	showSidebar bool
+
+	stats sessionStats
+
+	busy     bool
+	activity string
+	cancel   context.CancelFunc
+	queued   []string
+	// question is waiting for the user's answer, nil when there is none
+	question *tuiQuestionMsg
+
+	width, height int
+}
+
+func newTUIModel(agent *Agent) *tuiModel {
+	input := textarea.New()
+	input.Placeholder = i18n.T("Message (Enter to send, Alt-Enter to steer, Ctrl-T sidebar, Ctrl-C cancel/quit)")
+	input.ShowLineNumbers = false
+	input.SetHeight(tuiInputHeight)
+	input.Focus()
+
+	m := &tuiModel{
+		agent:        agent,
+		conversation: viewport.New(80, 20),
+		input:        input,
+		showSidebar:  true,
+	}
+	for _, msg := range agent.Messages() {
+		m.appendMessage(msg)
+	}
+	return m
+}
+
+// runTUI runs the agent inside the full-screen terminal UI
+func runTUI(agent *Agent) error {
+	if !theme.Enabled() {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	m := newTUIModel(agent)
+	if agent.markdown != nil {
+		m.markdownStyle = theme.Current().Markdown
+		if m.markdownStyle == "auto" {
+			m.markdownStyle = "light"
+			if lipgloss.HasDarkBackground() {
+				m.markdownStyle = "dark"
+			}
+		}
+	}
+	program := tea.NewProgram(m, tea.WithAltScreen())
+	m.send = program.Send
+	agent.onEvent = func(e AgentEvent) { program.Send(agentEventMsg(e)) }
+	agent.confirm = m.ask
+	defer func() {
+		agent.onEvent = nil
+		agent.confirm = nil
+	}()
+
+	_, err := program.Run()
+	agent.printUsage()
+	return err
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return tea.Batch(textarea.Blink, tuiTick())
+}
+
+func tuiTick() tea.Cmd {
+	return tea.Tick(tuiTickInterval, func(t time.Time) tea.Msg { return tuiTickMsg(t) })
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			if m.busy && m.cancel != nil {
+				m.cancel()
+				m.answer(false)
+				return m, nil
+			}
+			return m, tea.Quit
+		case tea.KeyCtrlT:
+			m.showSidebar = !m.showSidebar
+			m.layout()
+			return m, nil
+		case tea.KeyPgUp, tea.KeyPgDown:
+			var cmd tea.Cmd
+			m.conversation, cmd = m.conversation.Update(msg)
+			return m, cmd
+		case tea.KeyEnter:
+			text := strings.TrimSpace(m.input.Value())
+			m.input.Reset()
+			if msg.Alt && m.busy {
+				m.steer(text)
+				return m, nil
+			}
+			return m, m.submit(text)
+		}
+
+	case agentEventMsg:
+		m.handleEvent(AgentEvent(msg))
+		return m, nil
+
+	case tuiQuestionMsg:
+		m.question = &msg
+		m.appendLine(tuiErrorStyle.Render(msg.question + " [y/N]"))
+		return m, nil
+
+	case turnDoneMsg:
+		if msg.notSent {
+			m.appendLine(tuiMutedStyle.Render(i18n.T("Message not sent: session budget exhausted")))
+			return m, m.finishTurn(nil)
+		}
+		return m, m.finishTurn(msg.err)
+
+	case tuiTickMsg:
+		return m, tuiTick()
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// submit handles a line from the input box: commands run immediately, messages
+// start a turn or are queued while one is running
+func (m *tuiModel) submit(text string) tea.Cmd {
+	if m.question != nil {
+		answer := strings.ToLower(text)
+		m.answer(answer == "y" || answer == "yes")
+		return nil
+	}
+	if text == "" {
+		return nil
+	}
+	if text == "/quit" || text == "/exit" {
+		return tea.Quit
+	}
+	if strings.HasPrefix(text, "/") {
+		if m.busy {
+			m.appendLine(tuiErrorStyle.Render(i18n.T("Commands are not available while a turn is running")))
+			return nil
+		}
+		var err error
+		output := captureStdout(func() { _, err = m.agent.handleCommand(text) })
+		m.appendLine(tuiMutedStyle.Render(text))
+		if output != "" {
+			m.appendLine(strings.TrimRight(output, "\n"))
+		}
+		if err != nil {
+			m.appendLine(tuiErrorStyle.Render(i18n.T("Error") + ": " + err.Error()))
+		}
+		if m.agent.nextMessage == "" {
+			return nil
+		}
+		text, m.agent.nextMessage = m.agent.nextMessage, ""
+	}
+
+	if m.busy {
+		m.queued = append(m.queued, text)
+		m.appendLine(tuiMutedStyle.Render(i18n.T("Queued") + ": " + text))
+		return nil
+	}
+	return m.startTurn(text)
+}
+
+// steer sends a message to the running turn, which the model reads at its
+// next step instead of after the turn
+func (m *tuiModel) steer(text string) {
+	if text == "" {
+		return
+	}
+	if strings.HasPrefix(text, "/") {
+		m.appendLine(tuiErrorStyle.Render(i18n.T("Commands are not available while a turn is running")))
+		return
+	}
+	m.agent.steer(text)
+	m.appendLine(tuiMutedStyle.Render(i18n.T("Steering") + ": " + text))
+}
+
+// ask is the agent's confirm in the TUI: it shows question and waits for the
+// answer typed in the input box. It runs in the turn's goroutine, never in Update.
+func (m *tuiModel) ask(ctx context.Context, question string) bool {
+	answer := make(chan bool, 1)
+	m.send(tuiQuestionMsg{question: question, answer: answer})
+	select {
+	case ok := <-answer:
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// answer answers the pending question, if any
+func (m *tuiModel) answer(ok bool) {
+	if m.question != nil {
+		m.question.answer <- ok
+		m.question = nil
+	}
+}
+
+// startTurn sends text through the same steps as the REPL: the budget check,
+// mentions, the auto-commit snapshot, then after the turn the session save
+// and auto-commit. The steps emit events and may ask questions, so they run
+// in the command rather than in Update.
+func (m *tuiModel) startTurn(text string) tea.Cmd {
+	m.appendMessage(Message{Role: "user", Content: text, Meta: message.Now()})
+	ctx, cancel := context.WithCancel(context.Background())
+	m.busy = true
+	m.cancel = cancel
+
+	agent := m.agent
+	return func() tea.Msg {
+		defer cancel()
+		turn, err := agent.beginTurn(ctx, text)
+		if err != nil {
+			return turnDoneMsg{err: err, notSent: true}
+		}
+		return turnDoneMsg{err: agent.runUserTurn(context.Background(), ctx, turn)}
+	}
+}
+
+func (m *tuiModel) finishTurn(err error) tea.Cmd {
+	m.busy = false
+	m.cancel = nil
+	m.activity = ""
+	m.answer(false)
+	switch {
+	case errors.Is(err, ErrBudgetExhausted):
+		m.appendLine(tuiMutedStyle.Render(i18n.T("Turn stopped: session budget exhausted")))
+	case errors.Is(err, errTurnCancelled):
+		m.appendLine(tuiMutedStyle.Render(i18n.T("Turn cancelled")))
+	case err != nil:
+		m.appendLine(tuiErrorStyle.Render(i18n.T("Error") + ": " + err.Error()))
+	}
+
+	// steering sent as the turn ended runs as the next one
+	m.queued = append(m.agent.takeSteering(), m.queued...)
+	if len(m.queued) > 0 {
+		next := strings.Join(m.queued, "\n\n")
+		m.queued = nil
+		return m.startTurn(next)
+	}
+	return nil
+}
+
+func (m *tuiModel) handleEvent(e AgentEvent) {
+	switch e.Type {
+	case EventAssistantText:
+		m.appendMessage(Message{Role: "assistant", Content: e.Content, Meta: &message.Metadata{Time: e.Time}})
+	case EventToolCall:
+		m.addSidebar("▶ "+toolActivity(*e.ToolCall), lipgloss.NewStyle(), e.Time)
+	case EventToolOutput:
+		m.activity = truncate(e.ToolCall.Name+": "+lastLine(e.Content), 2*tuiSidebarWidth)
+	case EventToolResult:
+		m.addSidebar("✓ "+e.ToolCall.Name+" "+firstLine(e.Content), tuiMutedStyle, e.Time)
+	case EventToolError:
+		m.addSidebar("✗ "+e.ToolCall.Name, tuiErrorStyle, e.Time)
+		m.appendLine(tuiErrorStyle.Render(i18n.T("Tool Error") + ": " + e.Content))
+	case EventFileEdit:
+		added, removed := diff.Stat(e.Diff)
+		m.addSidebar(fmt.Sprintf("✎ %s (+%d -%d)", e.Path, added, removed), lipgloss.NewStyle(), e.Time)
+		m.appendLine(diff.Colorize(strings.TrimRight(e.Diff, "\n")))
+	case EventUsage:
+		m.stats.Record(e.Model, *e.Usage)
+	case EventActivity:
+		m.activity = e.Content
+	case EventUserMessage:
+		m.appendMessage(Message{Role: "user", Content: e.Content, Meta: &message.Metadata{Time: e.Time}})
+	case EventNotice:
+		m.appendLine(e.Content)
+	case EventTiming:
+		if m.agent.showTimings {
+			m.appendLine(tuiMutedStyle.Render(i18n.T("Timing") + ": " + e.Timing.String()))
+		}
+	}
+}
+
+func (m *tuiModel) appendMessage(msg Message) {
+	var at time.Time
+	if msg.Meta != nil {
+		at = msg.Meta.Time
+	}
+	stamp := ""
+	if m.agent.showTimestamps && !at.IsZero() {
+		stamp = tuiMutedStyle.Render(" · " + formatTime(at))
+	}
+	switch {
+	case msg.ToolCall != nil:
+		m.addSidebar("▶ "+toolActivity(*msg.ToolCall), lipgloss.NewStyle(), at)
+	case msg.Role == "user":
+		m.appendLine(tuiUserStyle.Render(i18n.T("You")) + stamp + "\n" + msg.Content)
+	default:
+		m.appendLine(tuiAssistantStyle.Render(i18n.T("Assistant")) + stamp + "\n" + m.markdown.Render(msg.Content))
+	}
+}
+
+func (m *tuiModel) appendLine(text string) {
+	if m.transcript.Len() > 0 {
+		m.transcript.WriteString("\n\n")
+	}
+	m.transcript.WriteString(text)
+	m.conversation.SetContent(m.transcript.String())
+	m.conversation.GotoBottom()
+}
+
+func (m *tuiModel) addSidebar(text string, style lipgloss.Style, at time.Time) {
+	m.sidebar = append(m.sidebar, sidebarEntry{text: text, style: style, at: at})
+	if len(m.sidebar) > maxSidebarEntries {
+		m.sidebar = m.sidebar[len(m.sidebar)-maxSidebarEntries:]
+	}
+}
+
+// layout resizes the panes to fit the window
+func (m *tuiModel) layout() {
+	width := m.width
+	if m.showSidebar {
+		width -= tuiSidebarWidth + 2
+	}
+	m.conversation.Width = max(width, 20)
+	m.conversation.Height = max(m.height-tuiInputHeight-3, 3)
+	m.input.SetWidth(max(m.width-2, 20))
+
+	if m.markdownStyle != "" {
+		renderer, err := glamour.NewTermRenderer(glamour.WithStandardStyle(m.markdownStyle), glamour.WithWordWrap(m.conversation.Width-2))
+		if err == nil {
+			m.markdown = &markdownRenderer{renderer: renderer}
+		}
+	}
+	m.conversation.SetContent(m.transcript.String())
+}
+
+func (m *tuiModel) statusLine() string {
+	parts := []string{m.stats.String()}
+	if m.busy {
+		activity := m.activity
+		if activity == "" {
+			activity = i18n.T("working…")
+		}
+		parts = append(parts, activity)
+	}
+	if len(m.queued) > 0 {
+		parts = append(parts, i18n.Sprintf("%d queued", len(m.queued)))
+	}
+	style := tuiStatusStyle
+	if m.stats.contextFraction() >= contextWarnFraction {
+		style = tuiStatusWarnStyle
+	}
+	return style.Width(max(m.width, 1)).Render(strings.Join(parts, " · "))
+}
+
+func (m *tuiModel) sidebarView() string {
+	lines := []string{tuiMutedStyle.Render(i18n.T("Tool activity"))}
+	visible := m.sidebar
+	if limit := m.conversation.Height - 1; limit > 0 && len(visible) > limit {
+		visible = visible[len(visible)-limit:]
+	}
+	now := time.Now()
+	for _, entry := range visible {
+		// with timestamps on, each entry ends with how long ago it happened
+		if !m.agent.showTimestamps || entry.at.IsZero() {
+			lines = append(lines, entry.style.Render(truncate(entry.text, tuiSidebarWidth)))
+			continue
+		}
+		// the left padding of the sidebar takes one column
+		ago, width := i18n.Ago(entry.at, now), tuiSidebarWidth-1
+		text := truncate(entry.text, max(width-lipgloss.Width(ago)-2, 1))
+		padding := max(width-lipgloss.Width(text)-lipgloss.Width(ago), 1)
+		lines = append(lines, entry.style.Render(text)+strings.Repeat(" ", padding)+tuiMutedStyle.Render(ago))
+	}
+	return tuiSidebarStyle.Width(tuiSidebarWidth).Height(m.conversation.Height).Render(strings.Join(lines, "\n"))
+}
+
+func (m *tuiModel) View() string {
+	main := m.conversation.View()
+	if m.showSidebar {
+		main = lipgloss.JoinHorizontal(lipgloss.Top, main, m.sidebarView())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, main, tuiInputStyle.Render(m.input.View()), m.statusLine())
+}
+
+// captureStdout runs fn and returns what it printed, so command output can be
+// shown inside the TUI instead of corrupting the screen
+func captureStdout(fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		fn()
+		return ""
+	}
+	original := os.Stdout
+	os.Stdout = w
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	defer func() { os.Stdout = original }()
+	fn()
+	w.Close()
+	return <-output
+}
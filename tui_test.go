@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"agent/gitutil"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTUIModel(t *testing.T) {
+	t.Run("事件更新对话、侧边栏和状态栏", func(t *testing.T) {
+		m := newTUIModel(NewAgent(&mockProvider{}, nil, builtinTools()))
+		m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+		call := &ToolCall{ID: "1", Name: "read_file", Input: []byte(`{"path":"main.go"}`)}
+		m.Update(agentEventMsg{Type: EventUsage, Model: "gpt-4o", Usage: &Usage{InputTokens: 1000, OutputTokens: 200}})
+		m.Update(agentEventMsg{Type: EventToolCall, ToolCall: call})
+		m.Update(agentEventMsg{Type: EventToolResult, ToolCall: call, Content: "package main"})
+		m.Update(agentEventMsg{Type: EventAssistantText, Content: "完成"})
+
+		view := ansiPattern.ReplaceAllString(m.View(), "")
+		assert.Contains(t, view, "完成")
+		assert.Contains(t, view, "Tool activity")
+		assert.Contains(t, view, "read_file")
+		assert.Contains(t, view, "gpt-4o")
+		assert.Contains(t, view, "context 1.2k/128k (1%)")
+		assert.Greater(t, m.stats.Cost, 0.0)
+	})
+
+	t.Run("打开时间戳后显示消息时间和工具调用的相对时间", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{}, nil, nil)
+		agent.showTimestamps = true
+		m := newTUIModel(agent)
+		m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+		call := &ToolCall{ID: "1", Name: "read_file", Input: []byte(`{"path":"main.go"}`)}
+		m.Update(agentEventMsg{Type: EventToolCall, ToolCall: call, Time: time.Now().Add(-3 * time.Minute)})
+		at := time.Now()
+		m.Update(agentEventMsg{Type: EventAssistantText, Content: "完成", Time: at})
+
+		view := ansiPattern.ReplaceAllString(m.View(), "")
+		assert.Contains(t, view, "Assistant · "+formatTime(at))
+		assert.Contains(t, view, "3m ago")
+
+		_, cmd := m.Update(tuiTickMsg(time.Now()))
+		assert.NotNil(t, cmd, "定时刷新相对时间")
+	})
+
+	t.Run("Ctrl-T 切换侧边栏", func(t *testing.T) {
+		m := newTUIModel(NewAgent(&mockProvider{}, nil, nil))
+		m.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+		require.True(t, m.showSidebar)
+
+		m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+		assert.False(t, m.showSidebar)
+		assert.NotContains(t, ansiPattern.ReplaceAllString(m.View(), ""), "Tool activity")
+	})
+
+	t.Run("忙碌时输入排队，回合结束后发送", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{responses: []*Response{{Content: "第二个回答"}}}, nil, nil)
+		m := newTUIModel(agent)
+		m.busy = true
+
+		m.input.SetValue("下一个问题")
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		assert.Nil(t, cmd)
+		assert.Equal(t, []string{"下一个问题"}, m.queued)
+		assert.Contains(t, m.statusLine(), "1 queued")
+
+		cmd = m.finishTurn(nil)
+		require.NotNil(t, cmd)
+		assert.True(t, m.busy)
+		assert.Empty(t, m.queued)
+
+		done := cmd().(turnDoneMsg)
+		assert.NoError(t, done.err)
+		assert.Equal(t, "第二个回答", agent.conversation[len(agent.conversation)-1].Content)
+	})
+
+	t.Run("忙碌时 Alt-Enter 插话", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{}, nil, nil)
+		m := newTUIModel(agent)
+		m.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+		m.busy = true
+
+		m.input.SetValue("先看测试")
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+		assert.Nil(t, cmd)
+		assert.Empty(t, m.queued)
+		assert.True(t, agent.steered())
+		assert.Contains(t, ansiPattern.ReplaceAllString(m.View(), ""), "Steering: 先看测试")
+
+		// 回合结束时还没送达的插话作为下一轮发送
+		agent.provider = &mockProvider{responses: []*Response{{Content: "好的"}}}
+		cmd = m.finishTurn(nil)
+		require.NotNil(t, cmd)
+		assert.False(t, agent.steered())
+	})
+
+	t.Run("预算用完时在输入框中确认", func(t *testing.T) {
+		agent := NewAgent(&mockProvider{responses: []*Response{{Content: "继续"}}}, nil, nil)
+		agent.budget = &Budget{MaxTokens: 10}
+		agent.budget.Record("gpt-4o", Usage{InputTokens: 10})
+		m := newTUIModel(agent)
+		m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+		msgs := make(chan tea.Msg, 1)
+		m.send = func(msg tea.Msg) { msgs <- msg }
+		agent.confirm = m.ask
+		// 像 Bubble Tea 一样在另一个 goroutine 中执行命令，结果交给 Update
+		run := func(cmd tea.Cmd) {
+			go func() { msgs <- cmd() }()
+		}
+
+		m.input.SetValue("下一步")
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		require.NotNil(t, cmd)
+		run(cmd)
+		m.Update(<-msgs)
+		require.NotNil(t, m.question)
+		assert.Contains(t, ansiPattern.ReplaceAllString(m.View(), ""), "Continue anyway? [y/N]")
+		assert.Empty(t, agent.Messages(), "确认前不发送")
+
+		m.input.SetValue("n")
+		_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		assert.Nil(t, cmd)
+		m.Update(<-msgs)
+		assert.False(t, m.busy)
+		assert.Contains(t, ansiPattern.ReplaceAllString(m.View(), ""), "Message not sent")
+		assert.Empty(t, agent.Messages())
+
+		m.input.SetValue("下一步")
+		_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		run(cmd)
+		m.Update(<-msgs)
+		m.input.SetValue("y")
+		m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		done := (<-msgs).(turnDoneMsg)
+		assert.NoError(t, done.err)
+		assert.Equal(t, "继续", agent.conversation[len(agent.conversation)-1].Content)
+	})
+
+	t.Run("回合结束后自动提交改动", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git 不可用")
+		}
+		dir := t.TempDir()
+		chdir(t, dir)
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"config", "user.email", "test@example.com"},
+			{"config", "user.name", "test"},
+			{"config", "commit.gpgsign", "false"},
+			{"commit", "-q", "--allow-empty", "-m", "init"},
+		} {
+			require.NoError(t, exec.Command("git", args...).Run())
+		}
+		repo, err := gitutil.Open(dir)
+		require.NoError(t, err)
+
+		agent := editAgent()
+		provider := agent.provider.(*mockProvider)
+		provider.responses = append(provider.responses, &Response{Content: "feat: add hello.txt"})
+		agent.repo = repo
+		m := newTUIModel(agent)
+
+		m.input.SetValue("创建文件")
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		require.NotNil(t, cmd)
+		var done turnDoneMsg
+		captureStdout(func() { done = cmd().(turnDoneMsg) })
+		require.NoError(t, done.err)
+
+		log, err := repo.Run("log", "-1", "--format=%B")
+		require.NoError(t, err)
+		assert.Contains(t, log, "feat: add hello.txt")
+		assert.Contains(t, log, "Instruction: 创建文件")
+	})
+
+	t.Run("空闲时 Ctrl-C 退出", func(t *testing.T) {
+		m := newTUIModel(NewAgent(&mockProvider{}, nil, nil))
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+		require.NotNil(t, cmd)
+		assert.Equal(t, tea.QuitMsg{}, cmd())
+	})
+}
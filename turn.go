@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"agent/gitutil"
+	"agent/i18n"
+	"agent/theme"
+)
+
+// errTurnCancelled is returned by runUserTurn for a turn the user cancelled
+var errTurnCancelled = errors.New("turn cancelled")
+
+// pendingTurn is a message from an interactive front end that passed the
+// steps before its turn
+type pendingTurn struct {
+	// input is the message to send, with the mentioned files and the output
+	// of earlier !!commands attached
+	input string
+	// before is the repository as it was, for auto-commit; nil when it is off
+	before gitutil.Snapshot
+}
+
+// beginTurn runs the steps the REPL and the TUI take before a turn: once the
+// session budget is exhausted the user is asked whether to send anyway, then
+// mentions and shell output are attached and the repository is snapshotted
+// for auto-commit. A message not sent for the budget gives ErrBudgetExhausted.
+func (a *Agent) beginTurn(ctx context.Context, text string) (*pendingTurn, error) {
+	if err := a.checkBudget(ctx); err != nil {
+		return nil, err
+	}
+	turn := &pendingTurn{input: a.withShellContext(a.attachMentions(text))}
+	if a.repo != nil {
+		snapshot, err := a.repo.Snapshot()
+		if err != nil {
+			a.emit(AgentEvent{Type: EventNotice, Content: fmt.Sprintf("%s: %s", theme.Error(i18n.T("Auto-commit Error")), err)})
+		}
+		turn.before = snapshot
+	}
+	return turn, nil
+}
+
+// runUserTurn runs turn in turnCtx, then the steps the REPL and the TUI take
+// after it. A turn the user cancelled, so that turnCtx ended but ctx did
+// not, is dropped to keep the conversation consistent and reported as
+// errTurnCancelled. Otherwise the session is saved and, when the turn
+// succeeded, its changes are auto-committed.
+func (a *Agent) runUserTurn(ctx, turnCtx context.Context, turn *pendingTurn) error {
+	checkpoint := a.conversationLen()
+	err := a.runTurn(turnCtx, turn.input)
+	if err != nil && errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		a.truncateConversation(checkpoint)
+		a.reads.Reset()
+		return errTurnCancelled
+	}
+	if saveErr := a.saveSession(); saveErr != nil {
+		a.emit(AgentEvent{Type: EventNotice, Content: fmt.Sprintf("%s: %s", theme.Error(i18n.T("Session Error")), saveErr)})
+	}
+	if err != nil {
+		return err
+	}
+	if a.repo != nil && turn.before != nil {
+		if err := a.commitTurnChanges(ctx, turn.input, turn.before); err != nil {
+			a.emit(AgentEvent{Type: EventNotice, Content: fmt.Sprintf("%s: %s", theme.Error(i18n.T("Auto-commit Error")), err)})
+		}
+	}
+	return nil
+}
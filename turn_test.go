@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTurnLoopsOverToolCalls(t *testing.T) {
+	chdir(t, t.TempDir())
+	path := "notes.txt"
+	require.NoError(t, os.WriteFile(path, []byte("remember the milk"), 0644))
+	input, err := json.Marshal(tools.ReadFileInput{Path: path})
+	require.NoError(t, err)
+
+	provider := &mockProvider{responses: []*Response{
+		{Content: "Let me look.", ToolCalls: []ToolCall{{ID: "1", Name: "read_file", Input: input}}},
+		{ToolCalls: []ToolCall{{ID: "2", Name: "missing_tool", Input: json.RawMessage(`{}`)}}},
+		{Content: "It says to remember the milk."},
+	}}
+	agent := NewAgent(provider, nil, builtinTools())
+
+	require.NoError(t, agent.runTurn(context.Background(), "what do my notes say?"))
+	require.Len(t, provider.calls, 3)
+
+	// 第二次推理能看到工具结果
+	second := provider.calls[1]
+	assert.Equal(t, "user", second[len(second)-1].Role)
+	assert.Contains(t, second[len(second)-1].Content, "remember the milk")
+
+	third := provider.calls[2]
+	assert.Contains(t, third[len(third)-1].Content, "missing_tool not found")
+
+	last := agent.conversation[len(agent.conversation)-1]
+	assert.Equal(t, "It says to remember the milk.", last.Content)
+}
+
+func TestRunTurnStopsAtStepLimit(t *testing.T) {
+	var responses []*Response
+	for i := 0; i <= maxTurnSteps; i++ {
+		input := json.RawMessage(fmt.Sprintf(`{"n":%d}`, i))
+		responses = append(responses, &Response{ToolCalls: []ToolCall{{ID: fmt.Sprint(i), Name: "missing_tool", Input: input}}})
+	}
+	provider := &mockProvider{responses: responses}
+	agent := NewAgent(provider, nil, nil)
+
+	require.NoError(t, agent.runTurn(context.Background(), "keep going"))
+	assert.Len(t, provider.calls, maxTurnSteps, "达到步数上限后停止推理")
+}
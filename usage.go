@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"agent/i18n"
+)
+
+// SessionUsage summarizes what a session has used over all its runs: it is
+// shown by /usage and when the chat ends, and saved in the session file
+type SessionUsage struct {
+	Turns        int            `json:"turns"`
+	ToolCalls    map[string]int `json:"tool_calls,omitempty"`
+	InputTokens  int64          `json:"input_tokens"`
+	OutputTokens int64          `json:"output_tokens"`
+	CachedTokens int64          `json:"cached_tokens,omitempty"`
+	WallTimeMS   int64          `json:"wall_time_ms"`
+	CostUSD      float64        `json:"cost_usd"`
+}
+
+// usageTracker counts the turns and tool calls of the current run; tokens
+// and cost come from the agent's stats
+type usageTracker struct {
+	// prior is what earlier runs of a resumed session used
+	prior     SessionUsage
+	started   time.Time
+	turns     int
+	toolCalls map[string]int
+}
+
+func (u *usageTracker) recordTool(name string) {
+	if u.toolCalls == nil {
+		u.toolCalls = map[string]int{}
+	}
+	u.toolCalls[name]++
+}
+
+// usage returns the session's usage so far, including earlier runs
+func (a *Agent) usage() SessionUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	prior := a.usageTracker.prior
+	usage := SessionUsage{
+		Turns:        prior.Turns + a.usageTracker.turns,
+		ToolCalls:    map[string]int{},
+		InputTokens:  prior.InputTokens + a.stats.Usage.InputTokens,
+		OutputTokens: prior.OutputTokens + a.stats.Usage.OutputTokens,
+		CachedTokens: prior.CachedTokens + a.stats.Usage.CachedTokens,
+		WallTimeMS:   prior.WallTimeMS,
+		CostUSD:      prior.CostUSD + a.stats.Cost,
+	}
+	if !a.usageTracker.started.IsZero() {
+		usage.WallTimeMS += time.Since(a.usageTracker.started).Milliseconds()
+	}
+	for _, calls := range []map[string]int{prior.ToolCalls, a.usageTracker.toolCalls} {
+		for name, n := range calls {
+			usage.ToolCalls[name] += n
+		}
+	}
+	return usage
+}
+
+// resumeSession continues session, carrying on its conversation, pinned
+// files and usage
+func (a *Agent) resumeSession(session *Session) {
+	a.session = session
+	a.setConversation(session.Messages)
+	a.setPins(session.Pins)
+	a.reads.Reset()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usageTracker.prior = SessionUsage{}
+	if session.Usage != nil {
+		a.usageTracker.prior = *session.Usage
+	}
+}
+
+// writeTable writes the usage as a two-column table, with tool calls broken
+// down by tool, most used first
+func (u SessionUsage) writeTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	wall := time.Duration(u.WallTimeMS) * time.Millisecond
+	fmt.Fprintf(tw, i18n.T("Turns")+"\t%d\n", u.Turns)
+	fmt.Fprintf(tw, i18n.T("Wall time")+"\t%s\n", wall.Round(time.Second))
+	fmt.Fprintf(tw, i18n.T("Input tokens")+"\t%s\n", formatTokens(u.InputTokens))
+	fmt.Fprintf(tw, i18n.T("Output tokens")+"\t%s\n", formatTokens(u.OutputTokens))
+	fmt.Fprintf(tw, i18n.T("Cached tokens")+"\t%s\n", formatTokens(u.CachedTokens))
+	fmt.Fprintf(tw, i18n.T("Estimated cost")+"\t$%.4f\n", u.CostUSD)
+
+	names := make([]string, 0, len(u.ToolCalls))
+	total := 0
+	for name, n := range u.ToolCalls {
+		names = append(names, name)
+		total += n
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if u.ToolCalls[names[i]] != u.ToolCalls[names[j]] {
+			return u.ToolCalls[names[i]] > u.ToolCalls[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintf(tw, i18n.T("Tool calls")+"\t%d\n", total)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%d\n", name, u.ToolCalls[name])
+	}
+	tw.Flush()
+}
+
+// printUsage shows the session's usage when the chat ends, unless nothing
+// was sent in this run
+func (a *Agent) printUsage() {
+	a.mu.Lock()
+	turns := a.usageTracker.turns
+	a.mu.Unlock()
+	if turns == 0 {
+		return
+	}
+	fmt.Println("\n" + i18n.T("Session usage"))
+	a.usage().writeTable(os.Stdout)
+}
+
+func runUsageCommand(a *Agent, args []string) error {
+	a.usage().writeTable(os.Stdout)
+	return nil
+}
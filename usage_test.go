@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionUsage(t *testing.T) {
+	store := &FileSessionStore{Dir: t.TempDir()}
+	agent := oneShotAgent()
+	agent.store = store
+	agent.onEvent = func(AgentEvent) {}
+	require.NoError(t, agent.runTurn(context.Background(), "模块名是什么？"))
+	require.NoError(t, agent.saveSession())
+
+	usage := agent.usage()
+	assert.Equal(t, 1, usage.Turns)
+	assert.Equal(t, map[string]int{"read_file": 1}, usage.ToolCalls)
+	assert.Equal(t, int64(300), usage.InputTokens)
+	assert.Equal(t, int64(30), usage.OutputTokens)
+	assert.Greater(t, usage.CostUSD, 0.0)
+
+	t.Run("写入会话文件", func(t *testing.T) {
+		session, err := store.Load(agent.session.ID)
+		require.NoError(t, err)
+		require.NotNil(t, session.Usage)
+		assert.Equal(t, 1, session.Usage.Turns)
+		assert.Equal(t, int64(300), session.Usage.InputTokens)
+	})
+
+	t.Run("恢复的会话累计之前的用量", func(t *testing.T) {
+		session, err := store.Load(agent.session.ID)
+		require.NoError(t, err)
+		resumed := oneShotAgent()
+		resumed.resumeSession(session)
+		resumed.onEvent = func(AgentEvent) {}
+		require.NoError(t, resumed.runTurn(context.Background(), "再读一次"))
+
+		usage := resumed.usage()
+		assert.Equal(t, 2, usage.Turns)
+		assert.Equal(t, map[string]int{"read_file": 2}, usage.ToolCalls)
+		assert.Equal(t, int64(600), usage.InputTokens)
+	})
+}
+
+func TestUsageTable(t *testing.T) {
+	var out bytes.Buffer
+	SessionUsage{
+		Turns:        3,
+		ToolCalls:    map[string]int{"read_file": 1, "edit_file": 2, "shell": 1},
+		InputTokens:  12300,
+		OutputTokens: 950,
+		WallTimeMS:   134400,
+		CostUSD:      0.0123,
+	}.writeTable(&out)
+
+	assert.Equal(t, `Turns           3
+Wall time       2m14s
+Input tokens    12.3k
+Output tokens   950
+Cached tokens   0
+Estimated cost  $0.0123
+Tool calls      4
+  edit_file     2
+  read_file     1
+  shell         1
+`, out.String())
+}
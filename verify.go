@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/theme"
+	"agent/tools"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const (
+	// verifyTimeout bounds each check run after a file change
+	verifyTimeout = time.Minute
+	// maxVerifyOutput limits how much of a failed check's output is sent to
+	// the model; compilers report the first errors at the start
+	maxVerifyOutput = 4 * 1024
+)
+
+// verifyFailure is a check that failed after a tool changed files
+type verifyFailure struct {
+	Command string
+	Output  string
+}
+
+// verifyCommand is a check expanded for a changed file
+type verifyCommand struct {
+	Args         []string
+	FailOnOutput bool
+}
+
+// verifyCommands expands the checks matching the changed files, each
+// distinct command once, in the order of the checks. Checks requiring a file
+// the workspace root lacks are left out.
+func verifyCommands(root string, checks []config.VerifyCheck, files []tools.FileRef) []verifyCommand {
+	var commands []verifyCommand
+	seen := map[string]bool{}
+	for _, check := range checks {
+		if check.Requires != "" {
+			if _, err := os.Stat(filepath.Join(root, check.Requires)); err != nil {
+				continue
+			}
+		}
+		for _, file := range files {
+			name := filepath.ToSlash(filepath.Clean(file.Path))
+			if ok, _ := path.Match(check.Files, path.Base(name)); !ok {
+				continue
+			}
+			dir := path.Dir(name)
+			if dir != "." && !path.IsAbs(dir) {
+				dir = "./" + dir
+			}
+			replacer := strings.NewReplacer("{file}", name, "{dir}", dir)
+			args := make([]string, len(check.Command))
+			for i, arg := range check.Command {
+				args[i] = replacer.Replace(arg)
+			}
+			if key := strings.Join(args, "\x00"); !seen[key] {
+				seen[key] = true
+				commands = append(commands, verifyCommand{Args: args, FailOnOutput: check.FailOnOutput})
+			}
+		}
+	}
+	return commands
+}
+
+// verifyFiles runs the configured quick checks on the files a tool changed,
+// in the workspace root, and returns the ones that failed. The checks run
+// like commands of the shell tool: in its container when there is one, and
+// only if the shell policy allows them. Files that no longer exist are
+// skipped, and so are checks whose command is not installed. Working on
+// another machine skips the checks, since the files are looked up locally.
+func (a *Agent) verifyFiles(ctx context.Context, files []tools.FileRef) []verifyFailure {
+	if a.config.Verify.Disabled || a.config.Remote.Host != "" {
+		return nil
+	}
+	var existing []tools.FileRef
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(currentWorkspace.Root, file.Path)); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	log := a.logFor(subsystemTools)
+	policy := tools.CommandPolicy{Allow: a.config.Shell.Allow, Deny: a.config.Shell.Deny}
+	container := shellContainer(a.config.Shell)
+	var failures []verifyFailure
+	for _, check := range verifyCommands(currentWorkspace.Root, a.config.Verify.Checks, existing) {
+		command := shellQuote(check.Args)
+		checkCtx, cancel := context.WithTimeout(ctx, verifyTimeout)
+		output, err := currentWorkspace.RunShell(checkCtx, policy, container, command)
+		cancel()
+		var exit *exec.ExitError
+		switch {
+		case errors.Is(err, tools.ErrDenied):
+			log.Warn("verify check denied by the shell policy", "command", command, "error", err)
+			continue
+		case errors.As(err, &exit) && exit.ExitCode() == commandNotFound:
+			log.Debug("verify check skipped", "command", check.Args[0], "error", strings.TrimSpace(output))
+			continue
+		}
+		text := strings.TrimSpace(output)
+		if err == nil && (!check.FailOnOutput || text == "") {
+			continue
+		}
+		if text == "" {
+			text = err.Error()
+		}
+		log.Info("verify check failed", "command", command)
+		failures = append(failures, verifyFailure{Command: command, Output: truncate(text, maxVerifyOutput)})
+	}
+	return failures
+}
+
+// commandNotFound is the exit status of sh for a command it cannot find
+const commandNotFound = 127
+
+// shellQuote joins args into a command line for sh, quoting the ones that
+// need it
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		q, err := syntax.Quote(arg, syntax.LangPOSIX)
+		if err != nil {
+			// only NUL bytes cannot be quoted, and no command can take them
+			q = "''"
+		}
+		quoted[i] = q
+	}
+	return strings.Join(quoted, " ")
+}
+
+// verifyReport is appended to the tool result the model sees, so it fixes
+// the problems in the same turn
+func verifyReport(failures []verifyFailure) string {
+	var b strings.Builder
+	b.WriteString("Verification failed after this change. Fix these problems:")
+	for _, f := range failures {
+		b.WriteString("\n\n$ " + f.Command + "\n" + f.Output)
+	}
+	return b.String()
+}
+
+// verifyNotice tells the user which checks failed; the model gets their output
+func verifyNotice(failures []verifyFailure) string {
+	commands := make([]string, len(failures))
+	for i, f := range failures {
+		commands[i] = f.Command
+	}
+	return i18n.Sprintf("%s: %s", theme.Error(i18n.T("Verification failed")), strings.Join(commands, ", "))
+}
+
+// editedFiles returns the files a successful call of tool changed: the ones
+// the result names, or the file in the input when its diff is not empty
+func editedFiles(tool tools.ToolDefinition, result tools.ToolResult, path, unified string, err error) []tools.FileRef {
+	switch {
+	case err != nil || tool.ReadOnly:
+		return nil
+	case len(result.Files) > 0:
+		return result.Files
+	case path != "" && unified != "":
+		return []tools.FileRef{{Path: path}}
+	}
+	return nil
+}
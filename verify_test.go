@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent/config"
+	"agent/tools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCommands(t *testing.T) {
+	root := t.TempDir()
+	checks := []config.VerifyCheck{
+		{Files: "*.go", Command: []string{"gofmt", "-l", "{file}"}, FailOnOutput: true},
+		{Files: "*.go", Command: []string{"go", "build", "{dir}"}},
+		{Files: "*.py", Command: []string{"ruff", "check", "{file}"}, Requires: "pyproject.toml"},
+	}
+	files := []tools.FileRef{{Path: "pkg/a/a.go"}, {Path: "pkg/a/b.go"}, {Path: "main.go"}, {Path: "tool.py"}, {Path: "README.md"}}
+
+	commands := verifyCommands(root, checks, files)
+	args := [][]string{}
+	for _, c := range commands {
+		args = append(args, c.Args)
+	}
+	assert.Equal(t, [][]string{
+		{"gofmt", "-l", "pkg/a/a.go"},
+		{"gofmt", "-l", "pkg/a/b.go"},
+		{"gofmt", "-l", "main.go"},
+		{"go", "build", "./pkg/a"},
+		{"go", "build", "."},
+	}, args, "同一个包只编译一次，缺少 pyproject.toml 时不检查 Python 文件")
+	assert.True(t, commands[0].FailOnOutput)
+	assert.False(t, commands[3].FailOnOutput)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pyproject.toml"), nil, 0644))
+	commands = verifyCommands(root, checks, files)
+	assert.Equal(t, []string{"ruff", "check", "tool.py"}, commands[len(commands)-1].Args)
+}
+
+func TestVerifyAfterEdit(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("a.txt", []byte("good\n"), 0644))
+
+	run := func(t *testing.T, check config.VerifyCheck, edit tools.EditFileInput) (string, []AgentEvent) {
+		t.Helper()
+		input, err := json.Marshal(edit)
+		require.NoError(t, err)
+		provider := &mockProvider{responses: []*Response{
+			{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: input}}},
+			{Content: "Done."},
+		}}
+		agent := NewAgent(provider, nil, builtinTools())
+		agent.config.Verify.Checks = []config.VerifyCheck{check}
+		events := []AgentEvent{}
+		agent.onEvent = func(e AgentEvent) { events = append(events, e) }
+		require.NoError(t, agent.runTurn(context.Background(), "edit a.txt"))
+		return provider.calls[1][len(provider.calls[1])-1].Content, events
+	}
+	notices := func(events []AgentEvent) []string {
+		out := []string{}
+		for _, e := range events {
+			if e.Type == EventNotice {
+				out = append(out, e.Content)
+			}
+		}
+		return out
+	}
+	grepBad := config.VerifyCheck{Files: "*.txt", Command: []string{"sh", "-c", "grep -n bad {file} || true"}, FailOnOutput: true}
+
+	t.Run("检查失败时模型在工具结果中看到输出", func(t *testing.T) {
+		result, events := run(t, grepBad, tools.EditFileInput{Path: "a.txt", OldStr: "good", NewStr: "bad"})
+		assert.Contains(t, result, "Verification failed after this change")
+		assert.Contains(t, result, "$ sh -c 'grep -n bad a.txt || true'\n1:bad")
+		require.Len(t, notices(events), 1)
+		assert.Contains(t, notices(events)[0], "grep -n bad a.txt")
+	})
+
+	t.Run("检查通过时结果不变", func(t *testing.T) {
+		result, events := run(t, grepBad, tools.EditFileInput{Path: "a.txt", OldStr: "bad", NewStr: "fine"})
+		assert.NotContains(t, result, "Verification failed")
+		assert.Empty(t, notices(events))
+	})
+
+	t.Run("非零退出状态算失败", func(t *testing.T) {
+		check := config.VerifyCheck{Files: "*.txt", Command: []string{"sh", "-c", "echo broken {file}; exit 2"}}
+		result, _ := run(t, check, tools.EditFileInput{Path: "a.txt", OldStr: "fine", NewStr: "okay"})
+		assert.Contains(t, result, "broken a.txt")
+	})
+
+	t.Run("不匹配的文件和没有安装的命令不检查", func(t *testing.T) {
+		check := config.VerifyCheck{Files: "*.go", Command: []string{"false"}}
+		result, _ := run(t, check, tools.EditFileInput{Path: "a.txt", OldStr: "okay", NewStr: "good"})
+		assert.NotContains(t, result, "Verification failed")
+
+		check = config.VerifyCheck{Files: "*.txt", Command: []string{"no-such-checker-installed"}}
+		result, _ = run(t, check, tools.EditFileInput{Path: "a.txt", OldStr: "good", NewStr: "okay"})
+		assert.NotContains(t, result, "Verification failed")
+	})
+
+	t.Run("检查和 shell 工具一样受命令策略限制", func(t *testing.T) {
+		input, err := json.Marshal(tools.EditFileInput{Path: "a.txt", OldStr: "okay", NewStr: "bad"})
+		require.NoError(t, err)
+		provider := &mockProvider{responses: []*Response{
+			{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: input}}},
+			{Content: "Done."},
+		}}
+		agent := NewAgent(provider, nil, builtinTools())
+		agent.config.Verify.Checks = []config.VerifyCheck{grepBad, {Files: "*.txt", Command: []string{"touch", "ran"}}}
+		agent.config.Shell.Allow = []string{"go test"}
+		agent.onEvent = func(AgentEvent) {}
+		require.NoError(t, agent.runTurn(context.Background(), "edit a.txt"))
+		assert.NotContains(t, provider.calls[1][len(provider.calls[1])-1].Content, "Verification failed")
+		assert.NoFileExists(t, "ran", "允许列表之外的检查不执行")
+		require.NoError(t, os.WriteFile("a.txt", []byte("okay\n"), 0644))
+	})
+
+	t.Run("关闭检查", func(t *testing.T) {
+		input, err := json.Marshal(tools.EditFileInput{Path: "a.txt", OldStr: "okay", NewStr: "bad"})
+		require.NoError(t, err)
+		provider := &mockProvider{responses: []*Response{
+			{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: input}}},
+			{Content: "Done."},
+		}}
+		agent := NewAgent(provider, nil, builtinTools())
+		agent.config.Verify = config.Verify{Disabled: true, Checks: []config.VerifyCheck{grepBad}}
+		agent.onEvent = func(AgentEvent) {}
+		require.NoError(t, agent.runTurn(context.Background(), "edit a.txt"))
+		assert.NotContains(t, provider.calls[1][len(provider.calls[1])-1].Content, "Verification failed")
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"agent/config"
+	"agent/i18n"
+	"agent/pkg/provider"
+	"agent/theme"
+
+	"github.com/openai/openai-go/option"
+)
+
+// maxSpokenChars is the longest text the speech API reads in one request
+const maxSpokenChars = 4096
+
+var (
+	// defaultRecorders are tried in order when voice.record is not set; sox
+	// stops recording after two seconds of silence once something was said
+	defaultRecorders = [][]string{
+		{"rec", "-q", "-c", "1", "-r", "16000", "{file}", "silence", "1", "0.1", "3%", "1", "2.0", "3%"},
+	}
+	// defaultPlayers are tried in order when voice.play is not set
+	defaultPlayers = [][]string{
+		{"afplay", "{file}"},
+		{"mpv", "--really-quiet", "--no-video", "{file}"},
+		{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet", "{file}"},
+	}
+)
+
+// voiceMode lets the user talk to the agent: recordings are transcribed into
+// messages, and replies are read aloud one after another in the background
+type voiceMode struct {
+	transcriber provider.Transcriber
+	synthesizer provider.Synthesizer
+	// record and play are the commands that record to and play {file}
+	record, play []string
+	logger       *slog.Logger
+
+	replies chan spokenReply
+	mu      sync.Mutex
+	// ctx is cancelled to stop the reply being read and skip the queued ones
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// spokenReply is a reply queued to be read, with the context it was queued in
+type spokenReply struct {
+	ctx  context.Context
+	text string
+}
+
+// newVoiceMode sets up voice mode as cfg describes, finding a recorder and a
+// player among the installed programs unless they are configured
+func newVoiceMode(cfg config.Voice, logger *slog.Logger) (*voiceMode, error) {
+	key, _ := lookupAPIKey("openai", cfg.APIKeyEnv)
+	if key == "" && cfg.BaseURL == "" {
+		return nil, fmt.Errorf("voice mode needs an OpenAI API key, or voice.base_url for a compatible service")
+	}
+	var opts []option.RequestOption
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+	speech := provider.NewOpenAISpeech(key, opts...)
+	if cfg.TranscriptionModel != "" {
+		speech.TranscriptionModel = cfg.TranscriptionModel
+	}
+	if cfg.SpeechModel != "" {
+		speech.SpeechModel = cfg.SpeechModel
+	}
+	if cfg.Voice != "" {
+		speech.Voice = cfg.Voice
+	}
+	record := findProgram(cfg.Record, defaultRecorders)
+	if record == nil {
+		return nil, fmt.Errorf("no recorder found: install sox or set voice.record")
+	}
+	play := findProgram(cfg.Play, defaultPlayers)
+	if play == nil {
+		return nil, fmt.Errorf("no audio player found: install mpv or ffmpeg, or set voice.play")
+	}
+	v := &voiceMode{transcriber: speech, synthesizer: speech, record: record, play: play, logger: logger, replies: make(chan spokenReply, 16)}
+	v.ctx, v.cancel = context.WithCancel(context.Background())
+	go v.readReplies()
+	return v, nil
+}
+
+// findProgram returns the configured command, or else the first default
+// whose program is installed
+func findProgram(configured []string, defaults [][]string) []string {
+	if len(configured) > 0 {
+		return configured
+	}
+	for _, command := range defaults {
+		if _, err := exec.LookPath(command[0]); err == nil {
+			return command
+		}
+	}
+	return nil
+}
+
+// runWithFile runs command with {file} replaced by path
+func runWithFile(ctx context.Context, command []string, path string) error {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = strings.ReplaceAll(arg, "{file}", path)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+	return nil
+}
+
+// listen records the user until the recorder stops, usually after a pause,
+// and returns what they said. Reading replies aloud stops first.
+func (v *voiceMode) listen(ctx context.Context) (string, error) {
+	v.stop()
+	file, err := os.CreateTemp("", "agent-voice-*.wav")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	if err := runWithFile(ctx, v.record, file.Name()); err != nil {
+		return "", err
+	}
+	audio, err := os.Open(file.Name())
+	if err != nil {
+		return "", err
+	}
+	defer audio.Close()
+	text, err := v.transcriber.Transcribe(ctx, audio, "voice.wav")
+	return strings.TrimSpace(text), err
+}
+
+// speak queues a reply to be read aloud after the ones before it
+func (v *voiceMode) speak(reply string) {
+	text := speakable(reply)
+	if text == "" {
+		return
+	}
+	v.mu.Lock()
+	ctx := v.ctx
+	v.mu.Unlock()
+	select {
+	case v.replies <- spokenReply{ctx: ctx, text: text}:
+	default:
+		v.logger.Warn("too many replies waiting to be read aloud; skipping one")
+	}
+}
+
+// readReplies reads the queued replies until close
+func (v *voiceMode) readReplies() {
+	for reply := range v.replies {
+		if reply.ctx.Err() != nil {
+			continue
+		}
+		if err := v.say(reply.ctx, reply.text); err != nil && reply.ctx.Err() == nil {
+			v.logger.Warn("reading the reply aloud failed", "error", err)
+		}
+	}
+}
+
+// say synthesizes text and plays it
+func (v *voiceMode) say(ctx context.Context, text string) error {
+	audio, err := v.synthesizer.Speak(ctx, text)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp("", "agent-voice-*.mp3")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(audio)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return runWithFile(ctx, v.play, file.Name())
+}
+
+// stop stops the reply being read and skips the queued ones
+func (v *voiceMode) stop() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.cancel()
+	v.ctx, v.cancel = context.WithCancel(context.Background())
+}
+
+// close stops reading replies and ends voice mode
+func (v *voiceMode) close() {
+	v.stop()
+	close(v.replies)
+}
+
+var (
+	codeBlockPattern  = regexp.MustCompile("(?s)```.*?(```|$)")
+	markdownLink      = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownDecorator = regexp.MustCompile("(?m)^#+[ \t]*|^[ \t]*[-*][ \t]+|\\*\\*|__|`")
+)
+
+// speakable turns a Markdown reply into text worth reading aloud: code
+// blocks are not read, links are read by their text, and formatting is dropped
+func speakable(reply string) string {
+	text := codeBlockPattern.ReplaceAllString(reply, i18n.T("See the code on screen.")+"\n")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownDecorator.ReplaceAllString(text, "")
+	text = strings.TrimSpace(text)
+	if runes := []rune(text); len(runes) > maxSpokenChars {
+		text = string(runes[:maxSpokenChars])
+	}
+	return text
+}
+
+// listenInput records a message in voice mode and echoes what was heard
+func (a *Agent) listenInput(ctx context.Context) (string, error) {
+	fmt.Println(theme.Muted(i18n.T("Listening… pause to send")))
+	listenCtx, done := a.interrupts.BeginTurn(ctx)
+	defer done()
+	text, err := a.voice.listen(listenCtx)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		fmt.Println(i18n.T("Nothing heard"))
+		return "", nil
+	}
+	fmt.Printf("%s: %s\n", theme.User(i18n.T("You (voice)")), text)
+	return text, nil
+}
+
+// runVoiceCommand turns voice mode on or off; without an argument it toggles
+func runVoiceCommand(a *Agent, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /voice [on|off]")
+	}
+	on := a.voice == nil
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			on = true
+		case "off":
+			on = false
+		default:
+			return fmt.Errorf("usage: /voice [on|off]")
+		}
+	}
+	switch {
+	case on && a.voice == nil:
+		var cfg config.Voice
+		if a.config != nil {
+			cfg = a.config.Voice
+		}
+		voice, err := newVoiceMode(cfg, a.log())
+		if err != nil {
+			return err
+		}
+		a.voice = voice
+	case !on && a.voice != nil:
+		a.voice.close()
+		a.voice = nil
+	}
+	if on {
+		fmt.Println(i18n.T("Voice mode on: press Enter on an empty line to speak; replies are read aloud"))
+	} else {
+		fmt.Println(i18n.T("Voice mode off"))
+	}
+	return nil
+}
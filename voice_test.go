@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeakable(t *testing.T) {
+	reply := "## Fix\n\nThe **bug** is in `parse`, see [the docs](https://example.com):\n\n```go\nreturn nil\n```\n\n- run the tests"
+	assert.Equal(t, "Fix\n\nThe bug is in parse, see the docs:\n\nSee the code on screen.\n\n\nrun the tests", speakable(reply))
+	assert.Empty(t, speakable("**  **"))
+	assert.Len(t, speakable(strings.Repeat("a", maxSpokenChars+10)), maxSpokenChars, "太长的回复截断")
+}
+
+func TestVoiceMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用 sh 命令")
+	}
+	played := filepath.Join(t.TempDir(), "played")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/audio/transcriptions":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"text": " run the tests "}`))
+		case "/audio/speech":
+			w.Write([]byte("audio"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := &mockProvider{responses: []*Response{{Content: "All **green**."}}}
+	agent := NewAgent(provider, scriptedInput("/voice", "", "/voice off"), nil)
+	agent.config = &config.Config{Voice: config.Voice{
+		BaseURL: server.URL,
+		Record:  []string{"sh", "-c", "printf RIFF > {file}"},
+		Play:    []string{"sh", "-c", "cat {file} >> " + played},
+	}}
+	out := captureStdout(func() { require.NoError(t, agent.Run(context.Background())) })
+
+	assert.Contains(t, out, "Voice mode on")
+	assert.Contains(t, out, "You (voice)")
+	assert.Contains(t, out, "Voice mode off")
+	require.Len(t, provider.calls, 1, "空行录音后发送转写的文字")
+	last := provider.calls[0][len(provider.calls[0])-1]
+	assert.Equal(t, "run the tests", last.Content)
+	assert.Nil(t, agent.voice)
+}
+
+func TestVoiceModeReadsReplies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用 sh 命令")
+	}
+	played := filepath.Join(t.TempDir(), "played")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("audio;"))
+	}))
+	defer server.Close()
+
+	voice, err := newVoiceMode(config.Voice{
+		BaseURL: server.URL,
+		Record:  []string{"true", "{file}"},
+		Play:    []string{"sh", "-c", "cat {file} >> " + played},
+	}, NewAgent(nil, nil, nil).log())
+	require.NoError(t, err)
+	defer voice.close()
+
+	voice.speak("First.")
+	voice.speak("```\nonly code\n```")
+	voice.speak("Second.")
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(played)
+		return string(data) == "audio;audio;audio;"
+	}, 5*time.Second, 10*time.Millisecond, "依次朗读每条回复")
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/i18n"
+	"agent/search"
+	"agent/theme"
+
+	"github.com/spf13/cobra"
+)
+
+// Defaults for config.Watch
+const (
+	defaultWatchCooldown = 2 * time.Minute
+	defaultWatchInterval = 2 * time.Second
+)
+
+// maxWatchOutput limits how much of the test output is sent to the model; the
+// failures are usually at the end
+const maxWatchOutput = 16 * 1024
+
+// fileState is what the watcher compares to notice a changed file
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watcher runs a task when files in the workspace change, or when the test
+// command fails after they change. Runs of the task are at least cooldown apart.
+type watcher struct {
+	task     string
+	test     []string
+	cooldown time.Duration
+	out      io.Writer
+
+	// run starts an agent turn with the prompt
+	run func(ctx context.Context, prompt string) error
+	// confirm asks the user a yes/no question; nil runs without asking
+	confirm func(question string) bool
+	now     func() time.Time
+
+	files   map[string]fileState
+	changed map[string]bool
+	lastRun time.Time
+}
+
+func newWatchCommand(global *globalOptions) *cobra.Command {
+	var task, test string
+	var cooldown, interval time.Duration
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Run a task when files change or tests fail",
+		Long: `Watch the workspace and hand a task to the model when files change. With a test
+command, the task only runs when the tests fail after a change, e.g.
+
+  agent watch --test "go test ./..." --task "Diagnose the failing tests and propose a fix"
+
+At most one run starts per --cooldown. Before each run, and before each tool call
+that changes something, you are asked to approve unless --yes is given. The
+defaults come from watch in the config.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := global.cfg().Watch
+			if task != "" {
+				cfg.Task = task
+			}
+			if test != "" {
+				cfg.Test = strings.Fields(test)
+			}
+			if cooldown > 0 {
+				cfg.Cooldown = cooldown
+			}
+			if interval > 0 {
+				cfg.Interval = interval
+			}
+			if cfg.Task == "" {
+				return fmt.Errorf("no task given: use --task or set watch.task in the config")
+			}
+			if cfg.Cooldown == 0 {
+				cfg.Cooldown = defaultWatchCooldown
+			}
+			if cfg.Interval == 0 {
+				cfg.Interval = defaultWatchInterval
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			logger := global.logger(os.Stderr, slog.LevelWarn)
+			in := bufio.NewScanner(cmd.InOrStdin())
+			w := &watcher{task: cfg.Task, test: cfg.Test, cooldown: cfg.Cooldown, out: cmd.OutOrStdout()}
+			if !yes {
+				w.confirm = func(question string) bool {
+					fmt.Fprint(w.out, i18n.Sprintf("%s [y/N]: ", question))
+					if !in.Scan() {
+						return false
+					}
+					answer := strings.ToLower(strings.TrimSpace(in.Text()))
+					return answer == "y" || answer == "yes"
+				}
+			}
+			w.run = func(ctx context.Context, prompt string) error {
+				agent := NewAgent(nil, nil, nil)
+				agent.logger = logger
+				if _, err := agent.applyConfig(global.cfg()); err != nil {
+					return err
+				}
+				if w.confirm != nil {
+					agent.approve = func(ctx context.Context, call ToolCall) error {
+						if !w.confirm(fmt.Sprintf("Allow %s %s?", call.Name, call.Input)) {
+							return deniedByUser(call)
+						}
+						return nil
+					}
+				}
+				return agent.runTurn(ctx, prompt)
+			}
+			return w.watch(ctx, cfg.Interval)
+		},
+	}
+	cmd.Flags().StringVar(&task, "task", "", "the instruction to run when triggered")
+	cmd.Flags().StringVar(&test, "test", "", `command to run after changes, e.g. "go test ./..."; the task runs only when it fails`)
+	cmd.Flags().DurationVar(&cooldown, "cooldown", 0, "minimum time between runs (default 2m)")
+	cmd.Flags().DurationVar(&interval, "interval", 0, "how often to check for changes (default 2s)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "run tasks and tool calls without asking")
+	return cmd
+}
+
+// watch polls the workspace every interval until ctx is done
+func (w *watcher) watch(ctx context.Context, interval time.Duration) error {
+	files, err := scanWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+	w.files = files
+	fmt.Fprintln(w.out, i18n.Sprintf("Watching %d files for changes (Ctrl-C to stop)", len(files)))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := w.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintf(w.out, "%s: %s\n", theme.Error(i18n.T("Error")), err)
+		}
+	}
+}
+
+// poll checks the workspace once. Changes are collected until a check finds
+// none, so that a burst of saves triggers a single run.
+func (w *watcher) poll(ctx context.Context) error {
+	files, err := scanWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+	changed := changedFiles(w.files, files)
+	w.files = files
+	if len(changed) > 0 {
+		if w.changed == nil {
+			w.changed = map[string]bool{}
+		}
+		for _, path := range changed {
+			w.changed[path] = true
+		}
+		return nil
+	}
+	if len(w.changed) == 0 || w.clock().Sub(w.lastRun) < w.cooldown {
+		return nil
+	}
+	return w.trigger(ctx)
+}
+
+// trigger runs the tests, and the task when they fail or there are no tests
+func (w *watcher) trigger(ctx context.Context) error {
+	paths := make([]string, 0, len(w.changed))
+	for path := range w.changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	w.changed = nil
+
+	prompt := fmt.Sprintf("%s\n\nThese files changed: %s", w.task, strings.Join(paths, ", "))
+	if len(w.test) > 0 {
+		fmt.Fprintln(w.out, i18n.Sprintf("%d files changed; running %s", len(paths), strings.Join(w.test, " ")))
+		output, err := runWatchTest(ctx, w.test)
+		if err == nil {
+			fmt.Fprintln(w.out, theme.Success(i18n.T("Tests pass")))
+			return nil
+		}
+		fmt.Fprintf(w.out, "%s: %s\n", theme.Error(i18n.T("Tests fail")), err)
+		prompt += fmt.Sprintf("\n\n`%s` failed (%s):\n%s", strings.Join(w.test, " "), err, output)
+	} else {
+		fmt.Fprintln(w.out, i18n.Sprintf("%d files changed: %s", len(paths), strings.Join(paths, ", ")))
+	}
+
+	if w.confirm != nil && !w.confirm(i18n.T("Run the task?")) {
+		return nil
+	}
+	err := w.run(ctx, prompt)
+	// the task's own edits are not changes to react to; the cooldown starts
+	// when the task ends
+	if files, scanErr := scanWorkspace(ctx); scanErr == nil {
+		w.files = files
+	}
+	w.lastRun = w.clock()
+	return err
+}
+
+func (w *watcher) clock() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
+
+// runWatchTest runs the test command and returns the end of its output
+func runWatchTest(ctx context.Context, command []string) (string, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	output, err := cmd.CombinedOutput()
+	if len(output) > maxWatchOutput {
+		output = append([]byte("... (earlier output omitted)\n"), output[len(output)-maxWatchOutput:]...)
+	}
+	return string(output), err
+}
+
+// scanWorkspace returns the state of the files in the current directory that
+// are not ignored by .gitignore
+func scanWorkspace(ctx context.Context) (map[string]fileState, error) {
+	all, err := search.CompilePattern("*")
+	if err != nil {
+		return nil, err
+	}
+	paths, _, err := search.Glob(ctx, ".", all, search.Options{})
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return files, nil
+}
+
+// changedFiles returns the files created, modified or deleted between two scans
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+	for path, state := range after {
+		if old, ok := before[path]; !ok || old.size != state.size || !old.modTime.Equal(state.modTime) {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("main.go", []byte("package main\n"), 0644))
+	require.NoError(t, os.WriteFile(".gitignore", []byte("*.log\n"), 0644))
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var prompts []string
+	var out bytes.Buffer
+	w := &watcher{task: "Review the change", cooldown: time.Minute, out: &out, now: func() time.Time { return now }}
+	w.run = func(ctx context.Context, prompt string) error {
+		prompts = append(prompts, prompt)
+		// 任务自己的修改不会再次触发
+		return os.WriteFile("main.go", []byte("package main // fixed\n"), 0644)
+	}
+	files, err := scanWorkspace(ctx)
+	require.NoError(t, err)
+	w.files = files
+
+	require.NoError(t, os.WriteFile("util.go", []byte("package main\n"), 0644))
+	require.NoError(t, os.WriteFile("debug.log", []byte("x"), 0644))
+	require.NoError(t, w.poll(ctx))
+	assert.Empty(t, prompts, "等文件不再变化后才触发")
+	require.NoError(t, w.poll(ctx))
+	require.Len(t, prompts, 1)
+	assert.Equal(t, "Review the change\n\nThese files changed: util.go", prompts[0], "忽略 .gitignore 中的文件")
+	require.NoError(t, w.poll(ctx))
+	assert.Len(t, prompts, 1)
+
+	t.Run("冷却期间不触发", func(t *testing.T) {
+		require.NoError(t, os.Remove("util.go"))
+		require.NoError(t, w.poll(ctx))
+		require.NoError(t, w.poll(ctx))
+		assert.Len(t, prompts, 1)
+
+		now = now.Add(time.Minute)
+		require.NoError(t, w.poll(ctx))
+		require.Len(t, prompts, 2)
+		assert.Contains(t, prompts[1], "These files changed: util.go")
+	})
+
+	t.Run("拒绝时不运行任务", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		var questions []string
+		w.confirm = func(question string) bool {
+			questions = append(questions, question)
+			return false
+		}
+		defer func() { w.confirm = nil }()
+		require.NoError(t, os.WriteFile("util.go", []byte("package main\n"), 0644))
+		require.NoError(t, w.poll(ctx))
+		require.NoError(t, w.poll(ctx))
+		assert.Equal(t, []string{"Run the task?"}, questions)
+		assert.Len(t, prompts, 2)
+	})
+
+	t.Run("只在测试失败时运行任务", func(t *testing.T) {
+		if _, err := exec.LookPath("sh"); err != nil {
+			t.Skip("sh 不可用")
+		}
+		now = now.Add(time.Hour)
+		w.test = []string{"sh", "-c", "test -f broken && echo FAIL: TestMain && exit 1 || exit 0"}
+		require.NoError(t, os.WriteFile("util.go", []byte("package main\n\nfunc f() {}\n"), 0644))
+		require.NoError(t, w.poll(ctx))
+		require.NoError(t, w.poll(ctx))
+		assert.Len(t, prompts, 2)
+		assert.Contains(t, out.String(), "Tests pass")
+
+		require.NoError(t, os.WriteFile("broken", nil, 0644))
+		require.NoError(t, w.poll(ctx))
+		require.NoError(t, w.poll(ctx))
+		require.Len(t, prompts, 3)
+		assert.Contains(t, prompts[2], "These files changed: broken")
+		assert.Contains(t, prompts[2], "FAIL: TestMain")
+	})
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"agent/github"
+
+	"github.com/spf13/cobra"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the body, "sha256=<hex>",
+// on webhook payloads (as GitHub sends it) and on result callbacks
+const webhookSignatureHeader = "X-Hub-Signature-256"
+
+// maxWebhookBody is the largest payload the webhook accepts
+const maxWebhookBody = 1 << 20
+
+// maxQueuedRuns is how many runs can wait while another one runs
+const maxQueuedRuns = 16
+
+// webhookServer starts one-shot runs from webhook payloads, one at a time,
+// and posts their results to a callback:
+//
+//	POST /webhook  {"prompt": "..."}, or a GitHub "issues" event
+//
+// GitHub issues run when they are given the trigger label.
+type webhookServer struct {
+	// newAgent builds an agent with the provider, tools and stores configured
+	newAgent func() (*Agent, error)
+	logger   *slog.Logger
+	// secret verifies payload signatures and signs callbacks. It is required:
+	// without it any web page the user visits could start runs on the listener
+	secret string
+	// label is the issue label that triggers a run
+	label string
+	// callbackURL receives the result of each run as JSON; empty disables it
+	callbackURL string
+	// comment posts the result of a run started by an issue on the issue; nil disables it
+	comment func(ctx context.Context, repo github.Repository, number int, body string) error
+	http    *http.Client
+
+	runs chan webhookRun
+}
+
+// webhookRun is a run waiting in the queue
+type webhookRun struct {
+	ID string
+	// Source describes what started the run: "api" or "owner/repo#12"
+	Source string
+	Prompt string
+	// repo and issue are set for runs started by a GitHub issue
+	repo  github.Repository
+	issue int
+}
+
+// webhookResult is the body posted to the callback when a run ends
+type webhookResult struct {
+	RunID  string `json:"run_id"`
+	Source string `json:"source"`
+	runResult
+}
+
+// githubIssueEvent is the part of a GitHub "issues" event the webhook reads
+type githubIssueEvent struct {
+	Action string `json:"action"`
+	Label  struct {
+		Name string `json:"name"`
+	} `json:"label"`
+	Issue struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	} `json:"issue"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+func newWebhookServer(newAgent func() (*Agent, error), logger *slog.Logger) *webhookServer {
+	return &webhookServer{
+		newAgent: newAgent,
+		logger:   logger,
+		label:    "agent",
+		http:     &http.Client{Timeout: 30 * time.Second},
+		runs:     make(chan webhookRun, maxQueuedRuns),
+	}
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/webhook" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(body) > maxWebhookBody {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("payload larger than %d bytes", maxWebhookBody))
+		return
+	}
+	if s.secret == "" || !hmac.Equal([]byte(r.Header.Get(webhookSignatureHeader)), []byte(signWebhook(s.secret, body))) {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid %s signature", webhookSignatureHeader))
+		return
+	}
+
+	run, err := s.parse(r.Header.Get("X-GitHub-Event"), body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if run == nil {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+		return
+	}
+	select {
+	case s.runs <- *run:
+		s.logger.Info("webhook run queued", "run", run.ID, "source", run.Source)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued", "run_id": run.ID})
+	default:
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("too many queued runs"))
+	}
+}
+
+// parse returns the run a payload asks for, or nil if it asks for none
+func (s *webhookServer) parse(event string, body []byte) (*webhookRun, error) {
+	switch event {
+	case "":
+		var payload struct {
+			Prompt string `json:"prompt"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		if strings.TrimSpace(payload.Prompt) == "" {
+			return nil, fmt.Errorf("prompt must not be empty")
+		}
+		return &webhookRun{ID: newRunID(), Source: "api", Prompt: payload.Prompt}, nil
+	case "issues":
+		var payload githubIssueEvent
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		if payload.Action != "labeled" || payload.Label.Name != s.label {
+			return nil, nil
+		}
+		owner, name, ok := strings.Cut(payload.Repository.FullName, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid repository %q", payload.Repository.FullName)
+		}
+		issue := payload.Issue
+		return &webhookRun{
+			ID:     newRunID(),
+			Source: fmt.Sprintf("%s#%d", payload.Repository.FullName, issue.Number),
+			Prompt: fmt.Sprintf("Resolve GitHub issue #%d in %s: %s\n%s\n\n%s", issue.Number, payload.Repository.FullName, issue.Title, issue.HTMLURL, strings.TrimSpace(issue.Body)),
+			repo:   github.Repository{Owner: owner, Name: name},
+			issue:  issue.Number,
+		}, nil
+	default:
+		// ping and events other than issues start nothing
+		return nil, nil
+	}
+}
+
+// work runs queued runs one at a time until ctx is done
+func (s *webhookServer) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case run := <-s.runs:
+			s.run(ctx, run)
+		}
+	}
+}
+
+// run runs one prompt to completion and reports its result
+func (s *webhookServer) run(ctx context.Context, run webhookRun) {
+	result := webhookResult{RunID: run.ID, Source: run.Source, runResult: runResult{Type: EventResult, Status: "error"}}
+	agent, err := s.newAgent()
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		var out bytes.Buffer
+		runPrompt(ctx, agent, run.Prompt, outputJSON, &out)
+		if err := json.Unmarshal(out.Bytes(), &result.runResult); err != nil {
+			result.Error = fmt.Sprintf("run produced no result: %s", err)
+		}
+		result.Events = nil
+	}
+	s.logger.Info("webhook run finished", "run", run.ID, "status", result.Status, "error", result.Error)
+
+	if s.callbackURL != "" {
+		if err := s.callback(ctx, result); err != nil {
+			s.logger.Warn("webhook callback failed", "run", run.ID, "error", err)
+		}
+	}
+	if s.comment != nil && run.issue != 0 {
+		body := result.Result
+		if result.Error != "" {
+			body = fmt.Sprintf("The agent run failed: %s", result.Error)
+		}
+		if err := s.comment(ctx, run.repo, run.issue, body); err != nil {
+			s.logger.Warn("commenting on the issue failed", "run", run.ID, "error", err)
+		}
+	}
+}
+
+// callback posts the result to the callback URL, signed with the secret
+func (s *webhookServer) callback(ctx context.Context, result webhookResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != "" {
+		req.Header.Set(webhookSignatureHeader, signWebhook(s.secret, body))
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhook returns the signature header value of body
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newRunID() string {
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func newWebhookCommand(global *globalOptions) *cobra.Command {
+	var addr, secret, label, callbackURL string
+	var comment bool
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Start one-shot runs from incoming webhooks",
+		Long: `Listen for webhooks on POST /webhook and run each one to completion,
+one at a time, in the current directory:
+
+  {"prompt": "..."}                run the prompt
+  GitHub "issues" event            run when an issue is given --label
+
+Prometheus metrics are served on GET /metrics.
+
+When a run ends, its result (like "agent run --output json", plus run_id
+and source) is posted to --callback-url, and with --comment it is also
+posted as a comment on the issue that started it.
+
+Payloads must be signed with --secret (or AGENT_WEBHOOK_SECRET), which is
+required, like GitHub webhooks: with an X-Hub-Signature-256 HMAC header.
+Callbacks are signed the same way.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			logger := global.logger(os.Stderr, slog.LevelInfo)
+			m := newMetrics()
+			newAgent, _, err := serverAgents(global, logger, m)
+			if err != nil {
+				return err
+			}
+			webhook := newWebhookServer(newAgent, logger)
+			webhook.secret = secret
+			if webhook.secret == "" {
+				webhook.secret = os.Getenv("AGENT_WEBHOOK_SECRET")
+			}
+			if webhook.secret == "" {
+				return fmt.Errorf("webhook needs --secret or AGENT_WEBHOOK_SECRET: without one anyone who can reach the listener, including web pages you visit, can start runs")
+			}
+			webhook.label = label
+			webhook.callbackURL = callbackURL
+			if comment {
+				cfg := global.cfg().GitHub
+				token, _ := lookupAPIKey("github", cfg.TokenEnv)
+				if token == "" {
+					return fmt.Errorf("--comment needs a GitHub token: set GITHUB_TOKEN or run `agent auth login github`")
+				}
+				client := &github.Client{BaseURL: cfg.APIURL, Token: token}
+				webhook.comment = func(ctx context.Context, repo github.Repository, number int, body string) error {
+					_, err := client.CreateIssueComment(ctx, repo, number, body)
+					return err
+				}
+			}
+
+			server := &http.Server{Addr: addr, Handler: m.serve(webhook)}
+			go webhook.work(ctx)
+			go func() {
+				<-ctx.Done()
+				shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				server.Shutdown(shutdown)
+			}()
+			fmt.Fprintf(cmd.ErrOrStderr(), "Listening for webhooks on http://%s/webhook\n", addr)
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8090", "address to listen on")
+	cmd.Flags().StringVar(&secret, "secret", "", "shared secret for payload and callback signatures")
+	cmd.Flags().StringVar(&label, "label", "agent", "issue label that triggers a run")
+	cmd.Flags().StringVar(&callbackURL, "callback-url", "", "URL to post each run's result to")
+	cmd.Flags().BoolVar(&comment, "comment", false, "post the result as a comment on the GitHub issue that started the run")
+	return cmd
+}
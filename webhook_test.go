@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agent/github"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWebhook 启动一个 webhook 服务，运行结果回调到返回的通道
+func newTestWebhook(t *testing.T, newAgent func() *Agent, secret string) (*httptest.Server, *webhookServer, chan webhookResult) {
+	t.Helper()
+	results := make(chan webhookResult, 4)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if secret != "" {
+			assert.Equal(t, signWebhook(secret, body), r.Header.Get(webhookSignatureHeader))
+		}
+		var result webhookResult
+		assert.NoError(t, json.Unmarshal(body, &result))
+		results <- result
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	webhook := newWebhookServer(func() (*Agent, error) { return newAgent(), nil }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	webhook.secret = secret
+	webhook.callbackURL = callback.URL
+	go webhook.work(ctx)
+	server := httptest.NewServer(webhook)
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+		callback.Close()
+	})
+	return server, webhook, results
+}
+
+func postWebhook(t *testing.T, url, event, secret, body string) (int, map[string]string) {
+	t.Helper()
+	req, err := http.NewRequest("POST", url+"/webhook", strings.NewReader(body))
+	require.NoError(t, err)
+	if event != "" {
+		req.Header.Set("X-GitHub-Event", event)
+	}
+	if secret != "" {
+		req.Header.Set(webhookSignatureHeader, signWebhook(secret, []byte(body)))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var out map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	return resp.StatusCode, out
+}
+
+func waitResult(t *testing.T, results chan webhookResult) webhookResult {
+	t.Helper()
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback")
+		return webhookResult{}
+	}
+}
+
+const labeledIssue = `{"action": "labeled", "label": {"name": "agent"}, "issue": {"number": 12, "title": "模块名是什么？", "body": "请查看 go.mod", "html_url": "https://github.com/owner/repo/issues/12"}, "repository": {"full_name": "owner/repo"}}`
+
+func TestWebhook(t *testing.T) {
+	t.Run("提示词触发运行并回调结果", func(t *testing.T) {
+		server, _, results := newTestWebhook(t, oneShotAgent, "s3cret")
+		status, out := postWebhook(t, server.URL, "", "s3cret", `{"prompt": "模块名是什么？"}`)
+		assert.Equal(t, http.StatusAccepted, status)
+		assert.Equal(t, "queued", out["status"])
+
+		result := waitResult(t, results)
+		assert.Equal(t, out["run_id"], result.RunID)
+		assert.Equal(t, "api", result.Source)
+		assert.Equal(t, "success", result.Status)
+		assert.Equal(t, "模块名是 agent", result.Result)
+		assert.Empty(t, result.Events)
+	})
+
+	t.Run("带标签的 GitHub issue 触发运行并评论结果", func(t *testing.T) {
+		server, webhook, results := newTestWebhook(t, oneShotAgent, "s3cret")
+		comments := make(chan string, 1)
+		webhook.comment = func(ctx context.Context, repo github.Repository, number int, body string) error {
+			comments <- fmt.Sprintf("%s#%d: %s", repo, number, body)
+			return nil
+		}
+
+		status, _ := postWebhook(t, server.URL, "issues", "s3cret", labeledIssue)
+		assert.Equal(t, http.StatusAccepted, status)
+		result := waitResult(t, results)
+		assert.Equal(t, "owner/repo#12", result.Source)
+		assert.Equal(t, "owner/repo#12: 模块名是 agent", <-comments)
+	})
+
+	t.Run("签名错误时拒绝", func(t *testing.T) {
+		server, _, _ := newTestWebhook(t, oneShotAgent, "s3cret")
+		status, _ := postWebhook(t, server.URL, "issues", "wrong", labeledIssue)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		status, _ = postWebhook(t, server.URL, "", "", `{"prompt": "hi"}`)
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("没有密钥时拒绝所有请求", func(t *testing.T) {
+		server, _, _ := newTestWebhook(t, oneShotAgent, "")
+		status, _ := postWebhook(t, server.URL, "", "", `{"prompt": "hi"}`)
+		assert.Equal(t, http.StatusUnauthorized, status, "网页可以向本机端口提交表单")
+	})
+
+	t.Run("忽略其他事件和标签", func(t *testing.T) {
+		server, _, _ := newTestWebhook(t, oneShotAgent, "s3cret")
+		for _, tc := range []struct{ event, body string }{
+			{"ping", `{"zen": "Keep it simple."}`},
+			{"issues", strings.Replace(labeledIssue, `"name": "agent"`, `"name": "bug"`, 1)},
+			{"issues", strings.Replace(labeledIssue, `"labeled"`, `"opened"`, 1)},
+		} {
+			status, out := postWebhook(t, server.URL, tc.event, "s3cret", tc.body)
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, "ignored", out["status"])
+		}
+	})
+
+	t.Run("空提示词", func(t *testing.T) {
+		server, _, _ := newTestWebhook(t, oneShotAgent, "s3cret")
+		status, out := postWebhook(t, server.URL, "", "s3cret", `{"prompt": " "}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "prompt must not be empty", out["error"])
+	})
+}
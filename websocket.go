@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// EventError reports a WebSocket client message the server could not act on
+const EventError = "error"
+
+// clientMessage is a message from a WebSocket client:
+//
+//	{"type": "message", "content": "..."}          start a turn
+//	{"type": "approval", "id": "...", "approved": true}  answer an approval_request
+type clientMessage struct {
+	Type     string `json:"type"`
+	Content  string `json:"content,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Approved bool   `json:"approved,omitempty"`
+}
+
+// upgrader only accepts same-origin browser connections (the default check)
+var upgrader = websocket.Upgrader{}
+
+// serveWebSocket streams the session's events to the client as JSON text
+// messages, from ?since=N or new events only, and acts on the messages the
+// client sends back. Clients are pinged every heartbeat and dropped when no
+// pong or message arrives for two heartbeats, so dead connections do not
+// keep their session loaded.
+func (s *apiServer) serveWebSocket(w http.ResponseWriter, r *http.Request, live *liveSession) {
+	next, err := eventIndex(r, live)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		return
+	}
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	send := func(v interface{}) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(v)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if s.heartbeat > 0 {
+		alive := func() error { return conn.SetReadDeadline(time.Now().Add(2 * s.heartbeat)) }
+		alive()
+		conn.SetPongHandler(func(string) error { return alive() })
+		go s.ping(ctx, cancel, conn)
+	}
+	go func() {
+		// a read error means the client went away
+		defer cancel()
+		for {
+			var msg clientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				var timeout net.Error
+				if _, ok := err.(*websocket.CloseError); ok || ctx.Err() != nil || (errors.As(err, &timeout) && timeout.Timeout()) {
+					return
+				}
+				if send(AgentEvent{Type: EventError, Content: fmt.Sprintf("invalid message: %s", err)}) != nil {
+					return
+				}
+				continue
+			}
+			if s.heartbeat > 0 {
+				conn.SetReadDeadline(time.Now().Add(2 * s.heartbeat))
+			}
+			if err := s.handleClientMessage(live, msg); err != nil {
+				send(AgentEvent{Type: EventError, Content: err.Error()})
+			}
+		}
+	}()
+
+	s.follow(ctx, live, next, func(index int, e AgentEvent) error {
+		return send(e)
+	})
+}
+
+// ping sends a ping every heartbeat until ctx is done, and cancels the
+// connection when one cannot be sent
+func (s *apiServer) ping(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn) {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.heartbeat)); err != nil {
+				cancel()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *apiServer) handleClientMessage(live *liveSession, msg clientMessage) error {
+	switch msg.Type {
+	case "message":
+		if strings.TrimSpace(msg.Content) == "" {
+			return fmt.Errorf("content must not be empty")
+		}
+		_, err := s.startTurn(live, msg.Content)
+		return err
+	case "approval":
+		return live.decide(msg.ID, msg.Approved)
+	default:
+		return fmt.Errorf("unknown message type %q", msg.Type)
+	}
+}
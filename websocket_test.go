@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"agent/pkg/provider"
+	"agent/tools"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// editAgent 返回一个先调用 edit_file 创建 hello.txt 再结束的代理
+func editAgent() *Agent {
+	provider := &mockProvider{responses: []*Response{
+		{ToolCalls: []ToolCall{{ID: "1", Name: "edit_file", Input: []byte(`{"path":"hello.txt","old_str":"","new_str":"你好"}`)}}},
+		{Content: "完成"},
+	}}
+	return NewAgent(provider, nil, builtinTools())
+}
+
+// streamingAgent 的模型把回复分两段流式发送
+func streamingAgent() *Agent {
+	return NewAgent(ProviderFunc(func(ctx context.Context, _ []Message, _ []tools.ToolDefinition) (*Response, error) {
+		if onDelta := provider.TextDeltaFrom(ctx); onDelta != nil {
+			onDelta("你")
+			onDelta("好")
+		}
+		return &Response{Content: "你好"}, nil
+	}), nil, nil)
+}
+
+// dialSession 创建一个会话并连接它的 WebSocket 端点
+func dialSession(t *testing.T, serverURL string) *websocket.Conn {
+	t.Helper()
+	var created map[string]string
+	doJSON(t, "POST", serverURL+"/sessions", "", &created)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(serverURL, "http")+"/sessions/"+created["id"]+"/ws", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// nextEvent 读取下一个指定类型的事件，跳过其他事件
+func nextEvent(t *testing.T, conn *websocket.Conn, eventType string) AgentEvent {
+	t.Helper()
+	for {
+		var e AgentEvent
+		require.NoError(t, conn.ReadJSON(&e))
+		if e.Type == eventType {
+			return e
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	t.Run("发送消息并接收事件", func(t *testing.T) {
+		server, _ := newTestAPI(t, oneShotAgent, false)
+		conn := dialSession(t, server.URL)
+
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "message", Content: "模块名是什么？"}))
+		assert.Equal(t, "模块名是什么？", nextEvent(t, conn, EventUserMessage).Content)
+		assert.Equal(t, "read_file", nextEvent(t, conn, EventToolCall).ToolCall.Name)
+		assert.Equal(t, "模块名是 agent", nextEvent(t, conn, EventAssistantText).Content)
+		assert.Empty(t, nextEvent(t, conn, EventTurnDone).Content)
+	})
+
+	t.Run("流式发送回复的文本片段", func(t *testing.T) {
+		server, _ := newTestAPI(t, streamingAgent, false)
+		conn := dialSession(t, server.URL)
+
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "message", Content: "你好"}))
+		assert.Equal(t, "你", nextEvent(t, conn, EventTextDelta).Content)
+		assert.Equal(t, "好", nextEvent(t, conn, EventTextDelta).Content)
+		assert.Equal(t, "你好", nextEvent(t, conn, EventAssistantText).Content)
+		nextEvent(t, conn, EventTurnDone)
+	})
+
+	t.Run("批准工具调用", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		server, _ := newTestAPI(t, editAgent, true)
+		conn := dialSession(t, server.URL)
+
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "message", Content: "创建文件"}))
+		request := nextEvent(t, conn, EventApprovalRequest)
+		assert.Equal(t, "edit_file", request.ToolCall.Name)
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "approval", ID: request.ToolCall.ID, Approved: true}))
+		assert.Equal(t, "hello.txt", nextEvent(t, conn, EventFileEdit).Path)
+		nextEvent(t, conn, EventTurnDone)
+	})
+
+	t.Run("拒绝的工具调用不会执行，错误告知模型", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		server, _ := newTestAPI(t, editAgent, true)
+		conn := dialSession(t, server.URL)
+
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "message", Content: "创建文件"}))
+		request := nextEvent(t, conn, EventApprovalRequest)
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "approval", ID: request.ToolCall.ID, Approved: false}))
+		assert.Contains(t, nextEvent(t, conn, EventToolError).Content, "edit_file denied by the user")
+		nextEvent(t, conn, EventTurnDone)
+		assert.NoFileExists(t, "hello.txt")
+	})
+
+	t.Run("无效的消息返回错误事件", func(t *testing.T) {
+		server, _ := newTestAPI(t, oneShotAgent, false)
+		conn := dialSession(t, server.URL)
+
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "unknown"}))
+		assert.Contains(t, nextEvent(t, conn, EventError).Content, "unknown message type")
+		require.NoError(t, conn.WriteJSON(clientMessage{Type: "approval", ID: "42", Approved: true}))
+		assert.Contains(t, nextEvent(t, conn, EventError).Content, "awaiting approval")
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+		assert.Contains(t, nextEvent(t, conn, EventError).Content, "invalid message")
+	})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"agent/config"
+	"agent/prompts"
+	"agent/tools"
+	"agent/workflows"
+
+	"github.com/spf13/cobra"
+)
+
+// workflowDir holds the project's workflow definitions, relative to the
+// project root; they take precedence over the user's
+var workflowDir = filepath.Join(".agent", "workflows")
+
+// defaultWorkflowStore finds workflows in the project, then in the config directory
+func defaultWorkflowStore(cfg *config.Config) (*workflows.Store, error) {
+	dir, err := agentConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	root := cfg.ProjectDir
+	if root == "" {
+		root = currentWorkspace.Root
+	}
+	return &workflows.Store{Dirs: []string{filepath.Join(root, workflowDir), filepath.Join(dir, "workflows")}}, nil
+}
+
+// workflowRun runs a workflow's steps as turns of one session
+type workflowRun struct {
+	agent    *Agent
+	workflow *workflows.Workflow
+	// prompts are the steps' messages with the placeholders filled in
+	prompts []string
+	// confirm stops at checkpoints for the user; --yes runs straight through
+	confirm bool
+	out     io.Writer
+}
+
+// run works through the steps, each with only the tools it allows. At a
+// checkpoint the user continues, stops, or sends the model a message first,
+// e.g. to correct the triage before the fix starts.
+func (r *workflowRun) run(ctx context.Context) error {
+	all := r.agent.toolDefinitions()
+	defer r.agent.setTools(all)
+	steps := make([][]tools.ToolDefinition, len(r.workflow.Steps))
+	for i, step := range r.workflow.Steps {
+		steps[i] = all
+		if len(step.Tools) > 0 {
+			selected, err := selectTools(all, step.Tools)
+			if err != nil {
+				return fmt.Errorf("step %s: %w", step.Name, err)
+			}
+			steps[i] = selected
+		}
+	}
+
+	for i, step := range r.workflow.Steps {
+		fmt.Fprintf(r.out, "== Step %d/%d: %s\n", i+1, len(r.workflow.Steps), step.Name)
+		r.agent.setTools(steps[i])
+		if err := r.turn(ctx, r.prompts[i]); err != nil {
+			return err
+		}
+		if !step.Checkpoint || !r.confirm || i == len(r.workflow.Steps)-1 {
+			continue
+		}
+		for {
+			fmt.Fprintf(r.out, "Checkpoint after %s. Continue with %s? [Y/n, or a message for the model]: ", step.Name, r.workflow.Steps[i+1].Name)
+			answer, ok := r.agent.readInput()
+			answer = strings.TrimSpace(answer)
+			if !ok || strings.EqualFold(answer, "n") || strings.EqualFold(answer, "no") {
+				fmt.Fprintf(r.out, "Stopped the workflow; continue the session with agent chat --resume %s\n", r.agent.session.ID)
+				return nil
+			}
+			if answer == "" || strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes") {
+				break
+			}
+			if err := r.turn(ctx, answer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// turn runs one turn and saves the session, so a stopped workflow can be resumed
+func (r *workflowRun) turn(ctx context.Context, prompt string) error {
+	err := r.agent.runTurn(ctx, prompt)
+	if saveErr := r.agent.saveSession(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+func newWorkflowCommand(global *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workflow",
+		Short: "Run recurring workflows: sequences of steps with prompts, tools and checkpoints",
+		Long: "Workflows are YAML files in .agent/workflows of the project or workflows in the config directory,\n" +
+			"one per workflow, e.g. bugfix.yaml:\n\n" +
+			"  description: Triage, reproduce and fix a bug\n" +
+			"  steps:\n" +
+			"    - name: triage\n" +
+			"      prompt: Read issue {{issue}} and find the code involved.\n" +
+			"      tools: [read_file, grep, glob]\n" +
+			"      checkpoint: true\n" +
+			"    - name: fix\n" +
+			"      prompt: Reproduce {{issue}} with a failing test, then fix it.\n",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the workflows",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := defaultWorkflowStore(global.cfg())
+			if err != nil {
+				return err
+			}
+			list, err := store.List()
+			if err != nil {
+				return err
+			}
+			if len(list) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No workflows; add <name>.yaml files to %s\n", strings.Join(store.Dirs, " or "))
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSTEPS\tDESCRIPTION")
+			for _, workflow := range list {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", workflow.Name, len(workflow.Steps), workflow.Description)
+			}
+			return w.Flush()
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <name>",
+		Short: "Show a workflow's steps",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := defaultWorkflowStore(global.cfg())
+			if err != nil {
+				return err
+			}
+			workflow, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+			writeWorkflow(cmd.OutOrStdout(), workflow)
+			return nil
+		},
+	})
+
+	var yes bool
+	run := &cobra.Command{
+		Use:   "run <name> [key=value...]",
+		Short: "Run a workflow in a new session; the arguments fill its {{placeholders}}",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := defaultWorkflowStore(global.cfg())
+			if err != nil {
+				return err
+			}
+			workflow, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+			values, rest := prompts.ParseArgs(args[1:])
+			if len(rest) > 0 {
+				return fmt.Errorf("arguments must be key=value: %s", strings.Join(rest, " "))
+			}
+			rendered, err := workflow.Render(values)
+			if err != nil {
+				return err
+			}
+
+			scanner := bufio.NewScanner(cmd.InOrStdin())
+			agent := NewAgent(nil, func() (string, bool) {
+				if !scanner.Scan() {
+					return "", false
+				}
+				return scanner.Text(), true
+			}, nil)
+			agent.logger = global.logger(os.Stderr, slog.LevelWarn)
+			if _, err := agent.applyConfig(global.cfg()); err != nil {
+				return err
+			}
+			if store, err := DefaultSessionStore(); err == nil {
+				agent.store = store
+			}
+			if audit, err := defaultAuditLog(global.cfg()); err == nil {
+				agent.audit = audit
+			}
+			r := &workflowRun{agent: agent, workflow: workflow, prompts: rendered, confirm: !yes, out: cmd.OutOrStdout()}
+			return r.run(cmd.Context())
+		},
+	}
+	run.Flags().BoolVarP(&yes, "yes", "y", false, "run through the checkpoints without stopping, e.g. in scripts")
+	cmd.AddCommand(run)
+	return cmd
+}
+
+// writeWorkflow prints a workflow's steps with their tools and checkpoints
+func writeWorkflow(w io.Writer, workflow *workflows.Workflow) {
+	if workflow.Description != "" {
+		fmt.Fprintln(w, workflow.Description)
+	}
+	if placeholders := workflow.Placeholders(); len(placeholders) > 0 {
+		fmt.Fprintf(w, "Placeholders: %s\n", strings.Join(placeholders, ", "))
+	}
+	for i, step := range workflow.Steps {
+		fmt.Fprintf(w, "\n%d. %s\n", i+1, step.Name)
+		fmt.Fprintf(w, "   %s\n", strings.ReplaceAll(strings.TrimSpace(step.Prompt), "\n", "\n   "))
+		if len(step.Tools) > 0 {
+			fmt.Fprintf(w, "   tools: %s\n", strings.Join(step.Tools, ", "))
+		}
+		if step.Checkpoint {
+			fmt.Fprintln(w, "   checkpoint: waits for you before the next step")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"agent/workflows"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflowRun(t *testing.T) {
+	workflow := &workflows.Workflow{Name: "bugfix", Steps: []workflows.Step{
+		{Name: "triage", Prompt: "Triage #12", Tools: []string{"read_file"}, Checkpoint: true},
+		{Name: "fix", Prompt: "Fix #12"},
+	}}
+	newRun := func(provider *mockProvider, answers ...string) (*workflowRun, *bytes.Buffer) {
+		agent := NewAgent(provider, func() (string, bool) {
+			if len(answers) == 0 {
+				return "", false
+			}
+			answer := answers[0]
+			answers = answers[1:]
+			return answer, true
+		}, builtinTools())
+		agent.onEvent = func(AgentEvent) {}
+		var out bytes.Buffer
+		return &workflowRun{agent: agent, workflow: workflow, prompts: []string{"Triage #12", "Fix #12"}, confirm: true, out: &out}, &out
+	}
+
+	t.Run("检查点确认后继续，每一步只有列出的工具", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "Found it."}, {Content: "Fixed."}}}
+		run, out := newRun(provider, "")
+		all := len(run.agent.toolDefinitions())
+		require.NoError(t, run.run(context.Background()))
+
+		require.Len(t, provider.calls, 2)
+		assert.Equal(t, "Fix #12", provider.calls[1][len(provider.calls[1])-1].Content)
+		assert.Contains(t, out.String(), "== Step 1/2: triage")
+		assert.Contains(t, out.String(), "Continue with fix?")
+		assert.Len(t, run.agent.toolDefinitions(), all, "结束后恢复全部工具")
+	})
+
+	t.Run("检查点输入的消息先发给模型", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "Found it."}, {Content: "Looked again."}, {Content: "Fixed."}}}
+		run, _ := newRun(provider, "Check the parser too", "y")
+		require.NoError(t, run.run(context.Background()))
+		require.Len(t, provider.calls, 3)
+		assert.Equal(t, "Check the parser too", provider.calls[1][len(provider.calls[1])-1].Content)
+	})
+
+	t.Run("在检查点停止", func(t *testing.T) {
+		provider := &mockProvider{responses: []*Response{{Content: "Found it."}}}
+		run, out := newRun(provider, "n")
+		require.NoError(t, run.run(context.Background()))
+		assert.Len(t, provider.calls, 1)
+		assert.Contains(t, out.String(), "Stopped the workflow")
+	})
+
+	t.Run("未知的工具在开始前报错", func(t *testing.T) {
+		provider := &mockProvider{}
+		run, _ := newRun(provider)
+		run.workflow = &workflows.Workflow{Name: "bad", Steps: []workflows.Step{{Name: "a", Prompt: "x", Tools: []string{"teleport"}}}}
+		assert.ErrorContains(t, run.run(context.Background()), "teleport")
+		assert.Empty(t, provider.calls)
+	})
+}
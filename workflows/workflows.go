@@ -0,0 +1,155 @@
+// Package workflows 读取工作流定义：由若干步骤组成的固定流程，例如
+// 分诊 → 复现 → 修复 → 测试 → 提交，每一步是发给模型的一条消息，可以限定
+// 可用的工具，并在完成后暂停等待用户确认
+package workflows
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"agent/prompts"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow 是一个具名的工作流，定义在 <name>.yaml 文件中
+type Workflow struct {
+	Name string `yaml:"-"`
+	// Description 是列表中显示的说明
+	Description string `yaml:"description,omitempty"`
+	Steps       []Step `yaml:"steps"`
+}
+
+// Step 是工作流中的一步
+type Step struct {
+	Name string `yaml:"name"`
+	// Prompt 是这一步发给模型的消息，可以包含 {{占位符}}
+	Prompt string `yaml:"prompt"`
+	// Tools 非空时这一步只提供其中列出的工具，例如分诊时只允许读文件
+	Tools []string `yaml:"tools,omitempty"`
+	// Checkpoint 表示这一步完成后暂停，用户确认后才继续下一步
+	Checkpoint bool `yaml:"checkpoint,omitempty"`
+}
+
+// Parse 解析并检查名为 name 的工作流定义
+func Parse(name string, data []byte) (*Workflow, error) {
+	var w Workflow
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&w); err != nil {
+		return nil, fmt.Errorf("invalid workflow %s: %w", name, err)
+	}
+	w.Name = name
+	if len(w.Steps) == 0 {
+		return nil, fmt.Errorf("workflow %s has no steps", name)
+	}
+	for i, step := range w.Steps {
+		if strings.TrimSpace(step.Prompt) == "" {
+			return nil, fmt.Errorf("step %d of workflow %s has no prompt", i+1, name)
+		}
+		if step.Name == "" {
+			w.Steps[i].Name = fmt.Sprintf("step %d", i+1)
+		}
+	}
+	return &w, nil
+}
+
+// Placeholders 返回全部步骤中的占位符名称，按首次出现的顺序去重
+func (w *Workflow) Placeholders() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, step := range w.Steps {
+		for _, name := range (&prompts.Template{Body: step.Prompt}).Placeholders() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Render 用 values 填充各步骤的消息，缺少任何值时返回错误，一步也不填充
+func (w *Workflow) Render(values map[string]string) ([]string, error) {
+	missing := []string{}
+	for _, name := range w.Placeholders() {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("workflow %s needs values for: %s", w.Name, strings.Join(missing, ", "))
+	}
+	rendered := make([]string, len(w.Steps))
+	for i, step := range w.Steps {
+		prompt, err := (&prompts.Template{Name: w.Name, Body: step.Prompt}).Render(values)
+		if err != nil {
+			return nil, err
+		}
+		rendered[i] = prompt
+	}
+	return rendered, nil
+}
+
+// Store 从目录中加载工作流，每个 <name>.yaml 文件是一个工作流。Dirs 按优先级
+// 排列，前面目录中的工作流覆盖后面目录中的同名工作流
+type Store struct {
+	Dirs []string
+}
+
+// Load 按名称读取工作流
+func (s *Store) Load(name string) (*Workflow, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid workflow name %q", name)
+	}
+	for _, dir := range s.Dirs {
+		data, err := os.ReadFile(filepath.Join(dir, name+".yaml"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return Parse(name, data)
+	}
+	return nil, fmt.Errorf("workflow %s not found in %s", name, strings.Join(s.Dirs, ", "))
+}
+
+// List 返回全部工作流，按名称排序；不存在的目录被忽略，无效的定义返回错误
+func (s *Store) List() ([]*Workflow, error) {
+	byName := map[string]*Workflow{}
+	for _, dir := range s.Dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), ".yaml")
+			if _, ok := byName[name]; ok || entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			w, err := Parse(name, data)
+			if err != nil {
+				return nil, err
+			}
+			byName[name] = w
+		}
+	}
+	list := make([]*Workflow, 0, len(byName))
+	for _, w := range byName {
+		list = append(list, w)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
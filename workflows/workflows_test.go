@@ -0,0 +1,81 @@
+package workflows
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bugfix = `
+description: Triage, reproduce and fix a bug
+steps:
+  - name: triage
+    prompt: Read issue {{issue}} and find the code involved.
+    tools: [read_file, grep]
+    checkpoint: true
+  - name: fix
+    prompt: Fix {{issue}} with a regression test for {{ area }}.
+  - prompt: Commit the fix.
+`
+
+func TestParse(t *testing.T) {
+	t.Run("解析步骤", func(t *testing.T) {
+		w, err := Parse("bugfix", []byte(bugfix))
+		require.NoError(t, err)
+		assert.Equal(t, "bugfix", w.Name)
+		require.Len(t, w.Steps, 3)
+		assert.Equal(t, []string{"read_file", "grep"}, w.Steps[0].Tools)
+		assert.True(t, w.Steps[0].Checkpoint)
+		assert.Equal(t, "step 3", w.Steps[2].Name, "没有名称的步骤按序号命名")
+		assert.Equal(t, []string{"issue", "area"}, w.Placeholders())
+	})
+
+	t.Run("填充占位符", func(t *testing.T) {
+		w, err := Parse("bugfix", []byte(bugfix))
+		require.NoError(t, err)
+		prompts, err := w.Render(map[string]string{"issue": "#12", "area": "the parser"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"Read issue #12 and find the code involved.",
+			"Fix #12 with a regression test for the parser.",
+			"Commit the fix.",
+		}, prompts)
+
+		_, err = w.Render(map[string]string{"issue": "#12"})
+		assert.ErrorContains(t, err, "area")
+	})
+
+	t.Run("无效的定义", func(t *testing.T) {
+		for _, bad := range []string{"steps: []\n", "steps: [{name: a}]\n", "steps: [{prompt: x, checkpiont: true}]\n", "steps: x\n"} {
+			_, err := Parse("bad", []byte(bad))
+			assert.Error(t, err, bad)
+		}
+	})
+}
+
+func TestStore(t *testing.T) {
+	project, user := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(user, "bugfix.yaml"), []byte(bugfix), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(user, "release.yaml"), []byte("steps: [{prompt: Tag a release}]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "release.yaml"), []byte("description: project release\nsteps: [{prompt: Run make release}]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "notes.md"), []byte("not a workflow"), 0644))
+	store := &Store{Dirs: []string{project, user, filepath.Join(user, "missing")}}
+
+	w, err := store.Load("release")
+	require.NoError(t, err)
+	assert.Equal(t, "project release", w.Description, "项目中的工作流优先")
+
+	list, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "bugfix", list[0].Name)
+	assert.Equal(t, "project release", list[1].Description)
+
+	_, err = store.Load("deploy")
+	assert.ErrorContains(t, err, "not found")
+	_, err = store.Load("../bugfix")
+	assert.ErrorContains(t, err, "invalid workflow name")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agent/gitutil"
+	"agent/i18n"
+	"agent/pkg/message"
+)
+
+// changeWorkspace makes dir the workspace. The file tools, the sandbox and the
+// project config all resolve paths against the working directory, so the
+// workspace is the process's working directory; it returns the absolute path.
+func changeWorkspace(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	return abs, os.Chdir(abs)
+}
+
+// runCdCommand prints the workspace, or moves the session to another one: the
+// project config, tools and instructions are loaded again from there, files
+// read before must be read again, and auto-commit follows to the new
+// repository
+func runCdCommand(a *Agent, args []string) error {
+	if len(args) == 0 {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		fmt.Println(cwd)
+		return nil
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /cd [DIR]")
+	}
+	if a.resolveProfile == nil {
+		return fmt.Errorf("switching workspaces is not supported here")
+	}
+
+	previous, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	dir, err := changeWorkspace(args[0])
+	if err != nil {
+		return err
+	}
+	cfg, err := a.resolveProfile(a.config.Profile)
+	if err == nil {
+		_, err = a.applyConfig(cfg)
+	}
+	if err != nil {
+		// stay where the current tools and config point
+		if chdirErr := os.Chdir(previous); chdirErr != nil {
+			return errors.Join(err, chdirErr)
+		}
+		return err
+	}
+
+	a.reads.Reset()
+	// pinned paths are relative to the previous workspace
+	a.setPins(nil)
+	if a.repo != nil {
+		repo, err := gitutil.Open(".")
+		if err != nil {
+			a.repo = nil
+			fmt.Println(i18n.Sprintf("Auto-commit disabled: %s", err))
+		} else {
+			a.repo = repo
+		}
+	}
+	// paths the model saw so far are relative to the previous workspace
+	a.appendMessages(Message{
+		Role:    "user",
+		Content: fmt.Sprintf("The workspace is now %s; relative paths resolve against it.", dir),
+		Meta:    message.Now(),
+	})
+	fmt.Println(i18n.Sprintf("Workspace: %s", dir))
+	warnUntrustedProject(os.Stdout, cfg)
+	return nil
+}
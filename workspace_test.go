@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agent/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCdCommand(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(second, ".agent.yaml"), []byte("tools:\n  disabled: [shell]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(second, "notes.txt"), []byte("second"), 0644))
+	chdir(t, first)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("provider: openai\nbase_url: http://localhost:1/v1\n"), 0644))
+	t.Setenv("AGENT_PROFILE", "")
+	global := &globalOptions{configPath: path}
+
+	cfg, err := global.resolveConfig("")
+	require.NoError(t, err)
+	agent := NewAgent(nil, nil, nil)
+	_, err = agent.applyConfig(cfg)
+	require.NoError(t, err)
+	agent.resolveProfile = global.resolveConfig
+	assert.Contains(t, toolNames(agent.tools), "shell")
+
+	t.Run("切换工作区后重新加载项目配置", func(t *testing.T) {
+		out := captureStdout(func() {
+			_, err := agent.handleCommand("/cd " + second)
+			require.NoError(t, err)
+		})
+		dir, err := filepath.EvalSymlinks(second)
+		require.NoError(t, err)
+		cwd, err := os.Getwd()
+		require.NoError(t, err)
+		cwd, err = filepath.EvalSymlinks(cwd)
+		require.NoError(t, err)
+		assert.Equal(t, dir, cwd)
+		assert.Contains(t, out, "Workspace: ")
+		assert.NotContains(t, toolNames(agent.tools), "shell", "新工作区的 .agent.yaml 禁用了 shell")
+
+		result, err := currentWorkspace.ReadFile(context.Background(), []byte(`{"path":"notes.txt"}`))
+		require.NoError(t, err)
+		assert.Contains(t, result, "second")
+
+		msgs := agent.Messages()
+		require.NotEmpty(t, msgs)
+		assert.Contains(t, msgs[len(msgs)-1].Content, "The workspace is now")
+	})
+
+	t.Run("目录不存在时留在原处", func(t *testing.T) {
+		before, err := os.Getwd()
+		require.NoError(t, err)
+		_, err = agent.handleCommand("/cd " + filepath.Join(first, "missing"))
+		assert.Error(t, err)
+		after, err := os.Getwd()
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("无法回到原目录时同时报告两个错误", func(t *testing.T) {
+		gone := filepath.Join(t.TempDir(), "gone")
+		require.NoError(t, os.Mkdir(gone, 0755))
+		chdir(t, gone)
+		resolve := agent.resolveProfile
+		t.Cleanup(func() { agent.resolveProfile = resolve })
+		agent.resolveProfile = func(string) (*config.Config, error) {
+			require.NoError(t, os.Remove(gone))
+			return nil, errors.New("bad profile")
+		}
+
+		_, err := agent.handleCommand("/cd " + second)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bad profile")
+		assert.Contains(t, err.Error(), gone)
+	})
+
+	t.Run("没有参数时显示工作区", func(t *testing.T) {
+		out := captureStdout(func() {
+			_, err := agent.handleCommand("/cd")
+			require.NoError(t, err)
+		})
+		cwd, err := os.Getwd()
+		require.NoError(t, err)
+		assert.Equal(t, cwd+"\n", out)
+	})
+}
+
+func TestWorkspaceIndex(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	t.Cleanup(closeWorkspaceIndexes)
+	ollama := config.Embeddings{Provider: "ollama"}
+
+	index, err := workspaceIndex(first)
+	require.NoError(t, err)
+	again, err := workspaceIndex(first)
+	require.NoError(t, err)
+	assert.Same(t, index, again, "同一工作区共用一个索引")
+	semantic := workspaceEmbeddings(index, ollama, nil)
+	assert.Same(t, semantic, workspaceEmbeddings(index, ollama, nil), "重建工具时沿用已经计算的向量")
+
+	t.Run("切换工作区后关闭原来的索引", func(t *testing.T) {
+		other, err := workspaceIndex(second)
+		require.NoError(t, err)
+		assert.NotSame(t, index, other)
+		assert.NotSame(t, semantic, workspaceEmbeddings(other, ollama, nil))
+
+		require.NoError(t, os.WriteFile(filepath.Join(first, "late.go"), []byte("package late\n"), 0644))
+		time.Sleep(100 * time.Millisecond)
+		assert.Empty(t, index.Files(), "原来的工作区不再被监视")
+	})
+}
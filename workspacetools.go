@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"agent/config"
+	"agent/gitutil"
+	"agent/lsp"
+	"agent/pkg/provider"
+	"agent/search"
+	"agent/tools"
+
+	"github.com/openai/openai-go/option"
+)
+
+// lspClients shares one language server per workspace root and command, so
+// rebuilding the tools (/tools, /profile) does not start another server
+var lspClients = struct {
+	sync.Mutex
+	m map[string]*lsp.Client
+}{m: map[string]*lsp.Client{}}
+
+// toolEnv describes the environment the registered tools are built for:
+// cfg's shell policy and integrations, in workspace
+func toolEnv(cfg *config.Config, workspace *tools.Workspace) tools.Env {
+	env := tools.Env{
+		Workspace: workspace,
+		Policy:    tools.CommandPolicy{Allow: cfg.Shell.Allow, Deny: cfg.Shell.Deny},
+		GitHub: &tools.GitHub{
+			Remote: cfg.GitHub.Remote,
+			APIURL: cfg.GitHub.APIURL,
+			Token: func() string {
+				token, _ := lookupAPIKey("github", cfg.GitHub.TokenEnv)
+				return token
+			},
+		},
+		GitLab: &tools.GitLab{
+			Token: func() string {
+				token, _ := lookupAPIKey("gitlab", "")
+				return token
+			},
+		},
+	}
+	// The git and code intelligence tools work on local files only
+	if workspace.Remote == nil {
+		_, err := gitutil.Open(workspace.Root)
+		env.Repo = err == nil
+		env.LSP = lspClient(workspace, cfg.LSP)
+		if _, err := os.Stat(filepath.Join(workspace.Root, "go.mod")); err == nil {
+			env.GoModule = true
+		}
+		env.Projects = tools.DetectProjects(workspace.Root)
+	}
+	if len(cfg.Storage.Buckets) > 0 {
+		env.Storage = &tools.Storage{Buckets: cfg.Storage.Buckets}
+	}
+	if k := cfg.Kubernetes; k.Enabled {
+		env.Kubernetes = &tools.Kubernetes{Context: k.Context, Namespace: k.Namespace, Verbs: k.Verbs, Secrets: k.AllowSecrets, Raw: k.AllowRaw}
+	}
+	if cfg.Docker.Enabled {
+		env.Docker = &tools.Docker{Runtime: cfg.Docker.Runtime}
+	}
+	// The tracker tool is offered when Jira has a site URL or Linear has an API key
+	if cfg.Tracker.Jira.URL != "" {
+		env.Jira = &tools.Jira{
+			URL:   cfg.Tracker.Jira.URL,
+			Email: cfg.Tracker.Jira.Email,
+			Token: func() string {
+				token, _ := lookupAPIKey("jira", cfg.Tracker.Jira.TokenEnv)
+				return token
+			},
+		}
+	}
+	if token, _ := lookupAPIKey("linear", cfg.Tracker.Linear.TokenEnv); token != "" {
+		env.Linear = &tools.Linear{
+			Token: func() string {
+				token, _ := lookupAPIKey("linear", cfg.Tracker.Linear.TokenEnv)
+				return token
+			},
+		}
+	}
+	if embeddings := embeddingProvider(cfg.Embeddings); embeddings != nil {
+		env.Embeddings = embeddings
+	}
+	return env
+}
+
+// shellContainer returns the container shell commands run in, nil when they
+// run on the host
+func shellContainer(cfg config.Shell) *tools.Container {
+	if cfg.Backend == "" || cfg.Backend == "host" {
+		return nil
+	}
+	return &tools.Container{Runtime: cfg.Backend, Image: cfg.Image, Network: cfg.Network}
+}
+
+// embeddingProvider returns the configured embedding model, or nil when none
+// is configured or its API key is missing
+func embeddingProvider(cfg config.Embeddings) provider.EmbeddingProvider {
+	switch cfg.Provider {
+	case "openai":
+		key, _ := lookupAPIKey(cfg.Provider, cfg.APIKeyEnv)
+		if key == "" && cfg.BaseURL == "" {
+			return nil
+		}
+		var opts []option.RequestOption
+		if cfg.BaseURL != "" {
+			opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+		}
+		e := provider.NewOpenAIEmbeddings(key, opts...)
+		if cfg.Model != "" {
+			e.Model = cfg.Model
+		}
+		return e
+	case "gemini":
+		key, _ := lookupAPIKey(cfg.Provider, cfg.APIKeyEnv)
+		if key == "" {
+			return nil
+		}
+		e := provider.NewGemini(key)
+		if cfg.Model != "" {
+			e.Model = cfg.Model
+		}
+		if cfg.BaseURL != "" {
+			e.BaseURL = cfg.BaseURL
+		}
+		return e
+	case "ollama":
+		e := provider.NewOllama()
+		if cfg.Model != "" {
+			e.Model = cfg.Model
+		}
+		if cfg.BaseURL != "" {
+			e.BaseURL = cfg.BaseURL
+		}
+		return e
+	}
+	return nil
+}
+
+// customTools returns the external tools declared in cfg and the tools
+// defined by the project scripts, run in workspace. Relative command paths
+// are resolved against the project root.
+func customTools(cfg *config.Config, workspace *tools.Workspace) ([]tools.ToolDefinition, error) {
+	defs := []tools.ToolDefinition{}
+	for _, custom := range cfg.Tools.Custom {
+		if _, ok := tools.Default.Lookup(custom.Name); ok {
+			return nil, fmt.Errorf("custom tool %s has the name of a built-in tool", custom.Name)
+		}
+		command := append([]string{}, custom.Command...)
+		if filepath.Base(command[0]) != command[0] {
+			path, err := cfg.ResolvePath(command[0])
+			if err != nil {
+				return nil, err
+			}
+			command[0] = path
+		}
+		defs = append(defs, tools.ExternalTool(workspace, tools.External{
+			Name:        custom.Name,
+			Description: custom.Description,
+			Command:     command,
+			Schema:      custom.Schema,
+			ReadOnly:    custom.ReadOnly,
+			Timeout:     custom.Timeout,
+		}))
+	}
+	scripted, err := scriptTools(cfg, workspace)
+	if err != nil {
+		return nil, err
+	}
+	return append(defs, scripted...), nil
+}
+
+// isCustomTool reports whether cfg declares a custom tool called name, or
+// the project scripts define one
+func isCustomTool(cfg *config.Config, name string) bool {
+	for _, custom := range cfg.Tools.Custom {
+		if custom.Name == name {
+			return true
+		}
+	}
+	set, _ := scriptLoader(cfg).Load()
+	_, ok := set.Tool(name)
+	return ok
+}
+
+// lspClient returns the language server for the code intelligence tools, or
+// nil when they should not be offered: they are for Go modules only, unless
+// disabled in the config
+func lspClient(workspace *tools.Workspace, cfg config.LSP) *lsp.Client {
+	root, err := filepath.Abs(workspace.Root)
+	if err != nil {
+		root = workspace.Root
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil || cfg.Disabled || len(cfg.Command) == 0 {
+		return nil
+	}
+	key := root + "\x00" + strings.Join(cfg.Command, "\x00")
+	lspClients.Lock()
+	defer lspClients.Unlock()
+	client, ok := lspClients.m[key]
+	if !ok {
+		client = &lsp.Client{Root: root, Command: cfg.Command, Extensions: []string{".go", "go.mod", "go.sum", "go.work"}}
+		lspClients.m[key] = client
+	}
+	return client
+}
+
+// workspaceIndexes holds the watched index of the workspace, shared by the
+// sessions and rebuilt tools of the process, and its vector indexes by
+// embedding model. There is one workspace at a time, the working directory,
+// so the index of the previous one is closed when the workspace changes
+// (/cd, queued tasks, eval runs).
+var workspaceIndexes = struct {
+	sync.Mutex
+	index    *search.Index
+	semantic map[string]*search.Embeddings
+}{}
+
+// workspaceIndex returns the index of the workspace at root, building it and
+// starting to watch the workspace on first use
+func workspaceIndex(root string) (*search.Index, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	workspaceIndexes.Lock()
+	defer workspaceIndexes.Unlock()
+	if index := workspaceIndexes.index; index != nil {
+		if index.Root() == root {
+			return index, nil
+		}
+		index.Close()
+		workspaceIndexes.index, workspaceIndexes.semantic = nil, nil
+	}
+	index, err := search.NewIndex(context.Background(), root)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Watch(); err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	workspaceIndexes.index, workspaceIndexes.semantic = index, map[string]*search.Embeddings{}
+	return index, nil
+}
+
+// workspaceEmbeddings returns the vector index of the workspace's files for
+// the embedding model cfg, built on index, reusing the vectors computed so
+// far when the tools are rebuilt
+func workspaceEmbeddings(index *search.Index, cfg config.Embeddings, embedder tools.Embedder) *search.Embeddings {
+	key := cfg.Provider + "\x00" + cfg.Model + "\x00" + cfg.BaseURL
+	workspaceIndexes.Lock()
+	defer workspaceIndexes.Unlock()
+	if workspaceIndexes.index != index {
+		// the workspace changed since index was returned
+		return search.NewEmbeddings(index, embedder)
+	}
+	e, ok := workspaceIndexes.semantic[key]
+	if !ok {
+		e = search.NewEmbeddings(index, embedder)
+		workspaceIndexes.semantic[key] = e
+	}
+	return e
+}
+
+// closeWorkspaceIndexes stops watching the workspace
+func closeWorkspaceIndexes() {
+	workspaceIndexes.Lock()
+	defer workspaceIndexes.Unlock()
+	if workspaceIndexes.index != nil {
+		workspaceIndexes.index.Close()
+		workspaceIndexes.index, workspaceIndexes.semantic = nil, nil
+	}
+}